@@ -3,17 +3,20 @@ package action
 import (
 	"os"
 	"time"
+
+	"github.com/pivotal-golang/clock"
 )
 
 type AgentKiller struct {
+	clock clock.Clock
 }
 
-func NewAgentKiller() AgentKiller {
-	return AgentKiller{}
+func NewAgentKiller(clock clock.Clock) AgentKiller {
+	return AgentKiller{clock: clock}
 }
 
 func (a AgentKiller) KillAgent(waitToKillAgentInterval time.Duration) {
-	time.Sleep(waitToKillAgentInterval)
+	a.clock.Sleep(waitToKillAgentInterval)
 
 	os.Exit(0)
 
@@ -7,6 +7,8 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshdnsupdate "github.com/cloudfoundry/bosh-agent/platform/dnsupdate"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -23,6 +25,10 @@ type ApplyAction struct {
 	settingsService boshsettings.Service
 	instanceDir     string
 	fs              boshsys.FileSystem
+	dnsUpdater      boshdnsupdate.Updater
+
+	freeSpaceChecker boshstats.FreeSpaceChecker
+	dataDir          string
 }
 
 func NewApply(
@@ -31,12 +37,18 @@ func NewApply(
 	settingsService boshsettings.Service,
 	instanceDir string,
 	fs boshsys.FileSystem,
+	dnsUpdater boshdnsupdate.Updater,
+	freeSpaceChecker boshstats.FreeSpaceChecker,
+	dataDir string,
 ) (action ApplyAction) {
 	action.applier = applier
 	action.specService = specService
 	action.settingsService = settingsService
 	action.instanceDir = instanceDir
 	action.fs = fs
+	action.dnsUpdater = dnsUpdater
+	action.freeSpaceChecker = freeSpaceChecker
+	action.dataDir = dataDir
 	return
 }
 
@@ -49,6 +61,14 @@ func (a ApplyAction) IsPersistent() bool {
 }
 
 func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
+	// Specs without configuration hash only carry networks
+	if desiredSpec.ConfigurationHash != "" {
+		err := desiredSpec.Validate()
+		if err != nil {
+			return "", bosherr.WrapError(err, "Validating apply spec")
+		}
+	}
+
 	settings := a.settingsService.GetSettings()
 
 	resolvedDesiredSpec, err := a.specService.PopulateDHCPNetworks(desiredSpec, settings)
@@ -62,6 +82,12 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 			return "", bosherr.WrapError(err, "Getting current spec")
 		}
 
+		// Jobs and packages are installed onto the data disk
+		err = a.freeSpaceChecker.CheckFreeSpace(a.dataDir)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Applying")
+		}
+
 		err = a.applier.Apply(currentSpec, resolvedDesiredSpec)
 		if err != nil {
 			return "", bosherr.WrapError(err, "Applying")
@@ -78,6 +104,8 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 		return "", err
 	}
 
+	a.dnsUpdater.Update(settings.Env.Bosh.DNSUpdate, resolvedDesiredSpec.DNSRecords())
+
 	return "applied", nil
 }
 
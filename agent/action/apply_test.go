@@ -11,6 +11,9 @@ import (
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	fakednsupdate "github.com/cloudfoundry/bosh-agent/platform/dnsupdate/fakes"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
@@ -27,6 +30,9 @@ func init() {
 			dirProvider     boshdir.Provider
 			action          ApplyAction
 			fs              boshsys.FileSystem
+			dnsUpdater      *fakednsupdate.FakeUpdater
+
+			freeSpaceChecker *fakestats.FakeFreeSpaceChecker
 		)
 
 		BeforeEach(func() {
@@ -35,7 +41,9 @@ func init() {
 			settingsService = &fakesettings.FakeSettingsService{}
 			dirProvider = boshdir.NewProvider("/var/vcap")
 			fs = fakesys.NewFakeFileSystem()
-			action = NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), fs)
+			dnsUpdater = &fakednsupdate.FakeUpdater{}
+			freeSpaceChecker = &fakestats.FakeFreeSpaceChecker{}
+			action = NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), fs, dnsUpdater, freeSpaceChecker, dirProvider.DataDir())
 		})
 
 		It("apply should be asynchronous", func() {
@@ -55,11 +63,25 @@ func init() {
 
 			Context("when desired spec has configuration hash", func() {
 				currentApplySpec := boshas.V1ApplySpec{ConfigurationHash: "fake-current-config-hash"}
-				desiredApplySpec := boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash"}
+				jobName := "fake-job-name"
+				desiredApplySpec := boshas.V1ApplySpec{
+					ConfigurationHash: "fake-desired-config-hash",
+					Deployment:        "fake-deployment",
+					JobSpec:           boshas.JobSpec{Name: &jobName},
+				}
 				populatedDesiredApplySpec := boshas.V1ApplySpec{
 					ConfigurationHash: "fake-populated-desired-config-hash",
 				}
 
+				It("returns error without applying when desired spec is invalid", func() {
+					_, err := action.Run(boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash"})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Validating apply spec: Missing deployment\nMissing job name"))
+
+					Expect(specService.PopulateDHCPNetworksSpec).To(Equal(boshas.V1ApplySpec{}))
+					Expect(applier.Applied).To(BeFalse())
+				})
+
 				Context("when current spec can be retrieved", func() {
 					BeforeEach(func() {
 						specService.Spec = currentApplySpec
@@ -77,6 +99,27 @@ func init() {
 							specService.PopulateDHCPNetworksResultSpec = populatedDesiredApplySpec
 						})
 
+						It("checks free space on data dir before applying", func() {
+							_, err := action.Run(desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(freeSpaceChecker.CheckFreeSpaceMountedPaths).To(Equal([]string{"/var/vcap/data"}))
+						})
+
+						It("does not apply when there is insufficient disk space", func() {
+							freeSpaceChecker.CheckFreeSpaceErr = boshstats.InsufficientSpaceError{
+								MountedPath: "/var/vcap/data",
+								RequiredMB:  256,
+								AvailableMB: 10,
+							}
+
+							_, err := action.Run(desiredApplySpec)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("Insufficient disk space on /var/vcap/data: required 256MB, available 10MB"))
+
+							Expect(applier.Applied).To(BeFalse())
+							Expect(specService.Spec).To(Equal(currentApplySpec))
+						})
+
 						It("runs applier with populated desired spec", func() {
 							_, err := action.Run(desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
@@ -95,10 +138,36 @@ func init() {
 									Expect(specService.Spec).To(Equal(populatedDesiredApplySpec))
 								})
 
+								Context("when desired spec has networks with dns record names", func() {
+									BeforeEach(func() {
+										settingsService.Settings.Env.Bosh.DNSUpdate = boshsettings.DNSUpdate{Key: "fake-key"}
+										specService.PopulateDHCPNetworksResultSpec = boshas.V1ApplySpec{
+											ConfigurationHash: "fake-populated-desired-config-hash",
+											NetworkSpecs: map[string]boshas.NetworkSpec{
+												"fake-net": boshas.NetworkSpec{
+													Fields: map[string]interface{}{
+														"dns_record_name": "fake-record-name",
+														"ip":              "fake-ip",
+													},
+												},
+											},
+										}
+									})
+
+									It("updates dns records using the dns update settings", func() {
+										_, err := action.Run(desiredApplySpec)
+										Expect(err).ToNot(HaveOccurred())
+
+										Expect(dnsUpdater.UpdateCalled).To(BeTrue())
+										Expect(dnsUpdater.UpdateConfig).To(Equal(boshsettings.DNSUpdate{Key: "fake-key"}))
+										Expect(dnsUpdater.UpdateRecords).To(Equal(map[string]string{"fake-record-name": "fake-ip"}))
+									})
+								})
+
 								Context("desired spec has id, instance name, deployment name, and az", func() {
 
 									BeforeEach(func() {
-										desiredApplySpec = boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash", NodeID: "node-id01-123f-r2344", AvailabilityZone: "ex-az", Deployment: "deployment-name", Name: "instance-name"}
+										desiredApplySpec = boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash", JobSpec: boshas.JobSpec{Name: &jobName}, NodeID: "node-id01-123f-r2344", AvailabilityZone: "ex-az", Deployment: "deployment-name", Name: "instance-name"}
 										specService.PopulateDHCPNetworksResultSpec = desiredApplySpec
 									})
 
@@ -0,0 +1,14 @@
+package action
+
+// Actions that stop running once canceled, e.g. by killing their scripts;
+// only they are run with timeout requested by director
+var cancelableActions = map[string]bool{
+	"drain":      true,
+	"stop":       true,
+	"restart":    true,
+	"run_errand": true,
+}
+
+func IsCancelable(method string) bool {
+	return cancelableActions[method]
+}
@@ -2,18 +2,32 @@ package action
 
 import (
 	"errors"
+	"os"
 
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 type CompilePackageAction struct {
-	compiler boshcomp.Compiler
+	compiler         boshcomp.Compiler
+	freeSpaceChecker boshstats.FreeSpaceChecker
+	fs               boshsys.FileSystem
+	compileDir       string
 }
 
-func NewCompilePackage(compiler boshcomp.Compiler) (compilePackage CompilePackageAction) {
+func NewCompilePackage(
+	compiler boshcomp.Compiler,
+	freeSpaceChecker boshstats.FreeSpaceChecker,
+	fs boshsys.FileSystem,
+	compileDir string,
+) (compilePackage CompilePackageAction) {
 	compilePackage.compiler = compiler
+	compilePackage.freeSpaceChecker = freeSpaceChecker
+	compilePackage.fs = fs
+	compilePackage.compileDir = compileDir
 	return
 }
 
@@ -33,6 +47,24 @@ func (a CompilePackageAction) Run(blobID, sha1, name, version string, deps boshc
 		Version:     version,
 	}
 
+	return a.compile(pkg, deps)
+}
+
+func (a CompilePackageAction) compile(pkg boshcomp.Package, deps boshcomp.Dependencies) (val map[string]interface{}, err error) {
+	// Compile dir is otherwise only created by compiler once compilation starts
+	err = a.fs.MkdirAll(a.compileDir, os.ModePerm)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Creating compile dir for package %s", pkg.Name)
+		return
+	}
+
+	// Fail early instead of failing in the middle of compilation
+	err = a.freeSpaceChecker.CheckFreeSpace(a.compileDir)
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Compiling package %s", pkg.Name)
+		return
+	}
+
 	modelsDeps := []boshmodels.Package{}
 
 	for _, dep := range deps {
@@ -10,6 +10,9 @@ import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
 	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 func getCompileActionArguments() (blobID, sha1, name, version string, deps boshcomp.Dependencies) {
@@ -36,13 +39,17 @@ func getCompileActionArguments() (blobID, sha1, name, version string, deps boshc
 
 var _ = Describe("CompilePackageAction", func() {
 	var (
-		compiler *fakecomp.FakeCompiler
-		action   CompilePackageAction
+		compiler         *fakecomp.FakeCompiler
+		freeSpaceChecker *fakestats.FakeFreeSpaceChecker
+		fs               *fakesys.FakeFileSystem
+		action           CompilePackageAction
 	)
 
 	BeforeEach(func() {
 		compiler = fakecomp.NewFakeCompiler()
-		action = NewCompilePackage(compiler)
+		freeSpaceChecker = &fakestats.FakeFreeSpaceChecker{}
+		fs = fakesys.NewFakeFileSystem()
+		action = NewCompilePackage(compiler, freeSpaceChecker, fs, "/fake-compile-dir")
 	})
 
 	It("is asynchronous", func() {
@@ -101,6 +108,38 @@ var _ = Describe("CompilePackageAction", func() {
 			Expect(compiler.CompileDeps).To(ConsistOf(expectedDeps))
 		})
 
+		It("checks free space on compile dir before compiling", func() {
+			_, err := action.Run(getCompileActionArguments())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freeSpaceChecker.CheckFreeSpaceMountedPaths).To(Equal([]string{"/fake-compile-dir"}))
+			Expect(fs.FileExists("/fake-compile-dir")).To(BeTrue())
+		})
+
+		It("does not compile when compile dir cannot be created", func() {
+			fs.MkdirAllError = errors.New("fake-mkdir-err")
+
+			_, err := action.Run(getCompileActionArguments())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-mkdir-err"))
+
+			Expect(freeSpaceChecker.CheckFreeSpaceMountedPaths).To(BeEmpty())
+			Expect(compiler.CompilePkg).To(Equal(boshcomp.Package{}))
+		})
+
+		It("does not compile when there is insufficient disk space", func() {
+			freeSpaceChecker.CheckFreeSpaceErr = boshstats.InsufficientSpaceError{
+				MountedPath: "/fake-compile-dir",
+				RequiredMB:  256,
+				AvailableMB: 10,
+			}
+
+			_, err := action.Run(getCompileActionArguments())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Insufficient disk space on /fake-compile-dir: required 256MB, available 10MB"))
+
+			Expect(compiler.CompilePkg).To(Equal(boshcomp.Package{}))
+		})
+
 		It("returns error when compile fails", func() {
 			compiler.CompileErr = errors.New("fake-compile-error")
 
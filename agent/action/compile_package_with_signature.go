@@ -0,0 +1,58 @@
+package action
+
+import (
+	"errors"
+
+	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type CompilePackageWithSignatureAction struct {
+	compilePackage CompilePackageAction
+}
+
+// NewCompilePackageWithSignature compiles package only after its source
+// matches detached signature verified by the compiler
+func NewCompilePackageWithSignature(
+	compiler boshcomp.Compiler,
+	freeSpaceChecker boshstats.FreeSpaceChecker,
+	fs boshsys.FileSystem,
+	compileDir string,
+) CompilePackageWithSignatureAction {
+	return CompilePackageWithSignatureAction{
+		compilePackage: NewCompilePackage(compiler, freeSpaceChecker, fs, compileDir),
+	}
+}
+
+func (a CompilePackageWithSignatureAction) IsAsynchronous() bool {
+	return true
+}
+
+func (a CompilePackageWithSignatureAction) IsPersistent() bool {
+	return false
+}
+
+func (a CompilePackageWithSignatureAction) Run(blobID, sha1, name, version, signature string, deps boshcomp.Dependencies) (map[string]interface{}, error) {
+	if signature == "" {
+		return nil, errors.New("Package signature must be specified")
+	}
+
+	pkg := boshcomp.Package{
+		BlobstoreID: blobID,
+		Name:        name,
+		Sha1:        sha1,
+		Version:     version,
+		Signature:   signature,
+	}
+
+	return a.compilePackage.compile(pkg, deps)
+}
+
+func (a CompilePackageWithSignatureAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a CompilePackageWithSignatureAction) Cancel() error {
+	return errors.New("not supported")
+}
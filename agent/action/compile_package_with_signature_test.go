@@ -0,0 +1,82 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CompilePackageWithSignatureAction", func() {
+	var (
+		compiler         *fakecomp.FakeCompiler
+		freeSpaceChecker *fakestats.FakeFreeSpaceChecker
+		action           CompilePackageWithSignatureAction
+	)
+
+	BeforeEach(func() {
+		compiler = fakecomp.NewFakeCompiler()
+		freeSpaceChecker = &fakestats.FakeFreeSpaceChecker{}
+		action = NewCompilePackageWithSignature(compiler, freeSpaceChecker, fakesys.NewFakeFileSystem(), "/fake-compile-dir")
+	})
+
+	It("is asynchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeTrue())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	Describe("Run", func() {
+		It("compiles package with given signature so that compiler verifies it", func() {
+			compiler.CompileBlobID = "my-blob-id"
+			compiler.CompileSha1 = "some sha1"
+
+			blobID, sha1, name, version, deps := getCompileActionArguments()
+
+			value, err := action.Run(blobID, sha1, name, version, "fake-signature", deps)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(map[string]interface{}{
+				"result": map[string]string{
+					"blobstore_id": "my-blob-id",
+					"sha1":         "some sha1",
+				},
+			}))
+
+			Expect(compiler.CompilePkg).To(Equal(boshcomp.Package{
+				BlobstoreID: "fake-blobstore-id",
+				Sha1:        "fake-sha1",
+				Name:        "fake-package-name",
+				Version:     "fake-package-version",
+				Signature:   "fake-signature",
+			}))
+			Expect(freeSpaceChecker.CheckFreeSpaceMountedPaths).To(Equal([]string{"/fake-compile-dir"}))
+		})
+
+		It("returns error without compiling when signature is empty", func() {
+			blobID, sha1, name, version, deps := getCompileActionArguments()
+
+			_, err := action.Run(blobID, sha1, name, version, "", deps)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Package signature must be specified"))
+			Expect(compiler.CompilePkg).To(Equal(boshcomp.Package{}))
+		})
+
+		It("returns error when compiler refuses package signature", func() {
+			compiler.CompileErr = errors.New("fake-signature-err")
+
+			blobID, sha1, name, version, deps := getCompileActionArguments()
+
+			_, err := action.Run(blobID, sha1, name, version, "fake-signature", deps)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Compiling package fake-package-name: fake-signature-err"))
+		})
+	})
+})
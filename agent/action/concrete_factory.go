@@ -1,6 +1,10 @@
 package action
 
 import (
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
@@ -9,7 +13,9 @@ import (
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshdnsupdate "github.com/cloudfoundry/bosh-agent/platform/dnsupdate"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -33,6 +39,10 @@ func NewFactory(
 	specService boshas.V1Service,
 	jobScriptProvider boshscript.JobScriptProvider,
 	scriptCommandFactory boshsys.ScriptCommandFactory,
+	freeSpaceChecker boshstats.FreeSpaceChecker,
+	timeService clock.Clock,
+	bootstrapTime time.Time,
+	restartGracePeriod time.Duration,
 	logger boshlog.Logger,
 ) (factory Factory) {
 	compressor := platform.GetCompressor()
@@ -42,6 +52,9 @@ func NewFactory(
 	certManager := platform.GetCertManager()
 	ntpService := boshntp.NewConcreteService(platform.GetFs(), dirProvider)
 
+	startAction := NewStart(jobSupervisor, applier, specService, jobScriptProvider)
+	stopAction := NewStop(jobSupervisor, specService, NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger))
+
 	factory = concreteFactory{
 		availableActions: map[string]Action{
 			// Task management
@@ -51,36 +64,46 @@ func NewFactory(
 
 			// VM admin
 			"ssh":             NewSSH(settingsService, platform, dirProvider, logger),
-			"fetch_logs":      NewFetchLogs(compressor, copier, blobstore, dirProvider),
+			"fetch_logs":      NewFetchLogs(compressor, copier, blobstore, dirProvider, platform.GetFs()),
 			"update_settings": NewUpdateSettings(certManager, logger),
+			"get_settings":    NewGetSettings(settingsService),
+			"get_public_key":  NewGetPublicKey(platform.GetFs(), dirProvider),
+			"run_diagnostic":  NewRunDiagnostic(platform.GetRunner()),
+			"sync_ntp":        NewSyncNtp(platform),
 
 			// Job management
 			"prepare":    NewPrepare(applier),
-			"apply":      NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), platform.GetFs()),
-			"start":      NewStart(jobSupervisor, applier, specService),
-			"stop":       NewStop(jobSupervisor),
+			"apply":      NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), platform.GetFs(), boshdnsupdate.NewConcreteUpdater(platform.GetRunner(), logger), freeSpaceChecker, dirProvider.DataDir()),
+			"start":      startAction,
+			"stop":       stopAction,
+			"restart":    NewRestart(stopAction, startAction, restartGracePeriod, timeService),
+			"reboot":     NewReboot(jobSupervisor, platform, timeService, logger),
 			"drain":      NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger),
-			"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService),
-			"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), scriptCommandFactory, platform.GetRunner(), logger),
+			"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService, platform, timeService, bootstrapTime),
+			"run_errand": NewRunErrand(specService, settingsService, dirProvider.JobsDir(), scriptCommandFactory, platform.GetRunner(), logger),
 			"run_script": NewRunScript(jobScriptProvider, specService, logger),
 
+			"list_running_jobs": NewListRunningJobs(jobSupervisor),
+
 			// Compilation
-			"compile_package":    NewCompilePackage(compiler),
-			"release_apply_spec": NewReleaseApplySpec(platform),
+			"compile_package":                NewCompilePackage(compiler, freeSpaceChecker, platform.GetFs(), dirProvider.CompileDir()),
+			"compile_package_with_signature": NewCompilePackageWithSignature(compiler, freeSpaceChecker, platform.GetFs(), dirProvider.CompileDir()),
+			"release_apply_spec":             NewReleaseApplySpec(platform),
 
 			// Disk management
-			"list_disk":    NewListDisk(settingsService, platform, logger),
-			"migrate_disk": NewMigrateDisk(platform, dirProvider),
-			"mount_disk":   NewMountDisk(settingsService, platform, dirProvider, logger),
-			"unmount_disk": NewUnmountDisk(settingsService, platform),
+			"list_disk":           NewListDisk(settingsService, platform, logger),
+			"get_persistent_disk": NewGetPersistentDisk(settingsService, platform, logger),
+			"migrate_disk":        NewMigrateDisk(platform, dirProvider),
+			"mount_disk":          NewMountDisk(settingsService, platform, logger),
+			"unmount_disk":        NewUnmountDisk(settingsService, platform),
 
 			// ARP cache management
-			"delete_arp_entries": NewDeleteARPEntries(platform),
+			"delete_arp_entries": NewDeleteARPEntries(platform, logger),
 
 			// Networkingconcrete_factory_test.go
-			"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller()),
+			"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller(timeService)),
 			"prepare_configure_networks": NewPrepareConfigureNetworks(platform, settingsService),
-			"configure_networks":         NewConfigureNetworks(NewAgentKiller()),
+			"configure_networks":         NewConfigureNetworks(NewAgentKiller(timeService)),
 		},
 	}
 	return
@@ -1,10 +1,14 @@
 package action_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
 	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
@@ -14,8 +18,10 @@ import (
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
+	boshdnsupdate "github.com/cloudfoundry/bosh-agent/platform/dnsupdate"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
@@ -37,6 +43,9 @@ var _ = Describe("concreteFactory", func() {
 		jobSupervisor     *fakejobsuper.FakeJobSupervisor
 		specService       *fakeas.FakeV1Service
 		jobScriptProvider boshscript.JobScriptProvider
+		freeSpaceChecker  *fakestats.FakeFreeSpaceChecker
+		timeService       clock.Clock
+		bootstrapTime     time.Time
 		factory           Factory
 		logger            boshlog.Logger
 	)
@@ -52,6 +61,9 @@ var _ = Describe("concreteFactory", func() {
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		specService = fakeas.NewFakeV1Service()
 		jobScriptProvider = &fakescript.FakeJobScriptProvider{}
+		freeSpaceChecker = &fakestats.FakeFreeSpaceChecker{}
+		timeService = &fakeaction.FakeClock{}
+		bootstrapTime = time.Date(2016, time.January, 2, 3, 4, 5, 0, time.UTC)
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
 		factory = NewFactory(
@@ -66,6 +78,10 @@ var _ = Describe("concreteFactory", func() {
 			specService,
 			jobScriptProvider,
 			boshsys.NewScriptCommandFactory("linux"),
+			freeSpaceChecker,
+			timeService,
+			bootstrapTime,
+			5*time.Second,
 			logger,
 		)
 	})
@@ -79,7 +95,7 @@ var _ = Describe("concreteFactory", func() {
 	It("apply", func() {
 		action, err := factory.Create("apply")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewApply(applier, specService, settingsService, boshdir.NewProvider("/var/vcap").InstanceDir(), platform.GetFs())))
+		Expect(action).To(Equal(NewApply(applier, specService, settingsService, boshdir.NewProvider("/var/vcap").InstanceDir(), platform.GetFs(), boshdnsupdate.NewConcreteUpdater(platform.GetRunner(), logger), freeSpaceChecker, platform.GetDirProvider().DataDir())))
 	})
 
 	It("drain", func() {
@@ -92,7 +108,7 @@ var _ = Describe("concreteFactory", func() {
 	It("fetch_logs", func() {
 		action, err := factory.Create("fetch_logs")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewFetchLogs(platform.GetCompressor(), platform.GetCopier(), blobstore, platform.GetDirProvider())))
+		Expect(action).To(Equal(NewFetchLogs(platform.GetCompressor(), platform.GetCopier(), blobstore, platform.GetDirProvider(), platform.GetFs())))
 	})
 
 	It("get_task", func() {
@@ -111,7 +127,13 @@ var _ = Describe("concreteFactory", func() {
 		ntpService := boshntp.NewConcreteService(platform.GetFs(), platform.GetDirProvider())
 		action, err := factory.Create("get_state")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewGetState(settingsService, specService, jobSupervisor, platform.GetVitalsService(), ntpService)))
+		Expect(action).To(Equal(NewGetState(settingsService, specService, jobSupervisor, platform.GetVitalsService(), ntpService, platform, timeService, bootstrapTime)))
+	})
+
+	It("get_persistent_disk", func() {
+		action, err := factory.Create("get_persistent_disk")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewGetPersistentDisk(settingsService, platform, logger)))
 	})
 
 	It("list_disk", func() {
@@ -129,7 +151,7 @@ var _ = Describe("concreteFactory", func() {
 	It("mount_disk", func() {
 		action, err := factory.Create("mount_disk")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewMountDisk(settingsService, platform, platform.GetDirProvider(), logger)))
+		Expect(action).To(Equal(NewMountDisk(settingsService, platform, logger)))
 	})
 
 	It("ping", func() {
@@ -141,7 +163,7 @@ var _ = Describe("concreteFactory", func() {
 	It("prepare_network_change", func() {
 		action, err := factory.Create("prepare_network_change")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller())))
+		Expect(action).To(Equal(NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller(timeService))))
 	})
 
 	It("prepare_configure_networks", func() {
@@ -153,7 +175,7 @@ var _ = Describe("concreteFactory", func() {
 	It("configure_networks", func() {
 		action, err := factory.Create("configure_networks")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewConfigureNetworks(NewAgentKiller())))
+		Expect(action).To(Equal(NewConfigureNetworks(NewAgentKiller(timeService))))
 	})
 
 	It("ssh", func() {
@@ -165,13 +187,45 @@ var _ = Describe("concreteFactory", func() {
 	It("start", func() {
 		action, err := factory.Create("start")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewStart(jobSupervisor, applier, specService)))
+		Expect(action).To(Equal(NewStart(jobSupervisor, applier, specService, jobScriptProvider)))
+	})
+
+	It("restart", func() {
+		action, err := factory.Create("restart")
+		Expect(err).ToNot(HaveOccurred())
+		// Cannot do equality check since drain action uses channel in initializer
+		Expect(action).To(BeAssignableToTypeOf(RestartAction{}))
 	})
 
 	It("stop", func() {
 		action, err := factory.Create("stop")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewStop(jobSupervisor)))
+		// Cannot do equality check since drain action uses channel in initializer
+		Expect(action).To(BeAssignableToTypeOf(StopAction{}))
+	})
+
+	It("reboot", func() {
+		action, err := factory.Create("reboot")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewReboot(jobSupervisor, platform, timeService, logger)))
+	})
+
+	It("get_settings", func() {
+		action, err := factory.Create("get_settings")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewGetSettings(settingsService)))
+	})
+
+	It("list_running_jobs", func() {
+		action, err := factory.Create("list_running_jobs")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewListRunningJobs(jobSupervisor)))
+	})
+
+	It("get_public_key", func() {
+		action, err := factory.Create("get_public_key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewGetPublicKey(platform.GetFs(), platform.GetDirProvider())))
 	})
 
 	It("unmount_disk", func() {
@@ -183,7 +237,13 @@ var _ = Describe("concreteFactory", func() {
 	It("compile_package", func() {
 		action, err := factory.Create("compile_package")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewCompilePackage(compiler)))
+		Expect(action).To(Equal(NewCompilePackage(compiler, freeSpaceChecker, platform.GetFs(), platform.GetDirProvider().CompileDir())))
+	})
+
+	It("compile_package_with_signature", func() {
+		action, err := factory.Create("compile_package_with_signature")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewCompilePackageWithSignature(compiler, freeSpaceChecker, platform.GetFs(), platform.GetDirProvider().CompileDir())))
 	})
 
 	It("run_errand", func() {
@@ -206,9 +266,21 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewPrepare(applier)))
 	})
 
+	It("run_diagnostic", func() {
+		action, err := factory.Create("run_diagnostic")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewRunDiagnostic(platform.GetRunner())))
+	})
+
+	It("sync_ntp", func() {
+		action, err := factory.Create("sync_ntp")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewSyncNtp(platform)))
+	})
+
 	It("delete_arp_entries", func() {
 		action, err := factory.Create("delete_arp_entries")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewDeleteARPEntries(platform)))
+		Expect(action).To(Equal(NewDeleteARPEntries(platform, logger)))
 	})
 })
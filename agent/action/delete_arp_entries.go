@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 type DeleteARPEntriesActionArgs struct {
@@ -12,11 +13,16 @@ type DeleteARPEntriesActionArgs struct {
 
 type DeleteARPEntriesAction struct {
 	platform boshplatform.Platform
+
+	logTag string
+	logger boshlog.Logger
 }
 
-func NewDeleteARPEntries(platform boshplatform.Platform) DeleteARPEntriesAction {
+func NewDeleteARPEntries(platform boshplatform.Platform, logger boshlog.Logger) DeleteARPEntriesAction {
 	return DeleteARPEntriesAction{
 		platform: platform,
+		logTag:   "Delete ARP Entries Action",
+		logger:   logger,
 	}
 }
 
@@ -31,7 +37,11 @@ func (a DeleteARPEntriesAction) IsPersistent() bool {
 func (a DeleteARPEntriesAction) Run(args DeleteARPEntriesActionArgs) (interface{}, error) {
 	addresses := args.Ips
 	for _, address := range addresses {
-		a.platform.DeleteARPEntryWithIP(address)
+		// Stale entries are best-effort; a failure for one address should not block the rest
+		err := a.platform.DeleteARPEntryWithIP(address)
+		if err != nil {
+			a.logger.Warn(a.logTag, "Failed to delete ARP entry for %s: %s", address, err.Error())
+		}
 	}
 
 	resultMap := map[string]interface{}{}
@@ -1,11 +1,14 @@
 package action_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 func init() {
@@ -19,7 +22,7 @@ func init() {
 
 		BeforeEach(func() {
 			platform = new(fakeplatform.FakePlatform)
-			action = NewDeleteARPEntries(platform)
+			action = NewDeleteARPEntries(platform, boshlog.NewLogger(boshlog.LevelNone))
 			addresses = []string{"10.0.0.1", "10.0.0.2"}
 			args = DeleteARPEntriesActionArgs{
 				Ips: addresses,
@@ -39,6 +42,15 @@ func init() {
 			_, err := action.Run(args)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(platform.LastIPDeletedFromARP).To(Equal("10.0.0.2"))
+			Expect(platform.DeletedARPEntryIPs).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		})
+
+		It("continues with the remaining IPs when deleting an entry fails", func() {
+			platform.DeleteARPEntryWithIPErr = errors.New("fake-delete-arp-err")
+
+			_, err := action.Run(args)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(platform.DeletedARPEntryIPs).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
 		})
 
 		It("returns an empty map", func() {
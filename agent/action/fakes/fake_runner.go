@@ -1,6 +1,8 @@
 package fakes
 
 import (
+	"context"
+
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 )
 
@@ -9,6 +11,7 @@ type FakeRunner struct {
 	RunPayload []byte
 	RunValue   interface{}
 	RunErr     error
+	RunCtx     context.Context
 
 	ResumeAction  boshaction.Action
 	ResumePayload []byte
@@ -22,6 +25,11 @@ func (runner *FakeRunner) Run(action boshaction.Action, payload []byte) (interfa
 	return runner.RunValue, runner.RunErr
 }
 
+func (runner *FakeRunner) RunContext(ctx context.Context, action boshaction.Action, payload []byte) (interface{}, error) {
+	runner.RunCtx = ctx
+	return runner.Run(action, payload)
+}
+
 func (runner *FakeRunner) Resume(action boshaction.Action, payload []byte) (interface{}, error) {
 	runner.ResumeAction = action
 	runner.ResumePayload = payload
@@ -1,12 +1,21 @@
 package action
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 type FetchLogsAction struct {
@@ -14,6 +23,7 @@ type FetchLogsAction struct {
 	copier      boshcmd.Copier
 	blobstore   boshblob.Blobstore
 	settingsDir boshdirs.Provider
+	fs          boshsys.FileSystem
 }
 
 func NewFetchLogs(
@@ -21,11 +31,13 @@ func NewFetchLogs(
 	copier boshcmd.Copier,
 	blobstore boshblob.Blobstore,
 	settingsDir boshdirs.Provider,
+	fs boshsys.FileSystem,
 ) (action FetchLogsAction) {
 	action.compressor = compressor
 	action.copier = copier
 	action.blobstore = blobstore
 	action.settingsDir = settingsDir
+	action.fs = fs
 	return
 }
 
@@ -64,6 +76,10 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 
 	defer a.copier.CleanUp(tmpDir)
 
+	if streamingBlobstore, ok := a.blobstore.(boshagentblob.StreamingBlobstore); ok {
+		return a.streamLogs(streamingBlobstore, tmpDir)
+	}
+
 	tarball, err := a.compressor.CompressFilesInDir(tmpDir)
 	if err != nil {
 		err = bosherr.WrapError(err, "Making logs tarball")
@@ -91,3 +107,120 @@ func (a FetchLogsAction) Resume() (interface{}, error) {
 func (a FetchLogsAction) Cancel() error {
 	return errors.New("not supported")
 }
+
+// streamLogs pipes tarball directly into the blobstore so that
+// memory and disk usage does not depend on the size of the logs
+func (a FetchLogsAction) streamLogs(blobstore boshagentblob.StreamingBlobstore, dir string) (map[string]string, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	tarErrCh := make(chan error, 1)
+
+	go func() {
+		tarErr := a.writeTarball(dir, pipeWriter)
+		_ = pipeWriter.CloseWithError(tarErr)
+		tarErrCh <- tarErr
+	}()
+
+	digest := sha1.New()
+
+	blobID, err := blobstore.CreateFromReader(io.TeeReader(pipeReader, digest))
+
+	// Unblocks tarball writer if blobstore stopped reading early
+	_ = pipeReader.Close()
+
+	tarErr := <-tarErrCh
+
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Create file on blobstore")
+	}
+
+	if tarErr != nil {
+		return nil, bosherr.WrapError(tarErr, "Making logs tarball")
+	}
+
+	return map[string]string{
+		"blobstore_id": blobID,
+		"sha1":         fmt.Sprintf("%x", digest.Sum(nil)),
+	}, nil
+}
+
+func (a FetchLogsAction) writeTarball(dir string, writer io.Writer) error {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := a.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Relativizing '%s'", path)
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		return a.addToTarball(tarWriter, path, "./"+filepath.ToSlash(relPath), info)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return bosherr.WrapError(err, "Closing tar writer")
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return bosherr.WrapError(err, "Closing gzip writer")
+	}
+
+	return nil
+}
+
+func (a FetchLogsAction) addToTarball(tarWriter *tar.Writer, path, name string, info os.FileInfo) error {
+	var linkTarget string
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := a.fs.ReadLink(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading link '%s'", path)
+		}
+		linkTarget = target
+	}
+
+	header, err := tar.FileInfoHeader(info, linkTarget)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating tar header for '%s'", path)
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing tar header for '%s'", path)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := a.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", path)
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(tarWriter, file)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Adding '%s' to tarball", path)
+	}
+
+	return nil
+}
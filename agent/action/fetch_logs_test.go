@@ -1,16 +1,30 @@
 package action_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore/fakes"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("FetchLogsAction", func() {
@@ -27,7 +41,7 @@ var _ = Describe("FetchLogsAction", func() {
 		blobstore = &fakeblobstore.FakeBlobstore{}
 		dirProvider = boshdirs.NewProvider("/fake/dir")
 		copier = fakecmd.NewFakeCopier()
-		action = NewFetchLogs(compressor, copier, blobstore, dirProvider)
+		action = NewFetchLogs(compressor, copier, blobstore, dirProvider, fakesys.NewFakeFileSystem())
 	})
 
 	It("logs should be asynchronous", func() {
@@ -114,5 +128,137 @@ var _ = Describe("FetchLogsAction", func() {
 			afterCleanUpTarballPath = compressor.CleanUpTarballPath
 			Expect(afterCleanUpTarballPath).To(Equal("/fake-compressed-logs.tar"))
 		})
+
+		Context("when blobstore supports streaming", func() {
+			var (
+				streamingBlobstore *fakeagentblob.FakeStreamingBlobstore
+				logsDir            string
+			)
+
+			BeforeEach(func() {
+				var err error
+				logsDir, err = ioutil.TempDir("", "fetch-logs-test")
+				Expect(err).ToNot(HaveOccurred())
+
+				copier.FilteredCopyToTempTempDir = logsDir
+
+				streamingBlobstore = fakeagentblob.NewFakeStreamingBlobstore()
+				streamingBlobstore.CreateFromReaderBlobID = "my-streamed-blob-id"
+
+				fs := boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+				action = NewFetchLogs(compressor, copier, streamingBlobstore, dirProvider, fs)
+			})
+
+			AfterEach(func() {
+				_ = os.RemoveAll(logsDir)
+			})
+
+			writeLog := func(relPath string, content []byte) {
+				logPath := filepath.Join(logsDir, relPath)
+				Expect(os.MkdirAll(filepath.Dir(logPath), os.ModePerm)).To(Succeed())
+				Expect(ioutil.WriteFile(logPath, content, 0644)).To(Succeed())
+			}
+
+			readTarball := func(reader io.Reader) map[string][]byte {
+				gzipReader, err := gzip.NewReader(reader)
+				Expect(err).ToNot(HaveOccurred())
+
+				files := map[string][]byte{}
+				tarReader := tar.NewReader(gzipReader)
+				for {
+					header, err := tarReader.Next()
+					if err == io.EOF {
+						break
+					}
+					Expect(err).ToNot(HaveOccurred())
+
+					if header.Typeflag == tar.TypeReg {
+						content, err := ioutil.ReadAll(tarReader)
+						Expect(err).ToNot(HaveOccurred())
+						files[header.Name] = content
+					} else {
+						files[header.Name] = nil
+					}
+				}
+				return files
+			}
+
+			It("streams logs tarball to the blobstore without creating it on disk", func() {
+				writeLog("job/job.stdout.log", []byte("fake-stdout"))
+				writeLog("job/job.stderr.log", []byte("fake-stderr"))
+
+				streamed := &bytes.Buffer{}
+				streamingBlobstore.CreateFromReaderWriter = streamed
+
+				logs, err := action.Run("job", []string{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logs["blobstore_id"]).To(Equal("my-streamed-blob-id"))
+
+				Expect(compressor.CompressFilesInDirDir).To(Equal(""))
+				Expect(streamingBlobstore.CreateFileNames).To(BeEmpty())
+				Expect(copier.CleanUpTempDir).To(Equal(logsDir))
+
+				Expect(readTarball(streamed)).To(Equal(map[string][]byte{
+					"./job/":               nil,
+					"./job/job.stdout.log": []byte("fake-stdout"),
+					"./job/job.stderr.log": []byte("fake-stderr"),
+				}))
+			})
+
+			It("returns sha1 computed over the streamed tarball", func() {
+				writeLog("agent.log", []byte("fake-agent-log"))
+
+				streamed := &bytes.Buffer{}
+				streamingBlobstore.CreateFromReaderWriter = streamed
+
+				logs, err := action.Run("agent", []string{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(logs["sha1"]).To(Equal(fmt.Sprintf("%x", sha1.Sum(streamed.Bytes()))))
+				Expect(logs["sha1"]).To(Equal(streamingBlobstore.CreateFromReaderSHA1))
+			})
+
+			It("streams large logs", func() {
+				largeLog := bytes.Repeat([]byte("fake-log-line-0123456789\n"), 1024*1024)
+				writeLog("job-1/large.log", largeLog)
+				writeLog("job-2/large.log", largeLog)
+
+				tarballFile, err := ioutil.TempFile("", "fetch-logs-test-tarball")
+				Expect(err).ToNot(HaveOccurred())
+				defer os.Remove(tarballFile.Name())
+				defer tarballFile.Close()
+
+				streamingBlobstore.CreateFromReaderWriter = tarballFile
+
+				logs, err := action.Run("job", []string{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(logs["sha1"]).To(Equal(streamingBlobstore.CreateFromReaderSHA1))
+
+				_, err = tarballFile.Seek(0, 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				files := readTarball(tarballFile)
+				Expect(files["./job-1/large.log"]).To(HaveLen(len(largeLog)))
+				Expect(files["./job-2/large.log"]).To(Equal(largeLog))
+			})
+
+			It("returns error if blobstore fails to create blob", func() {
+				writeLog("agent.log", []byte("fake-agent-log"))
+				streamingBlobstore.CreateFromReaderErr = errors.New("fake-create-err")
+
+				_, err := action.Run("agent", []string{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Create file on blobstore"))
+				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+			})
+
+			It("returns error if logs tarball cannot be made", func() {
+				copier.FilteredCopyToTempTempDir = filepath.Join(logsDir, "non-existent")
+
+				_, err := action.Run("agent", []string{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("non-existent"))
+			})
+		})
 	})
 })
@@ -0,0 +1,102 @@
+package action
+
+import (
+	"errors"
+	"sort"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type GetPersistentDiskAction struct {
+	settingsService boshsettings.Service
+	platform        boshplatform.Platform
+	logger          boshlog.Logger
+}
+
+// PersistentDiskInfo is empty when no persistent disk is mounted
+type PersistentDiskInfo struct {
+	CID        string `json:"cid,omitempty"`
+	DevicePath string `json:"device_path,omitempty"`
+	MountPoint string `json:"mount_point,omitempty"`
+}
+
+func NewGetPersistentDisk(
+	settingsService boshsettings.Service,
+	platform boshplatform.Platform,
+	logger boshlog.Logger,
+) (action GetPersistentDiskAction) {
+	action.settingsService = settingsService
+	action.platform = platform
+	action.logger = logger
+	return
+}
+
+func (a GetPersistentDiskAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a GetPersistentDiskAction) IsPersistent() bool {
+	return false
+}
+
+func (a GetPersistentDiskAction) Run() (PersistentDiskInfo, error) {
+	settings := a.settingsService.GetSettings()
+
+	diskIDs := []string{}
+	for diskID := range settings.Disks.Persistent {
+		diskIDs = append(diskIDs, diskID)
+	}
+
+	// First mounted disk by id is returned so that result does not vary between calls
+	sort.Strings(diskIDs)
+
+	for _, diskID := range diskIDs {
+		if err := settings.ValidatePersistentDisk(diskID); err != nil {
+			a.logger.Warn("get-persistent-disk-action", "Skipping malformed persistent disk: %s", err.Error())
+			continue
+		}
+
+		diskSettings, _ := settings.PersistentDiskSettings(diskID)
+
+		isMounted, err := a.platform.IsPersistentDiskMounted(diskSettings)
+		if err != nil {
+			return PersistentDiskInfo{}, bosherr.WrapErrorf(err, "Checking whether device %+v is mounted", diskSettings)
+		}
+
+		if !isMounted {
+			a.logger.Debug("get-persistent-disk-action", "Volume '%s' not mounted", diskID)
+			continue
+		}
+
+		mountPoint := a.platform.GetPersistentDiskMountPoint(diskID)
+
+		// Mount table reflects actual partition rather than configured device
+		devicePath, isMountPoint, err := a.platform.IsMountPoint(mountPoint)
+		if err != nil {
+			return PersistentDiskInfo{}, bosherr.WrapErrorf(err, "Checking whether %s is a mount point", mountPoint)
+		}
+
+		if !isMountPoint {
+			devicePath = diskSettings.Path
+		}
+
+		return PersistentDiskInfo{
+			CID:        diskID,
+			DevicePath: devicePath,
+			MountPoint: mountPoint,
+		}, nil
+	}
+
+	return PersistentDiskInfo{}, nil
+}
+
+func (a GetPersistentDiskAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a GetPersistentDiskAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,132 @@
+package action_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("GetPersistentDisk", func() {
+		var (
+			settingsService *fakesettings.FakeSettingsService
+			platform        *fakeplatform.FakePlatform
+			action          GetPersistentDiskAction
+		)
+
+		BeforeEach(func() {
+			settingsService = &fakesettings.FakeSettingsService{}
+			platform = fakeplatform.NewFakePlatform()
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			action = NewGetPersistentDisk(settingsService, platform, logger)
+
+			settingsService.Settings.Disks = boshsettings.Disks{
+				Persistent: map[string]interface{}{
+					"fake-disk-cid": "/dev/sdb",
+				},
+			}
+		})
+
+		It("is synchronous", func() {
+			Expect(action.IsAsynchronous()).To(BeFalse())
+		})
+
+		It("is not persistent", func() {
+			Expect(action.IsPersistent()).To(BeFalse())
+		})
+
+		Context("when persistent disk is mounted", func() {
+			BeforeEach(func() {
+				platform.MountedDevicePaths = []string{"/dev/sdb"}
+			})
+
+			It("returns the disk cid with the device path from the mount table", func() {
+				platform.IsMountPointResult = true
+				platform.IsMountPointPartitionPath = "/dev/sdb1"
+
+				info, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info).To(Equal(PersistentDiskInfo{
+					CID:        "fake-disk-cid",
+					DevicePath: "/dev/sdb1",
+					MountPoint: "/var/vcap/store",
+				}))
+				Expect(platform.IsMountPointPath).To(Equal("/var/vcap/store"))
+			})
+
+			It("falls back to the configured device path when store is not a mount point", func() {
+				platform.IsMountPointResult = false
+
+				info, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.DevicePath).To(Equal("/dev/sdb"))
+			})
+
+			It("returns an error when checking the mount table fails", func() {
+				platform.IsMountPointErr = errors.New("fake-mount-point-err")
+
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-mount-point-err"))
+			})
+		})
+
+		Context("when two persistent disks are mounted", func() {
+			BeforeEach(func() {
+				settingsService.Settings.Disks = boshsettings.Disks{
+					Persistent: map[string]interface{}{
+						"fake-disk-cid-2": "/dev/sdc",
+						"fake-disk-cid-1": "/dev/sdb",
+					},
+				}
+				platform.MountedDevicePaths = []string{"/dev/sdb", "/dev/sdc"}
+				platform.PersistentDiskMountPoints = map[string]string{
+					"fake-disk-cid-1": "/var/vcap/stores/fake-disk-cid-1",
+					"fake-disk-cid-2": "/var/vcap/stores/fake-disk-cid-2",
+				}
+			})
+
+			It("always returns the disk with the lowest cid", func() {
+				for i := 0; i < 20; i++ {
+					info, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(info).To(Equal(PersistentDiskInfo{
+						CID:        "fake-disk-cid-1",
+						DevicePath: "/dev/sdb",
+						MountPoint: "/var/vcap/stores/fake-disk-cid-1",
+					}))
+				}
+			})
+		})
+
+		Context("when persistent disk is not mounted", func() {
+			It("returns an empty result", func() {
+				info, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info).To(Equal(PersistentDiskInfo{}))
+
+				infoJSON, err := json.Marshal(info)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(infoJSON)).To(Equal("{}"))
+			})
+		})
+
+		Context("when there are no persistent disks", func() {
+			It("returns an empty result", func() {
+				settingsService.Settings.Disks = boshsettings.Disks{}
+
+				info, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info).To(Equal(PersistentDiskInfo{}))
+			})
+		})
+	})
+}
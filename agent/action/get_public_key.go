@@ -0,0 +1,92 @@
+package action
+
+import (
+	"errors"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Only lines with one of these key types are returned
+var publicKeyTypePrefixes = []string{"ssh-", "ecdsa-sha2-", "sk-"}
+
+type GetPublicKeyAction struct {
+	fs          boshsys.FileSystem
+	dirProvider boshdirs.Provider
+}
+
+// NewGetPublicKey lets operators verify which key was installed
+// for vcap user during bootstrap
+func NewGetPublicKey(fs boshsys.FileSystem, dirProvider boshdirs.Provider) GetPublicKeyAction {
+	return GetPublicKeyAction{fs: fs, dirProvider: dirProvider}
+}
+
+type GetPublicKeyResponse struct {
+	PublicKeys []string `json:"public_keys"`
+}
+
+func (a GetPublicKeyAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a GetPublicKeyAction) IsPersistent() bool {
+	return false
+}
+
+func (a GetPublicKeyAction) Run() (GetPublicKeyResponse, error) {
+	response := GetPublicKeyResponse{PublicKeys: []string{}}
+
+	homeDir, err := a.fs.HomeDir(boshsettings.VCAPUsername)
+	if err != nil {
+		return response, bosherr.WrapError(err, "Finding home dir for user")
+	}
+
+	authKeysPath := a.dirProvider.AuthorizedKeysPath(boshsettings.VCAPUsername, homeDir)
+	if !a.fs.FileExists(authKeysPath) {
+		return response, nil
+	}
+
+	contents, err := a.fs.ReadFileString(authKeysPath)
+	if err != nil {
+		return response, bosherr.WrapError(err, "Reading authorized_keys file")
+	}
+
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if isPublicKeyLine(line) {
+			response.PublicKeys = append(response.PublicKeys, line)
+		}
+	}
+
+	return response, nil
+}
+
+func (a GetPublicKeyAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a GetPublicKeyAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+// isPublicKeyLine skips comments and anything else such as
+// private key material accidentally written to authorized_keys
+func isPublicKeyLine(line string) bool {
+	if line == "" || strings.HasPrefix(line, "#") || strings.Contains(line, "PRIVATE KEY") {
+		return false
+	}
+
+	// Key type may be preceded by options, e.g. from="10.0.0.1" ssh-rsa ...
+	for _, field := range strings.Fields(line) {
+		for _, prefix := range publicKeyTypePrefixes {
+			if strings.HasPrefix(field, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
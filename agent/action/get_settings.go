@@ -0,0 +1,144 @@
+package action
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+const redactedValue = "REDACTED"
+
+// Option keys containing any of these are considered secrets
+var secretOptionKeyParts = []string{"password", "secret", "key", "token", "credential"}
+
+type GetSettingsAction struct {
+	settingsService boshsettings.Service
+}
+
+func NewGetSettings(settingsService boshsettings.Service) GetSettingsAction {
+	return GetSettingsAction{
+		settingsService: settingsService,
+	}
+}
+
+func (a GetSettingsAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a GetSettingsAction) IsPersistent() bool {
+	return false
+}
+
+func (a GetSettingsAction) Run() (boshsettings.Settings, error) {
+	settings := a.settingsService.GetSettings()
+
+	settings.Mbus = redactURL(settings.Mbus)
+	settings.Blobstore.Options = redactOptions(settings.Blobstore.Options)
+
+	if settings.Env.Bosh.Password != "" {
+		settings.Env.Bosh.Password = redactedValue
+	}
+
+	if settings.Env.Bosh.DNSUpdate.Key != "" {
+		settings.Env.Bosh.DNSUpdate.Key = redactedValue
+	}
+
+	if settings.Env.Bosh.Mbus.Cert.PrivateKey != "" {
+		settings.Env.Bosh.Mbus.Cert.PrivateKey = redactedValue
+	}
+
+	if settings.Env.PersistentDiskPassphrase != "" {
+		settings.Env.PersistentDiskPassphrase = redactedValue
+	}
+
+	settings.Env.Custom = redactCustomEnv(settings.Env.Custom)
+
+	return settings, nil
+}
+
+func (a GetSettingsAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a GetSettingsAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedValue
+	}
+
+	if parsedURL.User != nil {
+		if _, hasPassword := parsedURL.User.Password(); hasPassword {
+			parsedURL.User = url.UserPassword(parsedURL.User.Username(), redactedValue)
+		}
+	}
+
+	return parsedURL.String()
+}
+
+// redactCustomEnv keeps only variable names since any value,
+// e.g. API token passed to job scripts, may be a credential
+func redactCustomEnv(env boshsettings.CustomEnv) boshsettings.CustomEnv {
+	if env == nil {
+		return nil
+	}
+
+	redacted := boshsettings.CustomEnv{}
+	for name := range env {
+		redacted[name] = redactedValue
+	}
+
+	return redacted
+}
+
+// redactOptions returns a copy so that loaded settings are left untouched
+func redactOptions(options map[string]interface{}) map[string]interface{} {
+	if options == nil {
+		return nil
+	}
+
+	redacted := map[string]interface{}{}
+	for key, value := range options {
+		if isSecretOptionKey(key) {
+			redacted[key] = redactedValue
+		} else {
+			redacted[key] = redactOptionValue(value)
+		}
+	}
+
+	return redacted
+}
+
+func redactOptionValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return redactOptions(typedValue)
+	case []interface{}:
+		redacted := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			redacted[i] = redactOptionValue(item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+func isSecretOptionKey(key string) bool {
+	lowerKey := strings.ToLower(key)
+	for _, part := range secretOptionKeyParts {
+		if strings.Contains(lowerKey, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,17 @@ package action
 
 import (
 	"errors"
+	"time"
+
+	"github.com/pivotal-golang/clock"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshversion "github.com/cloudfoundry/bosh-agent/version"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
@@ -17,6 +22,9 @@ type GetStateAction struct {
 	jobSupervisor   boshjobsuper.JobSupervisor
 	vitalsService   boshvitals.Service
 	ntpService      boshntp.Service
+	platform        boshplatform.Platform
+	timeService     clock.Clock
+	bootstrapTime   time.Time
 }
 
 func NewGetState(
@@ -25,12 +33,18 @@ func NewGetState(
 	jobSupervisor boshjobsuper.JobSupervisor,
 	vitalsService boshvitals.Service,
 	ntpService boshntp.Service,
+	platform boshplatform.Platform,
+	timeService clock.Clock,
+	bootstrapTime time.Time,
 ) (action GetStateAction) {
 	action.settingsService = settingsService
 	action.specService = specService
 	action.jobSupervisor = jobSupervisor
 	action.vitalsService = vitalsService
 	action.ntpService = ntpService
+	action.platform = platform
+	action.timeService = timeService
+	action.bootstrapTime = bootstrapTime
 	return
 }
 
@@ -50,10 +64,22 @@ type GetStateV1ApplySpec struct {
 	JobState     string                 `json:"job_state"`
 	Vitals       *boshvitals.Vitals     `json:"vitals,omitempty"`
 	Processes    []boshjobsuper.Process `json:"processes,omitempty"`
-	VM           boshsettings.VM        `json:"vm"`
+	VM           VMState                `json:"vm"`
 	Ntp          boshntp.Info           `json:"ntp"`
 }
 
+// VMState reports VM settings along with details about the running agent
+type VMState struct {
+	boshsettings.VM
+
+	AgentVersion  string `json:"agent_version"`
+	BootstrapTime string `json:"bootstrap_time"`
+	Uptime        int64  `json:"uptime"` // in seconds
+
+	// Only reported in full format since it requires reading route table
+	DefaultGatewayInterface string `json:"default_gateway_interface,omitempty"`
+}
+
 func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 	spec, err := a.specService.Get()
 	if err != nil {
@@ -78,6 +104,16 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 
 	settings := a.settingsService.GetSettings()
 
+	vmState := a.vmState(settings.VM)
+
+	if vitalsReference != nil {
+		// Interface is informational so failing to find default route does not fail get_state
+		defaultGatewayInterface, err := a.platform.GetDefaultGatewayInterface()
+		if err == nil {
+			vmState.DefaultGatewayInterface = defaultGatewayInterface
+		}
+	}
+
 	value := GetStateV1ApplySpec{
 		spec,
 		settings.AgentID,
@@ -85,7 +121,7 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 		a.jobSupervisor.Status(),
 		vitalsReference,
 		processes,
-		settings.VM,
+		vmState,
 		a.ntpService.GetInfo(),
 	}
 
@@ -102,6 +138,15 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 	return value, nil
 }
 
+func (a GetStateAction) vmState(vm boshsettings.VM) VMState {
+	return VMState{
+		VM:            vm,
+		AgentVersion:  boshversion.Version,
+		BootstrapTime: a.bootstrapTime.UTC().Format(time.RFC3339),
+		Uptime:        int64(a.timeService.Since(a.bootstrapTime) / time.Second),
+	}
+}
+
 func (a GetStateAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
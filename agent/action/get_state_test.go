@@ -2,15 +2,18 @@ package action_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
 	fakentp "github.com/cloudfoundry/bosh-agent/platform/ntp/fakes"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
@@ -26,6 +29,9 @@ var _ = Describe("GetState", func() {
 		specService     *fakeas.FakeV1Service
 		jobSupervisor   *fakejobsuper.FakeJobSupervisor
 		vitalsService   *fakevitals.FakeService
+		platform        *fakeplatform.FakePlatform
+		timeService     *fakeaction.FakeClock
+		bootstrapTime   time.Time
 		action          GetStateAction
 	)
 
@@ -40,7 +46,11 @@ var _ = Describe("GetState", func() {
 				Timestamp: "12 Oct 17:37:58",
 			},
 		}
-		action = NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService)
+		platform = fakeplatform.NewFakePlatform()
+		timeService = &fakeaction.FakeClock{}
+		timeService.SinceReturns(90 * time.Minute)
+		bootstrapTime = time.Date(2016, time.January, 2, 3, 4, 5, 0, time.UTC)
+		action = NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService, platform, timeService, bootstrapTime)
 	})
 
 	It("get state should be synchronous", func() {
@@ -73,7 +83,12 @@ var _ = Describe("GetState", func() {
 						AgentID:      "my-agent-id",
 						JobState:     "running",
 						BoshProtocol: "1",
-						VM:           boshsettings.VM{Name: "vm-abc-def"},
+						VM: VMState{
+							VM:            boshsettings.VM{Name: "vm-abc-def"},
+							AgentVersion:  "dev",
+							BootstrapTime: "2016-01-02T03:04:05Z",
+							Uptime:        5400,
+						},
 						Ntp: boshntp.Info{
 							Offset:    "0.34958",
 							Timestamp: "12 Oct 17:37:58",
@@ -117,7 +132,6 @@ var _ = Describe("GetState", func() {
 					}
 
 					vitalsService.GetVitals = expectedVitals
-					expectedVM := map[string]interface{}{"name": "vm-abc-def"}
 
 					expectedProcesses := []boshjobsuper.Process{
 						boshjobsuper.Process{
@@ -138,7 +152,76 @@ var _ = Describe("GetState", func() {
 					boshassert.MatchesJSONString(GinkgoT(), state.Deployment, `"fake-deployment"`)
 					Expect(*state.Vitals).To(Equal(expectedVitals))
 					Expect(state.Processes).To(Equal(expectedProcesses))
-					boshassert.MatchesJSONMap(GinkgoT(), state.VM, expectedVM)
+					boshassert.MatchesJSONString(GinkgoT(), state.VM, `{"name":"vm-abc-def","agent_version":"dev","bootstrap_time":"2016-01-02T03:04:05Z","uptime":5400}`)
+				})
+
+				It("reports restarts of processes tracked by job supervisor", func() {
+					trackingJobSupervisor := boshjobsuper.NewRestartTrackingJobSupervisor(jobSupervisor)
+					ntpService := &fakentp.FakeService{}
+					action = NewGetState(settingsService, specService, trackingJobSupervisor, vitalsService, ntpService, platform, timeService, bootstrapTime)
+
+					jobSupervisor.ProcessesStatus = []boshjobsuper.Process{
+						{Name: "fake-process-name", State: "running", Uptime: boshjobsuper.UptimeVitals{Secs: 60}},
+					}
+					_, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					jobSupervisor.ProcessesStatus = []boshjobsuper.Process{
+						{Name: "fake-process-name", State: "running", Uptime: boshjobsuper.UptimeVitals{Secs: 2}},
+					}
+					state, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.Processes).To(Equal([]boshjobsuper.Process{
+						{Name: "fake-process-name", State: "running", Uptime: boshjobsuper.UptimeVitals{Secs: 2}, Restarts: 1},
+					}))
+					boshassert.MatchesJSONString(GinkgoT(), state.Processes, `[{"name":"fake-process-name","state":"running","uptime":{"secs":2},"mem":{"percent":0},"cpu":{"total":0},"restarts":1}]`)
+				})
+
+				It("reports empty vm name when settings do not specify one", func() {
+					state, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.VM.Name).To(Equal(""))
+					boshassert.MatchesJSONString(GinkgoT(), state.VM, `{"name":"","agent_version":"dev","bootstrap_time":"2016-01-02T03:04:05Z","uptime":5400}`)
+				})
+
+				It("includes default gateway interface in full format", func() {
+					settingsService.Settings.VM.Name = "vm-abc-def"
+					platform.GetDefaultGatewayInterfaceName = "eth1"
+
+					state, err := action.Run("full")
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.VM.DefaultGatewayInterface).To(Equal("eth1"))
+					boshassert.MatchesJSONString(GinkgoT(), state.VM, `{"name":"vm-abc-def","agent_version":"dev","bootstrap_time":"2016-01-02T03:04:05Z","uptime":5400,"default_gateway_interface":"eth1"}`)
+				})
+
+				It("omits default gateway interface when it cannot be found", func() {
+					platform.GetDefaultGatewayInterfaceName = "eth1"
+					platform.GetDefaultGatewayInterfaceErr = errors.New("fake-route-err")
+
+					state, err := action.Run("full")
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.VM.DefaultGatewayInterface).To(BeEmpty())
+				})
+
+				It("does not include default gateway interface unless full format is requested", func() {
+					platform.GetDefaultGatewayInterfaceName = "eth1"
+
+					state, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.VM.DefaultGatewayInterface).To(BeEmpty())
+				})
+
+				It("reports uptime relative to bootstrap time", func() {
+					_, err := action.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(timeService.SinceCallCount()).To(Equal(1))
+					Expect(timeService.SinceArgsForCall(0)).To(Equal(bootstrapTime))
 				})
 
 				Describe("non-populated field formatting", func() {
@@ -41,6 +41,11 @@ func (a ListDiskAction) Run() (interface{}, error) {
 	for diskID := range settings.Disks.Persistent {
 		var isMounted bool
 
+		if err := settings.ValidatePersistentDisk(diskID); err != nil {
+			a.logger.Warn("list-disk-action", "Skipping malformed persistent disk: %s", err.Error())
+			continue
+		}
+
 		diskSettings, _ := settings.PersistentDiskSettings(diskID)
 		isMounted, err := a.platform.IsPersistentDiskMounted(diskSettings)
 		if err != nil {
@@ -54,5 +54,20 @@ func init() {
 			Expect(values).To(ContainElement("volume-3"))
 			Expect(len(values)).To(Equal(2))
 		})
+
+		It("skips malformed disks", func() {
+			platform.MountedDevicePaths = []string{"", "/dev/sdb"}
+
+			settingsService.Settings.Disks = boshsettings.Disks{
+				Persistent: map[string]interface{}{
+					"volume-1": nil,
+					"volume-2": map[string]interface{}{"path": "/dev/sdb"},
+				},
+			}
+
+			value, err := action.Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]string{"volume-2"}))
+		})
 	})
 }
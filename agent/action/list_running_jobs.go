@@ -0,0 +1,54 @@
+package action
+
+import (
+	"errors"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type ListRunningJobsAction struct {
+	jobSupervisor boshjobsuper.JobSupervisor
+}
+
+// NewListRunningJobs lets operators inspect state of every
+// process supervised for jobs without going through get_state
+func NewListRunningJobs(jobSupervisor boshjobsuper.JobSupervisor) ListRunningJobsAction {
+	return ListRunningJobsAction{jobSupervisor: jobSupervisor}
+}
+
+type JobProcessState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+func (a ListRunningJobsAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a ListRunningJobsAction) IsPersistent() bool {
+	return false
+}
+
+func (a ListRunningJobsAction) Run() ([]JobProcessState, error) {
+	processStates := []JobProcessState{}
+
+	processes, err := a.jobSupervisor.Processes()
+	if err != nil {
+		return processStates, bosherr.WrapError(err, "Getting processes from job supervisor")
+	}
+
+	for _, process := range processes {
+		processStates = append(processStates, JobProcessState{Name: process.Name, State: process.State})
+	}
+
+	return processStates, nil
+}
+
+func (a ListRunningJobsAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a ListRunningJobsAction) Cancel() error {
+	return errors.New("not supported")
+}
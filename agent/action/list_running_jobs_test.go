@@ -0,0 +1,66 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+)
+
+func init() {
+	Describe("ListRunningJobs", func() {
+		var (
+			jobSupervisor *fakejobsuper.FakeJobSupervisor
+			action        ListRunningJobsAction
+		)
+
+		BeforeEach(func() {
+			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+			action = NewListRunningJobs(jobSupervisor)
+		})
+
+		It("is synchronous", func() {
+			Expect(action.IsAsynchronous()).To(BeFalse())
+		})
+
+		It("is not persistent", func() {
+			Expect(action.IsPersistent()).To(BeFalse())
+		})
+
+		Describe("Run", func() {
+			It("returns names and states of running and stopped processes", func() {
+				jobSupervisor.ProcessesStatus = []boshjobsuper.Process{
+					{Name: "fake-process-1", State: "running", Uptime: boshjobsuper.UptimeVitals{Secs: 10}},
+					{Name: "fake-process-2", State: "stopped"},
+					{Name: "fake-process-3", State: "failing"},
+				}
+
+				processStates, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processStates).To(Equal([]JobProcessState{
+					{Name: "fake-process-1", State: "running"},
+					{Name: "fake-process-2", State: "stopped"},
+					{Name: "fake-process-3", State: "failing"},
+				}))
+			})
+
+			It("returns empty list when there are no processes", func() {
+				processStates, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(processStates).To(Equal([]JobProcessState{}))
+			})
+
+			It("returns error when job supervisor fails to get processes", func() {
+				jobSupervisor.ProcessesError = errors.New("fake-processes-error")
+
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-processes-error"))
+			})
+		})
+	})
+}
@@ -0,0 +1,161 @@
+package action
+
+import (
+	"errors"
+	"sort"
+
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const listenOnlyLogTag = "listenOnly"
+
+type listenOnlyFactory struct {
+	factory Factory
+	logger  boshlog.Logger
+}
+
+// simulatedResults are returned by actions that directors run
+// on every deploy besides apply, e.g. drain returns no wait time
+var simulatedResults = map[string]interface{}{
+	"prepare": "prepared",
+	"drain":   0,
+	"stop":    "stopped",
+	"start":   "started",
+}
+
+// NewListenOnlyFactory is used for disaster-recovery drills: actions run
+// during deploy are only simulated and other mutating actions are refused;
+// unlike AllowedActions directors can still go through their usual deploy flow.
+func NewListenOnlyFactory(factory Factory, logger boshlog.Logger) Factory {
+	return listenOnlyFactory{factory: factory, logger: logger}
+}
+
+func (f listenOnlyFactory) Create(method string) (Action, error) {
+	action, err := f.factory.Create(method)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsReadOnly(method) {
+		return action, nil
+	}
+
+	if method == "apply" {
+		return NewSimulatedApply(f.logger), nil
+	}
+
+	if result, found := simulatedResults[method]; found {
+		return NewSimulatedAction(method, result, action.IsAsynchronous(), f.logger), nil
+	}
+
+	return NewListenOnlyRefusal(method, f.logger), nil
+}
+
+type SimulatedApplyAction struct {
+	logger boshlog.Logger
+}
+
+func NewSimulatedApply(logger boshlog.Logger) SimulatedApplyAction {
+	return SimulatedApplyAction{logger: logger}
+}
+
+func (a SimulatedApplyAction) IsAsynchronous() bool {
+	return true
+}
+
+func (a SimulatedApplyAction) IsPersistent() bool {
+	return false
+}
+
+// Run responds like apply so that director continues deploying
+func (a SimulatedApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
+	packageNames := []string{}
+	for name := range desiredSpec.PackageSpecs {
+		packageNames = append(packageNames, name)
+	}
+
+	sort.Strings(packageNames)
+
+	a.logger.Info(
+		listenOnlyLogTag,
+		"Would apply spec for deployment '%s' with jobs %v and packages %v",
+		desiredSpec.Deployment, desiredSpec.JobSpec.JobTemplateNames(), packageNames,
+	)
+
+	return "applied", nil
+}
+
+func (a SimulatedApplyAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a SimulatedApplyAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+type SimulatedAction struct {
+	method       string
+	result       interface{}
+	asynchronous bool
+	logger       boshlog.Logger
+}
+
+func NewSimulatedAction(method string, result interface{}, asynchronous bool, logger boshlog.Logger) SimulatedAction {
+	return SimulatedAction{method: method, result: result, asynchronous: asynchronous, logger: logger}
+}
+
+// IsAsynchronous matches simulated action so that director waits for it as usual
+func (a SimulatedAction) IsAsynchronous() bool {
+	return a.asynchronous
+}
+
+func (a SimulatedAction) IsPersistent() bool {
+	return false
+}
+
+// Run accepts any arguments since they are not used
+func (a SimulatedAction) Run() (interface{}, error) {
+	a.logger.Info(listenOnlyLogTag, "Would run action %s", a.method)
+	return a.result, nil
+}
+
+func (a SimulatedAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a SimulatedAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+type ListenOnlyRefusalAction struct {
+	method string
+	logger boshlog.Logger
+}
+
+func NewListenOnlyRefusal(method string, logger boshlog.Logger) ListenOnlyRefusalAction {
+	return ListenOnlyRefusalAction{method: method, logger: logger}
+}
+
+func (a ListenOnlyRefusalAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a ListenOnlyRefusalAction) IsPersistent() bool {
+	return false
+}
+
+// Run accepts any arguments since they are not used
+func (a ListenOnlyRefusalAction) Run() (string, error) {
+	a.logger.Info(listenOnlyLogTag, "Would run action %s", a.method)
+	return "", bosherr.Errorf("Action %s is not run in listen-only mode", a.method)
+}
+
+func (a ListenOnlyRefusalAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a ListenOnlyRefusalAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,175 @@
+package action_test
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
+	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("listenOnlyFactory", func() {
+	var (
+		innerFactory *fakeaction.FakeFactory
+		logBuffer    *bytes.Buffer
+		factory      Factory
+	)
+
+	BeforeEach(func() {
+		innerFactory = fakeaction.NewFakeFactory()
+		logBuffer = bytes.NewBuffer([]byte{})
+		logger := boshlog.NewWriterLogger(boshlog.LevelDebug, logBuffer, logBuffer)
+		factory = NewListenOnlyFactory(innerFactory, logger)
+	})
+
+	It("returns read-only actions as is", func() {
+		getStateAction := &fakeaction.TestAction{}
+		innerFactory.RegisterAction("get_state", getStateAction)
+
+		action, err := factory.Create("get_state")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(getStateAction))
+	})
+
+	It("returns error for unknown actions", func() {
+		innerFactory.RegisterActionErr("fake-unknown-action", errors.New("fake-create-err"))
+
+		_, err := factory.Create("fake-unknown-action")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-create-err"))
+	})
+
+	Describe("apply", func() {
+		var (
+			applier         *fakeappl.FakeApplier
+			specService     *fakeas.FakeV1Service
+			platform        *fakeplatform.FakePlatform
+			currentSpec     boshas.V1ApplySpec
+			desiredSpecJSON []byte
+		)
+
+		BeforeEach(func() {
+			applier = fakeappl.NewFakeApplier()
+			specService = fakeas.NewFakeV1Service()
+			platform = fakeplatform.NewFakePlatform()
+
+			currentSpec = boshas.V1ApplySpec{Deployment: "fake-current-deployment"}
+			specService.Spec = currentSpec
+
+			logger := boshlog.NewWriterLogger(boshlog.LevelDebug, logBuffer, logBuffer)
+
+			// Wraps real factory so that real apply would have side effects on fakes
+			factory = NewListenOnlyFactory(NewFactory(
+				&fakesettings.FakeSettingsService{},
+				platform,
+				&fakeblobstore.FakeBlobstore{},
+				&faketask.FakeService{},
+				fakenotif.NewFakeNotifier(),
+				applier,
+				fakecomp.NewFakeCompiler(),
+				fakejobsuper.NewFakeJobSupervisor(),
+				specService,
+				&fakescript.FakeJobScriptProvider{},
+				boshsys.NewScriptCommandFactory("linux"),
+				&fakestats.FakeFreeSpaceChecker{},
+				&fakeaction.FakeClock{},
+				time.Now(),
+				0,
+				logger,
+			), logger)
+
+			desiredSpecJSON = []byte(`{"arguments":[{
+				"deployment": "fake-deployment",
+				"configuration_hash": "fake-configuration-hash",
+				"job": {"name": "fake-job", "templates": [{"name": "fake-template-1"}, {"name": "fake-template-2"}]},
+				"packages": {"fake-package-2": {"name": "fake-package-2"}, "fake-package-1": {"name": "fake-package-1"}}
+			}]}`)
+		})
+
+		It("simulates apply and logs what would be applied", func() {
+			action, err := factory.Create("apply")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action.IsAsynchronous()).To(BeTrue())
+
+			value, err := NewRunner().Run(action, desiredSpecJSON)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("applied"))
+
+			Expect(logBuffer.String()).To(ContainSubstring(
+				"Would apply spec for deployment 'fake-deployment' with jobs [fake-template-1 fake-template-2] and packages [fake-package-1 fake-package-2]",
+			))
+		})
+
+		It("does not change the system", func() {
+			action, err := factory.Create("apply")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = NewRunner().Run(action, desiredSpecJSON)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(applier.Applied).To(BeFalse())
+			Expect(specService.Spec).To(Equal(currentSpec))
+			Expect(platform.GetFs().FileExists("/var/vcap/instance/deployment")).To(BeFalse())
+			Expect(platform.GetRunner().(*fakesys.FakeCmdRunner).RunCommands).To(BeEmpty())
+		})
+	})
+
+	Describe("other actions run during deploy", func() {
+		It("logs and simulates them so that deploy continues", func() {
+			for method, expectedValue := range map[string]interface{}{
+				"prepare": "prepared",
+				"drain":   0,
+				"stop":    "stopped",
+				"start":   "started",
+			} {
+				innerAction := &fakeaction.TestAction{Asynchronous: method != "start"}
+				innerFactory.RegisterAction(method, innerAction)
+
+				action, err := factory.Create(method)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(action.IsAsynchronous()).To(Equal(innerAction.Asynchronous))
+
+				value, err := NewRunner().Run(action, []byte(`{"arguments":["fake-arg"]}`))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(expectedValue))
+
+				Expect(logBuffer.String()).To(ContainSubstring("Would run action " + method))
+			}
+		})
+	})
+
+	Describe("other mutating actions", func() {
+		It("logs and refuses to run them", func() {
+			innerFactory.RegisterAction("run_errand", &fakeaction.TestAction{Asynchronous: true})
+
+			action, err := factory.Create("run_errand")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action.IsAsynchronous()).To(BeFalse())
+
+			_, err = NewRunner().Run(action, []byte(`{"arguments":["fake-arg"]}`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Action run_errand is not run in listen-only mode"))
+
+			Expect(logBuffer.String()).To(ContainSubstring("Would run action run_errand"))
+		})
+	})
+})
@@ -5,7 +5,6 @@ import (
 
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
-	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -14,25 +13,23 @@ const logTag string = "MountDiskAction"
 
 type diskMounter interface {
 	MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) error
+	GetPersistentDiskMountPoint(diskID string) string
 }
 
 type MountDiskAction struct {
 	settingsService    boshsettings.Service
 	diskMounter        diskMounter
 	devicePathResolver boshdpresolv.DevicePathResolver
-	dirProvider        boshdirs.Provider
 	logger             boshlog.Logger
 }
 
 func NewMountDisk(
 	settingsService boshsettings.Service,
 	diskMounter diskMounter,
-	dirProvider boshdirs.Provider,
 	logger boshlog.Logger,
 ) (mountDisk MountDiskAction) {
 	mountDisk.settingsService = settingsService
 	mountDisk.diskMounter = diskMounter
-	mountDisk.dirProvider = dirProvider
 	mountDisk.logger = logger
 	return
 }
@@ -58,7 +55,13 @@ func (a MountDiskAction) Run(diskCid string) (interface{}, error) {
 		return nil, bosherr.Errorf("Persistent disk with volume id '%s' could not be found", diskCid)
 	}
 
-	mountPoint := a.dirProvider.StoreDir()
+	err = settings.ValidatePersistentDisk(diskCid)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating persistent disk settings")
+	}
+
+	// Store directory unless platform mounts each disk at its own path
+	mountPoint := a.diskMounter.GetPersistentDiskMountPoint(diskCid)
 
 	err = a.diskMounter.MountPersistentDisk(diskSettings, mountPoint)
 	if err != nil {
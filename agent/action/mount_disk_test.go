@@ -9,7 +9,6 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
-	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -25,9 +24,8 @@ var _ = Describe("MountDiskAction", func() {
 	BeforeEach(func() {
 		settingsService = &fakesettings.FakeSettingsService{}
 		platform = fakeplatform.NewFakePlatform()
-		dirProvider := boshdirs.NewProvider("/fake-base-dir")
 		logger = boshlog.NewLogger(boshlog.LevelNone)
-		action = NewMountDisk(settingsService, platform, dirProvider, logger)
+		action = NewMountDisk(settingsService, platform, logger)
 	})
 
 	It("is asynchronous", func() {
@@ -61,7 +59,39 @@ var _ = Describe("MountDiskAction", func() {
 							VolumeID: "fake-volume-id",
 							Path:     "fake-device-path",
 						}))
-						Expect(platform.MountPersistentDiskMountPoint).To(Equal("/fake-base-dir/store"))
+						Expect(platform.MountPersistentDiskMountPoint).To(Equal("/var/vcap/store"))
+					})
+				})
+
+				Context("when platform mounts each persistent disk at its own path", func() {
+					BeforeEach(func() {
+						settingsService.Settings.Disks.Persistent["fake-other-disk-cid"] = map[string]interface{}{
+							"path":      "fake-other-device-path",
+							"volume_id": "fake-other-volume-id",
+						}
+
+						platform.PersistentDiskMountPoints = map[string]string{
+							"fake-disk-cid":       "/var/vcap/stores/fake-disk-cid",
+							"fake-other-disk-cid": "/var/vcap/stores/fake-other-disk-cid",
+						}
+					})
+
+					It("mounts each disk at the path derived from its id", func() {
+						_, err := action.Run("fake-disk-cid")
+						Expect(err).NotTo(HaveOccurred())
+
+						_, err = action.Run("fake-other-disk-cid")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(platform.MountPersistentDiskMountPoints).To(Equal(map[string]string{
+							"fake-disk-cid":       "/var/vcap/stores/fake-disk-cid",
+							"fake-other-disk-cid": "/var/vcap/stores/fake-other-disk-cid",
+						}))
+						Expect(platform.MountPersistentDiskSettings).To(Equal(boshsettings.DiskSettings{
+							ID:       "fake-other-disk-cid",
+							VolumeID: "fake-other-volume-id",
+							Path:     "fake-other-device-path",
+						}))
 					})
 				})
 
@@ -76,6 +106,21 @@ var _ = Describe("MountDiskAction", func() {
 				})
 			})
 
+			Context("when disk settings are missing device path", func() {
+				BeforeEach(func() {
+					settingsService.Settings.Disks.Persistent = map[string]interface{}{
+						"fake-disk-cid": map[string]interface{}{},
+					}
+				})
+
+				It("returns error identifying the disk without mounting", func() {
+					_, err := action.Run("fake-disk-cid")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Validating persistent disk settings: Persistent disk 'fake-disk-cid' is missing device path"))
+					Expect(platform.MountPersistentDiskMountPoint).To(BeEmpty())
+				})
+			})
+
 			Context("when disk cid cannot be resolved to a device path from infrastructure settings", func() {
 				BeforeEach(func() {
 					settingsService.Settings.Disks.Persistent = map[string]interface{}{
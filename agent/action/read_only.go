@@ -0,0 +1,19 @@
+package action
+
+// Actions that do not change the system; they are run as is in listen-only mode
+// and are handled before other queued mbus messages
+var readOnlyActions = map[string]bool{
+	"ping":                true,
+	"get_task":            true,
+	"cancel_task":         true,
+	"get_state":           true,
+	"list_running_jobs":   true,
+	"get_settings":        true,
+	"get_public_key":      true,
+	"list_disk":           true,
+	"get_persistent_disk": true,
+}
+
+func IsReadOnly(method string) bool {
+	return readOnlyActions[method]
+}
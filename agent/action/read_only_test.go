@@ -0,0 +1,25 @@
+package action_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+)
+
+var _ = Describe("IsReadOnly", func() {
+	It("returns true for actions that do not change the system", func() {
+		for _, method := range []string{
+			"ping", "get_task", "cancel_task", "get_state", "list_running_jobs",
+			"get_settings", "get_public_key", "list_disk", "get_persistent_disk",
+		} {
+			Expect(IsReadOnly(method)).To(BeTrue(), method)
+		}
+	})
+
+	It("returns false for mutating and unknown actions", func() {
+		for _, method := range []string{"apply", "stop", "mount_disk", "run_errand", "sync_ntp", "fake-unknown"} {
+			Expect(IsReadOnly(method)).To(BeFalse(), method)
+		}
+	})
+})
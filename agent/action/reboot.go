@@ -0,0 +1,79 @@
+package action
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type RebootAction struct {
+	jobSupervisor        boshjobsuper.JobSupervisor
+	platform             boshplatform.Platform
+	timeService          clock.Clock
+	waitToRebootInterval time.Duration
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewReboot(
+	jobSupervisor boshjobsuper.JobSupervisor,
+	platform boshplatform.Platform,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) RebootAction {
+	return RebootAction{
+		jobSupervisor:        jobSupervisor,
+		platform:             platform,
+		timeService:          timeService,
+		waitToRebootInterval: 1 * time.Second,
+
+		logTag: "Reboot Action",
+		logger: logger,
+	}
+}
+
+func (a RebootAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a RebootAction) IsPersistent() bool {
+	return false
+}
+
+func (a RebootAction) Run() (string, error) {
+	// Stopping is a no-op when no jobs are running
+	err := a.jobSupervisor.Stop()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Stopping Monitored Services")
+	}
+
+	// Instead of waiting for some time, ideally this action would receive a signal
+	// that the response was sent to the API consumer.
+	go a.reboot()
+
+	return "rebooting", nil
+}
+
+func (a RebootAction) reboot() {
+	a.timeService.Sleep(a.waitToRebootInterval)
+
+	err := a.platform.Reboot()
+	if err != nil {
+		a.logger.Error(a.logTag, "Failed to reboot: %s", err.Error())
+	}
+}
+
+func (a RebootAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RebootAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,74 @@
+package action_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("Reboot", func() {
+		var (
+			jobSupervisor *fakejobsuper.FakeJobSupervisor
+			platform      *fakeplatform.FakePlatform
+			timeService   *fakeaction.FakeClock
+			action        RebootAction
+		)
+
+		BeforeEach(func() {
+			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+			platform = fakeplatform.NewFakePlatform()
+			timeService = &fakeaction.FakeClock{}
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			action = NewReboot(jobSupervisor, platform, timeService, logger)
+		})
+
+		It("is synchronous so that the director is acknowledged before the reboot", func() {
+			Expect(action.IsAsynchronous()).To(BeFalse())
+		})
+
+		It("is not persistent", func() {
+			Expect(action.IsPersistent()).To(BeFalse())
+		})
+
+		Describe("Run", func() {
+			It("returns rebooting", func() {
+				value, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal("rebooting"))
+			})
+
+			It("stops jobs and then reboots after a delay", func() {
+				stoppedBeforeReboot := make(chan bool, 1)
+				timeService.SleepStub = func(time.Duration) {
+					stoppedBeforeReboot <- jobSupervisor.Stopped
+				}
+
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(stoppedBeforeReboot).Should(Receive(BeTrue()))
+				Eventually(platform.RebootCalled).Should(BeTrue())
+				Expect(timeService.SleepArgsForCall(0)).To(Equal(1 * time.Second))
+			})
+
+			It("does not reboot if stopping jobs fails", func() {
+				jobSupervisor.StopErr = errors.New("fake-stop-err")
+
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-stop-err"))
+
+				Consistently(platform.RebootCalled, 100*time.Millisecond).Should(BeFalse())
+			})
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package action
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// RestartAction stops and starts jobs waiting for grace period in between
+// so that stopped services have time to release resources such as ports
+type RestartAction struct {
+	stopAction  StopAction
+	startAction StartAction
+	gracePeriod time.Duration
+	timeService clock.Clock
+}
+
+func NewRestart(
+	stopAction StopAction,
+	startAction StartAction,
+	gracePeriod time.Duration,
+	timeService clock.Clock,
+) RestartAction {
+	return RestartAction{
+		stopAction:  stopAction,
+		startAction: startAction,
+		gracePeriod: gracePeriod,
+		timeService: timeService,
+	}
+}
+
+func (a RestartAction) IsAsynchronous() bool {
+	return true
+}
+
+func (a RestartAction) IsPersistent() bool {
+	return false
+}
+
+func (a RestartAction) Run() (string, error) {
+	// Jobs are started again right away so they are not drained for shutdown
+	_, err := a.stopAction.Run(StopOptions{SkipDrain: true})
+	if err != nil {
+		return "", bosherr.WrapError(err, "Stopping jobs")
+	}
+
+	if a.gracePeriod > 0 {
+		a.timeService.Sleep(a.gracePeriod)
+	}
+
+	_, err = a.startAction.Run()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Starting jobs")
+	}
+
+	return "restarted", nil
+}
+
+func (a RestartAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RestartAction) Cancel() error {
+	return a.stopAction.Cancel()
+}
@@ -0,0 +1,114 @@
+package action_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("Restart", func() {
+		var (
+			jobSupervisor *fakejobsuper.FakeJobSupervisor
+			applier       *fakeappl.FakeApplier
+			fakeClock     *fakeclock.FakeClock
+			stopAction    StopAction
+			startAction   StartAction
+
+			notifier          *fakenotif.FakeNotifier
+			jobScriptProvider *fakescript.FakeJobScriptProvider
+		)
+
+		BeforeEach(func() {
+			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+			applier = fakeappl.NewFakeApplier()
+			specService := fakeas.NewFakeV1Service()
+			specService.Spec = boshas.V1ApplySpec{}
+			jobScriptProvider = &fakescript.FakeJobScriptProvider{}
+			jobScriptProvider.NewParallelScriptReturns(&fakescript.FakeCancellableScript{})
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+
+			notifier = fakenotif.NewFakeNotifier()
+			drainAction := NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger)
+			stopAction = NewStop(jobSupervisor, specService, drainAction)
+			startAction = NewStart(jobSupervisor, applier, specService, jobScriptProvider)
+		})
+
+		It("is asynchronous", func() {
+			action := NewRestart(stopAction, startAction, 0, fakeClock)
+			Expect(action.IsAsynchronous()).To(BeTrue())
+		})
+
+		It("is not persistent", func() {
+			action := NewRestart(stopAction, startAction, 0, fakeClock)
+			Expect(action.IsPersistent()).To(BeFalse())
+		})
+
+		It("stops and starts jobs without waiting when grace period is not set", func() {
+			action := NewRestart(stopAction, startAction, 0, fakeClock)
+
+			value, err := action.Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("restarted"))
+			Expect(jobSupervisor.Stopped).To(BeTrue())
+			Expect(jobSupervisor.Started).To(BeTrue())
+		})
+
+		It("does not drain jobs for shutdown since they are started again", func() {
+			action := NewRestart(stopAction, startAction, 0, fakeClock)
+
+			_, err := action.Run()
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < jobScriptProvider.NewParallelScriptCallCount(); i++ {
+				scriptName, _ := jobScriptProvider.NewParallelScriptArgsForCall(i)
+				Expect(scriptName).ToNot(Equal("drain"))
+			}
+			Expect(notifier.NotifiedShutdown).To(BeFalse())
+			Expect(jobSupervisor.Stopped).To(BeTrue())
+		})
+
+		It("waits for grace period between stopping and starting jobs", func() {
+			action := NewRestart(stopAction, startAction, 10*time.Second, fakeClock)
+
+			errCh := make(chan error)
+			go func() {
+				_, err := action.Run()
+				errCh <- err
+			}()
+
+			Eventually(fakeClock.WatcherCount).Should(Equal(1))
+			Expect(jobSupervisor.Stopped).To(BeTrue())
+			Expect(applier.Configured).To(BeFalse())
+
+			fakeClock.Increment(9 * time.Second)
+			Consistently(errCh).ShouldNot(Receive())
+
+			fakeClock.Increment(1 * time.Second)
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(jobSupervisor.Started).To(BeTrue())
+		})
+
+		It("does not start jobs if stopping fails", func() {
+			jobSupervisor.StopErr = errors.New("fake-stop-err")
+			action := NewRestart(stopAction, startAction, 0, fakeClock)
+
+			_, err := action.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-stop-err"))
+			Expect(jobSupervisor.Started).To(BeFalse())
+		})
+	})
+}
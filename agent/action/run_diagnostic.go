@@ -0,0 +1,77 @@
+package action
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// diagnosticCommands are the only commands RunDiagnosticAction runs;
+// they are looked up by name so that no caller provided input reaches the shell
+var diagnosticCommands = map[string][]string{
+	"disk_usage":          {"df", "-h"},
+	"processes":           {"ps", "aux"},
+	"network_connections": {"netstat", "-tunap"},
+}
+
+type RunDiagnosticAction struct {
+	cmdRunner boshsys.CmdRunner
+}
+
+type RunDiagnosticResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitStatus int    `json:"exit_status"`
+}
+
+func NewRunDiagnostic(cmdRunner boshsys.CmdRunner) RunDiagnosticAction {
+	return RunDiagnosticAction{cmdRunner: cmdRunner}
+}
+
+func (a RunDiagnosticAction) IsAsynchronous() bool {
+	return true
+}
+
+func (a RunDiagnosticAction) IsPersistent() bool {
+	return false
+}
+
+func (a RunDiagnosticAction) Run(name string) (RunDiagnosticResult, error) {
+	command, found := diagnosticCommands[name]
+	if !found {
+		return RunDiagnosticResult{}, bosherr.Errorf("Unknown diagnostic '%s', expected one of: %s", name, strings.Join(diagnosticNames(), ", "))
+	}
+
+	stdout, stderr, exitStatus, err := a.cmdRunner.RunCommand(command[0], command[1:]...)
+	if err != nil {
+		return RunDiagnosticResult{}, bosherr.WrapErrorf(err, "Running diagnostic '%s'", name)
+	}
+
+	return RunDiagnosticResult{
+		Stdout:     stdout,
+		Stderr:     stderr,
+		ExitStatus: exitStatus,
+	}, nil
+}
+
+func (a RunDiagnosticAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RunDiagnosticAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+func diagnosticNames() []string {
+	names := []string{}
+	for name := range diagnosticCommands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,57 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+func init() {
+	Describe("RunDiagnostic", func() {
+		var (
+			cmdRunner *fakesys.FakeCmdRunner
+			action    RunDiagnosticAction
+		)
+
+		BeforeEach(func() {
+			cmdRunner = fakesys.NewFakeCmdRunner()
+			action = NewRunDiagnostic(cmdRunner)
+		})
+
+		It("is asynchronous", func() {
+			Expect(action.IsAsynchronous()).To(BeTrue())
+		})
+
+		It("is not persistent", func() {
+			Expect(action.IsPersistent()).To(BeFalse())
+		})
+
+		It("runs command for known diagnostic and returns its output", func() {
+			cmdRunner.AddCmdResult("df -h", fakesys.FakeCmdResult{Stdout: "fake-stdout", Stderr: "fake-stderr"})
+
+			result, err := action.Run("disk_usage")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(RunDiagnosticResult{Stdout: "fake-stdout", Stderr: "fake-stderr"}))
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{{"df", "-h"}}))
+		})
+
+		It("returns error if diagnostic command fails", func() {
+			cmdRunner.AddCmdResult("ps aux", fakesys.FakeCmdResult{Error: errors.New("fake-run-err")})
+
+			_, err := action.Run("processes")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Running diagnostic 'processes': fake-run-err"))
+		})
+
+		It("rejects unknown diagnostic without running anything", func() {
+			_, err := action.Run("rm -rf /")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unknown diagnostic 'rm -rf /', expected one of: disk_usage, network_connections, processes"))
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+	})
+}
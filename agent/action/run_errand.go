@@ -2,10 +2,12 @@ package action
 
 import (
 	"errors"
+	"fmt"
 	"path"
 	"time"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -15,6 +17,7 @@ const runErrandActionLogTag = "runErrandAction"
 
 type RunErrandAction struct {
 	specService          boshas.V1Service
+	settingsService      boshsettings.Service
 	jobsDir              string
 	scriptCommandFactory boshsys.ScriptCommandFactory
 	cmdRunner            boshsys.CmdRunner
@@ -25,6 +28,7 @@ type RunErrandAction struct {
 
 func NewRunErrand(
 	specService boshas.V1Service,
+	settingsService boshsettings.Service,
 	jobsDir string,
 	scriptCommandFactory boshsys.ScriptCommandFactory,
 	cmdRunner boshsys.CmdRunner,
@@ -32,6 +36,7 @@ func NewRunErrand(
 ) RunErrandAction {
 	return RunErrandAction{
 		specService:          specService,
+		settingsService:      settingsService,
 		jobsDir:              jobsDir,
 		scriptCommandFactory: scriptCommandFactory,
 		cmdRunner:            cmdRunner,
@@ -72,6 +77,15 @@ func (a RunErrandAction) Run() (ErrandResult, error) {
 		"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
 	}
 
+	settings := a.settingsService.GetSettings()
+
+	err = settings.Env.Custom.Apply(command.Env)
+	if err != nil {
+		return ErrandResult{}, bosherr.WrapError(err, "Setting custom environment")
+	}
+
+	command = restrictErrandCommand(command, settings.Env.Bosh.Errand)
+
 	process, err := a.cmdRunner.RunComplexCommandAsync(command)
 	if err != nil {
 		return ErrandResult{}, bosherr.WrapError(err, "Running errand script")
@@ -104,6 +118,39 @@ func (a RunErrandAction) Run() (ErrandResult, error) {
 	}, nil
 }
 
+// restrictErrandCommand wraps command so that it runs
+// inside of chroot and/or cgroup scope with given limits
+func restrictErrandCommand(command boshsys.Command, errandEnv boshsettings.ErrandEnv) boshsys.Command {
+	if !errandEnv.IsRestricted() {
+		return command
+	}
+
+	args := append([]string{command.Name}, command.Args...)
+
+	if errandEnv.Chroot != "" {
+		args = append([]string{"chroot", errandEnv.Chroot}, args...)
+	}
+
+	if errandEnv.HasLimits() {
+		scopeArgs := []string{"systemd-run", "--scope", "--quiet"}
+
+		if errandEnv.MemoryLimitMB > 0 {
+			scopeArgs = append(scopeArgs, "-p", fmt.Sprintf("MemoryMax=%dM", errandEnv.MemoryLimitMB))
+		}
+
+		if errandEnv.CPUQuotaPercent > 0 {
+			scopeArgs = append(scopeArgs, "-p", fmt.Sprintf("CPUQuota=%d%%", errandEnv.CPUQuotaPercent))
+		}
+
+		args = append(append(scopeArgs, "--"), args...)
+	}
+
+	command.Name = args[0]
+	command.Args = args[1:]
+
+	return command
+}
+
 func (a RunErrandAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
@@ -10,6 +10,8 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -18,6 +20,7 @@ import (
 var _ = Describe("RunErrand", func() {
 	var (
 		specService          *fakeas.FakeV1Service
+		settingsService      *fakesettings.FakeSettingsService
 		cmdRunner            *fakesys.FakeCmdRunner
 		action               RunErrandAction
 		scriptCommandFactory boshsys.ScriptCommandFactory
@@ -25,10 +28,11 @@ var _ = Describe("RunErrand", func() {
 
 	BeforeEach(func() {
 		specService = fakeas.NewFakeV1Service()
+		settingsService = &fakesettings.FakeSettingsService{}
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		scriptCommandFactory = boshsys.NewScriptCommandFactory("linux")
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		action = NewRunErrand(specService, "/fake-jobs-dir", scriptCommandFactory, cmdRunner, logger)
+		action = NewRunErrand(specService, settingsService, "/fake-jobs-dir", scriptCommandFactory, cmdRunner, logger)
 	})
 
 	It("is asynchronous", func() {
@@ -83,6 +87,67 @@ var _ = Describe("RunErrand", func() {
 							},
 						}))
 					})
+
+					It("adds custom environment from settings", func() {
+						settingsService.Settings.Env.Custom = boshsettings.CustomEnv{"HTTP_PROXY": "http://fake-proxy:3128"}
+
+						_, err := action.Run()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cmdRunner.RunComplexCommands[0].Env).To(Equal(map[string]string{
+							"PATH":       "/usr/sbin:/usr/bin:/sbin:/bin",
+							"HTTP_PROXY": "http://fake-proxy:3128",
+						}))
+					})
+
+					It("returns an error without running errand when custom environment uses reserved names", func() {
+						settingsService.Settings.Env.Custom = boshsettings.CustomEnv{"BOSH_JOB_NAME": "fake-job"}
+
+						_, err := action.Run()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Setting custom environment"))
+						Expect(cmdRunner.RunComplexCommands).To(BeEmpty())
+					})
+				})
+
+				Context("when errand environment is restricted in settings", func() {
+					runsCommand := func(fullCmd string) boshsys.Command {
+						cmdRunner.AddProcess(fullCmd, &fakesys.FakeProcess{
+							WaitResult: boshsys.Result{Stdout: "fake-stdout", ExitStatus: 0},
+						})
+
+						result, err := action.Run()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.Stdout).To(Equal("fake-stdout"))
+
+						Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+						return cmdRunner.RunComplexCommands[0]
+					}
+
+					It("runs errand script inside of chroot", func() {
+						settingsService.Settings.Env.Bosh.Errand = boshsettings.ErrandEnv{Chroot: "/fake-chroot"}
+
+						command := runsCommand("chroot /fake-chroot /fake-jobs-dir/fake-job-name/bin/run")
+						Expect(command.Env).To(Equal(map[string]string{"PATH": "/usr/sbin:/usr/bin:/sbin:/bin"}))
+					})
+
+					It("runs errand script in cgroup scope with memory and cpu limits", func() {
+						settingsService.Settings.Env.Bosh.Errand = boshsettings.ErrandEnv{
+							MemoryLimitMB:   512,
+							CPUQuotaPercent: 50,
+						}
+
+						runsCommand("systemd-run --scope --quiet -p MemoryMax=512M -p CPUQuota=50% -- /fake-jobs-dir/fake-job-name/bin/run")
+					})
+
+					It("runs errand script inside of chroot in cgroup scope with only configured limits", func() {
+						settingsService.Settings.Env.Bosh.Errand = boshsettings.ErrandEnv{
+							Chroot:        "/fake-chroot",
+							MemoryLimitMB: 256,
+						}
+
+						command := runsCommand("systemd-run --scope --quiet -p MemoryMax=256M -- chroot /fake-chroot /fake-jobs-dir/fake-job-name/bin/run")
+						Expect(command.Name).To(Equal("systemd-run"))
+					})
 				})
 
 				Context("when errand script fails with non-0 exit code (execution of script is ok)", func() {
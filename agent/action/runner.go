@@ -2,7 +2,9 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -10,9 +12,22 @@ import (
 
 type Runner interface {
 	Run(action Action, payload []byte) (value interface{}, err error)
+
+	// RunContext cancels action once ctx is done and returns TimeoutError
+	// after action returns so that it never keeps running in background
+	RunContext(ctx context.Context, action Action, payload []byte) (value interface{}, err error)
 	Resume(action Action, payload []byte) (value interface{}, err error)
 }
 
+// TimeoutError is returned by RunContext when action did not return in time
+type TimeoutError struct {
+	Err error
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("Action timed out: %s", e.Err.Error())
+}
+
 func NewRunner() Runner {
 	return concreteRunner{}
 }
@@ -49,6 +64,31 @@ func (r concreteRunner) Run(action Action, payloadBytes []byte) (value interface
 	return r.extractReturns(values)
 }
 
+func (r concreteRunner) RunContext(ctx context.Context, action Action, payloadBytes []byte) (interface{}, error) {
+	type runResult struct {
+		value interface{}
+		err   error
+	}
+
+	resultCh := make(chan runResult, 1)
+
+	go func() {
+		value, err := r.Run(action, payloadBytes)
+		resultCh <- runResult{value: value, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.value, result.err
+	case <-ctx.Done():
+		// Action that fails to cancel is still waited for;
+		// its result is discarded either way
+		_ = action.Cancel()
+		<-resultCh
+		return nil, TimeoutError{Err: ctx.Err()}
+	}
+}
+
 func (r concreteRunner) Resume(action Action, payloadBytes []byte) (value interface{}, err error) {
 	return action.Resume()
 }
@@ -1,7 +1,9 @@
 package action_test
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -66,6 +68,33 @@ func (a *actionWithTypes) Cancel() error {
 	return nil
 }
 
+// slowAction runs until it is canceled
+type slowAction struct {
+	finish chan struct{}
+}
+
+func (a *slowAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a *slowAction) IsPersistent() bool {
+	return false
+}
+
+func (a *slowAction) Run() (string, error) {
+	<-a.finish
+	return "fake-value", nil
+}
+
+func (a *slowAction) Resume() (interface{}, error) {
+	return nil, nil
+}
+
+func (a *slowAction) Cancel() error {
+	close(a.finish)
+	return nil
+}
+
 type actionWithGoodRunMethod struct {
 	Value valueType
 	Err   error
@@ -328,6 +357,35 @@ func init() {
 			Expect(err).To(HaveOccurred())
 		})
 
+		Describe("RunContext", func() {
+			It("returns action result when action finishes before ctx is done", func() {
+				runner := NewRunner()
+				expectedValue := valueType{ID: 13, Success: true}
+				testAction := &actionWithGoodRunMethod{Value: expectedValue}
+
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+
+				value, err := runner.RunContext(ctx, testAction, []byte(`{"arguments":["setup", 123, {"user":"rob","pwd":"rob123","id":12}, ["a","b"]]}`))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(expectedValue))
+			})
+
+			It("cancels slow action and returns timeout error once it stops", func() {
+				runner := NewRunner()
+				testAction := &slowAction{finish: make(chan struct{})}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+
+				value, err := runner.RunContext(ctx, testAction, []byte(`{"arguments":[]}`))
+				Expect(value).To(BeNil())
+				Expect(err).To(Equal(TimeoutError{Err: context.DeadlineExceeded}))
+				Expect(err.Error()).To(Equal("Action timed out: context deadline exceeded"))
+				Expect(testAction.finish).To(BeClosed())
+			})
+		})
+
 		Describe("Resume", func() {
 			It("calls Resume() on action", func() {
 				runner := NewRunner()
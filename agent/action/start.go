@@ -5,21 +5,39 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type StartAction struct {
-	jobSupervisor boshjobsuper.JobSupervisor
-	applier       boshappl.Applier
-	specService   boshas.V1Service
+	jobSupervisor     boshjobsuper.JobSupervisor
+	applier           boshappl.Applier
+	specService       boshas.V1Service
+	jobScriptProvider boshscript.JobScriptProvider
 }
 
-func NewStart(jobSupervisor boshjobsuper.JobSupervisor, applier boshappl.Applier, specService boshas.V1Service) (start StartAction) {
+// StartOptions are optionally given by the director;
+// PostDeploy is set when start is the final step of a deploy
+type StartOptions struct {
+	PostDeploy bool `json:"post_deploy"`
+
+	// Runs pre-start and post-start scripts around starting jobs;
+	// not set by default since director runs them through run_script
+	RunStartScripts bool `json:"run_start_scripts"`
+}
+
+func NewStart(
+	jobSupervisor boshjobsuper.JobSupervisor,
+	applier boshappl.Applier,
+	specService boshas.V1Service,
+	jobScriptProvider boshscript.JobScriptProvider,
+) (start StartAction) {
 	start = StartAction{
-		jobSupervisor: jobSupervisor,
-		specService:   specService,
-		applier:       applier,
+		jobSupervisor:     jobSupervisor,
+		specService:       specService,
+		applier:           applier,
+		jobScriptProvider: jobScriptProvider,
 	}
 	return
 }
@@ -32,7 +50,7 @@ func (a StartAction) IsPersistent() bool {
 	return false
 }
 
-func (a StartAction) Run() (value string, err error) {
+func (a StartAction) Run(options ...StartOptions) (value string, err error) {
 	desiredApplySpec, err := a.specService.Get()
 	if err != nil {
 		err = bosherr.WrapError(err, "Getting apply spec")
@@ -45,12 +63,42 @@ func (a StartAction) Run() (value string, err error) {
 		return
 	}
 
-	err = a.jobSupervisor.Start()
+	runStartScripts := len(options) > 0 && options[0].RunStartScripts
+
+	if runStartScripts {
+		err = runJobScripts(a.jobScriptProvider, desiredApplySpec, "pre-start")
+		if err != nil {
+			err = bosherr.WrapError(err, "Running pre-start scripts")
+			return
+		}
+	}
+
+	if desiredApplySpec.JobSpec.OrderedStart {
+		err = a.jobSupervisor.StartJobs(desiredApplySpec.JobSpec.JobTemplateNames())
+	} else {
+		err = a.jobSupervisor.Start()
+	}
 	if err != nil {
 		err = bosherr.WrapError(err, "Starting Monitored Services")
 		return
 	}
 
+	if runStartScripts {
+		err = runJobScripts(a.jobScriptProvider, desiredApplySpec, "post-start")
+		if err != nil {
+			err = bosherr.WrapError(err, "Running post-start scripts")
+			return
+		}
+	}
+
+	if len(options) > 0 && options[0].PostDeploy {
+		err = runJobScripts(a.jobScriptProvider, desiredApplySpec, "post-deploy")
+		if err != nil {
+			err = bosherr.WrapError(err, "Running post-deploy scripts")
+			return
+		}
+	}
+
 	value = "started"
 	return
 }
@@ -62,3 +110,15 @@ func (a StartAction) Resume() (interface{}, error) {
 func (a StartAction) Cancel() error {
 	return errors.New("not supported")
 }
+
+// runJobScripts runs named script of every job in parallel;
+// jobs that do not have such script are skipped
+func runJobScripts(jobScriptProvider boshscript.JobScriptProvider, spec boshas.V1ApplySpec, scriptName string) error {
+	var scripts []boshscript.Script
+
+	for _, job := range spec.Jobs() {
+		scripts = append(scripts, jobScriptProvider.NewScript(job.BundleName(), scriptName))
+	}
+
+	return jobScriptProvider.NewParallelScript(scriptName, scripts).Run()
+}
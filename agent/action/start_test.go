@@ -6,25 +6,47 @@ import (
 
 	"errors"
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	"github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 func init() {
 	Describe("Start", func() {
 		var (
-			jobSupervisor *fakejobsuper.FakeJobSupervisor
-			applier       *fakeappl.FakeApplier
-			specService   *fakeas.FakeV1Service
-			action        StartAction
+			jobSupervisor     *fakejobsuper.FakeJobSupervisor
+			applier           *fakeappl.FakeApplier
+			specService       *fakeas.FakeV1Service
+			jobScriptProvider *fakescript.FakeJobScriptProvider
+			preStartScript    *fakescript.FakeCancellableScript
+			postStartScript   *fakescript.FakeCancellableScript
+			action            StartAction
 		)
 
 		BeforeEach(func() {
 			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 			applier = fakeappl.NewFakeApplier()
 			specService = fakeas.NewFakeV1Service()
-			action = NewStart(jobSupervisor, applier, specService)
+			jobScriptProvider = &fakescript.FakeJobScriptProvider{}
+
+			preStartScript = &fakescript.FakeCancellableScript{}
+			postStartScript = &fakescript.FakeCancellableScript{}
+			jobScriptProvider.NewParallelScriptStub = func(scriptName string, scripts []boshscript.Script) boshscript.CancellableScript {
+				switch scriptName {
+				case "pre-start":
+					return preStartScript
+				case "post-start":
+					return postStartScript
+				default:
+					panic("Non-matching parallel script created")
+				}
+			}
+
+			action = NewStart(jobSupervisor, applier, specService, jobScriptProvider)
 		})
 
 		It("is synchronous", func() {
@@ -47,6 +69,33 @@ func init() {
 			Expect(jobSupervisor.Started).To(BeTrue())
 		})
 
+		Context("when spec declares ordered start", func() {
+			BeforeEach(func() {
+				specService.Spec.JobSpec.OrderedStart = true
+				specService.Spec.JobSpec.JobTemplateSpecs = []applyspec.JobTemplateSpec{
+					{Name: "fake-job-1"},
+					{Name: "fake-job-2"},
+					{Name: "fake-job-3"},
+				}
+				jobScriptProvider.NewScriptReturns(&fakescript.FakeScript{})
+			})
+
+			It("starts services of jobs in declared order", func() {
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(jobSupervisor.Started).To(BeFalse())
+				Expect(jobSupervisor.StartedJobNames).To(Equal([]string{"fake-job-1", "fake-job-2", "fake-job-3"}))
+			})
+
+			It("returns error if starting jobs fails", func() {
+				jobSupervisor.StartJobsErr = errors.New("fake-start-jobs-err")
+
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-start-jobs-err"))
+			})
+		})
+
 		It("configures jobs", func() {
 			_, err := action.Run()
 			Expect(err).ToNot(HaveOccurred())
@@ -60,5 +109,211 @@ func init() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Configuring jobs"))
 		})
+
+		Context("when jobs have pre-start and post-start scripts", func() {
+			var (
+				job1Script *fakescript.FakeScript
+				job2Script *fakescript.FakeScript
+			)
+
+			BeforeEach(func() {
+				specService.Spec.JobSpec.JobTemplateSpecs = []applyspec.JobTemplateSpec{
+					{Name: "fake-job-1"},
+					{Name: "fake-job-2"},
+				}
+
+				job1Script = &fakescript.FakeScript{}
+				job2Script = &fakescript.FakeScript{}
+				jobScriptProvider.NewScriptStub = func(jobName, scriptName string) boshscript.Script {
+					if jobName == "fake-job-1" {
+						return job1Script
+					}
+					return job2Script
+				}
+			})
+
+			It("does not run pre-start and post-start scripts by default since director runs them", func() {
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(jobScriptProvider.NewParallelScriptCallCount()).To(Equal(0))
+				Expect(preStartScript.RunCallCount()).To(Equal(0))
+				Expect(postStartScript.RunCallCount()).To(Equal(0))
+				Expect(jobSupervisor.Started).To(BeTrue())
+			})
+
+			It("runs pre-start scripts of all jobs before starting monitored services", func() {
+				preStartScript.RunStub = func() error {
+					Expect(applier.Configured).To(BeTrue())
+					Expect(jobSupervisor.Started).To(BeFalse())
+					return nil
+				}
+
+				_, err := action.Run(StartOptions{RunStartScripts: true})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(preStartScript.RunCallCount()).To(Equal(1))
+
+				scriptName, scripts := jobScriptProvider.NewParallelScriptArgsForCall(0)
+				Expect(scriptName).To(Equal("pre-start"))
+				Expect(scripts).To(Equal([]boshscript.Script{job1Script, job2Script}))
+
+				jobName, scriptName := jobScriptProvider.NewScriptArgsForCall(0)
+				Expect(jobName).To(Equal("fake-job-1"))
+				Expect(scriptName).To(Equal("pre-start"))
+			})
+
+			It("runs post-start scripts of all jobs after starting monitored services", func() {
+				postStartScript.RunStub = func() error {
+					Expect(jobSupervisor.Started).To(BeTrue())
+					return nil
+				}
+
+				_, err := action.Run(StartOptions{RunStartScripts: true})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(postStartScript.RunCallCount()).To(Equal(1))
+
+				scriptName, scripts := jobScriptProvider.NewParallelScriptArgsForCall(1)
+				Expect(scriptName).To(Equal("post-start"))
+				Expect(scripts).To(Equal([]boshscript.Script{job1Script, job2Script}))
+			})
+
+			It("does not start monitored services if pre-start scripts fail", func() {
+				preStartScript.RunReturns(errors.New("fake-pre-start-err"))
+
+				_, err := action.Run(StartOptions{RunStartScripts: true})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Running pre-start scripts"))
+				Expect(err.Error()).To(ContainSubstring("fake-pre-start-err"))
+
+				Expect(jobSupervisor.Started).To(BeFalse())
+				Expect(postStartScript.RunCallCount()).To(Equal(0))
+			})
+
+			It("does not run post-start scripts if starting monitored services fails", func() {
+				jobSupervisor.StartErr = errors.New("fake-start-err")
+
+				_, err := action.Run(StartOptions{RunStartScripts: true})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-start-err"))
+
+				Expect(postStartScript.RunCallCount()).To(Equal(0))
+			})
+
+			It("returns error if post-start scripts fail", func() {
+				postStartScript.RunReturns(errors.New("fake-post-start-err"))
+
+				_, err := action.Run(StartOptions{RunStartScripts: true})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Running post-start scripts"))
+				Expect(err.Error()).To(ContainSubstring("fake-post-start-err"))
+
+				Expect(jobSupervisor.Started).To(BeTrue())
+			})
+		})
+
+		Context("when jobs have post-deploy scripts", func() {
+			var (
+				job1Script *fakescript.FakeScript
+				job2Script *fakescript.FakeScript
+				job3Script *fakescript.FakeScript
+			)
+
+			newPostDeployScript := func(jobName string) *fakescript.FakeScript {
+				script := &fakescript.FakeScript{}
+				script.TagReturns(jobName)
+				script.PathReturns("/fake/" + jobName + "/bin/post-deploy")
+				script.ExistsReturns(true)
+				return script
+			}
+
+			BeforeEach(func() {
+				specService.Spec.JobSpec.JobTemplateSpecs = []applyspec.JobTemplateSpec{
+					{Name: "fake-job-1"},
+					{Name: "fake-job-2"},
+					{Name: "fake-job-3"},
+				}
+
+				job1Script = newPostDeployScript("fake-job-1")
+				job2Script = newPostDeployScript("fake-job-2")
+				job3Script = newPostDeployScript("fake-job-3")
+
+				jobScriptProvider.NewScriptStub = func(jobName, scriptName string) boshscript.Script {
+					if scriptName != "post-deploy" {
+						return &fakescript.FakeScript{}
+					}
+
+					switch jobName {
+					case "fake-job-1":
+						return job1Script
+					case "fake-job-2":
+						return job2Script
+					default:
+						return job3Script
+					}
+				}
+
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				jobScriptProvider.NewParallelScriptStub = func(scriptName string, scripts []boshscript.Script) boshscript.CancellableScript {
+					switch scriptName {
+					case "post-deploy":
+						return boshscript.NewParallelScript(scriptName, scripts, logger)
+					default:
+						return &fakescript.FakeCancellableScript{}
+					}
+				}
+			})
+
+			It("runs post-deploy scripts of all jobs when start is the final deploy step", func() {
+				job1Script.RunStub = func() error {
+					Expect(jobSupervisor.Started).To(BeTrue())
+					return nil
+				}
+
+				_, err := action.Run(StartOptions{PostDeploy: true})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(job1Script.RunCallCount()).To(Equal(1))
+				Expect(job2Script.RunCallCount()).To(Equal(1))
+				Expect(job3Script.RunCallCount()).To(Equal(1))
+			})
+
+			It("does not run post-deploy scripts when start is not the final deploy step", func() {
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = action.Run(StartOptions{PostDeploy: false})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(job1Script.RunCallCount()).To(Equal(0))
+				Expect(job2Script.RunCallCount()).To(Equal(0))
+				Expect(job3Script.RunCallCount()).To(Equal(0))
+			})
+
+			It("runs all post-deploy scripts even if some fail and returns aggregated error", func() {
+				job1Script.RunReturns(errors.New("fake-job-1-err"))
+				job3Script.RunReturns(errors.New("fake-job-3-err"))
+
+				_, err := action.Run(StartOptions{PostDeploy: true})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Running post-deploy scripts"))
+				Expect(err.Error()).To(ContainSubstring("2 of 3 post-deploy scripts failed"))
+				Expect(err.Error()).To(ContainSubstring("fake-job-1"))
+				Expect(err.Error()).To(ContainSubstring("fake-job-3"))
+				Expect(err.Error()).To(ContainSubstring("Successful Jobs: fake-job-2"))
+
+				Expect(job1Script.RunCallCount()).To(Equal(1))
+				Expect(job2Script.RunCallCount()).To(Equal(1))
+				Expect(job3Script.RunCallCount()).To(Equal(1))
+			})
+
+			It("does not run post-deploy scripts if starting monitored services fails", func() {
+				jobSupervisor.StartErr = errors.New("fake-start-err")
+
+				_, err := action.Run(StartOptions{PostDeploy: true})
+				Expect(err).To(HaveOccurred())
+
+				Expect(job1Script.RunCallCount()).To(Equal(0))
+			})
+		})
 	})
 }
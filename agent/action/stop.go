@@ -2,18 +2,38 @@ package action
 
 import (
 	"errors"
+	"sync"
 
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type StopAction struct {
 	jobSupervisor boshjobsuper.JobSupervisor
+	specService   boshas.V1Service
+	drainAction   DrainAction
+
+	// Only drain scripts can be canceled; stopping services cannot
+	draining *drainingState
+}
+
+type drainingState struct {
+	sync.Mutex
+	draining bool
 }
 
-func NewStop(jobSupervisor boshjobsuper.JobSupervisor) (stop StopAction) {
+type StopOptions struct {
+	// Stops jobs immediately without running drain scripts (e.g. during emergency operations)
+	SkipDrain bool `json:"skip_drain"`
+}
+
+func NewStop(jobSupervisor boshjobsuper.JobSupervisor, specService boshas.V1Service, drainAction DrainAction) (stop StopAction) {
 	stop = StopAction{
 		jobSupervisor: jobSupervisor,
+		specService:   specService,
+		drainAction:   drainAction,
+		draining:      &drainingState{},
 	}
 	return
 }
@@ -26,8 +46,28 @@ func (a StopAction) IsPersistent() bool {
 	return false
 }
 
-func (a StopAction) Run() (value string, err error) {
-	err = a.jobSupervisor.Stop()
+func (a StopAction) Run(options ...StopOptions) (value string, err error) {
+	skipDrain := len(options) > 0 && options[0].SkipDrain
+
+	if !skipDrain {
+		err = a.drain()
+		if err != nil {
+			err = bosherr.WrapError(err, "Draining jobs")
+			return
+		}
+	}
+
+	currentSpec, err := a.specService.Get()
+	if err != nil {
+		err = bosherr.WrapError(err, "Getting apply spec")
+		return
+	}
+
+	if currentSpec.JobSpec.OrderedStart {
+		err = a.jobSupervisor.StopJobs(reversedJobNames(currentSpec.JobSpec.JobTemplateNames()))
+	} else {
+		err = a.jobSupervisor.Stop()
+	}
 	if err != nil {
 		err = bosherr.WrapError(err, "Stopping Monitored Services")
 		return
@@ -42,5 +82,35 @@ func (a StopAction) Resume() (interface{}, error) {
 }
 
 func (a StopAction) Cancel() error {
-	return errors.New("not supported")
+	a.draining.Lock()
+	defer a.draining.Unlock()
+
+	if !a.draining.draining {
+		return errors.New("not supported when not draining")
+	}
+
+	return a.drainAction.Cancel()
+}
+
+func (a StopAction) drain() error {
+	a.draining.Lock()
+	a.draining.draining = true
+	a.draining.Unlock()
+
+	defer func() {
+		a.draining.Lock()
+		a.draining.draining = false
+		a.draining.Unlock()
+	}()
+
+	_, err := a.drainAction.Run(DrainTypeShutdown)
+	return err
+}
+
+func reversedJobNames(jobNames []string) []string {
+	reversed := make([]string, 0, len(jobNames))
+	for i := len(jobNames) - 1; i >= 0; i-- {
+		reversed = append(reversed, jobNames[i])
+	}
+	return reversed
 }
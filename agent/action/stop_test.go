@@ -1,23 +1,60 @@
 package action_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
+	fakedrain "github.com/cloudfoundry/bosh-agent/agent/script/drain/fakes"
+	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 func init() {
 	Describe("Stop", func() {
 		var (
-			jobSupervisor *fakejobsuper.FakeJobSupervisor
-			action        StopAction
+			jobSupervisor     *fakejobsuper.FakeJobSupervisor
+			notifier          *fakenotif.FakeNotifier
+			specService       *fakeas.FakeV1Service
+			jobScriptProvider *fakescript.FakeJobScriptProvider
+			parallelScript    *fakescript.FakeCancellableScript
+			drainedJobs       []string
+			action            StopAction
 		)
 
 		BeforeEach(func() {
 			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
-			action = NewStop(jobSupervisor)
+			notifier = fakenotif.NewFakeNotifier()
+			specService = fakeas.NewFakeV1Service()
+			jobScriptProvider = &fakescript.FakeJobScriptProvider{}
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+
+			drainedJobs = []string{}
+			jobScriptProvider.NewDrainScriptStub = func(jobName string, params boshdrain.ScriptParams) boshscript.CancellableScript {
+				drainedJobs = append(drainedJobs, jobName)
+				return fakedrain.NewFakeScript(jobName)
+			}
+
+			parallelScript = &fakescript.FakeCancellableScript{}
+			jobScriptProvider.NewParallelScriptReturns(parallelScript)
+
+			specService.Spec = boshas.V1ApplySpec{
+				JobSpec: boshas.JobSpec{
+					Template:         "foo",
+					JobTemplateSpecs: []boshas.JobTemplateSpec{{Name: "foo"}},
+				},
+			}
+
+			drainAction := NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger)
+			action = NewStop(jobSupervisor, specService, drainAction)
 		})
 
 		It("is asynchronous", func() {
@@ -34,10 +71,110 @@ func init() {
 			Expect(stopped).To(Equal("stopped"))
 		})
 
-		It("stops job supervisor services", func() {
-			_, err := action.Run()
-			Expect(err).ToNot(HaveOccurred())
-			Expect(jobSupervisor.Stopped).To(BeTrue())
+		Context("when skip_drain is not given", func() {
+			It("runs drain scripts before stopping job supervisor services", func() {
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(drainedJobs).To(Equal([]string{"foo"}))
+				Expect(parallelScript.RunCallCount()).To(Equal(1))
+				Expect(notifier.NotifiedShutdown).To(BeTrue())
+				Expect(jobSupervisor.Stopped).To(BeTrue())
+			})
+
+			It("does not stop services if draining fails", func() {
+				parallelScript.RunReturns(errors.New("fake-drain-err"))
+
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-drain-err"))
+				Expect(jobSupervisor.Stopped).To(BeFalse())
+			})
+		})
+
+		Context("when skip_drain is false", func() {
+			It("runs drain scripts", func() {
+				_, err := action.Run(StopOptions{SkipDrain: false})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(parallelScript.RunCallCount()).To(Equal(1))
+				Expect(jobSupervisor.Stopped).To(BeTrue())
+			})
+		})
+
+		Context("when skip_drain is true", func() {
+			It("stops job supervisor services without running drain scripts", func() {
+				_, err := action.Run(StopOptions{SkipDrain: true})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(drainedJobs).To(BeEmpty())
+				Expect(parallelScript.RunCallCount()).To(Equal(0))
+				Expect(notifier.NotifiedShutdown).To(BeFalse())
+				Expect(jobSupervisor.Stopped).To(BeTrue())
+			})
+		})
+
+		Context("when spec declares ordered start", func() {
+			BeforeEach(func() {
+				specService.Spec.JobSpec.OrderedStart = true
+				specService.Spec.JobSpec.JobTemplateSpecs = []boshas.JobTemplateSpec{
+					{Name: "fake-job-1"},
+					{Name: "fake-job-2"},
+					{Name: "fake-job-3"},
+				}
+			})
+
+			It("stops services of jobs in reverse declared order", func() {
+				_, err := action.Run(StopOptions{SkipDrain: true})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(jobSupervisor.Stopped).To(BeFalse())
+				Expect(jobSupervisor.StoppedJobNames).To(Equal([]string{"fake-job-3", "fake-job-2", "fake-job-1"}))
+			})
+
+			It("returns error when stopping jobs fails", func() {
+				jobSupervisor.StopJobsErr = errors.New("fake-stop-jobs-err")
+
+				_, err := action.Run(StopOptions{SkipDrain: true})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-stop-jobs-err"))
+			})
+		})
+
+		It("returns error when getting apply spec fails", func() {
+			specService.GetErr = errors.New("fake-get-spec-err")
+
+			_, err := action.Run(StopOptions{SkipDrain: true})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-get-spec-err"))
+			Expect(jobSupervisor.Stopped).To(BeFalse())
+		})
+
+		Describe("Cancel", func() {
+			It("returns error when drain scripts are not running", func() {
+				err := action.Cancel()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("not supported when not draining"))
+			})
+
+			It("cancels running drain scripts", func() {
+				cancelErr := make(chan error, 1)
+				parallelScript.RunStub = func() error {
+					cancelErr <- action.Cancel()
+					return nil
+				}
+
+				_, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(<-cancelErr).ToNot(HaveOccurred())
+			})
+		})
+
+		It("returns error when stopping services fails", func() {
+			jobSupervisor.StopErr = errors.New("fake-stop-err")
+
+			_, err := action.Run(StopOptions{SkipDrain: true})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-stop-err"))
 		})
 	})
 }
@@ -0,0 +1,49 @@
+package action
+
+import (
+	"errors"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type SyncNtpAction struct {
+	platform boshplatform.Platform
+}
+
+// NewSyncNtp lets director change NTP servers after bootstrap
+func NewSyncNtp(platform boshplatform.Platform) SyncNtpAction {
+	return SyncNtpAction{platform: platform}
+}
+
+func (a SyncNtpAction) IsAsynchronous() bool {
+	return false
+}
+
+func (a SyncNtpAction) IsPersistent() bool {
+	return false
+}
+
+// Run returns servers that were applied; empty list clears custom servers.
+// Servers are not saved to settings and are replaced by NTP servers
+// from settings when agent restarts or reloads settings
+func (a SyncNtpAction) Run(servers []string) ([]string, error) {
+	if servers == nil {
+		servers = []string{}
+	}
+
+	err := a.platform.SyncTimeWithNtpServers(servers)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Syncing time with NTP servers")
+	}
+
+	return servers, nil
+}
+
+func (a SyncNtpAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a SyncNtpAction) Cancel() error {
+	return errors.New("not supported")
+}
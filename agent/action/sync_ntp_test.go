@@ -0,0 +1,59 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+)
+
+var _ = Describe("SyncNtp", func() {
+	var (
+		platform *fakeplatform.FakePlatform
+		action   SyncNtpAction
+	)
+
+	BeforeEach(func() {
+		platform = fakeplatform.NewFakePlatform()
+		action = NewSyncNtp(platform)
+	})
+
+	It("is synchronous", func() {
+		Expect(action.IsAsynchronous()).To(BeFalse())
+	})
+
+	It("is not persistent", func() {
+		Expect(action.IsPersistent()).To(BeFalse())
+	})
+
+	Describe("Run", func() {
+		It("syncs time with given ntp servers and returns them", func() {
+			servers, err := action.Run([]string{"0.north-america.pool.ntp.org", "1.north-america.pool.ntp.org"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(servers).To(Equal([]string{"0.north-america.pool.ntp.org", "1.north-america.pool.ntp.org"}))
+
+			Expect(platform.SyncTimeWithNtpServersServers).To(Equal([]string{"0.north-america.pool.ntp.org", "1.north-america.pool.ntp.org"}))
+		})
+
+		It("clears ntp servers when no ntp server is given", func() {
+			servers, err := action.Run(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(servers).To(Equal([]string{}))
+
+			Expect(platform.SyncTimeWithNtpServersCalled).To(BeTrue())
+			Expect(platform.SyncTimeWithNtpServersServers).To(Equal([]string{}))
+		})
+
+		It("returns error when syncing time fails", func() {
+			platform.SyncTimeWithNtpServersErr = errors.New("fake-sync-err")
+
+			_, err := action.Run([]string{"0.north-america.pool.ntp.org"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Syncing time with NTP servers"))
+			Expect(err.Error()).To(ContainSubstring("fake-sync-err"))
+		})
+	})
+})
@@ -40,6 +40,12 @@ func (a UnmountDiskAction) Run(diskID string) (value interface{}, err error) {
 		return
 	}
 
+	err = settings.ValidatePersistentDisk(diskID)
+	if err != nil {
+		err = bosherr.WrapError(err, "Validating persistent disk settings")
+		return
+	}
+
 	didUnmount, err := a.platform.UnmountPersistentDisk(diskSettings)
 	if err != nil {
 		err = bosherr.WrapError(err, "Unmounting persistent disk")
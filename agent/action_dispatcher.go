@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"context"
+
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
@@ -21,6 +23,9 @@ type concreteActionDispatcher struct {
 	taskManager   boshtask.Manager
 	actionFactory boshaction.Factory
 	actionRunner  boshaction.Runner
+
+	// Nil when all actions are allowed
+	allowedActions map[string]bool
 }
 
 func NewActionDispatcher(
@@ -29,13 +34,25 @@ func NewActionDispatcher(
 	taskManager boshtask.Manager,
 	actionFactory boshaction.Factory,
 	actionRunner boshaction.Runner,
+	allowedActions []string,
 ) (dispatcher ActionDispatcher) {
+	var allowedActionsMap map[string]bool
+
+	// Empty allowlist allows all actions for compatibility
+	if len(allowedActions) > 0 {
+		allowedActionsMap = map[string]bool{}
+		for _, method := range allowedActions {
+			allowedActionsMap[method] = true
+		}
+	}
+
 	return concreteActionDispatcher{
-		logger:        logger,
-		taskService:   taskService,
-		taskManager:   taskManager,
-		actionFactory: actionFactory,
-		actionRunner:  actionRunner,
+		logger:         logger,
+		taskService:    taskService,
+		taskManager:    taskManager,
+		actionFactory:  actionFactory,
+		actionRunner:   actionRunner,
+		allowedActions: allowedActionsMap,
 	}
 }
 
@@ -51,6 +68,14 @@ func (dispatcher concreteActionDispatcher) ResumePreviouslyDispatchedTasks() {
 	}
 
 	for _, taskInfo := range taskInfos {
+		if !dispatcher.isAllowed(taskInfo.Method) {
+			dispatcher.logger.Error(actionDispatcherLogTag, "Not resuming task of disallowed action %s", taskInfo.Method)
+			if removeErr := dispatcher.taskManager.RemoveInfo(taskInfo.TaskID); removeErr != nil {
+				dispatcher.logger.Warn(actionDispatcherLogTag, "Failed to remove task info: %s", removeErr.Error())
+			}
+			continue
+		}
+
 		action, err := dispatcher.actionFactory.Create(taskInfo.Method)
 		if err != nil {
 			dispatcher.logger.Error(actionDispatcherLogTag, "Unknown action %s", taskInfo.Method)
@@ -81,6 +106,17 @@ func (dispatcher concreteActionDispatcher) Dispatch(req boshhandler.Request) bos
 		return boshhandler.NewExceptionResponse(bosherr.Errorf("unknown message %s", req.Method))
 	}
 
+	if !dispatcher.isAllowed(req.Method) {
+		dispatcher.logger.Error(actionDispatcherLogTag, "Action %s is not allowed", req.Method)
+		return boshhandler.NewExceptionResponse(bosherr.Errorf("Action %s is not allowed", req.Method))
+	}
+
+	// Actions that cannot be canceled would keep running past timeout
+	if req.Timeout() > 0 && !boshaction.IsCancelable(req.Method) {
+		dispatcher.logger.Error(actionDispatcherLogTag, "Action %s cannot be run with timeout", req.Method)
+		return boshhandler.NewExceptionResponse(bosherr.Errorf("Action %s cannot be run with timeout since it cannot be canceled", req.Method))
+	}
+
 	if action.IsAsynchronous() {
 		return dispatcher.dispatchAsynchronousAction(action, req)
 	}
@@ -88,6 +124,10 @@ func (dispatcher concreteActionDispatcher) Dispatch(req boshhandler.Request) bos
 	return dispatcher.dispatchSynchronousAction(action, req)
 }
 
+func (dispatcher concreteActionDispatcher) isAllowed(method string) bool {
+	return dispatcher.allowedActions == nil || dispatcher.allowedActions[method]
+}
+
 func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	action boshaction.Action,
 	req boshhandler.Request,
@@ -98,7 +138,7 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	var err error
 
 	runTask := func() (interface{}, error) {
-		return dispatcher.actionRunner.Run(action, req.GetPayload())
+		return dispatcher.runAction(action, req)
 	}
 
 	cancelTask := func(_ boshtask.Task) error { return action.Cancel() }
@@ -150,14 +190,30 @@ func (dispatcher concreteActionDispatcher) dispatchSynchronousAction(
 ) boshhandler.Response {
 	dispatcher.logger.Info(actionDispatcherLogTag, "Running sync action %s", req.Method)
 
-	value, err := dispatcher.actionRunner.Run(action, req.GetPayload())
+	value, err := dispatcher.runAction(action, req)
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Action Failed %s", req.Method)
 		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
-		return boshhandler.NewExceptionResponse(err)
 	}
 
-	return boshhandler.NewValueResponse(value)
+	return boshhandler.NewResponse(value, err)
+}
+
+// runAction cancels action once timeout requested by director elapses
+func (dispatcher concreteActionDispatcher) runAction(action boshaction.Action, req boshhandler.Request) (interface{}, error) {
+	if req.Timeout() <= 0 {
+		return dispatcher.actionRunner.Run(action, req.GetPayload())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), req.Timeout())
+	defer cancel()
+
+	value, err := dispatcher.actionRunner.RunContext(ctx, action, req.GetPayload())
+	if _, isTimeout := err.(boshaction.TimeoutError); isTimeout {
+		return value, bosherr.WrapErrorf(err, "Action %s exceeded timeout of %s", req.Method, req.Timeout())
+	}
+
+	return value, err
 }
 
 func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
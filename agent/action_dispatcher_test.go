@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent"
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
@@ -17,6 +19,26 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// blockingAction runs until it is canceled
+type blockingAction struct {
+	canceled chan struct{}
+}
+
+func (a blockingAction) IsAsynchronous() bool { return false }
+func (a blockingAction) IsPersistent() bool   { return false }
+
+func (a blockingAction) Run() (string, error) {
+	<-a.canceled
+	return "fake-late-value", nil
+}
+
+func (a blockingAction) Resume() (interface{}, error) { return nil, nil }
+
+func (a blockingAction) Cancel() error {
+	close(a.canceled)
+	return nil
+}
+
 func init() {
 	Describe("actionDispatcher", func() {
 		var (
@@ -34,7 +56,7 @@ func init() {
 			taskManager = faketask.NewFakeManager()
 			actionFactory = fakeaction.NewFakeFactory()
 			actionRunner = &fakeaction.FakeRunner{}
-			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner)
+			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, nil)
 		})
 
 		It("responds with exception when the method is unknown", func() {
@@ -63,6 +85,21 @@ func init() {
 				Expect(boshhandler.NewValueResponse("fake-value")).To(Equal(resp))
 			})
 
+			It("serializes synchronous action result in value envelope", func() {
+				actionRunner.RunValue = map[string]interface{}{"fake-key": []string{"fake-value"}}
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":{"fake-key":["fake-value"]}}`)
+			})
+
+			It("serializes failing synchronous action in exception envelope without its value", func() {
+				actionRunner.RunValue = "fake-partial-value"
+				actionRunner.RunErr = errors.New("fake-run-error")
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"Action Failed fake-action: fake-run-error"}}`)
+			})
+
 			It("handles synchronous action when err", func() {
 				actionRunner.RunErr = errors.New("fake-run-error")
 
@@ -70,6 +107,47 @@ func init() {
 				expectedJSON := fmt.Sprintf("{\"exception\":{\"message\":\"Action Failed %s: fake-run-error\"}}", req.Method)
 				boshassert.MatchesJSONString(GinkgoT(), resp, expectedJSON)
 			})
+
+			It("runs action without deadline when request has no timeout", func() {
+				dispatcher.Dispatch(req)
+				Expect(actionRunner.RunCtx).To(BeNil())
+			})
+
+			It("runs cancelable action with deadline when request has timeout", func() {
+				req = boshhandler.NewRequest("fake-reply", "drain", []byte("fake-payload"))
+				req.TimeoutSeconds = 30
+				actionFactory.RegisterAction("drain", &fakeaction.TestAction{Asynchronous: false})
+
+				before := time.Now()
+				dispatcher.Dispatch(req)
+
+				deadline, ok := actionRunner.RunCtx.Deadline()
+				Expect(ok).To(BeTrue())
+				Expect(deadline).To(BeTemporally("~", before.Add(30*time.Second), time.Second))
+				Expect(req.GetPayload()).To(Equal(actionRunner.RunPayload))
+			})
+
+			It("responds with timeout exception after canceling action when timeout elapses", func() {
+				action := blockingAction{canceled: make(chan struct{})}
+				actionFactory.RegisterAction("stop", action)
+
+				dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, boshaction.NewRunner(), nil)
+
+				req := boshhandler.NewRequest("fake-reply", "stop", []byte(`{"arguments":[]}`))
+				req.TimeoutSeconds = 1
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"Action Failed stop: Action stop exceeded timeout of 1s: Action timed out: context deadline exceeded"}}`)
+				Expect(action.canceled).To(BeClosed())
+			})
+
+			It("refuses timeout for action that cannot be canceled", func() {
+				req.TimeoutSeconds = 30
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"Action fake-action cannot be run with timeout since it cannot be canceled"}}`)
+				Expect(actionRunner.RunAction).To(BeNil())
+			})
 		})
 
 		Context("when action is asynchronous", func() {
@@ -404,5 +482,39 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-cancel-err-2"))
 			})
 		})
+
+		Context("when allowed actions are configured", func() {
+			BeforeEach(func() {
+				dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, []string{"ping", "fake-allowed-action"})
+				actionFactory.RegisterAction("fake-allowed-action", &fakeaction.TestAction{Asynchronous: false})
+				actionFactory.RegisterAction("run_errand", &fakeaction.TestAction{Asynchronous: true})
+			})
+
+			It("dispatches an allowed action", func() {
+				actionRunner.RunValue = "fake-value"
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "fake-allowed-action", []byte("fake-payload")))
+				Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value")))
+			})
+
+			It("responds with exception for a disallowed action without running it", func() {
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "run_errand", []byte("fake-payload")))
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"Action run_errand is not allowed"}}`)
+				Expect(taskService.StartedTasks).To(BeEmpty())
+				Expect(actionRunner.RunPayload).To(BeNil())
+			})
+
+			It("does not resume tasks of disallowed actions", func() {
+				err := taskManager.AddInfo(boshtask.Info{TaskID: "fake-task-id", Method: "run_errand"})
+				Expect(err).ToNot(HaveOccurred())
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+				Expect(taskService.StartedTasks).To(BeEmpty())
+
+				taskInfos, err := taskManager.GetInfos()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(taskInfos).To(BeEmpty())
+			})
+		})
 	})
 }
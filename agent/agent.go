@@ -110,11 +110,12 @@ func (a Agent) generateHeartbeats(errCh chan error) {
 	// Send initial heartbeat
 	a.sendHeartbeat(errCh)
 
-	tickChan := time.Tick(a.heartbeatInterval)
+	ticker := a.timeService.NewTicker(a.heartbeatInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
-		case <-tickChan:
+		case <-ticker.C():
 			a.sendHeartbeat(errCh)
 		}
 	}
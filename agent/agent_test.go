@@ -171,6 +171,21 @@ func init() {
 						}
 					}
 
+					stopTicking := make(chan struct{})
+					defer close(stopTicking)
+
+					go func() {
+						for {
+							select {
+							case <-stopTicking:
+								return
+							default:
+								timeService.WaitForWatcherAndIncrement(5 * time.Millisecond)
+								time.Sleep(time.Millisecond)
+							}
+						}
+					}()
+
 					err := agent.Run()
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("stop"))
@@ -67,6 +67,13 @@ func (s concreteV1Service) PopulateDHCPNetworks(spec V1ApplySpec, settings boshs
 			return V1ApplySpec{}, bosherr.Errorf("Network '%s' is not found in settings", networkName)
 		}
 
+		if network.IsVIP() {
+			// VIP is not configured on any interface, only reported
+			// so that Director can associate it with this instance
+			spec.NetworkSpecs[networkName] = networkSpec.PopulateVIPInfo(network.IP)
+			continue
+		}
+
 		if !network.IsDHCP() {
 			continue
 		}
@@ -78,5 +85,14 @@ func (s concreteV1Service) PopulateDHCPNetworks(spec V1ApplySpec, settings boshs
 		)
 	}
 
+	// IP assigned by Director takes precedence;
+	// without default network for gateway IP stays empty
+	if spec.IP == "" {
+		ip, err := settings.PrimaryIP()
+		if err == nil {
+			spec.IP = ip
+		}
+	}
+
 	return spec, nil
 }
@@ -137,7 +137,21 @@ func init() {
 					It("returns spec without modifying any networks", func() {
 						spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
 						Expect(err).ToNot(HaveOccurred())
-						Expect(spec).To(Equal(unresolvedSpec))
+						Expect(spec.NetworkSpecs).To(Equal(unresolvedSpec.NetworkSpecs))
+					})
+
+					It("sets ip of default network for gateway", func() {
+						spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(spec.IP).To(Equal("fake-manual-ip"))
+					})
+
+					It("keeps ip assigned by director", func() {
+						unresolvedSpec.IP = "fake-director-ip"
+
+						spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(spec.IP).To(Equal("fake-director-ip"))
 					})
 				})
 
@@ -165,10 +179,14 @@ func init() {
 					})
 
 					It("returns spec with networks modified via DHCP and keeps everything else the same", func() {
+						manualSetting.Default = []string{"gateway"}
+						settings.Networks["static-net1"] = manualSetting
+
 						spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
 						Expect(err).ToNot(HaveOccurred())
 						Expect(spec).To(Equal(V1ApplySpec{
 							Deployment: "fake-deployment",
+							IP:         "fake-manual-ip",
 							NetworkSpecs: map[string]NetworkSpec{
 								"static-net1": staticSpec,
 								"dhcp-net2": NetworkSpec{
@@ -185,6 +203,54 @@ func init() {
 				})
 			})
 
+			Context("when there are dynamic and vip networks", func() {
+				var vipSpec NetworkSpec
+
+				BeforeEach(func() {
+					settings.Networks["dhcp-net"] = dynamicSetting
+					settings.Networks["vip-net"] = boshsettings.Network{
+						Type: "vip",
+						IP:   "fake-vip-ip",
+					}
+
+					vipSpec = NetworkSpec{
+						Fields: map[string]interface{}{"type": "vip"},
+					}
+
+					unresolvedSpec.NetworkSpecs["dhcp-net"] = dhcpSpec
+					unresolvedSpec.NetworkSpecs["vip-net"] = vipSpec
+				})
+
+				It("records vip network ip without treating it as dhcp network", func() {
+					spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(spec.NetworkSpecs["vip-net"]).To(Equal(NetworkSpec{
+						Fields: map[string]interface{}{
+							"type": "vip",
+							"ip":   "fake-vip-ip",
+						},
+					}))
+
+					Expect(spec.NetworkSpecs["dhcp-net"].Fields["ip"]).To(Equal(dynamicSetting.IP))
+				})
+
+				It("does not set ip when no network is default for gateway", func() {
+					spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(spec.IP).To(BeEmpty())
+				})
+
+				It("keeps vip network ip from spec when settings do not include it", func() {
+					settings.Networks["vip-net"] = boshsettings.Network{Type: "vip"}
+					vipSpec.Fields["ip"] = "fake-spec-vip-ip"
+
+					spec, err := service.PopulateDHCPNetworks(unresolvedSpec, settings)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(spec.NetworkSpecs["vip-net"].Fields["ip"]).To(Equal("fake-spec-vip-ip"))
+				})
+			})
+
 			Context("when associated network cannot be found in settings", func() {
 				BeforeEach(func() {
 					settings.Networks["net-present-in-settings"] = manualSetting
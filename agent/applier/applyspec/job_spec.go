@@ -12,6 +12,18 @@ type JobSpec struct {
 	Sha1             string            `json:"sha1"`
 	BlobstoreID      string            `json:"blobstore_id"`
 	JobTemplateSpecs []JobTemplateSpec `json:"templates"`
+
+	// When set jobs are started in order of templates and stopped in reverse order
+	OrderedStart bool `json:"ordered_start,omitempty"`
+}
+
+// JobTemplateNames returns names of templates in declared order
+func (s *JobSpec) JobTemplateNames() []string {
+	names := []string{}
+	for _, value := range s.JobTemplateSpecs {
+		names = append(names, value.Name)
+	}
+	return names
 }
 
 func (s *JobSpec) JobTemplateSpecsAsJobs() []models.Job {
@@ -7,10 +7,17 @@ type JobTemplateSpec struct {
 	Version     string `json:"version"`
 	Sha1        string `json:"sha1"`
 	BlobstoreID string `json:"blobstore_id"`
+
+	Logrotate *JobLogrotateSpec `json:"logrotate,omitempty"`
+}
+
+type JobLogrotateSpec struct {
+	Size   string `json:"size"`
+	Rotate int    `json:"rotate"`
 }
 
 func (s *JobTemplateSpec) AsJob() models.Job {
-	return models.Job{
+	job := models.Job{
 		Name:    s.Name,
 		Version: s.Version,
 		Source: models.Source{
@@ -18,4 +25,13 @@ func (s *JobTemplateSpec) AsJob() models.Job {
 			BlobstoreID: s.BlobstoreID,
 		},
 	}
+
+	if s.Logrotate != nil {
+		job.Logrotate = &models.Logrotate{
+			Size:   s.Logrotate.Size,
+			Rotate: s.Logrotate.Rotate,
+		}
+	}
+
+	return job
 }
@@ -2,8 +2,10 @@ package applyspec
 
 import (
 	"encoding/json"
+	"sort"
 
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type V1ApplySpec struct {
@@ -23,6 +25,10 @@ type V1ApplySpec struct {
 	NodeID           string `json:"id"`
 	AvailabilityZone string `json:"az"`
 
+	// IP is only reported to Director by get_state;
+	// job templates are rendered by Director and do not see it
+	IP string `json:"ip,omitempty"`
+
 	PersistentDisk int `json:"persistent_disk"`
 
 	RenderedTemplatesArchiveSpec RenderedTemplatesArchiveSpec `json:"rendered_templates_archive"`
@@ -51,6 +57,69 @@ type NetworkSpec struct {
 	Fields map[string]interface{}
 }
 
+// Validate checks fields required to apply jobs and packages
+// so that malformed specs fail before anything is downloaded.
+// All problems are returned in a single error.
+func (s V1ApplySpec) Validate() error {
+	errs := []error{}
+
+	if s.Deployment == "" {
+		errs = append(errs, bosherr.Error("Missing deployment"))
+	}
+
+	if s.JobSpec.Name == nil || *s.JobSpec.Name == "" {
+		errs = append(errs, bosherr.Error("Missing job name"))
+	}
+
+	for i, template := range s.JobSpec.JobTemplateSpecs {
+		if template.Name == "" {
+			errs = append(errs, bosherr.Errorf("Job template at index %d is missing name", i))
+			continue
+		}
+
+		if template.Sha1 == "" {
+			errs = append(errs, bosherr.Errorf("Job template '%s' is missing sha1", template.Name))
+		}
+
+		if template.BlobstoreID == "" {
+			errs = append(errs, bosherr.Errorf("Job template '%s' is missing blobstore_id", template.Name))
+		}
+	}
+
+	packageKeys := []string{}
+	for key := range s.PackageSpecs {
+		packageKeys = append(packageKeys, key)
+	}
+
+	sort.Strings(packageKeys)
+
+	for _, key := range packageKeys {
+		pkg := s.PackageSpecs[key]
+
+		if pkg.Name == "" {
+			errs = append(errs, bosherr.Errorf("Package '%s' is missing name", key))
+		}
+
+		if pkg.Version == "" {
+			errs = append(errs, bosherr.Errorf("Package '%s' is missing version", key))
+		}
+
+		if pkg.Sha1 == "" {
+			errs = append(errs, bosherr.Errorf("Package '%s' is missing sha1", key))
+		}
+
+		if pkg.BlobstoreID == "" {
+			errs = append(errs, bosherr.Errorf("Package '%s' is missing blobstore_id", key))
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
 // Jobs returns a list of pre-rendered job templates
 // extracted from a single tarball provided by BOSH director.
 func (s V1ApplySpec) Jobs() []models.Job {
@@ -79,6 +148,20 @@ func (s V1ApplySpec) MaxLogFileSize() string {
 	return "50M"
 }
 
+// DNSRecords maps each network's dns_record_name to its IP,
+// skipping networks that are missing either.
+func (s V1ApplySpec) DNSRecords() map[string]string {
+	records := map[string]string{}
+	for _, network := range s.NetworkSpecs {
+		name, _ := network.Fields["dns_record_name"].(string)
+		ip, _ := network.Fields["ip"].(string)
+		if name != "" && ip != "" {
+			records[name] = ip
+		}
+	}
+	return records
+}
+
 func (s NetworkSpec) PopulateIPInfo(ip, netmask, gateway string) NetworkSpec {
 	if s.Fields == nil {
 		s.Fields = map[string]interface{}{}
@@ -89,6 +172,18 @@ func (s NetworkSpec) PopulateIPInfo(ip, netmask, gateway string) NetworkSpec {
 	return s
 }
 
+// PopulateVIPInfo keeps IP assigned by Director when settings do not include one
+func (s NetworkSpec) PopulateVIPInfo(ip string) NetworkSpec {
+	if ip == "" {
+		return s
+	}
+	if s.Fields == nil {
+		s.Fields = map[string]interface{}{}
+	}
+	s.Fields["ip"] = ip
+	return s
+}
+
 func (s *NetworkSpec) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, &s.Fields)
 }
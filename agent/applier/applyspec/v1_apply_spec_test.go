@@ -2,6 +2,7 @@ package applyspec_test
 
 import (
 	"encoding/json"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -128,6 +129,61 @@ var _ = Describe("V1ApplySpec", func() {
 		})
 	})
 
+	Describe("Validate", func() {
+		It("returns no error for valid spec", func() {
+			specJSON := `{
+				"deployment": "fake-deployment",
+				"job": {
+					"name": "router",
+					"templates": [
+						{"name": "template 1", "version": "0.1", "sha1": "template 1 sha1", "blobstore_id": "template-blob-id-1"}
+					]
+				},
+				"packages": {
+					"package 1": {"name": "package 1", "version": "0.1", "sha1": "package 1 sha1", "blobstore_id": "package-blob-id-1"}
+				}
+			}`
+
+			spec := V1ApplySpec{}
+			err := json.Unmarshal([]byte(specJSON), &spec)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(spec.Validate()).To(Succeed())
+		})
+
+		It("returns single error listing all missing required fields", func() {
+			specJSON := `{
+				"job": {
+					"templates": [
+						{"name": "template 1", "version": "0.1"},
+						{"version": "0.2", "sha1": "template 2 sha1", "blobstore_id": "template-blob-id-2"}
+					]
+				},
+				"packages": {
+					"package 1": {"name": "package 1", "sha1": "package 1 sha1"},
+					"package 2": {"version": "0.2", "sha1": "package 2 sha1", "blobstore_id": "package-blob-id-2"}
+				}
+			}`
+
+			spec := V1ApplySpec{}
+			err := json.Unmarshal([]byte(specJSON), &spec)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = spec.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(strings.Join([]string{
+				"Missing deployment",
+				"Missing job name",
+				"Job template 'template 1' is missing sha1",
+				"Job template 'template 1' is missing blobstore_id",
+				"Job template at index 1 is missing name",
+				"Package 'package 1' is missing version",
+				"Package 'package 1' is missing blobstore_id",
+				"Package 'package 2' is missing name",
+			}, "\n")))
+		})
+	})
+
 	Describe("Jobs", func() {
 		It("returns jobs specified in job specs", func() {
 			jobName := "fake-job-legacy-name"
@@ -224,6 +280,25 @@ var _ = Describe("V1ApplySpec", func() {
 			}))
 		})
 
+		It("returns logrotate settings of jobs that specify them", func() {
+			specJSON := `{
+				"job": {
+					"templates": [
+						{"name": "fake-job1-name", "logrotate": {"size": "fake-size", "rotate": 3}},
+						{"name": "fake-job2-name"}
+					]
+				}
+			}`
+
+			spec := V1ApplySpec{}
+			err := json.Unmarshal([]byte(specJSON), &spec)
+			Expect(err).ToNot(HaveOccurred())
+
+			jobs := spec.Jobs()
+			Expect(jobs[0].Logrotate).To(Equal(&models.Logrotate{Size: "fake-size", Rotate: 3}))
+			Expect(jobs[1].Logrotate).To(BeNil())
+		})
+
 		It("returns no jobs when no jobs specified", func() {
 			spec := V1ApplySpec{}
 			Expect(spec.Jobs()).To(Equal([]models.Job{}))
@@ -273,6 +348,31 @@ var _ = Describe("V1ApplySpec", func() {
 			Expect(spec.MaxLogFileSize()).To(Equal("fake-size"))
 		})
 	})
+
+	Describe("DNSRecords", func() {
+		It("returns dns record names mapped to ips", func() {
+			spec := V1ApplySpec{
+				NetworkSpecs: map[string]NetworkSpec{
+					"net-a": NetworkSpec{Fields: map[string]interface{}{"dns_record_name": "fake-name-a", "ip": "fake-ip-a"}},
+					"net-b": NetworkSpec{Fields: map[string]interface{}{"dns_record_name": "fake-name-b", "ip": "fake-ip-b"}},
+				},
+			}
+			Expect(spec.DNSRecords()).To(Equal(map[string]string{
+				"fake-name-a": "fake-ip-a",
+				"fake-name-b": "fake-ip-b",
+			}))
+		})
+
+		It("skips networks without a dns record name or ip", func() {
+			spec := V1ApplySpec{
+				NetworkSpecs: map[string]NetworkSpec{
+					"no-name": NetworkSpec{Fields: map[string]interface{}{"ip": "fake-ip"}},
+					"no-ip":   NetworkSpec{Fields: map[string]interface{}{"dns_record_name": "fake-name"}},
+				},
+			}
+			Expect(spec.DNSRecords()).To(BeEmpty())
+		})
+	})
 })
 
 var _ = Describe("NetworkSpec", func() {
@@ -10,10 +10,13 @@ import (
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+const defaultLogrotateRotate = 7
+
 type concreteApplier struct {
 	jobApplier        jobs.Applier
 	packageApplier    packages.Applier
 	logrotateDelegate LogrotateDelegate
+	jobStoreDelegate  JobStoreDelegate
 	jobSupervisor     boshjobsuper.JobSupervisor
 	dirProvider       boshdirs.Provider
 }
@@ -22,6 +25,7 @@ func NewConcreteApplier(
 	jobApplier jobs.Applier,
 	packageApplier packages.Applier,
 	logrotateDelegate LogrotateDelegate,
+	jobStoreDelegate JobStoreDelegate,
 	jobSupervisor boshjobsuper.JobSupervisor,
 	dirProvider boshdirs.Provider,
 ) Applier {
@@ -29,6 +33,7 @@ func NewConcreteApplier(
 		jobApplier:        jobApplier,
 		packageApplier:    packageApplier,
 		logrotateDelegate: logrotateDelegate,
+		jobStoreDelegate:  jobStoreDelegate,
 		jobSupervisor:     jobSupervisor,
 		dirProvider:       dirProvider,
 	}
@@ -71,6 +76,16 @@ func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec)
 		return bosherr.WrapError(err, "Keeping only needed jobs")
 	}
 
+	var jobNames []string
+	for _, job := range jobs {
+		jobNames = append(jobNames, job.Name)
+	}
+
+	err = a.jobStoreDelegate.SetupJobStores(jobNames)
+	if err != nil {
+		return bosherr.WrapError(err, "Setting up job stores")
+	}
+
 	for _, pkg := range desiredApplySpec.Packages() {
 		err = a.packageApplier.Apply(pkg)
 		if err != nil {
@@ -112,14 +127,58 @@ func (a *concreteApplier) ConfigureJobs(desiredApplySpec as.ApplySpec) error {
 }
 
 func (a *concreteApplier) setUpLogrotate(applySpec as.ApplySpec) error {
+	var jobNames []string
+	groupJobNames := []string{}
+
+	for _, job := range applySpec.Jobs() {
+		if job.Logrotate == nil {
+			groupJobNames = append(groupJobNames, job.Name)
+		} else {
+			jobNames = append(jobNames, job.Name)
+		}
+	}
+
+	// Group config rotates all log directories unless some jobs
+	// have their own config; their logs must then be left out of it
+	if len(jobNames) == 0 {
+		groupJobNames = nil
+	}
+
 	err := a.logrotateDelegate.SetupLogrotate(
 		boshsettings.VCAPUsername,
 		a.dirProvider.BaseDir(),
 		applySpec.MaxLogFileSize(),
+		groupJobNames,
 	)
 	if err != nil {
 		return bosherr.WrapError(err, "Logrotate setup failed")
 	}
 
+	for _, job := range applySpec.Jobs() {
+		if job.Logrotate == nil {
+			continue
+		}
+
+		size := job.Logrotate.Size
+		if size == "" {
+			size = applySpec.MaxLogFileSize()
+		}
+
+		rotate := job.Logrotate.Rotate
+		if rotate <= 0 {
+			rotate = defaultLogrotateRotate
+		}
+
+		err = a.logrotateDelegate.SetupJobLogrotate(job.Name, a.dirProvider.BaseDir(), size, rotate)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Logrotate setup for job %s failed", job.Name)
+		}
+	}
+
+	err = a.logrotateDelegate.KeepOnlyJobsLogrotate(jobNames)
+	if err != nil {
+		return bosherr.WrapError(err, "Removing logrotate config of removed jobs")
+	}
+
 	return nil
 }
@@ -22,19 +22,53 @@ import (
 type FakeLogRotateDelegate struct {
 	SetupLogrotateErr  error
 	SetupLogrotateArgs SetupLogrotateArgs
+
+	SetupJobLogrotateErr  error
+	SetupJobLogrotateArgs []SetupJobLogrotateArgs
+
+	KeepOnlyJobsLogrotateErr      error
+	KeepOnlyJobsLogrotateJobNames []string
 }
 
 type SetupLogrotateArgs struct {
 	GroupName string
 	BasePath  string
 	Size      string
+	JobNames  []string
+}
+
+type SetupJobLogrotateArgs struct {
+	JobName  string
+	BasePath string
+	Size     string
+	Rotate   int
 }
 
-func (d *FakeLogRotateDelegate) SetupLogrotate(groupName, basePath, size string) error {
-	d.SetupLogrotateArgs = SetupLogrotateArgs{groupName, basePath, size}
+func (d *FakeLogRotateDelegate) SetupLogrotate(groupName, basePath, size string, jobNames []string) error {
+	d.SetupLogrotateArgs = SetupLogrotateArgs{groupName, basePath, size, jobNames}
 	return d.SetupLogrotateErr
 }
 
+func (d *FakeLogRotateDelegate) SetupJobLogrotate(jobName, basePath, size string, rotate int) error {
+	d.SetupJobLogrotateArgs = append(d.SetupJobLogrotateArgs, SetupJobLogrotateArgs{jobName, basePath, size, rotate})
+	return d.SetupJobLogrotateErr
+}
+
+func (d *FakeLogRotateDelegate) KeepOnlyJobsLogrotate(jobNames []string) error {
+	d.KeepOnlyJobsLogrotateJobNames = jobNames
+	return d.KeepOnlyJobsLogrotateErr
+}
+
+type FakeJobStoreDelegate struct {
+	SetupJobStoresErr      error
+	SetupJobStoresJobNames []string
+}
+
+func (d *FakeJobStoreDelegate) SetupJobStores(jobNames []string) error {
+	d.SetupJobStoresJobNames = jobNames
+	return d.SetupJobStoresErr
+}
+
 func buildJob() models.Job {
 	uuidGen := boshuuid.NewGenerator()
 	uuid, err := uuidGen.Generate()
@@ -55,6 +89,7 @@ func init() {
 			jobApplier        *fakejobs.FakeApplier
 			packageApplier    *fakepackages.FakeApplier
 			logRotateDelegate *FakeLogRotateDelegate
+			jobStoreDelegate  *FakeJobStoreDelegate
 			jobSupervisor     *fakejobsuper.FakeJobSupervisor
 			applier           Applier
 		)
@@ -63,11 +98,13 @@ func init() {
 			jobApplier = fakejobs.NewFakeApplier()
 			packageApplier = fakepackages.NewFakeApplier()
 			logRotateDelegate = &FakeLogRotateDelegate{}
+			jobStoreDelegate = &FakeJobStoreDelegate{}
 			jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 			applier = NewConcreteApplier(
 				jobApplier,
 				packageApplier,
 				logRotateDelegate,
+				jobStoreDelegate,
 				jobSupervisor,
 				boshdirs.NewProvider("/fake-base-dir"),
 			)
@@ -226,6 +263,31 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
 			})
 
+			It("sets up stores of jobs in the desired spec", func() {
+				currentJob := buildJob()
+				desiredJob1 := buildJob()
+				desiredJob2 := buildJob()
+
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob1, desiredJob2}},
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(jobStoreDelegate.SetupJobStoresJobNames).To(Equal([]string{desiredJob1.Name, desiredJob2.Name}))
+			})
+
+			It("returns error when setting up job stores fails", func() {
+				jobStoreDelegate.SetupJobStoresErr = errors.New("fake-setup-job-stores-error")
+
+				err := applier.Apply(
+					&fakeas.FakeApplySpec{},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+				)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-setup-job-stores-error"))
+			})
+
 			It("apply applies packages", func() {
 				pkg1 := buildPackage()
 				pkg2 := buildPackage()
@@ -319,6 +381,80 @@ func init() {
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-set-up-logrotate-error"))
 			})
+
+			Context("when jobs configure logrotate", func() {
+				var desiredSpec *fakeas.FakeApplySpec
+
+				BeforeEach(func() {
+					desiredSpec = &fakeas.FakeApplySpec{
+						MaxLogFileSizeResult: "fake-size",
+						JobResults: []models.Job{
+							{Name: "fake-job-1", Logrotate: &models.Logrotate{Size: "fake-job-1-size", Rotate: 3}},
+							{Name: "fake-job-2"},
+							{Name: "fake-job-3", Logrotate: &models.Logrotate{}},
+						},
+					}
+				})
+
+				It("leaves logs of jobs that configure logrotate out of group config", func() {
+					err := applier.Apply(&fakeas.FakeApplySpec{}, desiredSpec)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(logRotateDelegate.SetupLogrotateArgs).To(Equal(SetupLogrotateArgs{
+						GroupName: boshsettings.VCAPUsername,
+						BasePath:  "/fake-base-dir",
+						Size:      "fake-size",
+						JobNames:  []string{"fake-job-2"},
+					}))
+				})
+
+				It("sets up logrotate for jobs that configure it", func() {
+					err := applier.Apply(&fakeas.FakeApplySpec{}, desiredSpec)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(logRotateDelegate.SetupJobLogrotateArgs).To(Equal([]SetupJobLogrotateArgs{
+						{JobName: "fake-job-1", BasePath: "/fake-base-dir", Size: "fake-job-1-size", Rotate: 3},
+						{JobName: "fake-job-3", BasePath: "/fake-base-dir", Size: "fake-size", Rotate: 7},
+					}))
+				})
+
+				It("keeps only logrotate config of jobs that configure it", func() {
+					currentSpec := &fakeas.FakeApplySpec{
+						JobResults: []models.Job{
+							{Name: "fake-removed-job", Logrotate: &models.Logrotate{Size: "fake-size", Rotate: 1}},
+						},
+					}
+
+					err := applier.Apply(currentSpec, desiredSpec)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(logRotateDelegate.KeepOnlyJobsLogrotateJobNames).To(Equal([]string{"fake-job-1", "fake-job-3"}))
+				})
+
+				It("removes all job logrotate config when no jobs configure it", func() {
+					err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(logRotateDelegate.KeepOnlyJobsLogrotateJobNames).To(BeEmpty())
+				})
+
+				It("apply errs if setup job logrotate fails", func() {
+					logRotateDelegate.SetupJobLogrotateErr = errors.New("fake-set-up-job-logrotate-error")
+
+					err := applier.Apply(&fakeas.FakeApplySpec{}, desiredSpec)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-job-1"))
+					Expect(err.Error()).To(ContainSubstring("fake-set-up-job-logrotate-error"))
+				})
+
+				It("apply errs if removing job logrotate config fails", func() {
+					logRotateDelegate.KeepOnlyJobsLogrotateErr = errors.New("fake-keep-only-error")
+
+					err := applier.Apply(&fakeas.FakeApplySpec{}, desiredSpec)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
+				})
+			})
 		})
 	})
 }
@@ -0,0 +1,5 @@
+package applier
+
+type JobStoreDelegate interface {
+	SetupJobStores(jobNames []string) (err error)
+}
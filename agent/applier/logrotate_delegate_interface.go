@@ -1,5 +1,7 @@
 package applier
 
 type LogrotateDelegate interface {
-	SetupLogrotate(groupName, basePath, size string) (err error)
+	SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error)
+	SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error)
+	KeepOnlyJobsLogrotate(jobNames []string) (err error)
 }
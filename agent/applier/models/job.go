@@ -8,6 +8,15 @@ type Job struct {
 	// Packages that this job depends on; however,
 	// currently it will contain packages from all jobs
 	Packages []Package
+
+	// Logrotate is only set when job spec configures
+	// rotation of the job's logs
+	Logrotate *Logrotate
+}
+
+type Logrotate struct {
+	Size   string
+	Rotate int
 }
 
 func (s Job) BundleName() string {
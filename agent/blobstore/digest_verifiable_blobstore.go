@@ -0,0 +1,91 @@
+package blobstore
+
+import (
+	"io"
+	"os"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type digestVerifiableBlobstore struct {
+	blobstore boshblob.Blobstore
+	fs        boshsys.FileSystem
+}
+
+// NewDigestVerifiableBlobstore verifies fetched blobs against
+// multiple digest fingerprints, e.g. "<sha1>;sha256:<sha256>"
+func NewDigestVerifiableBlobstore(blobstore boshblob.Blobstore, fs boshsys.FileSystem) boshblob.Blobstore {
+	verifiable := digestVerifiableBlobstore{blobstore: blobstore, fs: fs}
+
+	// Streaming is offered only when wrapped blobstore supports it
+	// so that callers can keep checking for StreamingBlobstore
+	if streaming, ok := blobstore.(StreamingBlobstore); ok {
+		return streamingDigestVerifiableBlobstore{digestVerifiableBlobstore: verifiable, streaming: streaming}
+	}
+
+	return verifiable
+}
+
+type streamingDigestVerifiableBlobstore struct {
+	digestVerifiableBlobstore
+	streaming StreamingBlobstore
+}
+
+func (b streamingDigestVerifiableBlobstore) CreateFromReader(reader io.Reader) (string, error) {
+	return b.streaming.CreateFromReader(reader)
+}
+
+func (b digestVerifiableBlobstore) Get(blobID, fingerprint string) (string, error) {
+	if fingerprint == "" {
+		return b.blobstore.Get(blobID, fingerprint)
+	}
+
+	digest, err := ParseMultipleDigest(fingerprint)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing fingerprint of blob %s", blobID)
+	}
+
+	// Inner blobstore only understands SHA1 fingerprints
+	// hence verification is fully done here
+	fileName, err := b.blobstore.Get(blobID, "")
+	if err != nil {
+		return "", err
+	}
+
+	err = b.verify(fileName, digest)
+	if err != nil {
+		_ = b.blobstore.CleanUp(fileName)
+		return "", bosherr.WrapErrorf(err, "Verifying blob %s", blobID)
+	}
+
+	return fileName, nil
+}
+
+func (b digestVerifiableBlobstore) verify(fileName string, digest MultipleDigest) error {
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening blob file %s", fileName)
+	}
+
+	defer file.Close()
+
+	return digest.Verify(file)
+}
+
+func (b digestVerifiableBlobstore) CleanUp(fileName string) error {
+	return b.blobstore.CleanUp(fileName)
+}
+
+func (b digestVerifiableBlobstore) Create(fileName string) (string, string, error) {
+	return b.blobstore.Create(fileName)
+}
+
+func (b digestVerifiableBlobstore) Validate() error {
+	return b.blobstore.Validate()
+}
+
+func (b digestVerifiableBlobstore) Delete(blobID string) error {
+	return b.blobstore.Delete(blobID)
+}
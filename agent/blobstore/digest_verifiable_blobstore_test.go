@@ -0,0 +1,130 @@
+package blobstore_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	fakeagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore/fakes"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("digestVerifiableBlobstore", func() {
+	var (
+		innerBlobstore *fakeblob.FakeBlobstore
+		fs             *fakesys.FakeFileSystem
+		blobstore      boshblob.Blobstore
+	)
+
+	BeforeEach(func() {
+		innerBlobstore = fakeblob.NewFakeBlobstore()
+		fs = fakesys.NewFakeFileSystem()
+		blobstore = NewDigestVerifiableBlobstore(innerBlobstore, fs)
+
+		innerBlobstore.GetFileName = "/fake-blob-file"
+		fs.WriteFileString("/fake-blob-file", fakeBlobContents)
+	})
+
+	Describe("Get", func() {
+		It("returns blob when sha1 fingerprint matches", func() {
+			fileName, err := blobstore.Get("fake-blob-id", fakeBlobSHA1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-file"))
+
+			// Inner blobstore does not do SHA1 only verification
+			Expect(innerBlobstore.GetBlobIDs).To(Equal([]string{"fake-blob-id"}))
+			Expect(innerBlobstore.GetFingerprints).To(Equal([]string{""}))
+		})
+
+		It("returns blob when sha256 fingerprint matches", func() {
+			fileName, err := blobstore.Get("fake-blob-id", "sha256:"+fakeBlobSHA256)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-file"))
+		})
+
+		It("returns blob when multiple digest fingerprint matches", func() {
+			fileName, err := blobstore.Get("fake-blob-id", fakeBlobSHA1+";sha256:"+fakeBlobSHA256)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-file"))
+		})
+
+		It("returns error and cleans up blob when fingerprint does not match", func() {
+			_, err := blobstore.Get("fake-blob-id", fakeBlobSHA1+";sha256:fake-wrong-sha256")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Verifying blob fake-blob-id"))
+			Expect(err.Error()).To(ContainSubstring("SHA256 mismatch"))
+
+			Expect(innerBlobstore.CleanUpFileName).To(Equal("/fake-blob-file"))
+		})
+
+		It("returns error without fetching blob when fingerprint uses unknown algorithm", func() {
+			_, err := blobstore.Get("fake-blob-id", "md5:fake-md5")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown digest algorithm 'md5'"))
+
+			Expect(innerBlobstore.GetBlobIDs).To(BeEmpty())
+		})
+
+		It("does not verify blob when fingerprint is empty", func() {
+			fileName, err := blobstore.Get("fake-blob-id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-file"))
+		})
+
+		It("returns error when inner blobstore fails", func() {
+			innerBlobstore.GetError = errors.New("fake-get-err")
+
+			_, err := blobstore.Get("fake-blob-id", fakeBlobSHA1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-get-err"))
+		})
+
+		It("returns error when blob file cannot be opened", func() {
+			fs.OpenFileErr = errors.New("fake-open-err")
+
+			_, err := blobstore.Get("fake-blob-id", fakeBlobSHA1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+		})
+	})
+
+	Describe("Create", func() {
+		It("delegates to inner blobstore", func() {
+			innerBlobstore.CreateBlobID = "fake-blob-id"
+			innerBlobstore.CreateFingerprint = "fake-fingerprint"
+
+			blobID, fingerprint, err := blobstore.Create("/fake-file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(fingerprint).To(Equal("fake-fingerprint"))
+			Expect(innerBlobstore.CreateFileNames).To(Equal([]string{"/fake-file"}))
+		})
+	})
+
+	Describe("CreateFromReader", func() {
+		It("streams to inner blobstore when it supports streaming", func() {
+			streamingBlobstore := fakeagentblob.NewFakeStreamingBlobstore()
+			streamingBlobstore.CreateFromReaderBlobID = "fake-blob-id"
+
+			blobstore = NewDigestVerifiableBlobstore(streamingBlobstore, fs)
+
+			streaming, ok := blobstore.(StreamingBlobstore)
+			Expect(ok).To(BeTrue())
+
+			blobID, err := streaming.CreateFromReader(strings.NewReader("fake-contents"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(streamingBlobstore.CreateFromReaderSize).To(Equal(int64(len("fake-contents"))))
+		})
+
+		It("does not support streaming when inner blobstore does not", func() {
+			_, ok := blobstore.(StreamingBlobstore)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
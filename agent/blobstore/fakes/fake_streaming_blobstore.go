@@ -0,0 +1,53 @@
+package fakes
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+)
+
+// FakeStreamingBlobstore consumes given stream without buffering it
+type FakeStreamingBlobstore struct {
+	*fakeblob.FakeBlobstore
+
+	CreateFromReaderBlobID string
+	CreateFromReaderErr    error
+
+	// Stream contents are copied to CreateFromReaderWriter when it is set
+	CreateFromReaderWriter io.Writer
+
+	CreateFromReaderCallCount int
+	CreateFromReaderSize      int64
+	CreateFromReaderSHA1      string
+}
+
+func NewFakeStreamingBlobstore() *FakeStreamingBlobstore {
+	return &FakeStreamingBlobstore{FakeBlobstore: fakeblob.NewFakeBlobstore()}
+}
+
+func (bs *FakeStreamingBlobstore) CreateFromReader(reader io.Reader) (string, error) {
+	bs.CreateFromReaderCallCount++
+
+	if bs.CreateFromReaderErr != nil {
+		return "", bs.CreateFromReaderErr
+	}
+
+	digest := sha1.New()
+
+	writer := io.Writer(digest)
+	if bs.CreateFromReaderWriter != nil {
+		writer = io.MultiWriter(digest, bs.CreateFromReaderWriter)
+	}
+
+	size, err := io.Copy(writer, reader)
+	if err != nil {
+		return "", err
+	}
+
+	bs.CreateFromReaderSize = size
+	bs.CreateFromReaderSHA1 = fmt.Sprintf("%x", digest.Sum(nil))
+
+	return bs.CreateFromReaderBlobID, nil
+}
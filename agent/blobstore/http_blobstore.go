@@ -0,0 +1,514 @@
+package blobstore
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshhttptransport "github.com/cloudfoundry/bosh-agent/httptransport"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+const (
+	BlobstoreTypeHTTP = "http"
+
+	partialBlobsDirPermissions  = os.FileMode(0700)
+	partialBlobFilePermissions  = os.FileMode(0600)
+	httpBlobstoreTempFilePrefix = "bosh-blobstore-http-Get"
+
+	// Partial downloads not resumed for this long are removed
+	// since their blobs are most likely not requested anymore
+	abandonedPartialBlobAge = 24 * time.Hour
+)
+
+type httpBlobstore struct {
+	options         map[string]interface{}
+	partialBlobsDir string
+	httpClient      *http.Client
+	httpClientErr   error
+	fs              boshsys.FileSystem
+	uuidGen         boshuuid.Generator
+	timeService     clock.Clock
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewHTTPBlobstore talks to blobstore over HTTP without external client.
+// Interrupted downloads are kept in partialBlobsDir so that next attempt,
+// e.g. by retryable blobstore, resumes them with Range request.
+// Options are "endpoint" and optional "user", "password" and "ca_cert",
+// a PEM bundle trusted in addition to system CAs, e.g. when blobstore
+// is fronted by a load balancer with certificate issued by internal CA.
+func NewHTTPBlobstore(
+	options map[string]interface{},
+	partialBlobsDir string,
+	httpClient *http.Client,
+	fs boshsys.FileSystem,
+	uuidGen boshuuid.Generator,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) boshblob.Blobstore {
+	b := httpBlobstore{
+		options:         options,
+		partialBlobsDir: partialBlobsDir,
+		httpClient:      httpClient,
+		fs:              fs,
+		uuidGen:         uuidGen,
+		timeService:     timeService,
+		logTag:          "httpBlobstore",
+		logger:          logger,
+	}
+
+	// Invalid CA and unsupported HTTP transport are reported by Validate
+	if rootCAs, err := b.rootCAs(); err == nil && rootCAs != nil {
+		client, err := httpClientWithRootCAs(httpClient, rootCAs)
+		if err != nil {
+			b.httpClientErr = err
+		} else {
+			b.httpClient = client
+		}
+	}
+
+	return b
+}
+
+// BlobNotFoundError is returned when server does not have requested blob
+type BlobNotFoundError struct {
+	BlobID string
+}
+
+func (e BlobNotFoundError) Error() string {
+	return fmt.Sprintf("Blob %s not found", e.BlobID)
+}
+
+// InvalidBlobIDError is returned for blob ids that cannot be used
+// as partial download file name, e.g. "../fake-blob-id"
+type InvalidBlobIDError struct {
+	BlobID string
+}
+
+func (e InvalidBlobIDError) Error() string {
+	return fmt.Sprintf("Invalid blob id '%s'", e.BlobID)
+}
+
+// UnexpectedStatusError is returned when server responds to download with unexpected status
+type UnexpectedStatusError struct {
+	StatusCode int
+}
+
+func (e UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("Unexpected response status %d", e.StatusCode)
+}
+
+// Permanent is true for client errors that repeating the request would not fix
+func (e UnexpectedStatusError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+func (b httpBlobstore) Get(blobID, fingerprint string) (string, error) {
+	if blobID == "" || blobID == "." || blobID == ".." || strings.ContainsAny(blobID, "/\\") {
+		return "", InvalidBlobIDError{BlobID: blobID}
+	}
+
+	err := b.fs.MkdirAll(b.partialBlobsDir, partialBlobsDirPermissions)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating partial blobs directory")
+	}
+
+	b.removeAbandonedPartialBlobs()
+
+	partialPath := path.Join(b.partialBlobsDir, blobID)
+
+	err = b.download(blobID, partialPath, true)
+	if err != nil {
+		// Partial download is kept only when next attempt may resume it
+		if isPermanentGetError(err) {
+			_ = b.fs.RemoveAll(partialPath)
+		}
+
+		return "", bosherr.WrapErrorf(err, "Downloading blob %s", blobID)
+	}
+
+	// Resumed blob is verified in full since it was written in several attempts
+	if fingerprint != "" {
+		err = b.verify(partialPath, fingerprint)
+		if err != nil {
+			_ = b.fs.RemoveAll(partialPath)
+			return "", bosherr.WrapErrorf(err, "Verifying blob %s", blobID)
+		}
+	}
+
+	file, err := b.fs.TempFile(httpBlobstoreTempFilePrefix)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary file")
+	}
+
+	fileName := file.Name()
+	_ = file.Close()
+
+	err = b.fs.Rename(partialPath, fileName)
+	if err != nil {
+		_ = b.fs.RemoveAll(fileName)
+		return "", bosherr.WrapErrorf(err, "Moving downloaded blob %s", blobID)
+	}
+
+	return fileName, nil
+}
+
+// download keeps whatever was written to partialPath when copying fails
+func (b httpBlobstore) download(blobID, partialPath string, allowResume bool) error {
+	offset, err := b.partialSize(partialPath)
+	if err != nil {
+		return err
+	}
+
+	if !allowResume {
+		offset = 0
+	}
+
+	req, err := http.NewRequest("GET", b.blobURL(blobID), nil)
+	if err != nil {
+		return bosherr.WrapError(err, "Building request")
+	}
+
+	b.authorize(req)
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending request")
+	}
+
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		// Appending other range than requested would corrupt partial file
+		if !contentRangeStartsAt(resp.Header.Get("Content-Range"), offset) {
+			b.logger.Info(b.logTag, "Server returned unexpected range '%s' of blob %s, downloading it again", resp.Header.Get("Content-Range"), blobID)
+			return b.download(blobID, partialPath, false)
+		}
+
+		b.logger.Info(b.logTag, "Resuming download of blob %s from byte %d", blobID, offset)
+		flags |= os.O_APPEND
+
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Partial file does not belong to current blob contents
+		b.logger.Info(b.logTag, "Cannot resume download of blob %s from byte %d, downloading it again", blobID, offset)
+		return b.download(blobID, partialPath, false)
+
+	case resp.StatusCode == http.StatusNotFound:
+		return BlobNotFoundError{BlobID: blobID}
+
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			b.logger.Info(b.logTag, "Server does not support resuming download of blob %s, downloading it again", blobID)
+		}
+		flags |= os.O_TRUNC
+
+	default:
+		return UnexpectedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	file, err := b.fs.OpenFile(partialPath, flags, partialBlobFilePermissions)
+	if err != nil {
+		return bosherr.WrapError(err, "Opening partial blob file")
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing blob contents")
+	}
+
+	return nil
+}
+
+// contentRangeStartsAt checks header such as "bytes 10-34/35"
+func contentRangeStartsAt(contentRange string, offset int64) bool {
+	return strings.HasPrefix(contentRange, fmt.Sprintf("bytes %d-", offset))
+}
+
+// removeAbandonedPartialBlobs only logs failures since
+// they do not prevent downloading requested blob
+func (b httpBlobstore) removeAbandonedPartialBlobs() {
+	partialPaths, err := b.fs.Glob(path.Join(b.partialBlobsDir, "*"))
+	if err != nil {
+		b.logger.Warn(b.logTag, "Listing partial blobs: %s", err.Error())
+		return
+	}
+
+	for _, partialPath := range partialPaths {
+		modTime, err := b.partialModTime(partialPath)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Checking partial blob %s: %s", partialPath, err.Error())
+			continue
+		}
+
+		if b.timeService.Now().Sub(modTime) < abandonedPartialBlobAge {
+			continue
+		}
+
+		b.logger.Info(b.logTag, "Removing abandoned partial blob %s", partialPath)
+
+		err = b.fs.RemoveAll(partialPath)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Removing abandoned partial blob %s: %s", partialPath, err.Error())
+		}
+	}
+}
+
+func (b httpBlobstore) partialModTime(partialPath string) (time.Time, error) {
+	file, err := b.fs.OpenFile(partialPath, os.O_RDONLY, 0)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return stat.ModTime(), nil
+}
+
+func (b httpBlobstore) partialSize(partialPath string) (int64, error) {
+	if !b.fs.FileExists(partialPath) {
+		return 0, nil
+	}
+
+	file, err := b.fs.OpenFile(partialPath, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Opening partial blob file")
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Checking size of partial blob file")
+	}
+
+	return stat.Size(), nil
+}
+
+func (b httpBlobstore) verify(fileName, fingerprint string) error {
+	digest, err := ParseMultipleDigest(fingerprint)
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing fingerprint")
+	}
+
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening blob file %s", fileName)
+	}
+
+	defer file.Close()
+
+	return digest.Verify(file)
+}
+
+func (b httpBlobstore) CleanUp(fileName string) error {
+	return b.fs.RemoveAll(fileName)
+}
+
+func (b httpBlobstore) Create(fileName string) (string, string, error) {
+	blobID, err := b.uuidGen.Generate()
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Generating blob id")
+	}
+
+	fingerprint, err := b.sha1(fileName)
+	if err != nil {
+		return "", "", err
+	}
+
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Opening file %s", fileName)
+	}
+
+	defer file.Close()
+
+	err = b.upload(blobID, file)
+	if err != nil {
+		return "", "", err
+	}
+
+	return blobID, fingerprint, nil
+}
+
+// CreateFromReader uploads stream with chunked transfer encoding
+// so that it does not need to be staged on disk
+func (b httpBlobstore) CreateFromReader(reader io.Reader) (string, error) {
+	blobID, err := b.uuidGen.Generate()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Generating blob id")
+	}
+
+	err = b.upload(blobID, reader)
+	if err != nil {
+		return "", err
+	}
+
+	return blobID, nil
+}
+
+func (b httpBlobstore) upload(blobID string, body io.Reader) error {
+	req, err := http.NewRequest("PUT", b.blobURL(blobID), body)
+	if err != nil {
+		return bosherr.WrapError(err, "Building request")
+	}
+
+	b.authorize(req)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Uploading blob %s", blobID)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return bosherr.Errorf("Uploading blob %s: Unexpected response status %d", blobID, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (b httpBlobstore) sha1(fileName string) (string, error) {
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening file %s", fileName)
+	}
+
+	defer file.Close()
+
+	hash := sha1.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Calculating sha1 of %s", fileName)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func (b httpBlobstore) Validate() error {
+	if b.endpoint() == "" {
+		return bosherr.Error("Blobstore endpoint must be specified")
+	}
+
+	_, err := b.rootCAs()
+	if err != nil {
+		return err
+	}
+
+	return b.httpClientErr
+}
+
+func (b httpBlobstore) Delete(blobID string) error {
+	req, err := http.NewRequest("DELETE", b.blobURL(blobID), nil)
+	if err != nil {
+		return bosherr.WrapError(err, "Building request")
+	}
+
+	b.authorize(req)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting blob %s", blobID)
+	}
+
+	_ = resp.Body.Close()
+
+	// Deleting missing blob is not an error
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return bosherr.Errorf("Deleting blob %s: Unexpected response status %d", blobID, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// rootCAs returns nil when no CA is configured so that
+// only system CAs are trusted
+func (b httpBlobstore) rootCAs() (*x509.CertPool, error) {
+	caCert := b.stringOption("ca_cert")
+	if caCert == "" {
+		return nil, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	if !rootCAs.AppendCertsFromPEM([]byte(caCert)) {
+		return nil, bosherr.Error("Parsing blobstore CA certificate")
+	}
+
+	return rootCAs, nil
+}
+
+// httpClientWithRootCAs keeps proxy and other settings of httpClient
+func httpClientWithRootCAs(httpClient *http.Client, rootCAs *x509.CertPool) (*http.Client, error) {
+	transport, err := boshhttptransport.Transport(httpClient.Transport)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Trusting blobstore CA certificate")
+	}
+
+	client := *httpClient
+	client.Transport = boshhttptransport.WithTLSConfig(transport, func(config *tls.Config) {
+		config.RootCAs = rootCAs
+	})
+
+	return &client, nil
+}
+
+func (b httpBlobstore) blobURL(blobID string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(b.endpoint(), "/"), url.PathEscape(blobID))
+}
+
+func (b httpBlobstore) authorize(req *http.Request) {
+	user := b.stringOption("user")
+	if user != "" {
+		req.SetBasicAuth(user, b.stringOption("password"))
+	}
+}
+
+func (b httpBlobstore) endpoint() string {
+	return b.stringOption("endpoint")
+}
+
+func (b httpBlobstore) stringOption(name string) string {
+	value, _ := b.options[name].(string)
+	return value
+}
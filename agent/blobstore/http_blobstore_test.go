@@ -0,0 +1,480 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+var _ = Describe("httpBlobstore", func() {
+	const blobContents = "fake-large-blob-contents-0123456789"
+
+	var (
+		blobSHA1        string
+		partialBlobsDir string
+		server          *httptest.Server
+		requestsLock    sync.Mutex
+		rangeHeaders    []string
+		requestPaths    []string
+		timeService     *fakeclock.FakeClock
+		blobstore       boshblob.Blobstore
+	)
+
+	recordRange := func(req *http.Request) {
+		requestsLock.Lock()
+		defer requestsLock.Unlock()
+		rangeHeaders = append(rangeHeaders, req.Header.Get("Range"))
+		requestPaths = append(requestPaths, req.URL.EscapedPath())
+	}
+
+	newBlobstore := func(handler http.HandlerFunc) {
+		server = httptest.NewServer(handler)
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		blobstore = NewHTTPBlobstore(
+			map[string]interface{}{"endpoint": server.URL + "/blobs"},
+			partialBlobsDir,
+			http.DefaultClient,
+			boshsys.NewOsFileSystem(logger),
+			&fakeuuid.FakeGenerator{GeneratedUUID: "fake-blob-id"},
+			timeService,
+			logger,
+		)
+	}
+
+	writePartialBlob := func(contents string) {
+		Expect(os.MkdirAll(partialBlobsDir, os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(partialBlobsDir, "fake-blob-id"), []byte(contents), 0600)).To(Succeed())
+	}
+
+	readFile := func(fileName string) string {
+		contents, err := ioutil.ReadFile(fileName)
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	BeforeEach(func() {
+		blobSHA1 = fmt.Sprintf("%x", sha1.Sum([]byte(blobContents)))
+		rangeHeaders = nil
+		requestPaths = nil
+		timeService = fakeclock.NewFakeClock(time.Now())
+
+		var err error
+		partialBlobsDir, err = ioutil.TempDir("", "http-blobstore-test")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		_ = os.RemoveAll(partialBlobsDir)
+	})
+
+	Describe("Get", func() {
+		Context("when server supports Range requests", func() {
+			BeforeEach(func() {
+				newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+					recordRange(req)
+					http.ServeContent(w, req, "blob", time.Time{}, strings.NewReader(blobContents))
+				})
+			})
+
+			It("downloads whole blob when there is no partial download", func() {
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{""}))
+			})
+
+			It("resumes interrupted download from where it stopped", func() {
+				writePartialBlob(blobContents[:10])
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{"bytes=10-"}))
+			})
+
+			It("removes partial download once blob is fetched", func() {
+				writePartialBlob(blobContents[:10])
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				_, err = os.Stat(filepath.Join(partialBlobsDir, "fake-blob-id"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+
+			It("downloads blob again when partial download cannot be resumed", func() {
+				writePartialBlob(blobContents + "-fake-extra-contents")
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{fmt.Sprintf("bytes=%d-", len(blobContents)+20), ""}))
+			})
+
+			It("escapes blob id in request path", func() {
+				fileName, err := blobstore.Get("fake blob?id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(requestPaths).To(Equal([]string{"/blobs/fake%20blob%3Fid"}))
+			})
+
+			It("returns error without downloading blob when blob id would point outside of partial blobs dir", func() {
+				for _, blobID := range []string{"../fake-blob-id", "fake/blob-id", "..", ""} {
+					_, err := blobstore.Get(blobID, blobSHA1)
+					Expect(err).To(Equal(InvalidBlobIDError{BlobID: blobID}))
+				}
+
+				Expect(requestPaths).To(BeEmpty())
+			})
+
+			It("removes partial downloads abandoned long time ago", func() {
+				writePartialBlob(blobContents[:10])
+
+				abandonedPath := filepath.Join(partialBlobsDir, "fake-abandoned-blob-id")
+				Expect(ioutil.WriteFile(abandonedPath, []byte("fake-contents"), 0600)).To(Succeed())
+
+				abandonedTime := timeService.Now().Add(-25 * time.Hour)
+				Expect(os.Chtimes(abandonedPath, abandonedTime, abandonedTime)).To(Succeed())
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				_, err = os.Stat(abandonedPath)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+
+				Expect(rangeHeaders).To(Equal([]string{"bytes=10-"}))
+			})
+
+			It("returns error and removes partial download when resumed blob does not match sha1", func() {
+				writePartialBlob("fake-corrupted")
+
+				_, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Verifying blob fake-blob-id"))
+
+				_, err = os.Stat(filepath.Join(partialBlobsDir, "fake-blob-id"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when server does not support Range requests", func() {
+			BeforeEach(func() {
+				newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+					recordRange(req)
+					_, _ = w.Write([]byte(blobContents))
+				})
+			})
+
+			It("downloads whole blob again instead of appending to partial download", func() {
+				writePartialBlob(blobContents[:10])
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{"bytes=10-"}))
+			})
+		})
+
+		Context("when download is interrupted", func() {
+			BeforeEach(func() {
+				attempts := 0
+
+				newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+					recordRange(req)
+					attempts++
+
+					if attempts > 1 {
+						http.ServeContent(w, req, "blob", time.Time{}, strings.NewReader(blobContents))
+						return
+					}
+
+					// Promise whole blob but only send part of it
+					w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blobContents)))
+					_, _ = w.Write([]byte(blobContents[:15]))
+					w.(http.Flusher).Flush()
+
+					conn, _, err := w.(http.Hijacker).Hijack()
+					Expect(err).ToNot(HaveOccurred())
+					_ = conn.Close()
+				})
+			})
+
+			It("keeps partial download so that next attempt resumes it", func() {
+				_, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Downloading blob fake-blob-id"))
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{"", "bytes=15-"}))
+			})
+		})
+
+		Context("when server returns other range than requested", func() {
+			BeforeEach(func() {
+				newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+					recordRange(req)
+
+					if req.Header.Get("Range") != "" {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(blobContents)-1, len(blobContents)))
+						w.WriteHeader(http.StatusPartialContent)
+					}
+
+					_, _ = w.Write([]byte(blobContents))
+				})
+			})
+
+			It("downloads whole blob again instead of appending returned range", func() {
+				writePartialBlob(blobContents[:10])
+
+				fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+				Expect(err).ToNot(HaveOccurred())
+				defer blobstore.CleanUp(fileName)
+
+				Expect(readFile(fileName)).To(Equal(blobContents))
+				Expect(rangeHeaders).To(Equal([]string{"bytes=10-", ""}))
+			})
+		})
+
+		It("returns not found error and removes partial download when server does not have blob", func() {
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			writePartialBlob(blobContents[:10])
+
+			_, err := blobstore.Get("fake-blob-id", blobSHA1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Blob fake-blob-id not found"))
+
+			_, err = os.Stat(filepath.Join(partialBlobsDir, "fake-blob-id"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("returns error when server responds with unexpected status", func() {
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+
+			_, err := blobstore.Get("fake-blob-id", blobSHA1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unexpected response status 500"))
+		})
+	})
+
+	Describe("Create", func() {
+		It("uploads file and returns its sha1", func() {
+			var uploaded bytes.Buffer
+
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.URL.Path).To(Equal("/blobs/fake-blob-id"))
+				_, _ = uploaded.ReadFrom(req.Body)
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			fileName := filepath.Join(partialBlobsDir, "fake-upload")
+			Expect(ioutil.WriteFile(fileName, []byte(blobContents), 0600)).To(Succeed())
+
+			blobID, fingerprint, err := blobstore.Create(fileName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(fingerprint).To(Equal(blobSHA1))
+			Expect(uploaded.String()).To(Equal(blobContents))
+		})
+	})
+
+	Describe("CreateFromReader", func() {
+		It("uploads stream and returns blob id", func() {
+			var uploaded bytes.Buffer
+
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.URL.Path).To(Equal("/blobs/fake-blob-id"))
+				_, _ = uploaded.ReadFrom(req.Body)
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			blobID, err := blobstore.(StreamingBlobstore).CreateFromReader(strings.NewReader(blobContents))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(uploaded.String()).To(Equal(blobContents))
+		})
+
+		It("returns error when server responds with unexpected status", func() {
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			})
+
+			_, err := blobstore.(StreamingBlobstore).CreateFromReader(strings.NewReader(blobContents))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unexpected response status 403"))
+		})
+	})
+
+	Context("when blobstore certificate is issued by custom CA", func() {
+		var options map[string]interface{}
+
+		newTLSBlobstore := func(options map[string]interface{}) boshblob.Blobstore {
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			return NewHTTPBlobstore(
+				options,
+				partialBlobsDir,
+				http.DefaultClient,
+				boshsys.NewOsFileSystem(logger),
+				&fakeuuid.FakeGenerator{GeneratedUUID: "fake-blob-id"},
+				timeService,
+				logger,
+			)
+		}
+
+		BeforeEach(func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.Method {
+				case "GET":
+					http.ServeContent(w, req, "blob", time.Time{}, strings.NewReader(blobContents))
+				case "PUT":
+					w.WriteHeader(http.StatusCreated)
+				}
+			}))
+
+			caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+			options = map[string]interface{}{
+				"endpoint": server.URL + "/blobs",
+				"ca_cert":  string(caCert),
+			}
+		})
+
+		It("downloads blob when CA is configured", func() {
+			blobstore = newTLSBlobstore(options)
+			Expect(blobstore.Validate()).To(Succeed())
+
+			fileName, err := blobstore.Get("fake-blob-id", blobSHA1)
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(fileName)
+
+			Expect(readFile(fileName)).To(Equal(blobContents))
+		})
+
+		It("uploads blob when CA is configured", func() {
+			blobstore = newTLSBlobstore(options)
+
+			fileName := filepath.Join(partialBlobsDir, "fake-upload")
+			Expect(ioutil.WriteFile(fileName, []byte(blobContents), 0600)).To(Succeed())
+
+			blobID, fingerprint, err := blobstore.Create(fileName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(fingerprint).To(Equal(blobSHA1))
+		})
+
+		It("does not change transport of given HTTP client", func() {
+			blobstore = newTLSBlobstore(options)
+
+			_, err := http.DefaultClient.Get(server.URL + "/blobs/fake-blob-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("certificate"))
+		})
+
+		It("fails to download blob when CA is not configured", func() {
+			delete(options, "ca_cert")
+			blobstore = newTLSBlobstore(options)
+
+			_, err := blobstore.Get("fake-blob-id", blobSHA1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("certificate"))
+		})
+
+		It("fails to upload blob when CA is not configured", func() {
+			delete(options, "ca_cert")
+			blobstore = newTLSBlobstore(options)
+
+			fileName := filepath.Join(partialBlobsDir, "fake-upload")
+			Expect(ioutil.WriteFile(fileName, []byte(blobContents), 0600)).To(Succeed())
+
+			_, _, err := blobstore.Create(fileName)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("certificate"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("returns error when endpoint is not specified", func() {
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {})
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			blobstore = NewHTTPBlobstore(map[string]interface{}{}, partialBlobsDir, http.DefaultClient, boshsys.NewOsFileSystem(logger), &fakeuuid.FakeGenerator{}, timeService, logger)
+
+			err := blobstore.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Blobstore endpoint must be specified"))
+		})
+
+		It("returns error when CA certificate cannot be parsed", func() {
+			newBlobstore(func(w http.ResponseWriter, req *http.Request) {})
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			options := map[string]interface{}{"endpoint": server.URL, "ca_cert": "fake-ca-cert"}
+			blobstore = NewHTTPBlobstore(options, partialBlobsDir, http.DefaultClient, boshsys.NewOsFileSystem(logger), &fakeuuid.FakeGenerator{}, timeService, logger)
+
+			err := blobstore.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Parsing blobstore CA certificate"))
+		})
+
+		It("returns error when CA certificate cannot be trusted by HTTP client transport", func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+			caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			options := map[string]interface{}{"endpoint": server.URL, "ca_cert": string(caCert)}
+			httpClient := &http.Client{Transport: fakeRoundTripper{}}
+			blobstore = NewHTTPBlobstore(options, partialBlobsDir, httpClient, boshsys.NewOsFileSystem(logger), &fakeuuid.FakeGenerator{}, timeService, logger)
+
+			err := blobstore.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Configuring TLS of HTTP transport blobstore_test.fakeRoundTripper is not supported"))
+		})
+	})
+})
+
+type fakeRoundTripper struct{}
+
+func (fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
@@ -0,0 +1,155 @@
+package blobstore
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	DigestAlgorithmSHA1   = "sha1"
+	DigestAlgorithmSHA256 = "sha256"
+	DigestAlgorithmSHA512 = "sha512"
+)
+
+// Ordered from the weakest to the strongest
+var digestAlgorithms = []string{
+	DigestAlgorithmSHA1,
+	DigestAlgorithmSHA256,
+	DigestAlgorithmSHA512,
+}
+
+type Digest struct {
+	Algorithm string
+	Value     string
+}
+
+// DigestMismatchError is returned when contents do not match the expected digest
+type DigestMismatchError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e DigestMismatchError) Error() string {
+	return fmt.Sprintf("%s mismatch. Expected %s, got %s", strings.ToUpper(e.Algorithm), e.Expected, e.Actual)
+}
+
+// InvalidDigestError is returned when digests string cannot be parsed
+type InvalidDigestError struct {
+	Digests string
+	Reason  string
+}
+
+func (e InvalidDigestError) Error() string {
+	return fmt.Sprintf("%s in '%s'", e.Reason, e.Digests)
+}
+
+// MultipleDigest is parsed from strings such as "<sha1>;sha256:<sha256>".
+// Digests without algorithm prefix are legacy SHA1 digests.
+type MultipleDigest struct {
+	digests []Digest
+}
+
+func ParseMultipleDigest(digestsStr string) (MultipleDigest, error) {
+	var digests []Digest
+
+	for _, digestStr := range strings.Split(digestsStr, ";") {
+		digestStr = strings.TrimSpace(digestStr)
+		if digestStr == "" {
+			continue
+		}
+
+		digest := Digest{Algorithm: DigestAlgorithmSHA1, Value: digestStr}
+
+		if pieces := strings.SplitN(digestStr, ":", 2); len(pieces) == 2 {
+			digest = Digest{Algorithm: strings.ToLower(pieces[0]), Value: pieces[1]}
+		}
+
+		if !isKnownDigestAlgorithm(digest.Algorithm) {
+			return MultipleDigest{}, InvalidDigestError{Digests: digestsStr, Reason: fmt.Sprintf("Unknown digest algorithm '%s'", digest.Algorithm)}
+		}
+
+		if digest.Value == "" {
+			return MultipleDigest{}, InvalidDigestError{Digests: digestsStr, Reason: fmt.Sprintf("Empty %s digest", digest.Algorithm)}
+		}
+
+		digests = append(digests, digest)
+	}
+
+	if len(digests) == 0 {
+		return MultipleDigest{}, InvalidDigestError{Digests: digestsStr, Reason: "No digests found"}
+	}
+
+	return MultipleDigest{digests: digests}, nil
+}
+
+// Strongest returns digest calculated with the strongest known algorithm
+func (d MultipleDigest) Strongest() Digest {
+	strongest := d.digests[0]
+
+	for _, digest := range d.digests[1:] {
+		if digestAlgorithmStrength(digest.Algorithm) > digestAlgorithmStrength(strongest.Algorithm) {
+			strongest = digest
+		}
+	}
+
+	return strongest
+}
+
+// Verify checks contents of the reader against the strongest digest
+func (d MultipleDigest) Verify(reader io.Reader) error {
+	expected := d.Strongest()
+
+	actualValue, err := calculateDigest(expected.Algorithm, reader)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actualValue, expected.Value) {
+		return DigestMismatchError{Algorithm: expected.Algorithm, Expected: expected.Value, Actual: actualValue}
+	}
+
+	return nil
+}
+
+func calculateDigest(algorithm string, reader io.Reader) (string, error) {
+	var h hash.Hash
+
+	switch algorithm {
+	case DigestAlgorithmSHA1:
+		h = sha1.New()
+	case DigestAlgorithmSHA256:
+		h = sha256.New()
+	case DigestAlgorithmSHA512:
+		h = sha512.New()
+	default:
+		return "", bosherr.Errorf("Unknown digest algorithm '%s'", algorithm)
+	}
+
+	_, err := io.Copy(h, reader)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Calculating %s digest", algorithm)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func isKnownDigestAlgorithm(algorithm string) bool {
+	return digestAlgorithmStrength(algorithm) >= 0
+}
+
+func digestAlgorithmStrength(algorithm string) int {
+	for i, knownAlgorithm := range digestAlgorithms {
+		if knownAlgorithm == algorithm {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,89 @@
+package blobstore_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+)
+
+const (
+	fakeBlobContents = "fake-blob-contents"
+	fakeBlobSHA1     = "211e1818de9398c908b1c93ea98d53e812ecf143"
+	fakeBlobSHA256   = "6f6cae9f73f9d008d2e733803eb7338892e110b84afd9e7eeea98ae6a3e04c78"
+)
+
+var _ = Describe("MultipleDigest", func() {
+	Describe("ParseMultipleDigest", func() {
+		It("parses legacy sha1 digest", func() {
+			digest, err := ParseMultipleDigest(fakeBlobSHA1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.Strongest()).To(Equal(Digest{Algorithm: "sha1", Value: fakeBlobSHA1}))
+		})
+
+		It("parses prefixed digests", func() {
+			digest, err := ParseMultipleDigest("sha256:" + fakeBlobSHA256)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.Strongest()).To(Equal(Digest{Algorithm: "sha256", Value: fakeBlobSHA256}))
+		})
+
+		It("picks the strongest digest regardless of order", func() {
+			digest, err := ParseMultipleDigest("sha256:fake-sha256;sha512:fake-sha512;fake-sha1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.Strongest()).To(Equal(Digest{Algorithm: "sha512", Value: "fake-sha512"}))
+		})
+
+		It("returns error for unknown algorithms", func() {
+			_, err := ParseMultipleDigest(fakeBlobSHA1 + ";md5:fake-md5")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown digest algorithm 'md5'"))
+		})
+
+		It("returns error for digests without value", func() {
+			_, err := ParseMultipleDigest("sha256:")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Empty sha256 digest"))
+		})
+
+		It("returns error when no digests are given", func() {
+			_, err := ParseMultipleDigest(" ; ")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("No digests found"))
+		})
+	})
+
+	Describe("Verify", func() {
+		It("verifies legacy sha1 digest", func() {
+			digest, err := ParseMultipleDigest(fakeBlobSHA1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(digest.Verify(strings.NewReader(fakeBlobContents))).To(Succeed())
+		})
+
+		It("verifies sha256 digest", func() {
+			digest, err := ParseMultipleDigest("sha256:" + fakeBlobSHA256)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(digest.Verify(strings.NewReader(fakeBlobContents))).To(Succeed())
+		})
+
+		It("verifies multiple digests using the strongest one", func() {
+			// sha1 is wrong on purpose to show that it is not used
+			digest, err := ParseMultipleDigest("fake-wrong-sha1;sha256:" + fakeBlobSHA256)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(digest.Verify(strings.NewReader(fakeBlobContents))).To(Succeed())
+		})
+
+		It("returns error when the strongest digest does not match", func() {
+			digest, err := ParseMultipleDigest(fakeBlobSHA1 + ";sha256:fake-wrong-sha256")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = digest.Verify(strings.NewReader(fakeBlobContents))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("SHA256 mismatch. Expected fake-wrong-sha256, got " + fakeBlobSHA256))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package blobstore
+
+import (
+	"crypto/x509"
+	"fmt"
+	"path"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+// Provider builds the same blobstores as bosh-utils blobstore provider
+// without wrapping them in its retryable blobstore, since agent
+// retries blob downloads with backoff around all blobstore types
+// DAV client trusts CA configured in its "tls.cert.ca" config
+const blobstoreTypeDav = "dav"
+
+type Provider struct {
+	fs        boshsys.FileSystem
+	runner    boshsys.CmdRunner
+	configDir string
+	uuidGen   boshuuid.Generator
+}
+
+func NewProvider(
+	fs boshsys.FileSystem,
+	runner boshsys.CmdRunner,
+	configDir string,
+	uuidGen boshuuid.Generator,
+) Provider {
+	return Provider{
+		fs:        fs,
+		runner:    runner,
+		configDir: configDir,
+		uuidGen:   uuidGen,
+	}
+}
+
+func (p Provider) Get(storeType string, options map[string]interface{}) (boshblob.Blobstore, error) {
+	var blobstore boshblob.Blobstore
+
+	switch storeType {
+	case boshblob.BlobstoreTypeDummy:
+		blobstore = dummyBlobstore{}
+
+	case boshblob.BlobstoreTypeLocal:
+		blobstore = boshblob.NewLocalBlobstore(p.fs, p.uuidGen, options)
+
+	default:
+		externalOptions, err := externalBlobstoreOptions(storeType, options)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Validating blobstore")
+		}
+
+		externalConfigFile := path.Join(p.configDir, fmt.Sprintf("blobstore-%s.json", storeType))
+		blobstore = boshblob.NewExternalBlobstore(storeType, externalOptions, p.fs, p.runner, p.uuidGen, externalConfigFile)
+	}
+
+	// Compiler relies on SHA1 of created blobs which external blobstores do not return
+	blobstore = boshblob.NewSHA1VerifiableBlobstore(blobstore)
+
+	err := blobstore.Validate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating blobstore")
+	}
+
+	return blobstore, nil
+}
+
+// externalBlobstoreOptions passes "ca_cert" option to DAV client as its
+// "tls.cert.ca" config unless one is already set there; other clients
+// receive "ca_cert" as is
+func externalBlobstoreOptions(storeType string, options map[string]interface{}) (map[string]interface{}, error) {
+	caCert, _ := options["ca_cert"].(string)
+	if caCert == "" {
+		return options, nil
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(caCert)) {
+		return nil, bosherr.Error("Parsing blobstore CA certificate")
+	}
+
+	if storeType != blobstoreTypeDav {
+		return options, nil
+	}
+
+	tlsOptions := copyOptions(options["tls"])
+	certOptions := copyOptions(tlsOptions["cert"])
+
+	if _, found := certOptions["ca"]; !found {
+		certOptions["ca"] = caCert
+	}
+
+	tlsOptions["cert"] = certOptions
+
+	externalOptions := copyOptions(options)
+	externalOptions["tls"] = tlsOptions
+
+	return externalOptions, nil
+}
+
+// copyOptions returns empty options when value is not a JSON object
+// so that settings are never modified
+func copyOptions(value interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+
+	options, _ := value.(map[string]interface{})
+	for k, v := range options {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+type dummyBlobstore struct{}
+
+func (b dummyBlobstore) Get(blobID, fingerprint string) (string, error) {
+	return "", nil
+}
+
+func (b dummyBlobstore) CleanUp(fileName string) error {
+	return nil
+}
+
+func (b dummyBlobstore) Create(fileName string) (string, string, error) {
+	return "", "", nil
+}
+
+func (b dummyBlobstore) Validate() error {
+	return nil
+}
+
+func (b dummyBlobstore) Delete(blobID string) error {
+	return nil
+}
@@ -0,0 +1,145 @@
+package blobstore_test
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+)
+
+var _ = Describe("Provider", func() {
+	var (
+		fs       *fakesys.FakeFileSystem
+		runner   *fakesys.FakeCmdRunner
+		uuidGen  *fakeuuid.FakeGenerator
+		provider Provider
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		runner = fakesys.NewFakeCmdRunner()
+		uuidGen = &fakeuuid.FakeGenerator{}
+		provider = NewProvider(fs, runner, "/var/vcap/config", uuidGen)
+	})
+
+	Describe("Get", func() {
+		It("returns dummy blobstore", func() {
+			blobstore, err := provider.Get(boshblob.BlobstoreTypeDummy, map[string]interface{}{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobstore).ToNot(BeNil())
+		})
+
+		It("returns local blobstore verifying SHA1 without retrying it", func() {
+			options := map[string]interface{}{"blobstore_path": "/fake-blobstore-path"}
+
+			blobstore, err := provider.Get(boshblob.BlobstoreTypeLocal, options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobstore).To(Equal(boshblob.NewSHA1VerifiableBlobstore(
+				boshblob.NewLocalBlobstore(fs, uuidGen, options),
+			)))
+		})
+
+		It("returns external blobstore verifying SHA1 without retrying it", func() {
+			options := map[string]interface{}{"key": "value"}
+			runner.CommandExistsValue = true
+
+			blobstore, err := provider.Get("fake-external-type", options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobstore).To(Equal(boshblob.NewSHA1VerifiableBlobstore(
+				boshblob.NewExternalBlobstore(
+					"fake-external-type",
+					options,
+					fs,
+					runner,
+					uuidGen,
+					"/var/vcap/config/blobstore-fake-external-type.json",
+				),
+			)))
+		})
+
+		Context("when CA certificate is configured", func() {
+			var caCert string
+
+			externalBlobstore := func(storeType string, options map[string]interface{}) boshblob.Blobstore {
+				return boshblob.NewSHA1VerifiableBlobstore(
+					boshblob.NewExternalBlobstore(
+						storeType,
+						options,
+						fs,
+						runner,
+						uuidGen,
+						"/var/vcap/config/blobstore-"+storeType+".json",
+					),
+				)
+			}
+
+			BeforeEach(func() {
+				server := httptest.NewTLSServer(nil)
+				caCert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+				server.Close()
+
+				runner.CommandExistsValue = true
+			})
+
+			It("passes CA to DAV client config without changing settings options", func() {
+				options := map[string]interface{}{
+					"endpoint": "https://fake-dav",
+					"ca_cert":  caCert,
+					"tls":      map[string]interface{}{"insecure_skip_verify": false},
+				}
+
+				blobstore, err := provider.Get("dav", options)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore).To(Equal(externalBlobstore("dav", map[string]interface{}{
+					"endpoint": "https://fake-dav",
+					"ca_cert":  caCert,
+					"tls": map[string]interface{}{
+						"insecure_skip_verify": false,
+						"cert":                 map[string]interface{}{"ca": caCert},
+					},
+				})))
+
+				Expect(options["tls"]).To(Equal(map[string]interface{}{"insecure_skip_verify": false}))
+			})
+
+			It("keeps CA already configured for DAV client", func() {
+				options := map[string]interface{}{
+					"ca_cert": caCert,
+					"tls":     map[string]interface{}{"cert": map[string]interface{}{"ca": "fake-dav-ca"}},
+				}
+
+				blobstore, err := provider.Get("dav", options)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore).To(Equal(externalBlobstore("dav", options)))
+			})
+
+			It("passes CA to other external clients as is", func() {
+				options := map[string]interface{}{"ca_cert": caCert}
+
+				blobstore, err := provider.Get("s3", options)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore).To(Equal(externalBlobstore("s3", options)))
+			})
+
+			It("returns error when CA certificate is invalid", func() {
+				_, err := provider.Get("dav", map[string]interface{}{"ca_cert": "fake-invalid-ca"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Parsing blobstore CA certificate"))
+			})
+		})
+
+		It("returns error when external blobstore command is not in path", func() {
+			runner.CommandExistsValue = false
+
+			_, err := provider.Get("fake-external-type", map[string]interface{}{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating blobstore"))
+		})
+	})
+})
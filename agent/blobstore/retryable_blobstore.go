@@ -0,0 +1,105 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type retryableBlobstore struct {
+	blobstore boshblob.Blobstore
+	backoff   boshbackoff.Backoff
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewRetryableBlobstore retries downloading blobs with exponential backoff
+func NewRetryableBlobstore(blobstore boshblob.Blobstore, backoff boshbackoff.Backoff, logger boshlog.Logger) boshblob.Blobstore {
+	retryable := retryableBlobstore{
+		blobstore: blobstore,
+		backoff:   backoff,
+		logTag:    "retryableBlobstore",
+		logger:    logger,
+	}
+
+	// Streaming is offered only when wrapped blobstore supports it
+	// so that callers can keep checking for StreamingBlobstore
+	if streaming, ok := blobstore.(StreamingBlobstore); ok {
+		return streamingRetryableBlobstore{retryableBlobstore: retryable, streaming: streaming}
+	}
+
+	return retryable
+}
+
+type streamingRetryableBlobstore struct {
+	retryableBlobstore
+	streaming StreamingBlobstore
+}
+
+// CreateFromReader is not retried since stream cannot be read again
+func (b streamingRetryableBlobstore) CreateFromReader(reader io.Reader) (string, error) {
+	return b.streaming.CreateFromReader(reader)
+}
+
+func (b retryableBlobstore) Get(blobID, fingerprint string) (string, error) {
+	var fileName string
+
+	err := b.backoff.Retry(context.Background(), func() error {
+		var err error
+
+		fileName, err = b.blobstore.Get(blobID, fingerprint)
+		if err != nil {
+			b.logger.Info(b.logTag, "Failed to get blob %s with error '%s'", blobID, err.Error())
+
+			if isPermanentGetError(err) {
+				return boshbackoff.Permanent(err)
+			}
+		}
+
+		return err
+	})
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Getting blob %s", blobID)
+	}
+
+	return fileName, nil
+}
+
+// isPermanentGetError finds errors that downloading blob again would not fix
+func isPermanentGetError(err error) bool {
+	for err != nil {
+		switch typedErr := err.(type) {
+		case DigestMismatchError, InvalidDigestError, BlobNotFoundError, InvalidBlobIDError:
+			return true
+		case UnexpectedStatusError:
+			return typedErr.Permanent()
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+func (b retryableBlobstore) CleanUp(fileName string) error {
+	return b.blobstore.CleanUp(fileName)
+}
+
+func (b retryableBlobstore) Create(fileName string) (string, string, error) {
+	return b.blobstore.Create(fileName)
+}
+
+func (b retryableBlobstore) Validate() error {
+	return b.blobstore.Validate()
+}
+
+func (b retryableBlobstore) Delete(blobID string) error {
+	return b.blobstore.Delete(blobID)
+}
@@ -0,0 +1,195 @@
+package blobstore_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	fakeagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore/fakes"
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("retryableBlobstore", func() {
+	var (
+		innerBlobstore *fakeblob.FakeBlobstore
+		fakeClock      *fakeclock.FakeClock
+		blobstore      boshblob.Blobstore
+	)
+
+	BeforeEach(func() {
+		innerBlobstore = fakeblob.NewFakeBlobstore()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		backoff := boshbackoff.New(boshbackoff.Options{
+			InitialInterval: 1 * time.Second,
+			Multiplier:      2,
+			MaxInterval:     2 * time.Second,
+			MaxElapsedTime:  3 * time.Second,
+		}, fakeClock)
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		blobstore = NewRetryableBlobstore(innerBlobstore, backoff, logger)
+	})
+
+	Describe("Get", func() {
+		It("returns blob from inner blobstore", func() {
+			innerBlobstore.GetFileName = "/fake-blob-file"
+
+			fileName, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-file"))
+			Expect(innerBlobstore.GetBlobIDs).To(Equal([]string{"fake-blob-id"}))
+			Expect(innerBlobstore.GetFingerprints).To(Equal([]string{"fake-fingerprint"}))
+		})
+
+		It("retries getting blob until inner blobstore succeeds", func() {
+			innerBlobstore.GetFileNames = []string{"", "/fake-blob-file"}
+			innerBlobstore.GetErrs = []error{errors.New("fake-get-err"), nil}
+
+			type result struct {
+				fileName string
+				err      error
+			}
+			resultCh := make(chan result)
+
+			go func() {
+				fileName, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+				resultCh <- result{fileName, err}
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+
+			Eventually(resultCh).Should(Receive(Equal(result{fileName: "/fake-blob-file"})))
+			Expect(innerBlobstore.GetBlobIDs).To(Equal([]string{"fake-blob-id", "fake-blob-id"}))
+		})
+
+		It("returns error when inner blobstore keeps failing", func() {
+			innerBlobstore.GetError = errors.New("fake-get-err")
+
+			errCh := make(chan error)
+			go func() {
+				_, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+				errCh <- err
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+			fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Getting blob fake-blob-id: Giving up after 3 attempts: fake-get-err"))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(3))
+		})
+
+		It("does not retry when blob does not match its digest", func() {
+			innerBlobstore.GetError = bosherr.WrapError(
+				DigestMismatchError{Algorithm: "sha256", Expected: "fake-expected", Actual: "fake-actual"},
+				"Verifying blob fake-blob-id",
+			)
+
+			_, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Getting blob fake-blob-id: Verifying blob fake-blob-id: SHA256 mismatch. Expected fake-expected, got fake-actual"))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(1))
+		})
+
+		It("does not retry when blob is not found", func() {
+			innerBlobstore.GetError = bosherr.WrapError(BlobNotFoundError{BlobID: "fake-blob-id"}, "Downloading blob fake-blob-id")
+
+			_, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Getting blob fake-blob-id: Downloading blob fake-blob-id: Blob fake-blob-id not found"))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(1))
+		})
+
+		It("does not retry when fingerprint cannot be parsed", func() {
+			innerBlobstore.GetError = bosherr.WrapError(
+				InvalidDigestError{Digests: "md5:fake-digest", Reason: "Unknown digest algorithm 'md5'"},
+				"Parsing fingerprint of blob fake-blob-id",
+			)
+
+			_, err := blobstore.Get("fake-blob-id", "md5:fake-digest")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown digest algorithm 'md5' in 'md5:fake-digest'"))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(1))
+		})
+
+		It("does not retry when server refuses request", func() {
+			innerBlobstore.GetError = bosherr.WrapError(UnexpectedStatusError{StatusCode: 403}, "Downloading blob fake-blob-id")
+
+			_, err := blobstore.Get("fake-blob-id", "fake-fingerprint")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unexpected response status 403"))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(1))
+		})
+
+		It("retries when server is temporarily unavailable", func() {
+			innerBlobstore.GetFileNames = []string{"", "/fake-blob-file"}
+			innerBlobstore.GetErrs = []error{UnexpectedStatusError{StatusCode: 503}, nil}
+
+			fileNameCh := make(chan string)
+			go func() {
+				fileName, _ := blobstore.Get("fake-blob-id", "fake-fingerprint")
+				fileNameCh <- fileName
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+
+			Eventually(fileNameCh).Should(Receive(Equal("/fake-blob-file")))
+			Expect(innerBlobstore.GetBlobIDs).To(HaveLen(2))
+		})
+	})
+
+	Describe("CreateFromReader", func() {
+		var (
+			streamingBlobstore *fakeagentblob.FakeStreamingBlobstore
+			backoff            boshbackoff.Backoff
+		)
+
+		BeforeEach(func() {
+			streamingBlobstore = fakeagentblob.NewFakeStreamingBlobstore()
+			backoff = boshbackoff.New(boshbackoff.Options{
+				InitialInterval: 1 * time.Second,
+				MaxElapsedTime:  3 * time.Second,
+			}, fakeClock)
+		})
+
+		It("streams to inner blobstore when it supports streaming", func() {
+			streamingBlobstore.CreateFromReaderBlobID = "fake-blob-id"
+
+			blobstore = NewRetryableBlobstore(streamingBlobstore, backoff, boshlog.NewLogger(boshlog.LevelNone))
+
+			streaming, ok := blobstore.(StreamingBlobstore)
+			Expect(ok).To(BeTrue())
+
+			blobID, err := streaming.CreateFromReader(strings.NewReader("fake-contents"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(streamingBlobstore.CreateFromReaderSize).To(Equal(int64(len("fake-contents"))))
+		})
+
+		It("does not retry since stream cannot be read again", func() {
+			streamingBlobstore.CreateFromReaderErr = errors.New("fake-create-err")
+
+			blobstore = NewRetryableBlobstore(streamingBlobstore, backoff, boshlog.NewLogger(boshlog.LevelNone))
+
+			_, err := blobstore.(StreamingBlobstore).CreateFromReader(strings.NewReader("fake-contents"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+			Expect(streamingBlobstore.CreateFromReaderCallCount).To(Equal(1))
+		})
+
+		It("does not support streaming when inner blobstore does not", func() {
+			_, ok := blobstore.(StreamingBlobstore)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,11 @@
+package blobstore
+
+import (
+	"io"
+)
+
+// StreamingBlobstore is implemented by blobstores that are able
+// to create a blob from a stream instead of a file on disk
+type StreamingBlobstore interface {
+	CreateFromReader(reader io.Reader) (blobID string, err error)
+}
@@ -3,6 +3,11 @@ package agent
 import (
 	"errors"
 	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
 
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -12,6 +17,8 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+const bootstrapLogTag = "bootstrap"
+
 type Bootstrap interface {
 	Run() error
 }
@@ -21,13 +28,20 @@ type bootstrap struct {
 	platform        boshplatform.Platform
 	dirProvider     boshdir.Provider
 	settingsService boshsettings.Service
+	deadline        time.Duration
+	timeService     clock.Clock
+	progress        *bootstrapProgress
 	logger          boshlog.Logger
 }
 
+// NewBootstrap aborts Run when it does not finish within deadline,
+// e.g. when settings cannot be fetched; there is no deadline when 0
 func NewBootstrap(
 	platform boshplatform.Platform,
 	dirProvider boshdir.Provider,
 	settingsService boshsettings.Service,
+	deadline time.Duration,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) Bootstrap {
 	return bootstrap{
@@ -35,15 +49,45 @@ func NewBootstrap(
 		platform:        platform,
 		dirProvider:     dirProvider,
 		settingsService: settingsService,
+		deadline:        deadline,
+		timeService:     timeService,
+		progress:        &bootstrapProgress{},
 		logger:          logger,
 	}
 }
 
-func (boot bootstrap) Run() (err error) {
+// Run returns when deadline passes even though step in progress cannot be
+// interrupted; agent is expected to exit since bootstrap failed
+func (boot bootstrap) Run() error {
+	if boot.deadline == 0 {
+		return boot.run()
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- boot.run()
+	}()
+
+	timer := boot.timeService.NewTimer(boot.deadline)
+	defer timer.Stop()
+
+	select {
+	case err := <-errCh:
+		return err
+
+	case <-timer.C():
+		return bosherr.Errorf("Timed out after %s during bootstrap step '%s'", boot.deadline, boot.progress.Current())
+	}
+}
+
+func (boot bootstrap) run() (err error) {
+	boot.progress.Start("Setting up runtime configuration")
 	if err = boot.platform.SetupRuntimeConfiguration(); err != nil {
 		return bosherr.WrapError(err, "Setting up runtime configuration")
 	}
 
+	boot.progress.Start("Setting up ssh")
 	publicKey, err := boot.settingsService.PublicSSHKeyForUsername(boshsettings.VCAPUsername)
 	if err != nil {
 		return bosherr.WrapError(err, "Setting up ssh: Getting public key")
@@ -55,54 +99,100 @@ func (boot bootstrap) Run() (err error) {
 		}
 	}
 
+	boot.progress.Start("Fetching settings")
 	if err = boot.settingsService.LoadSettings(); err != nil {
 		return bosherr.WrapError(err, "Fetching settings")
 	}
 
 	settings := boot.settingsService.GetSettings()
 
+	if err = settings.Env.Custom.Validate(); err != nil {
+		return bosherr.WrapError(err, "Validating custom environment")
+	}
+
+	markers, err := loadBootstrapStepMarkers(boot.fs, path.Join(boot.dirProvider.BoshDir(), "bootstrap_steps.json"), settings)
+	if err != nil {
+		return bosherr.WrapError(err, "Loading bootstrap step markers")
+	}
+
+	boot.progress.Start("Setting user passwords")
 	if err = boot.setUserPasswords(settings.Env); err != nil {
 		return bosherr.WrapError(err, "Settings user password")
 	}
 
-	if err = boot.platform.SetupHostname(settings.AgentID); err != nil {
+	boot.progress.Start("Updating trusted certificates")
+	if err = boot.platform.GetCertManager().UpdateCertificates(settings.TrustedCerts); err != nil {
+		return bosherr.WrapError(err, "Updating trusted certificates")
+	}
+
+	boot.progress.Start("Setting up hostname")
+	if err = boot.platform.SetupHostname(settings.Hostname()); err != nil {
 		return bosherr.WrapError(err, "Setting up hostname")
 	}
 
-	if err = boot.platform.SetupNetworking(settings.Networks); err != nil {
+	boot.progress.Start("Setting up networking")
+	err = boot.runStep(markers, "setup_networking", func() error {
+		return boot.platform.SetupNetworking(settings.Networks)
+	})
+	if err != nil {
 		return bosherr.WrapError(err, "Setting up networking")
 	}
 
+	boot.progress.Start("Setting up NTP servers")
 	if err = boot.platform.SetTimeWithNtpServers(settings.Ntp); err != nil {
 		return bosherr.WrapError(err, "Setting up NTP servers")
 	}
 
-	if err = boot.platform.SetupRawEphemeralDisks(settings.RawEphemeralDiskSettings()); err != nil {
+	boot.progress.Start("Setting up raw ephemeral disk")
+	err = boot.runStep(markers, "setup_raw_ephemeral_disks", func() error {
+		return boot.platform.SetupRawEphemeralDisks(settings.RawEphemeralDiskSettings())
+	})
+	if err != nil {
 		return bosherr.WrapError(err, "Setting up raw ephemeral disk")
 	}
 
-	ephemeralDiskPath := boot.platform.GetEphemeralDiskPath(settings.EphemeralDiskSettings())
-	if err = boot.platform.SetupEphemeralDiskWithPath(ephemeralDiskPath); err != nil {
-		return bosherr.WrapError(err, "Setting up ephemeral disk")
-	}
+	boot.progress.Start("Setting up ephemeral and root disks")
+	// Root disk is set up in the same step since it needs resolved ephemeral disk path
+	err = boot.runStep(markers, "setup_ephemeral_and_root_disks", func() error {
+		ephemeralDiskSettings := settings.EphemeralDiskSettings()
+		ephemeralDiskPath := boot.platform.GetEphemeralDiskPath(ephemeralDiskSettings)
+		if err := boot.platform.SetupEphemeralDiskWithPath(ephemeralDiskPath, ephemeralDiskSettings.FileSystemType, settings.Env.GetDisableSwap()); err != nil {
+			return bosherr.WrapError(err, "Setting up ephemeral disk")
+		}
 
-	if err = boot.platform.SetupRootDisk(ephemeralDiskPath); err != nil {
-		return bosherr.WrapError(err, "Setting up root disk")
+		if err := boot.platform.SetupRootDisk(ephemeralDiskPath); err != nil {
+			return bosherr.WrapError(err, "Setting up root disk")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if err = boot.platform.SetupDataDir(); err != nil {
+	boot.progress.Start("Setting up data dir")
+	err = boot.runStep(markers, "setup_data_dir", boot.platform.SetupDataDir)
+	if err != nil {
 		return bosherr.WrapError(err, "Setting up data dir")
 	}
 
-	if err = boot.platform.SetupTmpDir(); err != nil {
+	boot.progress.Start("Setting up tmp dir")
+	err = boot.runStep(markers, "setup_tmp_dir", boot.platform.SetupTmpDir)
+	if err != nil {
 		return bosherr.WrapError(err, "Setting up tmp dir")
 	}
 
-	if len(settings.Disks.Persistent) > 1 {
+	boot.progress.Start("Mounting persistent disks")
+	diskIDs, ok := mountablePersistentDiskIDs(boot.platform, settings)
+	if !ok {
 		return errors.New("Error mounting persistent disk, there is more than one persistent disk")
 	}
 
-	for diskID := range settings.Disks.Persistent {
+	for _, diskID := range diskIDs {
+		if err = settings.ValidatePersistentDisk(diskID); err != nil {
+			return bosherr.WrapError(err, "Validating persistent disk settings")
+		}
+
 		diskSettings, _ := settings.PersistentDiskSettings(diskID)
 
 		isPartitioned, err := boot.platform.IsPersistentDiskMountable(diskSettings)
@@ -111,20 +201,23 @@ func (boot bootstrap) Run() (err error) {
 		}
 
 		if isPartitioned {
-			if err = boot.platform.MountPersistentDisk(diskSettings, boot.dirProvider.StoreDir()); err != nil {
+			if err = boot.platform.MountPersistentDisk(diskSettings, boot.platform.GetPersistentDiskMountPoint(diskID)); err != nil {
 				return bosherr.WrapError(err, "Mounting persistent disk")
 			}
 		}
 	}
 
+	boot.progress.Start("Setting up monit user")
 	if err = boot.platform.SetupMonitUser(); err != nil {
 		return bosherr.WrapError(err, "Setting up monit user")
 	}
 
+	boot.progress.Start("Starting monit")
 	if err = boot.platform.StartMonit(); err != nil {
 		return bosherr.WrapError(err, "Starting monit")
 	}
 
+	boot.progress.Start("Removing development tools")
 	if settings.Env.GetRemoveDevTools() {
 		packageFileListPath := path.Join(boot.dirProvider.EtcDir(), "dev_tools_file_list")
 
@@ -140,6 +233,45 @@ func (boot bootstrap) Run() (err error) {
 	return nil
 }
 
+// bootstrapProgress is read when deadline passes while step is still running
+type bootstrapProgress struct {
+	step string
+	lock sync.Mutex
+}
+
+func (p *bootstrapProgress) Start(step string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.step = step
+}
+
+func (p *bootstrapProgress) Current() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.step
+}
+
+// runStep skips step completed by previous bootstrap run with the same settings.
+// Failing to record completion is not fatal since step will just run again.
+func (boot bootstrap) runStep(markers *bootstrapStepMarkers, name string, step func() error) error {
+	if markers.IsCompleted(name) {
+		boot.logger.Info(bootstrapLogTag, "Skipping bootstrap step '%s' completed by previous run", name)
+		return nil
+	}
+
+	err := step()
+	if err != nil {
+		return err
+	}
+
+	err = markers.MarkCompleted(name)
+	if err != nil {
+		boot.logger.Warn(bootstrapLogTag, "Recording completion of bootstrap step '%s': %s", name, err.Error())
+	}
+
+	return nil
+}
+
 func (boot bootstrap) setUserPasswords(env boshsettings.Env) error {
 	password := env.GetPassword()
 	if password == "" {
@@ -160,3 +292,25 @@ func (boot bootstrap) setUserPasswords(env boshsettings.Env) error {
 
 	return nil
 }
+
+// mountablePersistentDiskIDs returns sorted disk IDs unless more than one disk
+// would be mounted at the same path; only platforms mounting each disk
+// at its own path support multiple persistent disks
+func mountablePersistentDiskIDs(platform boshplatform.Platform, settings boshsettings.Settings) ([]string, bool) {
+	diskIDs := []string{}
+	mountPoints := map[string]bool{}
+
+	for diskID := range settings.Disks.Persistent {
+		mountPoint := platform.GetPersistentDiskMountPoint(diskID)
+		if mountPoints[mountPoint] {
+			return nil, false
+		}
+
+		mountPoints[mountPoint] = true
+		diskIDs = append(diskIDs, diskID)
+	}
+
+	sort.Strings(diskIDs)
+
+	return diskIDs, true
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// bootstrapStepMarkers remember which bootstrap steps completed so that
+// bootstrap restarted after an interruption can skip them.
+// Markers only apply to the settings and boot they were recorded for
+// since effects of steps such as mounts do not survive a reboot.
+type bootstrapStepMarkers struct {
+	fs    boshsys.FileSystem
+	path  string
+	state bootstrapStepMarkersState
+}
+
+type bootstrapStepMarkersState struct {
+	SettingsDigest string   `json:"settings_digest"`
+	BootID         string   `json:"boot_id"`
+	CompletedSteps []string `json:"completed_steps"`
+}
+
+func loadBootstrapStepMarkers(fs boshsys.FileSystem, path string, settings boshsettings.Settings) (*bootstrapStepMarkers, error) {
+	settingsDigest, err := digestSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Boot ID is not available on all platforms in which case markers are kept across reboots
+	bootID, _ := fs.ReadFileString(bootIDPath)
+
+	markers := &bootstrapStepMarkers{
+		fs:   fs,
+		path: path,
+		state: bootstrapStepMarkersState{
+			SettingsDigest: settingsDigest,
+			BootID:         strings.TrimSpace(bootID),
+		},
+	}
+
+	if !fs.FileExists(path) {
+		return markers, nil
+	}
+
+	bytes, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading bootstrap step markers")
+	}
+
+	var state bootstrapStepMarkersState
+
+	err = json.Unmarshal(bytes, &state)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling bootstrap step markers")
+	}
+
+	if state.SettingsDigest == markers.state.SettingsDigest && state.BootID == markers.state.BootID {
+		markers.state.CompletedSteps = state.CompletedSteps
+	}
+
+	return markers, nil
+}
+
+func (m *bootstrapStepMarkers) IsCompleted(step string) bool {
+	for _, completedStep := range m.state.CompletedSteps {
+		if completedStep == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *bootstrapStepMarkers) MarkCompleted(step string) error {
+	m.state.CompletedSteps = append(m.state.CompletedSteps, step)
+
+	bytes, err := json.Marshal(m.state)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling bootstrap step markers")
+	}
+
+	err = m.fs.WriteFile(m.path, bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing bootstrap step markers")
+	}
+
+	return nil
+}
+
+func digestSettings(settings boshsettings.Settings) (string, error) {
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling settings")
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum(bytes)), nil
+}
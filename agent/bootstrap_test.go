@@ -12,13 +12,17 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	fakecert "github.com/cloudfoundry/bosh-agent/platform/cert/fakes"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	fakeip "github.com/cloudfoundry/bosh-agent/platform/net/ip/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -61,7 +65,7 @@ func init() {
 
 			bootstrap := func() error {
 				logger := boshlog.NewLogger(boshlog.LevelNone)
-				return NewBootstrap(platform, dirProvider, settingsService, logger).Run()
+				return NewBootstrap(platform, dirProvider, settingsService, 0, clock.NewClock(), logger).Run()
 			}
 
 			It("sets up runtime configuration", func() {
@@ -125,6 +129,36 @@ func init() {
 				Expect(platform.SetupHostnameHostname).To(Equal("foo-bar-baz-123"))
 			})
 
+			It("sets up hostname from env when provided", func() {
+				settingsService.Settings.AgentID = "foo-bar-baz-123"
+				settingsService.Settings.Env.Bosh.Hostname = "fake-hostname"
+
+				err := bootstrap()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(platform.SetupHostnameHostname).To(Equal("fake-hostname"))
+			})
+
+			It("installs trusted certs from settings", func() {
+				settingsService.Settings.TrustedCerts = "fake-trusted-certs"
+
+				err := bootstrap()
+				Expect(err).NotTo(HaveOccurred())
+
+				certManager := platform.GetCertManager().(*fakecert.FakeManager)
+				Expect(certManager.UpdateCertificatesCallCount()).To(Equal(1))
+				Expect(certManager.UpdateCertificatesArgsForCall(0)).To(Equal("fake-trusted-certs"))
+			})
+
+			It("returns error if installing trusted certs fails", func() {
+				certManager := platform.GetCertManager().(*fakecert.FakeManager)
+				certManager.UpdateCertificatesReturns(errors.New("fake-update-certs-err"))
+
+				err := bootstrap()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-update-certs-err"))
+				Expect(platform.SetupHostnameHostname).To(BeEmpty())
+			})
+
 			It("fetches initial settings", func() {
 				err := bootstrap()
 				Expect(err).NotTo(HaveOccurred())
@@ -139,6 +173,15 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-load-error"))
 			})
 
+			It("returns error before setting up anything when custom environment uses reserved name", func() {
+				settingsService.Settings.Env.Custom = boshsettings.CustomEnv{"PATH": "/fake-bin"}
+
+				err := bootstrap()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Custom environment variable 'PATH' is reserved"))
+				Expect(platform.SetupHostnameHostname).To(BeEmpty())
+			})
+
 			It("sets up networking", func() {
 				networks := boshsettings.Networks{
 					"bosh": boshsettings.Network{},
@@ -166,6 +209,32 @@ func init() {
 				}))
 			})
 
+			It("sets up ephemeral disk with filesystem type from env", func() {
+				settingsService.Settings.Disks = boshsettings.Disks{
+					Ephemeral: "fake-ephemeral-disk-setting",
+				}
+				settingsService.Settings.Env = boshsettings.Env{
+					EphemeralDiskFS: boshdisk.FileSystemXFS,
+				}
+
+				err := bootstrap()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(platform.SetupEphemeralDiskWithPathFsType).To(Equal(boshdisk.FileSystemXFS))
+			})
+
+			It("sets up ephemeral disk without swap when it is disabled in env", func() {
+				settingsService.Settings.Disks = boshsettings.Disks{
+					Ephemeral: "fake-ephemeral-disk-setting",
+				}
+				settingsService.Settings.Env = boshsettings.Env{
+					Bosh: boshsettings.BoshEnv{DisableSwap: true},
+				}
+
+				err := bootstrap()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(platform.SetupEphemeralDiskWithPathDisableSwap).To(BeTrue())
+			})
+
 			It("returns error if setting ephemeral disk fails", func() {
 				platform.SetupEphemeralDiskWithPathErr = errors.New("fake-setup-ephemeral-disk-err")
 				err := bootstrap()
@@ -331,6 +400,31 @@ func init() {
 					})
 				})
 
+				Context("when platform mounts each persistent disk at its own path", func() {
+					BeforeEach(func() {
+						settingsService.Settings.Disks = boshsettings.Disks{
+							Persistent: map[string]interface{}{
+								"vol-123": "/dev/sdb",
+								"vol-456": "/dev/sdc",
+							},
+						}
+						platform.PersistentDiskMountPoints = map[string]string{
+							"vol-123": "/var/vcap/stores/vol-123",
+							"vol-456": "/var/vcap/stores/vol-456",
+						}
+						platform.SetIsPersistentDiskMountable(true, nil)
+					})
+
+					It("mounts all persistent disks", func() {
+						err := bootstrap()
+						Expect(err).NotTo(HaveOccurred())
+						Expect(platform.MountPersistentDiskMountPoints).To(Equal(map[string]string{
+							"vol-123": "/var/vcap/stores/vol-123",
+							"vol-456": "/var/vcap/stores/vol-456",
+						}))
+					})
+				})
+
 				Context("when there is no persistent disk", func() {
 					It("does not try to mount ", func() {
 						settingsService.Settings.Disks = boshsettings.Disks{
@@ -399,6 +493,100 @@ func init() {
 					})
 				})
 			})
+
+			Describe("deadline", func() {
+				var timeService *fakeclock.FakeClock
+
+				BeforeEach(func() {
+					timeService = fakeclock.NewFakeClock(time.Now())
+				})
+
+				bootstrapWithDeadline := func() error {
+					logger := boshlog.NewLogger(boshlog.LevelNone)
+					return NewBootstrap(platform, dirProvider, settingsService, 10*time.Minute, timeService, logger).Run()
+				}
+
+				It("finishes when bootstrap completes within deadline", func() {
+					err := bootstrapWithDeadline()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(platform.StartMonitStarted).To(BeTrue())
+				})
+
+				It("returns error naming step in progress when deadline passes", func() {
+					unblock := make(chan struct{})
+					defer close(unblock)
+
+					settingsService.LoadSettingsCallback = func() {
+						<-unblock
+					}
+
+					go timeService.WaitForWatcherAndIncrement(10 * time.Minute)
+
+					err := bootstrapWithDeadline()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Timed out after 10m0s during bootstrap step 'Fetching settings'"))
+				})
+
+				It("returns error from bootstrap step before deadline passes", func() {
+					settingsService.LoadSettingsError = errors.New("fake-load-error")
+
+					err := bootstrapWithDeadline()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-load-error"))
+				})
+			})
+
+			Describe("Resuming interrupted bootstrap", func() {
+				BeforeEach(func() {
+					settingsService.Settings.AgentID = "fake-agent-id"
+
+					err := bootstrap()
+					Expect(err).NotTo(HaveOccurred())
+
+					platform.SetupDataDirCalled = false
+					platform.SetupTmpDirCalled = false
+				})
+
+				It("records completed steps", func() {
+					Expect(platform.GetFs().FileExists("/var/vcap/bosh/bootstrap_steps.json")).To(BeTrue())
+				})
+
+				It("skips steps completed by previous run", func() {
+					err := bootstrap()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(platform.SetupDataDirCalled).To(BeFalse())
+					Expect(platform.SetupTmpDirCalled).To(BeFalse())
+					Expect(platform.StartMonitStarted).To(BeTrue())
+				})
+
+				It("runs all steps again when settings changed", func() {
+					settingsService.Settings.AgentID = "fake-other-agent-id"
+
+					err := bootstrap()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(platform.SetupDataDirCalled).To(BeTrue())
+					Expect(platform.SetupTmpDirCalled).To(BeTrue())
+				})
+
+				It("runs steps again that did not complete", func() {
+					settingsService.Settings.AgentID = "fake-other-agent-id"
+					platform.SetupDataDirErr = errors.New("fake-setup-data-dir-err")
+
+					err := bootstrap()
+					Expect(err).To(HaveOccurred())
+					Expect(platform.SetupTmpDirCalled).To(BeFalse())
+
+					platform.SetupDataDirErr = nil
+					platform.SetupDataDirCalled = false
+
+					err = bootstrap()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(platform.SetupDataDirCalled).To(BeTrue())
+					Expect(platform.SetupTmpDirCalled).To(BeTrue())
+				})
+			})
 		})
 
 		Describe("Network setup exercised by Run", func() {
@@ -471,7 +659,7 @@ func init() {
 
 				diskManager.FakeRootDevicePartitioner.GetDeviceSizeInBytesSizes["/dev/vda"] = 1024 * 1024 * 1024
 
-				udev := boshudev.NewConcreteUdevDevice(runner, logger)
+				udev := boshudev.NewConcreteUdevDevice(runner, clock.NewClock(), logger)
 				linuxCdrom := boshcdrom.NewLinuxCdrom("/dev/sr0", udev, runner)
 				linuxCdutil := boshcdrom.NewCdUtil(dirProvider.SettingsDir(), fs, linuxCdrom, logger)
 
@@ -484,16 +672,16 @@ func init() {
 
 				ipResolver := boship.NewResolver(boship.NetworkInterfaceToAddrsFunc)
 
-				arping := bosharp.NewArping(runner, fs, logger, boshplatform.ArpIterations, boshplatform.ArpIterationDelay, boshplatform.ArpInterfaceCheckDelay)
-				interfaceConfigurationCreator := boshnet.NewInterfaceConfigurationCreator(logger)
+				arping := bosharp.NewArping(runner, fs, clock.NewClock(), logger, boshplatform.ArpIterations, boshplatform.ArpIterationDelay, boshplatform.ArpInterfaceCheckDelay)
+				interfaceConfigurationCreator := boshnet.NewInterfaceConfigurationCreator(boshnet.NewInterfaceNameResolver(), logger)
 
 				interfaceAddrsProvider = &fakeip.FakeInterfaceAddressesProvider{}
 				interfaceAddressesValidator := boship.NewInterfaceAddressesValidator(interfaceAddrsProvider)
 				dnsValidator := boshnet.NewDNSValidator(fs)
 				fs.WriteFileString("/etc/resolv.conf", "8.8.8.8 4.4.4.4")
-				ubuntuNetManager := boshnet.NewUbuntuNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, logger)
+				ubuntuNetManager := boshnet.NewUbuntuNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, boshnet.NewDhclientDHCPClient(), nil, &fakeaction.FakeClock{}, logger)
 
-				ubuntuCertManager := boshcert.NewUbuntuCertManager(fs, runner, 1, logger)
+				ubuntuCertManager := boshcert.NewUbuntuCertManager(fs, runner, 1, clock.NewClock(), logger)
 
 				monitRetryable := boshplatform.NewMonitRetryable(runner)
 				monitRetryStrategy := boshretry.NewAttemptRetryStrategy(10, 1*time.Second, monitRetryable, logger)
@@ -519,6 +707,7 @@ func init() {
 					ubuntuCertManager,
 					monitRetryStrategy,
 					devicePathResolver,
+					devicePathResolver,
 					500*time.Millisecond,
 					state,
 					linuxOptions,
@@ -550,6 +739,8 @@ func init() {
 					platform,
 					dirProvider,
 					settingsService,
+					0,
+					clock.NewClock(),
 					logger,
 				)
 			})
@@ -582,7 +773,7 @@ func init() {
 						It("raises an error", func() {
 							err := boot.Run()
 							Expect(err).To(HaveOccurred())
-							Expect(err.Error()).To(ContainSubstring("Number of network settings '1' is greater than the number of network devices '0"))
+							Expect(err.Error()).To(ContainSubstring("Timed out after 30s waiting for network interfaces with MAC addresses 'aa:bb:cc'"))
 						})
 					})
 				})
@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// DefaultStaleCompileDirAge is long enough for any compilation to finish
+const DefaultStaleCompileDirAge = 24 * time.Hour
+
+// Written next to each compile dir while package is compiled
+const startedMarkerSuffix = "-bosh-agent-started"
+
+const compileDirSweeperLogTag = "compileDirSweeper"
+
+type CompileDirSweeper struct {
+	compileDirProvider CompileDirProvider
+	fs                 boshsys.FileSystem
+	timeService        clock.Clock
+	maxAge             time.Duration
+	logger             boshlog.Logger
+}
+
+// NewCompileDirSweeper removes work dirs of compilations started more than
+// maxAge ago; more recent compilations are considered to be still running
+func NewCompileDirSweeper(
+	compileDirProvider CompileDirProvider,
+	fs boshsys.FileSystem,
+	timeService clock.Clock,
+	maxAge time.Duration,
+	logger boshlog.Logger,
+) CompileDirSweeper {
+	return CompileDirSweeper{
+		compileDirProvider: compileDirProvider,
+		fs:                 fs,
+		timeService:        timeService,
+		maxAge:             maxAge,
+		logger:             logger,
+	}
+}
+
+func (s CompileDirSweeper) Sweep() error {
+	markerPaths, err := s.fs.Glob(path.Join(s.compileDirProvider.CompileDir(), "*"+startedMarkerSuffix))
+	if err != nil {
+		return bosherr.WrapError(err, "Finding compilation work dirs")
+	}
+
+	for _, markerPath := range markerPaths {
+		compilePath := strings.TrimSuffix(markerPath, startedMarkerSuffix)
+
+		if !s.isStale(markerPath) {
+			s.logger.Debug(compileDirSweeperLogTag, "Keeping recent compilation work dir %s", compilePath)
+			continue
+		}
+
+		s.logger.Info(compileDirSweeperLogTag, "Removing stale compilation work dir %s", compilePath)
+
+		for _, stalePath := range []string{compilePath, unpackPath(compilePath), markerPath} {
+			err = s.fs.RemoveAll(stalePath)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Removing stale compilation work dir %s", stalePath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isStale treats markers without valid start time as stale
+// since compiler always writes one
+func (s CompileDirSweeper) isStale(markerPath string) bool {
+	contents, err := s.fs.ReadFileString(markerPath)
+	if err != nil {
+		return true
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(contents))
+	if err != nil {
+		return true
+	}
+
+	return s.timeService.Since(startedAt) > s.maxAge
+}
@@ -0,0 +1,89 @@
+package compiler_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CompileDirSweeper", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		sweeper     CompileDirSweeper
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Date(2016, time.January, 2, 3, 4, 5, 0, time.UTC))
+		sweeper = NewCompileDirSweeper(FakeCompileDirProvider{Dir: "/fake-compile-dir"}, fs, timeService, time.Hour, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	writeCompileDirs := func(pkgName string, startedAt string) {
+		fs.WriteFileString("/fake-compile-dir/"+pkgName+"/packaging", "fake-packaging")
+		fs.WriteFileString("/fake-compile-dir/"+pkgName+"-bosh-agent-unpack/partial-file", "fake-contents")
+		fs.WriteFileString("/fake-compile-dir/"+pkgName+"-bosh-agent-started", startedAt)
+	}
+
+	It("removes work dirs of compilations started before max age", func() {
+		writeCompileDirs("old_pkg", "2016-01-02T01:04:05Z")
+		writeCompileDirs("recent_pkg", "2016-01-02T02:34:05Z")
+		fs.SetGlob("/fake-compile-dir/*-bosh-agent-started", []string{
+			"/fake-compile-dir/old_pkg-bosh-agent-started",
+			"/fake-compile-dir/recent_pkg-bosh-agent-started",
+		})
+
+		err := sweeper.Sweep()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fs.FileExists("/fake-compile-dir/old_pkg")).To(BeFalse())
+		Expect(fs.FileExists("/fake-compile-dir/old_pkg-bosh-agent-unpack")).To(BeFalse())
+		Expect(fs.FileExists("/fake-compile-dir/old_pkg-bosh-agent-started")).To(BeFalse())
+
+		Expect(fs.FileExists("/fake-compile-dir/recent_pkg/packaging")).To(BeTrue())
+		Expect(fs.FileExists("/fake-compile-dir/recent_pkg-bosh-agent-unpack/partial-file")).To(BeTrue())
+		Expect(fs.FileExists("/fake-compile-dir/recent_pkg-bosh-agent-started")).To(BeTrue())
+	})
+
+	It("removes work dirs with invalid start time", func() {
+		writeCompileDirs("broken_pkg", "fake-invalid-time")
+		fs.SetGlob("/fake-compile-dir/*-bosh-agent-started", []string{"/fake-compile-dir/broken_pkg-bosh-agent-started"})
+
+		err := sweeper.Sweep()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists("/fake-compile-dir/broken_pkg")).To(BeFalse())
+	})
+
+	It("does not touch dirs that were not created by compiler", func() {
+		fs.WriteFileString("/fake-compile-dir/other-dir/file", "fake-contents")
+
+		err := sweeper.Sweep()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists("/fake-compile-dir/other-dir/file")).To(BeTrue())
+	})
+
+	It("returns error when removing stale work dir fails", func() {
+		writeCompileDirs("old_pkg", "2016-01-02T01:04:05Z")
+		fs.SetGlob("/fake-compile-dir/*-bosh-agent-started", []string{"/fake-compile-dir/old_pkg-bosh-agent-started"})
+		fs.RegisterRemoveAllError("/fake-compile-dir/old_pkg", errors.New("fake-remove-error"))
+
+		err := sweeper.Sweep()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
+	})
+
+	It("returns error when finding work dirs fails", func() {
+		fs.GlobErr = errors.New("fake-glob-error")
+
+		err := sweeper.Sweep()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-glob-error"))
+	})
+})
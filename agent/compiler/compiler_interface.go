@@ -13,6 +13,9 @@ type Package struct {
 	Name        string
 	Sha1        string
 	Version     string
+
+	// Signature is base64 encoded detached signature of the source package
+	Signature string
 }
 
 type Dependencies map[string]Package
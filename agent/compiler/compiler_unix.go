@@ -3,21 +3,40 @@
 package compiler
 
 import (
+	"os"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+// Only these variables are passed from agent environment to packaging
+// scripts so that compilation does not depend on how agent was started
+var packagingEnvPassThrough = []string{
+	"PATH", "HOME", "LANG", "TMPDIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+}
+
 func (c concreteCompiler) runPackagingCommand(compilePath, enablePath string, pkg Package) error {
+	env := map[string]string{
+		"BOSH_COMPILE_TARGET":  compilePath,
+		"BOSH_INSTALL_TARGET":  enablePath,
+		"BOSH_PACKAGE_NAME":    pkg.Name,
+		"BOSH_PACKAGE_VERSION": pkg.Version,
+	}
+
+	for _, name := range packagingEnvPassThrough {
+		if value, found := os.LookupEnv(name); found {
+			env[name] = value
+		}
+	}
+
 	command := boshsys.Command{
-		Name: "bash",
-		Args: []string{"-x", PackagingScriptName},
-		Env: map[string]string{
-			"BOSH_COMPILE_TARGET":  compilePath,
-			"BOSH_INSTALL_TARGET":  enablePath,
-			"BOSH_PACKAGE_NAME":    pkg.Name,
-			"BOSH_PACKAGE_VERSION": pkg.Version,
-		},
-		WorkingDir: compilePath,
+		Name:           "bash",
+		Args:           []string{"-x", PackagingScriptName},
+		Env:            env,
+		UseIsolatedEnv: true,
+		WorkingDir:     compilePath,
 	}
 	_, err := c.runner.RunCommand("compilation", PackagingScriptName, command)
 	if err != nil {
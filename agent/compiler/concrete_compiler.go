@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
@@ -13,12 +14,14 @@ import (
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pivotal-golang/clock"
 )
 
 const PackagingScriptName = "packaging"
 
 type CompileDirProvider interface {
 	CompileDir() string
+	EnabledPackagesDir() string
 }
 
 type concreteCompiler struct {
@@ -29,6 +32,8 @@ type concreteCompiler struct {
 	compileDirProvider CompileDirProvider
 	packageApplier     packages.Applier
 	packagesBc         boshbc.BundleCollection
+	signatureVerifier  PackageSignatureVerifier
+	timeService        clock.Clock
 }
 
 func NewConcreteCompiler(
@@ -39,6 +44,8 @@ func NewConcreteCompiler(
 	compileDirProvider CompileDirProvider,
 	packageApplier packages.Applier,
 	packagesBc boshbc.BundleCollection,
+	signatureVerifier PackageSignatureVerifier,
+	timeService clock.Clock,
 ) Compiler {
 	return concreteCompiler{
 		compressor:         compressor,
@@ -48,15 +55,25 @@ func NewConcreteCompiler(
 		compileDirProvider: compileDirProvider,
 		packageApplier:     packageApplier,
 		packagesBc:         packagesBc,
+		signatureVerifier:  signatureVerifier,
+		timeService:        timeService,
 	}
 }
 
 func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (string, string, error) {
-	err := c.packageApplier.KeepOnly([]boshmodels.Package{})
+	err := c.tearDownDependencies()
 	if err != nil {
-		return "", "", bosherr.WrapError(err, "Removing packages")
+		return "", "", err
 	}
 
+	// Next compilation must not see dependencies of the failed one
+	compiled := false
+	defer func() {
+		if !compiled {
+			_ = c.tearDownDependencies()
+		}
+	}()
+
 	for _, dep := range deps {
 		err := c.packageApplier.Apply(dep)
 		if err != nil {
@@ -65,15 +82,25 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 	}
 
 	compilePath := path.Join(c.compileDirProvider.CompileDir(), pkg.Name)
-	err = c.fetchAndUncompress(pkg, compilePath)
-	if err != nil {
-		return "", "", bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
-	}
 
+	// Always remove work dirs so that failed compilations do not fill up disk
 	defer func() {
 		_ = c.fs.RemoveAll(compilePath)
+		_ = c.fs.RemoveAll(unpackPath(compilePath))
+		_ = c.fs.RemoveAll(startedMarkerPath(compilePath))
 	}()
 
+	// Work dirs left behind by agent crashing mid-compilation are swept on startup
+	err = c.fs.WriteFileString(startedMarkerPath(compilePath), c.timeService.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Marking start of compilation of package %s", pkg.Name)
+	}
+
+	err = c.fetchAndUncompress(pkg, compilePath)
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
+	}
+
 	compiledPkg := boshmodels.Package{
 		Name:    pkg.Name,
 		Version: pkg.Version,
@@ -126,14 +153,44 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (strin
 		return "", "", bosherr.WrapError(err, "Uninstalling compiled package")
 	}
 
-	err = c.packageApplier.KeepOnly([]boshmodels.Package{})
+	compiled = true
+
+	err = c.tearDownDependencies()
 	if err != nil {
-		return "", "", bosherr.WrapError(err, "Removing packages")
+		return "", "", err
 	}
 
 	return uploadedBlobID, sha1, nil
 }
 
+// tearDownDependencies uninstalls all packages and removes package links
+// left behind by previous compilations, e.g. ones interrupted by agent restart
+func (c concreteCompiler) tearDownDependencies() error {
+	err := c.packageApplier.KeepOnly([]boshmodels.Package{})
+	if err != nil {
+		return bosherr.WrapError(err, "Removing packages")
+	}
+
+	enabledPaths, err := c.fs.Glob(path.Join(c.compileDirProvider.EnabledPackagesDir(), "*"))
+	if err != nil {
+		return bosherr.WrapError(err, "Globbing enabled packages")
+	}
+
+	for _, enabledPath := range enabledPaths {
+		target, err := c.fs.ReadLink(enabledPath)
+		if err != nil || target == "" {
+			continue // not a package link
+		}
+
+		err = c.fs.RemoveAll(enabledPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing stale package link %s", enabledPath)
+		}
+	}
+
+	return nil
+}
+
 func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) error {
 	if pkg.BlobstoreID == "" {
 		return bosherr.Error(fmt.Sprintf("Blobstore ID for package '%s' is empty", pkg.Name))
@@ -149,6 +206,16 @@ func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) erro
 		return bosherr.WrapErrorf(err, "Fetching package blob %s", pkg.BlobstoreID)
 	}
 
+	defer func() {
+		_ = c.blobstore.CleanUp(depFilePath)
+	}()
+
+	// Refuse to compile tampered sources before running any of their scripts
+	err = c.signatureVerifier.Verify(pkg, depFilePath)
+	if err != nil {
+		return err
+	}
+
 	err = c.atomicDecompress(depFilePath, targetDir)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Uncompressing package %s", pkg.Name)
@@ -157,8 +224,16 @@ func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) erro
 	return nil
 }
 
+func unpackPath(compilePath string) string {
+	return compilePath + "-bosh-agent-unpack"
+}
+
+func startedMarkerPath(compilePath string) string {
+	return compilePath + startedMarkerSuffix
+}
+
 func (c concreteCompiler) atomicDecompress(archivePath string, finalDir string) error {
-	tmpInstallPath := finalDir + "-bosh-agent-unpack"
+	tmpInstallPath := unpackPath(finalDir)
 
 	{
 		err := c.fs.RemoveAll(finalDir)
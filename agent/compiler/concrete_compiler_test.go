@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"runtime"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,6 +18,7 @@ import (
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	"github.com/pivotal-golang/clock/fakeclock"
 )
 
 type FakeCompileDirProvider struct {
@@ -25,6 +27,8 @@ type FakeCompileDirProvider struct {
 
 func (cdp FakeCompileDirProvider) CompileDir() string { return cdp.Dir }
 
+func (cdp FakeCompileDirProvider) EnabledPackagesDir() string { return "/fake-dir/packages" }
+
 func getCompileArgs() (Package, []boshmodels.Package) {
 	pkg := Package{
 		BlobstoreID: "blobstore_id",
@@ -65,6 +69,9 @@ func init() {
 			runner         *fakecmdrunner.FakeFileLoggingCmdRunner
 			packageApplier *fakepackages.FakeApplier
 			packagesBc     *fakebc.FakeBundleCollection
+
+			signatureVerifier PackageSignatureVerifier
+			timeService       *fakeclock.FakeClock
 		)
 
 		BeforeEach(func() {
@@ -74,6 +81,11 @@ func init() {
 			runner = fakecmdrunner.NewFakeFileLoggingCmdRunner()
 			packageApplier = fakepackages.NewFakeApplier()
 			packagesBc = fakebc.NewFakeBundleCollection()
+			timeService = fakeclock.NewFakeClock(time.Date(2016, time.March, 1, 12, 30, 0, 0, time.UTC))
+
+			var err error
+			signatureVerifier, err = NewPackageSignatureVerifier("", false, fs)
+			Expect(err).ToNot(HaveOccurred())
 
 			compiler = NewConcreteCompiler(
 				compressor,
@@ -83,6 +95,8 @@ func init() {
 				FakeCompileDirProvider{Dir: "/fake-compile-dir"},
 				packageApplier,
 				packagesBc,
+				signatureVerifier,
+				timeService,
 			)
 		})
 
@@ -137,6 +151,60 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
 			})
 
+			Context("when package signing public key is configured", func() {
+				var sign packageSigner
+
+				BeforeEach(func() {
+					var publicKeyPEM string
+					publicKeyPEM, sign = newPackageSigningKey("ecdsa")
+
+					var err error
+					signatureVerifier, err = NewPackageSignatureVerifier(publicKeyPEM, false, fs)
+					Expect(err).ToNot(HaveOccurred())
+
+					compiler = NewConcreteCompiler(
+						compressor,
+						blobstore,
+						fs,
+						runner,
+						FakeCompileDirProvider{Dir: "/fake-compile-dir"},
+						packageApplier,
+						packagesBc,
+						signatureVerifier,
+						timeService,
+					)
+
+					blobstore.GetFileName = "/tmp/fake-source-package"
+					fs.WriteFileString("/tmp/fake-source-package", "fake-source-contents")
+				})
+
+				It("compiles package with valid signature", func() {
+					pkg.Signature = sign([]byte("fake-source-contents"))
+
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(compressor.DecompressFileToDirTarballPaths).To(Equal([]string{"/tmp/fake-source-package"}))
+				})
+
+				It("refuses to compile tampered package and cleans up its download", func() {
+					pkg.Signature = sign([]byte("fake-other-contents"))
+
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Signature of package 'pkg_name' does not match"))
+
+					Expect(compressor.DecompressFileToDirTarballPaths).To(BeEmpty())
+					Expect(runner.RunCommands).To(BeEmpty())
+					Expect(blobstore.CleanUpFileName).To(Equal("/tmp/fake-source-package"))
+				})
+
+				It("refuses to compile unsigned package", func() {
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Package 'pkg_name' is not signed"))
+				})
+			})
+
 			It("fetches source package from blobstore without checking SHA1 by default because of Director bug", func() {
 				_, _, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
@@ -203,6 +271,97 @@ func init() {
 				_, _, err := compiler.Compile(pkg, pkgDeps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
+				Expect(fs.FileExists("/fake-compile-dir/pkg_name-bosh-agent-unpack")).To(BeFalse())
+				Expect(fs.FileExists("/fake-compile-dir/pkg_name-bosh-agent-started")).To(BeFalse())
+			})
+
+			It("marks start of compilation while package is compiled", func() {
+				var startedAt string
+				compressor.DecompressFileToDirCallBack = func() {
+					startedAt, _ = fs.ReadFileString("/fake-compile-dir/pkg_name-bosh-agent-started")
+				}
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(startedAt).To(Equal("2016-03-01T12:30:00Z"))
+			})
+
+			It("returns error when marking start of compilation fails", func() {
+				fs.WriteFileErrors["/fake-compile-dir/pkg_name-bosh-agent-started"] = errors.New("fake-write-error")
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+				Expect(compressor.DecompressFileToDirDirs).To(BeEmpty())
+			})
+
+			It("cleans up the compile directory when decompressing source package fails", func() {
+				compressor.DecompressFileToDirErr = errors.New("fake-decompress-error")
+				compressor.DecompressFileToDirCallBack = func() {
+					fs.WriteFileString("/fake-compile-dir/pkg_name-bosh-agent-unpack/partial-file", "fake-contents")
+				}
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-decompress-error"))
+
+				Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
+				Expect(fs.FileExists("/fake-compile-dir/pkg_name-bosh-agent-unpack")).To(BeFalse())
+			})
+
+			It("removes stale package links before installing dependent packages", func() {
+				fs.Symlink("/fake-dir/data/packages/stale_dep/stale_dep_version", "/fake-dir/packages/stale_dep")
+				fs.WriteFileString("/fake-dir/packages/not-a-link", "fake-contents")
+				fs.SetGlob("/fake-dir/packages/*", []string{"/fake-dir/packages/stale_dep", "/fake-dir/packages/not-a-link"})
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.FileExists("/fake-dir/packages/stale_dep")).To(BeFalse())
+				Expect(fs.FileExists("/fake-dir/packages/not-a-link")).To(BeTrue())
+			})
+
+			It("leaves no dependencies of previous compilation for the next one", func() {
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+
+				otherPkgDeps := []boshmodels.Package{pkgDeps[1]}
+				packageApplier.ActionsCalled = nil
+				packageApplier.AppliedPackages = []boshmodels.Package{}
+
+				_, _, err = compiler.Compile(pkg, otherPkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "KeepOnly"}))
+				Expect(packageApplier.AppliedPackages).To(Equal(otherPkgDeps))
+				Expect(packageApplier.KeptOnlyPackages).To(BeEmpty())
+			})
+
+			It("tears down dependencies when compilation fails so that they do not leak into next compilation", func() {
+				compressor.DecompressFileToDirErr = errors.New("fake-decompress-error")
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "Apply", "KeepOnly"}))
+			})
+
+			It("returns an error if removing stale package link fails", func() {
+				fs.Symlink("/fake-dir/data/packages/stale_dep/stale_dep_version", "/fake-dir/packages/stale_dep")
+				fs.SetGlob("/fake-dir/packages/*", []string{"/fake-dir/packages/stale_dep"})
+				fs.RegisterRemoveAllError("/fake-dir/packages/stale_dep", errors.New("fake-remove-error"))
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Removing stale package link /fake-dir/packages/stale_dep"))
+			})
+
+			It("cleans up downloaded source package", func() {
+				blobstore.GetFileName = "/tmp/fake-source-package"
+
+				_, _, err := compiler.Compile(pkg, pkgDeps)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore.CleanUpFileName).To(Equal("/tmp/fake-source-package"))
 			})
 
 			It("installs, enables and later cleans up bundle", func() {
@@ -245,6 +404,13 @@ func init() {
 					} else {
 						expectedCmd.Name = "bash"
 						expectedCmd.Args = []string{"-x", PackagingScriptName}
+						expectedCmd.UseIsolatedEnv = true
+						expectedCmd.Env["PATH"] = os.Getenv("PATH")
+						for _, name := range []string{"HOME", "LANG", "TMPDIR", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+							if value, found := os.LookupEnv(name); found {
+								expectedCmd.Env[name] = value
+							}
+						}
 					}
 
 					Expect(len(runner.RunCommands)).To(Equal(1))
@@ -260,6 +426,56 @@ func init() {
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-packaging-error"))
 				})
+
+				It("does not pass agent environment to packaging script", func() {
+					if runtime.GOOS == "windows" {
+						Skip("Packaging scripts inherit environment on Windows")
+					}
+
+					os.Setenv("FAKE_LEAKED_ENV", "fake-value")
+					defer os.Unsetenv("FAKE_LEAKED_ENV")
+
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(runner.RunCommands[0].UseIsolatedEnv).To(BeTrue())
+					Expect(runner.RunCommands[0].Env).ToNot(HaveKey("FAKE_LEAKED_ENV"))
+				})
+
+				It("passes temp dir and proxy settings to packaging script", func() {
+					if runtime.GOOS == "windows" {
+						Skip("Packaging scripts inherit environment on Windows")
+					}
+
+					names := []string{"TMPDIR", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"}
+
+					for _, name := range names {
+						if value, found := os.LookupEnv(name); found {
+							defer os.Setenv(name, value)
+						} else {
+							defer os.Unsetenv(name)
+						}
+						os.Setenv(name, "fake-"+name)
+					}
+
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).ToNot(HaveOccurred())
+
+					for _, name := range names {
+						Expect(runner.RunCommands[0].Env).To(HaveKeyWithValue(name, "fake-"+name))
+					}
+				})
+
+				It("cleans up the compile directory when packaging script fails", func() {
+					runner.RunCommandErr = errors.New("fake-packaging-error")
+
+					_, _, err := compiler.Compile(pkg, pkgDeps)
+					Expect(err).To(HaveOccurred())
+					Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "Apply", "KeepOnly"}))
+
+					Expect(fs.FileExists("/fake-compile-dir/pkg_name/" + PackagingScriptName)).To(BeFalse())
+					Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
+				})
 			})
 
 			It("does not run packaging script when script does not exist", func() {
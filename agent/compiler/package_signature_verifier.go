@@ -0,0 +1,119 @@
+package compiler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type PackageSignatureVerifier interface {
+	// Verify checks base64 encoded detached signature of the downloaded
+	// source package; empty signature means package is not signed
+	Verify(pkg Package, sourcePath string) error
+}
+
+type packageSignatureVerifier struct {
+	publicKey     crypto.PublicKey
+	allowUnsigned bool
+	fs            boshsys.FileSystem
+}
+
+// NewPackageSignatureVerifier verifies signatures with PEM encoded public key
+// (RSA, ECDSA or Ed25519) over SHA256 of the source package.
+// When no public key is configured packages are compiled without verification;
+// otherwise unsigned packages are refused unless allowUnsigned is set.
+func NewPackageSignatureVerifier(publicKeyPEM string, allowUnsigned bool, fs boshsys.FileSystem) (PackageSignatureVerifier, error) {
+	verifier := packageSignatureVerifier{allowUnsigned: allowUnsigned, fs: fs}
+
+	if publicKeyPEM == "" {
+		return verifier, nil
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, bosherr.Error("Parsing package signing public key: no PEM block found")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing package signing public key")
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		verifier.publicKey = publicKey
+	default:
+		return nil, bosherr.Errorf("Unsupported package signing public key type %T", publicKey)
+	}
+
+	return verifier, nil
+}
+
+func (v packageSignatureVerifier) Verify(pkg Package, sourcePath string) error {
+	if pkg.Signature == "" {
+		if v.publicKey == nil || v.allowUnsigned {
+			return nil
+		}
+		return bosherr.Errorf("Package '%s' is not signed", pkg.Name)
+	}
+
+	if v.publicKey == nil {
+		return bosherr.Errorf("Verifying signature of package '%s': no public key configured", pkg.Name)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(pkg.Signature)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Decoding signature of package '%s'", pkg.Name)
+	}
+
+	digest, err := v.digest(sourcePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading package '%s'", pkg.Name)
+	}
+
+	var valid bool
+
+	switch publicKey := v.publicKey.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(publicKey, digest, signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, digest, signature)
+	}
+
+	if !valid {
+		return bosherr.Errorf("Signature of package '%s' does not match", pkg.Name)
+	}
+
+	return nil
+}
+
+// digest streams package since source packages can be large
+func (v packageSignatureVerifier) digest(sourcePath string) ([]byte, error) {
+	file, err := v.fs.OpenFile(sourcePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,200 @@
+package compiler_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+type packageSigner func(contents []byte) string
+
+func newPackageSigningKey(algorithm string) (string, packageSigner) {
+	var (
+		publicKey crypto.PublicKey
+		sign      func(digest []byte) ([]byte, error)
+	)
+
+	switch algorithm {
+	case "rsa":
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		publicKey = &privateKey.PublicKey
+		sign = func(digest []byte) ([]byte, error) {
+			return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+		}
+	case "ecdsa":
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		publicKey = &privateKey.PublicKey
+		sign = func(digest []byte) ([]byte, error) {
+			return ecdsa.SignASN1(rand.Reader, privateKey, digest)
+		}
+	case "ed25519":
+		edPublicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		publicKey = edPublicKey
+		sign = func(digest []byte) ([]byte, error) {
+			return ed25519.Sign(privateKey, digest), nil
+		}
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}))
+
+	return publicKeyPEM, func(contents []byte) string {
+		digest := sha256.Sum256(contents)
+		signature, err := sign(digest[:])
+		Expect(err).ToNot(HaveOccurred())
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+}
+
+var _ = Describe("PackageSignatureVerifier", func() {
+	const sourcePath = "/fake-source-package.tgz"
+
+	var (
+		fs  *fakesys.FakeFileSystem
+		pkg Package
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		fs.WriteFileString(sourcePath, "fake-source-contents")
+
+		pkg = Package{Name: "fake-pkg"}
+	})
+
+	for _, algorithm := range []string{"rsa", "ecdsa", "ed25519"} {
+		algorithm := algorithm
+
+		Context("when "+algorithm+" public key is configured", func() {
+			var (
+				verifier PackageSignatureVerifier
+				sign     packageSigner
+			)
+
+			BeforeEach(func() {
+				var publicKeyPEM string
+				publicKeyPEM, sign = newPackageSigningKey(algorithm)
+
+				var err error
+				verifier, err = NewPackageSignatureVerifier(publicKeyPEM, false, fs)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("accepts package with valid signature", func() {
+				pkg.Signature = sign([]byte("fake-source-contents"))
+				Expect(verifier.Verify(pkg, sourcePath)).To(Succeed())
+			})
+
+			It("refuses tampered package", func() {
+				pkg.Signature = sign([]byte("fake-other-contents"))
+
+				err := verifier.Verify(pkg, sourcePath)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Signature of package 'fake-pkg' does not match"))
+			})
+		})
+	}
+
+	Context("when public key is configured", func() {
+		var (
+			publicKeyPEM string
+			sign         packageSigner
+		)
+
+		BeforeEach(func() {
+			publicKeyPEM, sign = newPackageSigningKey("ecdsa")
+		})
+
+		It("refuses unsigned package", func() {
+			verifier, err := NewPackageSignatureVerifier(publicKeyPEM, false, fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = verifier.Verify(pkg, sourcePath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Package 'fake-pkg' is not signed"))
+		})
+
+		It("accepts unsigned package when unsigned packages are allowed", func() {
+			verifier, err := NewPackageSignatureVerifier(publicKeyPEM, true, fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifier.Verify(pkg, sourcePath)).To(Succeed())
+		})
+
+		It("still verifies signed package when unsigned packages are allowed", func() {
+			verifier, err := NewPackageSignatureVerifier(publicKeyPEM, true, fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			pkg.Signature = sign([]byte("fake-other-contents"))
+			Expect(verifier.Verify(pkg, sourcePath)).ToNot(Succeed())
+		})
+
+		It("returns error when package cannot be opened", func() {
+			verifier, err := NewPackageSignatureVerifier(publicKeyPEM, false, fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			fs.OpenFileErr = errors.New("fake-open-err")
+			pkg.Signature = sign([]byte("fake-source-contents"))
+
+			err = verifier.Verify(pkg, sourcePath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading package 'fake-pkg': fake-open-err"))
+		})
+
+		It("returns error when signature is not base64 encoded", func() {
+			verifier, err := NewPackageSignatureVerifier(publicKeyPEM, false, fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			pkg.Signature = "fake-invalid-signature!"
+
+			err = verifier.Verify(pkg, sourcePath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Decoding signature of package 'fake-pkg'"))
+		})
+	})
+
+	Context("when public key is not configured", func() {
+		var verifier PackageSignatureVerifier
+
+		BeforeEach(func() {
+			var err error
+			verifier, err = NewPackageSignatureVerifier("", false, fs)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts unsigned package", func() {
+			Expect(verifier.Verify(pkg, sourcePath)).To(Succeed())
+		})
+
+		It("returns error for signed package since signature cannot be verified", func() {
+			pkg.Signature = "ZmFrZS1zaWduYXR1cmU="
+
+			err := verifier.Verify(pkg, sourcePath)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Verifying signature of package 'fake-pkg': no public key configured"))
+		})
+	})
+
+	It("returns error when public key cannot be parsed", func() {
+		_, err := NewPackageSignatureVerifier("fake-invalid-key", false, fs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing package signing public key"))
+	})
+})
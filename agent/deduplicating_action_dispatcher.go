@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	deduplicatingActionDispatcherLogTag = "Deduplicating Action Dispatcher"
+
+	// DefaultDeduplicationWindow covers NATS redelivery of recently sent messages
+	DefaultDeduplicationWindow = 10 * time.Minute
+
+	// DefaultDeduplicationMaxEntries bounds memory used by cached responses
+	DefaultDeduplicationMaxEntries = 1000
+)
+
+type dispatchedRequest struct {
+	dispatchedAt time.Time
+	done         chan struct{}
+	resp         boshhandler.Response
+}
+
+// deduplicatingActionDispatcher returns cached response when a NATS message with
+// already seen reply-id is redelivered instead of running its action again.
+type deduplicatingActionDispatcher struct {
+	dispatcher  ActionDispatcher
+	timeService clock.Clock
+	window      time.Duration
+	maxEntries  int
+
+	requests     map[string]*dispatchedRequest
+	requestOrder []string
+	requestsLock sync.Mutex
+
+	logger boshlog.Logger
+}
+
+func NewDeduplicatingActionDispatcher(
+	dispatcher ActionDispatcher,
+	timeService clock.Clock,
+	window time.Duration,
+	maxEntries int,
+	logger boshlog.Logger,
+) ActionDispatcher {
+	return &deduplicatingActionDispatcher{
+		dispatcher:  dispatcher,
+		timeService: timeService,
+		window:      window,
+		maxEntries:  maxEntries,
+
+		requests: map[string]*dispatchedRequest{},
+
+		logger: logger,
+	}
+}
+
+func (d *deduplicatingActionDispatcher) ResumePreviouslyDispatchedTasks() {
+	d.dispatcher.ResumePreviouslyDispatchedTasks()
+}
+
+func (d *deduplicatingActionDispatcher) Dispatch(req boshhandler.Request) boshhandler.Response {
+	if req.ReplyTo == "" {
+		return d.dispatcher.Dispatch(req)
+	}
+
+	d.requestsLock.Lock()
+
+	d.expireRequests()
+
+	if dispatched, found := d.requests[req.ReplyTo]; found {
+		d.requestsLock.Unlock()
+
+		d.logger.Info(deduplicatingActionDispatcherLogTag, "Returning original response for duplicate message %s (%s)", req.ReplyTo, req.Method)
+
+		// Duplicate may arrive while original is still running
+		<-dispatched.done
+
+		return dispatched.resp
+	}
+
+	dispatched := &dispatchedRequest{
+		dispatchedAt: d.timeService.Now(),
+		done:         make(chan struct{}),
+	}
+	d.addRequest(req.ReplyTo, dispatched)
+
+	d.requestsLock.Unlock()
+
+	dispatched.resp = d.dispatcher.Dispatch(req)
+	close(dispatched.done)
+
+	return dispatched.resp
+}
+
+// expireRequests must be called with requestsLock held
+func (d *deduplicatingActionDispatcher) expireRequests() {
+	now := d.timeService.Now()
+
+	for len(d.requestOrder) > 0 {
+		oldest := d.requests[d.requestOrder[0]]
+		if now.Sub(oldest.dispatchedAt) < d.window {
+			return
+		}
+		d.removeOldestRequest()
+	}
+}
+
+// addRequest must be called with requestsLock held
+func (d *deduplicatingActionDispatcher) addRequest(replyTo string, dispatched *dispatchedRequest) {
+	for len(d.requestOrder) >= d.maxEntries && len(d.requestOrder) > 0 {
+		d.removeOldestRequest()
+	}
+
+	d.requests[replyTo] = dispatched
+	d.requestOrder = append(d.requestOrder, replyTo)
+}
+
+func (d *deduplicatingActionDispatcher) removeOldestRequest() {
+	delete(d.requests, d.requestOrder[0])
+	d.requestOrder = d.requestOrder[1:]
+}
@@ -0,0 +1,100 @@
+package agent_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/agent"
+	fakeagent "github.com/cloudfoundry/bosh-agent/agent/fakes"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("deduplicatingActionDispatcher", func() {
+		var (
+			delegate    *fakeagent.FakeActionDispatcher
+			timeService *fakeclock.FakeClock
+			dispatcher  ActionDispatcher
+		)
+
+		BeforeEach(func() {
+			delegate = &fakeagent.FakeActionDispatcher{}
+			timeService = fakeclock.NewFakeClock(time.Now())
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			dispatcher = NewDeduplicatingActionDispatcher(delegate, timeService, time.Minute, 2, logger)
+		})
+
+		It("dispatches new messages to underlying dispatcher", func() {
+			delegate.DispatchResp = boshhandler.NewValueResponse("fake-value")
+			req := boshhandler.NewRequest("fake-reply-to", "mount_disk", []byte("fake-payload"))
+
+			resp := dispatcher.Dispatch(req)
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-value")))
+			Expect(delegate.DispatchReq).To(Equal(req))
+		})
+
+		It("returns original response for a duplicate message without dispatching it again", func() {
+			delegate.DispatchResp = boshhandler.NewValueResponse("fake-original-value")
+			req := boshhandler.NewRequest("fake-reply-to", "mount_disk", []byte("fake-payload"))
+			dispatcher.Dispatch(req)
+
+			delegate.DispatchResp = boshhandler.NewValueResponse("fake-new-value")
+			resp := dispatcher.Dispatch(req)
+
+			Expect(resp).To(Equal(boshhandler.NewValueResponse("fake-original-value")))
+			Expect(delegate.DispatchCallCount).To(Equal(1))
+		})
+
+		It("dispatches messages with different reply ids", func() {
+			dispatcher.Dispatch(boshhandler.NewRequest("fake-reply-to-1", "mount_disk", []byte("fake-payload")))
+			dispatcher.Dispatch(boshhandler.NewRequest("fake-reply-to-2", "mount_disk", []byte("fake-payload")))
+
+			Expect(delegate.DispatchCallCount).To(Equal(2))
+		})
+
+		It("does not deduplicate messages without reply id", func() {
+			req := boshhandler.NewRequest("", "ping", []byte("fake-payload"))
+			dispatcher.Dispatch(req)
+			dispatcher.Dispatch(req)
+
+			Expect(delegate.DispatchCallCount).To(Equal(2))
+		})
+
+		It("dispatches duplicate message again once window passed", func() {
+			req := boshhandler.NewRequest("fake-reply-to", "mount_disk", []byte("fake-payload"))
+			dispatcher.Dispatch(req)
+
+			timeService.Increment(time.Minute)
+			dispatcher.Dispatch(req)
+
+			Expect(delegate.DispatchCallCount).To(Equal(2))
+		})
+
+		It("bounds cached responses by evicting the oldest", func() {
+			req1 := boshhandler.NewRequest("fake-reply-to-1", "mount_disk", []byte("fake-payload"))
+			req2 := boshhandler.NewRequest("fake-reply-to-2", "mount_disk", []byte("fake-payload"))
+			req3 := boshhandler.NewRequest("fake-reply-to-3", "mount_disk", []byte("fake-payload"))
+
+			dispatcher.Dispatch(req1)
+			dispatcher.Dispatch(req2)
+			dispatcher.Dispatch(req3)
+			Expect(delegate.DispatchCallCount).To(Equal(3))
+
+			dispatcher.Dispatch(req3)
+			Expect(delegate.DispatchCallCount).To(Equal(3))
+
+			dispatcher.Dispatch(req1)
+			Expect(delegate.DispatchCallCount).To(Equal(4))
+		})
+
+		It("delegates resuming previously dispatched tasks", func() {
+			dispatcher.ResumePreviouslyDispatchedTasks()
+			Expect(delegate.ResumedPreviouslyDispatchedTasks).To(BeTrue())
+		})
+	})
+}
@@ -7,8 +7,9 @@ import (
 type FakeActionDispatcher struct {
 	ResumedPreviouslyDispatchedTasks bool
 
-	DispatchReq  boshhandler.Request
-	DispatchResp boshhandler.Response
+	DispatchReq       boshhandler.Request
+	DispatchResp      boshhandler.Response
+	DispatchCallCount int
 }
 
 func (dispatcher *FakeActionDispatcher) ResumePreviouslyDispatchedTasks() {
@@ -17,5 +18,6 @@ func (dispatcher *FakeActionDispatcher) ResumePreviouslyDispatchedTasks() {
 
 func (dispatcher *FakeActionDispatcher) Dispatch(req boshhandler.Request) boshhandler.Response {
 	dispatcher.DispatchReq = req
+	dispatcher.DispatchCallCount++
 	return dispatcher.DispatchResp
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/pivotal-golang/clock"
 
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -19,6 +20,7 @@ type ConcreteJobScriptProvider struct {
 	dirProvider          boshdir.Provider
 	scriptCommandFactory boshsys.ScriptCommandFactory
 	timeService          clock.Clock
+	settingsService      boshsettings.Service
 	logger               boshlog.Logger
 }
 
@@ -28,6 +30,7 @@ func NewConcreteJobScriptProvider(
 	dirProvider boshdir.Provider,
 	scriptCommandFactory boshsys.ScriptCommandFactory,
 	timeService clock.Clock,
+	settingsService boshsettings.Service,
 	logger boshlog.Logger,
 ) ConcreteJobScriptProvider {
 	return ConcreteJobScriptProvider{
@@ -36,6 +39,7 @@ func NewConcreteJobScriptProvider(
 		dirProvider:          dirProvider,
 		scriptCommandFactory: scriptCommandFactory,
 		timeService:          timeService,
+		settingsService:      settingsService,
 		logger:               logger,
 	}
 }
@@ -49,13 +53,17 @@ func (p ConcreteJobScriptProvider) NewScript(jobName string, scriptName string)
 	stderrLogFilename := fmt.Sprintf("%s.stderr.log", scriptName)
 	stderrLogPath := filepath.Join(p.dirProvider.LogsDir(), jobName, stderrLogFilename)
 
-	return NewScript(p.fs, p.cmdRunner, p.scriptCommandFactory, jobName, path, stdoutLogPath, stderrLogPath)
+	customEnv := p.settingsService.GetSettings().Env.Custom
+
+	return NewScript(p.fs, p.cmdRunner, p.scriptCommandFactory, jobName, path, stdoutLogPath, stderrLogPath, customEnv)
 }
 
 func (p ConcreteJobScriptProvider) NewDrainScript(jobName string, params boshdrain.ScriptParams) CancellableScript {
 	path := path.Join(p.dirProvider.JobsDir(), jobName, "bin", "drain"+p.scriptCommandFactory.Extension())
 
-	return boshdrain.NewConcreteScript(p.fs, p.cmdRunner, p.scriptCommandFactory, jobName, path, params, p.timeService, p.logger)
+	customEnv := p.settingsService.GetSettings().Env.Custom
+
+	return boshdrain.NewConcreteScript(p.fs, p.cmdRunner, p.scriptCommandFactory, jobName, path, params, customEnv, p.timeService, p.logger)
 }
 
 func (p ConcreteJobScriptProvider) NewParallelScript(scriptName string, scripts []Script) CancellableScript {
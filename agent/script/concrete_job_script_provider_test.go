@@ -9,15 +9,18 @@ import (
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
 	fakedrain "github.com/cloudfoundry/bosh-agent/agent/script/drain/fakes"
 	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("ConcreteJobScriptProvider", func() {
 	var (
-		logger         boshlog.Logger
-		scriptProvider boshscript.ConcreteJobScriptProvider
+		logger          boshlog.Logger
+		settingsService *fakesettings.FakeSettingsService
+		scriptProvider  boshscript.ConcreteJobScriptProvider
 	)
 
 	BeforeEach(func() {
@@ -25,12 +28,14 @@ var _ = Describe("ConcreteJobScriptProvider", func() {
 		fs := fakesys.NewFakeFileSystem()
 		dirProvider := boshdir.NewProvider("/the/base/dir")
 		logger = boshlog.NewLogger(boshlog.LevelNone)
+		settingsService = &fakesettings.FakeSettingsService{}
 		scriptProvider = boshscript.NewConcreteJobScriptProvider(
 			runner,
 			fs,
 			dirProvider,
 			&fakesys.FakeCommandFactory{},
 			&fakeaction.FakeClock{},
+			settingsService,
 			logger,
 		)
 	})
@@ -41,6 +46,25 @@ var _ = Describe("ConcreteJobScriptProvider", func() {
 			Expect(script.Tag()).To(Equal("myjob"))
 			Expect(script.Path()).To(Equal("/the/base/dir/jobs/myjob/bin/the-best-hook-ever"))
 		})
+
+		It("returns script with custom environment from settings", func() {
+			runner := fakesys.NewFakeCmdRunner()
+			settingsService.Settings.Env.Custom = boshsettings.CustomEnv{"FOO": "bar"}
+			scriptProvider = boshscript.NewConcreteJobScriptProvider(
+				runner,
+				fakesys.NewFakeFileSystem(),
+				boshdir.NewProvider("/the/base/dir"),
+				&fakesys.FakeCommandFactory{},
+				&fakeaction.FakeClock{},
+				settingsService,
+				logger,
+			)
+
+			err := scriptProvider.NewScript("myjob", "the-best-hook-ever").Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.RunComplexCommands).To(HaveLen(1))
+			Expect(runner.RunComplexCommands[0].Env).To(HaveKeyWithValue("FOO", "bar"))
+		})
 	})
 
 	Describe("NewDrainScript", func() {
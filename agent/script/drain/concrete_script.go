@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -20,6 +21,8 @@ type ConcreteScript struct {
 	path   string
 	params ScriptParams
 
+	customEnv boshsettings.CustomEnv
+
 	timeService clock.Clock
 	logTag      string
 	logger      boshlog.Logger
@@ -34,6 +37,7 @@ func NewConcreteScript(
 	tag string,
 	path string,
 	params ScriptParams,
+	customEnv boshsettings.CustomEnv,
 	timeService clock.Clock,
 	logger boshlog.Logger,
 ) ConcreteScript {
@@ -46,6 +50,8 @@ func NewConcreteScript(
 		path:   path,
 		params: params,
 
+		customEnv: customEnv,
+
 		timeService: timeService,
 
 		logTag: "DrainScript",
@@ -92,7 +98,8 @@ func (s ConcreteScript) runOnce(params ScriptParams) (int, error) {
 
 	command := s.scriptCommandFactory.New(s.path)
 	command.Env = map[string]string{
-		"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
+		"PATH":          "/usr/sbin:/usr/bin:/sbin:/bin",
+		"BOSH_JOB_NAME": s.tag,
 	}
 
 	jobState, err := params.JobState()
@@ -113,6 +120,11 @@ func (s ConcreteScript) runOnce(params ScriptParams) (int, error) {
 		command.Env["BOSH_JOB_NEXT_STATE"] = jobNextState
 	}
 
+	err = s.customEnv.Apply(command.Env)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Setting custom environment")
+	}
+
 	command.Args = append(command.Args, jobChange, hashChange)
 	command.Args = append(command.Args, updatedPkgs...)
 
@@ -11,6 +11,7 @@ import (
 	"github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	. "github.com/cloudfoundry/bosh-agent/agent/script/drain"
 	"github.com/cloudfoundry/bosh-agent/agent/script/drain/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -25,6 +26,7 @@ var _ = Describe("ConcreteScript", func() {
 		script               ConcreteScript
 		exampleSpec          func() applyspec.V1ApplySpec
 		scriptCommandFactory boshsys.ScriptCommandFactory
+		customEnv            boshsettings.CustomEnv
 	)
 
 	BeforeEach(func() {
@@ -33,11 +35,12 @@ var _ = Describe("ConcreteScript", func() {
 		params = &fakes.FakeScriptParams{}
 		fakeClock = &fakeaction.FakeClock{}
 		scriptCommandFactory = boshsys.NewScriptCommandFactory("linux")
+		customEnv = nil
 	})
 
 	JustBeforeEach(func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		script = NewConcreteScript(fs, runner, scriptCommandFactory, "my-tag", "/fake/script", params, fakeClock, logger)
+		script = NewConcreteScript(fs, runner, scriptCommandFactory, "my-tag", "/fake/script", params, customEnv, fakeClock, logger)
 	})
 
 	Describe("Tag", func() {
@@ -95,6 +98,7 @@ var _ = Describe("ConcreteScript", func() {
 				Args: []string{"job_unchanged", "hash_unchanged", "bar", "foo"},
 				Env: map[string]string{
 					"PATH":                "/usr/sbin:/usr/bin:/sbin:/bin",
+					"BOSH_JOB_NAME":       "my-tag",
 					"BOSH_JOB_STATE":      "{\"persistent_disk\":42}",
 					"BOSH_JOB_NEXT_STATE": "{\"persistent_disk\":42}",
 				},
@@ -163,6 +167,58 @@ var _ = Describe("ConcreteScript", func() {
 			Expect(err).To(HaveOccurred())
 		})
 
+		Describe("job name", func() {
+			BeforeEach(func() {
+				runner.AddProcess("/fake/script job_unchanged hash_unchanged bar foo",
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: "1"}})
+			})
+
+			It("sets the BOSH_JOB_NAME env variable to the job the script belongs to", func() {
+				err := script.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(len(runner.RunComplexCommands)).To(Equal(1))
+				Expect(runner.RunComplexCommands[0].Env["BOSH_JOB_NAME"]).To(Equal("my-tag"))
+			})
+		})
+
+		Describe("custom environment", func() {
+			BeforeEach(func() {
+				runner.AddProcess("/fake/script job_unchanged hash_unchanged bar foo",
+					&fakesys.FakeProcess{WaitResult: boshsys.Result{Stdout: "1"}})
+			})
+
+			Context("when custom variables are configured", func() {
+				BeforeEach(func() {
+					customEnv = boshsettings.CustomEnv{"HTTP_PROXY": "http://fake-proxy:3128"}
+				})
+
+				It("adds them to the script environment", func() {
+					err := script.Run()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(len(runner.RunComplexCommands)).To(Equal(1))
+					env := runner.RunComplexCommands[0].Env
+					Expect(env["HTTP_PROXY"]).To(Equal("http://fake-proxy:3128"))
+					Expect(env["BOSH_JOB_NAME"]).To(Equal("my-tag"))
+				})
+			})
+
+			Context("when custom variables use reserved names", func() {
+				BeforeEach(func() {
+					customEnv = boshsettings.CustomEnv{"BOSH_JOB_NAME": "other-job"}
+				})
+
+				It("returns an error without running the script", func() {
+					err := script.Run()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Setting custom environment"))
+
+					Expect(runner.RunComplexCommands).To(BeEmpty())
+				})
+			})
+		})
+
 		Describe("job state", func() {
 			BeforeEach(func() {
 				runner.AddProcess("/fake/script job_unchanged hash_unchanged bar foo",
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -22,6 +23,8 @@ type GenericScript struct {
 
 	stdoutLogPath string
 	stderrLogPath string
+
+	customEnv boshsettings.CustomEnv
 }
 
 func NewScript(
@@ -32,6 +35,7 @@ func NewScript(
 	path string,
 	stdoutLogPath string,
 	stderrLogPath string,
+	customEnv boshsettings.CustomEnv,
 ) GenericScript {
 	return GenericScript{
 		fs:                   fs,
@@ -43,6 +47,8 @@ func NewScript(
 
 		stdoutLogPath: stdoutLogPath,
 		stderrLogPath: stderrLogPath,
+
+		customEnv: customEnv,
 	}
 }
 
@@ -51,7 +57,17 @@ func (s GenericScript) Path() string { return s.path }
 func (s GenericScript) Exists() bool { return s.fs.FileExists(s.path) }
 
 func (s GenericScript) Run() error {
-	err := s.ensureContainingDir(s.stdoutLogPath)
+	command := s.scriptCommandFactory.New(s.path)
+	command.Env = map[string]string{
+		"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
+	}
+
+	err := s.customEnv.Apply(command.Env)
+	if err != nil {
+		return err
+	}
+
+	err = s.ensureContainingDir(s.stdoutLogPath)
 	if err != nil {
 		return err
 	}
@@ -77,10 +93,6 @@ func (s GenericScript) Run() error {
 		_ = stderrFile.Close()
 	}()
 
-	command := s.scriptCommandFactory.New(s.path)
-	command.Env = map[string]string{
-		"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
-	}
 	command.Stdout = stdoutFile
 	command.Stderr = stderrFile
 
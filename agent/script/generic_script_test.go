@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
@@ -19,6 +20,7 @@ var _ = Describe("GenericScript", func() {
 		genericScript        boshscript.GenericScript
 		stdoutLogPath        string
 		stderrLogPath        string
+		customEnv            boshsettings.CustomEnv
 	)
 
 	BeforeEach(func() {
@@ -27,6 +29,10 @@ var _ = Describe("GenericScript", func() {
 		scriptCommandFactory = &fakesys.FakeCommandFactory{}
 		stdoutLogPath = filepath.Join("base", "stdout", "logdir", "stdout.log")
 		stderrLogPath = filepath.Join("base", "stderr", "logdir", "stderr.log")
+		customEnv = nil
+	})
+
+	JustBeforeEach(func() {
 		genericScript = boshscript.NewScript(
 			fs,
 			cmdRunner,
@@ -35,6 +41,7 @@ var _ = Describe("GenericScript", func() {
 			"/path-to-script",
 			stdoutLogPath,
 			stderrLogPath,
+			customEnv,
 		)
 	})
 
@@ -81,6 +88,47 @@ var _ = Describe("GenericScript", func() {
 			Expect(err.Error()).To(Equal("fake-open-file-error"))
 		})
 
+		It("runs command with default environment", func() {
+			err := genericScript.Run()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+			Expect(cmdRunner.RunComplexCommands[0].Env).To(Equal(map[string]string{
+				"PATH": "/usr/sbin:/usr/bin:/sbin:/bin",
+			}))
+		})
+
+		Context("when custom environment is configured", func() {
+			BeforeEach(func() {
+				customEnv = boshsettings.CustomEnv{"HTTP_PROXY": "http://fake-proxy:3128"}
+			})
+
+			It("adds custom variables to command environment", func() {
+				err := genericScript.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cmdRunner.RunComplexCommands).To(HaveLen(1))
+				Expect(cmdRunner.RunComplexCommands[0].Env).To(Equal(map[string]string{
+					"PATH":       "/usr/sbin:/usr/bin:/sbin:/bin",
+					"HTTP_PROXY": "http://fake-proxy:3128",
+				}))
+			})
+		})
+
+		Context("when custom environment uses reserved names", func() {
+			BeforeEach(func() {
+				customEnv = boshsettings.CustomEnv{"BOSH_JOB_NAME": "fake-job"}
+			})
+
+			It("returns an error without running the command", func() {
+				err := genericScript.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("reserved prefix 'BOSH_'"))
+
+				Expect(cmdRunner.RunComplexCommands).To(BeEmpty())
+			})
+		})
+
 		Context("when command succeeds", func() {
 			BeforeEach(func() {
 				cmdRunner.AddCmdResult("/path-to-script", fakesys.FakeCmdResult{
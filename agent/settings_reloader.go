@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"os"
+	"reflect"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type SettingsReloader interface {
+	// Run reloads settings each time a signal is received until signalChannel is closed
+	Run(signalChannel <-chan os.Signal)
+	Reload() error
+}
+
+type settingsReloader struct {
+	settingsService boshsettings.Service
+	platform        boshplatform.Platform
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewSettingsReloader(
+	settingsService boshsettings.Service,
+	platform boshplatform.Platform,
+	logger boshlog.Logger,
+) SettingsReloader {
+	return settingsReloader{
+		settingsService: settingsService,
+		platform:        platform,
+
+		logTag: "settingsReloader",
+		logger: logger,
+	}
+}
+
+func (r settingsReloader) Run(signalChannel <-chan os.Signal) {
+	for range signalChannel {
+		r.logger.Info(r.logTag, "Received signal, reloading settings")
+
+		// Failing to reload must not take down running agent
+		err := r.Reload()
+		if err != nil {
+			r.logger.Error(r.logTag, "Reloading settings: %s", err.Error())
+		}
+	}
+}
+
+func (r settingsReloader) Reload() error {
+	oldSettings := r.settingsService.GetSettings()
+
+	// Loading settings also persists them to disk
+	err := r.settingsService.LoadSettings()
+	if err != nil {
+		return bosherr.WrapError(err, "Fetching settings")
+	}
+
+	newSettings := r.settingsService.GetSettings()
+
+	err = newSettings.Env.Custom.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating custom environment")
+	}
+
+	if !reflect.DeepEqual(oldSettings.Networks, newSettings.Networks) {
+		r.logger.Info(r.logTag, "Networks changed, setting up networking")
+
+		err = r.platform.SetupNetworking(newSettings.Networks)
+		if err != nil {
+			return bosherr.WrapError(err, "Setting up networking")
+		}
+	}
+
+	if !reflect.DeepEqual(oldSettings.Disks, newSettings.Disks) {
+		r.logger.Info(r.logTag, "Disks changed, setting up disks")
+
+		err = r.convergeDisks(oldSettings, newSettings)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r settingsReloader) convergeDisks(oldSettings, settings boshsettings.Settings) error {
+	// Detached disks are unmounted first since newly attached disk
+	// might need to be mounted at the same path
+	err := r.unmountDetachedPersistentDisks(oldSettings, settings)
+	if err != nil {
+		return err
+	}
+
+	err = r.platform.SetupRawEphemeralDisks(settings.RawEphemeralDiskSettings())
+	if err != nil {
+		return bosherr.WrapError(err, "Setting up raw ephemeral disk")
+	}
+
+	ephemeralDiskSettings := settings.EphemeralDiskSettings()
+	ephemeralDiskPath := r.platform.GetEphemeralDiskPath(ephemeralDiskSettings)
+
+	err = r.platform.SetupEphemeralDiskWithPath(ephemeralDiskPath, ephemeralDiskSettings.FileSystemType, settings.Env.GetDisableSwap())
+	if err != nil {
+		return bosherr.WrapError(err, "Setting up ephemeral disk")
+	}
+
+	diskIDs, ok := mountablePersistentDiskIDs(r.platform, settings)
+	if !ok {
+		return bosherr.Error("Mounting persistent disk, there is more than one persistent disk")
+	}
+
+	for _, diskID := range diskIDs {
+		err = settings.ValidatePersistentDisk(diskID)
+		if err != nil {
+			return bosherr.WrapError(err, "Validating persistent disk settings")
+		}
+
+		diskSettings, _ := settings.PersistentDiskSettings(diskID)
+
+		isMounted, err := r.platform.IsPersistentDiskMounted(diskSettings)
+		if err != nil {
+			return bosherr.WrapError(err, "Checking if persistent disk is mounted")
+		}
+
+		if isMounted {
+			continue
+		}
+
+		isMountable, err := r.platform.IsPersistentDiskMountable(diskSettings)
+		if err != nil {
+			return bosherr.WrapError(err, "Checking if persistent disk is partitioned")
+		}
+
+		if isMountable {
+			err = r.platform.MountPersistentDisk(diskSettings, r.platform.GetPersistentDiskMountPoint(diskID))
+			if err != nil {
+				return bosherr.WrapError(err, "Mounting persistent disk")
+			}
+		}
+	}
+
+	return nil
+}
+
+// unmountDetachedPersistentDisks unmounts persistent disks whose settings
+// were removed by the director after detaching them
+func (r settingsReloader) unmountDetachedPersistentDisks(oldSettings, settings boshsettings.Settings) error {
+	for diskID := range oldSettings.Disks.Persistent {
+		if _, found := settings.Disks.Persistent[diskID]; found {
+			continue
+		}
+
+		// Device of detached disk is usually gone hence only mount table is used
+		mountPoint := r.platform.GetPersistentDiskMountPoint(diskID)
+
+		_, isMountPoint, err := r.platform.IsMountPoint(mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking if detached persistent disk '%s' is mounted", diskID)
+		}
+
+		if !isMountPoint {
+			continue
+		}
+
+		r.logger.Info(r.logTag, "Unmounting detached persistent disk '%s'", diskID)
+
+		_, err = r.platform.UnmountPersistentDiskMountPoint(mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Unmounting detached persistent disk '%s'", diskID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package agent_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent"
+	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("SettingsReloader", func() {
+		var (
+			platform        *fakeplatform.FakePlatform
+			settingsSource  *fakeinf.FakeSettingsSource
+			settingsService boshsettings.Service
+			reloader        SettingsReloader
+		)
+
+		BeforeEach(func() {
+			platform = fakeplatform.NewFakePlatform()
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+
+			settingsSource = &fakeinf.FakeSettingsSource{
+				SettingsValue: boshsettings.Settings{
+					AgentID: "fake-agent-id",
+					Networks: boshsettings.Networks{
+						"fake-net": boshsettings.Network{Type: "manual", IP: "1.1.1.1", Netmask: "255.255.255.0"},
+					},
+				},
+			}
+
+			settingsService = boshsettings.NewService(
+				platform.GetFs(),
+				"/var/vcap/bosh/settings.json",
+				settingsSource,
+				platform,
+				logger,
+			)
+			Expect(settingsService.LoadSettings()).To(Succeed())
+
+			reloader = NewSettingsReloader(settingsService, platform, logger)
+		})
+
+		Describe("Reload", func() {
+			It("persists reloaded settings", func() {
+				settingsSource.SettingsValue.AgentID = "fake-new-agent-id"
+
+				err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(settingsService.GetSettings().AgentID).To(Equal("fake-new-agent-id"))
+
+				contents, err := platform.GetFs().ReadFileString("/var/vcap/bosh/settings.json")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(contents).To(ContainSubstring("fake-new-agent-id"))
+			})
+
+			It("does not converge networks or disks when they did not change", func() {
+				settingsSource.SettingsValue.AgentID = "fake-new-agent-id"
+
+				err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(platform.SetupNetworkingCalled).To(BeFalse())
+				Expect(platform.GetEphemeralDiskPathCalled).To(BeFalse())
+			})
+
+			It("sets up networking when networks changed", func() {
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{
+					"fake-net": boshsettings.Network{Type: "manual", IP: "2.2.2.2", Netmask: "255.255.255.0"},
+				}
+
+				err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(platform.SetupNetworkingCalled).To(BeTrue())
+				Expect(platform.SetupNetworkingNetworks).To(Equal(settingsSource.SettingsValue.Networks))
+			})
+
+			It("returns an error without converging when custom environment uses reserved name", func() {
+				settingsSource.SettingsValue.Env.Custom = boshsettings.CustomEnv{"LD_PRELOAD": "/fake-lib.so"}
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{}
+
+				err := reloader.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Custom environment variable 'LD_PRELOAD' is reserved"))
+				Expect(platform.SetupNetworkingCalled).To(BeFalse())
+			})
+
+			It("returns an error when setting up networking fails", func() {
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{}
+				platform.SetupNetworkingErr = errors.New("fake-network-err")
+
+				err := reloader.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-network-err"))
+			})
+
+			Context("when disks changed", func() {
+				BeforeEach(func() {
+					platform.GetEphemeralDiskPathRealPath = "/dev/sdb"
+					settingsSource.SettingsValue.Disks = boshsettings.Disks{
+						Ephemeral:  "/dev/sdb",
+						Persistent: map[string]interface{}{"fake-disk-id": "/dev/sdc"},
+					}
+				})
+
+				It("sets up ephemeral disk and mounts persistent disk", func() {
+					platform.SetIsPersistentDiskMountable(true, nil)
+
+					err := reloader.Reload()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(platform.SetupEphemeralDiskWithPathDevicePath).To(Equal("/dev/sdb"))
+					Expect(platform.MountPersistentDiskCalled).To(BeTrue())
+					Expect(platform.MountPersistentDiskSettings.Path).To(Equal("/dev/sdc"))
+					Expect(platform.MountPersistentDiskMountPoint).To(Equal("/var/vcap/store"))
+				})
+
+				It("does not remount an already mounted persistent disk", func() {
+					platform.SetIsPersistentDiskMountable(true, nil)
+					platform.MountedDevicePaths = []string{"/dev/sdc"}
+
+					err := reloader.Reload()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(platform.MountPersistentDiskCalled).To(BeFalse())
+				})
+
+				It("returns an error when setting up ephemeral disk fails", func() {
+					platform.SetupEphemeralDiskWithPathErr = errors.New("fake-ephemeral-err")
+
+					err := reloader.Reload()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-ephemeral-err"))
+				})
+			})
+
+			Context("when persistent disk settings were removed after detaching the disk", func() {
+				BeforeEach(func() {
+					settingsSource.SettingsValue.Disks = boshsettings.Disks{
+						Persistent: map[string]interface{}{
+							"fake-old-disk-id": "/dev/sdc",
+							"fake-kept-disk-id": map[string]interface{}{
+								"path": "/dev/sdd",
+							},
+						},
+					}
+					Expect(settingsService.LoadSettings()).To(Succeed())
+
+					settingsSource.SettingsValue.Disks = boshsettings.Disks{
+						Persistent: map[string]interface{}{
+							"fake-kept-disk-id": map[string]interface{}{
+								"path": "/dev/sdd",
+							},
+						},
+					}
+					platform.PersistentDiskMountPoints = map[string]string{
+						"fake-old-disk-id":  "/var/vcap/stores/fake-old-disk-id",
+						"fake-kept-disk-id": "/var/vcap/stores/fake-kept-disk-id",
+					}
+				})
+
+				It("unmounts previously mounted disk found in mount table even though its device is gone", func() {
+					platform.MountedDevicePaths = []string{"/dev/sdd"}
+					platform.IsMountPointResult = true
+
+					err := reloader.Reload()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(platform.IsMountPointPath).To(Equal("/var/vcap/stores/fake-old-disk-id"))
+					Expect(platform.UnmountPersistentDiskMountPointPath).To(Equal("/var/vcap/stores/fake-old-disk-id"))
+					Expect(platform.UnmountPersistentDiskSettings).To(Equal(boshsettings.DiskSettings{}))
+					Expect(platform.MountPersistentDiskCalled).To(BeFalse())
+				})
+
+				It("does not unmount disk whose mount point is not in mount table", func() {
+					platform.MountedDevicePaths = []string{"/dev/sdc", "/dev/sdd"}
+					platform.IsMountPointResult = false
+
+					err := reloader.Reload()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(platform.IsMountPointPath).To(Equal("/var/vcap/stores/fake-old-disk-id"))
+					Expect(platform.UnmountPersistentDiskMountPointPath).To(BeEmpty())
+				})
+
+				It("returns an error when checking mount table fails", func() {
+					platform.IsMountPointErr = errors.New("fake-mount-point-err")
+
+					err := reloader.Reload()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Checking if detached persistent disk 'fake-old-disk-id' is mounted"))
+					Expect(err.Error()).To(ContainSubstring("fake-mount-point-err"))
+				})
+
+				It("returns an error when unmounting fails", func() {
+					platform.MountedDevicePaths = []string{"/dev/sdd"}
+					platform.IsMountPointResult = true
+					platform.UnmountPersistentDiskMountPointErr = errors.New("fake-unmount-err")
+
+					err := reloader.Reload()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Unmounting detached persistent disk 'fake-old-disk-id'"))
+					Expect(err.Error()).To(ContainSubstring("fake-unmount-err"))
+				})
+			})
+
+			It("keeps persisted settings without converging when fetching settings fails", func() {
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{}
+				settingsSource.SettingsErr = errors.New("fake-settings-err")
+
+				err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(settingsService.GetSettings().AgentID).To(Equal("fake-agent-id"))
+				Expect(platform.SetupNetworkingCalled).To(BeFalse())
+			})
+		})
+
+		Describe("Run", func() {
+			It("reloads settings and converges on each signal", func() {
+				signalChannel := make(chan os.Signal, 1)
+				done := make(chan struct{})
+				go func() {
+					reloader.Run(signalChannel)
+					close(done)
+				}()
+
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{}
+				signalChannel <- syscall.SIGHUP
+				close(signalChannel)
+
+				Eventually(done).Should(BeClosed())
+				Expect(platform.SetupNetworkingCalled).To(BeTrue())
+			})
+
+			It("keeps running when reloading fails", func() {
+				signalChannel := make(chan os.Signal, 2)
+				done := make(chan struct{})
+				go func() {
+					reloader.Run(signalChannel)
+					close(done)
+				}()
+
+				settingsSource.SettingsValue.Networks = boshsettings.Networks{}
+				platform.SetupNetworkingErr = errors.New("fake-network-err")
+				signalChannel <- syscall.SIGHUP
+				signalChannel <- syscall.SIGHUP
+				close(signalChannel)
+
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type AgentState struct {
+	TaskResults map[string]TaskResult `json:"task_results,omitempty"`
+}
+
+type TaskResult struct {
+	State string      `json:"state"`
+	Value interface{} `json:"value,omitempty"`
+	Error string      `json:"error,omitempty"`
+
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+type Store interface {
+	Get() AgentState
+
+	// Update persists state changed by updateFunc;
+	// state is not changed if it cannot be persisted
+	Update(updateFunc func(*AgentState)) error
+}
+
+type fileStore struct {
+	fs   boshsys.FileSystem
+	path string
+
+	stateLock sync.RWMutex
+	state     AgentState
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewFileStore loads previously saved state from path.
+// Corrupt state file is ignored so that agent can start fresh.
+func NewFileStore(fs boshsys.FileSystem, path string, logger boshlog.Logger) (Store, error) {
+	store := &fileStore{
+		fs:     fs,
+		path:   path,
+		logTag: "agentState",
+		logger: logger,
+	}
+
+	if !fs.FileExists(path) {
+		return store, nil
+	}
+
+	bytes, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading agent state file")
+	}
+
+	err = json.Unmarshal(bytes, &store.state)
+	if err != nil {
+		logger.Error(store.logTag, "Ignoring corrupt agent state file %s: %s", path, err.Error())
+		store.state = AgentState{}
+	}
+
+	return store, nil
+}
+
+func (s *fileStore) Get() AgentState {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+
+	return s.state.copy()
+}
+
+func (s *fileStore) Update(updateFunc func(*AgentState)) error {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+
+	newState := s.state.copy()
+	updateFunc(&newState)
+
+	err := s.write(newState)
+	if err != nil {
+		return err
+	}
+
+	s.state = newState
+
+	return nil
+}
+
+// write replaces state file via rename so that
+// readers never see partially written state
+func (s *fileStore) write(state AgentState) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling agent state")
+	}
+
+	tmpPath := s.path + ".tmp"
+
+	err = s.fs.WriteFile(tmpPath, bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing agent state to temporary file")
+	}
+
+	err = s.fs.Rename(tmpPath, s.path)
+	if err != nil {
+		_ = s.fs.RemoveAll(tmpPath)
+		return bosherr.WrapError(err, "Replacing agent state file")
+	}
+
+	return nil
+}
+
+func (s AgentState) copy() AgentState {
+	if s.TaskResults != nil {
+		taskResults := make(map[string]TaskResult, len(s.TaskResults))
+		for taskID, result := range s.TaskResults {
+			taskResults[taskID] = result
+		}
+		s.TaskResults = taskResults
+	}
+
+	return s
+}
@@ -0,0 +1,156 @@
+package state_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/state"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileStore", func() {
+	var (
+		fs     *fakesys.FakeFileSystem
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	Describe("NewFileStore", func() {
+		It("starts with empty state when state file does not exist", func() {
+			store, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Get()).To(Equal(AgentState{}))
+		})
+
+		It("loads previously saved state", func() {
+			fs.WriteFileString("/fake-state.json", `{"task_results":{"fake-task-id":{"state":"done","value":"fake-value"}}}`)
+
+			store, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Get()).To(Equal(AgentState{
+				TaskResults: map[string]TaskResult{
+					"fake-task-id": TaskResult{State: "done", Value: "fake-value"},
+				},
+			}))
+		})
+
+		It("starts with empty state when state file is corrupt", func() {
+			fs.WriteFileString("/fake-state.json", `{"task_results":`)
+
+			store, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Get()).To(Equal(AgentState{}))
+		})
+
+		It("returns error when state file cannot be read", func() {
+			fs.WriteFileString("/fake-state.json", `{}`)
+			fs.ReadFileError = errors.New("fake-read-err")
+
+			_, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+		})
+	})
+
+	Describe("Update", func() {
+		var store Store
+
+		BeforeEach(func() {
+			var err error
+			store, err = NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("saves state that is loaded on next start", func() {
+			err := store.Update(func(state *AgentState) {
+				state.TaskResults = map[string]TaskResult{
+					"fake-task-id": TaskResult{State: "failed", Error: "fake-task-err"},
+				}
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			reloadedStore, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reloadedStore.Get()).To(Equal(store.Get()))
+			Expect(reloadedStore.Get().TaskResults["fake-task-id"].Error).To(Equal("fake-task-err"))
+		})
+
+		It("replaces state file via temporary file", func() {
+			err := store.Update(func(state *AgentState) {
+				state.TaskResults = map[string]TaskResult{"fake-task-id": TaskResult{State: "done"}}
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.RenameOldPaths).To(Equal([]string{"/fake-state.json.tmp"}))
+			Expect(fs.RenameNewPaths).To(Equal([]string{"/fake-state.json"}))
+			Expect(fs.FileExists("/fake-state.json.tmp")).To(BeFalse())
+		})
+
+		It("keeps previous state and state file when state cannot be written", func() {
+			fs.WriteFileString("/fake-state.json", `{}`)
+			fs.RenameError = errors.New("fake-rename-err")
+
+			err := store.Update(func(state *AgentState) {
+				state.TaskResults = map[string]TaskResult{"fake-task-id": TaskResult{State: "done"}}
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-rename-err"))
+
+			Expect(store.Get()).To(Equal(AgentState{}))
+
+			contents, err := fs.ReadFileString("/fake-state.json")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(Equal(`{}`))
+		})
+
+		It("does not let callers modify state without updating it", func() {
+			err := store.Update(func(state *AgentState) {
+				state.TaskResults = map[string]TaskResult{"fake-task-id": TaskResult{State: "done"}}
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			store.Get().TaskResults["fake-task-id"] = TaskResult{State: "failed"}
+
+			Expect(store.Get().TaskResults["fake-task-id"].State).To(Equal("done"))
+		})
+
+		It("is safe to update concurrently", func() {
+			wg := &sync.WaitGroup{}
+
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					err := store.Update(func(state *AgentState) {
+						if state.TaskResults == nil {
+							state.TaskResults = map[string]TaskResult{}
+						}
+						state.TaskResults[fmt.Sprintf("fake-task-id-%d", i)] = TaskResult{State: "done"}
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					_ = store.Get()
+				}(i)
+			}
+
+			wg.Wait()
+
+			Expect(store.Get().TaskResults).To(HaveLen(50))
+
+			reloadedStore, err := NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reloadedStore.Get().TaskResults).To(HaveLen(50))
+		})
+	})
+})
@@ -0,0 +1,128 @@
+package task
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/pivotal-golang/clock"
+
+	boshagentstate "github.com/cloudfoundry/bosh-agent/agent/state"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// MaxPersistedTaskResults limits number of finished task results kept in agent state
+const MaxPersistedTaskResults = 100
+
+type persistentTaskService struct {
+	service Service
+	store   boshagentstate.Store
+	clock   clock.Clock
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewPersistentTaskService records results of finished tasks in agent state
+// so that they can still be found after agent restarts
+func NewPersistentTaskService(
+	service Service,
+	store boshagentstate.Store,
+	clock clock.Clock,
+	logger boshlog.Logger,
+) Service {
+	return persistentTaskService{
+		service: service,
+		store:   store,
+		clock:   clock,
+		logTag:  "persistentTaskService",
+		logger:  logger,
+	}
+}
+
+func (s persistentTaskService) CreateTask(taskFunc Func, cancelFunc CancelFunc, endFunc EndFunc) (Task, error) {
+	return s.service.CreateTask(taskFunc, cancelFunc, s.persistingEndFunc(endFunc))
+}
+
+func (s persistentTaskService) CreateTaskWithID(id string, taskFunc Func, cancelFunc CancelFunc, endFunc EndFunc) Task {
+	return s.service.CreateTaskWithID(id, taskFunc, cancelFunc, s.persistingEndFunc(endFunc))
+}
+
+func (s persistentTaskService) StartTask(task Task) {
+	s.service.StartTask(task)
+}
+
+func (s persistentTaskService) FindTaskWithID(id string) (Task, bool) {
+	task, found := s.service.FindTaskWithID(id)
+	if found {
+		return task, true
+	}
+
+	result, found := s.store.Get().TaskResults[id]
+	if !found {
+		return Task{}, false
+	}
+
+	task = Task{ID: id, State: State(result.State), Value: result.Value}
+
+	if result.Error != "" {
+		task.Error = errors.New(result.Error)
+	}
+
+	return task, true
+}
+
+func (s persistentTaskService) persistingEndFunc(endFunc EndFunc) EndFunc {
+	return func(task Task) {
+		s.persist(task)
+
+		if endFunc != nil {
+			endFunc(task)
+		}
+	}
+}
+
+// persist only logs failures since task itself has already finished
+func (s persistentTaskService) persist(task Task) {
+	result := boshagentstate.TaskResult{
+		State:      string(task.State),
+		Value:      task.Value,
+		FinishedAt: s.clock.Now(),
+	}
+
+	if task.Error != nil {
+		result.Error = task.Error.Error()
+	}
+
+	err := s.store.Update(func(state *boshagentstate.AgentState) {
+		if state.TaskResults == nil {
+			state.TaskResults = map[string]boshagentstate.TaskResult{}
+		}
+
+		state.TaskResults[task.ID] = result
+
+		pruneTaskResults(state.TaskResults)
+	})
+	if err != nil {
+		s.logger.Warn(s.logTag, "Failed to persist result of task #%s: %s", task.ID, err.Error())
+	}
+}
+
+// pruneTaskResults removes oldest results beyond MaxPersistedTaskResults
+func pruneTaskResults(results map[string]boshagentstate.TaskResult) {
+	if len(results) <= MaxPersistedTaskResults {
+		return
+	}
+
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return results[ids[i]].FinishedAt.Before(results[ids[j]].FinishedAt)
+	})
+
+	for _, id := range ids[:len(ids)-MaxPersistedTaskResults] {
+		delete(results, id)
+	}
+}
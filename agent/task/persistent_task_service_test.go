@@ -0,0 +1,130 @@
+package task_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshagentstate "github.com/cloudfoundry/bosh-agent/agent/state"
+	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+func init() {
+	Describe("persistentTaskService", func() {
+		var (
+			fs        *fakesys.FakeFileSystem
+			fakeClock *fakeclock.FakeClock
+			logger    boshlog.Logger
+			service   Service
+		)
+
+		newService := func() Service {
+			store, err := boshagentstate.NewFileStore(fs, "/fake-state.json", logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			return NewPersistentTaskService(faketask.NewFakeService(), store, fakeClock, logger)
+		}
+
+		finishTask := func(id string, value interface{}, err error) {
+			task := service.CreateTaskWithID(id, nil, nil, nil)
+			task.Value = value
+			task.Error = err
+			task.State = StateDone
+			if err != nil {
+				task.State = StateFailed
+			}
+
+			task.EndFunc(task)
+		}
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			service = newService()
+		})
+
+		It("finds tasks known to inner service", func() {
+			task := service.CreateTaskWithID("fake-task-id", nil, nil, nil)
+			service.StartTask(task)
+
+			foundTask, found := service.FindTaskWithID("fake-task-id")
+			Expect(found).To(BeTrue())
+			Expect(foundTask.State).To(Equal(StateRunning))
+		})
+
+		It("finds results of tasks that finished before agent restarted", func() {
+			finishTask("fake-done-task-id", "fake-value", nil)
+			finishTask("fake-failed-task-id", nil, errors.New("fake-task-err"))
+
+			service = newService()
+
+			task, found := service.FindTaskWithID("fake-done-task-id")
+			Expect(found).To(BeTrue())
+			Expect(task).To(Equal(Task{ID: "fake-done-task-id", State: StateDone, Value: "fake-value"}))
+
+			task, found = service.FindTaskWithID("fake-failed-task-id")
+			Expect(found).To(BeTrue())
+			Expect(task.State).To(Equal(StateFailed))
+			Expect(task.Error).To(Equal(errors.New("fake-task-err")))
+		})
+
+		It("does not find unknown tasks", func() {
+			_, found := service.FindTaskWithID("fake-unknown-task-id")
+			Expect(found).To(BeFalse())
+		})
+
+		It("calls given end func after persisting result", func() {
+			var endedTask Task
+			endFunc := func(task Task) {
+				endedTask = task
+
+				_, found := newService().FindTaskWithID(task.ID)
+				Expect(found).To(BeTrue())
+			}
+
+			task, err := service.CreateTask(nil, nil, endFunc)
+			Expect(err).ToNot(HaveOccurred())
+
+			task.State = StateDone
+			task.EndFunc(task)
+			Expect(endedTask.ID).To(Equal("fake-generated-task-id"))
+		})
+
+		It("still ends task when result cannot be persisted", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			ended := false
+			task := service.CreateTaskWithID("fake-task-id", nil, nil, func(Task) { ended = true })
+
+			task.State = StateDone
+			task.EndFunc(task)
+			Expect(ended).To(BeTrue())
+		})
+
+		It("keeps only most recent task results", func() {
+			for i := 0; i <= MaxPersistedTaskResults; i++ {
+				finishTask(fmt.Sprintf("fake-task-id-%d", i), nil, nil)
+				fakeClock.Increment(time.Second)
+			}
+
+			service = newService()
+
+			_, found := service.FindTaskWithID("fake-task-id-0")
+			Expect(found).To(BeFalse())
+
+			_, found = service.FindTaskWithID("fake-task-id-1")
+			Expect(found).To(BeTrue())
+
+			_, found = service.FindTaskWithID(fmt.Sprintf("fake-task-id-%d", MaxPersistedTaskResults))
+			Expect(found).To(BeTrue())
+		})
+	})
+}
@@ -1,9 +1,14 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/pivotal-golang/clock"
@@ -15,16 +20,21 @@ import (
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshaj "github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	boshap "github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
+	boshagentstate "github.com/cloudfoundry/bosh-agent/agent/state"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	"github.com/cloudfoundry/bosh-agent/infrastructure/agentlogger"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
@@ -37,6 +47,8 @@ import (
 	sigar "github.com/cloudfoundry/gosigar"
 )
 
+const preflightTimeout = 10 * time.Second
+
 type App interface {
 	Setup(args []string) error
 	Run() error
@@ -44,12 +56,14 @@ type App interface {
 }
 
 type app struct {
-	logger      boshlog.Logger
-	agent       boshagent.Agent
-	platform    boshplatform.Platform
-	fs          boshsys.FileSystem
-	logTag      string
-	dirProvider boshdirs.Provider
+	logger           boshlog.Logger
+	agent            boshagent.Agent
+	settingsReloader boshagent.SettingsReloader
+	agentState       boshagentstate.Store
+	platform         boshplatform.Platform
+	fs               boshsys.FileSystem
+	logTag           string
+	dirProvider      boshdirs.Provider
 }
 
 func New(logger boshlog.Logger, fs boshsys.FileSystem) App {
@@ -71,9 +85,33 @@ func (app *app) Setup(args []string) error {
 		return bosherr.WrapError(err, "Loading config")
 	}
 
-	app.dirProvider = boshdirs.NewProvider(opts.BaseDirectory)
+	// Only sink loggers can be redirected to configured destination
+	if sinkLogger, ok := app.logger.(agentlogger.SinkLogger); ok {
+		err = sinkLogger.SwitchSink(config.Logging)
+		if err != nil {
+			return bosherr.WrapError(err, "Switching log destination")
+		}
+	}
+
+	err = config.Proxy.Apply()
+	if err != nil {
+		return bosherr.WrapError(err, "Applying proxy config")
+	}
+
+	app.dirProvider = boshdirs.NewProvider(opts.BaseDirectory).
+		WithCompileDir(config.Agent.CompileDir).
+		WithAuthorizedKeysFile(config.Agent.AuthorizedKeysFile)
 	app.logStemcellInfo()
 
+	if config.Agent.SensitiveFiles.Umask != "" {
+		umask, err := config.Agent.SensitiveFiles.ParsedUmask()
+		if err != nil {
+			return bosherr.WrapError(err, "Configuring sensitive files")
+		}
+
+		app.fs = boshplatform.NewSensitiveFileSystem(app.fs, umask, config.Agent.SensitiveFilePaths(app.dirProvider.BoshDir()))
+	}
+
 	statsCollector := boshsigar.NewSigarStatsCollector(&sigar.ConcreteSigar{})
 
 	scriptCommandFactory := boshsys.NewScriptCommandFactory(opts.PlatformName)
@@ -84,6 +122,17 @@ func (app *app) Setup(args []string) error {
 	}
 
 	timeService := clock.NewClock()
+	bootstrapTime := timeService.Now()
+
+	agentStatePath := config.Agent.StatePath
+	if agentStatePath == "" {
+		agentStatePath = filepath.Join(app.dirProvider.BoshDir(), "task_state.json")
+	}
+
+	app.agentState, err = boshagentstate.NewFileStore(app.fs, agentStatePath, app.logger)
+	if err != nil {
+		return bosherr.WrapError(err, "Loading agent state")
+	}
 
 	platformProvider := boshplatform.NewProvider(app.logger, app.dirProvider, statsCollector, scriptCommandFactory, app.fs, config.Platform, state, timeService)
 
@@ -92,46 +141,93 @@ func (app *app) Setup(args []string) error {
 		return bosherr.WrapError(err, "Getting platform")
 	}
 
-	settingsSourceFactory := boshinf.NewSettingsSourceFactory(config.Infrastructure.Settings, app.platform, app.logger)
+	settingsSourceFactory := boshinf.NewSettingsSourceFactory(config.Infrastructure.Settings, app.platform, timeService, app.logger)
 	settingsSource, err := settingsSourceFactory.New()
 	if err != nil {
 		return bosherr.WrapError(err, "Getting Settings Source")
 	}
 
+	settingsPath := filepath.Join(app.dirProvider.BoshDir(), "settings.json")
+
+	if config.Agent.Preflight.Enabled {
+		endpoints := append(settingsSourceFactory.ConnectivityEndpoints(), app.cachedBlobstoreEndpoints(settingsPath)...)
+
+		if err = app.runPreflight(config.Agent.Preflight, endpoints); err != nil {
+			return bosherr.WrapError(err, "Running preflight")
+		}
+	}
+
 	settingsService := boshsettings.NewService(
 		app.platform.GetFs(),
-		filepath.Join(app.dirProvider.BoshDir(), "settings.json"),
+		settingsPath,
 		settingsSource,
 		app.platform,
 		app.logger,
 	)
-	boot := boshagent.NewBootstrap(
-		app.platform,
-		app.dirProvider,
-		settingsService,
-		app.logger,
-	)
 
-	if err = boot.Run(); err != nil {
-		return bosherr.WrapError(err, "Running bootstrap")
+	// Listen-only agent must not reconfigure VM (networking, disks, etc.)
+	// hence settings are only fetched and never reloaded
+	if config.Agent.ListenOnly {
+		if err = settingsService.LoadSettings(); err != nil {
+			return bosherr.WrapError(err, "Fetching settings")
+		}
+	} else {
+		boot := boshagent.NewBootstrap(
+			app.platform,
+			app.dirProvider,
+			settingsService,
+			time.Duration(config.Agent.BootstrapDeadlineSeconds)*time.Second,
+			timeService,
+			app.logger,
+		)
+
+		if err = boot.Run(); err != nil {
+			return bosherr.WrapError(err, "Running bootstrap")
+		}
+
+		app.settingsReloader = boshagent.NewSettingsReloader(
+			settingsService,
+			app.platform,
+			app.logger,
+		)
 	}
 
-	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, app.logger)
+	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, config.Mbus, boshaction.IsReadOnly, app.logger)
 
 	mbusHandler, err := mbusHandlerProvider.Get(app.platform, app.dirProvider)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting mbus handler")
 	}
 
-	blobstoreProvider := boshblob.NewProvider(app.platform.GetFs(), app.platform.GetRunner(), app.dirProvider.EtcDir(), app.logger)
+	blobstoreProvider := boshagentblob.NewProvider(app.platform.GetFs(), app.platform.GetRunner(), app.dirProvider.EtcDir(), boshuuid.NewGenerator())
 
 	blobsettings := settingsService.GetSettings().Blobstore
-	blobstore, err := blobstoreProvider.Get(blobsettings.Type, blobsettings.Options)
+
+	var blobstore boshblob.Blobstore
+
+	if blobsettings.Type == boshagentblob.BlobstoreTypeHTTP {
+		blobstore = boshagentblob.NewHTTPBlobstore(
+			blobsettings.Options,
+			filepath.Join(app.dirProvider.DataDir(), "partial_blobs"),
+			boshinf.DefaultHTTPClient,
+			app.platform.GetFs(),
+			boshuuid.NewGenerator(),
+			timeService,
+			app.logger,
+		)
+
+		err = blobstore.Validate()
+	} else {
+		blobstore, err = blobstoreProvider.Get(blobsettings.Type, blobsettings.Options)
+	}
 	if err != nil {
 		return bosherr.WrapError(err, "Getting blobstore")
 	}
 
-	monitClientProvider := boshmonit.NewProvider(app.platform, app.logger)
+	blobstore = boshagentblob.NewDigestVerifiableBlobstore(blobstore, app.platform.GetFs())
+	blobstore = boshagentblob.NewRetryableBlobstore(blobstore, boshbackoff.New(boshbackoff.DefaultOptions, timeService), app.logger)
+
+	monitClientProvider := boshmonit.NewProvider(app.platform, timeService, app.logger)
 
 	monitClient, err := monitClientProvider.Get()
 	if err != nil {
@@ -144,6 +240,7 @@ func (app *app) Setup(args []string) error {
 		app.logger,
 		app.dirProvider,
 		mbusHandler,
+		timeService,
 	)
 
 	jobSupervisor, err := jobSupervisorProvider.Get(opts.JobSupervisor)
@@ -151,13 +248,37 @@ func (app *app) Setup(args []string) error {
 		return bosherr.WrapError(err, "Getting job supervisor")
 	}
 
+	jobSupervisor = boshjobsuper.NewRestartTrackingJobSupervisor(jobSupervisor)
+
 	notifier := boshnotif.NewNotifier(mbusHandler)
 
-	applier, compiler := app.buildApplierAndCompiler(app.dirProvider, blobstore, jobSupervisor)
+	signatureVerifier, err := boshcomp.NewPackageSignatureVerifier(
+		config.Agent.PackageSignature.PublicKey,
+		config.Agent.PackageSignature.AllowUnsigned,
+		app.platform.GetFs(),
+	)
+	if err != nil {
+		return bosherr.WrapError(err, "Building package signature verifier")
+	}
+
+	applier, compiler := app.buildApplierAndCompiler(app.dirProvider, blobstore, jobSupervisor, signatureVerifier, timeService)
+
+	compileDirSweeper := boshcomp.NewCompileDirSweeper(app.dirProvider, app.platform.GetFs(), timeService, boshcomp.DefaultStaleCompileDirAge, app.logger)
+
+	// Leftovers of crashed compilations only take up disk space so agent starts anyway
+	err = compileDirSweeper.Sweep()
+	if err != nil {
+		app.logger.Warn(app.logTag, "Sweeping stale compilation work dirs: %s", err.Error())
+	}
 
 	uuidGen := boshuuid.NewGenerator()
 
-	taskService := boshtask.NewAsyncTaskService(uuidGen, app.logger)
+	taskService := boshtask.NewPersistentTaskService(
+		boshtask.NewAsyncTaskService(uuidGen, app.logger),
+		app.agentState,
+		timeService,
+		app.logger,
+	)
 
 	taskManager := boshtask.NewManagerProvider().NewManager(
 		app.logger,
@@ -177,6 +298,7 @@ func (app *app) Setup(args []string) error {
 		app.platform.GetDirProvider(),
 		scriptCommandFactory,
 		timeService,
+		settingsService,
 		app.logger,
 	)
 
@@ -192,9 +314,18 @@ func (app *app) Setup(args []string) error {
 		specService,
 		jobScriptProvider,
 		scriptCommandFactory,
+		boshstats.NewFreeSpaceChecker(statsCollector, config.Agent.MinFreeDiskSpace()),
+		timeService,
+		bootstrapTime,
+		time.Duration(config.Agent.RestartGracePeriodSeconds)*time.Second,
 		app.logger,
 	)
 
+	if config.Agent.ListenOnly {
+		app.logger.Info(app.logTag, "Running in listen-only mode")
+		actionFactory = boshaction.NewListenOnlyFactory(actionFactory, app.logger)
+	}
+
 	actionRunner := boshaction.NewRunner()
 
 	actionDispatcher := boshagent.NewActionDispatcher(
@@ -203,8 +334,19 @@ func (app *app) Setup(args []string) error {
 		taskManager,
 		actionFactory,
 		actionRunner,
+		config.Agent.AllowedActions,
 	)
 
+	if mbusHandlerProvider.UniqueReplyTo() && config.Agent.DeduplicationWindow() > 0 {
+		actionDispatcher = boshagent.NewDeduplicatingActionDispatcher(
+			actionDispatcher,
+			timeService,
+			config.Agent.DeduplicationWindow(),
+			boshagent.DefaultDeduplicationMaxEntries,
+			app.logger,
+		)
+	}
+
 	syslogServer := boshsyslog.NewServer(33331, net.Listen, app.logger)
 
 	app.agent = boshagent.New(
@@ -225,6 +367,13 @@ func (app *app) Setup(args []string) error {
 }
 
 func (app *app) Run() error {
+	// Operators send SIGHUP to force settings refresh without restarting agent
+	if app.settingsReloader != nil {
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+		go app.settingsReloader.Run(reloadSignals)
+	}
+
 	err := app.agent.Run()
 	if err != nil {
 		return bosherr.WrapError(err, "Running agent")
@@ -240,6 +389,8 @@ func (app *app) buildApplierAndCompiler(
 	dirProvider boshdirs.Provider,
 	blobstore boshblob.Blobstore,
 	jobSupervisor boshjobsuper.JobSupervisor,
+	signatureVerifier boshcomp.PackageSignatureVerifier,
+	timeService clock.Clock,
 ) (boshapplier.Applier, boshcomp.Compiler) {
 	jobsBc := boshbc.NewFileBundleCollection(
 		dirProvider.DataDir(),
@@ -274,6 +425,7 @@ func (app *app) buildApplierAndCompiler(
 		jobApplier,
 		packageApplierProvider.Root(),
 		app.platform,
+		app.platform,
 		jobSupervisor,
 		dirProvider,
 	)
@@ -295,6 +447,8 @@ func (app *app) buildApplierAndCompiler(
 		dirProvider,
 		packageApplierProvider.Root(),
 		packageApplierProvider.RootBundleCollection(),
+		signatureVerifier,
+		timeService,
 	)
 
 	return applier, compiler
@@ -306,6 +460,54 @@ func (app *app) loadConfig(path string) (Config, error) {
 	return LoadConfigFromPath(fs, path)
 }
 
+// cachedBlobstoreEndpoints returns blobstore endpoint from settings saved
+// on previous boot since current settings are not fetched yet
+func (app *app) cachedBlobstoreEndpoints(settingsPath string) []boshinf.ConnectivityEndpoint {
+	settingsJSON, err := app.platform.GetFs().ReadFile(settingsPath)
+	if err != nil {
+		return nil
+	}
+
+	var settings boshsettings.Settings
+
+	if err = json.Unmarshal(settingsJSON, &settings); err != nil {
+		return nil
+	}
+
+	endpoint, _ := settings.Blobstore.Options["endpoint"].(string)
+	if endpoint == "" {
+		return nil
+	}
+
+	return []boshinf.ConnectivityEndpoint{{Name: "blobstore", Address: endpoint}}
+}
+
+func (app *app) runPreflight(opts PreflightOptions, endpoints []boshinf.ConnectivityEndpoint) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	httpClient := &http.Client{
+		Transport: boshinf.DefaultHTTPClient.Transport,
+		Timeout:   preflightTimeout,
+	}
+
+	checker := boshinf.NewConnectivityChecker(httpClient, preflightTimeout, app.logger)
+
+	report := checker.Check(endpoints)
+	if report.AllReachable() {
+		app.logger.Info(app.logTag, "Preflight connectivity: %s", report)
+		return nil
+	}
+
+	if opts.FailOnUnreachable {
+		return bosherr.Errorf("Unreachable endpoints: %s", report)
+	}
+
+	app.logger.Warn(app.logTag, "Preflight connectivity: %s", report)
+	return nil
+}
+
 func (app *app) logStemcellInfo() {
 	stemcellVersionFilePath := filepath.Join(app.dirProvider.EtcDir(), "stemcell_version")
 	stemcellVersion := app.fileContents(stemcellVersionFilePath)
@@ -10,6 +10,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	"github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -17,6 +18,10 @@ import (
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
+func settingsReloaderOf(a App) boshagent.SettingsReloader {
+	return a.(*app).settingsReloader
+}
+
 func init() {
 	Describe("App", func() {
 		var (
@@ -121,6 +126,31 @@ func init() {
 			Expect(app.GetPlatform().GetDevicePathResolver()).To(Equal(devicepathresolver.NewIdentityDevicePathResolver()))
 		})
 
+		It("sets up settings reloader", func() {
+			err := app.Setup([]string{"bosh-agent", "-P", "dummy", "-C", agentConfPath, "-b", baseDir})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(settingsReloaderOf(app)).ToNot(BeNil())
+			Expect(filepath.Join(baseDir, "bosh", "bootstrap_steps.json")).To(BeAnExistingFile())
+		})
+
+		Context("when agent is listen-only", func() {
+			BeforeEach(func() {
+				agentConfJSON = `{
+					"Agent": { "ListenOnly": true },
+					"Infrastructure": { "Settings": { "Sources": [{ "Type": "CDROM", "FileName": "/fake-file-name" }] } }
+				}`
+			})
+
+			It("skips bootstrap and settings reloader", func() {
+				err := app.Setup([]string{"bosh-agent", "-P", "dummy", "-C", agentConfPath, "-b", baseDir})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(settingsReloaderOf(app)).To(BeNil())
+				Expect(filepath.Join(baseDir, "bosh", "bootstrap_steps.json")).ToNot(BeAnExistingFile())
+			})
+		})
+
 		Context("when DevicePathResolutionType is 'virtio'", func() {
 			BeforeEach(func() {
 				agentConfJSON = `{
@@ -2,9 +2,18 @@ package app
 
 import (
 	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	"github.com/cloudfoundry/bosh-agent/infrastructure/agentlogger"
+	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -12,6 +21,172 @@ import (
 type Config struct {
 	Platform       boshplatform.Options
 	Infrastructure boshinf.Options
+	Proxy          ProxyOptions
+	Mbus           boshmbus.Options
+	Agent          AgentOptions
+	Logging        agentlogger.SinkOptions
+}
+
+type AgentOptions struct {
+	// Only listed actions are dispatched, e.g. to disable run_errand;
+	// all actions are allowed when empty
+	AllowedActions []string
+
+	// Compile and apply fail early when compile dir or data dir has less free space;
+	// 0 disables the check; defaults to stats.DefaultMinFreeSpaceInMB
+	MinFreeDiskSpaceMB *int
+
+	// Redelivered NATS messages get cached response within this window;
+	// 0 disables deduplication; defaults to agent.DefaultDeduplicationWindow
+	DeduplicationWindowSeconds *int
+
+	// CompileDir is where packages are compiled;
+	// defaults to compile in the data directory
+	CompileDir string
+
+	// AuthorizedKeysFile is where SSH public keys are installed for users,
+	// e.g. "/etc/ssh/authorized_keys/%u"; see directories.Provider
+	AuthorizedKeysFile string
+
+	// StatePath is where results of persistent tasks are kept across restarts;
+	// defaults to task_state.json in the bosh directory
+	StatePath string
+
+	// Restart action waits this long between stopping and starting jobs;
+	// defaults to 0
+	RestartGracePeriodSeconds int
+
+	// Agent only responds to read-only actions and simulates apply,
+	// prepare, drain, stop and start so that deploys still succeed,
+	// e.g. during disaster-recovery drills
+	ListenOnly bool
+
+	// Bootstrap is aborted when it does not finish in time, e.g. when
+	// settings cannot be fetched; there is no deadline when 0
+	BootstrapDeadlineSeconds int
+
+	Preflight PreflightOptions
+
+	PackageSignature PackageSignatureOptions
+
+	SensitiveFiles SensitiveFilesOptions
+}
+
+// SensitiveFilesOptions tighten permissions of files written by the agent
+// that contain credentials, e.g. authorized_keys and settings cache
+type SensitiveFilesOptions struct {
+	// Octal umask applied to sensitive files, e.g. "0077";
+	// file permissions are not changed when empty
+	Umask string
+
+	// Glob patterns of sensitive files; patterns without a slash
+	// match base names. Defaults to authorized keys files and settings cache
+	Paths []string
+}
+
+func (o SensitiveFilesOptions) ParsedUmask() (os.FileMode, error) {
+	umask, err := strconv.ParseUint(o.Umask, 8, 32)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Parsing sensitive files umask '%s'", o.Umask)
+	}
+
+	if umask&^0777 != 0 {
+		return 0, bosherr.Errorf("Parsing sensitive files umask '%s': only permission bits are allowed", o.Umask)
+	}
+
+	return os.FileMode(umask), nil
+}
+
+func (o AgentOptions) MinFreeDiskSpace() int {
+	if o.MinFreeDiskSpaceMB == nil {
+		return boshstats.DefaultMinFreeSpaceInMB
+	}
+
+	return *o.MinFreeDiskSpaceMB
+}
+
+func (o AgentOptions) DeduplicationWindow() time.Duration {
+	if o.DeduplicationWindowSeconds == nil {
+		return boshagent.DefaultDeduplicationWindow
+	}
+
+	return time.Duration(*o.DeduplicationWindowSeconds) * time.Second
+}
+
+// SensitiveFilePaths returns configured patterns or ones matching
+// authorized keys files and settings cache in the bosh directory
+func (o AgentOptions) SensitiveFilePaths(boshDir string) []string {
+	if len(o.SensitiveFiles.Paths) > 0 {
+		return o.SensitiveFiles.Paths
+	}
+
+	keysPattern := "authorized_keys"
+
+	if o.AuthorizedKeysFile != "" {
+		keysPattern = strings.NewReplacer("%%", "%", "%h", "*", "%u", "*").Replace(o.AuthorizedKeysFile)
+
+		// Home directories cannot be matched by a single wildcard
+		if !path.IsAbs(keysPattern) {
+			keysPattern = path.Base(keysPattern)
+		}
+	}
+
+	return []string{keysPattern, path.Join(boshDir, "settings.json")}
+}
+
+// PackageSignatureOptions enable verifying detached signatures
+// of source packages before they are compiled
+type PackageSignatureOptions struct {
+	// PEM encoded RSA, ECDSA or Ed25519 public key;
+	// signatures are not verified when empty
+	PublicKey string
+
+	// Compile packages without signature even when public key is configured
+	AllowUnsigned bool
+}
+
+// PreflightOptions enable checking connectivity to settings source
+// and blobstore endpoints before settings are fetched
+// to quickly diagnose provisioning issues
+type PreflightOptions struct {
+	Enabled bool
+
+	// Otherwise unreachable endpoints are only logged
+	FailOnUnreachable bool
+}
+
+// ProxyOptions override proxy environment variables inherited by the agent.
+// They are picked up by agent HTTP clients and by external blobstore clients.
+type ProxyOptions struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+func (o ProxyOptions) Apply() error {
+	vars := map[string]string{
+		"HTTP_PROXY":  o.HTTPProxy,
+		"HTTPS_PROXY": o.HTTPSProxy,
+		"NO_PROXY":    o.NoProxy,
+	}
+
+	for name, value := range vars {
+		if value == "" {
+			continue
+		}
+
+		err := os.Setenv(name, value)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Setting %s", name)
+		}
+
+		err = os.Setenv(strings.ToLower(name), value)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Setting %s", strings.ToLower(name))
+		}
+	}
+
+	return nil
 }
 
 func LoadConfigFromPath(fs boshsys.FileSystem, path string) (Config, error) {
@@ -1,11 +1,21 @@
 package app
 
 import (
+	"os"
+	"runtime"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	"github.com/cloudfoundry/bosh-agent/infrastructure/agentlogger"
+	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
@@ -19,14 +29,18 @@ var _ = Describe("LoadConfigFromPath", func() {
 	})
 
 	It("returns populates config", func() {
+		minFreeDiskSpaceMB := 512
+
 		fs.WriteFileString("/fake-config.conf", `{
 			"Platform": {
 				"Linux": {
 					"UseDefaultTmpDir": true,
 					"UsePreformattedPersistentDisk": true,
 					"BindMountPersistentDisk": true,
+					"JobStoreMountsDir": "/var/vcap/chroot/store",
 					"SkipDiskSetup": true,
-					"DevicePathResolutionType": "virtio"
+					"DevicePathResolutionType": "virtio",
+					"DHCPClient": "systemd-networkd"
 				}
 			},
 			"Infrastructure": {
@@ -39,6 +53,7 @@ var _ = Describe("LoadConfigFromPath", func() {
 					  {
 					  	"Type": "ConfigDrive",
 					  	"DiskPaths": ["/fake-disk-path1", "/fake-disk-path2"],
+					  	"Label": "config-2",
 					  	"MetaDataPath": "/fake-metadata-path",
 					  	"UserDataPath": "/fake-userdata-path",
 					  	"SettingsPath": "/fake-settings-path"
@@ -53,6 +68,21 @@ var _ = Describe("LoadConfigFromPath", func() {
 					  	"Type": "CDROM",
 					  	"FileName": "/fake-file-name"
 					  },
+					  {
+					  	"Type": "Azure",
+					  	"CustomDataPath": "fake-custom-data-path"
+					  },
+					  {
+					  	"Type": "GCE",
+					  	"RegistryEndpointAttribute": "fake-attribute"
+					  },
+					  {
+					  	"Type": "DHCP",
+					  	"LeaseFilePaths": ["/fake-leases/*.lease"],
+					  	"RegistryEndpointOption": "fake-option",
+					  	"URI": "http://fake-uri",
+					  	"UserDataPath": "/fake-user-data-path"
+					  },
 					  {
 						"Type": "InstanceMetadata",
 						"URI": "/fake-uri",
@@ -63,6 +93,43 @@ var _ = Describe("LoadConfigFromPath", func() {
 				  "UseServerName": true,
 				  "UseRegistry": true
 				}
+			},
+			"Proxy": {
+				"HTTPProxy": "http://fake-proxy:3128",
+				"HTTPSProxy": "http://fake-secure-proxy:3128",
+				"NoProxy": "fake-no-proxy-host"
+			},
+			"Mbus": {
+				"MaxMessageSize": 2048,
+				"MaxConcurrentHandlers": 4,
+				"MaxQueuedMessages": 20
+			},
+			"Agent": {
+				"AllowedActions": ["ping", "get_task"],
+				"MinFreeDiskSpaceMB": 512,
+				"CompileDir": "/fake-compile-dir",
+				"AuthorizedKeysFile": "/fake-keys/%u",
+				"StatePath": "/fake-state-path",
+				"RestartGracePeriodSeconds": 3,
+				"ListenOnly": true,
+				"BootstrapDeadlineSeconds": 600,
+				"Preflight": {
+					"Enabled": true,
+					"FailOnUnreachable": true
+				},
+				"PackageSignature": {
+					"PublicKey": "fake-public-key",
+					"AllowUnsigned": true
+				},
+				"SensitiveFiles": {
+					"Umask": "0077",
+					"Paths": ["authorized_keys", "/fake-settings.json"]
+				}
+			},
+			"Logging": {
+				"Destination": "syslog",
+				"SyslogFacility": "local3",
+				"Level": "INFO"
 			}
 		}`)
 
@@ -74,8 +141,10 @@ var _ = Describe("LoadConfigFromPath", func() {
 					UseDefaultTmpDir:              true,
 					UsePreformattedPersistentDisk: true,
 					BindMountPersistentDisk:       true,
+					JobStoreMountsDir:             "/var/vcap/chroot/store",
 					SkipDiskSetup:                 true,
 					DevicePathResolutionType:      "virtio",
+					DHCPClient:                    "systemd-networkd",
 				},
 			},
 			Infrastructure: boshinf.Options{
@@ -86,6 +155,7 @@ var _ = Describe("LoadConfigFromPath", func() {
 						},
 						boshinf.ConfigDriveSourceOptions{
 							DiskPaths:    []string{"/fake-disk-path1", "/fake-disk-path2"},
+							Label:        "config-2",
 							MetaDataPath: "/fake-metadata-path",
 							UserDataPath: "/fake-userdata-path",
 							SettingsPath: "/fake-settings-path",
@@ -98,6 +168,18 @@ var _ = Describe("LoadConfigFromPath", func() {
 						boshinf.CDROMSourceOptions{
 							FileName: "/fake-file-name",
 						},
+						boshinf.AzureSourceOptions{
+							CustomDataPath: "fake-custom-data-path",
+						},
+						boshinf.GCESourceOptions{
+							RegistryEndpointAttribute: "fake-attribute",
+						},
+						boshinf.DHCPSourceOptions{
+							LeaseFilePaths:         []string{"/fake-leases/*.lease"},
+							RegistryEndpointOption: "fake-option",
+							URI:                    "http://fake-uri",
+							UserDataPath:           "/fake-user-data-path",
+						},
 						boshinf.InstanceMetadataSourceOptions{
 							URI:          "/fake-uri",
 							Headers:      map[string]string{"fake": "headers"},
@@ -108,6 +190,47 @@ var _ = Describe("LoadConfigFromPath", func() {
 					UseRegistry:   true,
 				},
 			},
+			Proxy: ProxyOptions{
+				HTTPProxy:  "http://fake-proxy:3128",
+				HTTPSProxy: "http://fake-secure-proxy:3128",
+				NoProxy:    "fake-no-proxy-host",
+			},
+			Mbus: boshmbus.Options{
+				MaxMessageSize:        2048,
+				MaxConcurrentHandlers: 4,
+				MaxQueuedMessages:     20,
+			},
+			Agent: AgentOptions{
+				AllowedActions:     []string{"ping", "get_task"},
+				MinFreeDiskSpaceMB: &minFreeDiskSpaceMB,
+				CompileDir:         "/fake-compile-dir",
+				AuthorizedKeysFile: "/fake-keys/%u",
+				StatePath:          "/fake-state-path",
+
+				RestartGracePeriodSeconds: 3,
+				ListenOnly:                true,
+				BootstrapDeadlineSeconds:  600,
+
+				Preflight: PreflightOptions{
+					Enabled:           true,
+					FailOnUnreachable: true,
+				},
+
+				PackageSignature: PackageSignatureOptions{
+					PublicKey:     "fake-public-key",
+					AllowUnsigned: true,
+				},
+
+				SensitiveFiles: SensitiveFilesOptions{
+					Umask: "0077",
+					Paths: []string{"authorized_keys", "/fake-settings.json"},
+				},
+			},
+			Logging: agentlogger.SinkOptions{
+				Destination:    "syslog",
+				SyslogFacility: "local3",
+				Level:          "INFO",
+			},
 		}))
 	})
 
@@ -273,4 +396,171 @@ var _ = Describe("LoadConfigFromPath", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("Unmarshalling source type 'CDROM'"))
 	})
+
+	It("returns errors if failed to decode Env source options", func() {
+		fs.WriteFileString("/fake-config.conf", `{
+			"Infrastructure": {
+			  "Settings": {
+				  "Sources": [{
+				  	"Type": "Env",
+				  	"VariableName": 1
+				  }]
+				}
+			}
+		}`)
+
+		_, err := LoadConfigFromPath(fs, "/fake-config.conf")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling source type 'Env'"))
+	})
+})
+
+var _ = Describe("ProxyOptions", func() {
+	proxyVars := []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"}
+
+	var originalVars map[string]string
+
+	BeforeEach(func() {
+		originalVars = map[string]string{}
+		for _, name := range proxyVars {
+			originalVars[name] = os.Getenv(name)
+			os.Unsetenv(name)
+		}
+	})
+
+	AfterEach(func() {
+		for name, value := range originalVars {
+			os.Setenv(name, value)
+		}
+	})
+
+	Describe("Apply", func() {
+		It("overrides the proxy environment variables", func() {
+			err := ProxyOptions{
+				HTTPProxy:  "http://fake-proxy:3128",
+				HTTPSProxy: "http://fake-secure-proxy:3128",
+				NoProxy:    "fake-no-proxy-host",
+			}.Apply()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Getenv("HTTP_PROXY")).To(Equal("http://fake-proxy:3128"))
+			Expect(os.Getenv("http_proxy")).To(Equal("http://fake-proxy:3128"))
+			Expect(os.Getenv("HTTPS_PROXY")).To(Equal("http://fake-secure-proxy:3128"))
+			Expect(os.Getenv("https_proxy")).To(Equal("http://fake-secure-proxy:3128"))
+			Expect(os.Getenv("NO_PROXY")).To(Equal("fake-no-proxy-host"))
+			Expect(os.Getenv("no_proxy")).To(Equal("fake-no-proxy-host"))
+		})
+
+		It("leaves inherited variables alone when nothing is configured", func() {
+			os.Setenv("HTTP_PROXY", "http://inherited-proxy:3128")
+
+			err := ProxyOptions{}.Apply()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Getenv("HTTP_PROXY")).To(Equal("http://inherited-proxy:3128"))
+			Expect(os.Getenv("NO_PROXY")).To(BeEmpty())
+		})
+
+		It("is inherited by external blobstore commands", func() {
+			if runtime.GOOS == "windows" {
+				Skip("Pending on Windows")
+			}
+
+			err := ProxyOptions{HTTPProxy: "http://fake-proxy:3128"}.Apply()
+			Expect(err).ToNot(HaveOccurred())
+
+			runner := boshsys.NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+			stdout, _, _, err := runner.RunCommand("sh", "-c", "echo $HTTP_PROXY")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Equal("http://fake-proxy:3128\n"))
+		})
+	})
+})
+
+var _ = Describe("SensitiveFilesOptions", func() {
+	Describe("ParsedUmask", func() {
+		It("parses octal umask", func() {
+			umask, err := SensitiveFilesOptions{Umask: "0027"}.ParsedUmask()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(umask).To(Equal(os.FileMode(0027)))
+		})
+
+		It("returns error when umask is not octal", func() {
+			_, err := SensitiveFilesOptions{Umask: "0089"}.ParsedUmask()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing sensitive files umask '0089'"))
+		})
+
+		It("returns error when umask has more than permission bits", func() {
+			_, err := SensitiveFilesOptions{Umask: "1077"}.ParsedUmask()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("only permission bits are allowed"))
+		})
+	})
+})
+
+var _ = Describe("AgentOptions", func() {
+	Describe("MinFreeDiskSpace", func() {
+		It("returns configured minimum free space", func() {
+			minFreeDiskSpaceMB := 512
+			Expect(AgentOptions{MinFreeDiskSpaceMB: &minFreeDiskSpaceMB}.MinFreeDiskSpace()).To(Equal(512))
+		})
+
+		It("returns 0 when it is configured to disable the check", func() {
+			minFreeDiskSpaceMB := 0
+			Expect(AgentOptions{MinFreeDiskSpaceMB: &minFreeDiskSpaceMB}.MinFreeDiskSpace()).To(Equal(0))
+		})
+
+		It("defaults when it is not configured", func() {
+			Expect(AgentOptions{}.MinFreeDiskSpace()).To(Equal(boshstats.DefaultMinFreeSpaceInMB))
+		})
+	})
+
+	Describe("DeduplicationWindow", func() {
+		It("returns configured window", func() {
+			deduplicationWindowSeconds := 60
+			opts := AgentOptions{DeduplicationWindowSeconds: &deduplicationWindowSeconds}
+			Expect(opts.DeduplicationWindow()).To(Equal(time.Minute))
+		})
+
+		It("returns 0 when it is configured to disable deduplication", func() {
+			deduplicationWindowSeconds := 0
+			opts := AgentOptions{DeduplicationWindowSeconds: &deduplicationWindowSeconds}
+			Expect(opts.DeduplicationWindow()).To(Equal(time.Duration(0)))
+		})
+
+		It("defaults when it is not configured", func() {
+			Expect(AgentOptions{}.DeduplicationWindow()).To(Equal(boshagent.DefaultDeduplicationWindow))
+		})
+	})
+
+	Describe("SensitiveFilePaths", func() {
+		It("returns configured paths", func() {
+			opts := AgentOptions{SensitiveFiles: SensitiveFilesOptions{Paths: []string{"/fake-path"}}}
+			Expect(opts.SensitiveFilePaths("/var/vcap/bosh")).To(Equal([]string{"/fake-path"}))
+		})
+
+		It("defaults to authorized_keys and settings cache", func() {
+			Expect(AgentOptions{}.SensitiveFilePaths("/var/vcap/bosh")).To(Equal([]string{
+				"authorized_keys",
+				"/var/vcap/bosh/settings.json",
+			}))
+		})
+
+		It("matches configured authorized keys files of all users", func() {
+			opts := AgentOptions{AuthorizedKeysFile: "/etc/ssh/keys/%u"}
+			Expect(opts.SensitiveFilePaths("/var/vcap/bosh")).To(Equal([]string{
+				"/etc/ssh/keys/*",
+				"/var/vcap/bosh/settings.json",
+			}))
+		})
+
+		It("matches configured authorized keys files in home directories by name", func() {
+			opts := AgentOptions{AuthorizedKeysFile: ".ssh/authorized_keys2"}
+			Expect(opts.SensitiveFilePaths("/var/vcap/bosh")).To(Equal([]string{
+				"authorized_keys2",
+				"/var/vcap/bosh/settings.json",
+			}))
+		})
+	})
 })
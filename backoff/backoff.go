@@ -0,0 +1,106 @@
+package backoff
+
+import (
+	"context"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type Options struct {
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+
+	// Zero means retrying until the context is cancelled
+	MaxElapsedTime time.Duration
+}
+
+var DefaultOptions = Options{
+	InitialInterval: 500 * time.Millisecond,
+	Multiplier:      2,
+	MaxInterval:     30 * time.Second,
+	MaxElapsedTime:  5 * time.Minute,
+}
+
+// Interval returns how long to wait after given zero-based failed attempt
+func (o Options) Interval(attempt int) time.Duration {
+	interval := float64(o.InitialInterval)
+
+	// Stop multiplying once capped to avoid overflowing
+	for i := 0; i < attempt && (o.MaxInterval == 0 || interval < float64(o.MaxInterval)); i++ {
+		interval *= o.Multiplier
+	}
+
+	if o.MaxInterval > 0 && interval > float64(o.MaxInterval) {
+		return o.MaxInterval
+	}
+
+	return time.Duration(interval)
+}
+
+// PermanentError stops Retry from making further attempts
+type PermanentError struct {
+	Err error
+}
+
+func Permanent(err error) error {
+	return PermanentError{Err: err}
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+type Backoff struct {
+	options     Options
+	timeService clock.Clock
+}
+
+func New(options Options, timeService clock.Clock) Backoff {
+	return Backoff{
+		options:     options,
+		timeService: timeService,
+	}
+}
+
+// Retry calls fn until it succeeds, ctx is cancelled or waiting for
+// another attempt would exceed MaxElapsedTime. Last error from fn is returned.
+// Error wrapped with Permanent is returned unwrapped without further attempts.
+func (b Backoff) Retry(ctx context.Context, fn func() error) error {
+	startTime := b.timeService.Now()
+
+	for attempt := 0; ; attempt++ {
+		if ctx.Err() != nil {
+			return bosherr.WrapError(ctx.Err(), "Retrying cancelled")
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if permanentErr, ok := err.(PermanentError); ok {
+			return permanentErr.Err
+		}
+
+		interval := b.options.Interval(attempt)
+
+		if b.options.MaxElapsedTime > 0 {
+			if b.timeService.Since(startTime)+interval > b.options.MaxElapsedTime {
+				return bosherr.WrapErrorf(err, "Giving up after %d attempts", attempt+1)
+			}
+		}
+
+		timer := b.timeService.NewTimer(interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return bosherr.WrapErrorf(err, "Retrying cancelled after %d attempts", attempt+1)
+		case <-timer.C():
+		}
+	}
+}
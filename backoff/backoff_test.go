@@ -0,0 +1,161 @@
+package backoff_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/backoff"
+)
+
+var _ = Describe("Backoff", func() {
+	var (
+		options   Options
+		fakeClock *fakeclock.FakeClock
+		backoff   Backoff
+	)
+
+	BeforeEach(func() {
+		options = Options{
+			InitialInterval: 1 * time.Second,
+			Multiplier:      2,
+			MaxInterval:     4 * time.Second,
+			MaxElapsedTime:  10 * time.Second,
+		}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+	})
+
+	JustBeforeEach(func() {
+		backoff = New(options, fakeClock)
+	})
+
+	Describe("Interval", func() {
+		It("multiplies initial interval for every failed attempt up to max interval", func() {
+			Expect(options.Interval(0)).To(Equal(1 * time.Second))
+			Expect(options.Interval(1)).To(Equal(2 * time.Second))
+			Expect(options.Interval(2)).To(Equal(4 * time.Second))
+			Expect(options.Interval(3)).To(Equal(4 * time.Second))
+			Expect(options.Interval(1000)).To(Equal(4 * time.Second))
+		})
+
+		It("supports fractional multipliers", func() {
+			options.Multiplier = 1.5
+			Expect(options.Interval(1)).To(Equal(1500 * time.Millisecond))
+			Expect(options.Interval(2)).To(Equal(2250 * time.Millisecond))
+		})
+
+		It("does not cap intervals when max interval is not set", func() {
+			options.MaxInterval = 0
+			Expect(options.Interval(4)).To(Equal(16 * time.Second))
+		})
+	})
+
+	Describe("Retry", func() {
+		It("does not wait when first attempt succeeds", func() {
+			attempts := 0
+
+			err := backoff.Retry(context.Background(), func() error {
+				attempts++
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(attempts).To(Equal(1))
+			Expect(fakeClock.WatcherCount()).To(Equal(0))
+		})
+
+		It("retries until attempt succeeds", func() {
+			attempts := 0
+			errCh := make(chan error)
+
+			go func() {
+				errCh <- backoff.Retry(context.Background(), func() error {
+					attempts++
+					if attempts < 3 {
+						return errors.New("fake-err")
+					}
+					return nil
+				})
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+			fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(attempts).To(Equal(3))
+		})
+
+		It("gives up when next wait would exceed max elapsed time", func() {
+			attempts := 0
+			errCh := make(chan error)
+
+			go func() {
+				errCh <- backoff.Retry(context.Background(), func() error {
+					attempts++
+					return errors.New("fake-err")
+				})
+			}()
+
+			// Attempts happen at 0s, 1s, 3s and 7s; waiting 4s more would exceed 10s
+			fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+			fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+			fakeClock.WaitForWatcherAndIncrement(4 * time.Second)
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Giving up after 4 attempts: fake-err"))
+			Expect(attempts).To(Equal(4))
+		})
+
+		It("stops waiting when context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error)
+
+			go func() {
+				errCh <- backoff.Retry(ctx, func() error {
+					return errors.New("fake-err")
+				})
+			}()
+
+			Eventually(fakeClock.WatcherCount).Should(Equal(1))
+			cancel()
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Retrying cancelled after 1 attempts: fake-err"))
+			Expect(fakeClock.WatcherCount()).To(Equal(0))
+		})
+
+		It("stops retrying when attempt fails permanently", func() {
+			attempts := 0
+			permanentErr := errors.New("fake-permanent-err")
+
+			err := backoff.Retry(context.Background(), func() error {
+				attempts++
+				return Permanent(permanentErr)
+			})
+			Expect(err).To(Equal(permanentErr))
+			Expect(attempts).To(Equal(1))
+			Expect(fakeClock.WatcherCount()).To(Equal(0))
+		})
+
+		It("does not attempt when context is already cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			attempts := 0
+			err := backoff.Retry(ctx, func() error {
+				attempts++
+				return nil
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Retrying cancelled: context canceled"))
+			Expect(attempts).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// Responses smaller than this are never compressed since
+// gzip and base64 overhead outweighs savings
+const CompressResponseMinLength = 16 * 1024
+
+const gzipEncoding = "gzip"
+
+type compressedValueResponse struct {
+	// Value is base64 encoded gzip of JSON encoded value
+	Value      string `json:"value"`
+	Compressed bool   `json:"compressed"`
+}
+
+func NewCompressedValueResponse(value interface{}) (Response, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling response value")
+	}
+
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	_, err = writer.Write(valueJSON)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Compressing response value")
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Compressing response value")
+	}
+
+	return compressedValueResponse{
+		Value:      base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Compressed: true,
+	}, nil
+}
+
+func (r compressedValueResponse) Shorten() Response {
+	return r
+}
+
+// DecompressValue reverses NewCompressedValueResponse
+// given value found in the response envelope
+func DecompressValue(encodedValue string, value interface{}) error {
+	compressed, err := base64.StdEncoding.DecodeString(encodedValue)
+	if err != nil {
+		return bosherr.WrapError(err, "Decoding response value")
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return bosherr.WrapError(err, "Decompressing response value")
+	}
+
+	defer reader.Close()
+
+	valueJSON, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return bosherr.WrapError(err, "Decompressing response value")
+	}
+
+	err = json.Unmarshal(valueJSON, value)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshalling response value")
+	}
+
+	return nil
+}
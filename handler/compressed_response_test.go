@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/handler"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type compressedEnvelope struct {
+	Value      string `json:"value"`
+	Compressed bool   `json:"compressed"`
+}
+
+var _ = Describe("NewCompressedValueResponse", func() {
+	It("sets compressed flag and value that can be decompressed", func() {
+		value := map[string]interface{}{"job_state": "running", "processes": []interface{}{"fake-process"}}
+
+		resp, err := NewCompressedValueResponse(value)
+		Expect(err).ToNot(HaveOccurred())
+
+		respJSON, err := json.Marshal(resp)
+		Expect(err).ToNot(HaveOccurred())
+
+		var envelope compressedEnvelope
+		Expect(json.Unmarshal(respJSON, &envelope)).To(Succeed())
+		Expect(envelope.Compressed).To(BeTrue())
+
+		var decompressedValue map[string]interface{}
+		Expect(DecompressValue(envelope.Value, &decompressedValue)).To(Succeed())
+		Expect(decompressedValue).To(Equal(value))
+	})
+
+	It("returns error when value was not compressed", func() {
+		var value interface{}
+		err := DecompressValue("ZmFrZS12YWx1ZQ==", &value)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Decompressing response value"))
+	})
+})
+
+var _ = Describe("PerformHandlerWithJSON", func() {
+	var (
+		logger     boshlog.Logger
+		largeValue string
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		largeValue = strings.Repeat("fake-process-state ", CompressResponseMinLength/10)
+	})
+
+	perform := func(rawJSON string, value string) []byte {
+		respJSON, _, err := PerformHandlerWithJSON([]byte(rawJSON), func(req Request) Response {
+			return NewValueResponse(value)
+		}, UnlimitedResponseLength, logger)
+		Expect(err).ToNot(HaveOccurred())
+		return respJSON
+	}
+
+	It("compresses large response value when director accepts gzip", func() {
+		respJSON := perform(`{"method":"get_state","accept_encoding":"gzip"}`, largeValue)
+		Expect(len(respJSON)).To(BeNumerically("<", len(largeValue)))
+
+		var envelope compressedEnvelope
+		Expect(json.Unmarshal(respJSON, &envelope)).To(Succeed())
+		Expect(envelope.Compressed).To(BeTrue())
+
+		var value string
+		Expect(DecompressValue(envelope.Value, &value)).To(Succeed())
+		Expect(value).To(Equal(largeValue))
+	})
+
+	It("does not compress small response value", func() {
+		respJSON := perform(`{"method":"get_state","accept_encoding":"gzip"}`, "fake-value")
+		Expect(string(respJSON)).To(Equal(`{"value":"fake-value"}`))
+	})
+
+	It("does not compress large response value when director does not accept gzip", func() {
+		respJSON := perform(`{"method":"get_state"}`, largeValue)
+		Expect(string(respJSON)).To(Equal(`{"value":"` + largeValue + `"}`))
+	})
+
+	It("does not compress exception responses", func() {
+		respJSON, _, err := PerformHandlerWithJSON([]byte(`{"method":"get_state","accept_encoding":"gzip"}`), func(req Request) Response {
+			return NewExceptionResponse(errors.New(largeValue))
+		}, UnlimitedResponseLength, logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(respJSON)).To(ContainSubstring(`"exception"`))
+		Expect(string(respJSON)).ToNot(ContainSubstring(`"compressed"`))
+	})
+})
@@ -32,7 +32,7 @@ func PerformHandlerWithJSON(rawJSON []byte, handler Func, maxResponseLength int,
 		return []byte{}, request, nil
 	}
 
-	respJSON, err := marshalResponse(response, maxResponseLength, logger)
+	respJSON, err := marshalResponse(response, request.AcceptsGzip(), maxResponseLength, logger)
 	if err != nil {
 		return respJSON, request, err
 	}
@@ -56,13 +56,29 @@ func BuildErrorWithJSON(msg string, logger boshlog.Logger) ([]byte, error) {
 	return respJSON, nil
 }
 
-func marshalResponse(response Response, maxResponseLength int, logger boshlog.Logger) ([]byte, error) {
+func marshalResponse(response Response, acceptsGzip bool, maxResponseLength int, logger boshlog.Logger) ([]byte, error) {
 	respJSON, err := json.Marshal(response)
 	if err != nil {
 		logger.Error(mbusHandlerLogTag, "Failed to marshal response: %s", err.Error())
 		return respJSON, bosherr.WrapError(err, "Marshalling JSON response")
 	}
 
+	if valueResp, ok := response.(valueResponse); ok && acceptsGzip && len(respJSON) >= CompressResponseMinLength {
+		compressedResp, err := NewCompressedValueResponse(valueResp.Value)
+		if err != nil {
+			logger.Error(mbusHandlerLogTag, "Failed to compress response: %s", err.Error())
+			return respJSON, bosherr.WrapError(err, "Compressing response")
+		}
+
+		response = compressedResp
+
+		respJSON, err = json.Marshal(response)
+		if err != nil {
+			logger.Error(mbusHandlerLogTag, "Failed to marshal response: %s", err.Error())
+			return respJSON, bosherr.WrapError(err, "Marshalling JSON response")
+		}
+	}
+
 	if maxResponseLength == UnlimitedResponseLength {
 		return respJSON, nil
 	}
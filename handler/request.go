@@ -1,5 +1,9 @@
 package handler
 
+import (
+	"time"
+)
+
 func NewRequest(replyTo, method string, payload []byte) Request {
 	return Request{
 		ReplyTo: replyTo,
@@ -11,9 +15,26 @@ func NewRequest(replyTo, method string, payload []byte) Request {
 type Request struct {
 	ReplyTo string `json:"reply_to"`
 	Method  string
+
+	// AcceptEncoding is set by directors that can decompress large responses
+	AcceptEncoding string `json:"accept_encoding"`
+
+	// TimeoutSeconds is set by directors that give up on long-running
+	// actions; action is canceled once it elapses; 0 means no timeout.
+	// Only cancelable actions accept it.
+	TimeoutSeconds int `json:"timeout"`
+
 	Payload []byte
 }
 
 func (r Request) GetPayload() []byte {
 	return r.Payload
 }
+
+func (r Request) Timeout() time.Duration {
+	return time.Duration(r.TimeoutSeconds) * time.Second
+}
+
+func (r Request) AcceptsGzip() bool {
+	return r.AcceptEncoding == gzipEncoding
+}
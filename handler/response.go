@@ -10,6 +10,16 @@ type Response interface {
 	Shorten() Response
 }
 
+// NewResponse wraps action result in the envelope expected by the director:
+// {"value": ...} on success and {"exception": {"message": ...}} on failure
+func NewResponse(value interface{}, err error) Response {
+	if err != nil {
+		return NewExceptionResponse(err)
+	}
+
+	return NewValueResponse(value)
+}
+
 type valueResponse struct {
 	Value interface{} `json:"value"`
 }
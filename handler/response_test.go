@@ -22,6 +22,18 @@ func (e *testShortError) ShortError() string {
 	return msg
 }
 
+var _ = Describe("NewResponse", func() {
+	It("wraps value in value envelope when there is no error", func() {
+		resp := NewResponse(map[string]string{"agent_task_id": "fake-task-id"}, nil)
+		boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":{"agent_task_id":"fake-task-id"}}`)
+	})
+
+	It("wraps error in exception envelope without value", func() {
+		resp := NewResponse("fake-value", errors.New("fake-err"))
+		boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"fake-err"}}`)
+	})
+})
+
 var _ = Describe("NewValueResponse", func() {
 	It("can be serialized to JSON", func() {
 		resp := NewValueResponse("fake-value")
@@ -0,0 +1,13 @@
+package httptransport_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HTTP Transport Suite")
+}
@@ -0,0 +1,38 @@
+package httptransport
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// Transport returns roundTripper whose TLS settings can be changed;
+// http.DefaultTransport is used when roundTripper is nil. Other round trippers
+// are rejected since replacing them would silently drop their proxy settings.
+func Transport(roundTripper http.RoundTripper) (*http.Transport, error) {
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+
+	transport, ok := roundTripper.(*http.Transport)
+	if !ok {
+		return nil, bosherr.Errorf("Configuring TLS of HTTP transport %T is not supported", roundTripper)
+	}
+
+	return transport, nil
+}
+
+// WithTLSConfig returns copy of transport with TLS config changed by configure;
+// proxy, keep-alive and other settings of transport are kept
+func WithTLSConfig(transport *http.Transport, configure func(*tls.Config)) *http.Transport {
+	transport = transport.Clone()
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	configure(transport.TLSClientConfig)
+
+	return transport
+}
@@ -0,0 +1,68 @@
+package httptransport_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/httptransport"
+)
+
+type fakeRoundTripper struct{}
+
+func (fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+var _ = Describe("Transport", func() {
+	It("returns given transport", func() {
+		transport := &http.Transport{}
+
+		result, err := Transport(transport)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result == transport).To(BeTrue())
+	})
+
+	It("returns default transport when round tripper is not set", func() {
+		result, err := Transport(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result == http.DefaultTransport).To(BeTrue())
+	})
+
+	It("returns error for round trippers whose TLS settings cannot be changed", func() {
+		_, err := Transport(fakeRoundTripper{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Configuring TLS of HTTP transport httptransport_test.fakeRoundTripper is not supported"))
+	})
+})
+
+var _ = Describe("WithTLSConfig", func() {
+	It("changes TLS config of copy and keeps other settings", func() {
+		proxyURL, err := url.Parse("http://fake-proxy")
+		Expect(err).ToNot(HaveOccurred())
+
+		transport := &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+		}
+
+		result := WithTLSConfig(transport, func(config *tls.Config) {
+			config.ServerName = "fake-server-name"
+		})
+
+		Expect(result == transport).To(BeFalse())
+		Expect(result.Proxy).ToNot(BeNil())
+		Expect(result.TLSClientConfig.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+		Expect(result.TLSClientConfig.ServerName).To(Equal("fake-server-name"))
+		Expect(transport.TLSClientConfig.ServerName).To(BeEmpty())
+	})
+
+	It("creates TLS config when transport does not have one", func() {
+		result := WithTLSConfig(&http.Transport{}, func(config *tls.Config) {
+			config.ServerName = "fake-server-name"
+		})
+
+		Expect(result.TLSClientConfig.ServerName).To(Equal("fake-server-name"))
+	})
+})
@@ -0,0 +1,177 @@
+package agentlogger
+
+import (
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+)
+
+type SinkOptions struct {
+	// Defaults to stdout
+	Destination string
+
+	// Required when Destination is file
+	FilePath string
+
+	// e.g. local3; defaults to user
+	SyslogFacility string
+
+	// Local syslog daemon is used when address is empty
+	SyslogNetwork string
+	SyslogAddress string
+
+	// e.g. INFO; current level is kept when empty
+	Level string
+}
+
+// SinkLogger is a logger whose destination can be changed after it
+// has been handed out, e.g. once agent config has been loaded.
+type SinkLogger interface {
+	boshlog.Logger
+	SwitchSink(options SinkOptions) error
+}
+
+type sinkLogger struct {
+	stdout io.Writer
+	stderr io.Writer
+
+	mutex       sync.RWMutex
+	level       boshlog.LogLevel
+	forcedDebug bool
+	logger      boshlog.Logger
+	closers     []io.Closer
+}
+
+// NewSinkLogger starts out logging to stdout and stderr
+func NewSinkLogger(level boshlog.LogLevel, stdout, stderr io.Writer) SinkLogger {
+	return &sinkLogger{
+		stdout: stdout,
+		stderr: stderr,
+		level:  level,
+		logger: boshlog.NewWriterLogger(level, stdout, stderr),
+	}
+}
+
+func (l *sinkLogger) SwitchSink(options SinkOptions) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	level := l.level
+
+	if options.Level != "" {
+		var err error
+
+		level, err = boshlog.Levelify(options.Level)
+		if err != nil {
+			return bosherr.WrapError(err, "Parsing log level")
+		}
+	}
+
+	outWriter, errWriter, closers, err := l.openSink(options)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening log destination '%s'", options.Destination)
+	}
+
+	logger := boshlog.NewWriterLogger(level, outWriter, errWriter)
+	if l.forcedDebug {
+		logger.ToggleForcedDebug()
+	}
+
+	for _, closer := range l.closers {
+		_ = closer.Close()
+	}
+
+	l.level = level
+	l.logger = logger
+	l.closers = closers
+
+	return nil
+}
+
+func (l *sinkLogger) openSink(options SinkOptions) (io.Writer, io.Writer, []io.Closer, error) {
+	switch options.Destination {
+	case "", SinkStdout:
+		return l.stdout, l.stderr, nil, nil
+
+	case SinkFile:
+		if options.FilePath == "" {
+			return nil, nil, nil, bosherr.Error("File path must be specified")
+		}
+
+		// Not using boshsys.FileSystem since it logs through this logger
+		file, err := os.OpenFile(options.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0640))
+		if err != nil {
+			return nil, nil, nil, bosherr.WrapErrorf(err, "Opening log file '%s'", options.FilePath)
+		}
+
+		return file, file, []io.Closer{file}, nil
+
+	case SinkSyslog:
+		outWriter, errWriter, err := openSyslogSink(options)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return outWriter, errWriter, []io.Closer{outWriter, errWriter}, nil
+
+	default:
+		return nil, nil, nil, bosherr.Errorf("Unknown log destination '%s'", options.Destination)
+	}
+}
+
+func (l *sinkLogger) current() boshlog.Logger {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.logger
+}
+
+func (l *sinkLogger) Debug(tag, msg string, args ...interface{}) {
+	l.current().Debug(tag, msg, args...)
+}
+
+func (l *sinkLogger) DebugWithDetails(tag, msg string, args ...interface{}) {
+	l.current().DebugWithDetails(tag, msg, args...)
+}
+
+func (l *sinkLogger) Info(tag, msg string, args ...interface{}) {
+	l.current().Info(tag, msg, args...)
+}
+
+func (l *sinkLogger) Warn(tag, msg string, args ...interface{}) {
+	l.current().Warn(tag, msg, args...)
+}
+
+func (l *sinkLogger) Error(tag, msg string, args ...interface{}) {
+	l.current().Error(tag, msg, args...)
+}
+
+func (l *sinkLogger) ErrorWithDetails(tag, msg string, args ...interface{}) {
+	l.current().ErrorWithDetails(tag, msg, args...)
+}
+
+func (l *sinkLogger) HandlePanic(tag string) {
+	// Wrapped logger's HandlePanic would not see the panic
+	// since recover only works in the deferred function itself
+	panic := recover()
+	if panic != nil {
+		l.current().ErrorWithDetails(tag, "Panic: %v", panic, debug.Stack())
+		os.Exit(2)
+	}
+}
+
+func (l *sinkLogger) ToggleForcedDebug() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.forcedDebug = !l.forcedDebug
+	l.logger.ToggleForcedDebug()
+}
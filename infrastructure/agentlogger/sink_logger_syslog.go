@@ -0,0 +1,64 @@
+// +build !windows
+
+package agentlogger
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const sinkSyslogTag = "bosh-agent"
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// openSyslogSink returns separate writers so that errors
+// are sent with err severity while other messages use info
+func openSyslogSink(options SinkOptions) (io.WriteCloser, io.WriteCloser, error) {
+	facility := syslog.LOG_USER
+
+	if options.SyslogFacility != "" {
+		var found bool
+
+		facility, found = syslogFacilities[strings.ToLower(options.SyslogFacility)]
+		if !found {
+			return nil, nil, bosherr.Errorf("Unknown syslog facility '%s'", options.SyslogFacility)
+		}
+	}
+
+	outWriter, err := syslog.Dial(options.SyslogNetwork, options.SyslogAddress, facility|syslog.LOG_INFO, sinkSyslogTag)
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Connecting to syslog")
+	}
+
+	errWriter, err := syslog.Dial(options.SyslogNetwork, options.SyslogAddress, facility|syslog.LOG_ERR, sinkSyslogTag)
+	if err != nil {
+		_ = outWriter.Close()
+		return nil, nil, bosherr.WrapError(err, "Connecting to syslog")
+	}
+
+	return outWriter, errWriter, nil
+}
@@ -0,0 +1,13 @@
+// +build windows
+
+package agentlogger
+
+import (
+	"io"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func openSyslogSink(options SinkOptions) (io.WriteCloser, io.WriteCloser, error) {
+	return nil, nil, bosherr.Error("Logging to syslog is not supported on windows")
+}
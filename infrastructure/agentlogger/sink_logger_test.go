@@ -0,0 +1,205 @@
+package agentlogger_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure/agentlogger"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("SinkLogger", func() {
+	var (
+		outBuf *bytes.Buffer
+		errBuf *bytes.Buffer
+		logger SinkLogger
+	)
+
+	BeforeEach(func() {
+		outBuf = new(bytes.Buffer)
+		errBuf = new(bytes.Buffer)
+		logger = NewSinkLogger(boshlog.LevelInfo, outBuf, errBuf)
+	})
+
+	It("logs formatted lines to stdout and stderr by default", func() {
+		logger.Info("fake-tag", "fake-%s", "info")
+		logger.Error("fake-tag", "fake-%s", "error")
+
+		Expect(outBuf.String()).To(MatchRegexp(`\[fake-tag\] .* INFO - fake-info\n`))
+		Expect(errBuf.String()).To(MatchRegexp(`\[fake-tag\] .* ERROR - fake-error\n`))
+	})
+
+	Describe("SwitchSink", func() {
+		Context("when destination is stdout", func() {
+			It("logs formatted lines to stdout", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkStdout, Level: "DEBUG"})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Debug("fake-tag", "fake-debug")
+				Expect(outBuf.String()).To(MatchRegexp(`\[fake-tag\] .* DEBUG - fake-debug\n`))
+			})
+		})
+
+		Context("when destination is file", func() {
+			var (
+				tmpDir  string
+				logPath string
+			)
+
+			BeforeEach(func() {
+				var err error
+				tmpDir, err = ioutil.TempDir("", "sink-logger")
+				Expect(err).ToNot(HaveOccurred())
+
+				logPath = filepath.Join(tmpDir, "agent.log")
+			})
+
+			AfterEach(func() {
+				_ = os.RemoveAll(tmpDir)
+			})
+
+			It("appends formatted lines to the file", func() {
+				err := ioutil.WriteFile(logPath, []byte("existing-line\n"), 0640)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = logger.SwitchSink(SinkOptions{Destination: SinkFile, FilePath: logPath})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Info("fake-tag", "fake-info")
+				logger.Error("fake-tag", "fake-error")
+
+				contents, err := ioutil.ReadFile(logPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(MatchRegexp(`^existing-line\n.*\[fake-tag\] .* INFO - fake-info\n.*\[fake-tag\] .* ERROR - fake-error\n$`))
+
+				Expect(outBuf.Len()).To(Equal(0))
+				Expect(errBuf.Len()).To(Equal(0))
+			})
+
+			It("keeps current level when level is not specified", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkFile, FilePath: logPath})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Debug("fake-tag", "fake-debug")
+				logger.Info("fake-tag", "fake-info")
+
+				contents, err := ioutil.ReadFile(logPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).ToNot(ContainSubstring("fake-debug"))
+				Expect(string(contents)).To(ContainSubstring("fake-info"))
+			})
+
+			It("keeps configured level when switching again", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkFile, FilePath: logPath, Level: "ERROR"})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = logger.SwitchSink(SinkOptions{Destination: SinkStdout})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Info("fake-tag", "fake-info")
+				logger.Error("fake-tag", "fake-error")
+
+				Expect(outBuf.String()).ToNot(ContainSubstring("fake-info"))
+				Expect(errBuf.String()).To(ContainSubstring("fake-error"))
+			})
+
+			It("keeps forced debug when switching", func() {
+				logger.ToggleForcedDebug()
+
+				err := logger.SwitchSink(SinkOptions{Destination: SinkFile, FilePath: logPath})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Debug("fake-tag", "fake-debug")
+
+				contents, err := ioutil.ReadFile(logPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(ContainSubstring("fake-debug"))
+			})
+
+			It("returns error when file path is not specified", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkFile})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("File path must be specified"))
+			})
+
+			It("returns error and keeps logging to previous destination when file cannot be opened", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkFile, FilePath: filepath.Join(tmpDir, "missing", "agent.log")})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Opening log file"))
+
+				logger.Info("fake-tag", "fake-info")
+				Expect(outBuf.String()).To(ContainSubstring("fake-info"))
+			})
+		})
+
+		Context("when destination is syslog", func() {
+			var conn net.PacketConn
+
+			BeforeEach(func() {
+				var err error
+				conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				_ = conn.Close()
+			})
+
+			readMessage := func() string {
+				buf := make([]byte, 4096)
+				err := conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				Expect(err).ToNot(HaveOccurred())
+
+				n, _, err := conn.ReadFrom(buf)
+				Expect(err).ToNot(HaveOccurred())
+				return string(buf[:n])
+			}
+
+			It("sends formatted lines with configured facility and severity", func() {
+				err := logger.SwitchSink(SinkOptions{
+					Destination:    SinkSyslog,
+					SyslogFacility: "local3",
+					SyslogNetwork:  "udp",
+					SyslogAddress:  conn.LocalAddr().String(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				logger.Info("fake-tag", "fake-info")
+				// local3 (19) * 8 + info (6)
+				Expect(readMessage()).To(MatchRegexp(`^<158>.* bosh-agent\[\d+\]: .*\[fake-tag\] .* INFO - fake-info\n$`))
+
+				logger.Error("fake-tag", "fake-error")
+				// local3 (19) * 8 + err (3)
+				Expect(readMessage()).To(MatchRegexp(`^<155>.* bosh-agent\[\d+\]: .*\[fake-tag\] .* ERROR - fake-error\n$`))
+			})
+
+			It("returns error for unknown facility", func() {
+				err := logger.SwitchSink(SinkOptions{Destination: SinkSyslog, SyslogFacility: "fake-facility"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Unknown syslog facility 'fake-facility'"))
+			})
+		})
+
+		It("returns error and keeps level for unknown level", func() {
+			err := logger.SwitchSink(SinkOptions{Level: "fake-level"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing log level"))
+
+			logger.Debug("fake-tag", "fake-debug")
+			Expect(outBuf.String()).To(BeEmpty())
+		})
+
+		It("returns error for unknown destination", func() {
+			err := logger.SwitchSink(SinkOptions{Destination: "fake-destination"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unknown log destination 'fake-destination'"))
+		})
+	})
+})
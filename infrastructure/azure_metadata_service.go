@@ -0,0 +1,212 @@
+package infrastructure
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	boshplat "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	DefaultAzureMetadataHost   = "http://169.254.169.254"
+	DefaultAzureCustomDataPath = "/var/lib/waagent/CustomData"
+	azureInstanceComputePath   = "/metadata/instance/compute?api-version=2019-06-01"
+	azureVcapHomeDir           = "/home/vcap/"
+)
+
+// Azure instance metadata service rejects requests without this header
+var azureMetadataHeaders = map[string]string{"Metadata": "true"}
+
+type azureComputeMetadata struct {
+	Name       string `json:"name"`
+	PublicKeys []struct {
+		KeyData string `json:"keyData"`
+		Path    string `json:"path"`
+	} `json:"publicKeys"`
+}
+
+type azureMetadataService struct {
+	metadataHost   string
+	customDataPath string
+	resolver       DNSResolver
+	platform       boshplat.Platform
+	httpClient     HTTPClient
+	logTag         string
+	logger         boshlog.Logger
+}
+
+func NewAzureMetadataService(
+	metadataHost string,
+	customDataPath string,
+	resolver DNSResolver,
+	platform boshplat.Platform,
+	httpClient HTTPClient,
+	logger boshlog.Logger,
+) MetadataService {
+	if metadataHost == "" {
+		metadataHost = DefaultAzureMetadataHost
+	}
+
+	if customDataPath == "" {
+		customDataPath = DefaultAzureCustomDataPath
+	}
+
+	return azureMetadataService{
+		metadataHost:   metadataHost,
+		customDataPath: customDataPath,
+		resolver:       resolver,
+		platform:       platform,
+		httpClient:     httpClient,
+		logTag:         "azureMetadataService",
+		logger:         logger,
+	}
+}
+
+func (ms azureMetadataService) IsAvailable() bool {
+	return ms.platform.GetFs().FileExists(ms.customDataPath)
+}
+
+// GetPublicKey returns the key installed for vcap, or the first key when none is specific to vcap
+func (ms azureMetadataService) GetPublicKey() (string, error) {
+	compute, err := ms.getComputeMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	for _, publicKey := range compute.PublicKeys {
+		if strings.HasPrefix(publicKey.Path, azureVcapHomeDir) {
+			return strings.TrimSpace(publicKey.KeyData), nil
+		}
+	}
+
+	if len(compute.PublicKeys) > 0 {
+		return strings.TrimSpace(compute.PublicKeys[0].KeyData), nil
+	}
+
+	return "", nil
+}
+
+func (ms azureMetadataService) GetInstanceID() (string, error) {
+	compute, err := ms.getComputeMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	if compute.Name == "" {
+		return "", bosherr.Error("Empty instance name in Azure instance metadata")
+	}
+
+	return compute.Name, nil
+}
+
+func (ms azureMetadataService) GetServerName() (string, error) {
+	customData, err := ms.getCustomData()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Getting custom data")
+	}
+
+	serverName := customData.Server.Name
+
+	if len(serverName) == 0 {
+		return "", bosherr.Error("Empty server name")
+	}
+
+	return serverName, nil
+}
+
+func (ms azureMetadataService) GetRegistryEndpoint() (string, error) {
+	customData, err := ms.getCustomData()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Getting custom data")
+	}
+
+	endpoint := customData.Registry.Endpoint
+	nameServers := customData.DNS.Nameserver
+
+	if len(nameServers) > 0 {
+		endpoint, err = ms.resolver.LookupHost(nameServers, endpoint)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Resolving registry endpoint")
+		}
+	}
+
+	return endpoint, nil
+}
+
+func (ms azureMetadataService) GetNetworks() (boshsettings.Networks, error) {
+	return nil, nil
+}
+
+// getCustomData reads custom data placed on disk by the provisioning agent.
+// Depending on the agent version it is either plain JSON or base64 encoded JSON.
+func (ms azureMetadataService) getCustomData() (UserDataContentsType, error) {
+	var customData UserDataContentsType
+
+	contents, err := ms.platform.GetFs().ReadFile(ms.customDataPath)
+	if err != nil {
+		return customData, bosherr.WrapErrorf(err, "Reading custom data from %s", ms.customDataPath)
+	}
+
+	contents = []byte(strings.TrimSpace(string(contents)))
+
+	if !json.Valid(contents) {
+		decoded, err := base64.StdEncoding.DecodeString(string(contents))
+		if err != nil {
+			return customData, bosherr.WrapError(err, "Decoding base64 custom data")
+		}
+		contents = decoded
+	}
+
+	err = json.Unmarshal(contents, &customData)
+	if err != nil {
+		return customData, bosherr.WrapError(err, "Unmarshalling custom data")
+	}
+
+	ms.logger.Debug(ms.logTag, "Read custom data '%#v'", customData)
+
+	return customData, nil
+}
+
+func (ms azureMetadataService) getComputeMetadata() (azureComputeMetadata, error) {
+	var compute azureComputeMetadata
+
+	err := ensureMinimalNetworkSetup(ms.platform, ms.logTag, ms.logger)
+	if err != nil {
+		return compute, err
+	}
+
+	url := fmt.Sprintf("%s%s", ms.metadataHost, azureInstanceComputePath)
+	resp, err := ms.httpClient.Get(url, azureMetadataHeaders)
+	if err != nil {
+		return compute, bosherr.WrapErrorf(err, "Getting instance metadata from url %s", url)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			ms.logger.Warn(ms.logTag, "Failed to close response body when getting instance metadata: %s", err.Error())
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return compute, bosherr.Errorf("Getting instance metadata from url %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return compute, bosherr.WrapError(err, "Reading instance metadata response body")
+	}
+
+	err = json.Unmarshal(bytes, &compute)
+	if err != nil {
+		return compute, bosherr.WrapError(err, "Unmarshalling instance metadata")
+	}
+
+	return compute, nil
+}
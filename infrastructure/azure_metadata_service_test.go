@@ -0,0 +1,212 @@
+package infrastructure_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	fakeplat "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("AzureMetadataService", func() {
+	var (
+		ts              *httptest.Server
+		computeMetadata string
+		platform        *fakeplat.FakePlatform
+		dnsResolver     *fakeinf.FakeDNSResolver
+		metadataService MetadataService
+	)
+
+	BeforeEach(func() {
+		computeMetadata = `{"name": "fake-vm-name", "publicKeys": []}`
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			Expect(r.URL.Path).To(Equal("/metadata/instance/compute"))
+			Expect(r.URL.Query().Get("api-version")).ToNot(BeEmpty())
+
+			w.Write([]byte(computeMetadata))
+		})
+		ts = httptest.NewServer(handler)
+
+		platform = fakeplat.NewFakePlatform()
+		platform.GetConfiguredNetworkInterfacesInterfaces = []string{"fake-interface"}
+		dnsResolver = &fakeinf.FakeDNSResolver{}
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		httpClient := NewHTTPClient(&http.Client{}, "fake-user-agent")
+		metadataService = NewAzureMetadataService(ts.URL, "/fake-custom-data", dnsResolver, platform, httpClient, logger)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	Describe("IsAvailable", func() {
+		It("returns true when custom data is present", func() {
+			platform.Fs.WriteFileString("/fake-custom-data", "{}")
+			Expect(metadataService.IsAvailable()).To(BeTrue())
+		})
+
+		It("returns false when custom data is missing", func() {
+			Expect(metadataService.IsAvailable()).To(BeFalse())
+		})
+	})
+
+	Describe("GetServerName", func() {
+		It("returns server name from plain JSON custom data", func() {
+			platform.Fs.WriteFileString("/fake-custom-data", `{"server":{"name":"fake-server-name"}}`)
+
+			serverName, err := metadataService.GetServerName()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serverName).To(Equal("fake-server-name"))
+		})
+
+		It("returns server name from base64 encoded custom data", func() {
+			encoded := base64.StdEncoding.EncodeToString([]byte(`{"server":{"name":"fake-server-name"}}`))
+			platform.Fs.WriteFileString("/fake-custom-data", encoded+"\n")
+
+			serverName, err := metadataService.GetServerName()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serverName).To(Equal("fake-server-name"))
+		})
+
+		It("returns an error when custom data can not be decoded", func() {
+			platform.Fs.WriteFileString("/fake-custom-data", "not-json-or-base64!")
+
+			_, err := metadataService.GetServerName()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Decoding base64 custom data"))
+		})
+
+		It("returns an error when server name is empty", func() {
+			platform.Fs.WriteFileString("/fake-custom-data", `{}`)
+
+			_, err := metadataService.GetServerName()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Empty server name"))
+		})
+
+		It("returns an error when custom data is missing", func() {
+			_, err := metadataService.GetServerName()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading custom data from /fake-custom-data"))
+		})
+	})
+
+	Describe("GetRegistryEndpoint", func() {
+		It("returns the registry endpoint from custom data", func() {
+			platform.Fs.WriteFileString("/fake-custom-data", `{"registry":{"endpoint":"http://fake-registry.com"}}`)
+
+			endpoint, err := metadataService.GetRegistryEndpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("http://fake-registry.com"))
+		})
+
+		Context("when nameservers are provided", func() {
+			BeforeEach(func() {
+				encoded := base64.StdEncoding.EncodeToString([]byte(`{
+					"registry":{"endpoint":"http://fake-registry.com"},
+					"dns":{"nameserver":["fake-dns-server-ip"]}
+				}`))
+				platform.Fs.WriteFileString("/fake-custom-data", encoded)
+			})
+
+			It("resolves the registry endpoint", func() {
+				dnsResolver.RegisterRecord(fakeinf.FakeDNSRecord{
+					DNSServers: []string{"fake-dns-server-ip"},
+					Host:       "http://fake-registry.com",
+					IP:         "http://fake-registry-ip",
+				})
+
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://fake-registry-ip"))
+			})
+
+			It("returns an error when resolving fails", func() {
+				dnsResolver.LookupHostErr = errors.New("fake-lookup-host-err")
+
+				_, err := metadataService.GetRegistryEndpoint()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-lookup-host-err"))
+			})
+		})
+	})
+
+	Describe("GetInstanceID", func() {
+		It("returns the vm name from instance metadata", func() {
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("fake-vm-name"))
+		})
+
+		It("returns an error when the vm name is empty", func() {
+			computeMetadata = `{}`
+
+			_, err := metadataService.GetInstanceID()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Empty instance name"))
+		})
+
+		Context("when no networks are configured", func() {
+			BeforeEach(func() {
+				platform.GetConfiguredNetworkInterfacesInterfaces = []string{}
+			})
+
+			It("sets up DHCP network before contacting instance metadata", func() {
+				_, err := metadataService.GetInstanceID()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(platform.SetupNetworkingNetworks).To(Equal(boshsettings.Networks{
+					"eth0": boshsettings.Network{Type: "dynamic"},
+				}))
+			})
+		})
+	})
+
+	Describe("GetPublicKey", func() {
+		It("returns the key installed for vcap", func() {
+			computeMetadata = `{"publicKeys": [
+				{"keyData": "fake-other-key", "path": "/home/other/.ssh/authorized_keys"},
+				{"keyData": "fake-vcap-key\n", "path": "/home/vcap/.ssh/authorized_keys"}
+			]}`
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("fake-vcap-key"))
+		})
+
+		It("falls back to the first key", func() {
+			computeMetadata = `{"publicKeys": [{"keyData": "fake-other-key", "path": "/home/other/.ssh/authorized_keys"}]}`
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("fake-other-key"))
+		})
+
+		It("returns an empty key when there are no keys", func() {
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(BeEmpty())
+		})
+	})
+
+	Describe("GetNetworks", func() {
+		It("returns no networks so that DHCP is used", func() {
+			networks, err := metadataService.GetNetworks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks).To(BeNil())
+		})
+	})
+})
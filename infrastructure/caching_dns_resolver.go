@@ -0,0 +1,140 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const cachingDNSResolverLogTag = "cachingDNSResolver"
+
+type dnsCacheEntry struct {
+	IP        string    `json:"ip"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CachingDNSResolver struct {
+	resolver    DNSResolver
+	ttl         time.Duration
+	cachePath   string
+	fs          boshsys.FileSystem
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	entries map[string]dnsCacheEntry
+	loaded  bool
+	lock    sync.Mutex
+}
+
+// NewCachingDNSResolver remembers resolved hosts for ttl and returns them
+// when lookup fails, e.g. when DNS is temporarily down during boot.
+// Entries are persisted to cachePath so that they survive agent restarts;
+// they are only kept in memory when cachePath is empty.
+func NewCachingDNSResolver(
+	resolver DNSResolver,
+	ttl time.Duration,
+	cachePath string,
+	fs boshsys.FileSystem,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) *CachingDNSResolver {
+	return &CachingDNSResolver{
+		resolver:    resolver,
+		ttl:         ttl,
+		cachePath:   cachePath,
+		fs:          fs,
+		timeService: timeService,
+		logger:      logger,
+		entries:     map[string]dnsCacheEntry{},
+	}
+}
+
+func (r *CachingDNSResolver) LookupHost(dnsServers []string, host string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.load()
+
+	ip, err := r.resolver.LookupHost(dnsServers, host)
+	if err == nil {
+		r.entries[host] = dnsCacheEntry{IP: ip, ExpiresAt: r.timeService.Now().Add(r.ttl)}
+		r.save()
+		return ip, nil
+	}
+
+	entry, found := r.entries[host]
+	if !found || r.isStale(entry) {
+		return "", err
+	}
+
+	r.logger.Warn(cachingDNSResolverLogTag, "Using cached address '%s' for host '%s' after failing to resolve it: %s", entry.IP, host, err.Error())
+
+	return entry.IP, nil
+}
+
+func (r *CachingDNSResolver) isStale(entry dnsCacheEntry) bool {
+	return !r.timeService.Now().Before(entry.ExpiresAt)
+}
+
+// load is not fatal since cache only helps when DNS is down
+func (r *CachingDNSResolver) load() {
+	if r.loaded || r.cachePath == "" {
+		return
+	}
+
+	r.loaded = true
+
+	if !r.fs.FileExists(r.cachePath) {
+		return
+	}
+
+	err := r.readEntries()
+	if err != nil {
+		r.logger.Warn(cachingDNSResolverLogTag, "Ignoring DNS cache: %s", err.Error())
+	}
+}
+
+func (r *CachingDNSResolver) readEntries() error {
+	bytes, err := r.fs.ReadFile(r.cachePath)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading DNS cache")
+	}
+
+	entries := map[string]dnsCacheEntry{}
+
+	err = json.Unmarshal(bytes, &entries)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshalling DNS cache")
+	}
+
+	for host, entry := range entries {
+		if !r.isStale(entry) {
+			r.entries[host] = entry
+		}
+	}
+
+	return nil
+}
+
+func (r *CachingDNSResolver) save() {
+	if r.cachePath == "" {
+		return
+	}
+
+	bytes, err := json.Marshal(r.entries)
+	if err != nil {
+		r.logger.Warn(cachingDNSResolverLogTag, "Marshalling DNS cache: %s", err.Error())
+		return
+	}
+
+	err = r.fs.WriteFile(r.cachePath, bytes)
+	if err != nil {
+		r.logger.Warn(cachingDNSResolverLogTag, "Writing DNS cache: %s", err.Error())
+	}
+}
@@ -0,0 +1,132 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CachingDNSResolver", func() {
+	const cachePath = "/var/vcap/bosh/dns_cache.json"
+
+	var (
+		dnsResolver *fakeinf.FakeDNSResolver
+		fs          *fakesys.FakeFileSystem
+		timeService *fakeclock.FakeClock
+		logger      boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		dnsResolver = &fakeinf.FakeDNSResolver{}
+		dnsResolver.RegisterRecord(fakeinf.FakeDNSRecord{
+			DNSServers: []string{"8.8.8.8"},
+			Host:       "fake-registry-host",
+			IP:         "1.2.3.4",
+		})
+
+		fs = fakesys.NewFakeFileSystem()
+		timeService = fakeclock.NewFakeClock(time.Now())
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	newResolver := func(path string) *CachingDNSResolver {
+		return NewCachingDNSResolver(dnsResolver, time.Hour, path, fs, timeService, logger)
+	}
+
+	It("returns resolved address", func() {
+		ip, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("1.2.3.4"))
+	})
+
+	It("writes resolved address with expiry to cache file", func() {
+		_, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+
+		contents, err := fs.ReadFile(cachePath)
+		Expect(err).ToNot(HaveOccurred())
+
+		var entries map[string]map[string]interface{}
+		Expect(json.Unmarshal(contents, &entries)).To(Succeed())
+		Expect(entries["fake-registry-host"]["ip"]).To(Equal("1.2.3.4"))
+
+		expiresAt, err := time.Parse(time.RFC3339Nano, entries["fake-registry-host"]["expires_at"].(string))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expiresAt.Equal(timeService.Now().Add(time.Hour))).To(BeTrue())
+	})
+
+	It("returns cached address when lookup fails", func() {
+		resolver := newResolver(cachePath)
+
+		_, err := resolver.LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+
+		dnsResolver.LookupHostErr = errors.New("fake-lookup-err")
+
+		ip, err := resolver.LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("1.2.3.4"))
+	})
+
+	It("reloads cached address written before restart when lookup fails", func() {
+		_, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+
+		dnsResolver.LookupHostErr = errors.New("fake-lookup-err")
+
+		ip, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("1.2.3.4"))
+	})
+
+	It("returns lookup error when cached address is stale", func() {
+		_, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+
+		timeService.Increment(time.Hour)
+		dnsResolver.LookupHostErr = errors.New("fake-lookup-err")
+
+		_, err = newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-lookup-err"))
+	})
+
+	It("returns lookup error when host was never resolved", func() {
+		dnsResolver.LookupHostErr = errors.New("fake-lookup-err")
+
+		_, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-lookup-err"))
+	})
+
+	It("ignores cache file that cannot be parsed", func() {
+		fs.WriteFileString(cachePath, "fake-invalid-json")
+
+		ip, err := newResolver(cachePath).LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("1.2.3.4"))
+	})
+
+	It("keeps cache only in memory when cache path is empty", func() {
+		resolver := newResolver("")
+
+		_, err := resolver.LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists(cachePath)).To(BeFalse())
+
+		dnsResolver.LookupHostErr = errors.New("fake-lookup-err")
+
+		ip, err := resolver.LookupHost([]string{"8.8.8.8"}, "fake-registry-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("1.2.3.4"))
+	})
+})
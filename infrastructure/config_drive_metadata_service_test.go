@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -18,6 +22,32 @@ import (
 
 var _ = Describe("ConfigDriveMetadataService", describeConfigDriveMetadataService)
 
+// loadConfigDriveFixture makes every file of fixture config drive
+// readable from diskPath as if the drive was mounted by platform
+func loadConfigDriveFixture(platform *fakeplatform.FakePlatform, diskPath string) {
+	fixturePath := filepath.Join("testdata", "config-drive")
+
+	err := filepath.Walk(fixturePath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		contents, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(fixturePath, filePath)
+		if err != nil {
+			return err
+		}
+
+		platform.SetGetFilesContentsFromDisk(path.Join(diskPath, filepath.ToSlash(relPath)), contents, nil)
+		return nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+}
+
 func describeConfigDriveMetadataService() {
 	var (
 		metadataService MetadataService
@@ -275,4 +305,76 @@ func describeConfigDriveMetadataService() {
 			})
 		})
 	})
+
+	Context("when reading fixture config drive", func() {
+		BeforeEach(func() {
+			platform = fakeplatform.NewFakePlatform()
+			loadConfigDriveFixture(platform, "/dev/disk/by-label/config-2")
+
+			resolver.RegisterRecord(fakeinf.FakeDNSRecord{
+				DNSServers: []string{"10.0.0.2"},
+				Host:       "http://registry.service.internal:25777",
+				IP:         "http://10.0.0.10:25777",
+			})
+
+			metadataService = NewConfigDriveMetadataService(
+				resolver,
+				platform,
+				[]string{"/dev/disk/by-label/CONFIG-2", "/dev/disk/by-label/config-2"},
+				"ec2/latest/meta-data.json",
+				"ec2/latest/user-data.json",
+				logger,
+			)
+		})
+
+		It("reads meta data and user data from the first drive that has them", func() {
+			platform.SetGetFilesContentsFromDisk(
+				"/dev/disk/by-label/CONFIG-2/ec2/latest/meta-data.json", nil, errors.New("fake-mount-err"))
+
+			Expect(metadataService.IsAvailable()).To(BeTrue())
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC fixture@config-drive"))
+
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("i-0a1b2c3d"))
+
+			serverName, err := metadataService.GetServerName()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serverName).To(Equal("vm-3c5e7a9b"))
+
+			Expect(platform.GetFileContentsFromDiskDiskPaths).To(Equal([]string{
+				"/dev/disk/by-label/CONFIG-2",
+				"/dev/disk/by-label/config-2",
+			}))
+		})
+
+		It("resolves registry endpoint with name server from user data", func() {
+			Expect(metadataService.IsAvailable()).To(BeTrue())
+
+			endpoint, err := metadataService.GetRegistryEndpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("http://10.0.0.10:25777"))
+		})
+
+		It("returns networks from user data", func() {
+			Expect(metadataService.IsAvailable()).To(BeTrue())
+
+			networks, err := metadataService.GetNetworks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks).To(Equal(boshsettings.Networks{
+				"default": boshsettings.Network{
+					Type:    "manual",
+					IP:      "10.0.16.5",
+					Netmask: "255.255.240.0",
+					Gateway: "10.0.16.1",
+					Default: []string{"dns", "gateway"},
+					DNS:     []string{"10.0.0.2"},
+					Mac:     "fa:16:3e:11:22:33",
+				},
+			}))
+		})
+	})
 }
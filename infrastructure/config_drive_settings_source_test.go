@@ -125,4 +125,23 @@ var _ = Describe("ConfigDriveSettingsSource", func() {
 			Expect(err.Error()).To(ContainSubstring("fake-read-disk-error-2"))
 		})
 	})
+
+	Context("when reading fixture config drive", func() {
+		It("returns public key from its meta data", func() {
+			platform = fakeplatform.NewFakePlatform()
+			loadConfigDriveFixture(platform, "/dev/disk/by-label/config-2")
+
+			source = NewConfigDriveSettingsSource(
+				[]string{"/dev/disk/by-label/config-2"},
+				"ec2/latest/meta-data.json",
+				"ec2/latest/user-data.json",
+				platform,
+				boshlog.NewLogger(boshlog.LevelNone),
+			)
+
+			publicKey, err := source.PublicSSHKeyForUsername("fake-username")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC fixture@config-drive"))
+		})
+	})
 })
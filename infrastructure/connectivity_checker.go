@@ -0,0 +1,142 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type ConnectivityEndpoint struct {
+	Name string
+
+	// e.g. "http://registry:25777" is checked with HEAD request;
+	// "blobstore:25250" is checked by opening TCP connection
+	Address string
+}
+
+type ConnectivityStatus struct {
+	ConnectivityEndpoint
+
+	Reachable bool
+	Err       error
+}
+
+type ConnectivityReport struct {
+	Statuses []ConnectivityStatus
+}
+
+func (r ConnectivityReport) AllReachable() bool {
+	for _, status := range r.Statuses {
+		if !status.Reachable {
+			return false
+		}
+	}
+	return true
+}
+
+// String summarizes each endpoint on a single line so that
+// provisioning issues can be spotted quickly in agent logs
+func (r ConnectivityReport) String() string {
+	descriptions := []string{}
+
+	for _, status := range r.Statuses {
+		if status.Reachable {
+			descriptions = append(descriptions, fmt.Sprintf("%s (%s): up", status.Name, status.Address))
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("%s (%s): down: %s", status.Name, status.Address, status.Err.Error()))
+		}
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+type ConnectivityChecker interface {
+	Check(endpoints []ConnectivityEndpoint) ConnectivityReport
+}
+
+type connectivityChecker struct {
+	httpClient  *http.Client
+	dialTimeout time.Duration
+	logTag      string
+	logger      boshlog.Logger
+}
+
+func NewConnectivityChecker(httpClient *http.Client, dialTimeout time.Duration, logger boshlog.Logger) ConnectivityChecker {
+	return connectivityChecker{
+		httpClient:  httpClient,
+		dialTimeout: dialTimeout,
+		logTag:      "connectivityChecker",
+		logger:      logger,
+	}
+}
+
+func (c connectivityChecker) Check(endpoints []ConnectivityEndpoint) ConnectivityReport {
+	report := ConnectivityReport{}
+
+	for _, endpoint := range endpoints {
+		// Credentials embedded in URL are neither sent nor reported
+		endpoint.Address = withoutCredentials(endpoint.Address)
+
+		err := c.checkEndpoint(endpoint.Address)
+		if err != nil {
+			c.logger.Debug(c.logTag, "Endpoint %s at '%s' is unreachable: %s", endpoint.Name, endpoint.Address, err.Error())
+		}
+
+		report.Statuses = append(report.Statuses, ConnectivityStatus{
+			ConnectivityEndpoint: endpoint,
+			Reachable:            err == nil,
+			Err:                  err,
+		})
+	}
+
+	return report
+}
+
+func (c connectivityChecker) checkEndpoint(address string) error {
+	if strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://") {
+		return c.checkHTTP(address)
+	}
+
+	return c.checkTCP(address)
+}
+
+// checkHTTP treats any HTTP response as reachable since endpoints
+// may require authentication or not serve their root path
+func (c connectivityChecker) checkHTTP(address string) error {
+	resp, err := c.httpClient.Head(address)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending HEAD request")
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+func (c connectivityChecker) checkTCP(address string) error {
+	conn, err := net.DialTimeout("tcp", address, c.dialTimeout)
+	if err != nil {
+		return bosherr.WrapError(err, "Opening TCP connection")
+	}
+
+	_ = conn.Close()
+
+	return nil
+}
+
+func withoutCredentials(address string) string {
+	endpointURL, err := url.Parse(address)
+	if err != nil || endpointURL.User == nil {
+		return address
+	}
+
+	endpointURL.User = nil
+
+	return endpointURL.String()
+}
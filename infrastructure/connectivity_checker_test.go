@@ -0,0 +1,108 @@
+package infrastructure_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("ConnectivityChecker", func() {
+	var (
+		ts              *httptest.Server
+		receivedMethod  string
+		receivedAuth    bool
+		listener        net.Listener
+		unreachableAddr string
+		checker         ConnectivityChecker
+	)
+
+	BeforeEach(func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedMethod = r.Method
+			_, _, receivedAuth = r.BasicAuth()
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+		ts = httptest.NewServer(handler)
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		unreachableAddr = closedListener.Addr().String()
+		closedListener.Close()
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		checker = NewConnectivityChecker(&http.Client{Timeout: time.Second}, time.Second, logger)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+		listener.Close()
+	})
+
+	It("reports HTTP endpoint as up when it responds with any status", func() {
+		report := checker.Check([]ConnectivityEndpoint{{Name: "registry", Address: ts.URL}})
+
+		Expect(report.AllReachable()).To(BeTrue())
+		Expect(report.Statuses).To(HaveLen(1))
+		Expect(report.Statuses[0].Reachable).To(BeTrue())
+		Expect(receivedMethod).To(Equal("HEAD"))
+	})
+
+	It("does not send or report credentials embedded in HTTP endpoint", func() {
+		address := strings.Replace(ts.URL, "http://", "http://fake-user:fake-password@", 1)
+
+		report := checker.Check([]ConnectivityEndpoint{{Name: "blobstore", Address: address}})
+
+		Expect(report.AllReachable()).To(BeTrue())
+		Expect(receivedAuth).To(BeFalse())
+		Expect(report.String()).ToNot(ContainSubstring("fake-password"))
+		Expect(report.Statuses[0].Address).To(Equal(ts.URL))
+	})
+
+	It("reports TCP endpoint as up when connection can be opened", func() {
+		report := checker.Check([]ConnectivityEndpoint{{Name: "blobstore", Address: listener.Addr().String()}})
+
+		Expect(report.AllReachable()).To(BeTrue())
+		Expect(report.Statuses[0].Reachable).To(BeTrue())
+	})
+
+	It("returns consolidated report of reachable and unreachable endpoints", func() {
+		report := checker.Check([]ConnectivityEndpoint{
+			{Name: "registry", Address: ts.URL},
+			{Name: "blobstore", Address: unreachableAddr},
+			{Name: "other", Address: "http://" + unreachableAddr},
+		})
+
+		Expect(report.AllReachable()).To(BeFalse())
+		Expect(report.Statuses).To(HaveLen(3))
+
+		Expect(report.Statuses[0].Reachable).To(BeTrue())
+		Expect(report.Statuses[0].Err).ToNot(HaveOccurred())
+
+		Expect(report.Statuses[1].Reachable).To(BeFalse())
+		Expect(report.Statuses[1].Err.Error()).To(ContainSubstring("Opening TCP connection"))
+
+		Expect(report.Statuses[2].Reachable).To(BeFalse())
+		Expect(report.Statuses[2].Err.Error()).To(ContainSubstring("Sending HEAD request"))
+
+		Expect(report.String()).To(HavePrefix("registry (" + ts.URL + "): up, blobstore (" + unreachableAddr + "): down: Opening TCP connection"))
+		Expect(report.String()).To(ContainSubstring("other (http://" + unreachableAddr + "): down: Sending HEAD request"))
+	})
+
+	It("returns empty report that is reachable when there are no endpoints", func() {
+		report := checker.Check(nil)
+		Expect(report.AllReachable()).To(BeTrue())
+		Expect(report.String()).To(BeEmpty())
+	})
+})
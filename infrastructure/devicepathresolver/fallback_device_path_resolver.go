@@ -0,0 +1,243 @@
+package devicepathresolver
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	fallbackPollInterval = 1 * time.Second
+	sysBlockSectorSize   = 512
+)
+
+// Virtual block devices that can never be an attached disk
+var ignoredBlockDevicePrefixes = []string{"loop", "ram", "dm-", "sr", "md"}
+
+// Matches partition suffix of device name, e.g. '1' in sda1 or 'p1' in nvme0n1p1
+var partitionSuffixRegexp = regexp.MustCompile(`^p?[0-9]+$`)
+
+type fallbackDevicePathResolver struct {
+	devicePathResolver DevicePathResolver
+	resolvedDevices    *ResolvedDevices
+	diskWaitTimeout    time.Duration
+	fs                 boshsys.FileSystem
+	mountsSearcher     boshdisk.MountsSearcher
+	timeService        clock.Clock
+	logger             boshlog.Logger
+	logTag             string
+}
+
+// NewFallbackDevicePathResolver detects the device by size and model
+// when devicePathResolver does not find an existing device.
+// Detection is only attempted for disk settings that specify size or model.
+// Mounted and partitioned devices, devices used by device mapper
+// and devices in resolvedDevices are never detected.
+func NewFallbackDevicePathResolver(
+	devicePathResolver DevicePathResolver,
+	resolvedDevices *ResolvedDevices,
+	diskWaitTimeout time.Duration,
+	fs boshsys.FileSystem,
+	mountsSearcher boshdisk.MountsSearcher,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+) DevicePathResolver {
+	return fallbackDevicePathResolver{
+		devicePathResolver: devicePathResolver,
+		resolvedDevices:    resolvedDevices,
+		diskWaitTimeout:    diskWaitTimeout,
+		fs:                 fs,
+		mountsSearcher:     mountsSearcher,
+		timeService:        timeService,
+		logger:             logger,
+		logTag:             "fallbackDevicePathResolver",
+	}
+}
+
+func (r fallbackDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.DiskSettings) (string, bool, error) {
+	realPath, timedOut, err := r.devicePathResolver.GetRealDevicePath(diskSettings)
+	if diskSettings.Size == 0 && diskSettings.Model == "" {
+		return realPath, timedOut, err
+	}
+
+	if err == nil && r.fs.FileExists(realPath) {
+		return realPath, false, nil
+	}
+
+	if err != nil {
+		r.logger.Debug(r.logTag, "Failed to resolve disk %+v: %s", diskSettings, err.Error())
+	} else {
+		r.logger.Debug(r.logTag, "Resolved device path '%s' for disk %+v does not exist", realPath, diskSettings)
+	}
+
+	var waited time.Duration
+
+	for {
+		devicePaths, err := r.findMatchingDevices(diskSettings)
+		if err != nil {
+			return "", false, err
+		}
+
+		if len(devicePaths) == 1 {
+			r.logger.Info(r.logTag, "Detected device '%s' for disk %+v by size and model", devicePaths[0], diskSettings)
+			return devicePaths[0], false, nil
+		}
+
+		if len(devicePaths) > 1 {
+			return "", false, bosherr.Errorf(
+				"Found multiple devices matching size %d MiB and model '%s': %s",
+				diskSettings.Size, diskSettings.Model, strings.Join(devicePaths, ", "),
+			)
+		}
+
+		if waited >= r.diskWaitTimeout {
+			return "", true, bosherr.Errorf(
+				"Timed out after %s detecting device matching size %d MiB and model '%s'",
+				r.diskWaitTimeout, diskSettings.Size, diskSettings.Model,
+			)
+		}
+
+		r.timeService.Sleep(fallbackPollInterval)
+		waited += fallbackPollInterval
+	}
+}
+
+func (r fallbackDevicePathResolver) findMatchingDevices(diskSettings boshsettings.DiskSettings) ([]string, error) {
+	sysBlockPaths, err := r.fs.Glob("/sys/block/*")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing block devices")
+	}
+
+	usedDevicePaths, err := r.usedDevicePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	devicePaths := []string{}
+
+	for _, sysBlockPath := range sysBlockPaths {
+		deviceName := path.Base(sysBlockPath)
+		if isIgnoredBlockDevice(deviceName) {
+			continue
+		}
+
+		devicePath := path.Join("/dev", deviceName)
+
+		if isDeviceUsed(devicePath, usedDevicePaths) {
+			r.logger.Debug(r.logTag, "Skipping device '%s' since it is mounted or belongs to another disk", deviceName)
+			continue
+		}
+
+		inUse, err := r.hasPartitionsOrHolders(sysBlockPath, deviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		if inUse {
+			r.logger.Debug(r.logTag, "Skipping device '%s' since it is partitioned or used by device mapper", deviceName)
+			continue
+		}
+
+		if diskSettings.Size > 0 {
+			sizeInMiB, err := r.deviceSizeInMiB(sysBlockPath)
+			if err != nil {
+				r.logger.Debug(r.logTag, "Skipping device '%s': %s", deviceName, err.Error())
+				continue
+			}
+
+			if sizeInMiB != diskSettings.Size {
+				continue
+			}
+		}
+
+		if diskSettings.Model != "" {
+			model, err := r.fs.ReadFileString(path.Join(sysBlockPath, "device", "model"))
+			if err != nil || strings.TrimSpace(model) != diskSettings.Model {
+				continue
+			}
+		}
+
+		devicePaths = append(devicePaths, devicePath)
+	}
+
+	return devicePaths, nil
+}
+
+// usedDevicePaths includes mounted devices, hence root device,
+// and devices resolved for other disks
+func (r fallbackDevicePathResolver) usedDevicePaths() ([]string, error) {
+	mounts, err := r.mountsSearcher.SearchMounts()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Searching mounts")
+	}
+
+	usedPaths := []string{}
+
+	for _, mount := range mounts {
+		usedPaths = append(usedPaths, mount.PartitionPath)
+	}
+
+	return append(usedPaths, r.resolvedDevices.Paths()...), nil
+}
+
+func (r fallbackDevicePathResolver) hasPartitionsOrHolders(sysBlockPath, deviceName string) (bool, error) {
+	partitionPaths, err := r.fs.Glob(path.Join(sysBlockPath, deviceName+"*"))
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Listing partitions of device '%s'", deviceName)
+	}
+
+	holderPaths, err := r.fs.Glob(path.Join(sysBlockPath, "holders", "*"))
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Listing holders of device '%s'", deviceName)
+	}
+
+	return len(partitionPaths) > 0 || len(holderPaths) > 0, nil
+}
+
+// isDeviceUsed matches the device itself and any of its partitions
+func isDeviceUsed(devicePath string, usedPaths []string) bool {
+	for _, usedPath := range usedPaths {
+		if usedPath == devicePath {
+			return true
+		}
+
+		if strings.HasPrefix(usedPath, devicePath) && partitionSuffixRegexp.MatchString(strings.TrimPrefix(usedPath, devicePath)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r fallbackDevicePathResolver) deviceSizeInMiB(sysBlockPath string) (uint64, error) {
+	sectors, err := r.fs.ReadFileString(path.Join(sysBlockPath, "size"))
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Reading device size")
+	}
+
+	sectorCount, err := strconv.ParseUint(strings.TrimSpace(sectors), 10, 64)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Parsing device size")
+	}
+
+	return sectorCount * sysBlockSectorSize / (1024 * 1024), nil
+}
+
+func isIgnoredBlockDevice(deviceName string) bool {
+	for _, prefix := range ignoredBlockDevicePrefixes {
+		if strings.HasPrefix(deviceName, prefix) {
+			return true
+		}
+	}
+	return false
+}
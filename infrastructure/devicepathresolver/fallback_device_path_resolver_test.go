@@ -0,0 +1,252 @@
+package devicepathresolver_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
+	fakedpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver/fakes"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
+	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fallbackDevicePathResolver", func() {
+	var (
+		devicePathResolver *fakedpresolv.FakeDevicePathResolver
+		fs                 *fakesys.FakeFileSystem
+		timeService        *fakeaction.FakeClock
+		mountsSearcher     *fakedisk.FakeMountsSearcher
+		resolvedDevices    *ResolvedDevices
+		pathResolver       DevicePathResolver
+
+		diskSettings boshsettings.DiskSettings
+	)
+
+	// 10 GiB in 512 byte sectors
+	const tenGiBSectors = "20971520\n"
+
+	BeforeEach(func() {
+		devicePathResolver = fakedpresolv.NewFakeDevicePathResolver()
+		fs = fakesys.NewFakeFileSystem()
+		timeService = &fakeaction.FakeClock{}
+		mountsSearcher = &fakedisk.FakeMountsSearcher{}
+		resolvedDevices = NewResolvedDevices()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		pathResolver = NewFallbackDevicePathResolver(devicePathResolver, resolvedDevices, 30*time.Second, fs, mountsSearcher, timeService, logger)
+
+		diskSettings = boshsettings.DiskSettings{
+			Path:  "/dev/sdb",
+			Size:  10240,
+			Model: "fake-model",
+		}
+
+		fs.SetGlob("/sys/block/*", []string{"/sys/block/loop0", "/sys/block/vda", "/sys/block/vdb"})
+		fs.WriteFileString("/sys/block/loop0/size", tenGiBSectors)
+		fs.WriteFileString("/sys/block/vda/size", "4194304\n")
+		fs.WriteFileString("/sys/block/vda/device/model", "fake-model\n")
+		fs.WriteFileString("/sys/block/vdb/size", tenGiBSectors)
+		fs.WriteFileString("/sys/block/vdb/device/model", "fake-model  \n")
+	})
+
+	Context("when resolved device path exists", func() {
+		It("returns resolved path without detecting device", func() {
+			devicePathResolver.RealDevicePath = "/dev/sdb"
+			fs.WriteFileString("/dev/sdb", "")
+
+			realPath, timedOut, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timedOut).To(BeFalse())
+			Expect(realPath).To(Equal("/dev/sdb"))
+			Expect(devicePathResolver.GetRealDevicePathDiskSettings).To(Equal(diskSettings))
+			Expect(timeService.SleepCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when resolved device path does not exist", func() {
+		BeforeEach(func() {
+			devicePathResolver.RealDevicePath = "/dev/sdb"
+		})
+
+		It("detects device by size and model", func() {
+			realPath, timedOut, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timedOut).To(BeFalse())
+			Expect(realPath).To(Equal("/dev/vdb"))
+		})
+
+		It("detects device by size only when model is not specified", func() {
+			diskSettings.Model = ""
+			fs.WriteFileString("/sys/block/vdb/device/model", "other-model")
+
+			realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(realPath).To(Equal("/dev/vdb"))
+		})
+
+		It("retries until device appears", func() {
+			fs.SetGlob("/sys/block/*", []string{"/sys/block/vda"})
+
+			timeService.SleepStub = func(time.Duration) {
+				if timeService.SleepCallCount() == 3 {
+					fs.SetGlob("/sys/block/*", []string{"/sys/block/vda", "/sys/block/vdb"})
+				}
+			}
+
+			realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(realPath).To(Equal("/dev/vdb"))
+			Expect(timeService.SleepCallCount()).To(Equal(3))
+			Expect(timeService.SleepArgsForCall(0)).To(Equal(1 * time.Second))
+		})
+
+		It("returns error when multiple devices match", func() {
+			fs.SetGlob("/sys/block/*", []string{"/sys/block/vdb", "/sys/block/vdc"})
+			fs.WriteFileString("/sys/block/vdc/size", tenGiBSectors)
+			fs.WriteFileString("/sys/block/vdc/device/model", "fake-model")
+
+			_, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Found multiple devices matching size 10240 MiB and model 'fake-model': /dev/vdb, /dev/vdc"))
+		})
+
+		It("returns timeout error when no device matches", func() {
+			diskSettings.Size = 20480
+
+			realPath, timedOut, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Timed out after 30s detecting device matching size 20480 MiB and model 'fake-model'"))
+			Expect(timedOut).To(BeTrue())
+			Expect(realPath).To(Equal(""))
+			Expect(timeService.SleepCallCount()).To(Equal(30))
+		})
+
+		Context("when another device matches size and model", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/sys/block/*", []string{"/sys/block/vda", "/sys/block/vdb", "/sys/block/vdc"})
+				fs.WriteFileString("/sys/block/vdc/size", tenGiBSectors)
+				fs.WriteFileString("/sys/block/vdc/device/model", "fake-model")
+			})
+
+			It("skips root device", func() {
+				mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+					{PartitionPath: "/dev/vdb1", MountPoint: "/"},
+				}
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdc"))
+			})
+
+			It("skips mounted devices", func() {
+				mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+					{PartitionPath: "/dev/vdc", MountPoint: "/var/vcap/store"},
+				}
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdb"))
+			})
+
+			It("does not confuse devices sharing name prefix with mounted partitions", func() {
+				fs.SetGlob("/sys/block/*", []string{"/sys/block/vdb", "/sys/block/vdbb"})
+				fs.WriteFileString("/sys/block/vdbb/size", tenGiBSectors)
+				fs.WriteFileString("/sys/block/vdbb/device/model", "fake-model")
+				mountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+					{PartitionPath: "/dev/vdbb1", MountPoint: "/"},
+				}
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdb"))
+			})
+
+			It("skips partitioned devices", func() {
+				fs.SetGlob("/sys/block/vdb/vdb*", []string{"/sys/block/vdb/vdb1"})
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdc"))
+			})
+
+			It("skips devices used by device mapper", func() {
+				fs.SetGlob("/sys/block/vdc/holders/*", []string{"/sys/block/vdc/holders/dm-0"})
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdb"))
+			})
+
+			It("skips devices resolved for other disks", func() {
+				resolvedDevices.Add("/dev/vdb")
+
+				realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(realPath).To(Equal("/dev/vdc"))
+			})
+
+			It("returns error when more than one device is left", func() {
+				_, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Found multiple devices matching size 10240 MiB and model 'fake-model': /dev/vdb, /dev/vdc"))
+			})
+
+			It("returns error when searching mounts fails", func() {
+				mountsSearcher.SearchMountsErr = errors.New("fake-search-mounts-err")
+
+				_, _, err := pathResolver.GetRealDevicePath(diskSettings)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Searching mounts: fake-search-mounts-err"))
+			})
+		})
+
+		It("returns error when listing block devices fails", func() {
+			fs.GlobErr = errors.New("fake-glob-err")
+
+			_, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Listing block devices: fake-glob-err"))
+		})
+	})
+
+	Context("when device path cannot be resolved", func() {
+		It("detects device by size and model", func() {
+			devicePathResolver.GetRealDevicePathErr = errors.New("fake-resolve-err")
+
+			realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(realPath).To(Equal("/dev/vdb"))
+		})
+	})
+
+	Context("when disk settings do not specify size or model", func() {
+		BeforeEach(func() {
+			diskSettings.Size = 0
+			diskSettings.Model = ""
+		})
+
+		It("returns result of device path resolver", func() {
+			devicePathResolver.GetRealDevicePathErr = errors.New("fake-resolve-err")
+			devicePathResolver.GetRealDevicePathTimedOut = true
+
+			_, timedOut, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-resolve-err"))
+			Expect(timedOut).To(BeTrue())
+		})
+
+		It("returns resolved path even when it does not exist", func() {
+			devicePathResolver.RealDevicePath = "/dev/sdb"
+
+			realPath, _, err := pathResolver.GetRealDevicePath(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(realPath).To(Equal("/dev/sdb"))
+		})
+	})
+})
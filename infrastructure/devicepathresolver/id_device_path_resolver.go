@@ -5,6 +5,8 @@ import (
 	"path"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshudev "github.com/cloudfoundry/bosh-agent/platform/udevdevice"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -18,6 +20,7 @@ type idDevicePathResolver struct {
 	devicePrefix    string
 	udev            boshudev.UdevDevice
 	fs              boshsys.FileSystem
+	clock           clock.Clock
 }
 
 func NewIDDevicePathResolver(
@@ -25,12 +28,14 @@ func NewIDDevicePathResolver(
 	devicePrefix string,
 	udev boshudev.UdevDevice,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 ) DevicePathResolver {
 	return idDevicePathResolver{
 		diskWaitTimeout: diskWaitTimeout,
 		devicePrefix:    devicePrefix,
 		udev:            udev,
 		fs:              fs,
+		clock:           clock,
 	}
 }
 
@@ -53,7 +58,7 @@ func (idpr idDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.Dis
 		return "", false, bosherr.WrapError(err, "Running udevadm settle")
 	}
 
-	stopAfter := time.Now().Add(idpr.diskWaitTimeout)
+	stopAfter := idpr.clock.Now().Add(idpr.diskWaitTimeout)
 	found := false
 
 	var realPath string
@@ -65,11 +70,11 @@ func (idpr idDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.Dis
 	}
 
 	for !found {
-		if time.Now().After(stopAfter) {
+		if idpr.clock.Now().After(stopAfter) {
 			return "", true, bosherr.Errorf("Timed out getting real device path for '%s'", diskID)
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		idpr.clock.Sleep(100 * time.Millisecond)
 
 		deviceIDPath := path.Join("/", "dev", "disk", "by-id", deviceID)
 		realPath, err = idpr.fs.ReadLink(deviceIDPath)
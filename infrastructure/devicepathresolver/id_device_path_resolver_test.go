@@ -11,6 +11,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 )
@@ -34,7 +35,7 @@ var _ = Describe("IDDevicePathResolver", func() {
 	})
 
 	JustBeforeEach(func() {
-		pathResolver = NewIDDevicePathResolver(500*time.Millisecond, devicePrefix, udev, fs)
+		pathResolver = NewIDDevicePathResolver(500*time.Millisecond, devicePrefix, udev, fs, clock.NewClock())
 	})
 
 	Describe("GetRealDevicePath", func() {
@@ -1,20 +1,53 @@
 package devicepathresolver
 
 import (
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-type identityDevicePathResolver struct{}
+type identityDevicePathResolver struct {
+	diskWaitTimeout time.Duration
+	fs              boshsys.FileSystem
+	clock           clock.Clock
+}
 
 func NewIdentityDevicePathResolver() DevicePathResolver {
 	return identityDevicePathResolver{}
 }
 
+// NewWaitingIdentityDevicePathResolver returns resolver that waits
+// up to diskWaitTimeout for device to appear at given path
+func NewWaitingIdentityDevicePathResolver(
+	diskWaitTimeout time.Duration,
+	fs boshsys.FileSystem,
+	clock clock.Clock,
+) DevicePathResolver {
+	return identityDevicePathResolver{diskWaitTimeout: diskWaitTimeout, fs: fs, clock: clock}
+}
+
 func (r identityDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.DiskSettings) (string, bool, error) {
 	if len(diskSettings.Path) == 0 {
 		return "", false, bosherr.Error("Getting real device path: path is missing")
 	}
 
+	if r.diskWaitTimeout <= 0 {
+		return diskSettings.Path, false, nil
+	}
+
+	stopAfter := r.clock.Now().Add(r.diskWaitTimeout)
+
+	for !r.fs.FileExists(diskSettings.Path) {
+		if r.clock.Now().After(stopAfter) {
+			return "", true, bosherr.Errorf("Timed out getting real device path for %s", diskSettings.Path)
+		}
+
+		r.clock.Sleep(100 * time.Millisecond)
+	}
+
 	return diskSettings.Path, false, nil
 }
@@ -1,9 +1,14 @@
 package devicepathresolver_test
 
 import (
+	"time"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
 	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -26,4 +31,77 @@ var _ = Describe("IdentityDevicePathResolver", func() {
 			Expect(err.Error()).To(ContainSubstring("path is missing"))
 		})
 	})
+
+	Context("when path is provided", func() {
+		It("returns path without checking that device is present", func() {
+			realPath, timedOut, err := identityDevicePathResolver.GetRealDevicePath(boshsettings.DiskSettings{Path: "/dev/sdb"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timedOut).To(BeFalse())
+			Expect(realPath).To(Equal("/dev/sdb"))
+		})
+	})
+
+	Context("when waiting for device", func() {
+		type result struct {
+			realPath string
+			timedOut bool
+			err      error
+		}
+
+		var (
+			fs        *fakesys.FakeFileSystem
+			fakeClock *fakeclock.FakeClock
+		)
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			identityDevicePathResolver = NewWaitingIdentityDevicePathResolver(time.Second, fs, fakeClock)
+		})
+
+		resolveInBackground := func() chan result {
+			resultCh := make(chan result, 1)
+			go func() {
+				realPath, timedOut, err := identityDevicePathResolver.GetRealDevicePath(boshsettings.DiskSettings{Path: "/dev/sdb"})
+				resultCh <- result{realPath, timedOut, err}
+			}()
+			return resultCh
+		}
+
+		It("returns path of device that is present right away", func() {
+			fs.WriteFile("/dev/sdb", []byte{})
+
+			realPath, timedOut, err := identityDevicePathResolver.GetRealDevicePath(boshsettings.DiskSettings{Path: "/dev/sdb"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timedOut).To(BeFalse())
+			Expect(realPath).To(Equal("/dev/sdb"))
+		})
+
+		It("returns path once device appears", func() {
+			resultCh := resolveInBackground()
+
+			Eventually(fakeClock.WatcherCount).Should(Equal(1))
+			fs.WriteFile("/dev/sdb", []byte{})
+			fakeClock.Increment(100 * time.Millisecond)
+
+			var res result
+			Eventually(resultCh).Should(Receive(&res))
+			Expect(res.err).ToNot(HaveOccurred())
+			Expect(res.timedOut).To(BeFalse())
+			Expect(res.realPath).To(Equal("/dev/sdb"))
+		})
+
+		It("times out when device never appears", func() {
+			resultCh := resolveInBackground()
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			var res result
+			Eventually(resultCh).Should(Receive(&res))
+			Expect(res.err).To(HaveOccurred())
+			Expect(res.err.Error()).To(Equal("Timed out getting real device path for /dev/sdb"))
+			Expect(res.timedOut).To(BeTrue())
+		})
+	})
 })
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -12,17 +14,19 @@ import (
 type mappedDevicePathResolver struct {
 	diskWaitTimeout time.Duration
 	fs              boshsys.FileSystem
+	clock           clock.Clock
 }
 
 func NewMappedDevicePathResolver(
 	diskWaitTimeout time.Duration,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 ) DevicePathResolver {
-	return mappedDevicePathResolver{fs: fs, diskWaitTimeout: diskWaitTimeout}
+	return mappedDevicePathResolver{fs: fs, diskWaitTimeout: diskWaitTimeout, clock: clock}
 }
 
 func (dpr mappedDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.DiskSettings) (string, bool, error) {
-	stopAfter := time.Now().Add(dpr.diskWaitTimeout)
+	stopAfter := dpr.clock.Now().Add(dpr.diskWaitTimeout)
 
 	devicePath := diskSettings.Path
 	if len(devicePath) == 0 {
@@ -32,11 +36,11 @@ func (dpr mappedDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.
 	realPath, found := dpr.findPossibleDevice(devicePath)
 
 	for !found {
-		if time.Now().After(stopAfter) {
+		if dpr.clock.Now().After(stopAfter) {
 			return "", true, bosherr.Errorf("Timed out getting real device path for %s", devicePath)
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		dpr.clock.Sleep(100 * time.Millisecond)
 
 		realPath, found = dpr.findPossibleDevice(devicePath)
 	}
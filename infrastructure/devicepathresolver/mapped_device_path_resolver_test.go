@@ -6,6 +6,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -16,17 +17,34 @@ import (
 var _ = Describe("mappedDevicePathResolver", func() {
 	var (
 		fs           boshsys.FileSystem
+		fakeClock    *fakeclock.FakeClock
 		diskSettings boshsettings.DiskSettings
 		resolver     DevicePathResolver
 	)
 
+	type result struct {
+		realPath string
+		timedOut bool
+		err      error
+	}
+
+	resolveInBackground := func() chan result {
+		resultCh := make(chan result, 1)
+		go func() {
+			realPath, timedOut, err := resolver.GetRealDevicePath(diskSettings)
+			resultCh <- result{realPath, timedOut, err}
+		}()
+		return resultCh
+	}
+
 	BeforeEach(func() {
 		if runtime.GOOS == "windows" {
 			Skip("Not yet implemented on Windows")
 		}
 
 		fs = fakesys.NewFakeFileSystem()
-		resolver = NewMappedDevicePathResolver(time.Second, fs)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		resolver = NewMappedDevicePathResolver(time.Second, fs, fakeClock)
 		diskSettings = boshsettings.DiskSettings{
 			Path: "/dev/sda",
 		}
@@ -85,26 +103,33 @@ var _ = Describe("mappedDevicePathResolver", func() {
 
 	Context("when no matching device is found the first time", func() {
 		Context("when the timeout has not expired", func() {
-			BeforeEach(func() {
-				time.AfterFunc(time.Second, func() {
-					fs.WriteFile("/dev/xvda", []byte{})
-				})
-			})
-
 			It("returns the match", func() {
-				realPath, timedOut, err := resolver.GetRealDevicePath(diskSettings)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(timedOut).To(BeFalse())
-				Expect(realPath).To(Equal("/dev/xvda"))
+				resultCh := resolveInBackground()
+
+				Eventually(fakeClock.WatcherCount).Should(Equal(1))
+				fs.WriteFile("/dev/xvda", []byte{})
+				fakeClock.Increment(100 * time.Millisecond)
+
+				var res result
+				Eventually(resultCh).Should(Receive(&res))
+				Expect(res.err).NotTo(HaveOccurred())
+				Expect(res.timedOut).To(BeFalse())
+				Expect(res.realPath).To(Equal("/dev/xvda"))
 			})
 		})
 
 		Context("when the timeout has expired", func() {
 			It("errs", func() {
-				_, timedOut, err := resolver.GetRealDevicePath(diskSettings)
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(Equal("Timed out getting real device path for /dev/sda"))
-				Expect(timedOut).To(BeTrue())
+				resultCh := resolveInBackground()
+
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+				var res result
+				Eventually(resultCh).Should(Receive(&res))
+				Expect(res.err).To(HaveOccurred())
+				Expect(res.err.Error()).To(Equal("Timed out getting real device path for /dev/sda"))
+				Expect(res.timedOut).To(BeTrue())
 			})
 		})
 	})
@@ -133,10 +158,16 @@ var _ = Describe("mappedDevicePathResolver", func() {
 			})
 
 			It("returns an error", func() {
-				realPath, timedOut, err := resolver.GetRealDevicePath(diskSettings)
-				Expect(realPath).To(Equal(""))
-				Expect(err).To(HaveOccurred())
-				Expect(timedOut).To(BeTrue())
+				resultCh := resolveInBackground()
+
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+				var res result
+				Eventually(resultCh).Should(Receive(&res))
+				Expect(res.realPath).To(Equal(""))
+				Expect(res.err).To(HaveOccurred())
+				Expect(res.timedOut).To(BeTrue())
 			})
 		})
 	})
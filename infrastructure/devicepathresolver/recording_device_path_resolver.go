@@ -0,0 +1,63 @@
+package devicepathresolver
+
+import (
+	"sort"
+	"sync"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+// ResolvedDevices keeps paths of devices that belong to persistent
+// and raw ephemeral disks so that they are never detected as ephemeral disk
+type ResolvedDevices struct {
+	lock  sync.RWMutex
+	paths map[string]struct{}
+}
+
+func NewResolvedDevices() *ResolvedDevices {
+	return &ResolvedDevices{paths: map[string]struct{}{}}
+}
+
+func (d *ResolvedDevices) Add(path string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.paths[path] = struct{}{}
+}
+
+func (d *ResolvedDevices) Paths() []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	paths := []string{}
+	for path := range d.paths {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+type recordingDevicePathResolver struct {
+	devicePathResolver DevicePathResolver
+	resolvedDevices    *ResolvedDevices
+}
+
+// NewRecordingDevicePathResolver adds every device path that
+// devicePathResolver successfully resolves to resolvedDevices
+func NewRecordingDevicePathResolver(devicePathResolver DevicePathResolver, resolvedDevices *ResolvedDevices) DevicePathResolver {
+	return recordingDevicePathResolver{
+		devicePathResolver: devicePathResolver,
+		resolvedDevices:    resolvedDevices,
+	}
+}
+
+func (r recordingDevicePathResolver) GetRealDevicePath(diskSettings boshsettings.DiskSettings) (string, bool, error) {
+	realPath, timedOut, err := r.devicePathResolver.GetRealDevicePath(diskSettings)
+	if err == nil && realPath != "" {
+		r.resolvedDevices.Add(realPath)
+	}
+
+	return realPath, timedOut, err
+}
@@ -0,0 +1,53 @@
+package devicepathresolver_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
+	fakedpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+var _ = Describe("recordingDevicePathResolver", func() {
+	var (
+		devicePathResolver *fakedpresolv.FakeDevicePathResolver
+		resolvedDevices    *ResolvedDevices
+		pathResolver       DevicePathResolver
+	)
+
+	BeforeEach(func() {
+		devicePathResolver = fakedpresolv.NewFakeDevicePathResolver()
+		resolvedDevices = NewResolvedDevices()
+		pathResolver = NewRecordingDevicePathResolver(devicePathResolver, resolvedDevices)
+	})
+
+	It("records resolved device paths", func() {
+		devicePathResolver.RealDevicePath = "/dev/sdc"
+
+		realPath, _, err := pathResolver.GetRealDevicePath(boshsettings.DiskSettings{ID: "fake-disk-id"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(realPath).To(Equal("/dev/sdc"))
+		Expect(devicePathResolver.GetRealDevicePathDiskSettings).To(Equal(boshsettings.DiskSettings{ID: "fake-disk-id"}))
+
+		devicePathResolver.RealDevicePath = "/dev/sdb"
+
+		_, _, err = pathResolver.GetRealDevicePath(boshsettings.DiskSettings{ID: "fake-other-disk-id"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resolvedDevices.Paths()).To(Equal([]string{"/dev/sdb", "/dev/sdc"}))
+	})
+
+	It("does not record device path when resolving fails", func() {
+		devicePathResolver.RealDevicePath = "/dev/sdc"
+		devicePathResolver.GetRealDevicePathErr = errors.New("fake-resolve-err")
+		devicePathResolver.GetRealDevicePathTimedOut = true
+
+		_, timedOut, err := pathResolver.GetRealDevicePath(boshsettings.DiskSettings{ID: "fake-disk-id"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("fake-resolve-err"))
+		Expect(timedOut).To(BeTrue())
+		Expect(resolvedDevices.Paths()).To(BeEmpty())
+	})
+})
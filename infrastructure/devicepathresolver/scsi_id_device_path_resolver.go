@@ -4,6 +4,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -16,6 +18,7 @@ import (
 type SCSIIDDevicePathResolver struct {
 	diskWaitTimeout time.Duration
 	fs              boshsys.FileSystem
+	clock           clock.Clock
 
 	logTag string
 	logger boshlog.Logger
@@ -24,11 +27,13 @@ type SCSIIDDevicePathResolver struct {
 func NewSCSIIDDevicePathResolver(
 	diskWaitTimeout time.Duration,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 	logger boshlog.Logger,
 ) SCSIIDDevicePathResolver {
 	return SCSIIDDevicePathResolver{
 		diskWaitTimeout: diskWaitTimeout,
 		fs:              fs,
+		clock:           clock,
 
 		logTag: "scsiIDresolver",
 		logger: logger,
@@ -53,7 +58,7 @@ func (idpr SCSIIDDevicePathResolver) GetRealDevicePath(diskSettings boshsettings
 		}
 	}
 
-	stopAfter := time.Now().Add(idpr.diskWaitTimeout)
+	stopAfter := idpr.clock.Now().Add(idpr.diskWaitTimeout)
 	found := false
 
 	var realPath string
@@ -61,11 +66,11 @@ func (idpr SCSIIDDevicePathResolver) GetRealDevicePath(diskSettings boshsettings
 	for !found {
 		idpr.logger.Debug(idpr.logTag, "Waiting for device to appear")
 
-		if time.Now().After(stopAfter) {
+		if idpr.clock.Now().After(stopAfter) {
 			return "", true, bosherr.Errorf("Timed out getting real device path for '%s'", diskSettings.DeviceID)
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		idpr.clock.Sleep(100 * time.Millisecond)
 
 		uuid := strings.Replace(diskSettings.DeviceID, "-", "", -1)
 		disks, err := idpr.fs.Glob("/dev/disk/by-id/*" + uuid)
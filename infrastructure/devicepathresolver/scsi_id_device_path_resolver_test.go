@@ -11,6 +11,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 )
@@ -28,7 +29,7 @@ var _ = Describe("ScsiIDDevicePathResolver", func() {
 		deviceID := "ab1b46b5-bf22-4332-bddd-12a05ea1a5fc"
 		id = strings.Replace(deviceID, "-", "", -1)
 		fs = fakesys.NewFakeFileSystem()
-		pathResolver = NewSCSIIDDevicePathResolver(500*time.Millisecond, fs, boshlog.NewLogger(boshlog.LevelNone))
+		pathResolver = NewSCSIIDDevicePathResolver(500*time.Millisecond, fs, clock.NewClock(), boshlog.NewLogger(boshlog.LevelNone))
 		diskSettings = boshsettings.DiskSettings{
 			DeviceID: deviceID,
 		}
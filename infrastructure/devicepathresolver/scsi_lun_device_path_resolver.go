@@ -6,6 +6,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -15,6 +17,7 @@ import (
 type SCSILunDevicePathResolver struct {
 	diskWaitTimeout time.Duration
 	fs              boshsys.FileSystem
+	clock           clock.Clock
 
 	logTag string
 	logger boshlog.Logger
@@ -23,11 +26,13 @@ type SCSILunDevicePathResolver struct {
 func NewSCSILunDevicePathResolver(
 	diskWaitTimeout time.Duration,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 	logger boshlog.Logger,
 ) SCSILunDevicePathResolver {
 	return SCSILunDevicePathResolver{
 		fs:              fs,
 		diskWaitTimeout: diskWaitTimeout,
+		clock:           clock,
 
 		logTag: "scsiLunResolver",
 		logger: logger,
@@ -57,7 +62,7 @@ func (ldpr SCSILunDevicePathResolver) GetRealDevicePath(diskSettings boshsetting
 		}
 	}
 
-	stopAfter := time.Now().Add(ldpr.diskWaitTimeout)
+	stopAfter := ldpr.clock.Now().Add(ldpr.diskWaitTimeout)
 
 	var vmBusDeviceForDataDisks string
 
@@ -90,11 +95,11 @@ func (ldpr SCSILunDevicePathResolver) GetRealDevicePath(diskSettings boshsetting
 	for {
 		ldpr.logger.Debug(ldpr.logTag, "Waiting for device to appear")
 
-		if time.Now().After(stopAfter) {
+		if ldpr.clock.Now().After(stopAfter) {
 			return "", true, bosherr.Errorf("Timed out getting real device path by lun '%s' and host_device_id '%s'", diskSettings.Lun, diskSettings.HostDeviceID)
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		ldpr.clock.Sleep(100 * time.Millisecond)
 
 		devicePaths, err := ldpr.fs.Glob(deviceGlobPath)
 		if err != nil {
@@ -10,6 +10,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	. "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 )
@@ -25,7 +26,7 @@ var _ = Describe("SCSILunDevicePathResolver", func() {
 	BeforeEach(func() {
 		lun := "0"
 		fs = fakesys.NewFakeFileSystem()
-		pathResolver = NewSCSILunDevicePathResolver(500*time.Millisecond, fs, boshlog.NewLogger(boshlog.LevelNone))
+		pathResolver = NewSCSILunDevicePathResolver(500*time.Millisecond, fs, clock.NewClock(), boshlog.NewLogger(boshlog.LevelNone))
 		diskSettings = boshsettings.DiskSettings{
 			Lun:          lun,
 			HostDeviceID: "fake-host-device-id",
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -15,15 +17,18 @@ const maxScanRetries = 30
 type SCSIVolumeIDDevicePathResolver struct {
 	diskWaitTimeout time.Duration
 	fs              boshsys.FileSystem
+	clock           clock.Clock
 }
 
 func NewSCSIVolumeIDDevicePathResolver(
 	diskWaitTimeout time.Duration,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 ) SCSIVolumeIDDevicePathResolver {
 	return SCSIVolumeIDDevicePathResolver{
 		fs:              fs,
 		diskWaitTimeout: diskWaitTimeout,
+		clock:           clock,
 	}
 }
 
@@ -65,7 +70,7 @@ func (devicePathResolver SCSIVolumeIDDevicePathResolver) GetRealDevicePath(diskS
 	for i := 0; i < maxScanRetries; i++ {
 		devicePaths, err = devicePathResolver.fs.Glob(deviceGlobPath)
 		if err != nil || len(devicePaths) == 0 {
-			time.Sleep(devicePathResolver.diskWaitTimeout)
+			devicePathResolver.clock.Sleep(devicePathResolver.diskWaitTimeout)
 			continue
 		} else {
 			break
@@ -5,6 +5,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -23,7 +24,7 @@ var _ = Describe("SCSIVolumeIDDevicePathResolver", func() {
 
 	BeforeEach(func() {
 		fs = fakesys.NewFakeFileSystem()
-		resolver = NewSCSIVolumeIDDevicePathResolver(sleepInterval, fs)
+		resolver = NewSCSIVolumeIDDevicePathResolver(sleepInterval, fs, clock.NewClock())
 
 		fs.SetGlob("/sys/bus/scsi/devices/*:0:0:0/block/*", []string{
 			"/sys/bus/scsi/devices/0:0:0:0/block/sr0",
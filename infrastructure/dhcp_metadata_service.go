@@ -0,0 +1,182 @@
+package infrastructure
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Site-specific options (224-254) not declared in dhclient.conf
+// are recorded in lease files as unknown-<code>
+const DefaultDHCPRegistryEndpointOption = "unknown-224"
+
+// Lease files written by dhclient on Ubuntu and CentOS respectively
+var DefaultDHCPLeaseFilePaths = []string{
+	"/var/lib/dhcp/dhclient*.leases",
+	"/var/lib/dhclient/*.leases",
+}
+
+// dhclient writes values with non-printable characters as colon separated hex bytes
+var dhcpHexOptionValueRegex = regexp.MustCompile(`^[0-9a-fA-F]{1,2}(:[0-9a-fA-F]{1,2})*$`)
+
+type dhcpMetadataService struct {
+	leaseFilePaths          []string
+	registryEndpointOption  string
+	userDataMetadataService MetadataService
+	fs                      boshsys.FileSystem
+	logTag                  string
+	logger                  boshlog.Logger
+}
+
+// NewDHCPMetadataService reads registry endpoint from the DHCP lease option
+// and relies on userDataMetadataService for everything else,
+// including registry endpoint when the option is absent
+func NewDHCPMetadataService(
+	leaseFilePaths []string,
+	registryEndpointOption string,
+	userDataMetadataService MetadataService,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) MetadataService {
+	if len(leaseFilePaths) == 0 {
+		leaseFilePaths = DefaultDHCPLeaseFilePaths
+	}
+
+	if registryEndpointOption == "" {
+		registryEndpointOption = DefaultDHCPRegistryEndpointOption
+	}
+
+	return dhcpMetadataService{
+		leaseFilePaths:          leaseFilePaths,
+		registryEndpointOption:  registryEndpointOption,
+		userDataMetadataService: userDataMetadataService,
+		fs:                      fs,
+		logTag:                  "dhcpMetadataService",
+		logger:                  logger,
+	}
+}
+
+func (ms dhcpMetadataService) IsAvailable() bool {
+	_, found, err := ms.findRegistryEndpoint()
+	if err == nil && found {
+		return true
+	}
+
+	return ms.userDataMetadataService.IsAvailable()
+}
+
+func (ms dhcpMetadataService) GetPublicKey() (string, error) {
+	return ms.userDataMetadataService.GetPublicKey()
+}
+
+func (ms dhcpMetadataService) GetInstanceID() (string, error) {
+	return ms.userDataMetadataService.GetInstanceID()
+}
+
+func (ms dhcpMetadataService) GetServerName() (string, error) {
+	return ms.userDataMetadataService.GetServerName()
+}
+
+func (ms dhcpMetadataService) GetNetworks() (boshsettings.Networks, error) {
+	return ms.userDataMetadataService.GetNetworks()
+}
+
+func (ms dhcpMetadataService) GetRegistryEndpoint() (string, error) {
+	endpoint, found, err := ms.findRegistryEndpoint()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Reading registry endpoint from DHCP lease")
+	}
+
+	if found {
+		ms.logger.Debug(ms.logTag, "Found registry endpoint '%s' in DHCP option %s", endpoint, ms.registryEndpointOption)
+		return endpoint, nil
+	}
+
+	ms.logger.Debug(ms.logTag, "DHCP option %s not found, falling back to user data", ms.registryEndpointOption)
+
+	return ms.userDataMetadataService.GetRegistryEndpoint()
+}
+
+// findRegistryEndpoint uses the most recent lease found in the first lease file that has the option
+func (ms dhcpMetadataService) findRegistryEndpoint() (string, bool, error) {
+	for _, leaseFilePath := range ms.leaseFilePaths {
+		leaseFiles, err := ms.fs.Glob(leaseFilePath)
+		if err != nil {
+			return "", false, bosherr.WrapErrorf(err, "Finding lease files '%s'", leaseFilePath)
+		}
+
+		for _, leaseFile := range leaseFiles {
+			contents, err := ms.fs.ReadFileString(leaseFile)
+			if err != nil {
+				return "", false, bosherr.WrapErrorf(err, "Reading lease file '%s'", filepath.Base(leaseFile))
+			}
+
+			value, found := ms.findLeaseOption(contents)
+			if found {
+				return value, true, nil
+			}
+		}
+	}
+
+	return "", false, nil
+}
+
+// findLeaseOption returns value of the option in the last lease that has it, e.g.
+//
+//	lease {
+//	  interface "eth0";
+//	  option unknown-224 "https://registry.example.com:25777";
+//	}
+func (ms dhcpMetadataService) findLeaseOption(contents string) (string, bool) {
+	var value string
+	var found bool
+
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "option" || fields[1] != ms.registryEndpointOption {
+			continue
+		}
+
+		decodedValue, err := decodeDHCPOptionValue(strings.TrimSpace(fields[2]))
+		if err != nil {
+			ms.logger.Warn(ms.logTag, "Ignoring malformed DHCP option %s: %s", ms.registryEndpointOption, err.Error())
+			continue
+		}
+
+		value, found = decodedValue, true
+	}
+
+	return value, found
+}
+
+func decodeDHCPOptionValue(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		return strconv.Unquote(value)
+	}
+
+	if dhcpHexOptionValueRegex.MatchString(value) {
+		var decoded []byte
+
+		for _, hexByte := range strings.Split(value, ":") {
+			b, err := hex.DecodeString(strings.Repeat("0", 2-len(hexByte)) + hexByte)
+			if err != nil {
+				return "", err
+			}
+
+			decoded = append(decoded, b...)
+		}
+
+		return strings.TrimRight(string(decoded), "\x00"), nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,209 @@
+package infrastructure_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+const sampleDHCPLeaseFile = `default-duid "\000\001\000\001\037\217\262\007RT\000\022\064V";
+lease {
+  interface "eth0";
+  fixed-address 10.0.16.5;
+  option subnet-mask 255.255.255.0;
+  option routers 10.0.16.1;
+  option unknown-224 "http://old-registry:25777";
+  option dhcp-lease-time 3600;
+  option domain-name-servers 10.0.16.2;
+  renew 2 2016/01/12 10:00:00;
+  rebind 2 2016/01/12 10:30:00;
+  expire 2 2016/01/12 10:37:30;
+}
+lease {
+  interface "eth0";
+  fixed-address 10.0.16.5;
+  option subnet-mask 255.255.255.0;
+  option routers 10.0.16.1;
+  option unknown-224 "http://fake-registry:25777";
+  option dhcp-lease-time 3600;
+  renew 2 2016/01/12 11:00:00;
+  rebind 2 2016/01/12 11:30:00;
+  expire 2 2016/01/12 11:37:30;
+}
+`
+
+var _ = Describe("DHCPMetadataService", func() {
+	var (
+		fs                      *fakesys.FakeFileSystem
+		userDataMetadataService *fakeinf.FakeMetadataService
+		leaseFilePaths          []string
+		registryEndpointOption  string
+		metadataService         MetadataService
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		userDataMetadataService = &fakeinf.FakeMetadataService{
+			PublicKey:        "fake-public-key",
+			InstanceID:       "fake-instance-id",
+			ServerName:       "fake-server-name",
+			RegistryEndpoint: "http://user-data-registry:25777",
+			Networks:         boshsettings.Networks{"net1": boshsettings.Network{IP: "fake-ip"}},
+		}
+		leaseFilePaths = nil
+		registryEndpointOption = ""
+	})
+
+	JustBeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		metadataService = NewDHCPMetadataService(leaseFilePaths, registryEndpointOption, userDataMetadataService, fs, logger)
+	})
+
+	Describe("GetRegistryEndpoint", func() {
+		Context("when dhclient lease file contains the option", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/var/lib/dhcp/dhclient*.leases", []string{"/var/lib/dhcp/dhclient.eth0.leases"})
+				fs.WriteFileString("/var/lib/dhcp/dhclient.eth0.leases", sampleDHCPLeaseFile)
+			})
+
+			It("returns registry endpoint from the most recent lease", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://fake-registry:25777"))
+			})
+
+			It("is available", func() {
+				Expect(metadataService.IsAvailable()).To(BeTrue())
+			})
+		})
+
+		Context("when lease file is in CentOS location", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/var/lib/dhclient/*.leases", []string{"/var/lib/dhclient/dhclient-eth0.leases"})
+				fs.WriteFileString("/var/lib/dhclient/dhclient-eth0.leases", sampleDHCPLeaseFile)
+			})
+
+			It("returns registry endpoint from the lease", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://fake-registry:25777"))
+			})
+		})
+
+		Context("when option value is recorded as hex bytes", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/var/lib/dhcp/dhclient*.leases", []string{"/var/lib/dhcp/dhclient.leases"})
+				fs.WriteFileString("/var/lib/dhcp/dhclient.leases", `lease {
+  interface "eth0";
+  option unknown-224 68:74:74:70:3a:2f:2f:72:65:67:69:73:74:72:79:0;
+}
+`)
+			})
+
+			It("decodes the value", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://registry"))
+			})
+		})
+
+		Context("when custom lease files and option are configured", func() {
+			BeforeEach(func() {
+				leaseFilePaths = []string{"/fake-leases/*.lease"}
+				registryEndpointOption = "bosh-registry"
+
+				fs.SetGlob("/fake-leases/*.lease", []string{"/fake-leases/eth0.lease"})
+				fs.WriteFileString("/fake-leases/eth0.lease", `lease {
+  option unknown-224 "http://wrong-registry:25777";
+  option bosh-registry "http://custom-registry:25777";
+}
+`)
+			})
+
+			It("reads configured option from configured lease files", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://custom-registry:25777"))
+			})
+		})
+
+		Context("when lease file does not contain the option", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/var/lib/dhcp/dhclient*.leases", []string{"/var/lib/dhcp/dhclient.eth0.leases"})
+				fs.WriteFileString("/var/lib/dhcp/dhclient.eth0.leases", `lease {
+  interface "eth0";
+  option routers 10.0.16.1;
+}
+`)
+			})
+
+			It("falls back to registry endpoint from user data", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://user-data-registry:25777"))
+			})
+
+			It("returns error when user data fails", func() {
+				userDataMetadataService.GetRegistryEndpointErr = errors.New("fake-user-data-err")
+
+				_, err := metadataService.GetRegistryEndpoint()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("fake-user-data-err"))
+			})
+
+			It("reports availability of user data", func() {
+				Expect(metadataService.IsAvailable()).To(BeFalse())
+
+				userDataMetadataService.Available = true
+				Expect(metadataService.IsAvailable()).To(BeTrue())
+			})
+		})
+
+		Context("when there are no lease files", func() {
+			It("falls back to registry endpoint from user data", func() {
+				endpoint, err := metadataService.GetRegistryEndpoint()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoint).To(Equal("http://user-data-registry:25777"))
+			})
+		})
+
+		Context("when reading lease file fails", func() {
+			BeforeEach(func() {
+				fs.SetGlob("/var/lib/dhcp/dhclient*.leases", []string{"/var/lib/dhcp/dhclient.eth0.leases"})
+				fs.WriteFileString("/var/lib/dhcp/dhclient.eth0.leases", sampleDHCPLeaseFile)
+				fs.ReadFileError = errors.New("fake-read-err")
+			})
+
+			It("returns error", func() {
+				_, err := metadataService.GetRegistryEndpoint()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-read-err"))
+			})
+		})
+	})
+
+	It("gets other metadata from user data", func() {
+		publicKey, err := metadataService.GetPublicKey()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(publicKey).To(Equal("fake-public-key"))
+
+		instanceID, err := metadataService.GetInstanceID()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instanceID).To(Equal("fake-instance-id"))
+
+		serverName, err := metadataService.GetServerName()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serverName).To(Equal("fake-server-name"))
+
+		networks, err := metadataService.GetNetworks()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(networks).To(Equal(userDataMetadataService.Networks))
+	})
+})
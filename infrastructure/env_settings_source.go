@@ -0,0 +1,103 @@
+package infrastructure
+
+import (
+	"os"
+	"sync"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// DefaultSettingsEnvVariable holds settings JSON when env source
+// does not configure variable name
+const DefaultSettingsEnvVariable = "BOSH_AGENT_SETTINGS"
+
+// EnvSettingsSource reads full settings JSON from an environment variable.
+// Used by containerized agents that have neither a settings file nor metadata service.
+type EnvSettingsSource struct {
+	variableName string
+
+	lock     sync.Mutex
+	read     bool
+	contents string
+	found    bool
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewEnvSettingsSource(variableName string, logger boshlog.Logger) *EnvSettingsSource {
+	if variableName == "" {
+		variableName = DefaultSettingsEnvVariable
+	}
+
+	return &EnvSettingsSource{
+		variableName: variableName,
+
+		logTag: "EnvSettingsSource",
+		logger: logger,
+	}
+}
+
+func (s *EnvSettingsSource) PublicSSHKeyForUsername(string) (string, error) {
+	var p PublicKeyContent
+
+	err := s.decode(&p)
+	if err != nil {
+		return "", err
+	}
+
+	return p.PublicKey, nil
+}
+
+func (s *EnvSettingsSource) Settings() (boshsettings.Settings, error) {
+	var settings boshsettings.Settings
+
+	err := s.decode(&settings)
+	if err != nil {
+		return settings, err
+	}
+
+	s.logger.Debug(s.logTag, "Read settings from environment variable '%s'", s.variableName)
+
+	return settings, nil
+}
+
+func (s *EnvSettingsSource) decode(v interface{}) error {
+	contents, found := s.readVariable()
+	if !found {
+		return bosherr.Errorf("Environment variable '%s' with settings is not set", s.variableName)
+	}
+
+	err := boshsettings.NewJSONDecoder().Decode([]byte(contents), v)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing settings from environment variable '%s'", s.variableName)
+	}
+
+	return nil
+}
+
+// readVariable reads variable only once and removes it from environment
+// so that settings and their credentials are not inherited by processes
+// started by agent, e.g. job scripts
+func (s *EnvSettingsSource) readVariable() (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.read {
+		return s.contents, s.found
+	}
+
+	s.contents, s.found = os.LookupEnv(s.variableName)
+	s.read = true
+
+	if s.found {
+		err := os.Unsetenv(s.variableName)
+		if err != nil {
+			s.logger.Warn(s.logTag, "Unsetting environment variable '%s': %s", s.variableName, err.Error())
+		}
+	}
+
+	return s.contents, s.found
+}
@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// FileSettingsSource reads full settings from a file on disk.
+// Used by warden/bare-metal environments that have no metadata service or registry.
+type FileSettingsSource struct {
+	settingsPath string
+	decoder      boshsettings.Decoder
+
+	fs boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFileSettingsSource(
+	settingsPath string,
+	decoder boshsettings.Decoder,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) *FileSettingsSource {
+	return &FileSettingsSource{
+		settingsPath: settingsPath,
+		decoder:      decoder,
+
+		fs: fs,
+
+		logTag: "FileSettingsSource",
+		logger: logger,
+	}
+}
+
+func (s *FileSettingsSource) PublicSSHKeyForUsername(string) (string, error) {
+	var p PublicKeyContent
+
+	contents, err := s.fs.ReadFile(s.settingsPath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading settings file '%s'", s.settingsPath)
+	}
+
+	err = s.decoder.Decode(contents, &p)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing settings file '%s'", s.settingsPath)
+	}
+
+	return p.PublicKey, nil
+}
+
+func (s *FileSettingsSource) Settings() (boshsettings.Settings, error) {
+	var settings boshsettings.Settings
+
+	contents, err := s.fs.ReadFile(s.settingsPath)
+	if err != nil {
+		return settings, bosherr.WrapErrorf(err, "Reading settings file '%s'", s.settingsPath)
+	}
+
+	err = s.decoder.Decode(contents, &settings)
+	if err != nil {
+		return settings, bosherr.WrapErrorf(err, "Parsing settings file '%s'", s.settingsPath)
+	}
+
+	s.logger.Debug(s.logTag, "Read settings from '%s'", s.settingsPath)
+
+	return settings, nil
+}
@@ -0,0 +1,153 @@
+package infrastructure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	boshplat "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	DefaultGCEMetadataHost              = "http://metadata.google.internal/computeMetadata/v1"
+	DefaultGCERegistryEndpointAttribute = "bosh_registry_endpoint"
+	gceSSHKeysUsername                  = "vcap"
+)
+
+// GCE metadata server rejects requests without this header
+var gceMetadataHeaders = map[string]string{"Metadata-Flavor": "Google"}
+
+type gceMetadataService struct {
+	metadataHost              string
+	registryEndpointAttribute string
+	platform                  boshplat.Platform
+	httpClient                HTTPClient
+	logTag                    string
+	logger                    boshlog.Logger
+}
+
+func NewGCEMetadataService(
+	metadataHost string,
+	registryEndpointAttribute string,
+	platform boshplat.Platform,
+	httpClient HTTPClient,
+	logger boshlog.Logger,
+) MetadataService {
+	if metadataHost == "" {
+		metadataHost = DefaultGCEMetadataHost
+	}
+
+	if registryEndpointAttribute == "" {
+		registryEndpointAttribute = DefaultGCERegistryEndpointAttribute
+	}
+
+	return gceMetadataService{
+		metadataHost:              metadataHost,
+		registryEndpointAttribute: registryEndpointAttribute,
+		platform:                  platform,
+		httpClient:                httpClient,
+		logTag:                    "gceMetadataService",
+		logger:                    logger,
+	}
+}
+
+func (ms gceMetadataService) IsAvailable() bool { return true }
+
+// GetPublicKey looks for vcap's key in instance metadata first and falls back to project metadata.
+// Keys are listed one per line as "username:key".
+func (ms gceMetadataService) GetPublicKey() (string, error) {
+	for _, path := range []string{"/instance/attributes/ssh-keys", "/project/attributes/ssh-keys"} {
+		sshKeys, found, err := ms.get(path)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Getting ssh keys")
+		}
+
+		if !found {
+			continue
+		}
+
+		if publicKey := ms.findPublicKey(sshKeys); publicKey != "" {
+			return publicKey, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (ms gceMetadataService) GetInstanceID() (string, error) {
+	return ms.getRequired("/instance/name")
+}
+
+func (ms gceMetadataService) GetServerName() (string, error) {
+	return ms.getRequired("/instance/name")
+}
+
+func (ms gceMetadataService) GetRegistryEndpoint() (string, error) {
+	return ms.getRequired(fmt.Sprintf("/instance/attributes/%s", ms.registryEndpointAttribute))
+}
+
+func (ms gceMetadataService) GetNetworks() (boshsettings.Networks, error) {
+	return nil, nil
+}
+
+func (ms gceMetadataService) findPublicKey(sshKeys string) string {
+	for _, line := range strings.Split(sshKeys, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) == 2 && parts[0] == gceSSHKeysUsername {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	return ""
+}
+
+func (ms gceMetadataService) getRequired(path string) (string, error) {
+	value, found, err := ms.get(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !found || value == "" {
+		return "", bosherr.Errorf("Missing GCE metadata value at %s", path)
+	}
+
+	return value, nil
+}
+
+func (ms gceMetadataService) get(path string) (string, bool, error) {
+	err := ensureMinimalNetworkSetup(ms.platform, ms.logTag, ms.logger)
+	if err != nil {
+		return "", false, err
+	}
+
+	url := fmt.Sprintf("%s%s", ms.metadataHost, path)
+	resp, err := ms.httpClient.Get(url, gceMetadataHeaders)
+	if err != nil {
+		return "", false, bosherr.WrapErrorf(err, "Getting value from url %s", url)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			ms.logger.Warn(ms.logTag, "Failed to close response body when getting value from %s: %s", url, err.Error())
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false, bosherr.Errorf("Getting value from url %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", false, bosherr.WrapErrorf(err, "Reading response body from %s", url)
+	}
+
+	return strings.TrimSpace(string(bytes)), true, nil
+}
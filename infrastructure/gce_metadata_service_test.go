@@ -0,0 +1,180 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeplat "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("GCEMetadataService", func() {
+	var (
+		ts              *httptest.Server
+		metadata        map[string]string
+		platform        *fakeplat.FakePlatform
+		metadataService MetadataService
+	)
+
+	BeforeEach(func() {
+		metadata = map[string]string{
+			"/instance/name": "fake-instance-name",
+			"/instance/attributes/bosh_registry_endpoint": "http://fake-registry:25777",
+		}
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			value, found := metadata[r.URL.Path]
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Write([]byte(value))
+		})
+		ts = httptest.NewServer(handler)
+
+		platform = fakeplat.NewFakePlatform()
+		platform.GetConfiguredNetworkInterfacesInterfaces = []string{"fake-interface"}
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		httpClient := NewHTTPClient(&http.Client{}, "fake-user-agent")
+		metadataService = NewGCEMetadataService(ts.URL, "", platform, httpClient, logger)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	Describe("IsAvailable", func() {
+		It("returns true", func() {
+			Expect(metadataService.IsAvailable()).To(BeTrue())
+		})
+	})
+
+	Describe("GetPublicKey", func() {
+		It("returns vcap key from instance metadata", func() {
+			metadata["/instance/attributes/ssh-keys"] = "other:ssh-rsa other-key other\nvcap:ssh-rsa fake-instance-key vcap\n"
+			metadata["/project/attributes/ssh-keys"] = "vcap:ssh-rsa fake-project-key vcap"
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa fake-instance-key vcap"))
+		})
+
+		It("falls back to project metadata", func() {
+			metadata["/project/attributes/ssh-keys"] = "vcap:ssh-rsa fake-project-key vcap"
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa fake-project-key vcap"))
+		})
+
+		It("returns an empty key when no vcap key is present", func() {
+			metadata["/instance/attributes/ssh-keys"] = "other:ssh-rsa other-key other"
+
+			publicKey, err := metadataService.GetPublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(BeEmpty())
+		})
+	})
+
+	Describe("GetInstanceID", func() {
+		It("returns the instance name", func() {
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("fake-instance-name"))
+		})
+	})
+
+	Describe("GetServerName", func() {
+		It("returns the instance name", func() {
+			serverName, err := metadataService.GetServerName()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serverName).To(Equal("fake-instance-name"))
+		})
+
+		It("returns an error when the instance name is missing", func() {
+			delete(metadata, "/instance/name")
+
+			_, err := metadataService.GetServerName()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Missing GCE metadata value at /instance/name"))
+		})
+	})
+
+	Describe("GetRegistryEndpoint", func() {
+		It("returns the registry endpoint from the custom attribute", func() {
+			endpoint, err := metadataService.GetRegistryEndpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("http://fake-registry:25777"))
+		})
+
+		It("uses the configured attribute", func() {
+			metadata["/instance/attributes/fake-attribute"] = "http://other-registry:25777"
+			httpClient := NewHTTPClient(&http.Client{}, "fake-user-agent")
+			metadataService = NewGCEMetadataService(ts.URL, "fake-attribute", platform, httpClient, boshlog.NewLogger(boshlog.LevelNone))
+
+			endpoint, err := metadataService.GetRegistryEndpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("http://other-registry:25777"))
+		})
+	})
+
+	It("sends the Metadata-Flavor header required by the metadata server", func() {
+		var unflavoredClient HTTPClient = noHeadersHTTPClient{NewHTTPClient(&http.Client{}, "fake-user-agent")}
+		metadataService = NewGCEMetadataService(ts.URL, "", platform, unflavoredClient, boshlog.NewLogger(boshlog.LevelNone))
+
+		_, err := metadataService.GetInstanceID()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unexpected status 403"))
+	})
+
+	Describe("GetNetworks", func() {
+		It("returns no networks so that DHCP is used", func() {
+			networks, err := metadataService.GetNetworks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks).To(BeNil())
+		})
+	})
+
+	Context("when no networks are configured", func() {
+		BeforeEach(func() {
+			platform.GetConfiguredNetworkInterfacesInterfaces = []string{}
+		})
+
+		It("sets up DHCP network", func() {
+			_, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(platform.SetupNetworkingNetworks).To(Equal(boshsettings.Networks{
+				"eth0": boshsettings.Network{Type: "dynamic"},
+			}))
+		})
+
+		It("returns an error when setting up DHCP fails", func() {
+			platform.SetupNetworkingErr = errors.New("fake-network-error")
+
+			_, err := metadataService.GetInstanceID()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-network-error"))
+		})
+	})
+})
+
+// noHeadersHTTPClient drops headers to verify the metadata server contract
+type noHeadersHTTPClient struct {
+	delegate HTTPClient
+}
+
+func (c noHeadersHTTPClient) Get(url string, _ map[string]string) (*http.Response, error) {
+	return c.delegate.Get(url, nil)
+}
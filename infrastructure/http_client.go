@@ -0,0 +1,142 @@
+package infrastructure
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-agent/version"
+)
+
+const (
+	metadataServiceHost = "169.254.169.254"
+
+	// GCE metadata server is usually addressed by name
+	gceMetadataServiceHost = "metadata.google.internal"
+
+	// Larger unread remainders are not worth reading to reuse connection
+	maxDrainedBodyBytes = 64 * 1024
+
+	// Same as bosh-utils HTTP client so that unresponsive
+	// endpoints do not block bootstrap indefinitely
+	httpDialTimeout         = 30 * time.Second
+	httpTLSHandshakeTimeout = 10 * time.Second
+)
+
+// DefaultHTTPClient honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// except for requests to the metadata service which are always sent directly
+var DefaultHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:               ProxyBypassingMetadataService(http.ProxyFromEnvironment),
+		Dial:                (&net.Dialer{Timeout: httpDialTimeout}).Dial,
+		TLSHandshakeTimeout: httpTLSHandshakeTimeout,
+	},
+}
+
+// KeepAliveOptions tune reuse of connections since metadata is fetched
+// with several requests in quick succession during boot
+type KeepAliveOptions struct {
+	// Every request opens new connection when set
+	Disabled bool
+
+	// Defaults to http.DefaultMaxIdleConnsPerHost
+	MaxIdleConnsPerHost int
+
+	// Idle connections are kept open indefinitely when 0
+	IdleConnTimeoutSeconds int
+}
+
+func (o KeepAliveOptions) IsDefault() bool {
+	return o == KeepAliveOptions{}
+}
+
+// NewKeepAliveHTTPClient returns client that bypasses proxy for metadata
+// service like DefaultHTTPClient but with tuned connection reuse
+func NewKeepAliveHTTPClient(opts KeepAliveOptions) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               ProxyBypassingMetadataService(http.ProxyFromEnvironment),
+			Dial:                (&net.Dialer{Timeout: httpDialTimeout}).Dial,
+			TLSHandshakeTimeout: httpTLSHandshakeTimeout,
+			DisableKeepAlives:   opts.Disabled,
+			MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+			IdleConnTimeout:     time.Duration(opts.IdleConnTimeoutSeconds) * time.Second,
+		},
+	}
+}
+
+type HTTPClient interface {
+	Get(url string, headers map[string]string) (*http.Response, error)
+}
+
+type httpClient struct {
+	client    *http.Client
+	userAgent string
+}
+
+// DefaultUserAgent identifies the agent and its version to metadata services and registries
+func DefaultUserAgent() string {
+	return "bosh-agent/" + version.Version
+}
+
+func NewHTTPClient(client *http.Client, userAgent string) HTTPClient {
+	return httpClient{
+		client:    client,
+		userAgent: userAgent,
+	}
+}
+
+func (c httpClient) Get(url string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+
+	// Explicitly configured headers take precedence
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = drainingReadCloser{resp.Body}
+
+	return resp, nil
+}
+
+// drainingReadCloser reads remaining body on close so that connection
+// can be reused even when caller does not read body, e.g. for 404 responses
+type drainingReadCloser struct {
+	io.ReadCloser
+}
+
+func (rc drainingReadCloser) Close() error {
+	_, _ = io.CopyN(ioutil.Discard, rc.ReadCloser, maxDrainedBodyBytes)
+	return rc.ReadCloser.Close()
+}
+
+func ProxyBypassingMetadataService(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if host == metadataServiceHost || strings.EqualFold(strings.TrimSuffix(host, "."), gceMetadataServiceHost) {
+			return nil, nil
+		}
+
+		return proxy(req)
+	}
+}
@@ -0,0 +1,197 @@
+package infrastructure_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+)
+
+var _ = Describe("HTTPClient", func() {
+	var (
+		ts            *httptest.Server
+		receivedAgent string
+		receivedKey   string
+	)
+
+	BeforeEach(func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedAgent = r.Header.Get("User-Agent")
+			receivedKey = r.Header.Get("key")
+		})
+		ts = httptest.NewServer(handler)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	Describe("Get", func() {
+		It("sends the configured user agent and headers", func() {
+			client := NewHTTPClient(&http.Client{}, "fake-user-agent")
+
+			resp, err := client.Get(ts.URL, map[string]string{"key": "value"})
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(receivedAgent).To(Equal("fake-user-agent"))
+			Expect(receivedKey).To(Equal("value"))
+		})
+
+		It("prefers a user agent given in the headers", func() {
+			client := NewHTTPClient(&http.Client{}, "fake-user-agent")
+
+			resp, err := client.Get(ts.URL, map[string]string{"User-Agent": "header-user-agent"})
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(receivedAgent).To(Equal("header-user-agent"))
+		})
+
+		It("returns an error when the request fails", func() {
+			client := NewHTTPClient(&http.Client{}, "fake-user-agent")
+
+			_, err := client.Get("bad-url", nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewKeepAliveHTTPClient", func() {
+		var (
+			server      *httptest.Server
+			connsLock   sync.Mutex
+			connsOpened int
+		)
+
+		BeforeEach(func() {
+			connsOpened = 0
+
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/missing" {
+					// Larger than what transport buffers when reading headers
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(strings.Repeat("fake-not-found-page", 1000)))
+					return
+				}
+				_, _ = w.Write([]byte("fake-metadata-contents"))
+			}))
+
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					connsLock.Lock()
+					connsOpened++
+					connsLock.Unlock()
+				}
+			}
+
+			server.Start()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		fetchSequentially := func(client HTTPClient) int {
+			for _, path := range []string{"/instance-id", "/missing", "/user-data"} {
+				resp, err := client.Get(server.URL+path, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				// Metadata service does not read body of 404 responses
+				if resp.StatusCode == http.StatusOK {
+					_, err = ioutil.ReadAll(resp.Body)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				Expect(resp.Body.Close()).To(Succeed())
+			}
+
+			connsLock.Lock()
+			defer connsLock.Unlock()
+			return connsOpened
+		}
+
+		It("reuses connection across sequential requests", func() {
+			client := NewHTTPClient(NewKeepAliveHTTPClient(KeepAliveOptions{MaxIdleConnsPerHost: 1}), "fake-user-agent")
+			Expect(fetchSequentially(client)).To(Equal(1))
+		})
+
+		It("keeps dial and TLS handshake timeouts of default client", func() {
+			transport := NewKeepAliveHTTPClient(KeepAliveOptions{Disabled: true}).Transport.(*http.Transport)
+			Expect(transport.TLSHandshakeTimeout).To(Equal(DefaultHTTPClient.Transport.(*http.Transport).TLSHandshakeTimeout))
+			Expect(transport.TLSHandshakeTimeout).To(Equal(10 * time.Second))
+			Expect(transport.Dial).ToNot(BeNil())
+		})
+
+		It("opens connection for every request when keep-alive is disabled", func() {
+			client := NewHTTPClient(NewKeepAliveHTTPClient(KeepAliveOptions{Disabled: true}), "fake-user-agent")
+			Expect(fetchSequentially(client)).To(Equal(3))
+		})
+	})
+
+	Describe("DefaultUserAgent", func() {
+		It("includes the agent version", func() {
+			Expect(DefaultUserAgent()).To(Equal("bosh-agent/dev"))
+		})
+	})
+
+	Describe("ProxyBypassingMetadataService", func() {
+		var proxy func(*http.Request) (*url.URL, error)
+
+		BeforeEach(func() {
+			proxy = ProxyBypassingMetadataService(func(*http.Request) (*url.URL, error) {
+				return url.Parse("http://fake-proxy:3128")
+			})
+		})
+
+		It("does not proxy requests to the metadata service", func() {
+			req, err := http.NewRequest("GET", "http://169.254.169.254/latest/user-data", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			proxyURL, err := proxy(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL).To(BeNil())
+		})
+
+		It("does not proxy requests to the metadata service on an explicit port", func() {
+			req, err := http.NewRequest("GET", "http://169.254.169.254:80/latest/user-data", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			proxyURL, err := proxy(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL).To(BeNil())
+		})
+
+		It("does not proxy requests to the GCE metadata service", func() {
+			for _, metadataURL := range []string{
+				"http://metadata.google.internal/computeMetadata/v1/instance/id",
+				"http://metadata.google.internal.:80/computeMetadata/v1/instance/id",
+				"http://Metadata.Google.Internal/computeMetadata/v1/instance/id",
+			} {
+				req, err := http.NewRequest("GET", metadataURL, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				proxyURL, err := proxy(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proxyURL).To(BeNil(), metadataURL)
+			}
+		})
+
+		It("proxies other requests", func() {
+			req, err := http.NewRequest("GET", "http://fake-registry:25777/instances/fake-id/settings", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			proxyURL, err := proxy(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL.String()).To(Equal("http://fake-proxy:3128"))
+		})
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	boshplat "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -18,8 +19,10 @@ type httpMetadataService struct {
 	userdataPath    string
 	instanceIDPath  string
 	sshKeysPath     string
+	sshKeysMaxIndex int
 	resolver        DNSResolver
 	platform        boshplat.Platform
+	httpClient      HTTPClient
 	logTag          string
 	logger          boshlog.Logger
 }
@@ -30,8 +33,10 @@ func NewHTTPMetadataService(
 	userdataPath string,
 	instanceIDPath string,
 	sshKeysPath string,
+	sshKeysMaxIndex int,
 	resolver DNSResolver,
 	platform boshplat.Platform,
+	httpClient HTTPClient,
 	logger boshlog.Logger,
 ) DynamicMetadataService {
 	return httpMetadataService{
@@ -40,8 +45,10 @@ func NewHTTPMetadataService(
 		userdataPath:    userdataPath,
 		instanceIDPath:  instanceIDPath,
 		sshKeysPath:     sshKeysPath,
+		sshKeysMaxIndex: sshKeysMaxIndex,
 		resolver:        resolver,
 		platform:        platform,
+		httpClient:      httpClient,
 		logTag:          "httpMetadataService",
 		logger:          logger,
 	}
@@ -61,10 +68,38 @@ func (ms httpMetadataService) GetPublicKey() (string, error) {
 		return "", err
 	}
 
-	url := fmt.Sprintf("%s%s", ms.metadataHost, ms.sshKeysPath)
+	indexPos := strings.LastIndex(ms.sshKeysPath, "/0/")
+	if ms.sshKeysMaxIndex == 0 || indexPos == -1 {
+		publicKey, _, err := ms.getPublicKey(ms.sshKeysPath)
+		return publicKey, err
+	}
+
+	publicKeys := []string{}
+
+	// Keys are expected to be numbered without gaps so probing stops at first missing key
+	for i := 0; i <= ms.sshKeysMaxIndex; i++ {
+		keyPath := fmt.Sprintf("%s/%d/%s", ms.sshKeysPath[:indexPos], i, ms.sshKeysPath[indexPos+len("/0/"):])
+
+		publicKey, found, err := ms.getPublicKey(keyPath)
+		if err != nil {
+			return "", err
+		}
+
+		if !found {
+			break
+		}
+
+		publicKeys = append(publicKeys, strings.TrimSpace(publicKey))
+	}
+
+	return strings.Join(publicKeys, "\n"), nil
+}
+
+func (ms httpMetadataService) getPublicKey(keyPath string) (string, bool, error) {
+	url := fmt.Sprintf("%s%s", ms.metadataHost, keyPath)
 	resp, err := ms.doGet(url)
 	if err != nil {
-		return "", bosherr.WrapErrorf(err, "Getting open ssh key from url %s", url)
+		return "", false, bosherr.WrapErrorf(err, "Getting open ssh key from url %s", url)
 	}
 
 	defer func() {
@@ -73,12 +108,16 @@ func (ms httpMetadataService) GetPublicKey() (string, error) {
 		}
 	}()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+
 	bytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", bosherr.WrapError(err, "Reading ssh key response body")
+		return "", false, bosherr.WrapError(err, "Reading ssh key response body")
 	}
 
-	return string(bytes), nil
+	return string(bytes), true, nil
 }
 
 func (ms httpMetadataService) GetInstanceID() (string, error) {
@@ -214,17 +253,21 @@ func (ms httpMetadataService) getUserData() (UserDataContentsType, error) {
 }
 
 func (ms httpMetadataService) ensureMinimalNetworkSetup() error {
+	return ensureMinimalNetworkSetup(ms.platform, ms.logTag, ms.logger)
+}
+
+func ensureMinimalNetworkSetup(platform boshplat.Platform, logTag string, logger boshlog.Logger) error {
 	// We check for configuration presence instead of verifying
 	// that network is reachable because we want to preserve
 	// network configuration that was passed to agent.
-	configuredInterfaces, err := ms.platform.GetConfiguredNetworkInterfaces()
+	configuredInterfaces, err := platform.GetConfiguredNetworkInterfaces()
 	if err != nil {
 		return bosherr.WrapError(err, "Getting configured network interfaces")
 	}
 
 	if len(configuredInterfaces) == 0 {
-		ms.logger.Debug(ms.logTag, "No configured networks found, setting up DHCP network")
-		err = ms.platform.SetupNetworking(boshsettings.Networks{
+		logger.Debug(logTag, "No configured networks found, setting up DHCP network")
+		err = platform.SetupNetworking(boshsettings.Networks{
 			"eth0": {
 				Type: boshsettings.NetworkTypeDynamic,
 			},
@@ -238,16 +281,5 @@ func (ms httpMetadataService) ensureMinimalNetworkSetup() error {
 }
 
 func (ms httpMetadataService) doGet(url string) (*http.Response, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	for key, value := range ms.metadataHeaders {
-		req.Header.Add(key, value)
-	}
-
-	return client.Do(req)
+	return ms.httpClient.Get(url, ms.metadataHeaders)
 }
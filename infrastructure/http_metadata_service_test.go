@@ -26,15 +26,17 @@ func describeHTTPMetadataService() {
 		platform        *fakeplat.FakePlatform
 		logger          boshlog.Logger
 		metadataService MetadataService
+		httpClient      HTTPClient
 	)
 
 	BeforeEach(func() {
+		httpClient = NewHTTPClient(&http.Client{}, "fake-user-agent")
 		metadataHeaders = make(map[string]string)
 		metadataHeaders["key"] = "value"
 		dnsResolver = &fakeinf.FakeDNSResolver{}
 		platform = fakeplat.NewFakePlatform()
 		logger = boshlog.NewLogger(boshlog.LevelNone)
-		metadataService = NewHTTPMetadataService("fake-metadata-host", metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", dnsResolver, platform, logger)
+		metadataService = NewHTTPMetadataService("fake-metadata-host", metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", 0, dnsResolver, platform, httpClient, logger)
 	})
 
 	ItEnsuresMinimalNetworkSetup := func(subject func() (string, error)) {
@@ -88,6 +90,7 @@ func describeHTTPMetadataService() {
 				Expect(r.Method).To(Equal("GET"))
 				Expect(r.URL.Path).To(Equal("/ssh-keys"))
 				Expect(r.Header.Get("key")).To(Equal("value"))
+				Expect(r.Header.Get("User-Agent")).To(Equal("fake-user-agent"))
 
 				w.Write([]byte("fake-public-key"))
 			})
@@ -101,7 +104,7 @@ func describeHTTPMetadataService() {
 		Context("when the ssh keys path is present", func() {
 			BeforeEach(func() {
 				sshKeysPath = "/ssh-keys"
-				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", sshKeysPath, dnsResolver, platform, logger)
+				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", sshKeysPath, 0, dnsResolver, platform, httpClient, logger)
 			})
 
 			It("returns fetched public key", func() {
@@ -115,10 +118,73 @@ func describeHTTPMetadataService() {
 			})
 		})
 
+		Context("when multiple keys are enabled", func() {
+			var (
+				keysServer     *httptest.Server
+				requestedPaths []string
+			)
+
+			BeforeEach(func() {
+				requestedPaths = []string{}
+
+				handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requestedPaths = append(requestedPaths, r.URL.Path)
+
+					switch r.URL.Path {
+					case "/public-keys/0/openssh-key":
+						w.Write([]byte("fake-public-key-0\n"))
+					case "/public-keys/1/openssh-key":
+						w.Write([]byte("fake-public-key-1\n"))
+					default:
+						w.WriteHeader(http.StatusNotFound)
+					}
+				})
+				keysServer = httptest.NewServer(handler)
+			})
+
+			AfterEach(func() {
+				keysServer.Close()
+			})
+
+			It("returns keys up to first missing index", func() {
+				metadataService = NewHTTPMetadataService(keysServer.URL, metadataHeaders, "/user-data", "/instanceid", "/public-keys/0/openssh-key", 5, dnsResolver, platform, httpClient, logger)
+
+				publicKey, err := metadataService.GetPublicKey()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(publicKey).To(Equal("fake-public-key-0\nfake-public-key-1"))
+				Expect(requestedPaths).To(Equal([]string{
+					"/public-keys/0/openssh-key",
+					"/public-keys/1/openssh-key",
+					"/public-keys/2/openssh-key",
+				}))
+			})
+
+			It("does not probe indices beyond max index", func() {
+				metadataService = NewHTTPMetadataService(keysServer.URL, metadataHeaders, "/user-data", "/instanceid", "/public-keys/0/openssh-key", 1, dnsResolver, platform, httpClient, logger)
+
+				publicKey, err := metadataService.GetPublicKey()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(publicKey).To(Equal("fake-public-key-0\nfake-public-key-1"))
+				Expect(requestedPaths).To(Equal([]string{
+					"/public-keys/0/openssh-key",
+					"/public-keys/1/openssh-key",
+				}))
+			})
+
+			It("only fetches key at index 0 when max index is not set", func() {
+				metadataService = NewHTTPMetadataService(keysServer.URL, metadataHeaders, "/user-data", "/instanceid", "/public-keys/0/openssh-key", 0, dnsResolver, platform, httpClient, logger)
+
+				publicKey, err := metadataService.GetPublicKey()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(publicKey).To(Equal("fake-public-key-0\n"))
+				Expect(requestedPaths).To(Equal([]string{"/public-keys/0/openssh-key"}))
+			})
+		})
+
 		Context("when the ssh keys path is not present", func() {
 			BeforeEach(func() {
 				sshKeysPath = ""
-				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", sshKeysPath, dnsResolver, platform, logger)
+				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", sshKeysPath, 0, dnsResolver, platform, httpClient, logger)
 			})
 
 			It("returns an empty ssh key", func() {
@@ -142,6 +208,7 @@ func describeHTTPMetadataService() {
 				Expect(r.Method).To(Equal("GET"))
 				Expect(r.URL.Path).To(Equal("/instanceid"))
 				Expect(r.Header.Get("key")).To(Equal("value"))
+				Expect(r.Header.Get("User-Agent")).To(Equal("fake-user-agent"))
 
 				w.Write([]byte("fake-instance-id"))
 			})
@@ -155,7 +222,7 @@ func describeHTTPMetadataService() {
 		Context("when the instance ID path is present", func() {
 			BeforeEach(func() {
 				instanceIDPath = "/instanceid"
-				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", instanceIDPath, "/ssh-keys", dnsResolver, platform, logger)
+				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", instanceIDPath, "/ssh-keys", 0, dnsResolver, platform, httpClient, logger)
 			})
 
 			It("returns fetched instance id", func() {
@@ -172,7 +239,7 @@ func describeHTTPMetadataService() {
 		Context("when the instance ID path is not present", func() {
 			BeforeEach(func() {
 				instanceIDPath = ""
-				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", instanceIDPath, "/ssh-keys", dnsResolver, platform, logger)
+				metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", instanceIDPath, "/ssh-keys", 0, dnsResolver, platform, httpClient, logger)
 			})
 
 			It("returns an empty instance ID", func() {
@@ -195,6 +262,7 @@ func describeHTTPMetadataService() {
 			Expect(r.Method).To(Equal("GET"))
 			Expect(r.URL.Path).To(Equal("/user-data"))
 			Expect(r.Header.Get("key")).To(Equal("value"))
+			Expect(r.Header.Get("User-Agent")).To(Equal("fake-user-agent"))
 
 			var jsonStr string
 
@@ -212,7 +280,7 @@ func describeHTTPMetadataService() {
 
 			handler := http.HandlerFunc(handlerFunc)
 			ts = httptest.NewServer(handler)
-			metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", dnsResolver, platform, logger)
+			metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", 0, dnsResolver, platform, httpClient, logger)
 		})
 
 		AfterEach(func() {
@@ -262,6 +330,7 @@ func describeHTTPMetadataService() {
 			Expect(r.Method).To(Equal("GET"))
 			Expect(r.URL.Path).To(Equal("/user-data"))
 			Expect(r.Header.Get("key")).To(Equal("value"))
+			Expect(r.Header.Get("User-Agent")).To(Equal("fake-user-agent"))
 
 			var jsonStr string
 
@@ -284,7 +353,7 @@ func describeHTTPMetadataService() {
 
 			handler := http.HandlerFunc(handlerFunc)
 			ts = httptest.NewServer(handler)
-			metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", dnsResolver, platform, logger)
+			metadataService = NewHTTPMetadataService(ts.URL, metadataHeaders, "/user-data", "/instanceid", "/ssh-keys", 0, dnsResolver, platform, httpClient, logger)
 		})
 
 		AfterEach(func() {
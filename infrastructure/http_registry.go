@@ -1,31 +1,83 @@
 package infrastructure
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	boshplat "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+// DefaultRegistrySettingsPathTemplate is appended to registry endpoint;
+// {id} is replaced with instance id or server name
+const DefaultRegistrySettingsPathTemplate = "/instances/{id}/settings"
+
+// RegistrySettingsNotFoundError is returned when registry responds with 404
+// that does not indicate settings are about to become available
+type RegistrySettingsNotFoundError struct {
+	URL string
+}
+
+func (e RegistrySettingsNotFoundError) Error() string {
+	return fmt.Sprintf("Registry has no settings at url %s", e.URL)
+}
+
+// RegistryConnectError is returned when registry host could not be connected to;
+// IP is set to the address tried when host had to be resolved first
+type RegistryConnectError struct {
+	Host string
+	IP   string
+	Err  error
+}
+
+func (e RegistryConnectError) Error() string {
+	if e.IP != "" && e.IP != e.Host {
+		return fmt.Sprintf("Resolved registry host '%s' to '%s' but could not connect: %s", e.Host, e.IP, e.Err.Error())
+	}
+
+	return fmt.Sprintf("Could not connect to registry host '%s': %s", e.Host, e.Err.Error())
+}
+
 type httpRegistry struct {
 	metadataService   MetadataService
 	platform          boshplat.Platform
 	useServerNameAsID bool
+	httpClient        HTTPClient
+	backoff           boshbackoff.Backoff
+
+	// When empty every 404 is retried
+	retryableNotFoundBodies []string
+
+	// Defaults to DefaultRegistrySettingsPathTemplate
+	settingsPathTemplate string
 }
 
 func NewHTTPRegistry(
 	metadataService MetadataService,
 	platform boshplat.Platform,
 	useServerNameAsID bool,
+	httpClient HTTPClient,
+	backoff boshbackoff.Backoff,
+	retryableNotFoundBodies []string,
+	settingsPathTemplate string,
 ) Registry {
 	return httpRegistry{
-		metadataService:   metadataService,
-		platform:          platform,
-		useServerNameAsID: useServerNameAsID,
+		metadataService:         metadataService,
+		platform:                platform,
+		useServerNameAsID:       useServerNameAsID,
+		httpClient:              httpClient,
+		backoff:                 backoff,
+		retryableNotFoundBodies: retryableNotFoundBodies,
+		settingsPathTemplate:    settingsPathTemplate,
 	}
 }
 
@@ -51,6 +103,14 @@ func (r httpRegistry) GetSettings() (boshsettings.Settings, error) {
 		}
 	}
 
+	// Otherwise settings would be requested from e.g. /instances//settings
+	if strings.TrimSpace(identifier) == "" {
+		if r.useServerNameAsID {
+			return settings, bosherr.Error("Metadata service returned empty server name")
+		}
+		return settings, bosherr.Error("Metadata service returned empty instance id")
+	}
+
 	registryEndpoint, err := r.metadataService.GetRegistryEndpoint()
 	if err != nil {
 		return settings, bosherr.WrapError(err, "Getting registry endpoint")
@@ -68,19 +128,16 @@ func (r httpRegistry) GetSettings() (boshsettings.Settings, error) {
 		}
 	}
 
-	settingsURL := fmt.Sprintf("%s/instances/%s/settings", registryEndpoint, identifier)
-	wrapperResponse, err := http.Get(settingsURL)
-	if err != nil {
-		return settings, bosherr.WrapError(err, "Getting settings from url")
-	}
-
-	defer func() {
-		_ = wrapperResponse.Body.Close()
-	}()
+	settingsURL := registryEndpoint + r.settingsPath(identifier)
 
-	wrapperBytes, err := ioutil.ReadAll(wrapperResponse.Body)
+	// Registry may not be reachable right after networking is set up
+	var wrapperBytes []byte
+	err = r.backoff.Retry(context.Background(), func() error {
+		wrapperBytes, err = r.fetchSettingsWrapper(settingsURL)
+		return err
+	})
 	if err != nil {
-		return settings, bosherr.WrapError(err, "Reading settings response body")
+		return settings, err
 	}
 
 	var wrapper settingsWrapperType
@@ -97,3 +154,88 @@ func (r httpRegistry) GetSettings() (boshsettings.Settings, error) {
 
 	return settings, nil
 }
+
+func (r httpRegistry) fetchSettingsWrapper(settingsURL string) ([]byte, error) {
+	wrapperResponse, err := r.httpClient.Get(settingsURL, nil)
+	if err != nil {
+		return nil, registryRequestError(settingsURL, err)
+	}
+
+	defer func() {
+		_ = wrapperResponse.Body.Close()
+	}()
+
+	if wrapperResponse.StatusCode == http.StatusNotFound && len(r.retryableNotFoundBodies) > 0 {
+		return nil, r.notFoundError(settingsURL, wrapperResponse)
+	}
+
+	if wrapperResponse.StatusCode != http.StatusOK {
+		return nil, bosherr.Errorf("Getting settings from url %s: unexpected status %d", settingsURL, wrapperResponse.StatusCode)
+	}
+
+	wrapperBytes, err := ioutil.ReadAll(wrapperResponse.Body)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading settings response body")
+	}
+
+	return wrapperBytes, nil
+}
+
+// registryRequestError tells apart registry host that could not be resolved
+// from one that was resolved but could not be connected to; settings URL
+// has IP instead of hostname when registry endpoint resolver resolved it
+func registryRequestError(settingsURL string, err error) error {
+	parsedURL, parseErr := url.Parse(settingsURL)
+	if parseErr != nil {
+		return bosherr.WrapError(err, "Getting settings from url")
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return RegistryLookupError{Host: parsedURL.Hostname(), Err: dnsErr}
+	}
+
+	connectErr := RegistryConnectError{Host: parsedURL.Hostname(), Err: err}
+
+	var resolvedErr ResolvedHostError
+	if errors.As(err, &resolvedErr) {
+		connectErr.Host = resolvedErr.Host
+		connectErr.IP = resolvedErr.IP
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Addr != nil {
+		ip, _, splitErr := net.SplitHostPort(opErr.Addr.String())
+		if splitErr == nil {
+			connectErr.IP = ip
+		}
+	}
+
+	return connectErr
+}
+
+// notFoundError tells apart registry that does not have settings yet
+// from registry that will never have them based on response body
+func (r httpRegistry) notFoundError(settingsURL string, response *http.Response) error {
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading settings response body")
+	}
+
+	for _, retryableBody := range r.retryableNotFoundBodies {
+		if strings.Contains(string(bodyBytes), retryableBody) {
+			return bosherr.Errorf("Getting settings from url %s: settings not ready yet", settingsURL)
+		}
+	}
+
+	return boshbackoff.Permanent(RegistrySettingsNotFoundError{URL: settingsURL})
+}
+
+func (r httpRegistry) settingsPath(identifier string) string {
+	template := r.settingsPathTemplate
+	if template == "" {
+		template = DefaultRegistrySettingsPathTemplate
+	}
+
+	return strings.Replace(template, "{id}", identifier, -1)
+}
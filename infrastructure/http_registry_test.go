@@ -6,14 +6,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	. "github.com/cloudfoundry/bosh-agent/infrastructure"
 	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
 	fakeplat "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("httpRegistry", describeHTTPRegistry)
@@ -23,18 +28,32 @@ func describeHTTPRegistry() {
 		metadataService *fakeinf.FakeMetadataService
 		registry        Registry
 		platform        *fakeplat.FakePlatform
+		httpClient      HTTPClient
+		fakeClock       *fakeclock.FakeClock
+		backoff         boshbackoff.Backoff
 	)
 
 	BeforeEach(func() {
+		httpClient = NewHTTPClient(&http.Client{}, "fake-user-agent")
 		metadataService = &fakeinf.FakeMetadataService{}
 		platform = &fakeplat.FakePlatform{}
-		registry = NewHTTPRegistry(metadataService, platform, false)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		backoff = boshbackoff.New(boshbackoff.Options{
+			InitialInterval: 1 * time.Second,
+			Multiplier:      2,
+			MaxInterval:     4 * time.Second,
+			MaxElapsedTime:  3 * time.Second,
+		}, fakeClock)
+		registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "")
 	})
 
 	Describe("GetSettings", func() {
 		var (
-			ts           *httptest.Server
-			settingsJSON string
+			ts               *httptest.Server
+			settingsJSON     string
+			failingRequests  int
+			notFoundRequests int
+			notFoundBody     string
 		)
 
 		BeforeEach(func() {
@@ -43,10 +62,26 @@ func describeHTTPRegistry() {
 
 				Expect(r.Method).To(Equal("GET"))
 				Expect(r.URL.Path).To(Equal("/instances/fake-identifier/settings"))
+				Expect(r.Header.Get("User-Agent")).To(Equal("fake-user-agent"))
+
+				if failingRequests > 0 {
+					failingRequests--
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				if notFoundRequests > 0 {
+					notFoundRequests--
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(notFoundBody))
+					return
+				}
 
 				w.Write([]byte(settingsJSON))
 			})
 
+			failingRequests = 0
+			notFoundRequests = 0
 			ts = httptest.NewServer(boshRegistryHandler)
 		})
 
@@ -59,7 +94,7 @@ func describeHTTPRegistry() {
 				settingsJSON = `{"settings": "{\"agent_id\":\"my-agent-id\"}"}`
 				metadataService.InstanceID = "fake-identifier"
 				metadataService.RegistryEndpoint = ts.URL
-				registry = NewHTTPRegistry(metadataService, platform, false)
+				registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "")
 			})
 
 			Context("when the metadata has Networks information", func() {
@@ -120,7 +155,7 @@ func describeHTTPRegistry() {
 
 		Context("when registry is configured to not use server name as id", func() {
 			BeforeEach(func() {
-				registry = NewHTTPRegistry(metadataService, platform, false)
+				registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "")
 				metadataService.InstanceID = "fake-identifier"
 				metadataService.RegistryEndpoint = ts.URL
 			})
@@ -133,6 +168,140 @@ func describeHTTPRegistry() {
 				Expect(settings).To(Equal(boshsettings.Settings{AgentID: "my-agent-id"}))
 			})
 
+			It("fetches settings based on instance id from override file instead of metadata service", func() {
+				settingsJSON = `{"settings": "{\"agent_id\":\"my-agent-id\"}"}`
+				metadataService.InstanceID = "fake-wrong-identifier"
+
+				fs := fakesys.NewFakeFileSystem()
+				fs.WriteFileString("/var/vcap/bosh/instance-id", "fake-identifier")
+				overrideMetadataService := NewInstanceIDOverrideMetadataService(metadataService, "/var/vcap/bosh/instance-id", fs, boshlog.NewLogger(boshlog.LevelNone))
+				registry = NewHTTPRegistry(overrideMetadataService, platform, false, httpClient, backoff, nil, "")
+
+				settings, err := registry.GetSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(settings).To(Equal(boshsettings.Settings{AgentID: "my-agent-id"}))
+			})
+
+			It("retries fetching settings when registry responds with an error", func() {
+				settingsJSON = `{"settings": "{\"agent_id\":\"my-agent-id\"}"}`
+				failingRequests = 2
+
+				resultCh := make(chan boshsettings.Settings)
+				go func() {
+					defer GinkgoRecover()
+					settings, err := registry.GetSettings()
+					Expect(err).ToNot(HaveOccurred())
+					resultCh <- settings
+				}()
+
+				fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+				fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+				Eventually(resultCh).Should(Receive(Equal(boshsettings.Settings{AgentID: "my-agent-id"})))
+				Expect(failingRequests).To(Equal(0))
+			})
+
+			It("returns error if registry keeps responding with an error", func() {
+				failingRequests = 10
+
+				errCh := make(chan error)
+				go func() {
+					_, err := registry.GetSettings()
+					errCh <- err
+				}()
+
+				fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+				fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+				var err error
+				Eventually(errCh).Should(Receive(&err))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Giving up after 3 attempts"))
+				Expect(err.Error()).To(ContainSubstring("unexpected status 500"))
+			})
+
+			Context("when registry cannot be connected to", func() {
+				getSettingsErr := func() error {
+					errCh := make(chan error)
+					go func() {
+						_, err := registry.GetSettings()
+						errCh <- err
+					}()
+
+					fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+					fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+					var err error
+					Eventually(errCh).Should(Receive(&err))
+					return err
+				}
+
+				BeforeEach(func() {
+					ts.Close()
+				})
+
+				It("returns connect error including registry IP", func() {
+					err := getSettingsErr()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Giving up after 3 attempts"))
+					Expect(err.Error()).To(ContainSubstring("Could not connect to registry host '127.0.0.1'"))
+				})
+
+				It("returns connect error including registry host and IP it was resolved to", func() {
+					metadataService.RegistryEndpoint = strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+					err := getSettingsErr()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(MatchRegexp("Resolved registry host 'localhost' to '(127.0.0.1|::1)' but could not connect"))
+				})
+
+				It("returns connect error including registry host that registry endpoint resolver resolved", func() {
+					resolvedHosts := NewResolvedHosts()
+					resolvedHosts.Add("127.0.0.1", "fake-registry.bosh")
+
+					httpClient = NewHTTPClient(&http.Client{Transport: newServerNameTransport(&http.Client{}, "", resolvedHosts)}, "fake-user-agent")
+					registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "")
+
+					err := getSettingsErr()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Resolved registry host 'fake-registry.bosh' to '127.0.0.1' but could not connect"))
+				})
+			})
+
+			Context("when registry responds with 404", func() {
+				BeforeEach(func() {
+					registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, []string{"not ready"}, "")
+					settingsJSON = `{"settings": "{\"agent_id\":\"my-agent-id\"}"}`
+					notFoundRequests = 1
+				})
+
+				It("retries fetching settings when response body indicates settings are not ready", func() {
+					notFoundBody = `{"status": "not ready"}`
+
+					resultCh := make(chan boshsettings.Settings)
+					go func() {
+						defer GinkgoRecover()
+						settings, err := registry.GetSettings()
+						Expect(err).ToNot(HaveOccurred())
+						resultCh <- settings
+					}()
+
+					fakeClock.WaitForWatcherAndIncrement(1 * time.Second)
+
+					Eventually(resultCh).Should(Receive(Equal(boshsettings.Settings{AgentID: "my-agent-id"})))
+					Expect(notFoundRequests).To(Equal(0))
+				})
+
+				It("returns not found error without retrying when response body does not indicate settings are not ready", func() {
+					notFoundBody = `{"status": "not found"}`
+
+					_, err := registry.GetSettings()
+					Expect(err).To(HaveOccurred())
+					Expect(err).To(Equal(RegistrySettingsNotFoundError{URL: ts.URL + "/instances/fake-identifier/settings"}))
+					Expect(fakeClock.WatcherCount()).To(Equal(0))
+				})
+			})
+
 			It("returns error if registry settings wrapper cannot be parsed", func() {
 				settingsJSON = "invalid-json"
 
@@ -282,9 +451,63 @@ func describeHTTPRegistry() {
 			})
 		})
 
+		Context("when metadata service returns empty instance id", func() {
+			BeforeEach(func() {
+				metadataService.InstanceID = ""
+				metadataService.RegistryEndpoint = ts.URL
+			})
+
+			It("returns error without requesting settings", func() {
+				settings, err := registry.GetSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Metadata service returned empty instance id"))
+				Expect(settings).To(Equal(boshsettings.Settings{}))
+			})
+		})
+
+		Context("when metadata service returns empty server name", func() {
+			BeforeEach(func() {
+				registry = NewHTTPRegistry(metadataService, platform, true, httpClient, backoff, nil, "")
+				metadataService.ServerName = ""
+				metadataService.RegistryEndpoint = ts.URL
+			})
+
+			It("returns error without requesting settings", func() {
+				_, err := registry.GetSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Metadata service returned empty server name"))
+			})
+		})
+
+		Context("when settings path template is configured", func() {
+			var requestedPaths []string
+
+			BeforeEach(func() {
+				requestedPaths = nil
+
+				ts.Close()
+				ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requestedPaths = append(requestedPaths, r.URL.Path)
+					w.Write([]byte(`{"settings": "{\"agent_id\":\"my-agent-id\"}"}`))
+				}))
+
+				metadataService.InstanceID = "fake-identifier"
+				metadataService.RegistryEndpoint = ts.URL
+				registry = NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "/v2/agents/{id}/settings")
+			})
+
+			It("requests settings at path built from the template", func() {
+				settings, err := registry.GetSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(settings).To(Equal(boshsettings.Settings{AgentID: "my-agent-id"}))
+
+				Expect(requestedPaths).To(Equal([]string{"/v2/agents/fake-identifier/settings"}))
+			})
+		})
+
 		Context("when registry is configured to use server name as id", func() {
 			BeforeEach(func() {
-				registry = NewHTTPRegistry(metadataService, platform, true)
+				registry = NewHTTPRegistry(metadataService, platform, true, httpClient, backoff, nil, "")
 				metadataService.ServerName = "fake-identifier"
 				metadataService.RegistryEndpoint = ts.URL
 			})
@@ -0,0 +1,56 @@
+package infrastructure
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// instanceIDOverrideMetadataService lets operators pin instance id used
+// to build registry settings URL when metadata service reports a wrong one
+type instanceIDOverrideMetadataService struct {
+	MetadataService
+
+	instanceIDPath string
+	fs             boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewInstanceIDOverrideMetadataService(
+	metadataService MetadataService,
+	instanceIDPath string,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) MetadataService {
+	return instanceIDOverrideMetadataService{
+		MetadataService: metadataService,
+
+		instanceIDPath: instanceIDPath,
+		fs:             fs,
+
+		logTag: "instanceIDOverrideMetadataService",
+		logger: logger,
+	}
+}
+
+// GetInstanceID prefers non-empty instance id file over metadata service
+func (ms instanceIDOverrideMetadataService) GetInstanceID() (string, error) {
+	if ms.fs.FileExists(ms.instanceIDPath) {
+		contents, err := ms.fs.ReadFileString(ms.instanceIDPath)
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Reading instance id file '%s'", ms.instanceIDPath)
+		}
+
+		instanceID := strings.TrimSpace(contents)
+		if instanceID != "" {
+			ms.logger.Info(ms.logTag, "Using instance id '%s' from '%s' instead of metadata service", instanceID, ms.instanceIDPath)
+			return instanceID, nil
+		}
+	}
+
+	return ms.MetadataService.GetInstanceID()
+}
@@ -0,0 +1,71 @@
+package infrastructure_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("InstanceIDOverrideMetadataService", func() {
+	var (
+		delegate        *fakeinf.FakeMetadataService
+		fs              *fakesys.FakeFileSystem
+		metadataService MetadataService
+	)
+
+	BeforeEach(func() {
+		delegate = &fakeinf.FakeMetadataService{
+			InstanceID:       "fake-metadata-instance-id",
+			RegistryEndpoint: "http://fake-registry",
+		}
+		fs = fakesys.NewFakeFileSystem()
+		metadataService = NewInstanceIDOverrideMetadataService(delegate, "/var/vcap/bosh/instance-id", fs, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("GetInstanceID", func() {
+		It("returns instance id from file when file exists", func() {
+			fs.WriteFileString("/var/vcap/bosh/instance-id", "fake-pinned-instance-id\n")
+
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("fake-pinned-instance-id"))
+		})
+
+		It("returns instance id from metadata service when file does not exist", func() {
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("fake-metadata-instance-id"))
+		})
+
+		It("returns instance id from metadata service when file is empty", func() {
+			fs.WriteFileString("/var/vcap/bosh/instance-id", " \n")
+
+			instanceID, err := metadataService.GetInstanceID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceID).To(Equal("fake-metadata-instance-id"))
+		})
+
+		It("returns error when file cannot be read", func() {
+			fs.WriteFileString("/var/vcap/bosh/instance-id", "fake-pinned-instance-id")
+			fs.ReadFileError = errors.New("fake-read-err")
+
+			_, err := metadataService.GetInstanceID()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading instance id file '/var/vcap/bosh/instance-id'"))
+		})
+	})
+
+	It("delegates other metadata to metadata service", func() {
+		fs.WriteFileString("/var/vcap/bosh/instance-id", "fake-pinned-instance-id")
+
+		endpoint, err := metadataService.GetRegistryEndpoint()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint).To(Equal("http://fake-registry"))
+	})
+})
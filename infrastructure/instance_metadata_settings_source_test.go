@@ -30,7 +30,7 @@ func describeInstanceMetadataSettingsSource() {
 		settingsPath = "/computeMetadata/v1/instance/attributes/bosh_settings"
 		platform = fakeplat.NewFakePlatform()
 		logger = boshlog.NewLogger(boshlog.LevelNone)
-		metadataSource = NewInstanceMetadataSettingsSource("http://fake-metadata-host", metadataHeaders, settingsPath, platform, logger)
+		metadataSource = NewInstanceMetadataSettingsSource("http://fake-metadata-host", metadataHeaders, settingsPath, platform, NewHTTPClient(&http.Client{}, "fake-user-agent"), logger)
 	})
 
 	Describe("PublicSSHKeyForUsername", func() {
@@ -63,7 +63,7 @@ func describeInstanceMetadataSettingsSource() {
 		BeforeEach(func() {
 			handler := http.HandlerFunc(handlerFunc)
 			ts = httptest.NewServer(handler)
-			metadataSource = NewInstanceMetadataSettingsSource(ts.URL, metadataHeaders, settingsPath, platform, logger)
+			metadataSource = NewInstanceMetadataSettingsSource(ts.URL, metadataHeaders, settingsPath, platform, NewHTTPClient(&http.Client{}, "fake-user-agent"), logger)
 		})
 
 		AfterEach(func() {
@@ -77,7 +77,7 @@ func describeInstanceMetadataSettingsSource() {
 		})
 
 		It("returns an error if reading from the instance metadata endpoint fails", func() {
-			metadataSource = NewInstanceMetadataSettingsSource("bad-registry-endpoint", metadataHeaders, settingsPath, platform, logger)
+			metadataSource = NewInstanceMetadataSettingsSource("bad-registry-endpoint", metadataHeaders, settingsPath, platform, NewHTTPClient(&http.Client{}, "fake-user-agent"), logger)
 			_, err := metadataSource.Settings()
 			Expect(err).To(HaveOccurred())
 		})
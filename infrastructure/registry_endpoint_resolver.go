@@ -4,17 +4,57 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+// RegistryLookupError is returned when registry host cannot be resolved
+// as opposed to RegistryConnectError when it is resolved but unreachable
+type RegistryLookupError struct {
+	Host string
+	Err  error
+}
+
+func (e RegistryLookupError) Error() string {
+	return fmt.Sprintf("DNS lookup failed for registry host '%s': %s", e.Host, e.Err.Error())
+}
+
+// ResolvedHosts remembers registry hostnames by resolved IPs
+// so that TLS connections to those IPs can still verify hostnames
+type ResolvedHosts struct {
+	lock      sync.RWMutex
+	hostnames map[string]string
+}
+
+func NewResolvedHosts() *ResolvedHosts {
+	return &ResolvedHosts{hostnames: map[string]string{}}
+}
+
+func (h *ResolvedHosts) Add(ip, hostname string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.hostnames[ip] = hostname
+}
+
+func (h *ResolvedHosts) Hostname(ip string) (string, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	hostname, found := h.hostnames[ip]
+	return hostname, found
+}
+
 type registryEndpointResolver struct {
-	delegate DNSResolver
+	delegate      DNSResolver
+	resolvedHosts *ResolvedHosts
 }
 
-func NewRegistryEndpointResolver(resolver DNSResolver) DNSResolver {
+func NewRegistryEndpointResolver(resolver DNSResolver, resolvedHosts *ResolvedHosts) DNSResolver {
 	return registryEndpointResolver{
-		delegate: resolver,
+		delegate:      resolver,
+		resolvedHosts: resolvedHosts,
 	}
 }
 
@@ -27,7 +67,11 @@ func (r registryEndpointResolver) LookupHost(dnsServers []string, endpoint strin
 	registryHostAndPort := strings.Split(registryURL.Host, ":")
 	registryIP, err := r.delegate.LookupHost(dnsServers, registryHostAndPort[0])
 	if err != nil {
-		return "", bosherr.WrapError(err, "Looking up registry")
+		return "", RegistryLookupError{Host: registryHostAndPort[0], Err: err}
+	}
+
+	if registryIP != registryHostAndPort[0] {
+		r.resolvedHosts.Add(registryIP, registryHostAndPort[0])
 	}
 
 	if len(registryHostAndPort) == 2 {
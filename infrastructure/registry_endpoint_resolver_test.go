@@ -16,12 +16,14 @@ var _ = Describe("RegistryEndpointResolver", func() {
 			dnsServers               []string
 			registryEndpointResolver DNSResolver
 			delegate                 *fakeinf.FakeDNSResolver
+			resolvedHosts            *ResolvedHosts
 		)
 
 		BeforeEach(func() {
 			dnsServers = []string{"fake-dns-server-ip"}
 			delegate = &fakeinf.FakeDNSResolver{}
-			registryEndpointResolver = NewRegistryEndpointResolver(delegate)
+			resolvedHosts = NewResolvedHosts()
+			registryEndpointResolver = NewRegistryEndpointResolver(delegate, resolvedHosts)
 		})
 
 		Context("when registry endpoint is successfully resolved", func() {
@@ -48,6 +50,15 @@ var _ = Describe("RegistryEndpointResolver", func() {
 					Expect(resolvedEndpoint).To(Equal("http://fake-registry-ip"))
 				})
 			})
+
+			It("remembers hostname of resolved IP", func() {
+				_, err := registryEndpointResolver.LookupHost(dnsServers, "https://fake-registry.com:8877")
+				Expect(err).ToNot(HaveOccurred())
+
+				hostname, found := resolvedHosts.Hostname("fake-registry-ip")
+				Expect(found).To(BeTrue())
+				Expect(hostname).To(Equal("fake-registry.com"))
+			})
 		})
 
 		Context("when registry endpoint is not successfully resolved", func() {
@@ -61,6 +72,12 @@ var _ = Describe("RegistryEndpointResolver", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-lookup-host-err"))
 			})
+
+			It("returns DNS lookup error including registry host", func() {
+				_, err := registryEndpointResolver.LookupHost(dnsServers, "http://fake-registry.com:8877")
+				Expect(err).To(Equal(RegistryLookupError{Host: "fake-registry.com", Err: delegate.LookupHostErr}))
+				Expect(err.Error()).To(Equal("DNS lookup failed for registry host 'fake-registry.com': fake-lookup-host-err"))
+			})
 		})
 	})
 })
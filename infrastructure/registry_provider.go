@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"strings"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	boshplat "github.com/cloudfoundry/bosh-agent/platform"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -14,12 +15,16 @@ type RegistryProvider interface {
 }
 
 type registryProvider struct {
-	metadataService MetadataService
-	useServerName   bool
-	platform        boshplat.Platform
-	fs              boshsys.FileSystem
-	logTag          string
-	logger          boshlog.Logger
+	metadataService         MetadataService
+	useServerName           bool
+	platform                boshplat.Platform
+	fs                      boshsys.FileSystem
+	httpClient              HTTPClient
+	backoff                 boshbackoff.Backoff
+	retryableNotFoundBodies []string
+	settingsPathTemplate    string
+	logTag                  string
+	logger                  boshlog.Logger
 }
 
 func NewRegistryProvider(
@@ -27,15 +32,23 @@ func NewRegistryProvider(
 	platform boshplat.Platform,
 	useServerName bool,
 	fs boshsys.FileSystem,
+	httpClient HTTPClient,
+	backoff boshbackoff.Backoff,
+	retryableNotFoundBodies []string,
+	settingsPathTemplate string,
 	logger boshlog.Logger,
 ) RegistryProvider {
 	return &registryProvider{
-		metadataService: metadataService,
-		platform:        platform,
-		useServerName:   useServerName,
-		fs:              fs,
-		logTag:          "registryProvider",
-		logger:          logger,
+		metadataService:         metadataService,
+		platform:                platform,
+		useServerName:           useServerName,
+		fs:                      fs,
+		httpClient:              httpClient,
+		backoff:                 backoff,
+		retryableNotFoundBodies: retryableNotFoundBodies,
+		settingsPathTemplate:    settingsPathTemplate,
+		logTag:                  "registryProvider",
+		logger:                  logger,
 	}
 }
 
@@ -47,7 +60,7 @@ func (p *registryProvider) GetRegistry() (Registry, error) {
 
 	if strings.HasPrefix(registryEndpoint, "http") {
 		p.logger.Debug(p.logTag, "Using http registry at %s", registryEndpoint)
-		return NewHTTPRegistry(p.metadataService, p.platform, p.useServerName), nil
+		return NewHTTPRegistry(p.metadataService, p.platform, p.useServerName, p.httpClient, p.backoff, p.retryableNotFoundBodies, p.settingsPathTemplate), nil
 	}
 
 	p.logger.Debug(p.logTag, "Using file registry at %s", registryEndpoint)
@@ -2,10 +2,14 @@ package infrastructure_test
 
 import (
 	"errors"
+	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	. "github.com/cloudfoundry/bosh-agent/infrastructure"
 	fakeinf "github.com/cloudfoundry/bosh-agent/infrastructure/fakes"
 	fakeplat "github.com/cloudfoundry/bosh-agent/platform/fakes"
@@ -19,6 +23,8 @@ var _ = Describe("RegistryProvider", func() {
 		platform         *fakeplat.FakePlatform
 		useServerName    bool
 		fs               *fakesys.FakeFileSystem
+		httpClient       HTTPClient
+		backoff          boshbackoff.Backoff
 		registryProvider RegistryProvider
 	)
 
@@ -27,11 +33,13 @@ var _ = Describe("RegistryProvider", func() {
 		platform = &fakeplat.FakePlatform{}
 		useServerName = false
 		fs = fakesys.NewFakeFileSystem()
+		httpClient = NewHTTPClient(&http.Client{}, "fake-user-agent")
+		backoff = boshbackoff.New(boshbackoff.DefaultOptions, fakeclock.NewFakeClock(time.Now()))
 	})
 
 	JustBeforeEach(func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		registryProvider = NewRegistryProvider(metadataService, platform, useServerName, fs, logger)
+		registryProvider = NewRegistryProvider(metadataService, platform, useServerName, fs, httpClient, backoff, nil, "", logger)
 	})
 
 	Describe("GetRegistry", func() {
@@ -46,7 +54,7 @@ var _ = Describe("RegistryProvider", func() {
 				It("returns an http registry that does not use server name as id", func() {
 					registry, err := registryProvider.GetRegistry()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(registry).To(Equal(NewHTTPRegistry(metadataService, platform, false)))
+					Expect(registry).To(Equal(NewHTTPRegistry(metadataService, platform, false, httpClient, backoff, nil, "")))
 				})
 			})
 
@@ -56,7 +64,7 @@ var _ = Describe("RegistryProvider", func() {
 				It("returns an http registry that uses server name as id", func() {
 					registry, err := registryProvider.GetRegistry()
 					Expect(err).ToNot(HaveOccurred())
-					Expect(registry).To(Equal(NewHTTPRegistry(metadataService, platform, true)))
+					Expect(registry).To(Equal(NewHTTPRegistry(metadataService, platform, true, httpClient, backoff, nil, "")))
 				})
 			})
 		})
@@ -0,0 +1,109 @@
+package infrastructure
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+
+	boshhttptransport "github.com/cloudfoundry/bosh-agent/httptransport"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ResolvedHostError keeps hostname of the resolved IP that request failed for
+// so that errors name the registry host operators configured
+type ResolvedHostError struct {
+	Host string
+	IP   string
+	Err  error
+}
+
+func (e ResolvedHostError) Error() string {
+	return fmt.Sprintf("Requesting '%s' resolved to '%s': %s", e.Host, e.IP, e.Err.Error())
+}
+
+func (e ResolvedHostError) Unwrap() error {
+	return e.Err
+}
+
+// serverNameTransport sends TLS server name of the original registry host
+// when registry is dialed by its resolved IP; otherwise handshake would
+// present the IP and certificate issued for the hostname would not match
+type serverNameTransport struct {
+	base          *http.Transport
+	serverName    string
+	resolvedHosts *ResolvedHosts
+
+	// Transports are cloned from base per server name to reuse connections
+	lock       *sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// NewServerNameTransport uses serverName for all TLS connections when it is set
+// and hostnames remembered for resolved IPs otherwise
+func NewServerNameTransport(client *http.Client, serverName string, resolvedHosts *ResolvedHosts) (http.RoundTripper, error) {
+	base, err := boshhttptransport.Transport(client.Transport)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building registry TLS server name transport")
+	}
+
+	return serverNameTransport{
+		base:          base,
+		serverName:    serverName,
+		resolvedHosts: resolvedHosts,
+		lock:          &sync.Mutex{},
+		transports:    map[string]*http.Transport{},
+	}, nil
+}
+
+func (t serverNameTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		if hostname, found := t.resolvedHosts.Hostname(req.URL.Hostname()); found {
+			return nil, ResolvedHostError{Host: hostname, IP: req.URL.Hostname(), Err: err}
+		}
+	}
+
+	return resp, err
+}
+
+func (t serverNameTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+
+	return t.transportFor(t.serverNameFor(req.URL.Hostname())).RoundTrip(req)
+}
+
+func (t serverNameTransport) serverNameFor(host string) string {
+	if t.serverName != "" {
+		return t.serverName
+	}
+
+	if hostname, found := t.resolvedHosts.Hostname(host); found {
+		return hostname
+	}
+
+	return ""
+}
+
+func (t serverNameTransport) transportFor(serverName string) *http.Transport {
+	if serverName == "" {
+		return t.base
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if transport, found := t.transports[serverName]; found {
+		return transport
+	}
+
+	transport := boshhttptransport.WithTLSConfig(t.base, func(config *tls.Config) {
+		config.ServerName = serverName
+	})
+
+	t.transports[serverName] = transport
+
+	return transport
+}
@@ -0,0 +1,185 @@
+package infrastructure_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+)
+
+var _ = Describe("ServerNameTransport", func() {
+	var (
+		ts            *httptest.Server
+		client        *http.Client
+		resolvedHosts *ResolvedHosts
+		resolvedURL   string
+	)
+
+	BeforeEach(func() {
+		// Certificate is only valid for hostname, not for IP the server listens on
+		cert, certPool := newHostnameOnlyCertificate("fake-registry.bosh")
+
+		ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("fake-settings"))
+		}))
+		ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		ts.StartTLS()
+
+		resolvedURL = ts.URL
+
+		client = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certPool}},
+		}
+
+		resolvedHosts = NewResolvedHosts()
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	get := func(transport http.RoundTripper) (string, error) {
+		response, err := (&http.Client{Transport: transport}).Get(resolvedURL)
+		if err != nil {
+			return "", err
+		}
+
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		return string(body), err
+	}
+
+	It("verifies certificate against hostname resolved IP belongs to", func() {
+		resolvedHosts.Add("127.0.0.1", "fake-registry.bosh")
+
+		body, err := get(newServerNameTransport(client, "", resolvedHosts))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(Equal("fake-settings"))
+	})
+
+	It("sends hostname as TLS server name", func() {
+		var serverName string
+		ts.TLS.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, nil
+		}
+
+		resolvedHosts.Add("127.0.0.1", "fake-registry.bosh")
+
+		_, err := get(newServerNameTransport(client, "", resolvedHosts))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serverName).To(Equal("fake-registry.bosh"))
+	})
+
+	It("uses configured server name", func() {
+		resolvedHosts.Add("127.0.0.1", "fake-other-registry.bosh")
+
+		body, err := get(newServerNameTransport(client, "fake-registry.bosh", resolvedHosts))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(Equal("fake-settings"))
+	})
+
+	It("fails certificate verification when hostname of IP is unknown", func() {
+		_, err := get(newServerNameTransport(client, "", resolvedHosts))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("127.0.0.1"))
+		Expect(err.Error()).To(ContainSubstring("certificate"))
+	})
+
+	It("fails certificate verification when hostname does not match", func() {
+		resolvedHosts.Add("127.0.0.1", "fake-other-registry.bosh")
+
+		_, err := get(newServerNameTransport(client, "", resolvedHosts))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-other-registry.bosh"))
+	})
+
+	It("sends plain HTTP requests through base transport", func() {
+		plainTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("fake-plain-settings"))
+		}))
+		defer plainTS.Close()
+
+		resolvedURL = plainTS.URL
+
+		body, err := get(newServerNameTransport(client, "fake-registry.bosh", resolvedHosts))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(Equal("fake-plain-settings"))
+		Expect(strings.HasPrefix(resolvedURL, "http://127.0.0.1")).To(BeTrue())
+	})
+
+	It("returns error naming hostname of resolved IP request failed for", func() {
+		resolvedHosts.Add("127.0.0.1", "fake-registry.bosh")
+		ts.Close()
+
+		_, err := get(newServerNameTransport(client, "", resolvedHosts))
+		Expect(err).To(HaveOccurred())
+
+		var resolvedErr ResolvedHostError
+		Expect(errors.As(err, &resolvedErr)).To(BeTrue())
+		Expect(resolvedErr.Host).To(Equal("fake-registry.bosh"))
+		Expect(resolvedErr.IP).To(Equal("127.0.0.1"))
+	})
+
+	It("returns error when base transport TLS settings cannot be changed", func() {
+		client = &http.Client{Transport: fakeRoundTripper{}}
+
+		_, err := NewServerNameTransport(client, "fake-registry.bosh", resolvedHosts)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Configuring TLS of HTTP transport infrastructure_test.fakeRoundTripper is not supported"))
+	})
+})
+
+type fakeRoundTripper struct{}
+
+func (fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func newServerNameTransport(client *http.Client, serverName string, resolvedHosts *ResolvedHosts) http.RoundTripper {
+	transport, err := NewServerNameTransport(client, serverName, resolvedHosts)
+	Expect(err).ToNot(HaveOccurred())
+
+	return transport
+}
+
+func newHostnameOnlyCertificate(hostname string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: hostname},
+		DNSNames:              []string{hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	parsedCert, err := x509.ParseCertificate(certDER)
+	Expect(err).ToNot(HaveOccurred())
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parsedCert)
+
+	return tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: key}, certPool
+}
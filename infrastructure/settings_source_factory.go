@@ -2,9 +2,15 @@ package infrastructure
 
 import (
 	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	mapstruc "github.com/mitchellh/mapstructure"
+	"github.com/pivotal-golang/clock"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	boshplat "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -19,6 +25,38 @@ type SettingsOptions struct {
 	Sources       SourceOptionsSlice
 	UseServerName bool
 	UseRegistry   bool
+
+	// Substrings of registry 404 response body indicating settings
+	// are not available yet; other 404 responses are not retried.
+	// When empty every 404 response is retried.
+	RegistryRetryableNotFoundBodies []string
+
+	// e.g. "/v2/agents/{id}/settings" where {id} is instance id or server name;
+	// defaults to DefaultRegistrySettingsPathTemplate
+	RegistrySettingsPathTemplate string
+
+	// TLS server name expected in registry certificate, e.g. when registry
+	// endpoint is an IP; defaults to hostname the endpoint was resolved from
+	RegistryServerName string
+
+	// User-Agent header sent with metadata and registry requests;
+	// defaults to bosh-agent/<version>
+	UserAgent string
+
+	// Connection reuse for metadata and registry requests
+	KeepAlive KeepAliveOptions
+
+	// Resolved registry hosts are used for this long when DNS lookup fails;
+	// they are not cached when 0
+	DNSCacheTTLSeconds int
+
+	// Cached hosts are persisted to this file to survive agent restarts;
+	// they are only kept in memory when empty
+	DNSCachePath string
+
+	// Instance id written to this file takes precedence over the one
+	// reported by metadata service when building registry settings URL
+	InstanceIDPath string
 }
 
 // SourceOptionsSlice is used for unmarshalling different source types
@@ -34,13 +72,43 @@ type HTTPSourceOptions struct {
 	UserDataPath   string
 	InstanceIDPath string
 	SSHKeysPath    string
+
+	// When greater than zero keys at indices up to SSHKeysMaxIndex are fetched
+	// by replacing the 0 segment of SSHKeysPath, e.g. public-keys/0/openssh-key;
+	// defaults to fetching only the key at SSHKeysPath
+	SSHKeysMaxIndex int
 }
 
 func (o HTTPSourceOptions) sourceOptionsInterface() {}
 
+type DHCPSourceOptions struct {
+	// Lease file globs; defaults to dhclient lease files
+	LeaseFilePaths []string
+
+	// Lease option holding registry endpoint; defaults to unknown-224
+	RegistryEndpointOption string
+
+	// User data is used for all other metadata
+	// and for registry endpoint when the option is absent
+	URI            string
+	Headers        map[string]string
+	UserDataPath   string
+	InstanceIDPath string
+	SSHKeysPath    string
+
+	// See HTTPSourceOptions
+	SSHKeysMaxIndex int
+}
+
+func (o DHCPSourceOptions) sourceOptionsInterface() {}
+
 type ConfigDriveSourceOptions struct {
 	DiskPaths []string
 
+	// Filesystem label of the config drive, e.g. config-2;
+	// matching block devices are tried before DiskPaths
+	Label string
+
 	MetaDataPath string
 	UserDataPath string
 
@@ -49,15 +117,45 @@ type ConfigDriveSourceOptions struct {
 
 func (o ConfigDriveSourceOptions) sourceOptionsInterface() {}
 
+// OpenStack labels ISO 9660 config drives in lower case
+// while VFAT labels are reported in upper case
+func (o ConfigDriveSourceOptions) diskPaths() []string {
+	if o.Label == "" {
+		return o.DiskPaths
+	}
+
+	diskPaths := []string{}
+	seenLabels := map[string]bool{}
+
+	for _, label := range []string{o.Label, strings.ToLower(o.Label), strings.ToUpper(o.Label)} {
+		if !seenLabels[label] {
+			seenLabels[label] = true
+			diskPaths = append(diskPaths, path.Join("/dev/disk/by-label", label))
+		}
+	}
+
+	return append(diskPaths, o.DiskPaths...)
+}
+
 type FileSourceOptions struct {
 	MetaDataPath string
 	UserDataPath string
 
 	SettingsPath string
+
+	// Format of the settings file, either json (default) or yaml
+	SettingsFormat string
 }
 
 func (o FileSourceOptions) sourceOptionsInterface() {}
 
+type EnvSourceOptions struct {
+	// Defaults to DefaultSettingsEnvVariable
+	VariableName string
+}
+
+func (o EnvSourceOptions) sourceOptionsInterface() {}
+
 type CDROMSourceOptions struct {
 	FileName string
 }
@@ -72,21 +170,54 @@ type InstanceMetadataSourceOptions struct {
 
 func (o InstanceMetadataSourceOptions) sourceOptionsInterface() {}
 
+type GCESourceOptions struct {
+	// Defaults to http://metadata.google.internal/computeMetadata/v1
+	URI string
+
+	// Custom instance attribute holding registry endpoint;
+	// defaults to bosh_registry_endpoint
+	RegistryEndpointAttribute string
+}
+
+func (o GCESourceOptions) sourceOptionsInterface() {}
+
+type AzureSourceOptions struct {
+	// Defaults to http://169.254.169.254
+	URI string
+
+	// File written by the provisioning agent; defaults to /var/lib/waagent/CustomData
+	CustomDataPath string
+}
+
+func (o AzureSourceOptions) sourceOptionsInterface() {}
+
 type SettingsSourceFactory struct {
-	options  SettingsOptions
-	platform boshplat.Platform
-	logger   boshlog.Logger
+	options     SettingsOptions
+	platform    boshplat.Platform
+	timeService clock.Clock
+	logger      boshlog.Logger
+
+	// Shared by all sources so that they reuse connections
+	client *http.Client
 }
 
 func NewSettingsSourceFactory(
 	options SettingsOptions,
 	platform boshplat.Platform,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) SettingsSourceFactory {
+	client := DefaultHTTPClient
+	if !options.KeepAlive.IsDefault() {
+		client = NewKeepAliveHTTPClient(options.KeepAlive)
+	}
+
 	return SettingsSourceFactory{
-		options:  options,
-		platform: platform,
-		logger:   logger,
+		options:     options,
+		platform:    platform,
+		timeService: timeService,
+		logger:      logger,
+		client:      client,
 	}
 }
 
@@ -98,11 +229,59 @@ func (f SettingsSourceFactory) New() (boshsettings.Source, error) {
 	return f.buildWithoutRegistry()
 }
 
+// ConnectivityEndpoints returns network endpoints settings are fetched from;
+// file, environment, CDROM and config drive sources have none
+func (f SettingsSourceFactory) ConnectivityEndpoints() []ConnectivityEndpoint {
+	endpoints := []ConnectivityEndpoint{}
+
+	for _, opts := range f.options.Sources {
+		var uri string
+
+		switch typedOpts := opts.(type) {
+		case HTTPSourceOptions:
+			uri = typedOpts.URI
+		case DHCPSourceOptions:
+			uri = typedOpts.URI
+		case InstanceMetadataSourceOptions:
+			uri = typedOpts.URI
+		case GCESourceOptions:
+			uri = typedOpts.URI
+			if uri == "" {
+				uri = DefaultGCEMetadataHost
+			}
+		case AzureSourceOptions:
+			uri = typedOpts.URI
+			if uri == "" {
+				uri = DefaultAzureMetadataHost
+			}
+		}
+
+		if uri != "" {
+			endpoints = append(endpoints, ConnectivityEndpoint{Name: "settings source", Address: uri})
+		}
+	}
+
+	return endpoints
+}
+
 func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error) {
 	var metadataServices []MetadataService
 
-	digDNSResolver := NewDigDNSResolver(f.platform.GetRunner(), f.logger)
-	resolver := NewRegistryEndpointResolver(digDNSResolver)
+	var dnsResolver DNSResolver = NewDigDNSResolver(f.platform.GetRunner(), f.logger)
+
+	if f.options.DNSCacheTTLSeconds > 0 {
+		dnsResolver = NewCachingDNSResolver(
+			dnsResolver,
+			time.Duration(f.options.DNSCacheTTLSeconds)*time.Second,
+			f.options.DNSCachePath,
+			f.platform.GetFs(),
+			f.timeService,
+			f.logger,
+		)
+	}
+
+	resolvedHosts := NewResolvedHosts()
+	resolver := NewRegistryEndpointResolver(dnsResolver, resolvedHosts)
 
 	for _, opts := range f.options.Sources {
 		var metadataService MetadataService
@@ -115,8 +294,32 @@ func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error)
 				typedOpts.UserDataPath,
 				typedOpts.InstanceIDPath,
 				typedOpts.SSHKeysPath,
+				typedOpts.SSHKeysMaxIndex,
+				resolver,
+				f.platform,
+				f.httpClient(),
+				f.logger,
+			)
+
+		case DHCPSourceOptions:
+			userDataMetadataService := NewHTTPMetadataService(
+				typedOpts.URI,
+				typedOpts.Headers,
+				typedOpts.UserDataPath,
+				typedOpts.InstanceIDPath,
+				typedOpts.SSHKeysPath,
+				typedOpts.SSHKeysMaxIndex,
 				resolver,
 				f.platform,
+				f.httpClient(),
+				f.logger,
+			)
+
+			metadataService = NewDHCPMetadataService(
+				typedOpts.LeaseFilePaths,
+				typedOpts.RegistryEndpointOption,
+				userDataMetadataService,
+				f.platform.GetFs(),
 				f.logger,
 			)
 
@@ -124,7 +327,7 @@ func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error)
 			metadataService = NewConfigDriveMetadataService(
 				resolver,
 				f.platform,
-				typedOpts.DiskPaths,
+				typedOpts.diskPaths(),
 				typedOpts.MetaDataPath,
 				typedOpts.UserDataPath,
 				f.logger,
@@ -139,6 +342,28 @@ func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error)
 				f.logger,
 			)
 
+		case GCESourceOptions:
+			metadataService = NewGCEMetadataService(
+				typedOpts.URI,
+				typedOpts.RegistryEndpointAttribute,
+				f.platform,
+				f.httpClient(),
+				f.logger,
+			)
+
+		case AzureSourceOptions:
+			metadataService = NewAzureMetadataService(
+				typedOpts.URI,
+				typedOpts.CustomDataPath,
+				resolver,
+				f.platform,
+				f.httpClient(),
+				f.logger,
+			)
+
+		case EnvSourceOptions:
+			return nil, bosherr.Error("Env source is not supported when registry is used")
+
 		case CDROMSourceOptions:
 			return nil, bosherr.Error("CDROM source is not supported when registry is used")
 
@@ -149,7 +374,17 @@ func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error)
 	}
 
 	metadataService := NewMultiSourceMetadataService(metadataServices...)
-	registryProvider := NewRegistryProvider(metadataService, f.platform, f.options.UseServerName, f.platform.GetFs(), f.logger)
+
+	if f.options.InstanceIDPath != "" {
+		metadataService = NewInstanceIDOverrideMetadataService(metadataService, f.options.InstanceIDPath, f.platform.GetFs(), f.logger)
+	}
+	backoff := boshbackoff.New(boshbackoff.DefaultOptions, f.timeService)
+	registryHTTPClient, err := f.registryHTTPClient(resolvedHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	registryProvider := NewRegistryProvider(metadataService, f.platform, f.options.UseServerName, f.platform.GetFs(), registryHTTPClient, backoff, f.options.RegistryRetryableNotFoundBodies, f.options.RegistrySettingsPathTemplate, f.logger)
 	settingsSource := NewComplexSettingsSource(metadataService, registryProvider, f.logger)
 
 	return settingsSource, nil
@@ -165,9 +400,12 @@ func (f SettingsSourceFactory) buildWithoutRegistry() (boshsettings.Source, erro
 		case HTTPSourceOptions:
 			return nil, bosherr.Error("HTTP source is not supported without registry")
 
+		case DHCPSourceOptions:
+			return nil, bosherr.Error("DHCP source is not supported without registry")
+
 		case ConfigDriveSourceOptions:
 			settingsSource = NewConfigDriveSettingsSource(
-				typedOpts.DiskPaths,
+				typedOpts.diskPaths(),
 				typedOpts.MetaDataPath,
 				typedOpts.SettingsPath,
 				f.platform,
@@ -175,7 +413,26 @@ func (f SettingsSourceFactory) buildWithoutRegistry() (boshsettings.Source, erro
 			)
 
 		case FileSourceOptions:
-			return nil, bosherr.Error("File source is not supported without registry")
+			decoder, err := boshsettings.NewDecoder(typedOpts.SettingsFormat)
+			if err != nil {
+				return nil, bosherr.WrapError(err, "Building file settings decoder")
+			}
+
+			settingsSource = NewFileSettingsSource(
+				typedOpts.SettingsPath,
+				decoder,
+				f.platform.GetFs(),
+				f.logger,
+			)
+
+		case EnvSourceOptions:
+			settingsSource = NewEnvSettingsSource(typedOpts.VariableName, f.logger)
+
+		case GCESourceOptions:
+			return nil, bosherr.Error("GCE source is not supported without registry")
+
+		case AzureSourceOptions:
+			return nil, bosherr.Error("Azure source is not supported without registry")
 
 		case CDROMSourceOptions:
 			settingsSource = NewCDROMSettingsSource(
@@ -190,6 +447,7 @@ func (f SettingsSourceFactory) buildWithoutRegistry() (boshsettings.Source, erro
 				typedOpts.Headers,
 				typedOpts.SettingsPath,
 				f.platform,
+				f.httpClient(),
 				f.logger,
 			)
 		}
@@ -200,6 +458,26 @@ func (f SettingsSourceFactory) buildWithoutRegistry() (boshsettings.Source, erro
 	return NewMultiSettingsSource(settingsSources...)
 }
 
+func (f SettingsSourceFactory) httpClient() HTTPClient {
+	return NewHTTPClient(f.client, f.userAgent())
+}
+
+func (f SettingsSourceFactory) registryHTTPClient(resolvedHosts *ResolvedHosts) (HTTPClient, error) {
+	transport, err := NewServerNameTransport(f.client, f.options.RegistryServerName, resolvedHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHTTPClient(&http.Client{Transport: transport}, f.userAgent()), nil
+}
+
+func (f SettingsSourceFactory) userAgent() string {
+	if f.options.UserAgent == "" {
+		return DefaultUserAgent()
+	}
+	return f.options.UserAgent
+}
+
 func (s *SourceOptionsSlice) UnmarshalJSON(data []byte) error {
 	var maps []map[string]interface{}
 
@@ -218,6 +496,10 @@ func (s *SourceOptionsSlice) UnmarshalJSON(data []byte) error {
 				var o HTTPSourceOptions
 				err, opts = mapstruc.Decode(m, &o), o
 
+			case optType == "DHCP":
+				var o DHCPSourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
 			case optType == "InstanceMetadata":
 				var o InstanceMetadataSourceOptions
 				err, opts = mapstruc.Decode(m, &o), o
@@ -230,10 +512,22 @@ func (s *SourceOptionsSlice) UnmarshalJSON(data []byte) error {
 				var o FileSourceOptions
 				err, opts = mapstruc.Decode(m, &o), o
 
+			case optType == "Env":
+				var o EnvSourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
 			case optType == "CDROM":
 				var o CDROMSourceOptions
 				err, opts = mapstruc.Decode(m, &o), o
 
+			case optType == "GCE":
+				var o GCESourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
+			case optType == "Azure":
+				var o AzureSourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
 			default:
 				err = bosherr.Errorf("Unknown source type '%s'", optType)
 			}
@@ -1,31 +1,47 @@
 package infrastructure_test
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
+	boshbackoff "github.com/cloudfoundry/bosh-agent/backoff"
 	. "github.com/cloudfoundry/bosh-agent/infrastructure"
 	fakeplat "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 var _ = Describe("SettingsSourceFactory", func() {
 	Describe("New", func() {
 		var (
-			options  SettingsOptions
-			platform *fakeplat.FakePlatform
-			logger   boshlog.Logger
-			factory  SettingsSourceFactory
+			options     SettingsOptions
+			platform    *fakeplat.FakePlatform
+			timeService *fakeclock.FakeClock
+			logger      boshlog.Logger
+			factory     SettingsSourceFactory
+
+			httpClient         HTTPClient
+			registryHTTPClient HTTPClient
+			backoff            boshbackoff.Backoff
 		)
 
 		BeforeEach(func() {
+			httpClient = NewHTTPClient(DefaultHTTPClient, DefaultUserAgent())
+			registryHTTPClient = NewHTTPClient(&http.Client{Transport: newServerNameTransport(DefaultHTTPClient, "", NewResolvedHosts())}, DefaultUserAgent())
 			options = SettingsOptions{}
 			platform = fakeplat.NewFakePlatform()
+			timeService = fakeclock.NewFakeClock(time.Now())
 			logger = boshlog.NewLogger(boshlog.LevelNone)
+			backoff = boshbackoff.New(boshbackoff.DefaultOptions, timeService)
 		})
 
 		JustBeforeEach(func() {
-			factory = NewSettingsSourceFactory(options, platform, logger)
+			factory = NewSettingsSourceFactory(options, platform, timeService, logger)
 		})
 
 		Context("when UseRegistry is set to true", func() {
@@ -42,16 +58,55 @@ var _ = Describe("SettingsSourceFactory", func() {
 					})
 
 					It("returns a settings source that uses HTTP to fetch settings", func() {
-						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger))
-						httpMetadataService := NewHTTPMetadataService("http://fake-url", nil, "", "", "", resolver, platform, logger)
+						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
+						httpMetadataService := NewHTTPMetadataService("http://fake-url", nil, "", "", "", 0, resolver, platform, httpClient, logger)
 						multiSourceMetadataService := NewMultiSourceMetadataService(httpMetadataService)
-						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), logger)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
 						httpSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
 
 						settingsSource, err := factory.New()
 						Expect(err).ToNot(HaveOccurred())
 						Expect(settingsSource).To(Equal(httpSettingsSource))
 					})
+
+					Context("when a user agent is configured", func() {
+						BeforeEach(func() {
+							options.UserAgent = "fake-user-agent"
+							httpClient = NewHTTPClient(DefaultHTTPClient, "fake-user-agent")
+							registryHTTPClient = NewHTTPClient(&http.Client{Transport: newServerNameTransport(DefaultHTTPClient, "", NewResolvedHosts())}, "fake-user-agent")
+						})
+
+						It("uses it for metadata and registry requests", func() {
+							resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
+							httpMetadataService := NewHTTPMetadataService("http://fake-url", nil, "", "", "", 0, resolver, platform, httpClient, logger)
+							multiSourceMetadataService := NewMultiSourceMetadataService(httpMetadataService)
+							registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
+							httpSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
+
+							settingsSource, err := factory.New()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(settingsSource).To(Equal(httpSettingsSource))
+						})
+					})
+
+					Context("when a registry server name is configured", func() {
+						BeforeEach(func() {
+							options.RegistryServerName = "fake-registry.bosh"
+							registryHTTPClient = NewHTTPClient(&http.Client{Transport: newServerNameTransport(DefaultHTTPClient, "fake-registry.bosh", NewResolvedHosts())}, DefaultUserAgent())
+						})
+
+						It("uses it for registry requests", func() {
+							resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
+							httpMetadataService := NewHTTPMetadataService("http://fake-url", nil, "", "", "", 0, resolver, platform, httpClient, logger)
+							multiSourceMetadataService := NewMultiSourceMetadataService(httpMetadataService)
+							registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
+							httpSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
+
+							settingsSource, err := factory.New()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(settingsSource).To(Equal(httpSettingsSource))
+						})
+					})
 				})
 
 				Context("when using ConfigDrive source", func() {
@@ -69,7 +124,7 @@ var _ = Describe("SettingsSourceFactory", func() {
 					})
 
 					It("returns a settings source that uses config drive to fetch settings", func() {
-						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger))
+						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
 						configDriveMetadataService := NewConfigDriveMetadataService(
 							resolver,
 							platform,
@@ -79,7 +134,7 @@ var _ = Describe("SettingsSourceFactory", func() {
 							logger,
 						)
 						multiSourceMetadataService := NewMultiSourceMetadataService(configDriveMetadataService)
-						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), logger)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
 						configDriveSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
 
 						settingsSource, err := factory.New()
@@ -109,7 +164,7 @@ var _ = Describe("SettingsSourceFactory", func() {
 							logger,
 						)
 						multiSourceMetadataService := NewMultiSourceMetadataService(fileMetadataService)
-						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), logger)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
 						fileSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
 
 						settingsSource, err := factory.New()
@@ -118,6 +173,70 @@ var _ = Describe("SettingsSourceFactory", func() {
 					})
 				})
 
+				Context("when using GCE source", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{
+							GCESourceOptions{RegistryEndpointAttribute: "fake-attribute"},
+						}
+					})
+
+					It("returns a settings source that uses GCE metadata to fetch settings", func() {
+						gceMetadataService := NewGCEMetadataService("", "fake-attribute", platform, httpClient, logger)
+						multiSourceMetadataService := NewMultiSourceMetadataService(gceMetadataService)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
+						gceSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
+
+						settingsSource, err := factory.New()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(settingsSource).To(Equal(gceSettingsSource))
+					})
+				})
+
+				Context("when using DHCP source", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{
+							DHCPSourceOptions{
+								RegistryEndpointOption: "fake-option",
+								URI:                    "http://fake-url",
+								UserDataPath:           "/fake-user-data-path",
+							},
+						}
+					})
+
+					It("returns a settings source that reads registry endpoint from DHCP lease with user data fallback", func() {
+						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
+						httpMetadataService := NewHTTPMetadataService("http://fake-url", nil, "/fake-user-data-path", "", "", 0, resolver, platform, httpClient, logger)
+						dhcpMetadataService := NewDHCPMetadataService(nil, "fake-option", httpMetadataService, platform.GetFs(), logger)
+						multiSourceMetadataService := NewMultiSourceMetadataService(dhcpMetadataService)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
+						dhcpSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
+
+						settingsSource, err := factory.New()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(settingsSource).To(Equal(dhcpSettingsSource))
+					})
+				})
+
+				Context("when using Azure source", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{
+							AzureSourceOptions{CustomDataPath: "fake-custom-data-path"},
+						}
+					})
+
+					It("returns a settings source that uses Azure custom data to fetch settings", func() {
+						resolver := NewRegistryEndpointResolver(NewDigDNSResolver(platform.GetRunner(), logger), NewResolvedHosts())
+						azureMetadataService := NewAzureMetadataService("", "fake-custom-data-path", resolver, platform, httpClient, logger)
+						multiSourceMetadataService := NewMultiSourceMetadataService(azureMetadataService)
+						registryProvider := NewRegistryProvider(multiSourceMetadataService, platform, useServerName, platform.GetFs(), registryHTTPClient, backoff, nil, "", logger)
+						azureSettingsSource := NewComplexSettingsSource(multiSourceMetadataService, registryProvider, logger)
+
+						settingsSource, err := factory.New()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(settingsSource).To(Equal(azureSettingsSource))
+					})
+				})
+
 				Context("when using CDROM source", func() {
 					BeforeEach(func() {
 						options.Sources = []SourceOptions{
@@ -133,6 +252,18 @@ var _ = Describe("SettingsSourceFactory", func() {
 						Expect(err.Error()).To(ContainSubstring("CDROM source is not supported when registry is used"))
 					})
 				})
+
+				Context("when using Env source", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{EnvSourceOptions{}}
+					})
+
+					It("returns error because it is not supported", func() {
+						_, err := factory.New()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Env source is not supported when registry is used"))
+					})
+				})
 			}
 
 			Context("when UseServerName is set to true", func() {
@@ -194,13 +325,172 @@ var _ = Describe("SettingsSourceFactory", func() {
 					Expect(err).ToNot(HaveOccurred())
 					Expect(settingsSource).To(Equal(multiSettingsSource))
 				})
+
+				Context("when config drive label is configured", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{
+							ConfigDriveSourceOptions{
+								DiskPaths: []string{"/fake-disk-path"},
+								Label:     "config-2",
+
+								MetaDataPath: "fake-meta-data-path",
+
+								SettingsPath: "fake-settings-path",
+							},
+						}
+					})
+
+					It("looks up config drive by label before configured disk paths", func() {
+						configDriveSettingsSource := NewConfigDriveSettingsSource(
+							[]string{"/dev/disk/by-label/config-2", "/dev/disk/by-label/CONFIG-2", "/fake-disk-path"},
+							"fake-meta-data-path",
+							"fake-settings-path",
+							platform,
+							logger,
+						)
+
+						multiSettingsSource, err := NewMultiSettingsSource(configDriveSettingsSource)
+						Expect(err).ToNot(HaveOccurred())
+
+						settingsSource, err := factory.New()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(settingsSource).To(Equal(multiSettingsSource))
+					})
+
+					It("reads settings from config drive found by upper case label", func() {
+						platform.SetGetFilesContentsFromDisk(
+							"/dev/disk/by-label/config-2/fake-settings-path", nil, errors.New("fake-mount-err"))
+						platform.SetGetFilesContentsFromDisk(
+							"/dev/disk/by-label/CONFIG-2/fake-settings-path", []byte(`{"agent_id": "fake-agent-id"}`), nil)
+
+						settingsSource, err := factory.New()
+						Expect(err).ToNot(HaveOccurred())
+
+						settings, err := settingsSource.Settings()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(settings.AgentID).To(Equal("fake-agent-id"))
+						Expect(platform.GetFileContentsFromDiskDiskPaths).To(Equal([]string{
+							"/dev/disk/by-label/config-2",
+							"/dev/disk/by-label/CONFIG-2",
+						}))
+					})
+				})
 			})
 
 			Context("when using File source", func() {
 				BeforeEach(func() {
 					options = SettingsOptions{
 						Sources: []SourceOptions{
-							FileSourceOptions{},
+							FileSourceOptions{
+								SettingsPath: "fake-settings-path",
+							},
+						},
+					}
+				})
+
+				It("returns a settings source that reads settings from the file", func() {
+					fileSettingsSource := NewFileSettingsSource(
+						"fake-settings-path",
+						boshsettings.NewJSONDecoder(),
+						platform.GetFs(),
+						logger,
+					)
+
+					multiSettingsSource, err := NewMultiSettingsSource(fileSettingsSource)
+					Expect(err).ToNot(HaveOccurred())
+
+					settingsSource, err := factory.New()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(settingsSource).To(Equal(multiSettingsSource))
+				})
+
+				It("decodes the settings file with configured format", func() {
+					options.Sources = []SourceOptions{
+						FileSourceOptions{SettingsPath: "fake-settings-path", SettingsFormat: "yaml"},
+					}
+
+					fileSettingsSource := NewFileSettingsSource(
+						"fake-settings-path",
+						boshsettings.NewYAMLDecoder(),
+						platform.GetFs(),
+						logger,
+					)
+
+					multiSettingsSource, err := NewMultiSettingsSource(fileSettingsSource)
+					Expect(err).ToNot(HaveOccurred())
+
+					settingsSource, err := NewSettingsSourceFactory(options, platform, timeService, logger).New()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(settingsSource).To(Equal(multiSettingsSource))
+				})
+
+				It("returns error when settings format is unknown", func() {
+					options.Sources = []SourceOptions{
+						FileSourceOptions{SettingsPath: "fake-settings-path", SettingsFormat: "fake-format"},
+					}
+
+					_, err := NewSettingsSourceFactory(options, platform, timeService, logger).New()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Unknown settings format 'fake-format'"))
+				})
+			})
+
+			Context("when using Env source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							EnvSourceOptions{VariableName: "FAKE_SETTINGS"},
+						},
+					}
+				})
+
+				It("returns a settings source that reads settings from the environment variable", func() {
+					multiSettingsSource, err := NewMultiSettingsSource(NewEnvSettingsSource("FAKE_SETTINGS", logger))
+					Expect(err).ToNot(HaveOccurred())
+
+					settingsSource, err := factory.New()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(settingsSource).To(Equal(multiSettingsSource))
+				})
+			})
+
+			Context("when using GCE source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							GCESourceOptions{},
+						},
+					}
+				})
+
+				It("returns error because it is not supported", func() {
+					_, err := factory.New()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("GCE source is not supported without registry"))
+				})
+			})
+
+			Context("when using DHCP source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							DHCPSourceOptions{},
+						},
+					}
+				})
+
+				It("returns an error because it is not supported", func() {
+					_, err := factory.New()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("DHCP source is not supported without registry"))
+				})
+			})
+
+			Context("when using Azure source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							AzureSourceOptions{},
 						},
 					}
 				})
@@ -208,7 +498,7 @@ var _ = Describe("SettingsSourceFactory", func() {
 				It("returns error because it is not supported", func() {
 					_, err := factory.New()
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("File source is not supported without registry"))
+					Expect(err.Error()).To(ContainSubstring("Azure source is not supported without registry"))
 				})
 			})
 
@@ -240,4 +530,45 @@ var _ = Describe("SettingsSourceFactory", func() {
 			})
 		})
 	})
+
+	Describe("ConnectivityEndpoints", func() {
+		var options SettingsOptions
+
+		BeforeEach(func() {
+			options = SettingsOptions{}
+		})
+
+		endpoints := func() []ConnectivityEndpoint {
+			factory := NewSettingsSourceFactory(options, fakeplat.NewFakePlatform(), fakeclock.NewFakeClock(time.Now()), boshlog.NewLogger(boshlog.LevelNone))
+			return factory.ConnectivityEndpoints()
+		}
+
+		It("returns URIs of sources fetching settings over network", func() {
+			options.Sources = []SourceOptions{
+				HTTPSourceOptions{URI: "http://fake-metadata"},
+				FileSourceOptions{SettingsPath: "/fake-settings-path"},
+				InstanceMetadataSourceOptions{URI: "http://fake-instance-metadata"},
+			}
+
+			Expect(endpoints()).To(Equal([]ConnectivityEndpoint{
+				{Name: "settings source", Address: "http://fake-metadata"},
+				{Name: "settings source", Address: "http://fake-instance-metadata"},
+			}))
+		})
+
+		It("returns default metadata hosts of GCE and Azure sources", func() {
+			options.Sources = []SourceOptions{GCESourceOptions{}, AzureSourceOptions{}}
+
+			Expect(endpoints()).To(Equal([]ConnectivityEndpoint{
+				{Name: "settings source", Address: DefaultGCEMetadataHost},
+				{Name: "settings source", Address: DefaultAzureMetadataHost},
+			}))
+		})
+
+		It("returns no endpoints for local sources", func() {
+			options.Sources = []SourceOptions{CDROMSourceOptions{FileName: "fake-file-name"}, EnvSourceOptions{}}
+
+			Expect(endpoints()).To(BeEmpty())
+		})
+	})
 })
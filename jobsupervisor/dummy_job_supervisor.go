@@ -24,6 +24,14 @@ func (s *dummyJobSupervisor) Stop() error {
 	return nil
 }
 
+func (s *dummyJobSupervisor) StartJobs(jobNames []string) error {
+	return s.Start()
+}
+
+func (s *dummyJobSupervisor) StopJobs(jobNames []string) error {
+	return s.Stop()
+}
+
 func (s *dummyJobSupervisor) Unmonitor() error {
 	return nil
 }
@@ -91,6 +91,14 @@ func (d *dummyNatsJobSupervisor) Stop() error {
 	return nil
 }
 
+func (d *dummyNatsJobSupervisor) StartJobs(jobNames []string) error {
+	return d.Start()
+}
+
+func (d *dummyNatsJobSupervisor) StopJobs(jobNames []string) error {
+	return d.Stop()
+}
+
 func (d *dummyNatsJobSupervisor) Unmonitor() error {
 	return nil
 }
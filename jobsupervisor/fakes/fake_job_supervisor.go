@@ -20,6 +20,12 @@ type FakeJobSupervisor struct {
 	Stopped bool
 	StopErr error
 
+	StartedJobNames []string
+	StartJobsErr    error
+
+	StoppedJobNames []string
+	StopJobsErr     error
+
 	Unmonitored  bool
 	UnmonitorErr error
 
@@ -70,6 +76,16 @@ func (m *FakeJobSupervisor) Stop() error {
 	return m.StopErr
 }
 
+func (m *FakeJobSupervisor) StartJobs(jobNames []string) error {
+	m.StartedJobNames = jobNames
+	return m.StartJobsErr
+}
+
+func (m *FakeJobSupervisor) StopJobs(jobNames []string) error {
+	m.StoppedJobNames = jobNames
+	return m.StopJobsErr
+}
+
 func (m *FakeJobSupervisor) Unmonitor() error {
 	m.Unmonitored = true
 	return m.UnmonitorErr
@@ -10,6 +10,9 @@ type Process struct {
 	Uptime UptimeVitals `json:"uptime,omitempty"`
 	Memory MemoryVitals `json:"mem,omitempty"`
 	CPU    CPUVitals    `json:"cpu,omitempty"`
+
+	// Restarts since last apply; only counted by restart tracking supervisor
+	Restarts int `json:"restarts,omitempty"`
 }
 
 type UptimeVitals struct {
@@ -34,6 +37,10 @@ type JobSupervisor interface {
 	Start() error
 	Stop() error
 
+	// Actions taken on services of given jobs one job at a time in given order
+	StartJobs(jobNames []string) error
+	StopJobs(jobNames []string) error
+
 	// Start and Stop should still function after Unmonitor.
 	// Calling Start after Unmonitor should re-monitor all jobs.
 	// Calling Stop after Unmonitor should not re-monitor all jobs.
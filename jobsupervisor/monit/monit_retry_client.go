@@ -14,6 +14,7 @@ type monitRetryClient struct {
 	maxUnavailableAttempts uint
 	maxOtherAttempts       uint
 	retryDelay             time.Duration
+	timeService            clock.Clock
 	logger                 boshlog.Logger
 }
 
@@ -22,6 +23,7 @@ func NewMonitRetryClient(
 	maxUnavailableAttempts uint,
 	maxOtherAttempts uint,
 	retryDelay time.Duration,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) boshhttp.Client {
 	return &monitRetryClient{
@@ -29,19 +31,19 @@ func NewMonitRetryClient(
 		maxUnavailableAttempts: maxUnavailableAttempts,
 		maxOtherAttempts:       maxOtherAttempts,
 		retryDelay:             retryDelay,
+		timeService:            timeService,
 		logger:                 logger,
 	}
 }
 
 func (r *monitRetryClient) Do(req *http.Request) (*http.Response, error) {
 	requestRetryable := boshhttp.NewRequestRetryable(req, r.delegate, r.logger)
-	timeService := clock.NewClock()
 	retryStrategy := NewMonitRetryStrategy(
 		requestRetryable,
 		r.maxUnavailableAttempts,
 		r.maxOtherAttempts,
 		r.retryDelay,
-		timeService,
+		r.timeService,
 	)
 
 	err := retryStrategy.Try()
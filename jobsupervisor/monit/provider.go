@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshhttp "github.com/cloudfoundry/bosh-utils/http"
@@ -28,7 +30,7 @@ type clientProvider struct {
 	longHTTPClient  boshhttp.Client
 }
 
-func NewProvider(platform boshplatform.Platform, logger boshlog.Logger) ClientProvider {
+func NewProvider(platform boshplatform.Platform, timeService clock.Clock, logger boshlog.Logger) ClientProvider {
 	httpClient := http.DefaultClient
 
 	shortHTTPClient := boshhttp.NewRetryClient(
@@ -43,6 +45,7 @@ func NewProvider(platform boshplatform.Platform, logger boshlog.Logger) ClientPr
 		longRetryStrategyAttempts,
 		shortRetryStrategyAttempts,
 		retryDelay,
+		timeService,
 		logger,
 	)
 
@@ -6,6 +6,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshhttp "github.com/cloudfoundry/bosh-utils/http"
@@ -22,13 +23,15 @@ var _ = Describe("clientProvider", func() {
 		platform.GetMonitCredentialsUsername = "fake-user"
 		platform.GetMonitCredentialsPassword = "fake-pass"
 
-		client, err := NewProvider(platform, logger).Get()
+		timeService := fakeclock.NewFakeClock(time.Now())
+
+		client, err := NewProvider(platform, timeService, logger).Get()
 		Expect(err).ToNot(HaveOccurred())
 
 		httpClient := http.DefaultClient
 
 		shortHTTPClient := boshhttp.NewRetryClient(httpClient, 20, 1*time.Second, logger)
-		longHTTPClient := NewMonitRetryClient(httpClient, 300, 20, 1*time.Second, logger)
+		longHTTPClient := NewMonitRetryClient(httpClient, 300, 20, 1*time.Second, timeService, logger)
 
 		expectedClient := NewHTTPClient(
 			"127.0.0.1:2822",
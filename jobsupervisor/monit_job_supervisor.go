@@ -3,8 +3,11 @@ package jobsupervisor
 import (
 	"fmt"
 	"path"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
 	"github.com/pivotal/go-smtpd/smtpd"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
@@ -17,10 +20,14 @@ import (
 
 const monitJobSupervisorLogTag = "monitJobSupervisor"
 
+// Matches service names declared in job monit files, e.g. 'check process nginx'
+var monitServiceNameRegexp = regexp.MustCompile(`(?m)^\s*check\s+\w+\s+"?([^\s"]+)"?`)
+
 type monitJobSupervisor struct {
 	fs          boshsys.FileSystem
 	runner      boshsys.CmdRunner
 	client      boshmonit.Client
+	clock       clock.Clock
 	logger      boshlog.Logger
 	dirProvider boshdir.Provider
 
@@ -45,6 +52,7 @@ func NewMonitJobSupervisor(
 	fs boshsys.FileSystem,
 	runner boshsys.CmdRunner,
 	client boshmonit.Client,
+	clock clock.Clock,
 	logger boshlog.Logger,
 	dirProvider boshdir.Provider,
 	jobFailuresServerPort int,
@@ -54,6 +62,7 @@ func NewMonitJobSupervisor(
 		fs:          fs,
 		runner:      runner,
 		client:      client,
+		clock:       clock,
 		logger:      logger,
 		dirProvider: dirProvider,
 
@@ -99,7 +108,7 @@ func (m monitJobSupervisor) Reload() error {
 				oldIncarnation, currentIncarnation,
 			)
 
-			time.Sleep(m.reloadOptions.DelayBetweenCheckTries)
+			m.clock.Sleep(m.reloadOptions.DelayBetweenCheckTries)
 		}
 	}
 
@@ -153,6 +162,106 @@ func (m monitJobSupervisor) Stop() error {
 	return nil
 }
 
+func (m monitJobSupervisor) StartJobs(jobNames []string) error {
+	for _, jobName := range jobNames {
+		services, err := m.jobServices(jobName)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Getting services of job %s", jobName)
+		}
+
+		for _, service := range services {
+			m.logger.Debug(monitJobSupervisorLogTag, "Starting service %s of job %s", service, jobName)
+			err = m.client.StartService(service)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Starting service %s", service)
+			}
+		}
+	}
+
+	err := m.fs.RemoveAll(m.stoppedFilePath())
+	if err != nil {
+		return bosherr.WrapError(err, "Removing stopped File")
+	}
+
+	return nil
+}
+
+func (m monitJobSupervisor) StopJobs(jobNames []string) error {
+	for _, jobName := range jobNames {
+		services, err := m.jobServices(jobName)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Getting services of job %s", jobName)
+		}
+
+		for _, service := range services {
+			m.logger.Debug(monitJobSupervisorLogTag, "Stopping service %s of job %s", service, jobName)
+			err = m.client.StopService(service)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Stopping service %s", service)
+			}
+		}
+	}
+
+	err := m.fs.WriteFileString(m.stoppedFilePath(), "")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating stopped File")
+	}
+
+	return nil
+}
+
+// jobServices reads service names from monit files added for the job
+// including additional monit files which are added as <job>_<label>
+// with the same job index. Since other jobs may also be prefixed
+// with <job>_ (e.g. redis and redis_exporter) additional files are
+// matched by job index of the job's monit file; only when job has
+// no monit file they are matched by prefix.
+func (m monitJobSupervisor) jobServices(jobName string) ([]string, error) {
+	configPaths, err := m.fs.Glob(path.Join(m.dirProvider.MonitJobsDir(), "*.monitrc"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing job monit files")
+	}
+
+	// Files are named <4 digit job index>_<job name>.monitrc
+	jobIndexPrefixes := map[string]bool{}
+
+	for _, configPath := range configPaths {
+		fileName := strings.TrimSuffix(path.Base(configPath), ".monitrc")
+		if len(fileName) >= 5 && fileName[5:] == jobName {
+			jobIndexPrefixes[fileName[:5]] = true
+		}
+	}
+
+	services := []string{}
+
+	for _, configPath := range configPaths {
+		fileName := strings.TrimSuffix(path.Base(configPath), ".monitrc")
+		if len(fileName) < 5 {
+			continue
+		}
+
+		configJobName := fileName[5:]
+		if configJobName != jobName && !strings.HasPrefix(configJobName, jobName+"_") {
+			continue
+		}
+
+		if len(jobIndexPrefixes) > 0 && !jobIndexPrefixes[fileName[:5]] {
+			continue
+		}
+
+		config, err := m.fs.ReadFileString(configPath)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Reading job monit file")
+		}
+
+		for _, match := range monitServiceNameRegexp.FindAllStringSubmatch(config, -1) {
+			services = append(services, match[1])
+		}
+	}
+
+	return services, nil
+}
+
 func (m monitJobSupervisor) Unmonitor() error {
 	services, err := m.client.ServicesInGroup("vcap")
 	if err != nil {
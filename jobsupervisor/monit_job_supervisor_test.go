@@ -10,6 +10,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
@@ -50,6 +51,7 @@ var _ = Describe("monitJobSupervisor", func() {
 			fs,
 			runner,
 			client,
+			clock.NewClock(),
 			logger,
 			dirProvider,
 			jobFailuresServerPort,
@@ -192,6 +194,79 @@ var _ = Describe("monitJobSupervisor", func() {
 		})
 	})
 
+	Describe("StartJobs and StopJobs", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("/var/vcap/monit/job/0000_fake-job-1.monitrc", "check process fake-service-1\n  with pidfile /fake-pid")
+			fs.WriteFileString("/var/vcap/monit/job/0001_fake-job-2.monitrc", "check process fake-service-2a\ncheck process fake-service-2b")
+			fs.WriteFileString("/var/vcap/monit/job/0001_fake-job-2_extra.monitrc", "check file fake-service-2c")
+			fs.WriteFileString("/var/vcap/monit/job/0002_fake-job-3.monitrc", "check process fake-service-3")
+			fs.WriteFileString("/var/vcap/monit/job/0003_fake-job-2_exporter.monitrc", "check process fake-service-4")
+			fs.WriteFileString("/var/vcap/monit/job/0004_fake-job-5_extra.monitrc", "check process fake-service-5")
+			fs.SetGlob("/var/vcap/monit/job/*.monitrc", []string{
+				"/var/vcap/monit/job/0000_fake-job-1.monitrc",
+				"/var/vcap/monit/job/0001_fake-job-2.monitrc",
+				"/var/vcap/monit/job/0001_fake-job-2_extra.monitrc",
+				"/var/vcap/monit/job/0002_fake-job-3.monitrc",
+				"/var/vcap/monit/job/0003_fake-job-2_exporter.monitrc",
+				"/var/vcap/monit/job/0004_fake-job-5_extra.monitrc",
+			})
+		})
+
+		It("does not start services of other jobs prefixed with job name", func() {
+			err := monit.StartJobs([]string{"fake-job-2", "fake-job-2_exporter"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(client.StartServiceNames).To(Equal([]string{
+				"fake-service-2a",
+				"fake-service-2b",
+				"fake-service-2c",
+				"fake-service-4",
+			}))
+		})
+
+		It("starts services of additional monit files of job without monit file", func() {
+			err := monit.StartJobs([]string{"fake-job-5"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(client.StartServiceNames).To(Equal([]string{"fake-service-5"}))
+		})
+
+		It("starts services of each job in given order", func() {
+			err := monit.StartJobs([]string{"fake-job-3", "fake-job-1", "fake-job-2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(client.StartServiceNames).To(Equal([]string{
+				"fake-service-3",
+				"fake-service-1",
+				"fake-service-2a",
+				"fake-service-2b",
+				"fake-service-2c",
+			}))
+		})
+
+		It("deletes stopped file", func() {
+			fs.WriteFileString("/var/vcap/monit/stopped", "")
+
+			err := monit.StartJobs([]string{"fake-job-1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("/var/vcap/monit/stopped")).To(BeFalse())
+		})
+
+		It("stops services of each job in given order", func() {
+			err := monit.StopJobs([]string{"fake-job-3", "fake-job-2", "fake-job-1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(client.StopServiceNames).To(Equal([]string{
+				"fake-service-3",
+				"fake-service-2a",
+				"fake-service-2b",
+				"fake-service-2c",
+				"fake-service-1",
+			}))
+			Expect(fs.FileExists("/var/vcap/monit/stopped")).To(BeTrue())
+		})
+	})
+
 	Describe("Status", func() {
 		It("status returns running when all services are monitored and running", func() {
 			client.StatusStatus = fakemonit.FakeMonitStatus{
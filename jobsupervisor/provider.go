@@ -5,6 +5,8 @@ package jobsupervisor
 import (
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
@@ -25,6 +27,7 @@ func NewProvider(
 	logger boshlog.Logger,
 	dirProvider boshdir.Provider,
 	handler boshhandler.Handler,
+	timeService clock.Clock,
 ) (p Provider) {
 	fs := platform.GetFs()
 	runner := platform.GetRunner()
@@ -32,6 +35,7 @@ func NewProvider(
 		fs,
 		runner,
 		client,
+		timeService,
 		logger,
 		dirProvider,
 		jobSupervisorListenPort,
@@ -5,6 +5,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakemonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit/fakes"
@@ -23,6 +24,7 @@ func init() {
 			dirProvider           boshdir.Provider
 			jobFailuresServerPort int
 			handler               *fakembus.FakeHandler
+			timeService           *fakeclock.FakeClock
 			provider              Provider
 		)
 
@@ -33,6 +35,7 @@ func init() {
 			dirProvider = boshdir.NewProvider("/fake-base-dir")
 			jobFailuresServerPort = 2825
 			handler = &fakembus.FakeHandler{}
+			timeService = fakeclock.NewFakeClock(time.Now())
 
 			provider = NewProvider(
 				platform,
@@ -40,6 +43,7 @@ func init() {
 				logger,
 				dirProvider,
 				handler,
+				timeService,
 			)
 		})
 
@@ -51,6 +55,7 @@ func init() {
 				platform.Fs,
 				platform.Runner,
 				client,
+				timeService,
 				logger,
 				dirProvider,
 				jobFailuresServerPort,
@@ -0,0 +1,77 @@
+package jobsupervisor
+
+import (
+	"sync"
+)
+
+type processHistory struct {
+	running bool
+	uptime  int
+
+	// Processes coming up for the first time are not restarts
+	started bool
+
+	restarts int
+}
+
+// restartTrackingJobSupervisor counts restarts of each process
+// based on successive statuses to help with debugging flapping jobs
+type restartTrackingJobSupervisor struct {
+	JobSupervisor
+
+	histories     map[string]processHistory
+	historiesLock sync.Mutex
+}
+
+func NewRestartTrackingJobSupervisor(jobSupervisor JobSupervisor) JobSupervisor {
+	return &restartTrackingJobSupervisor{
+		JobSupervisor: jobSupervisor,
+		histories:     map[string]processHistory{},
+	}
+}
+
+// Processes reports process as restarted when it is running again
+// after it went down or when its uptime went backwards
+func (s *restartTrackingJobSupervisor) Processes() ([]Process, error) {
+	processes, err := s.JobSupervisor.Processes()
+	if err != nil {
+		return processes, err
+	}
+
+	s.historiesLock.Lock()
+	defer s.historiesLock.Unlock()
+
+	for i, process := range processes {
+		history := s.histories[process.Name]
+		running := process.State == "running"
+
+		if running && history.started && (!history.running || process.Uptime.Secs < history.uptime) {
+			history.restarts++
+		}
+
+		history.running = running
+		history.uptime = process.Uptime.Secs
+		history.started = history.started || running
+
+		s.histories[process.Name] = history
+		processes[i].Restarts = history.restarts
+	}
+
+	return processes, nil
+}
+
+// RemoveAllJobs is called on every apply so that
+// newly applied jobs start with no restarts
+func (s *restartTrackingJobSupervisor) RemoveAllJobs() error {
+	err := s.JobSupervisor.RemoveAllJobs()
+	if err != nil {
+		return err
+	}
+
+	s.historiesLock.Lock()
+	defer s.historiesLock.Unlock()
+
+	s.histories = map[string]processHistory{}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package jobsupervisor_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor"
+	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
+)
+
+var _ = Describe("restartTrackingJobSupervisor", func() {
+	var (
+		delegate      *fakejobsuper.FakeJobSupervisor
+		jobSupervisor JobSupervisor
+	)
+
+	BeforeEach(func() {
+		delegate = fakejobsuper.NewFakeJobSupervisor()
+		jobSupervisor = NewRestartTrackingJobSupervisor(delegate)
+	})
+
+	process := func(name, state string, uptime int) Process {
+		return Process{Name: name, State: state, Uptime: UptimeVitals{Secs: uptime}}
+	}
+
+	restartsAfter := func(statuses ...[]Process) map[string]int {
+		restarts := map[string]int{}
+
+		for _, status := range statuses {
+			delegate.ProcessesStatus = status
+
+			processes, err := jobSupervisor.Processes()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, p := range processes {
+				restarts[p.Name] = p.Restarts
+			}
+		}
+
+		return restarts
+	}
+
+	Describe("Processes", func() {
+		It("reports no restarts for processes that keep running", func() {
+			restarts := restartsAfter(
+				[]Process{process("fake-process", "running", 10)},
+				[]Process{process("fake-process", "running", 40)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process": 0}))
+		})
+
+		It("does not count first start of process as restart", func() {
+			restarts := restartsAfter(
+				[]Process{process("fake-process", "initializing", 0)},
+				[]Process{process("fake-process", "running", 5)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process": 0}))
+		})
+
+		It("counts process running again after it failed as restart", func() {
+			restarts := restartsAfter(
+				[]Process{process("fake-process", "running", 10)},
+				[]Process{process("fake-process", "failing", 0)},
+				[]Process{process("fake-process", "running", 3)},
+				[]Process{process("fake-process", "failing", 0)},
+				[]Process{process("fake-process", "running", 2)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process": 2}))
+		})
+
+		It("counts uptime going backwards between statuses as restart", func() {
+			restarts := restartsAfter(
+				[]Process{process("fake-process-1", "running", 100), process("fake-process-2", "running", 100)},
+				[]Process{process("fake-process-1", "running", 5), process("fake-process-2", "running", 130)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process-1": 1, "fake-process-2": 0}))
+		})
+
+		It("returns error from job supervisor", func() {
+			delegate.ProcessesError = errors.New("fake-processes-err")
+
+			_, err := jobSupervisor.Processes()
+			Expect(err).To(Equal(delegate.ProcessesError))
+		})
+	})
+
+	Describe("RemoveAllJobs", func() {
+		It("resets restart counts so that newly applied jobs start with none", func() {
+			restartsAfter(
+				[]Process{process("fake-process", "running", 100)},
+				[]Process{process("fake-process", "running", 5)},
+			)
+
+			Expect(jobSupervisor.RemoveAllJobs()).To(Succeed())
+			Expect(delegate.RemovedAllJobs).To(BeTrue())
+
+			restarts := restartsAfter(
+				[]Process{process("fake-process", "running", 1)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process": 0}))
+		})
+
+		It("keeps restart counts when removing jobs fails", func() {
+			restartsAfter(
+				[]Process{process("fake-process", "running", 100)},
+				[]Process{process("fake-process", "running", 5)},
+			)
+
+			delegate.RemovedAllJobsErr = errors.New("fake-remove-err")
+			Expect(jobSupervisor.RemoveAllJobs()).To(Equal(delegate.RemovedAllJobsErr))
+
+			restarts := restartsAfter(
+				[]Process{process("fake-process", "running", 6)},
+			)
+			Expect(restarts).To(Equal(map[string]int{"fake-process": 1}))
+		})
+	})
+})
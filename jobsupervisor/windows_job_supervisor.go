@@ -15,6 +15,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/pivotal-golang/clock"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/http_server"
 	"golang.org/x/sys/windows/svc"
@@ -145,6 +146,7 @@ type windowsJobSupervisor struct {
 	cmdRunner             boshsys.CmdRunner
 	dirProvider           boshdirs.Provider
 	fs                    boshsys.FileSystem
+	clock                 clock.Clock
 	logger                boshlog.Logger
 	logTag                string
 	msgCh                 chan *windowsServiceEvent
@@ -168,6 +170,7 @@ func NewWindowsJobSupervisor(
 	cmdRunner boshsys.CmdRunner,
 	dirProvider boshdirs.Provider,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 	logger boshlog.Logger,
 	jobFailuresServerPort int,
 	cancelChan chan bool,
@@ -176,6 +179,7 @@ func NewWindowsJobSupervisor(
 		cmdRunner:   cmdRunner,
 		dirProvider: dirProvider,
 		fs:          fs,
+		clock:       clock,
 		logger:      logger,
 		logTag:      "windowsJobSupervisor",
 		msgCh:       make(chan *windowsServiceEvent, 8),
@@ -231,6 +235,15 @@ func (w *windowsJobSupervisor) Stop() error {
 	return nil
 }
 
+// Windows services of all jobs are managed together so order is not honored
+func (w *windowsJobSupervisor) StartJobs(jobNames []string) error {
+	return w.Start()
+}
+
+func (w *windowsJobSupervisor) StopJobs(jobNames []string) error {
+	return w.Stop()
+}
+
 func (w *windowsJobSupervisor) Unmonitor() error {
 	s.stateSet(stateDisabled)
 	_, _, _, err := s.cmdRunner.RunCommand("powershell", "-noprofile", "-noninteractive", "/C", unmonitorJobScript)
@@ -407,7 +420,7 @@ func (w *windowsJobSupervisor) RemoveAllJobs() error {
 	}
 
 	i := 0
-	start := time.Now()
+	start := s.clock.Now()
 	for {
 		stdout, _, _, err := s.cmdRunner.RunCommand(
 			"powershell",
@@ -429,13 +442,13 @@ func (w *windowsJobSupervisor) RemoveAllJobs() error {
 				MaxRetries)
 		}
 		s.logger.Debug(s.logTag, "Waiting for services to be deleted: attempt (%d) time (%s)",
-			i, time.Since(start))
+			i, s.clock.Since(start))
 
-		time.Sleep(RetryInterval)
+		s.clock.Sleep(RetryInterval)
 	}
 
 	s.logger.Debug(s.logTag, "Removed Windows job supervisor services: attempts (%d) time (%s)",
-		i, time.Since(start))
+		i, s.clock.Since(start))
 
 	return nil
 }
@@ -460,7 +473,7 @@ func (w *windowsJobSupervisor) MonitorJobFailures(handler JobFailureHandler) err
 		}
 		handler(boshalert.MonitAlert{
 			Action:      "Start",
-			Date:        time.Now().Format(time.RFC1123Z),
+			Date:        s.clock.Now().Format(time.RFC1123Z),
 			Event:       event.Event,
 			ID:          event.ProcessName,
 			Service:     event.ProcessName,
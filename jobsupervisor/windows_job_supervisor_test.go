@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/pivotal-golang/clock"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/mgr"
 
@@ -112,7 +113,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 		WriteJobConfig := func(configContents WindowsProcessConfig) (string, error) {
 			dirProvider := boshdirs.NewProvider(basePath)
 			runner = boshsys.NewExecCmdRunner(logger)
-			jobSupervisor = NewWindowsJobSupervisor(runner, dirProvider, fs, logger, jobFailuresServerPort, make(chan bool))
+			jobSupervisor = NewWindowsJobSupervisor(runner, dirProvider, fs, clock.NewClock(), logger, jobFailuresServerPort, make(chan bool))
 			if err := jobSupervisor.RemoveAllJobs(); err != nil {
 				return "", err
 			}
@@ -428,7 +429,7 @@ var _ = Describe("WindowsJobSupervisor", func() {
 				dirProvider := boshdirs.NewProvider(basePath)
 				runner = boshsys.NewExecCmdRunner(logger)
 				cancelServer = make(chan bool)
-				jobSupervisor = NewWindowsJobSupervisor(runner, dirProvider, fs, logger, jobFailuresServerPort, cancelServer)
+				jobSupervisor = NewWindowsJobSupervisor(runner, dirProvider, fs, clock.NewClock(), logger, jobFailuresServerPort, cancelServer)
 			})
 
 			AfterEach(func() {
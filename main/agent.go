@@ -16,7 +16,8 @@ import (
 const mainLogTag = "main"
 
 func main() {
-	logger := newSignalableLogger(boshlog.NewLogger(boshlog.LevelDebug))
+	// Destination and level are switched once agent config is loaded
+	logger := newSignalableLogger(agentlogger.NewSinkLogger(boshlog.LevelDebug, os.Stdout, os.Stderr))
 
 	defer logger.HandlePanic("Main")
 
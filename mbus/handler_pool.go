@@ -0,0 +1,117 @@
+package mbus
+
+import (
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	// DefaultMaxConcurrentHandlers limits messages handled at the same time
+	DefaultMaxConcurrentHandlers = 10
+
+	// DefaultMaxQueuedMessages limits messages waiting for a free handler
+	DefaultMaxQueuedMessages = 100
+)
+
+// HandlerPool bounds number of messages handled concurrently;
+// messages beyond that limit wait in a bounded queue.
+type HandlerPool struct {
+	size       int
+	maxQueued  int
+	isPriority func(method string) bool
+
+	lock           *sync.Mutex
+	running        int
+	queued         []chan struct{}
+	priorityQueued []chan struct{}
+}
+
+// NewHandlerPool handles queued messages whose method satisfies isPriority
+// before other queued messages, e.g. so that API consumers can still check
+// on busy agent and its tasks; all messages are queued in order when nil
+func NewHandlerPool(size, maxQueued int, isPriority func(method string) bool) *HandlerPool {
+	if size <= 0 {
+		size = DefaultMaxConcurrentHandlers
+	}
+
+	if maxQueued <= 0 {
+		maxQueued = DefaultMaxQueuedMessages
+	}
+
+	return &HandlerPool{
+		size:       size,
+		maxQueued:  maxQueued,
+		isPriority: isPriority,
+		lock:       &sync.Mutex{},
+	}
+}
+
+// Run blocks until handler is free to run handleFunc
+// or returns error right away when queue is full
+func (p *HandlerPool) Run(method string, handleFunc func()) error {
+	err := p.acquire(method)
+	if err != nil {
+		return err
+	}
+
+	defer p.release()
+
+	handleFunc()
+
+	return nil
+}
+
+// Queued returns number of messages waiting for a free handler
+func (p *HandlerPool) Queued() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.queued) + len(p.priorityQueued)
+}
+
+func (p *HandlerPool) acquire(method string) error {
+	p.lock.Lock()
+
+	if p.running < p.size {
+		p.running++
+		p.lock.Unlock()
+		return nil
+	}
+
+	if len(p.queued)+len(p.priorityQueued) >= p.maxQueued {
+		p.lock.Unlock()
+		return bosherr.Errorf("Too many queued messages (limit %d), rejecting %s", p.maxQueued, method)
+	}
+
+	ready := make(chan struct{})
+
+	if p.isPriority != nil && p.isPriority(method) {
+		p.priorityQueued = append(p.priorityQueued, ready)
+	} else {
+		p.queued = append(p.queued, ready)
+	}
+
+	p.lock.Unlock()
+
+	<-ready
+
+	return nil
+}
+
+// release hands handler over to next queued message, if any
+func (p *HandlerPool) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch {
+	case len(p.priorityQueued) > 0:
+		close(p.priorityQueued[0])
+		p.priorityQueued = p.priorityQueued[1:]
+	case len(p.queued) > 0:
+		close(p.queued[0])
+		p.queued = p.queued[1:]
+	default:
+		p.running--
+	}
+}
@@ -0,0 +1,189 @@
+package mbus_test
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+)
+
+var _ = Describe("HandlerPool", func() {
+	var (
+		pool *HandlerPool
+
+		handledLock *sync.Mutex
+		handled     []string
+
+		running *sync.WaitGroup
+	)
+
+	BeforeEach(func() {
+		handledLock = &sync.Mutex{}
+		handled = nil
+		running = &sync.WaitGroup{}
+	})
+
+	AfterEach(func() {
+		running.Wait()
+	})
+
+	handle := func(method string) func() {
+		return func() {
+			handledLock.Lock()
+			handled = append(handled, method)
+			handledLock.Unlock()
+		}
+	}
+
+	handledMethods := func() []string {
+		handledLock.Lock()
+		defer handledLock.Unlock()
+		return append([]string{}, handled...)
+	}
+
+	// runBlocking occupies a handler until returned func is called
+	runBlocking := func(method string) func() {
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+
+		running.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer running.Done()
+
+			err := pool.Run(method, func() {
+				close(started)
+				<-unblock
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}()
+
+		Eventually(started).Should(BeClosed())
+
+		return func() { close(unblock) }
+	}
+
+	runAsync := func(method string) {
+		running.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer running.Done()
+
+			err := pool.Run(method, handle(method))
+			Expect(err).ToNot(HaveOccurred())
+		}()
+	}
+
+	isReadOnly := func(method string) bool {
+		return method == "get_task"
+	}
+
+	It("handles messages right away while handlers are free", func() {
+		pool = NewHandlerPool(2, 1, isReadOnly)
+
+		unblock := runBlocking("apply")
+		defer unblock()
+
+		err := pool.Run("stop", handle("stop"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(handledMethods()).To(Equal([]string{"stop"}))
+		Expect(pool.Queued()).To(Equal(0))
+	})
+
+	It("queues messages until handler is free", func() {
+		pool = NewHandlerPool(1, 1, isReadOnly)
+
+		unblock := runBlocking("apply")
+
+		runAsync("stop")
+
+		Eventually(pool.Queued).Should(Equal(1))
+		Consistently(handledMethods).Should(BeEmpty())
+
+		unblock()
+
+		Eventually(handledMethods).Should(Equal([]string{"stop"}))
+		Expect(pool.Queued()).To(Equal(0))
+	})
+
+	It("rejects messages when queue is full", func() {
+		pool = NewHandlerPool(1, 1, isReadOnly)
+
+		unblock := runBlocking("apply")
+
+		runAsync("stop")
+		Eventually(pool.Queued).Should(Equal(1))
+
+		err := pool.Run("start", handle("start"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Too many queued messages (limit 1), rejecting start"))
+
+		Expect(handledMethods()).To(BeEmpty())
+		Expect(pool.Queued()).To(Equal(1))
+
+		unblock()
+
+		Eventually(handledMethods).Should(Equal([]string{"stop"}))
+	})
+
+	It("handles queued priority messages first", func() {
+		pool = NewHandlerPool(1, 3, isReadOnly)
+
+		unblock := runBlocking("apply")
+
+		runAsync("stop")
+		Eventually(pool.Queued).Should(Equal(1))
+
+		runAsync("start")
+		Eventually(pool.Queued).Should(Equal(2))
+
+		runAsync("get_task")
+		Eventually(pool.Queued).Should(Equal(3))
+
+		unblock()
+
+		Eventually(handledMethods).Should(Equal([]string{"get_task", "stop", "start"}))
+	})
+
+	It("handles queued messages in order without priority predicate", func() {
+		pool = NewHandlerPool(1, 2, nil)
+
+		unblock := runBlocking("apply")
+
+		runAsync("stop")
+		Eventually(pool.Queued).Should(Equal(1))
+
+		runAsync("get_task")
+		Eventually(pool.Queued).Should(Equal(2))
+
+		unblock()
+
+		Eventually(handledMethods).Should(Equal([]string{"stop", "get_task"}))
+	})
+
+	It("uses default limits when not configured", func() {
+		pool = NewHandlerPool(0, 0, isReadOnly)
+
+		var unblocks []func()
+		for i := 0; i < DefaultMaxConcurrentHandlers; i++ {
+			unblocks = append(unblocks, runBlocking("apply"))
+		}
+
+		for i := 0; i < DefaultMaxQueuedMessages; i++ {
+			runAsync("stop")
+		}
+		Eventually(pool.Queued).Should(Equal(DefaultMaxQueuedMessages))
+
+		err := pool.Run("start", handle("start"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Too many queued messages"))
+
+		for _, unblock := range unblocks {
+			unblock()
+		}
+
+		Eventually(handledMethods).Should(HaveLen(DefaultMaxQueuedMessages))
+	})
+})
@@ -14,17 +14,38 @@ import (
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+type Options struct {
+	// Incoming messages larger than this are rejected;
+	// defaults to DefaultMaxMessageSize
+	MaxMessageSize int
+
+	// Messages handled at the same time;
+	// defaults to DefaultMaxConcurrentHandlers
+	MaxConcurrentHandlers int
+
+	// Messages waiting for a free handler beyond this are rejected;
+	// defaults to DefaultMaxQueuedMessages
+	MaxQueuedMessages int
+}
+
 type HandlerProvider struct {
 	settingsService boshsettings.Service
+	options         Options
+	isPriority      func(method string) bool
 	logger          boshlog.Logger
 	handler         boshhandler.Handler
 }
 
+// NewHandlerProvider passes isPriority to handler pool of NATS handler
 func NewHandlerProvider(
 	settingsService boshsettings.Service,
+	options Options,
+	isPriority func(method string) bool,
 	logger boshlog.Logger,
 ) (p HandlerProvider) {
 	p.settingsService = settingsService
+	p.options = options
+	p.isPriority = isPriority
 	p.logger = logger
 	return
 }
@@ -46,7 +67,8 @@ func (p HandlerProvider) Get(
 
 	switch mbusURL.Scheme {
 	case "nats":
-		handler = NewNatsHandler(p.settingsService, yagnats.NewClient(), p.logger, platform)
+		handlerPool := NewHandlerPool(p.options.MaxConcurrentHandlers, p.options.MaxQueuedMessages, p.isPriority)
+		handler = NewNatsHandler(p.settingsService, yagnats.NewClient(), p.options.MaxMessageSize, handlerPool, p.logger, platform)
 	case "https":
 		handler = boshmicro.NewHTTPSHandler(mbusURL, p.logger, platform.GetFs(), dirProvider)
 	default:
@@ -57,3 +79,10 @@ func (p HandlerProvider) Get(
 
 	return
 }
+
+// UniqueReplyTo tells whether each message carries its own reply-to;
+// HTTPS clients send director id as reply-to of every request
+func (p HandlerProvider) UniqueReplyTo() bool {
+	mbusURL, err := url.Parse(p.settingsService.GetSettings().Mbus)
+	return err == nil && mbusURL.Scheme == "nats"
+}
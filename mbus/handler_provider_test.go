@@ -30,7 +30,7 @@ var _ = Describe("HandlerProvider", func() {
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 		platform = fakeplatform.NewFakePlatform()
 		dirProvider = boshdir.NewProvider("/var/vcap")
-		provider = NewHandlerProvider(settingsService, logger)
+		provider = NewHandlerProvider(settingsService, Options{}, nil, logger)
 	})
 
 	Describe("Get", func() {
@@ -40,7 +40,7 @@ var _ = Describe("HandlerProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			// yagnats.NewClient returns new object every time
-			expectedHandler := NewNatsHandler(settingsService, yagnats.NewClient(), logger, platform)
+			expectedHandler := NewNatsHandler(settingsService, yagnats.NewClient(), 0, NewHandlerPool(0, 0, nil), logger, platform)
 			Expect(reflect.TypeOf(handler)).To(Equal(reflect.TypeOf(expectedHandler)))
 		})
 
@@ -60,4 +60,16 @@ var _ = Describe("HandlerProvider", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("UniqueReplyTo", func() {
+		It("returns true for nats", func() {
+			settingsService.Settings.Mbus = "nats://lol"
+			Expect(provider.UniqueReplyTo()).To(BeTrue())
+		})
+
+		It("returns false for https since director id is sent as reply-to", func() {
+			settingsService.Settings.Mbus = "https://lol"
+			Expect(provider.UniqueReplyTo()).To(BeFalse())
+		})
+	})
 })
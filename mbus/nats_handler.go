@@ -1,16 +1,17 @@
 package mbus
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
-	"net/url"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/yagnats"
 
@@ -23,6 +24,12 @@ import (
 
 const (
 	responseMaxLength = 1024 * 1024
+
+	// Matches timeout used by yagnats for plain connections
+	natsDialTimeout = 5 * time.Second
+
+	// DefaultMaxMessageSize matches default NATS server max_payload
+	DefaultMaxMessageSize = 1024 * 1024
 )
 
 type Handler interface {
@@ -37,6 +44,8 @@ type natsHandler struct {
 	settingsService boshsettings.Service
 	client          yagnats.NATSClient
 	platform        boshplatform.Platform
+	maxMessageSize  int
+	handlerPool     *HandlerPool
 
 	handlerFuncs     []boshhandler.Func
 	handlerFuncsLock sync.Mutex
@@ -48,13 +57,21 @@ type natsHandler struct {
 func NewNatsHandler(
 	settingsService boshsettings.Service,
 	client yagnats.NATSClient,
+	maxMessageSize int,
+	handlerPool *HandlerPool,
 	logger boshlog.Logger,
 	platform boshplatform.Platform,
 ) Handler {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+
 	return &natsHandler{
 		settingsService: settingsService,
 		client:          client,
 		platform:        platform,
+		maxMessageSize:  maxMessageSize,
+		handlerPool:     handlerPool,
 
 		logger: logger,
 		logTag: "NATS Handler",
@@ -77,22 +94,26 @@ func (h *natsHandler) Run(handlerFunc boshhandler.Func) error {
 func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 	h.RegisterAdditionalFunc(handlerFunc)
 
-	connProvider, err := h.getConnectionInfo()
+	mbusURL, err := ParseMbusURL(h.settingsService.GetSettings().Mbus)
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing Nats URL")
+	}
+
+	connProvider, err := h.getConnectionProvider(mbusURL)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting connection info")
 	}
 
 	h.client.BeforeConnectCallback(func() {
-		hostSplit := strings.Split(connProvider.Addr, ":")
-		ip := hostSplit[0]
-
-		if net.ParseIP(ip) == nil {
-			return
-		}
-
-		err = h.platform.DeleteARPEntryWithIP(ip)
-		if err != nil {
-			h.logger.Error(h.logTag, "Cleaning ip-mac address cache for: %s", ip)
+		for _, ip := range mbusURL.Hostnames() {
+			if net.ParseIP(ip) == nil {
+				continue
+			}
+
+			err = h.platform.DeleteARPEntryWithIP(ip)
+			if err != nil {
+				h.logger.Error(h.logTag, "Cleaning ip-mac address cache for: %s", ip)
+			}
 		}
 	})
 
@@ -113,9 +134,11 @@ func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 		handlerFuncs := h.handlerFuncs
 		h.handlerFuncsLock.Unlock()
 
-		for _, handlerFunc := range handlerFuncs {
-			h.handleNatsMsg(natsMsg, handlerFunc)
-		}
+		h.queueNatsMsg(natsMsg, func() {
+			for _, handlerFunc := range handlerFuncs {
+				h.handleNatsMsg(natsMsg, handlerFunc)
+			}
+		})
 	})
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Subscribing to %s", subject)
@@ -151,6 +174,56 @@ func (h *natsHandler) Stop() {
 	h.client.Disconnect()
 }
 
+// queueNatsMsg waits for a free handler; when message is too large
+// or queue is full sender is told right away instead of waiting for a timeout
+func (h *natsHandler) queueNatsMsg(natsMsg *yagnats.Message, handleFunc func()) {
+	if len(natsMsg.Payload) > h.maxMessageSize {
+		h.rejectNatsMsg(oversizedMsgReplyTo(natsMsg.Payload), bosherr.Errorf("Rejecting message of %d bytes: exceeds maximum message size of %d bytes", len(natsMsg.Payload), h.maxMessageSize))
+		return
+	}
+
+	var req boshhandler.Request
+
+	// Messages that cannot be parsed are rejected by handler
+	_ = json.Unmarshal(natsMsg.Payload, &req)
+
+	err := h.handlerPool.Run(req.Method, handleFunc)
+	if err != nil {
+		h.rejectNatsMsg(req.ReplyTo, err)
+	}
+}
+
+// oversizedMsgReplyTo only picks reply_to so that arguments
+// of oversized message are never decoded
+func oversizedMsgReplyTo(payload []byte) string {
+	var msg struct {
+		ReplyTo string `json:"reply_to"`
+	}
+
+	_ = json.Unmarshal(payload, &msg)
+
+	return msg.ReplyTo
+}
+
+func (h *natsHandler) rejectNatsMsg(replyTo string, err error) {
+	h.logger.Error(h.logTag, err.Error())
+
+	if replyTo == "" {
+		return
+	}
+
+	respBytes, err := boshhandler.BuildErrorWithJSON(err.Error(), h.logger)
+	if err != nil {
+		h.logger.Error(h.logTag, "Building rejection response: %s", err.Error())
+		return
+	}
+
+	err = h.client.Publish(replyTo, respBytes)
+	if err != nil {
+		h.logger.Error(h.logTag, "Publishing to the client: %s", err.Error())
+	}
+}
+
 func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshhandler.Func) {
 	respBytes, req, err := boshhandler.PerformHandlerWithJSON(
 		natsMsg.Payload,
@@ -187,26 +260,115 @@ func (h *natsHandler) runUntilInterrupted() {
 	}
 }
 
-func (h *natsHandler) getConnectionInfo() (*yagnats.ConnectionInfo, error) {
-	settings := h.settingsService.GetSettings()
+// getConnectionProvider returns a cluster trying hosts in order
+// when mbus URL lists more than one host
+func (h *natsHandler) getConnectionProvider(mbusURL MbusURL) (yagnats.ConnectionProvider, error) {
+	if mbusURL.Username != "" && !mbusURL.PasswordSet {
+		return nil, errors.New("No password set for connection")
+	}
 
-	natsURL, err := url.Parse(settings.Mbus)
-	if err != nil {
-		return nil, bosherr.WrapError(err, "Parsing Nats URL")
+	hostnames := mbusURL.Hostnames()
+
+	if len(mbusURL.Hosts) == 1 {
+		return h.getConnectionInfo(mbusURL, mbusURL.Hosts[0], hostnames[0])
 	}
 
+	cluster := &yagnats.ConnectionCluster{}
+
+	for i, host := range mbusURL.Hosts {
+		connInfo, err := h.getConnectionInfo(mbusURL, host, hostnames[i])
+		if err != nil {
+			return nil, err
+		}
+		cluster.Members = append(cluster.Members, connInfo)
+	}
+
+	return cluster, nil
+}
+
+func (h *natsHandler) getConnectionInfo(mbusURL MbusURL, host, hostname string) (*yagnats.ConnectionInfo, error) {
+	settings := h.settingsService.GetSettings()
+
 	connInfo := new(yagnats.ConnectionInfo)
-	connInfo.Addr = natsURL.Host
+	connInfo.Addr = host
+	connInfo.Username = mbusURL.Username
+	connInfo.Password = mbusURL.Password
+
+	tlsConfig, err := NewTLSConfig(settings.Env.Bosh.Mbus.Cert, mbusURL.TLSServerName(hostname))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building TLS config")
+	}
 
-	user := natsURL.User
-	if user != nil {
-		password, passwordIsSet := user.Password()
-		if !passwordIsSet {
-			return nil, errors.New("No password set for connection")
+	if tlsConfig != nil {
+		connInfo.Dial = func(network, address string) (net.Conn, error) {
+			return dialNatsTLS(network, address, tlsConfig)
 		}
-		connInfo.Password = password
-		connInfo.Username = user.Username()
 	}
 
 	return connInfo, nil
 }
+
+// dialNatsTLS upgrades connection to TLS after reading INFO
+// that NATS server always sends in plain text first
+func dialNatsTLS(network, address string, tlsConfig *tls.Config) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, address, natsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(natsDialTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, bosherr.WrapError(err, "Setting NATS handshake deadline")
+	}
+
+	// Reading byte by byte so that nothing past INFO is consumed
+	// before connection is handed over to TLS client
+	info, err := readNatsLine(conn)
+	if err != nil {
+		conn.Close()
+		return nil, bosherr.WrapError(err, "Reading NATS server INFO")
+	}
+
+	if !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return nil, bosherr.Errorf("Expected INFO from NATS server, got '%s'", info)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, bosherr.WrapError(err, "Upgrading NATS connection to TLS")
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		tlsConn.Close()
+		return nil, bosherr.WrapError(err, "Clearing NATS handshake deadline")
+	}
+
+	return tlsConn, nil
+}
+
+func readNatsLine(conn net.Conn) (string, error) {
+	var line []byte
+
+	buf := make([]byte, 1)
+
+	for len(line) < responseMaxLength {
+		_, err := conn.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		if buf[0] == '\n' {
+			return strings.TrimRight(string(line), "\r"), nil
+		}
+
+		line = append(line, buf[0])
+	}
+
+	return "", bosherr.Errorf("Line exceeds %d bytes", responseMaxLength)
+}
@@ -0,0 +1,39 @@
+package mbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// NewTLSConfig returns nil when no CA is configured so that
+// connection to the director is left unencrypted
+func NewTLSConfig(certs boshsettings.CertKeyPair, serverName string) (*tls.Config, error) {
+	if certs.CA == "" {
+		return nil, nil
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM([]byte(certs.CA)) {
+		return nil, bosherr.Error("Parsing mbus CA certificate")
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if certs.Certificate != "" || certs.PrivateKey != "" {
+		clientCert, err := tls.X509KeyPair([]byte(certs.Certificate), []byte(certs.PrivateKey))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Parsing mbus client certificate")
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,74 @@
+package mbus_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+var _ = Describe("NewTLSConfig", func() {
+	var (
+		certPEM string
+		keyPEM  string
+	)
+
+	BeforeEach(func() {
+		certBytes, err := ioutil.ReadFile("agent.cert")
+		Expect(err).ToNot(HaveOccurred())
+		certPEM = string(certBytes)
+
+		keyBytes, err := ioutil.ReadFile("agent.key")
+		Expect(err).ToNot(HaveOccurred())
+		keyPEM = string(keyBytes)
+	})
+
+	It("returns no config when CA is not configured", func() {
+		tlsConfig, err := NewTLSConfig(boshsettings.CertKeyPair{}, "fake-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig).To(BeNil())
+	})
+
+	It("verifies server certificate against configured CA", func() {
+		tlsConfig, err := NewTLSConfig(boshsettings.CertKeyPair{CA: certPEM}, "fake-host")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig.RootCAs).ToNot(BeNil())
+		Expect(tlsConfig.ServerName).To(Equal("fake-host"))
+		Expect(tlsConfig.InsecureSkipVerify).To(BeFalse())
+		Expect(tlsConfig.Certificates).To(BeEmpty())
+	})
+
+	It("presents client certificate when configured", func() {
+		tlsConfig, err := NewTLSConfig(boshsettings.CertKeyPair{
+			CA:          certPEM,
+			Certificate: certPEM,
+			PrivateKey:  keyPEM,
+		}, "fake-host")
+		Expect(err).ToNot(HaveOccurred())
+
+		expectedCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig.Certificates).To(HaveLen(1))
+		Expect(tlsConfig.Certificates[0].Certificate).To(Equal(expectedCert.Certificate))
+	})
+
+	It("returns error when CA cannot be parsed", func() {
+		_, err := NewTLSConfig(boshsettings.CertKeyPair{CA: "fake-ca"}, "fake-host")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Parsing mbus CA certificate"))
+	})
+
+	It("returns error when client certificate cannot be parsed", func() {
+		_, err := NewTLSConfig(boshsettings.CertKeyPair{
+			CA:          certPEM,
+			Certificate: certPEM,
+			PrivateKey:  "fake-key",
+		}, "fake-host")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing mbus client certificate"))
+	})
+})
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	"github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -44,9 +46,10 @@ type certManager struct {
 	// Update execution time limit in seconds
 	// No retry if 0
 	updateTimeout time.Duration
+	clock         clock.Clock
 }
 
-func NewUbuntuCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, logger logger.Logger) Manager {
+func NewUbuntuCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, clock clock.Clock, logger logger.Logger) Manager {
 	return &certManager{
 		fs:            fs,
 		runner:        runner,
@@ -56,10 +59,11 @@ func NewUbuntuCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeo
 		logger:        logger,
 		logTag:        "UbuntuCertManager",
 		updateTimeout: timeout,
+		clock:         clock,
 	}
 }
 
-func NewCentOSCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, logger logger.Logger) Manager {
+func NewCentOSCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, clock clock.Clock, logger logger.Logger) Manager {
 	return &certManager{
 		fs:            fs,
 		runner:        runner,
@@ -68,10 +72,11 @@ func NewCentOSCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeo
 		logger:        logger,
 		logTag:        "CentOSCertManager",
 		updateTimeout: timeout,
+		clock:         clock,
 	}
 }
 
-func NewDummyCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, logger logger.Logger) Manager {
+func NewDummyCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeout time.Duration, clock clock.Clock, logger logger.Logger) Manager {
 	return &certManager{
 		fs:            fs,
 		runner:        runner,
@@ -80,6 +85,7 @@ func NewDummyCertManager(fs boshsys.FileSystem, runner boshsys.CmdRunner, timeou
 		logger:        logger,
 		logTag:        "DummyCertManager",
 		updateTimeout: timeout,
+		clock:         clock,
 	}
 }
 
@@ -90,15 +96,23 @@ func (c *certManager) UpdateCertificates(certs string) error {
 		return nil
 	}
 
+	slicedCerts := splitCerts(certs)
+
+	// Avoid running update command on every bootstrap and settings update
+	// since it is slow and occasionally hangs
+	if c.certFilesMatch(slicedCerts) {
+		c.logger.Debug(c.logTag, "Trusted certificates are already up to date")
+		return nil
+	}
+
 	deletedFilesCount, err := deleteFiles(c.fs, c.path, "bosh-trusted-cert-")
 	c.logger.Debug(c.logTag, "Deleted %d existing certificate files", deletedFilesCount)
 	if err != nil {
 		return err
 	}
 
-	slicedCerts := splitCerts(certs)
 	for i, cert := range slicedCerts {
-		err := c.fs.WriteFileString(fmt.Sprintf("%sbosh-trusted-cert-%d.crt", c.path, i+1), cert)
+		err := c.fs.WriteFileString(c.certFilePath(i), cert)
 		if err != nil {
 			return err
 		}
@@ -124,13 +138,17 @@ func (c *certManager) UpdateCertificates(certs string) error {
 
 			resultChannel := process.Wait()
 
+			timer := c.clock.NewTimer(c.updateTimeout * time.Second)
+
 			select {
-			case <-time.After(c.updateTimeout * time.Second):
+			case <-timer.C():
 				err = process.TerminateNicely(5 * time.Second)
 				if err != nil {
 					c.logger.Debug(c.logTag, "Failed to terminate update certificates cmd '%s' after %d seconds", c.updateCmdPath, c.updateTimeout)
 				}
 			case result := <-resultChannel:
+				timer.Stop()
+
 				if result.Error == nil {
 					c.logger.Debug(c.logTag, "Successfully updated new certificate files")
 					return nil
@@ -152,6 +170,37 @@ func (c *certManager) UpdateCertificates(certs string) error {
 	return nil
 }
 
+func (c *certManager) certFilePath(i int) string {
+	return fmt.Sprintf("%sbosh-trusted-cert-%d.crt", c.path, i+1)
+}
+
+// certFilesMatch returns true when exactly the given certs are installed
+func (c *certManager) certFilesMatch(certs []string) bool {
+	files, err := c.fs.Glob(fmt.Sprintf("%sbosh-trusted-cert-*", c.path))
+	if err != nil || len(files) != len(certs) {
+		return false
+	}
+
+	installedFiles := map[string]bool{}
+	for _, file := range files {
+		installedFiles[file] = true
+	}
+
+	for i, cert := range certs {
+		path := c.certFilePath(i)
+		if !installedFiles[path] {
+			return false
+		}
+
+		contents, err := c.fs.ReadFileString(path)
+		if err != nil || contents != cert {
+			return false
+		}
+	}
+
+	return true
+}
+
 // SplitCerts returns a slice containing each PEM certificate in the given string.
 // extra data before the first cert, between each cert, and after the last cert
 // is all discarded. Each string in the returned slice will begin with
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	"github.com/cloudfoundry/bosh-agent/platform/cert"
 	"github.com/cloudfoundry/bosh-utils/logger"
@@ -168,6 +170,10 @@ var _ = Describe("Certificate Management", func() {
 			certManager   cert.Manager
 		)
 
+		updateCommandCount := func() int {
+			return len(fakeCmdRunner.RunCommands) + len(fakeCmdRunner.RunComplexCommands)
+		}
+
 		SharedLinuxCertManagerExamples := func(certBasePath, certUpdateProgram string) {
 			It("writes 1 cert to a file", func() {
 				err := certManager.UpdateCertificates(cert1)
@@ -212,6 +218,55 @@ var _ = Describe("Certificate Management", func() {
 				Expect(countFiles(fakeFs, certBasePath)).To(Equal(1))
 			})
 
+			Context("when certs are already installed", func() {
+				BeforeEach(func() {
+					fakeFs.WriteFileString(fmt.Sprintf("%s/bosh-trusted-cert-1.crt", certBasePath), cert1)
+					fakeFs.SetGlob(fmt.Sprintf("%s/bosh-trusted-cert-*", certBasePath), []string{
+						fmt.Sprintf("%s/bosh-trusted-cert-1.crt", certBasePath),
+					})
+				})
+
+				It("does not rewrite cert files or run update command when certs are unchanged", func() {
+					fakeFs.WriteFileError = errors.New("NOT ALLOW")
+
+					err := certManager.UpdateCertificates(cert1)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(updateCommandCount()).To(Equal(0))
+				})
+
+				It("runs update command when cert contents changed", func() {
+					changedCert := strings.Replace(cert1, "DtmvI8bXKxU=", "DtmvI8bXKxV=", 1)
+
+					err := certManager.UpdateCertificates(changedCert)
+					Expect(err).NotTo(HaveOccurred())
+
+					contents, err := fakeFs.ReadFileString(fmt.Sprintf("%s/bosh-trusted-cert-1.crt", certBasePath))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(contents).To(Equal(changedCert))
+					Expect(updateCommandCount()).To(Equal(1))
+				})
+
+				It("runs update command when certs are added", func() {
+					err := certManager.UpdateCertificates(fmt.Sprintf("%s\n%s\n", cert1, cert1))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(countFiles(fakeFs, certBasePath)).To(Equal(2))
+					Expect(updateCommandCount()).To(Equal(1))
+				})
+
+				It("removes certs that are no longer present and runs update command", func() {
+					err := certManager.UpdateCertificates("")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeFs.FileExists(fmt.Sprintf("%s/bosh-trusted-cert-1.crt", certBasePath))).To(BeFalse())
+					Expect(updateCommandCount()).To(Equal(1))
+				})
+			})
+
+			It("does not run update command when there are no certs to install or remove", func() {
+				err := certManager.UpdateCertificates("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updateCommandCount()).To(Equal(0))
+			})
+
 			It("returns an error when writing new cert files fails", func() {
 				fakeFs.WriteFileError = errors.New("NOT ALLOW")
 				err := certManager.UpdateCertificates(cert1)
@@ -246,7 +301,7 @@ var _ = Describe("Certificate Management", func() {
 					ExitStatus: 0,
 					Sticky:     true,
 				})
-				certManager = cert.NewUbuntuCertManager(fakeFs, fakeCmdRunner, 1, log)
+				certManager = cert.NewUbuntuCertManager(fakeFs, fakeCmdRunner, 1, clock.NewClock(), log)
 				fakeResult = boshsys.Result{
 					Stdout:     "",
 					Stderr:     "",
@@ -326,7 +381,7 @@ var _ = Describe("Certificate Management", func() {
 					ExitStatus: 0,
 					Sticky:     true,
 				})
-				certManager = cert.NewCentOSCertManager(fakeFs, fakeCmdRunner, 0, log)
+				certManager = cert.NewCentOSCertManager(fakeFs, fakeCmdRunner, 0, clock.NewClock(), log)
 			})
 
 			SharedLinuxCertManagerExamples("/etc/pki/ca-trust/source/anchors", "/usr/bin/update-ca-trust")
@@ -339,7 +394,7 @@ var _ = Describe("Certificate Management", func() {
 					ExitStatus: 2,
 					Error:      errors.New("command failed"),
 				})
-				certManager = cert.NewCentOSCertManager(fakeFs, fakeCmdRunner, 0, log)
+				certManager = cert.NewCentOSCertManager(fakeFs, fakeCmdRunner, 0, clock.NewClock(), log)
 
 				err := certManager.UpdateCertificates(cert1)
 				Expect(err).To(HaveOccurred())
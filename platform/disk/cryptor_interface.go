@@ -0,0 +1,15 @@
+package disk
+
+type Cryptor interface {
+	// IsEncrypted checks whether device starts with LUKS header
+	IsEncrypted(devicePath string) (bool, error)
+
+	// Open maps decrypted device and returns its path;
+	// already opened device is not opened again
+	Open(devicePath, passphrase string) (mappedPath string, err error)
+
+	// MappedPath returns path of decrypted device if it is open
+	MappedPath(devicePath string) (mappedPath string, isOpen bool)
+
+	Close(devicePath string) error
+}
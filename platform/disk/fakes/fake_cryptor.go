@@ -0,0 +1,61 @@
+package fakes
+
+import (
+	"path"
+)
+
+type FakeCryptor struct {
+	EncryptedDevices map[string]bool
+	IsEncryptedErr   error
+
+	OpenDevicePaths []string
+	OpenPassphrases []string
+	OpenErr         error
+
+	// Opened devices are mapped to their decrypted paths
+	MappedPaths map[string]string
+
+	ClosedDevicePaths []string
+	CloseErr          error
+}
+
+func NewFakeCryptor() *FakeCryptor {
+	return &FakeCryptor{
+		EncryptedDevices: map[string]bool{},
+		MappedPaths:      map[string]string{},
+	}
+}
+
+func (c *FakeCryptor) IsEncrypted(devicePath string) (bool, error) {
+	return c.EncryptedDevices[devicePath], c.IsEncryptedErr
+}
+
+func (c *FakeCryptor) Open(devicePath, passphrase string) (string, error) {
+	c.OpenDevicePaths = append(c.OpenDevicePaths, devicePath)
+	c.OpenPassphrases = append(c.OpenPassphrases, passphrase)
+
+	if c.OpenErr != nil {
+		return "", c.OpenErr
+	}
+
+	mappedPath := "/dev/mapper/" + path.Base(devicePath) + "-fake-crypt"
+	c.MappedPaths[devicePath] = mappedPath
+
+	return mappedPath, nil
+}
+
+func (c *FakeCryptor) MappedPath(devicePath string) (string, bool) {
+	mappedPath, isOpen := c.MappedPaths[devicePath]
+	return mappedPath, isOpen
+}
+
+func (c *FakeCryptor) Close(devicePath string) error {
+	c.ClosedDevicePaths = append(c.ClosedDevicePaths, devicePath)
+
+	if c.CloseErr != nil {
+		return c.CloseErr
+	}
+
+	delete(c.MappedPaths, devicePath)
+	return nil
+}
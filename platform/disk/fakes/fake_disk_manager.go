@@ -11,6 +11,7 @@ type FakeDiskManager struct {
 	FakeFormatter             *FakeFormatter
 	FakeMounter               *FakeMounter
 	FakeMountsSearcher        *FakeMountsSearcher
+	FakeCryptor               *FakeCryptor
 	FakeRootDevicePartitioner *FakePartitioner
 	FakeDiskUtil              *fakedevutil.FakeDeviceUtil
 	DiskUtilDiskPath          string
@@ -24,6 +25,7 @@ func NewFakeDiskManager() *FakeDiskManager {
 		FakeFormatter:             &FakeFormatter{},
 		FakeMounter:               &FakeMounter{},
 		FakeMountsSearcher:        &FakeMountsSearcher{},
+		FakeCryptor:               NewFakeCryptor(),
 		FakeRootDevicePartitioner: NewFakePartitioner(),
 		FakeDiskUtil:              fakedevutil.NewFakeDeviceUtil(),
 		PartedPartitionerCalled:   false,
@@ -57,6 +59,10 @@ func (m *FakeDiskManager) GetMountsSearcher() boshdisk.MountsSearcher {
 	return m.FakeMountsSearcher
 }
 
+func (m *FakeDiskManager) GetCryptor() boshdisk.Cryptor {
+	return m.FakeCryptor
+}
+
 func (m *FakeDiskManager) GetDiskUtil(diskPath string) boshdevutil.DeviceUtil {
 	m.DiskUtilDiskPath = diskPath
 	return m.FakeDiskUtil
@@ -19,9 +19,10 @@ type FakeMounter struct {
 	SwapOnPartitionPaths []string
 	SwapOnErr            error
 
-	UnmountPartitionPathOrMountPoint string
-	UnmountDidUnmount                bool
-	UnmountErr                       error
+	UnmountPartitionPathOrMountPoint   string
+	UnmountPartitionPathsOrMountPoints []string
+	UnmountDidUnmount                  bool
+	UnmountErr                         error
 
 	IsMountPointPath          string
 	IsMountPointPartitionPath string
@@ -31,6 +32,7 @@ type FakeMounter struct {
 	IsMountedDevicePathOrMountPoint string
 	IsMountedResult                 bool
 	IsMountedErr                    error
+	IsMountedStub                   func(devicePathOrMountPoint string) (bool, error)
 }
 
 func (m *FakeMounter) Mount(partitionPath, mountPoint string, mountOptions ...string) error {
@@ -61,6 +63,7 @@ func (m *FakeMounter) SwapOn(partitionPath string) (err error) {
 
 func (m *FakeMounter) Unmount(partitionPathOrMountPoint string) (didUnmount bool, err error) {
 	m.UnmountPartitionPathOrMountPoint = partitionPathOrMountPoint
+	m.UnmountPartitionPathsOrMountPoints = append(m.UnmountPartitionPathsOrMountPoints, partitionPathOrMountPoint)
 	return m.UnmountDidUnmount, m.UnmountErr
 }
 
@@ -71,5 +74,8 @@ func (m *FakeMounter) IsMountPoint(path string) (partitionPath string, result bo
 
 func (m *FakeMounter) IsMounted(devicePathOrMountPoint string) (bool, error) {
 	m.IsMountedDevicePathOrMountPoint = devicePathOrMountPoint
+	if m.IsMountedStub != nil {
+		return m.IsMountedStub(devicePathOrMountPoint)
+	}
 	return m.IsMountedResult, m.IsMountedErr
 }
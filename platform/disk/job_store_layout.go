@@ -0,0 +1,167 @@
+package disk
+
+import (
+	"os"
+	"path"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// JobStoreLayout exposes each job's directory on the persistent disk
+// as a separate bind mount: <mountsDir>/<job> -> <storeDir>/<job>
+type JobStoreLayout interface {
+	// Apply bind-mounts store directories of given jobs
+	// and removes bind mounts of all other jobs
+	Apply(jobNames []string) error
+
+	// Restore bind-mounts store directories of previously applied jobs,
+	// e.g. after persistent disk was mounted again on reboot
+	Restore() error
+
+	// Release unmounts all bind mounts so that persistent disk can be unmounted;
+	// applied jobs are remembered for Restore
+	Release() error
+}
+
+type bindMountJobStoreLayout struct {
+	storeDir  string
+	mountsDir string
+	mounter   Mounter
+	fs        boshsys.FileSystem
+	logger    boshlog.Logger
+	logTag    string
+}
+
+// NewBindMountJobStoreLayout expects mounter to perform bind mounts
+func NewBindMountJobStoreLayout(
+	storeDir string,
+	mountsDir string,
+	mounter Mounter,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) JobStoreLayout {
+	return bindMountJobStoreLayout{
+		storeDir:  storeDir,
+		mountsDir: mountsDir,
+		mounter:   mounter,
+		fs:        fs,
+		logger:    logger,
+		logTag:    "bindMountJobStoreLayout",
+	}
+}
+
+func (l bindMountJobStoreLayout) Apply(jobNames []string) error {
+	keep := map[string]bool{}
+
+	for _, jobName := range jobNames {
+		err := l.fs.MkdirAll(path.Join(l.mountsDir, jobName), os.FileMode(0755))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating store mount point for job %s", jobName)
+		}
+
+		keep[jobName] = true
+	}
+
+	appliedJobNames, err := l.appliedJobNames()
+	if err != nil {
+		return err
+	}
+
+	for _, jobName := range appliedJobNames {
+		if keep[jobName] {
+			continue
+		}
+
+		mountPoint := path.Join(l.mountsDir, jobName)
+
+		_, err := l.mounter.Unmount(mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Unmounting store of job %s", jobName)
+		}
+
+		// Only the empty mount point is removed; job data stays on the persistent disk
+		err = l.fs.RemoveAll(mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing store mount point for job %s", jobName)
+		}
+	}
+
+	return l.Restore()
+}
+
+func (l bindMountJobStoreLayout) Restore() error {
+	_, isMountPoint, err := l.mounter.IsMountPoint(l.storeDir)
+	if err != nil {
+		return bosherr.WrapError(err, "Checking whether persistent disk is mounted")
+	}
+
+	if !isMountPoint {
+		l.logger.Debug(l.logTag, "Skipping bind-mounting job stores since %s is not mounted", l.storeDir)
+		return nil
+	}
+
+	jobNames, err := l.appliedJobNames()
+	if err != nil {
+		return err
+	}
+
+	for _, jobName := range jobNames {
+		mountPoint := path.Join(l.mountsDir, jobName)
+
+		isMounted, err := l.mounter.IsMounted(mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking whether store of job %s is mounted", jobName)
+		}
+
+		if isMounted {
+			continue
+		}
+
+		jobStoreDir := path.Join(l.storeDir, jobName)
+
+		err = l.fs.MkdirAll(jobStoreDir, os.FileMode(0755))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating store directory for job %s", jobName)
+		}
+
+		err = l.mounter.Mount(jobStoreDir, mountPoint)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Bind-mounting store of job %s", jobName)
+		}
+	}
+
+	return nil
+}
+
+func (l bindMountJobStoreLayout) Release() error {
+	jobNames, err := l.appliedJobNames()
+	if err != nil {
+		return err
+	}
+
+	for _, jobName := range jobNames {
+		_, err := l.mounter.Unmount(path.Join(l.mountsDir, jobName))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Unmounting store of job %s", jobName)
+		}
+	}
+
+	return nil
+}
+
+func (l bindMountJobStoreLayout) appliedJobNames() ([]string, error) {
+	mountPoints, err := l.fs.Glob(path.Join(l.mountsDir, "*"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding job store mount points")
+	}
+
+	jobNames := []string{}
+
+	for _, mountPoint := range mountPoints {
+		jobNames = append(jobNames, path.Base(mountPoint))
+	}
+
+	return jobNames, nil
+}
@@ -0,0 +1,166 @@
+package disk_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/disk"
+	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("bindMountJobStoreLayout", func() {
+	var (
+		mounter *fakedisk.FakeMounter
+		fs      *fakesys.FakeFileSystem
+		layout  JobStoreLayout
+	)
+
+	BeforeEach(func() {
+		mounter = &fakedisk.FakeMounter{}
+		fs = fakesys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		layout = NewBindMountJobStoreLayout("/fake-store", "/fake-store-mounts", mounter, fs, logger)
+
+		mounter.IsMountPointResult = true
+	})
+
+	Describe("Apply", func() {
+		It("bind-mounts store directory of each job", func() {
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1", "/fake-store-mounts/job2"})
+
+			err := layout.Apply([]string{"job1", "job2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/fake-store/job1")).To(BeTrue())
+			Expect(fs.FileExists("/fake-store/job2")).To(BeTrue())
+			Expect(fs.FileExists("/fake-store-mounts/job1")).To(BeTrue())
+			Expect(fs.FileExists("/fake-store-mounts/job2")).To(BeTrue())
+
+			Expect(mounter.IsMountPointPath).To(Equal("/fake-store"))
+			Expect(mounter.MountPartitionPaths).To(Equal([]string{"/fake-store/job1", "/fake-store/job2"}))
+			Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-store-mounts/job1", "/fake-store-mounts/job2"}))
+			Expect(mounter.UnmountPartitionPathsOrMountPoints).To(BeEmpty())
+		})
+
+		It("does not bind-mount job stores that are already mounted", func() {
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1", "/fake-store-mounts/job2"})
+
+			mounter.IsMountedStub = func(mountPoint string) (bool, error) {
+				return mountPoint == "/fake-store-mounts/job1", nil
+			}
+
+			err := layout.Apply([]string{"job1", "job2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mounter.MountPartitionPaths).To(Equal([]string{"/fake-store/job2"}))
+			Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-store-mounts/job2"}))
+		})
+
+		It("unmounts and removes mount points of jobs that are no longer applied", func() {
+			fs.MkdirAll("/fake-store-mounts/old-job", 0755)
+			fs.WriteFileString("/fake-store/old-job/data", "fake-data")
+			fs.SetGlob("/fake-store-mounts/*",
+				[]string{"/fake-store-mounts/job1", "/fake-store-mounts/old-job"},
+				[]string{"/fake-store-mounts/job1"},
+			)
+
+			err := layout.Apply([]string{"job1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mounter.UnmountPartitionPathsOrMountPoints).To(Equal([]string{"/fake-store-mounts/old-job"}))
+			Expect(fs.FileExists("/fake-store-mounts/old-job")).To(BeFalse())
+			Expect(fs.FileExists("/fake-store/old-job/data")).To(BeTrue())
+
+			Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-store-mounts/job1"}))
+		})
+
+		It("only creates mount points when persistent disk is not mounted", func() {
+			mounter.IsMountPointResult = false
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1"})
+
+			err := layout.Apply([]string{"job1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/fake-store-mounts/job1")).To(BeTrue())
+			Expect(fs.FileExists("/fake-store/job1")).To(BeFalse())
+			Expect(mounter.MountCalled).To(BeFalse())
+		})
+
+		It("returns error when unmounting store of removed job fails", func() {
+			fs.MkdirAll("/fake-store-mounts/old-job", 0755)
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/old-job"})
+			mounter.UnmountErr = errors.New("fake-unmount-err")
+
+			err := layout.Apply([]string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unmounting store of job old-job: fake-unmount-err"))
+			Expect(fs.FileExists("/fake-store-mounts/old-job")).To(BeTrue())
+		})
+
+		It("returns error when bind-mounting fails", func() {
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1"})
+			mounter.MountErr = errors.New("fake-mount-err")
+
+			err := layout.Apply([]string{"job1"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Bind-mounting store of job job1: fake-mount-err"))
+		})
+
+		It("returns error when checking whether persistent disk is mounted fails", func() {
+			mounter.IsMountPointErr = errors.New("fake-is-mount-point-err")
+
+			err := layout.Apply([]string{"job1"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-is-mount-point-err"))
+		})
+	})
+
+	Describe("Restore", func() {
+		It("bind-mounts store directories of previously applied jobs", func() {
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1"})
+
+			err := layout.Restore()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mounter.MountPartitionPaths).To(Equal([]string{"/fake-store/job1"}))
+			Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-store-mounts/job1"}))
+		})
+
+		It("does nothing when persistent disk is not mounted", func() {
+			mounter.IsMountPointResult = false
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1"})
+
+			err := layout.Restore()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mounter.MountCalled).To(BeFalse())
+		})
+	})
+
+	Describe("Release", func() {
+		It("unmounts store of each applied job and keeps mount points", func() {
+			fs.MkdirAll("/fake-store-mounts/job1", 0755)
+			fs.MkdirAll("/fake-store-mounts/job2", 0755)
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1", "/fake-store-mounts/job2"})
+
+			err := layout.Release()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mounter.UnmountPartitionPathsOrMountPoints).To(Equal([]string{"/fake-store-mounts/job1", "/fake-store-mounts/job2"}))
+			Expect(fs.FileExists("/fake-store-mounts/job1")).To(BeTrue())
+			Expect(fs.FileExists("/fake-store-mounts/job2")).To(BeTrue())
+		})
+
+		It("returns error when unmounting fails", func() {
+			fs.SetGlob("/fake-store-mounts/*", []string{"/fake-store-mounts/job1"})
+			mounter.UnmountErr = errors.New("fake-unmount-err")
+
+			err := layout.Release()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unmounting store of job job1: fake-unmount-err"))
+		})
+	})
+})
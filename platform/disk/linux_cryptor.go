@@ -0,0 +1,83 @@
+package disk
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var luksMagic = []byte{'L', 'U', 'K', 'S', 0xba, 0xbe}
+
+// cryptsetup exits with 2 when no key slot can be opened with given passphrase
+const cryptsetupWrongPassphraseExitStatus = 2
+
+type linuxCryptor struct {
+	runner boshsys.CmdRunner
+	fs     boshsys.FileSystem
+}
+
+func NewLinuxCryptor(runner boshsys.CmdRunner, fs boshsys.FileSystem) Cryptor {
+	return linuxCryptor{runner: runner, fs: fs}
+}
+
+func (c linuxCryptor) IsEncrypted(devicePath string) (bool, error) {
+	file, err := c.fs.OpenFile(devicePath, os.O_RDONLY, 0)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Opening device '%s'", devicePath)
+	}
+
+	defer file.Close()
+
+	header := make([]byte, len(luksMagic))
+
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return false, bosherr.WrapErrorf(err, "Reading header of device '%s'", devicePath)
+	}
+
+	return n >= len(luksMagic) && bytes.Equal(header, luksMagic), nil
+}
+
+func (c linuxCryptor) Open(devicePath, passphrase string) (string, error) {
+	mappedPath, isOpen := c.MappedPath(devicePath)
+	if isOpen {
+		return mappedPath, nil
+	}
+
+	_, stderr, exitStatus, err := c.runner.RunCommandWithInput(passphrase, "cryptsetup", "luksOpen", "--key-file=-", devicePath, c.mappedName(devicePath))
+	if err != nil {
+		if exitStatus == cryptsetupWrongPassphraseExitStatus {
+			return "", bosherr.Errorf("Wrong passphrase for encrypted device '%s'", devicePath)
+		}
+		return "", bosherr.WrapErrorf(err, "Shelling out to cryptsetup luksOpen: %s", stderr)
+	}
+
+	return mappedPath, nil
+}
+
+func (c linuxCryptor) MappedPath(devicePath string) (string, bool) {
+	mappedPath := path.Join("/dev/mapper", c.mappedName(devicePath))
+	return mappedPath, c.fs.FileExists(mappedPath)
+}
+
+func (c linuxCryptor) Close(devicePath string) error {
+	if _, isOpen := c.MappedPath(devicePath); !isOpen {
+		return nil
+	}
+
+	_, _, _, err := c.runner.RunCommand("cryptsetup", "luksClose", c.mappedName(devicePath))
+	if err != nil {
+		return bosherr.WrapError(err, "Shelling out to cryptsetup luksClose")
+	}
+
+	return nil
+}
+
+// mappedName is unique per device, e.g. /dev/sdc is mapped to /dev/mapper/sdc-crypt
+func (c linuxCryptor) mappedName(devicePath string) string {
+	return path.Base(devicePath) + "-crypt"
+}
@@ -0,0 +1,121 @@
+package disk_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/disk"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("LinuxCryptor", func() {
+	var (
+		runner  *fakesys.FakeCmdRunner
+		fs      *fakesys.FakeFileSystem
+		cryptor Cryptor
+	)
+
+	BeforeEach(func() {
+		runner = fakesys.NewFakeCmdRunner()
+		fs = fakesys.NewFakeFileSystem()
+		cryptor = NewLinuxCryptor(runner, fs)
+	})
+
+	Describe("IsEncrypted", func() {
+		It("returns true when device starts with LUKS header", func() {
+			fs.WriteFile("/dev/sdc", append([]byte{'L', 'U', 'K', 'S', 0xba, 0xbe, 0x00, 0x01}, []byte("fake-header")...))
+
+			encrypted, err := cryptor.IsEncrypted("/dev/sdc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encrypted).To(BeTrue())
+		})
+
+		It("returns false when device does not start with LUKS header", func() {
+			fs.WriteFileString("/dev/sdc", "fake-ext4-superblock")
+
+			encrypted, err := cryptor.IsEncrypted("/dev/sdc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encrypted).To(BeFalse())
+		})
+
+		It("returns false when device is shorter than LUKS header", func() {
+			fs.WriteFileString("/dev/sdc", "LUKS")
+
+			encrypted, err := cryptor.IsEncrypted("/dev/sdc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encrypted).To(BeFalse())
+		})
+
+		It("returns error when device cannot be opened", func() {
+			fs.OpenFileErr = errors.New("fake-open-err")
+
+			_, err := cryptor.IsEncrypted("/dev/sdc")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Opening device '/dev/sdc': fake-open-err"))
+		})
+	})
+
+	Describe("Open", func() {
+		It("opens device with passphrase given on stdin", func() {
+			mappedPath, err := cryptor.Open("/dev/sdc", "fake-passphrase")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mappedPath).To(Equal("/dev/mapper/sdc-crypt"))
+
+			Expect(runner.RunCommandsWithInput).To(Equal([][]string{
+				{"fake-passphrase", "cryptsetup", "luksOpen", "--key-file=-", "/dev/sdc", "sdc-crypt"},
+			}))
+		})
+
+		It("does not open device again when it is already open", func() {
+			fs.WriteFileString("/dev/mapper/sdc-crypt", "")
+
+			mappedPath, err := cryptor.Open("/dev/sdc", "fake-passphrase")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mappedPath).To(Equal("/dev/mapper/sdc-crypt"))
+			Expect(runner.RunCommandsWithInput).To(BeEmpty())
+		})
+
+		It("returns error when passphrase is wrong", func() {
+			runner.AddCmdResult("fake-wrong-passphrase cryptsetup luksOpen --key-file=- /dev/sdc sdc-crypt", fakesys.FakeCmdResult{
+				Stderr:     "No key available with this passphrase.",
+				ExitStatus: 2,
+				Error:      errors.New("fake-exit-err"),
+			})
+
+			_, err := cryptor.Open("/dev/sdc", "fake-wrong-passphrase")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Wrong passphrase for encrypted device '/dev/sdc'"))
+			Expect(err.Error()).ToNot(ContainSubstring("fake-wrong-passphrase"))
+		})
+
+		It("returns error when cryptsetup fails", func() {
+			runner.AddCmdResult("fake-passphrase cryptsetup luksOpen --key-file=- /dev/sdc sdc-crypt", fakesys.FakeCmdResult{
+				Stderr:     "fake-stderr",
+				ExitStatus: 1,
+				Error:      errors.New("fake-exit-err"),
+			})
+
+			_, err := cryptor.Open("/dev/sdc", "fake-passphrase")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Shelling out to cryptsetup luksOpen: fake-stderr: fake-exit-err"))
+		})
+	})
+
+	Describe("Close", func() {
+		It("closes open device", func() {
+			fs.WriteFileString("/dev/mapper/sdc-crypt", "")
+
+			err := cryptor.Close("/dev/sdc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.RunCommands).To(Equal([][]string{{"cryptsetup", "luksClose", "sdc-crypt"}}))
+		})
+
+		It("does nothing when device is not open", func() {
+			err := cryptor.Close("/dev/sdc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.RunCommands).To(BeEmpty())
+		})
+	})
+})
@@ -16,6 +16,7 @@ type linuxDiskManager struct {
 	formatter             Formatter
 	mounter               Mounter
 	mountsSearcher        MountsSearcher
+	cryptor               Cryptor
 	fs                    boshsys.FileSystem
 	logger                boshlog.Logger
 	runner                boshsys.CmdRunner
@@ -26,6 +27,7 @@ func NewLinuxDiskManager(
 	runner boshsys.CmdRunner,
 	fs boshsys.FileSystem,
 	bindMount bool,
+	timeService clock.Clock,
 ) (manager Manager) {
 	var mounter Mounter
 	var mountsSearcher MountsSearcher
@@ -42,19 +44,20 @@ func NewLinuxDiskManager(
 		mountsSearcher = NewCmdMountsSearcher(runner)
 	}
 
-	mounter = NewLinuxMounter(runner, mountsSearcher, 1*time.Second)
+	mounter = NewLinuxMounter(runner, mountsSearcher, 1*time.Second, timeService)
 
 	if bindMount {
 		mounter = NewLinuxBindMounter(mounter)
 	}
 
 	return linuxDiskManager{
-		partitioner:           NewSfdiskPartitioner(logger, runner, clock.NewClock()),
+		partitioner:           NewSfdiskPartitioner(logger, runner, timeService),
 		rootDevicePartitioner: NewRootDevicePartitioner(logger, runner, uint64(20*1024*1024)),
-		partedPartitioner:     NewPartedPartitioner(logger, runner, clock.NewClock()),
+		partedPartitioner:     NewPartedPartitioner(logger, runner, timeService),
 		formatter:             NewLinuxFormatter(runner, fs),
 		mounter:               mounter,
 		mountsSearcher:        mountsSearcher,
+		cryptor:               NewLinuxCryptor(runner, fs),
 		fs:                    fs,
 		logger:                logger,
 		runner:                runner,
@@ -72,6 +75,7 @@ func (m linuxDiskManager) GetRootDevicePartitioner() Partitioner {
 func (m linuxDiskManager) GetFormatter() Formatter           { return m.formatter }
 func (m linuxDiskManager) GetMounter() Mounter               { return m.mounter }
 func (m linuxDiskManager) GetMountsSearcher() MountsSearcher { return m.mountsSearcher }
+func (m linuxDiskManager) GetCryptor() Cryptor               { return m.cryptor }
 
 func (m linuxDiskManager) GetDiskUtil(diskPath string) boshdevutil.DeviceUtil {
 	return NewDiskUtil(diskPath, m.runner, m.mounter, m.fs, m.logger)
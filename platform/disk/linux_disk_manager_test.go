@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	. "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -16,20 +17,22 @@ var _ = Describe("NewLinuxDiskManager", func() {
 		runner *fakesys.FakeCmdRunner
 		fs     *fakesys.FakeFileSystem
 		logger boshlog.Logger
+		clock  *fakeaction.FakeClock
 	)
 
 	BeforeEach(func() {
 		runner = fakesys.NewFakeCmdRunner()
 		fs = fakesys.NewFakeFileSystem()
 		logger = boshlog.NewLogger(boshlog.LevelNone)
+		clock = &fakeaction.FakeClock{}
 	})
 
 	Context("when bindMount is set to false", func() {
 		It("returns disk manager configured not to do bind mounting", func() {
 			expectedMountsSearcher := NewProcMountsSearcher(fs)
-			expectedMounter := NewLinuxMounter(runner, expectedMountsSearcher, 1*time.Second)
+			expectedMounter := NewLinuxMounter(runner, expectedMountsSearcher, 1*time.Second, clock)
 
-			diskManager := NewLinuxDiskManager(logger, runner, fs, false)
+			diskManager := NewLinuxDiskManager(logger, runner, fs, false, clock)
 			Expect(diskManager.GetMounter()).To(Equal(expectedMounter))
 		})
 	})
@@ -37,9 +40,9 @@ var _ = Describe("NewLinuxDiskManager", func() {
 	Context("when bindMount is set to true", func() {
 		It("returns disk manager configured to do bind mounting", func() {
 			expectedMountsSearcher := NewCmdMountsSearcher(runner)
-			expectedMounter := NewLinuxBindMounter(NewLinuxMounter(runner, expectedMountsSearcher, 1*time.Second))
+			expectedMounter := NewLinuxBindMounter(NewLinuxMounter(runner, expectedMountsSearcher, 1*time.Second, clock))
 
-			diskManager := NewLinuxDiskManager(logger, runner, fs, true)
+			diskManager := NewLinuxDiskManager(logger, runner, fs, true, clock)
 			Expect(diskManager.GetMounter()).To(Equal(expectedMounter))
 		})
 	})
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -13,18 +15,21 @@ type linuxMounter struct {
 	mountsSearcher    MountsSearcher
 	maxUnmountRetries int
 	unmountRetrySleep time.Duration
+	timeService       clock.Clock
 }
 
 func NewLinuxMounter(
 	runner boshsys.CmdRunner,
 	mountsSearcher MountsSearcher,
 	unmountRetrySleep time.Duration,
+	timeService clock.Clock,
 ) Mounter {
 	return linuxMounter{
 		runner:            runner,
 		mountsSearcher:    mountsSearcher,
 		maxUnmountRetries: 600,
 		unmountRetrySleep: unmountRetrySleep,
+		timeService:       timeService,
 	}
 }
 
@@ -100,7 +105,7 @@ func (m linuxMounter) Unmount(partitionOrMountPoint string) (bool, error) {
 	_, _, _, err = m.runner.RunCommand("umount", partitionOrMountPoint)
 
 	for i := 1; i < m.maxUnmountRetries && err != nil; i++ {
-		time.Sleep(m.unmountRetrySleep)
+		m.timeService.Sleep(m.unmountRetrySleep)
 		_, _, _, err = m.runner.RunCommand("umount", partitionOrMountPoint)
 	}
 
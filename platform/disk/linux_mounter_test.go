@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	. "github.com/cloudfoundry/bosh-agent/platform/disk"
 	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -34,13 +35,15 @@ var _ = Describe("linuxMounter", func() {
 	var (
 		runner         *fakesys.FakeCmdRunner
 		mountsSearcher *fakedisk.FakeMountsSearcher
+		timeService    *fakeaction.FakeClock
 		mounter        Mounter
 	)
 
 	BeforeEach(func() {
 		runner = fakesys.NewFakeCmdRunner()
 		mountsSearcher = &fakedisk.FakeMountsSearcher{}
-		mounter = NewLinuxMounter(runner, mountsSearcher, 1*time.Millisecond)
+		timeService = &fakeaction.FakeClock{}
+		mounter = NewLinuxMounter(runner, mountsSearcher, 1*time.Millisecond, timeService)
 	})
 
 	Describe("Mount", func() {
@@ -115,7 +118,7 @@ var _ = Describe("linuxMounter", func() {
 				},
 			}
 
-			mounter := NewLinuxMounter(runner, changingMountsSearcher, 1*time.Millisecond)
+			mounter := NewLinuxMounter(runner, changingMountsSearcher, 1*time.Millisecond, timeService)
 
 			err := mounter.RemountAsReadonly("/mnt/bar")
 			Expect(err).ToNot(HaveOccurred())
@@ -144,7 +147,7 @@ var _ = Describe("linuxMounter", func() {
 				},
 			}
 
-			mounter := NewLinuxMounter(runner, changingMountsSearcher, 1*time.Millisecond)
+			mounter := NewLinuxMounter(runner, changingMountsSearcher, 1*time.Millisecond, timeService)
 
 			err := mounter.Remount("/mnt/foo", "/mnt/bar")
 			Expect(err).ToNot(HaveOccurred())
@@ -236,6 +239,10 @@ var _ = Describe("linuxMounter", func() {
 			Expect(runner.RunCommands[0]).To(Equal([]string{"umount", "/dev/xvdb2"}))
 			Expect(runner.RunCommands[1]).To(Equal([]string{"umount", "/dev/xvdb2"}))
 			Expect(runner.RunCommands[2]).To(Equal([]string{"umount", "/dev/xvdb2"}))
+
+			Expect(timeService.SleepCallCount()).To(Equal(2))
+			Expect(timeService.SleepArgsForCall(0)).To(Equal(1 * time.Millisecond))
+			Expect(timeService.SleepArgsForCall(1)).To(Equal(1 * time.Millisecond))
 		})
 
 		It("returns error when it fails to unmount too many times", func() {
@@ -243,6 +250,9 @@ var _ = Describe("linuxMounter", func() {
 
 			_, err := mounter.Unmount("/dev/xvdb2")
 			Expect(err).To(HaveOccurred())
+
+			Expect(len(runner.RunCommands)).To(Equal(600))
+			Expect(timeService.SleepCallCount()).To(Equal(599))
 		})
 
 		It("returns error and does not try to unmount anything when searching mounts fails", func() {
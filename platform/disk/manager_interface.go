@@ -11,5 +11,6 @@ type Manager interface {
 	GetFormatter() Formatter
 	GetMounter() Mounter
 	GetMountsSearcher() MountsSearcher
+	GetCryptor() Cryptor
 	GetDiskUtil(diskPath string) boshdevutil.DeviceUtil
 }
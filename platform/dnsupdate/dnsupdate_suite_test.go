@@ -0,0 +1,13 @@
+package dnsupdate_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDNSUpdate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DNS Update Suite")
+}
@@ -0,0 +1,17 @@
+package fakes
+
+import (
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+type FakeUpdater struct {
+	UpdateCalled  bool
+	UpdateConfig  boshsettings.DNSUpdate
+	UpdateRecords map[string]string
+}
+
+func (u *FakeUpdater) Update(config boshsettings.DNSUpdate, records map[string]string) {
+	u.UpdateCalled = true
+	u.UpdateConfig = config
+	u.UpdateRecords = records
+}
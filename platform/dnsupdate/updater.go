@@ -0,0 +1,88 @@
+package dnsupdate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	defaultKeyAlgorithm = "hmac-sha256"
+	defaultTTL          = 300
+)
+
+type Updater interface {
+	// Update registers an A record for each name pointing at its IP.
+	// Failures are logged and do not stop remaining records from being sent.
+	Update(config boshsettings.DNSUpdate, records map[string]string)
+}
+
+type concreteUpdater struct {
+	runner boshsys.CmdRunner
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewConcreteUpdater(runner boshsys.CmdRunner, logger boshlog.Logger) Updater {
+	return concreteUpdater{
+		runner: runner,
+		logTag: "dnsUpdater",
+		logger: logger,
+	}
+}
+
+func (u concreteUpdater) Update(config boshsettings.DNSUpdate, records map[string]string) {
+	if config.Key == "" || len(records) == 0 {
+		return
+	}
+
+	if !u.runner.CommandExists("nsupdate") {
+		u.logger.Warn(u.logTag, "Skipping dynamic DNS update: nsupdate is not installed")
+		return
+	}
+
+	names := []string{}
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// Key material is passed on stdin to keep it out of the process list
+		_, stderr, _, err := u.runner.RunCommandWithInput(u.payload(config, name, records[name]), "nsupdate")
+		if err != nil {
+			u.logger.Warn(u.logTag, "Failed to update DNS record %s: %s %s", name, err.Error(), stderr)
+		}
+	}
+}
+
+func (u concreteUpdater) payload(config boshsettings.DNSUpdate, name, ip string) string {
+	algorithm := config.KeyAlgorithm
+	if algorithm == "" {
+		algorithm = defaultKeyAlgorithm
+	}
+
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	lines := []string{}
+
+	if config.Server != "" {
+		lines = append(lines, fmt.Sprintf("server %s", config.Server))
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("key %s:%s %s", algorithm, config.KeyName, config.Key),
+		fmt.Sprintf("update delete %s A", name),
+		fmt.Sprintf("update add %s %d A %s", name, ttl, ip),
+		"send",
+	)
+
+	return strings.Join(lines, "\n") + "\n"
+}
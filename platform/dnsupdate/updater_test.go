@@ -0,0 +1,113 @@
+package dnsupdate_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/dnsupdate"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("concreteUpdater", func() {
+	var (
+		runner  *fakesys.FakeCmdRunner
+		updater Updater
+		config  boshsettings.DNSUpdate
+		records map[string]string
+	)
+
+	BeforeEach(func() {
+		runner = fakesys.NewFakeCmdRunner()
+		runner.CommandExistsValue = true
+		updater = NewConcreteUpdater(runner, boshlog.NewLogger(boshlog.LevelNone))
+
+		config = boshsettings.DNSUpdate{
+			Server:  "fake-dns-server",
+			KeyName: "fake-key-name",
+			Key:     "fake-key",
+		}
+
+		records = map[string]string{
+			"0.job.net-b.deployment.bosh": "10.0.0.2",
+			"0.job.net-a.deployment.bosh": "10.0.0.1",
+		}
+	})
+
+	Describe("Update", func() {
+		It("sends an nsupdate request for each record", func() {
+			updater.Update(config, records)
+
+			Expect(runner.RunCommandsWithInput).To(Equal([][]string{
+				{
+					"server fake-dns-server\n" +
+						"key hmac-sha256:fake-key-name fake-key\n" +
+						"update delete 0.job.net-a.deployment.bosh A\n" +
+						"update add 0.job.net-a.deployment.bosh 300 A 10.0.0.1\n" +
+						"send\n",
+					"nsupdate",
+				},
+				{
+					"server fake-dns-server\n" +
+						"key hmac-sha256:fake-key-name fake-key\n" +
+						"update delete 0.job.net-b.deployment.bosh A\n" +
+						"update add 0.job.net-b.deployment.bosh 300 A 10.0.0.2\n" +
+						"send\n",
+					"nsupdate",
+				},
+			}))
+		})
+
+		It("uses the configured key algorithm and ttl", func() {
+			config.KeyAlgorithm = "hmac-sha512"
+			config.TTL = 60
+			config.Server = ""
+
+			updater.Update(config, map[string]string{"fake-name": "10.0.0.1"})
+
+			Expect(runner.RunCommandsWithInput).To(Equal([][]string{
+				{
+					"key hmac-sha512:fake-key-name fake-key\n" +
+						"update delete fake-name A\n" +
+						"update add fake-name 60 A 10.0.0.1\n" +
+						"send\n",
+					"nsupdate",
+				},
+			}))
+		})
+
+		It("continues with remaining records when an update fails", func() {
+			runner.AddCmdResult(
+				"server fake-dns-server\n"+
+					"key hmac-sha256:fake-key-name fake-key\n"+
+					"update delete 0.job.net-a.deployment.bosh A\n"+
+					"update add 0.job.net-a.deployment.bosh 300 A 10.0.0.1\n"+
+					"send\n nsupdate",
+				fakesys.FakeCmdResult{Error: errors.New("fake-nsupdate-err")},
+			)
+
+			updater.Update(config, records)
+
+			Expect(runner.RunCommandsWithInput).To(HaveLen(2))
+		})
+
+		It("does nothing when no key is configured", func() {
+			config.Key = ""
+
+			updater.Update(config, records)
+
+			Expect(runner.RunCommandsWithInput).To(BeEmpty())
+		})
+
+		It("does nothing when nsupdate is not installed", func() {
+			runner.CommandExistsValue = false
+
+			updater.Update(config, records)
+
+			Expect(runner.RunCommandsWithInput).To(BeEmpty())
+		})
+	})
+})
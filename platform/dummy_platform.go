@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"path"
 
+	"github.com/pivotal-golang/clock"
+
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	boshcert "github.com/cloudfoundry/bosh-agent/platform/cert"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -48,6 +51,7 @@ func NewDummyPlatform(
 	cmdRunner boshsys.CmdRunner,
 	dirProvider boshdirs.Provider,
 	devicePathResolver boshdpresolv.DevicePathResolver,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) Platform {
 	return &dummyPlatform{
@@ -59,7 +63,7 @@ func NewDummyPlatform(
 		dirProvider:        dirProvider,
 		devicePathResolver: devicePathResolver,
 		vitalsService:      boshvitals.NewService(collector, dirProvider),
-		certManager:        boshcert.NewDummyCertManager(fs, cmdRunner, 0, logger),
+		certManager:        boshcert.NewDummyCertManager(fs, cmdRunner, 0, timeService, logger),
 	}
 }
 
@@ -136,7 +140,15 @@ func (p dummyPlatform) GetCertManager() (certManager boshcert.Manager) {
 	return p.certManager
 }
 
-func (p dummyPlatform) SetupLogrotate(groupName, basePath, size string) (err error) {
+func (p dummyPlatform) SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error) {
+	return
+}
+
+func (p dummyPlatform) SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error) {
+	return
+}
+
+func (p dummyPlatform) KeepOnlyJobsLogrotate(jobNames []string) (err error) {
 	return
 }
 
@@ -144,7 +156,11 @@ func (p dummyPlatform) SetTimeWithNtpServers(servers []string) (err error) {
 	return
 }
 
-func (p dummyPlatform) SetupEphemeralDiskWithPath(devicePath string) (err error) {
+func (p dummyPlatform) SyncTimeWithNtpServers(servers []string) (err error) {
+	return
+}
+
+func (p dummyPlatform) SetupEphemeralDiskWithPath(devicePath string, fsType boshdisk.FileSystemType, disableSwap bool) (err error) {
 	return
 }
 
@@ -226,6 +242,32 @@ func (p dummyPlatform) UnmountPersistentDisk(diskSettings boshsettings.DiskSetti
 	return true, nil
 }
 
+func (p dummyPlatform) UnmountPersistentDiskMountPoint(mountPoint string) (didUnmount bool, err error) {
+	mounts, err := p.existingMounts()
+	if err != nil {
+		return false, err
+	}
+
+	var updatedMounts []mount
+	for _, mount := range mounts {
+		if mount.MountDir != mountPoint {
+			updatedMounts = append(updatedMounts, mount)
+		}
+	}
+
+	updatedMountsJSON, err := json.Marshal(updatedMounts)
+	if err != nil {
+		return false, err
+	}
+
+	err = p.fs.WriteFile(p.mountsPath(), updatedMountsJSON)
+	if err != nil {
+		return false, err
+	}
+
+	return len(updatedMounts) != len(mounts), nil
+}
+
 func (p dummyPlatform) GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string {
 	return "/dev/sdb"
 }
@@ -292,6 +334,14 @@ func (p dummyPlatform) IsPersistentDiskMountable(diskSettings boshsettings.DiskS
 	return false, nil
 }
 
+func (p dummyPlatform) SetupJobStores(jobNames []string) (err error) {
+	return
+}
+
+func (p dummyPlatform) GetPersistentDiskMountPoint(diskID string) string {
+	return p.dirProvider.StoreDir()
+}
+
 func (p dummyPlatform) StartMonit() (err error) {
 	return
 }
@@ -312,6 +362,14 @@ func (p dummyPlatform) DeleteARPEntryWithIP(ip string) error {
 	return nil
 }
 
+func (p dummyPlatform) Reboot() error {
+	return nil
+}
+
+func (p dummyPlatform) GetDefaultGatewayInterface() (string, error) {
+	return "", nil
+}
+
 func (p dummyPlatform) GetDefaultNetwork() (boshsettings.Network, error) {
 	var network boshsettings.Network
 
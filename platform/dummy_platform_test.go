@@ -3,6 +3,7 @@ package platform_test
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	"encoding/json"
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
@@ -16,6 +17,7 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	"path"
+	"time"
 )
 
 type mount struct {
@@ -52,6 +54,7 @@ func describeDummyPlatform() {
 			cmdRunner,
 			dirProvider,
 			devicePathResolver,
+			fakeclock.NewFakeClock(time.Now()),
 			logger,
 		)
 	})
@@ -2,11 +2,13 @@ package fakes
 
 import (
 	"path"
+	"sync"
 
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	fakedpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver/fakes"
 	boshcert "github.com/cloudfoundry/bosh-agent/platform/cert"
 	fakecert "github.com/cloudfoundry/bosh-agent/platform/cert/fakes"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -49,8 +51,14 @@ type FakePlatform struct {
 
 	SetTimeWithNtpServersServers []string
 
-	SetupEphemeralDiskWithPathDevicePath string
-	SetupEphemeralDiskWithPathErr        error
+	SyncTimeWithNtpServersCalled  bool
+	SyncTimeWithNtpServersServers []string
+	SyncTimeWithNtpServersErr     error
+
+	SetupEphemeralDiskWithPathDevicePath  string
+	SetupEphemeralDiskWithPathFsType      boshdisk.FileSystemType
+	SetupEphemeralDiskWithPathDisableSwap bool
+	SetupEphemeralDiskWithPathErr         error
 
 	SetupRawEphemeralDisksDevices   []boshsettings.DiskSettings
 	SetupRawEphemeralDisksErr       error
@@ -66,13 +74,22 @@ type FakePlatform struct {
 	SetupNetworkingNetworks boshsettings.Networks
 	SetupNetworkingErr      error
 
-	MountPersistentDiskCalled     bool
-	MountPersistentDiskSettings   boshsettings.DiskSettings
-	MountPersistentDiskMountPoint string
-	MountPersistentDiskErr        error
+	MountPersistentDiskCalled      bool
+	MountPersistentDiskSettings    boshsettings.DiskSettings
+	MountPersistentDiskMountPoint  string
+	MountPersistentDiskMountPoints map[string]string
+	MountPersistentDiskErr         error
+
+	// Disk IDs mapped to mount points; defaults to the store directory
+	PersistentDiskMountPoints map[string]string
 
 	UnmountPersistentDiskDidUnmount bool
 	UnmountPersistentDiskSettings   boshsettings.DiskSettings
+	UnmountPersistentDiskErr        error
+
+	UnmountPersistentDiskMountPointPath       string
+	UnmountPersistentDiskMountPointDidUnmount bool
+	UnmountPersistentDiskMountPointErr        error
 
 	GetFileContentsFromCDROMPath        string
 	GetFileContentsFromCDROMContents    []byte
@@ -97,6 +114,9 @@ type FakePlatform struct {
 	IsPersistentDiskMountableResult bool
 	IsPersistentDiskMountableErr    error
 
+	SetupJobStoresJobNames []string
+	SetupJobStoresErr      error
+
 	IsMountPointPath          string
 	IsMountPointPartitionPath string
 	IsMountPointResult        bool
@@ -119,11 +139,15 @@ type FakePlatform struct {
 	GetDefaultNetworkNetwork boshsettings.Network
 	GetDefaultNetworkErr     error
 
+	GetDefaultGatewayInterfaceName string
+	GetDefaultGatewayInterfaceErr  error
+
 	GetConfiguredNetworkInterfacesInterfaces []string
 	GetConfiguredNetworkInterfacesErr        error
 
 	LastIPDeletedFromARP    string
 	DeleteARPEntryWithIPErr error
+	DeletedARPEntryIPs      []string
 
 	certManager boshcert.Manager
 
@@ -132,6 +156,11 @@ type FakePlatform struct {
 
 	SetupRootDiskCalledTimes int
 	SetupRootDiskError       error
+
+	// Reboot is called from action goroutine
+	rebootLock   sync.Mutex
+	rebootCalled bool
+	RebootErr    error
 }
 
 func NewFakePlatform() (platform *FakePlatform) {
@@ -255,7 +284,15 @@ func (p *FakePlatform) GetCertManager() (certManager boshcert.Manager) {
 	return p.certManager
 }
 
-func (p *FakePlatform) SetupLogrotate(groupName, basePath, size string) (err error) {
+func (p *FakePlatform) SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error) {
+	return
+}
+
+func (p *FakePlatform) SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error) {
+	return
+}
+
+func (p *FakePlatform) KeepOnlyJobsLogrotate(jobNames []string) (err error) {
 	return
 }
 
@@ -264,8 +301,16 @@ func (p *FakePlatform) SetTimeWithNtpServers(servers []string) (err error) {
 	return
 }
 
-func (p *FakePlatform) SetupEphemeralDiskWithPath(devicePath string) (err error) {
+func (p *FakePlatform) SyncTimeWithNtpServers(servers []string) (err error) {
+	p.SyncTimeWithNtpServersCalled = true
+	p.SyncTimeWithNtpServersServers = servers
+	return p.SyncTimeWithNtpServersErr
+}
+
+func (p *FakePlatform) SetupEphemeralDiskWithPath(devicePath string, fsType boshdisk.FileSystemType, disableSwap bool) (err error) {
 	p.SetupEphemeralDiskWithPathDevicePath = devicePath
+	p.SetupEphemeralDiskWithPathFsType = fsType
+	p.SetupEphemeralDiskWithPathDisableSwap = disableSwap
 	return p.SetupEphemeralDiskWithPathErr
 }
 
@@ -289,15 +334,25 @@ func (p *FakePlatform) MountPersistentDisk(diskSettings boshsettings.DiskSetting
 	p.MountPersistentDiskCalled = true
 	p.MountPersistentDiskSettings = diskSettings
 	p.MountPersistentDiskMountPoint = mountPoint
+	if p.MountPersistentDiskMountPoints == nil {
+		p.MountPersistentDiskMountPoints = map[string]string{}
+	}
+	p.MountPersistentDiskMountPoints[diskSettings.ID] = mountPoint
 	return p.MountPersistentDiskErr
 }
 
 func (p *FakePlatform) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error) {
 	p.UnmountPersistentDiskSettings = diskSettings
 	didUnmount = p.UnmountPersistentDiskDidUnmount
+	err = p.UnmountPersistentDiskErr
 	return
 }
 
+func (p *FakePlatform) UnmountPersistentDiskMountPoint(mountPoint string) (didUnmount bool, err error) {
+	p.UnmountPersistentDiskMountPointPath = mountPoint
+	return p.UnmountPersistentDiskMountPointDidUnmount, p.UnmountPersistentDiskMountPointErr
+}
+
 func (p *FakePlatform) GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string {
 	p.GetEphemeralDiskPathCalled = true
 	p.GetEphemeralDiskPathSettings = diskSettings
@@ -360,6 +415,19 @@ func (p *FakePlatform) SetIsPersistentDiskMountable(isPartitioned bool, err erro
 	p.IsPersistentDiskMountableErr = err
 }
 
+func (p *FakePlatform) SetupJobStores(jobNames []string) (err error) {
+	p.SetupJobStoresJobNames = jobNames
+	return p.SetupJobStoresErr
+}
+
+func (p *FakePlatform) GetPersistentDiskMountPoint(diskID string) string {
+	if mountPoint, found := p.PersistentDiskMountPoints[diskID]; found {
+		return mountPoint
+	}
+
+	return p.GetDirProvider().StoreDir()
+}
+
 func (p *FakePlatform) IsPersistentDiskMountable(diskSettings boshsettings.DiskSettings) (bool, error) {
 	return p.IsPersistentDiskMountableResult, p.IsPersistentDiskMountableErr
 }
@@ -382,9 +450,25 @@ func (p *FakePlatform) GetMonitCredentials() (username, password string, err err
 
 func (p *FakePlatform) DeleteARPEntryWithIP(ip string) error {
 	p.LastIPDeletedFromARP = ip
+	p.DeletedARPEntryIPs = append(p.DeletedARPEntryIPs, ip)
 	return p.DeleteARPEntryWithIPErr
 }
 
+func (p *FakePlatform) Reboot() error {
+	p.rebootLock.Lock()
+	defer p.rebootLock.Unlock()
+
+	p.rebootCalled = true
+	return p.RebootErr
+}
+
+func (p *FakePlatform) RebootCalled() bool {
+	p.rebootLock.Lock()
+	defer p.rebootLock.Unlock()
+
+	return p.rebootCalled
+}
+
 func (p *FakePlatform) PrepareForNetworkingChange() error {
 	p.PrepareForNetworkingChangeCalled = true
 	return p.PrepareForNetworkingChangeErr
@@ -394,6 +478,10 @@ func (p *FakePlatform) GetDefaultNetwork() (boshsettings.Network, error) {
 	return p.GetDefaultNetworkNetwork, p.GetDefaultNetworkErr
 }
 
+func (p *FakePlatform) GetDefaultGatewayInterface() (string, error) {
+	return p.GetDefaultGatewayInterfaceName, p.GetDefaultGatewayInterfaceErr
+}
+
 func (p *FakePlatform) GetHostPublicKey() (string, error) {
 	return p.GetHostPublicKeyValue, p.GetHostPublicKeyError
 }
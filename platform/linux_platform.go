@@ -38,12 +38,19 @@ const (
 	tmpDirPermissions      = os.FileMode(0755) // 0755 to make sure that vcap user can use new temp dir
 
 	sshDirPermissions          = os.FileMode(0700)
+	sharedSSHDirPermissions    = os.FileMode(0755)
 	sshAuthKeysFilePermissions = os.FileMode(0600)
 
 	minRootEphemeralSpaceInBytes = uint64(1024 * 1024 * 1024)
 	maxFdiskPartitionSize        = uint64(2 * 1024 * 1024 * 1024 * 1024)
 )
 
+// Used when settings do not specify persistent disk mount options
+var defaultPersistentDiskMountOptions = []string{"defaults"}
+
+// Each mount option must look like `name` or `name=value`, e.g. `noatime` or `commit=60`
+var validMountOptionRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*(=[A-Za-z0-9_.:/-]+)?$`)
+
 type LinuxOptions struct {
 	// When set to true loop back device
 	// is not going to be overlayed over /tmp to limit /tmp dir size
@@ -61,6 +68,11 @@ type LinuxOptions struct {
 	// ephemeral disk
 	CreatePartitionIfNoEphemeralDisk bool
 
+	// When set to true and no ephemeral disk is mounted, the agent will create
+	// a file on the root partition, sized from the available root space,
+	// and mount it through a loop device as the ephemeral disk
+	CreateLoopbackFileIfNoEphemeralDisk bool
+
 	// When set to true the agent will skip both root and ephemeral disk partitioning
 	SkipDiskSetup bool
 
@@ -70,6 +82,33 @@ type LinuxOptions struct {
 
 	// Device prexix when using virtio (defaults to 'virtio')
 	VirtioDevicePrefix string
+
+	// Disk device node may appear some time after disk is attached;
+	// device path resolvers wait this long for it. Each resolution strategy
+	// keeps its own wait when 0; '' strategy then expects device to be present
+	DevicePathResolutionWaitSeconds int
+
+	// When set store directory of each applied job is bind-mounted from the
+	// persistent disk to <JobStoreMountsDir>/<job>, e.g. into a chroot of jobs
+	// that cannot see the store directory; disabled when empty
+	JobStoreMountsDir string
+
+	// When set to true each persistent disk is mounted at <base>/stores/<disk id>
+	// instead of the store directory so that multiple disks can be mounted at once
+	MountPersistentDisksByID bool
+
+	// When set to true networks are never configured through DHCP;
+	// every network must specify its IP, netmask and gateway
+	DisableDHCP bool
+
+	// DHCP client used for dynamic networks;
+	// possible values: dhclient, systemd-networkd, '' (detected from the image)
+	DHCPClient string
+
+	// Rendered as resolv.conf options line, e.g. ["timeout:2", "attempts:2"];
+	// line is left out when empty. Only applies to static and preconfigured
+	// networks since DHCP clients write their own resolv.conf
+	ResolvConfOptions []string
 }
 
 type linux struct {
@@ -86,6 +125,7 @@ type linux struct {
 	certManager            boshcert.Manager
 	monitRetryStrategy     boshretry.RetryStrategy
 	devicePathResolver     boshdpresolv.DevicePathResolver
+	ephemeralDiskResolver  boshdpresolv.DevicePathResolver
 	diskScanDuration       time.Duration
 	options                LinuxOptions
 	state                  *BootstrapState
@@ -107,6 +147,7 @@ func NewLinuxPlatform(
 	certManager boshcert.Manager,
 	monitRetryStrategy boshretry.RetryStrategy,
 	devicePathResolver boshdpresolv.DevicePathResolver,
+	ephemeralDiskResolver boshdpresolv.DevicePathResolver,
 	diskScanDuration time.Duration,
 	state *BootstrapState,
 	options LinuxOptions,
@@ -127,6 +168,7 @@ func NewLinuxPlatform(
 		certManager:            certManager,
 		monitRetryStrategy:     monitRetryStrategy,
 		devicePathResolver:     devicePathResolver,
+		ephemeralDiskResolver:  ephemeralDiskResolver,
 		diskScanDuration:       diskScanDuration,
 		state:                  state,
 		options:                options,
@@ -179,7 +221,15 @@ func (p linux) GetDevicePathResolver() (devicePathResolver boshdpresolv.DevicePa
 }
 
 func (p linux) SetupNetworking(networks boshsettings.Networks) (err error) {
-	return p.netManager.SetupNetworking(networks, nil)
+	result, err := p.netManager.SetupNetworking(networks, nil)
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info(logTag, "Set up networking with static interfaces %v, dhcp interfaces %v, dns servers %v (restarted: %t)",
+		result.StaticInterfaces, result.DHCPInterfaces, result.DNSServers, result.Restarted)
+
+	return nil
 }
 
 func (p linux) GetConfiguredNetworkInterfaces() ([]string, error) {
@@ -334,17 +384,28 @@ func (p linux) SetupSSH(publicKey, username string) error {
 		return bosherr.WrapError(err, "Finding home dir for user")
 	}
 
-	sshPath := path.Join(homeDir, ".ssh")
-	err = p.fs.MkdirAll(sshPath, sshDirPermissions)
-	if err != nil {
-		return bosherr.WrapError(err, "Making ssh directory")
-	}
-	err = p.fs.Chown(sshPath, username)
-	if err != nil {
-		return bosherr.WrapError(err, "Chowning ssh directory")
+	authKeysPath := p.dirProvider.AuthorizedKeysPath(username, homeDir)
+
+	sshPath := path.Dir(authKeysPath)
+
+	// Directories outside of home dir, e.g. /etc/ssh/keys, are shared
+	// with other users and sshd hence they stay owned by root
+	if strings.HasPrefix(sshPath, path.Clean(homeDir)+"/") {
+		err = p.fs.MkdirAll(sshPath, sshDirPermissions)
+		if err != nil {
+			return bosherr.WrapError(err, "Making ssh directory")
+		}
+		err = p.fs.Chown(sshPath, username)
+		if err != nil {
+			return bosherr.WrapError(err, "Chowning ssh directory")
+		}
+	} else {
+		err = p.fs.MkdirAll(sshPath, sharedSSHDirPermissions)
+		if err != nil {
+			return bosherr.WrapError(err, "Making ssh directory")
+		}
 	}
 
-	authKeysPath := path.Join(sshPath, "authorized_keys")
 	err = p.fs.WriteFileString(authKeysPath, publicKey)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating authorized_keys file")
@@ -372,20 +433,22 @@ func (p linux) SetUserPassword(user, encryptedPwd string) (err error) {
 
 func (p linux) SetupHostname(hostname string) error {
 	if !p.state.Linux.HostsConfigured {
-		_, _, _, err := p.cmdRunner.RunCommand("hostname", hostname)
-		if err != nil {
-			return bosherr.WrapError(err, "Setting hostname")
-		}
+		if !p.isHostnameSet(hostname) {
+			_, _, _, err := p.cmdRunner.RunCommand("hostname", hostname)
+			if err != nil {
+				return bosherr.WrapError(err, "Setting hostname")
+			}
 
-		err = p.fs.WriteFileString("/etc/hostname", hostname)
-		if err != nil {
-			return bosherr.WrapError(err, "Writing to /etc/hostname")
+			err = p.fs.WriteFileString("/etc/hostname", hostname)
+			if err != nil {
+				return bosherr.WrapError(err, "Writing to /etc/hostname")
+			}
 		}
 
 		buffer := bytes.NewBuffer([]byte{})
 		t := template.Must(template.New("etc-hosts").Parse(etcHostsTemplate))
 
-		err = t.Execute(buffer, hostname)
+		err := t.Execute(buffer, hostname)
 		if err != nil {
 			return bosherr.WrapError(err, "Generating config from template")
 		}
@@ -405,6 +468,22 @@ func (p linux) SetupHostname(hostname string) error {
 	return nil
 }
 
+// isHostnameSet checks both running and persisted hostname,
+// e.g. when image or cloud-init already applied hostname from metadata
+func (p linux) isHostnameSet(hostname string) bool {
+	runningHostname, err := p.fs.ReadFileString("/proc/sys/kernel/hostname")
+	if err != nil || strings.TrimSpace(runningHostname) != hostname {
+		return false
+	}
+
+	etcHostname, err := p.fs.ReadFileString("/etc/hostname")
+	if err != nil || strings.TrimSpace(etcHostname) != hostname {
+		return false
+	}
+
+	return true
+}
+
 const etcHostsTemplate = `127.0.0.1 localhost {{ . }}
 
 # The following lines are desirable for IPv6 capable hosts
@@ -416,16 +495,30 @@ ff02::2 ip6-allrouters
 ff02::3 ip6-allhosts
 `
 
-func (p linux) SetupLogrotate(groupName, basePath, size string) (err error) {
+// SetupLogrotate writes logrotate config for the group. When jobNames is nil
+// all log directories are rotated; otherwise only top-level log files and
+// logs of given jobs are, since logrotate rejects log files that are also
+// matched by another config (e.g. one written by SetupJobLogrotate).
+func (p linux) SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error) {
 	buffer := bytes.NewBuffer([]byte{})
 	t := template.Must(template.New("logrotate-d-config").Parse(etcLogrotateDTemplate))
 
 	type logrotateArgs struct {
 		BasePath string
+		Globs    []string
 		Size     string
 	}
 
-	err = t.Execute(buffer, logrotateArgs{basePath, size})
+	globs := []string{"*.log", ".*.log", "*/*.log", "*/.*.log", "*/*/*.log", "*/*/.*.log"}
+
+	if jobNames != nil {
+		globs = []string{"*.log", ".*.log"}
+		for _, jobName := range jobNames {
+			globs = append(globs, jobLogrotateGlobs(jobName)...)
+		}
+	}
+
+	err = t.Execute(buffer, logrotateArgs{basePath, globs, size})
 	if err != nil {
 		err = bosherr.WrapError(err, "Generating logrotate config")
 		return
@@ -440,11 +533,76 @@ func (p linux) SetupLogrotate(groupName, basePath, size string) (err error) {
 	return
 }
 
+// SetupJobLogrotate writes logrotate config for logs of a single job.
+// Group config must not include the job (see SetupLogrotate).
+func (p linux) SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error) {
+	buffer := bytes.NewBuffer([]byte{})
+	t := template.Must(template.New("logrotate-d-job-config").Parse(etcLogrotateDJobTemplate))
+
+	type jobLogrotateArgs struct {
+		BasePath string
+		Globs    []string
+		Size     string
+		Rotate   int
+	}
+
+	err = t.Execute(buffer, jobLogrotateArgs{basePath, jobLogrotateGlobs(jobName), size, rotate})
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Generating logrotate config for job %s", jobName)
+		return
+	}
+
+	err = p.fs.WriteFile(path.Join("/etc/logrotate.d", jobLogrotatePrefix+jobName), buffer.Bytes())
+	if err != nil {
+		err = bosherr.WrapError(err, "Writing to /etc/logrotate.d")
+		return
+	}
+
+	return
+}
+
+// KeepOnlyJobsLogrotate removes logrotate config of jobs that are not given
+func (p linux) KeepOnlyJobsLogrotate(jobNames []string) (err error) {
+	configPaths, err := p.fs.Glob(path.Join("/etc/logrotate.d", jobLogrotatePrefix+"*"))
+	if err != nil {
+		err = bosherr.WrapError(err, "Finding job logrotate configs")
+		return
+	}
+
+	keep := map[string]bool{}
+	for _, jobName := range jobNames {
+		keep[path.Join("/etc/logrotate.d", jobLogrotatePrefix+jobName)] = true
+	}
+
+	for _, configPath := range configPaths {
+		if keep[configPath] {
+			continue
+		}
+
+		err = p.fs.RemoveAll(configPath)
+		if err != nil {
+			err = bosherr.WrapErrorf(err, "Removing job logrotate config %s", configPath)
+			return
+		}
+	}
+
+	return
+}
+
+func jobLogrotateGlobs(jobName string) []string {
+	return []string{
+		jobName + "/*.log",
+		jobName + "/.*.log",
+		jobName + "/*/*.log",
+		jobName + "/*/.*.log",
+	}
+}
+
 // Logrotate config file - /etc/logrotate.d/<group-name>
 // Stemcell stage logrotate_config configures logrotate to run every hour
 const etcLogrotateDTemplate = `# Generated by bosh-agent
 
-{{ .BasePath }}/data/sys/log/*.log {{ .BasePath }}/data/sys/log/.*.log {{ .BasePath }}/data/sys/log/*/*.log {{ .BasePath }}/data/sys/log/*/.*.log {{ .BasePath }}/data/sys/log/*/*/*.log {{ .BasePath }}/data/sys/log/*/*/.*.log {
+{{ range .Globs }}{{ $.BasePath }}/data/sys/log/{{ . }} {{ end }}{
   missingok
   rotate 7
   compress
@@ -454,8 +612,23 @@ const etcLogrotateDTemplate = `# Generated by bosh-agent
 }
 `
 
+const jobLogrotatePrefix = "bosh-job-"
+
+// Logrotate config file - /etc/logrotate.d/bosh-job-<job-name>
+const etcLogrotateDJobTemplate = `# Generated by bosh-agent
+
+{{ range .Globs }}{{ $.BasePath }}/data/sys/log/{{ . }} {{ end }}{
+  missingok
+  rotate {{ .Rotate }}
+  compress
+  delaycompress
+  copytruncate
+  size={{ .Size }}
+}
+`
+
 func (p linux) SetTimeWithNtpServers(servers []string) (err error) {
-	serversFilePath := path.Join(p.dirProvider.BaseDir(), "/bosh/etc/ntpserver")
+	serversFilePath := p.ntpServersFilePath()
 	if len(servers) == 0 {
 		return
 	}
@@ -471,11 +644,50 @@ func (p linux) SetTimeWithNtpServers(servers []string) (err error) {
 	return
 }
 
-func (p linux) SetupEphemeralDiskWithPath(realPath string) error {
+// SyncTimeWithNtpServers is used when NTP servers change at runtime;
+// unlike bootstrap an empty list removes previously configured servers
+// and failing to sync is reported back. Change is temporary: settings are
+// not updated, so bootstrap after restart or settings reload writes
+// servers from settings again (keeping runtime ones only when settings have none)
+func (p linux) SyncTimeWithNtpServers(servers []string) error {
+	serversFilePath := p.ntpServersFilePath()
+
+	if len(servers) == 0 {
+		err := p.fs.RemoveAll(serversFilePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing %s", serversFilePath)
+		}
+
+		return nil
+	}
+
+	err := p.fs.WriteFileString(serversFilePath, strings.Join(servers, " "))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing to %s", serversFilePath)
+	}
+
+	_, stderr, _, err := p.cmdRunner.RunCommand("ntpdate")
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Shelling out to ntpdate: %s", stderr)
+	}
+
+	return nil
+}
+
+func (p linux) ntpServersFilePath() string {
+	return path.Join(p.dirProvider.BaseDir(), "/bosh/etc/ntpserver")
+}
+
+func (p linux) SetupEphemeralDiskWithPath(realPath string, fsType boshdisk.FileSystemType, disableSwap bool) error {
 	if p.options.SkipDiskSetup {
 		return nil
 	}
 
+	dataFsType, err := p.resolveFileSystemType(fsType)
+	if err != nil {
+		return err
+	}
+
 	p.logger.Info(logTag, "Setting up ephemeral disk...")
 	mountPoint := p.dirProvider.DataDir()
 
@@ -499,6 +711,10 @@ func (p linux) SetupEphemeralDiskWithPath(realPath string) error {
 		return bosherr.WrapError(err, "Creating data dir")
 	}
 
+	if realPath == "" && !p.options.CreatePartitionIfNoEphemeralDisk && p.options.CreateLoopbackFileIfNoEphemeralDisk {
+		return p.setupLoopbackEphemeralDisk(mountPoint, dataFsType)
+	}
+
 	var swapPartitionPath, dataPartitionPath string
 
 	// Agent can only setup ephemeral data directory either on ephemeral device
@@ -511,33 +727,38 @@ func (p linux) SetupEphemeralDiskWithPath(realPath string) error {
 			return bosherr.Error("No ephemeral disk found, cannot use root partition as ephemeral disk")
 		}
 
-		swapPartitionPath, dataPartitionPath, err = p.createEphemeralPartitionsOnRootDevice()
+		swapPartitionPath, dataPartitionPath, err = p.createEphemeralPartitionsOnRootDevice(disableSwap)
 		if err != nil {
 			return bosherr.WrapError(err, "Creating ephemeral partitions on root device")
 		}
 	} else {
-		swapPartitionPath, dataPartitionPath, err = p.partitionEphemeralDisk(realPath)
+		swapPartitionPath, dataPartitionPath, err = p.partitionEphemeralDisk(realPath, disableSwap)
 		if err != nil {
 			return bosherr.WrapError(err, "Partitioning ephemeral disk")
 		}
 	}
 
-	p.logger.Info(logTag, "Formatting `%s' as swap", swapPartitionPath)
-	err = p.diskManager.GetFormatter().Format(swapPartitionPath, boshdisk.FileSystemSwap)
-	if err != nil {
-		return bosherr.WrapError(err, "Formatting swap")
+	// Data partition takes up space of swap partition when swap is disabled
+	if !disableSwap {
+		p.logger.Info(logTag, "Formatting `%s' as swap", swapPartitionPath)
+		err = p.diskManager.GetFormatter().Format(swapPartitionPath, boshdisk.FileSystemSwap)
+		if err != nil {
+			return bosherr.WrapError(err, "Formatting swap")
+		}
 	}
 
-	p.logger.Info(logTag, "Formatting `%s' as ext4", dataPartitionPath)
-	err = p.diskManager.GetFormatter().Format(dataPartitionPath, boshdisk.FileSystemExt4)
+	p.logger.Info(logTag, "Formatting `%s' as %s", dataPartitionPath, dataFsType)
+	err = p.diskManager.GetFormatter().Format(dataPartitionPath, dataFsType)
 	if err != nil {
-		return bosherr.WrapError(err, "Formatting data partition with ext4")
+		return bosherr.WrapErrorf(err, "Formatting data partition with %s", dataFsType)
 	}
 
-	p.logger.Info(logTag, "Mounting `%s' as swap", swapPartitionPath)
-	err = p.diskManager.GetMounter().SwapOn(swapPartitionPath)
-	if err != nil {
-		return bosherr.WrapError(err, "Mounting swap")
+	if !disableSwap {
+		p.logger.Info(logTag, "Mounting `%s' as swap", swapPartitionPath)
+		err = p.diskManager.GetMounter().SwapOn(swapPartitionPath)
+		if err != nil {
+			return bosherr.WrapError(err, "Mounting swap")
+		}
 	}
 
 	p.logger.Info(logTag, "Mounting `%s' at `%s'", dataPartitionPath, mountPoint)
@@ -550,7 +771,7 @@ func (p linux) SetupEphemeralDiskWithPath(realPath string) error {
 }
 
 func (p linux) SetupRawEphemeralDisks(devices []boshsettings.DiskSettings) (err error) {
-	if p.options.SkipDiskSetup {
+	if p.options.SkipDiskSetup || len(devices) == 0 {
 		return nil
 	}
 
@@ -747,21 +968,58 @@ func (p linux) changeTmpDirPermissions(path string) error {
 }
 
 func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountPoint string) error {
+	err := p.mountPersistentDisk(diskSetting, mountPoint)
+	if err != nil {
+		return err
+	}
+
+	if p.options.JobStoreMountsDir != "" && mountPoint == p.dirProvider.StoreDir() {
+		err = p.jobStoreLayout().Restore()
+		if err != nil {
+			return bosherr.WrapError(err, "Restoring job store bind mounts")
+		}
+	}
+
+	return nil
+}
+
+func (p linux) mountPersistentDisk(diskSetting boshsettings.DiskSettings, mountPoint string) error {
 	p.logger.Debug(logTag, "Mounting persistent disk %+v at %s", diskSetting, mountPoint)
 
+	mountOptions, err := p.persistentDiskMountOptions(diskSetting.MountOptions)
+	if err != nil {
+		return err
+	}
+
 	realPath, _, err := p.devicePathResolver.GetRealDevicePath(diskSetting)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting real device path")
 	}
 
+	encrypted, err := p.diskManager.GetCryptor().IsEncrypted(realPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Checking whether persistent disk is encrypted")
+	}
+
+	if encrypted {
+		realPath, err = p.openEncryptedPersistentDisk(realPath, diskSetting.EncryptionPassphrase)
+		if err != nil {
+			return err
+		}
+	}
+
 	devicePath, isMountPoint, err := p.IsMountPoint(mountPoint)
 	if err != nil {
 		return bosherr.WrapError(err, "Checking mount point")
 	}
 	p.logger.Info(logTag, "realPath = %s, devicePath = %s, isMountPoint = %s", realPath, devicePath, isMountPoint)
 
+	diskPath := realPath
 	partitionPath := realPath + "1"
-	if strings.Contains(realPath, "/dev/mapper/") {
+	if encrypted {
+		// Decrypted LUKS volume holds filesystem without partition table
+		partitionPath = realPath
+	} else if strings.Contains(realPath, "/dev/mapper/") {
 		partitionPath = realPath + "-part1"
 	}
 
@@ -774,39 +1032,41 @@ func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountP
 		mountPoint = p.dirProvider.StoreMigrationDir()
 	}
 
-	err = p.fs.MkdirAll(mountPoint, persistentDiskPermissions)
+	mountedPath := partitionPath
+	if p.options.UsePreformattedPersistentDisk {
+		mountedPath = realPath
+	}
+
+	existingMountPoint, isMounted, err := p.findMountPoint(mountedPath)
 	if err != nil {
-		return bosherr.WrapErrorf(err, "Creating directory %s", mountPoint)
+		return bosherr.WrapError(err, "Checking whether persistent disk is mounted")
 	}
 
-	if !p.options.UsePreformattedPersistentDisk {
-		partitions := []boshdisk.Partition{
-			{Type: boshdisk.PartitionTypeLinux},
+	if isMounted {
+		if existingMountPoint == mountPoint {
+			p.logger.Info(logTag, "device: %s is already mounted on %s, skipping mounting", mountedPath, mountPoint)
+			return nil
 		}
 
-		diskSize, err := p.diskManager.GetDiskUtil(realPath).GetBlockDeviceSize()
+		return bosherr.Errorf("Persistent disk '%s' is already mounted at '%s' instead of '%s'", mountedPath, existingMountPoint, mountPoint)
+	}
 
-		p.logger.Debug(logTag, "Persistent disk size to be partitioned is: %d, and error is: %v", diskSize, err)
+	err = p.fs.MkdirAll(mountPoint, persistentDiskPermissions)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory %s", mountPoint)
+	}
 
-		if err != nil || diskSize < maxFdiskPartitionSize {
-			p.logger.Debug(logTag, "fdisk partitioner was chosen")
-			err = p.diskManager.GetPartitioner().Partition(realPath, partitions)
-		} else {
-			p.logger.Debug(logTag, "parted partitioner was chosen")
-			err = p.diskManager.GetPartedPartitioner().Partition(realPath, partitions)
+	if !p.options.UsePreformattedPersistentDisk {
+		if !encrypted {
+			err = p.partitionPersistentDisk(realPath)
+			if err != nil {
+				return err
+			}
 		}
 
+		persistentDiskFS, err := p.resolveFileSystemType(diskSetting.FileSystemType)
 		if err != nil {
-			return bosherr.WrapError(err, "Partitioning disk")
-		}
-
-		persistentDiskFS := diskSetting.FileSystemType
-		switch persistentDiskFS {
-		case boshdisk.FileSystemExt4, boshdisk.FileSystemXFS:
-		case boshdisk.FileSystemDefault:
-			persistentDiskFS = boshdisk.FileSystemExt4
-		default:
-			return bosherr.Error(fmt.Sprintf(`The filesystem type "%s" is not supported`, diskSetting.FileSystemType))
+			return err
 		}
 
 		err = p.diskManager.GetFormatter().Format(partitionPath, persistentDiskFS)
@@ -817,14 +1077,139 @@ func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountP
 		realPath = partitionPath
 	}
 
-	err = p.diskManager.GetMounter().Mount(realPath, mountPoint)
+	err = p.diskManager.GetMounter().Mount(realPath, mountPoint, "-o", strings.Join(mountOptions, ","))
 	if err != nil {
 		return bosherr.WrapError(err, "Mounting partition")
 	}
 
+	if !p.options.UsePreformattedPersistentDisk && !encrypted {
+		err = p.growPersistentDisk(diskPath, partitionPath, mountPoint, diskSetting.FileSystemType)
+		if err != nil {
+			return bosherr.WrapError(err, "Growing persistent disk")
+		}
+	}
+
 	return nil
 }
 
+func (p linux) partitionPersistentDisk(realPath string) error {
+	partitions := []boshdisk.Partition{
+		{Type: boshdisk.PartitionTypeLinux},
+	}
+
+	diskSize, err := p.diskManager.GetDiskUtil(realPath).GetBlockDeviceSize()
+
+	p.logger.Debug(logTag, "Persistent disk size to be partitioned is: %d, and error is: %v", diskSize, err)
+
+	if err != nil || diskSize < maxFdiskPartitionSize {
+		p.logger.Debug(logTag, "fdisk partitioner was chosen")
+		err = p.diskManager.GetPartitioner().Partition(realPath, partitions)
+	} else {
+		p.logger.Debug(logTag, "parted partitioner was chosen")
+		err = p.diskManager.GetPartedPartitioner().Partition(realPath, partitions)
+	}
+
+	if err != nil {
+		return bosherr.WrapError(err, "Partitioning disk")
+	}
+
+	return nil
+}
+
+// openEncryptedPersistentDisk returns path of decrypted device
+// that is then formatted and mounted instead of the LUKS volume;
+// device that is already open (e.g. mounted before agent restart) is reused
+func (p linux) openEncryptedPersistentDisk(realPath string, passphrase boshsettings.Secret) (string, error) {
+	mappedPath, isOpen := p.diskManager.GetCryptor().MappedPath(realPath)
+	if isOpen {
+		p.logger.Info(logTag, "Encrypted persistent disk '%s' is already open at '%s'", realPath, mappedPath)
+		return mappedPath, nil
+	}
+
+	if passphrase == "" {
+		return "", bosherr.Errorf("Persistent disk '%s' is encrypted but no passphrase is configured", realPath)
+	}
+
+	p.logger.Info(logTag, "Opening encrypted persistent disk '%s'", realPath)
+
+	mappedPath, err := p.diskManager.GetCryptor().Open(realPath, string(passphrase))
+	if err != nil {
+		return "", bosherr.WrapError(err, "Opening encrypted persistent disk")
+	}
+
+	return mappedPath, nil
+}
+
+// growPersistentDisk expands the persistent disk partition and its filesystem
+// to fill the underlying device, e.g. after the volume was resized by the IaaS.
+// It is a no-op if the partition already spans the whole device.
+func (p linux) growPersistentDisk(devicePath, partitionPath, mountPoint string, fsType boshdisk.FileSystemType) error {
+	if !p.cmdRunner.CommandExists("growpart") {
+		p.logger.Info(logTag, "The program 'growpart' is not installed, persistent disk cannot be grown")
+		return nil
+	}
+
+	stdout, _, _, err := p.cmdRunner.RunCommand("growpart", devicePath, "1")
+	if err != nil {
+		if strings.Contains(stdout, "NOCHANGE") {
+			p.logger.Debug(logTag, "Persistent disk partition `%s' already uses all available space", partitionPath)
+			return nil
+		}
+		return bosherr.WrapError(err, "Shelling out to growpart")
+	}
+
+	fsType, err = p.resolveFileSystemType(fsType)
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info(logTag, "Resizing %s filesystem on `%s'", fsType, partitionPath)
+
+	if fsType == boshdisk.FileSystemXFS {
+		_, _, _, err = p.cmdRunner.RunCommand("xfs_growfs", mountPoint)
+		if err != nil {
+			return bosherr.WrapError(err, "Shelling out to xfs_growfs")
+		}
+		return nil
+	}
+
+	_, _, _, err = p.cmdRunner.RunCommand("resize2fs", "-f", partitionPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Shelling out to resize2fs")
+	}
+
+	return nil
+}
+
+func (p linux) findMountPoint(partitionPath string) (string, bool, error) {
+	mounts, err := p.diskManager.GetMountsSearcher().SearchMounts()
+	if err != nil {
+		return "", false, bosherr.WrapError(err, "Searching mounts")
+	}
+
+	for _, mount := range mounts {
+		if mount.PartitionPath == partitionPath {
+			return mount.MountPoint, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func (p linux) persistentDiskMountOptions(options []string) ([]string, error) {
+	if len(options) == 0 {
+		return defaultPersistentDiskMountOptions, nil
+	}
+
+	for _, option := range options {
+		if !validMountOptionRegexp.MatchString(option) {
+			return nil, bosherr.Errorf("Invalid persistent disk mount option '%s'", option)
+		}
+	}
+
+	return options, nil
+}
+
 func (p linux) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (bool, error) {
 	p.logger.Debug(logTag, "Unmounting persistent disk %+v", diskSettings)
 
@@ -836,6 +1221,22 @@ func (p linux) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (bo
 		return false, bosherr.WrapError(err, "Getting real device path")
 	}
 
+	cryptor := p.diskManager.GetCryptor()
+
+	if mappedPath, isOpen := cryptor.MappedPath(realPath); isOpen {
+		didUnmount, err := p.unmountPersistentDiskDevice(mappedPath)
+		if err != nil || !didUnmount {
+			return didUnmount, err
+		}
+
+		err = cryptor.Close(realPath)
+		if err != nil {
+			return false, bosherr.WrapError(err, "Closing encrypted persistent disk")
+		}
+
+		return true, nil
+	}
+
 	if !p.options.UsePreformattedPersistentDisk {
 		if strings.Contains(realPath, "/dev/mapper/") {
 			realPath = realPath + "-part1"
@@ -844,12 +1245,46 @@ func (p linux) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (bo
 		}
 	}
 
-	return p.diskManager.GetMounter().Unmount(realPath)
+	return p.unmountPersistentDiskDevice(realPath)
+}
+
+// UnmountPersistentDiskMountPoint leaves encrypted device mapping open
+// since it is not known which device is mapped without resolving it
+func (p linux) UnmountPersistentDiskMountPoint(mountPoint string) (bool, error) {
+	p.logger.Debug(logTag, "Unmounting persistent disk at %s", mountPoint)
+
+	return p.unmountPersistentDiskDevice(mountPoint)
+}
+
+func (p linux) unmountPersistentDiskDevice(realPath string) (bool, error) {
+	if p.options.JobStoreMountsDir == "" {
+		return p.diskManager.GetMounter().Unmount(realPath)
+	}
+
+	// Bind mounts would keep persistent disk busy
+	err := p.jobStoreLayout().Release()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Releasing job store bind mounts")
+	}
+
+	didUnmount, err := p.diskManager.GetMounter().Unmount(realPath)
+	if err != nil {
+		return false, err
+	}
+
+	// Unmounted disk might not have been the one mounted at the store directory
+	err = p.jobStoreLayout().Restore()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Restoring job store bind mounts")
+	}
+
+	return didUnmount, nil
 }
 
 func (p linux) GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string {
-	realPath, _, err := p.devicePathResolver.GetRealDevicePath(diskSettings)
+	realPath, _, err := p.ephemeralDiskResolver.GetRealDevicePath(diskSettings)
 	if err != nil {
+		p.logger.Warn(logTag, "Resolving ephemeral disk path: %s", err.Error())
 		return ""
 	}
 
@@ -862,6 +1297,12 @@ func (p linux) IsPersistentDiskMountable(diskSettings boshsettings.DiskSettings)
 		return false, bosherr.WrapErrorf(err, "Validating path: %s", diskSettings.Path)
 	}
 
+	// LUKS volume does not have partition table until it is opened
+	encrypted, err := p.diskManager.GetCryptor().IsEncrypted(realPath)
+	if err == nil && encrypted {
+		return true, nil
+	}
+
 	stdout, stderr, _, _ := p.cmdRunner.RunCommand("sfdisk", "-d", realPath)
 	if strings.Contains(stderr, "unrecognized partition table type") {
 		return false, nil
@@ -893,6 +1334,16 @@ func (p linux) MigratePersistentDisk(fromMountPoint, toMountPoint string) (err e
 		return
 	}
 
+	moveJobStores := p.options.JobStoreMountsDir != "" && fromMountPoint == p.dirProvider.StoreDir()
+
+	if moveJobStores {
+		err = p.jobStoreLayout().Release()
+		if err != nil {
+			err = bosherr.WrapError(err, "Releasing job store bind mounts")
+			return
+		}
+	}
+
 	_, err = p.diskManager.GetMounter().Unmount(fromMountPoint)
 	if err != nil {
 		err = bosherr.WrapError(err, "Unmounting old persistent disk")
@@ -902,10 +1353,47 @@ func (p linux) MigratePersistentDisk(fromMountPoint, toMountPoint string) (err e
 	err = p.diskManager.GetMounter().Remount(toMountPoint, fromMountPoint)
 	if err != nil {
 		err = bosherr.WrapError(err, "Remounting new disk on original mountpoint")
+		return
+	}
+
+	if moveJobStores {
+		err = p.jobStoreLayout().Restore()
+		if err != nil {
+			err = bosherr.WrapError(err, "Restoring job store bind mounts")
+		}
 	}
 	return
 }
 
+// SetupJobStores bind-mounts store directories of given jobs
+// and removes bind mounts of jobs that are no longer applied
+func (p linux) SetupJobStores(jobNames []string) error {
+	if p.options.JobStoreMountsDir == "" {
+		return nil
+	}
+
+	return p.jobStoreLayout().Apply(jobNames)
+}
+
+func (p linux) GetPersistentDiskMountPoint(diskID string) string {
+	if p.options.MountPersistentDisksByID {
+		return p.dirProvider.StoreDirForDisk(diskID)
+	}
+
+	return p.dirProvider.StoreDir()
+}
+
+func (p linux) jobStoreLayout() boshdisk.JobStoreLayout {
+	mounter := p.diskManager.GetMounter()
+
+	// Mounter already performs bind mounts when persistent disk is bind-mounted
+	if !p.options.BindMountPersistentDisk {
+		mounter = boshdisk.NewLinuxBindMounter(mounter)
+	}
+
+	return boshdisk.NewBindMountJobStoreLayout(p.dirProvider.StoreDir(), p.options.JobStoreMountsDir, mounter, p.fs, p.logger)
+}
+
 func (p linux) IsPersistentDiskMounted(diskSettings boshsettings.DiskSettings) (bool, error) {
 	p.logger.Debug(logTag, "Checking whether persistent disk %+v is mounted", diskSettings)
 	realPath, timedOut, err := p.devicePathResolver.GetRealDevicePath(diskSettings)
@@ -917,6 +1405,10 @@ func (p linux) IsPersistentDiskMounted(diskSettings boshsettings.DiskSettings) (
 		return false, bosherr.WrapError(err, "Getting real device path")
 	}
 
+	if mappedPath, isOpen := p.diskManager.GetCryptor().MappedPath(realPath); isOpen {
+		return p.diskManager.GetMounter().IsMounted(mappedPath)
+	}
+
 	if !p.options.UsePreformattedPersistentDisk {
 		if strings.Contains(realPath, "/dev/mapper/") {
 			realPath = realPath + "-part1"
@@ -981,18 +1473,54 @@ func (p linux) PrepareForNetworkingChange() error {
 }
 
 func (p linux) DeleteARPEntryWithIP(ip string) error {
-	_, _, _, err := p.cmdRunner.RunCommand("arp", "-d", ip)
+	_, stderr, _, err := p.cmdRunner.RunCommand("arp", "-d", ip)
 	if err != nil {
+		// Nothing to flush for addresses that are not in the ARP cache
+		if strings.Contains(stderr, "No ARP entry") {
+			return nil
+		}
+
 		return bosherr.WrapError(err, "Deleting arp entry")
 	}
 
 	return nil
 }
 
+func (p linux) Reboot() error {
+	_, _, _, err := p.cmdRunner.RunCommand("sync")
+	if err != nil {
+		return bosherr.WrapError(err, "Flushing filesystem buffers")
+	}
+
+	_, _, _, err = p.cmdRunner.RunCommand("shutdown", "-r", "now")
+	if err != nil {
+		return bosherr.WrapError(err, "Rebooting")
+	}
+
+	return nil
+}
+
 func (p linux) GetDefaultNetwork() (boshsettings.Network, error) {
 	return p.defaultNetworkResolver.GetDefaultNetwork()
 }
 
+func (p linux) GetDefaultGatewayInterface() (string, error) {
+	return p.defaultNetworkResolver.GetDefaultGatewayInterface()
+}
+
+// resolveFileSystemType validates the user-configured filesystem type,
+// falling back to ext4 when none was given.
+func (p linux) resolveFileSystemType(fsType boshdisk.FileSystemType) (boshdisk.FileSystemType, error) {
+	switch fsType {
+	case boshdisk.FileSystemExt4, boshdisk.FileSystemXFS:
+		return fsType, nil
+	case boshdisk.FileSystemDefault:
+		return boshdisk.FileSystemExt4, nil
+	default:
+		return "", bosherr.Errorf(`The filesystem type "%s" is not supported`, fsType)
+	}
+}
+
 func (p linux) calculateEphemeralDiskPartitionSizes(diskSizeInBytes uint64) (uint64, uint64, error) {
 	memStats, err := p.collector.GetMemStats()
 	if err != nil {
@@ -1047,7 +1575,7 @@ func (p linux) findRootDevicePathAndNumber() (string, int, error) {
 	return "", 0, bosherr.Error("Getting root partition device")
 }
 
-func (p linux) createEphemeralPartitionsOnRootDevice() (string, string, error) {
+func (p linux) createEphemeralPartitionsOnRootDevice(disableSwap bool) (string, string, error) {
 	p.logger.Info(logTag, "Creating swap & ephemeral partitions on root disk...")
 	p.logger.Debug(logTag, "Determining root device")
 
@@ -1068,16 +1596,11 @@ func (p linux) createEphemeralPartitionsOnRootDevice() (string, string, error) {
 	}
 
 	p.logger.Debug(logTag, "Calculating partition sizes of `%s', remaining size: %dB", rootDevicePath, remainingSizeInBytes)
-	swapSizeInBytes, linuxSizeInBytes, err := p.calculateEphemeralDiskPartitionSizes(remainingSizeInBytes)
+	partitions, err := p.ephemeralDiskPartitions(remainingSizeInBytes, disableSwap)
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Calculating partition sizes")
 	}
 
-	partitions := []boshdisk.Partition{
-		{SizeInBytes: swapSizeInBytes, Type: boshdisk.PartitionTypeSwap},
-		{SizeInBytes: linuxSizeInBytes, Type: boshdisk.PartitionTypeLinux},
-	}
-
 	for _, partition := range partitions {
 		p.logger.Info(logTag, "Partitioning root device `%s': %s", rootDevicePath, partition)
 	}
@@ -1087,12 +1610,98 @@ func (p linux) createEphemeralPartitionsOnRootDevice() (string, string, error) {
 		return "", "", bosherr.WrapErrorf(err, "Partitioning root device `%s'", rootDevicePath)
 	}
 
+	if disableSwap {
+		return "", rootDevicePath + strconv.Itoa(rootDeviceNumber+1), nil
+	}
+
 	swapPartitionPath := rootDevicePath + strconv.Itoa(rootDeviceNumber+1)
 	dataPartitionPath := rootDevicePath + strconv.Itoa(rootDeviceNumber+2)
 	return swapPartitionPath, dataPartitionPath, nil
 }
 
-func (p linux) partitionEphemeralDisk(realPath string) (string, string, error) {
+func (p linux) setupLoopbackEphemeralDisk(mountPoint string, fsType boshdisk.FileSystemType) error {
+	p.logger.Info(logTag, "Creating ephemeral disk backed by a file on root disk...")
+
+	imagePath := path.Join(p.dirProvider.BoshDir(), "ephemeral_disk.img")
+
+	// Keep existing file on agent restarts so that ephemeral data is preserved
+	if !p.fs.FileExists(imagePath) {
+		sizeInBytes, err := p.loopbackEphemeralDiskSize()
+		if err != nil {
+			return err
+		}
+
+		p.logger.Info(logTag, "Allocating %dB for `%s'", sizeInBytes, imagePath)
+		_, _, _, err = p.cmdRunner.RunCommand("fallocate", "-l", strconv.FormatUint(sizeInBytes, 10), imagePath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Allocating ephemeral disk file `%s'", imagePath)
+		}
+	}
+
+	loopDevicePath, err := p.attachLoopDevice(imagePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Attaching loop device for `%s'", imagePath)
+	}
+
+	p.logger.Info(logTag, "Formatting `%s' as %s", loopDevicePath, fsType)
+	err = p.diskManager.GetFormatter().Format(loopDevicePath, fsType)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Formatting loop device with %s", fsType)
+	}
+
+	p.logger.Info(logTag, "Mounting `%s' at `%s'", loopDevicePath, mountPoint)
+	err = p.diskManager.GetMounter().Mount(loopDevicePath, mountPoint)
+	if err != nil {
+		return bosherr.WrapError(err, "Mounting loop device")
+	}
+
+	return nil
+}
+
+// loopbackEphemeralDiskSize uses three quarters of the free root space
+// so that the root filesystem is not completely filled up
+func (p linux) loopbackEphemeralDiskSize() (uint64, error) {
+	diskStats, err := p.collector.GetDiskStats("/")
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Getting root disk stats")
+	}
+
+	var freeSizeInBytes uint64
+	if diskStats.DiskUsage.Total > diskStats.DiskUsage.Used {
+		// Disk usage is reported in KB
+		freeSizeInBytes = (diskStats.DiskUsage.Total - diskStats.DiskUsage.Used) * 1024
+	}
+
+	sizeInBytes := freeSizeInBytes / 4 * 3
+	sizeInBytes = sizeInBytes / (1024 * 1024) * (1024 * 1024)
+
+	if sizeInBytes < minRootEphemeralSpaceInBytes {
+		return 0, newInsufficientSpaceError(sizeInBytes, minRootEphemeralSpaceInBytes)
+	}
+
+	return sizeInBytes, nil
+}
+
+func (p linux) attachLoopDevice(imagePath string) (string, error) {
+	stdout, _, _, err := p.cmdRunner.RunCommand("losetup", "-j", imagePath)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Finding attached loop device")
+	}
+
+	// e.g. "/dev/loop0: [2049]:131 (/var/vcap/bosh/ephemeral_disk.img)"
+	if attached := strings.TrimSpace(stdout); attached != "" {
+		return strings.SplitN(attached, ":", 2)[0], nil
+	}
+
+	stdout, _, _, err = p.cmdRunner.RunCommand("losetup", "--find", "--show", imagePath)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Setting up loop device")
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+func (p linux) partitionEphemeralDisk(realPath string, disableSwap bool) (string, string, error) {
 	p.logger.Info(logTag, "Creating swap & ephemeral partitions on ephemeral disk...")
 	p.logger.Debug(logTag, "Getting device size of `%s'", realPath)
 	diskSizeInBytes, err := p.diskManager.GetPartitioner().GetDeviceSizeInBytes(realPath)
@@ -1101,27 +1710,45 @@ func (p linux) partitionEphemeralDisk(realPath string) (string, string, error) {
 	}
 
 	p.logger.Debug(logTag, "Calculating ephemeral disk partition sizes of `%s' with total disk size %dB", realPath, diskSizeInBytes)
-	swapSizeInBytes, linuxSizeInBytes, err := p.calculateEphemeralDiskPartitionSizes(diskSizeInBytes)
+	partitions, err := p.ephemeralDiskPartitions(diskSizeInBytes, disableSwap)
 	if err != nil {
 		return "", "", bosherr.WrapError(err, "Calculating partition sizes")
 	}
 
-	partitions := []boshdisk.Partition{
-		{SizeInBytes: swapSizeInBytes, Type: boshdisk.PartitionTypeSwap},
-		{SizeInBytes: linuxSizeInBytes, Type: boshdisk.PartitionTypeLinux},
-	}
-
 	p.logger.Info(logTag, "Partitioning ephemeral disk `%s' with %s", realPath, partitions)
 	err = p.diskManager.GetPartitioner().Partition(realPath, partitions)
 	if err != nil {
 		return "", "", bosherr.WrapErrorf(err, "Partitioning ephemeral disk `%s'", realPath)
 	}
 
+	if disableSwap {
+		return "", realPath + "1", nil
+	}
+
 	swapPartitionPath := realPath + "1"
 	dataPartitionPath := realPath + "2"
 	return swapPartitionPath, dataPartitionPath, nil
 }
 
+// ephemeralDiskPartitions gives whole disk to data partition when swap is disabled
+func (p linux) ephemeralDiskPartitions(diskSizeInBytes uint64, disableSwap bool) ([]boshdisk.Partition, error) {
+	if disableSwap {
+		return []boshdisk.Partition{
+			{SizeInBytes: diskSizeInBytes, Type: boshdisk.PartitionTypeLinux},
+		}, nil
+	}
+
+	swapSizeInBytes, linuxSizeInBytes, err := p.calculateEphemeralDiskPartitionSizes(diskSizeInBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return []boshdisk.Partition{
+		{SizeInBytes: swapSizeInBytes, Type: boshdisk.PartitionTypeSwap},
+		{SizeInBytes: linuxSizeInBytes, Type: boshdisk.PartitionTypeLinux},
+	}, nil
+}
+
 func (p linux) RemoveDevTools(packageFileListPath string) error {
 	content, err := p.fs.ReadFileString(packageFileListPath)
 	if err != nil {
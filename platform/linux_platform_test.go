@@ -15,6 +15,7 @@ import (
 	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
 	fakenet "github.com/cloudfoundry/bosh-agent/platform/net/fakes"
+	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -101,6 +102,7 @@ func describeLinuxPlatform() {
 			certManager,
 			monitRetryStrategy,
 			devicePathResolver,
+			devicePathResolver,
 			5*time.Millisecond,
 			state,
 			options,
@@ -289,6 +291,7 @@ bosh_foobar:...`
 					certManager,
 					monitRetryStrategy,
 					devicePathResolver,
+					devicePathResolver,
 					5*time.Millisecond,
 					state,
 					options,
@@ -356,6 +359,54 @@ bosh_foobar:...`
 			Expect("some public key").To(Equal(authKeysStat.StringContents()))
 		})
 
+		Context("when authorized keys file is configured", func() {
+			BeforeEach(func() {
+				dirProvider = boshdirs.NewProvider("/fake-dir").WithAuthorizedKeysFile("/etc/ssh/authorized_keys/%u/keys")
+			})
+
+			It("writes public key to configured path with correct permissions leaving directory outside of home owned by root", func() {
+				fs.HomeDirHomePath = "/some/home/dir"
+
+				err := platform.SetupSSH("some public key", "vcap")
+				Expect(err).ToNot(HaveOccurred())
+
+				sshDirStat := fs.GetFileTestStat("/etc/ssh/authorized_keys/vcap")
+				Expect(sshDirStat).NotTo(BeNil())
+				Expect(sshDirStat.FileType).To(Equal(fakesys.FakeFileTypeDir))
+				Expect(sshDirStat.FileMode).To(Equal(os.FileMode(0755)))
+				Expect(sshDirStat.Username).To(BeEmpty())
+
+				authKeysStat := fs.GetFileTestStat("/etc/ssh/authorized_keys/vcap/keys")
+				Expect(authKeysStat).NotTo(BeNil())
+				Expect(authKeysStat.FileMode).To(Equal(os.FileMode(0600)))
+				Expect(authKeysStat.Username).To(Equal("vcap"))
+				Expect(authKeysStat.StringContents()).To(Equal("some public key"))
+
+				Expect(fs.FileExists("/some/home/dir/.ssh/authorized_keys")).To(BeFalse())
+			})
+		})
+
+		Context("when authorized keys file is configured relative to home dir", func() {
+			BeforeEach(func() {
+				dirProvider = boshdirs.NewProvider("/fake-dir").WithAuthorizedKeysFile(".ssh2/keys")
+			})
+
+			It("makes ssh directory owned by user", func() {
+				fs.HomeDirHomePath = "/some/home/dir"
+
+				err := platform.SetupSSH("some public key", "vcap")
+				Expect(err).ToNot(HaveOccurred())
+
+				sshDirStat := fs.GetFileTestStat("/some/home/dir/.ssh2")
+				Expect(sshDirStat).NotTo(BeNil())
+				Expect(sshDirStat.FileMode).To(Equal(os.FileMode(0700)))
+				Expect(sshDirStat.Username).To(Equal("vcap"))
+
+				authKeysStat := fs.GetFileTestStat("/some/home/dir/.ssh2/keys")
+				Expect(authKeysStat).NotTo(BeNil())
+				Expect(authKeysStat.Username).To(Equal("vcap"))
+			})
+		})
 	})
 
 	Describe("SetUserPassword", func() {
@@ -434,6 +485,39 @@ ff02::3 ip6-allhosts
 			})
 		})
 
+		Context("When hostname has already been set", func() {
+			BeforeEach(func() {
+				fs.WriteFileString("/proc/sys/kernel/hostname", "foobar.local\n")
+				fs.WriteFileString("/etc/hostname", "foobar.local\n")
+			})
+
+			It("does not set hostname again", func() {
+				err := platform.SetupHostname("foobar.local")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(cmdRunner.RunCommands).To(BeEmpty())
+
+				hostnameFileContent, err := fs.ReadFileString("/etc/hostname")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hostnameFileContent).To(Equal("foobar.local\n"))
+
+				hostsFileContent, err := fs.ReadFileString("/etc/hosts")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hostsFileContent).To(Equal(expectedEtcHosts))
+			})
+
+			It("sets hostname if it differs", func() {
+				err := platform.SetupHostname("newfoo.local")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(cmdRunner.RunCommands).To(Equal([][]string{{"hostname", "newfoo.local"}}))
+
+				hostnameFileContent, err := fs.ReadFileString("/etc/hostname")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hostnameFileContent).To(Equal("newfoo.local"))
+			})
+		})
+
 		Context("When host files have already been configured", func() {
 			It("skips setting up hostname to prevent overriding changes made by the release author", func() {
 				platform.SetupHostname("foobar.local")
@@ -467,12 +551,101 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 `
 
 		It("sets up logrotate", func() {
-			platform.SetupLogrotate("fake-group-name", "fake-base-path", "fake-size")
+			platform.SetupLogrotate("fake-group-name", "fake-base-path", "fake-size", nil)
 
 			logrotateFileContent, err := fs.ReadFileString("/etc/logrotate.d/fake-group-name")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(logrotateFileContent).To(Equal(expectedEtcLogrotate))
 		})
+
+		It("only includes logs of given jobs so that logs of jobs with own config are not rotated twice", func() {
+			err := platform.SetupLogrotate("fake-group-name", "fake-base-path", "fake-size", []string{"fake-job"})
+			Expect(err).NotTo(HaveOccurred())
+
+			logrotateFileContent, err := fs.ReadFileString("/etc/logrotate.d/fake-group-name")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logrotateFileContent).To(Equal(`# Generated by bosh-agent
+
+fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-path/data/sys/log/fake-job/*.log fake-base-path/data/sys/log/fake-job/.*.log fake-base-path/data/sys/log/fake-job/*/*.log fake-base-path/data/sys/log/fake-job/*/.*.log {
+  missingok
+  rotate 7
+  compress
+  delaycompress
+  copytruncate
+  size=fake-size
+}
+`))
+		})
+	})
+
+	Describe("SetupJobLogrotate", func() {
+		const expectedJobLogrotate = `# Generated by bosh-agent
+
+fake-base-path/data/sys/log/fake-job/*.log fake-base-path/data/sys/log/fake-job/.*.log fake-base-path/data/sys/log/fake-job/*/*.log fake-base-path/data/sys/log/fake-job/*/.*.log {
+  missingok
+  rotate 3
+  compress
+  delaycompress
+  copytruncate
+  size=fake-size
+}
+`
+
+		It("sets up logrotate for job logs", func() {
+			err := platform.SetupJobLogrotate("fake-job", "fake-base-path", "fake-size", 3)
+			Expect(err).NotTo(HaveOccurred())
+
+			logrotateFileContent, err := fs.ReadFileString("/etc/logrotate.d/bosh-job-fake-job")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logrotateFileContent).To(Equal(expectedJobLogrotate))
+		})
+
+		It("returns error if writing logrotate config fails", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := platform.SetupJobLogrotate("fake-job", "fake-base-path", "fake-size", 3)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+		})
+	})
+
+	Describe("KeepOnlyJobsLogrotate", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("/etc/logrotate.d/bosh-job-kept-job", "fake-kept-config")
+			fs.WriteFileString("/etc/logrotate.d/bosh-job-removed-job", "fake-removed-config")
+			fs.WriteFileString("/etc/logrotate.d/vcap", "fake-group-config")
+
+			fs.SetGlob("/etc/logrotate.d/bosh-job-*", []string{
+				"/etc/logrotate.d/bosh-job-kept-job",
+				"/etc/logrotate.d/bosh-job-removed-job",
+			})
+		})
+
+		It("removes logrotate config of jobs that are not given", func() {
+			err := platform.KeepOnlyJobsLogrotate([]string{"kept-job"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fs.FileExists("/etc/logrotate.d/bosh-job-kept-job")).To(BeTrue())
+			Expect(fs.FileExists("/etc/logrotate.d/bosh-job-removed-job")).To(BeFalse())
+			Expect(fs.FileExists("/etc/logrotate.d/vcap")).To(BeTrue())
+		})
+
+		It("removes all job logrotate config when no jobs are given", func() {
+			err := platform.KeepOnlyJobsLogrotate(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fs.FileExists("/etc/logrotate.d/bosh-job-kept-job")).To(BeFalse())
+			Expect(fs.FileExists("/etc/logrotate.d/bosh-job-removed-job")).To(BeFalse())
+			Expect(fs.FileExists("/etc/logrotate.d/vcap")).To(BeTrue())
+		})
+
+		It("returns error if finding job logrotate configs fails", func() {
+			fs.GlobErr = errors.New("fake-glob-err")
+
+			err := platform.KeepOnlyJobsLogrotate([]string{"kept-job"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-glob-err"))
+		})
 	})
 
 	Describe("SetTimeWithNtpServers", func() {
@@ -496,6 +669,61 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 		})
 	})
 
+	Describe("SyncTimeWithNtpServers", func() {
+		It("rewrites ntp servers and syncs time", func() {
+			fs.WriteFileString("/fake-dir/bosh/etc/ntpserver", "fake-old-ntp-server")
+
+			err := platform.SyncTimeWithNtpServers([]string{"0.north-america.pool.ntp.org", "1.north-america.pool.ntp.org"})
+			Expect(err).ToNot(HaveOccurred())
+
+			ntpConfig := fs.GetFileTestStat("/fake-dir/bosh/etc/ntpserver")
+			Expect(ntpConfig.StringContents()).To(Equal("0.north-america.pool.ntp.org 1.north-america.pool.ntp.org"))
+
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{{"ntpdate"}}))
+		})
+
+		It("removes ntp servers without syncing time when no ntp server provided", func() {
+			fs.WriteFileString("/fake-dir/bosh/etc/ntpserver", "fake-old-ntp-server")
+
+			err := platform.SyncTimeWithNtpServers([]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.FileExists("/fake-dir/bosh/etc/ntpserver")).To(BeFalse())
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
+		It("returns error when writing ntp servers fails", func() {
+			fs.WriteFileError = errors.New("fake-write-err")
+
+			err := platform.SyncTimeWithNtpServers([]string{"0.north-america.pool.ntp.org"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Writing to /fake-dir/bosh/etc/ntpserver"))
+			Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
+		It("returns error when removing ntp servers fails", func() {
+			fs.RemoveAllError = errors.New("fake-remove-all-err")
+
+			err := platform.SyncTimeWithNtpServers([]string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Removing /fake-dir/bosh/etc/ntpserver"))
+			Expect(err.Error()).To(ContainSubstring("fake-remove-all-err"))
+		})
+
+		It("returns error when syncing time fails", func() {
+			cmdRunner.AddCmdResult("ntpdate", fakesys.FakeCmdResult{
+				Stderr: "fake-no-server-suitable",
+				Error:  errors.New("fake-ntpdate-err"),
+			})
+
+			err := platform.SyncTimeWithNtpServers([]string{"0.north-america.pool.ntp.org"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Shelling out to ntpdate: fake-no-server-suitable"))
+			Expect(err.Error()).To(ContainSubstring("fake-ntpdate-err"))
+		})
+	})
+
 	Describe("SetupEphemeralDiskWithPath", func() {
 		var (
 			partitioner *fakedisk.FakePartitioner
@@ -531,10 +759,22 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 		}
 
 		Context("when ephemeral disk path is provided", func() {
-			act := func() error { return platform.SetupEphemeralDiskWithPath("/dev/xvda") }
+			act := func() error {
+				return platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemDefault, false)
+			}
 
 			itSetsUpEphemeralDisk(act)
 
+			It("uses the device even if loopback file mode is enabled", func() {
+				options.CreateLoopbackFileIfNoEphemeralDisk = true
+
+				err := platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemDefault, false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(partitioner.PartitionCalled).To(BeTrue())
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/xvda2"}))
+				Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("losetup")))
+			})
+
 			It("returns error if creating data dir fails", func() {
 				fs.MkdirAllError = errors.New("fake-mkdir-all-err")
 
@@ -592,6 +832,21 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 				Expect(formatter.FormatFsTypes[1]).To(Equal(boshdisk.FileSystemExt4))
 			})
 
+			It("formats data partition with xfs when requested", func() {
+				err := platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemXFS, false)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(formatter.FormatFsTypes).To(Equal([]boshdisk.FileSystemType{boshdisk.FileSystemSwap, boshdisk.FileSystemXFS}))
+			})
+
+			It("returns an error when filesystem type is not supported", func() {
+				err := platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemType("blahblah"), false)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal(`The filesystem type "blahblah" is not supported`))
+				Expect(partitioner.PartitionCalled).To(BeFalse())
+				Expect(formatter.FormatCalled).To(BeFalse())
+			})
+
 			It("mounts swap and data partitions", func() {
 				err := act()
 				Expect(err).NotTo(HaveOccurred())
@@ -634,10 +889,45 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 					{SizeInBytes: diskSizeInBytes / 2, Type: boshdisk.PartitionTypeLinux},
 				}))
 			})
+
+			Context("when swap is disabled", func() {
+				act := func() error {
+					return platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemDefault, true)
+				}
+
+				It("gives the whole disk to data partition", func() {
+					partitioner.GetDeviceSizeInBytesSizes["/dev/xvda"] = 2 * 1024 * 1024 * 1024
+					collector.MemStats.Total = 1024 * 1024 * 1024
+
+					err := act()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(partitioner.PartitionPartitions).To(Equal([]boshdisk.Partition{
+						{SizeInBytes: 2 * 1024 * 1024 * 1024, Type: boshdisk.PartitionTypeLinux},
+					}))
+				})
+
+				It("formats and mounts data partition without creating swap", func() {
+					err := act()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(formatter.FormatPartitionPaths).To(Equal([]string{"/dev/xvda1"}))
+					Expect(formatter.FormatFsTypes).To(Equal([]boshdisk.FileSystemType{boshdisk.FileSystemExt4}))
+					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/xvda1"}))
+					Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-dir/data"}))
+					Expect(mounter.SwapOnPartitionPaths).To(BeEmpty())
+				})
+
+				It("does not need mem stats", func() {
+					collector.MemStatsErr = errors.New("fake-memstats-error")
+
+					err := act()
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
 		})
 
 		Context("when ephemeral disk path is not provided", func() {
-			act := func() error { return platform.SetupEphemeralDiskWithPath("") }
+			act := func() error { return platform.SetupEphemeralDiskWithPath("", boshdisk.FileSystemDefault, false) }
 
 			Context("when agent should partition ephemeral disk on root disk", func() {
 				BeforeEach(func() {
@@ -765,6 +1055,18 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 								Expect(mounter.SwapOnPartitionPaths[0]).To(Equal("/dev/vda2"))
 							})
 
+							It("creates only data partition when swap is disabled", func() {
+								err := platform.SetupEphemeralDiskWithPath("", boshdisk.FileSystemDefault, true)
+								Expect(err).NotTo(HaveOccurred())
+
+								Expect(partitioner.PartitionPartitions).To(Equal([]boshdisk.Partition{
+									{SizeInBytes: 1024 * 1024 * 1024, Type: boshdisk.PartitionTypeLinux},
+								}))
+								Expect(formatter.FormatPartitionPaths).To(Equal([]string{"/dev/vda2"}))
+								Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/vda2"}))
+								Expect(mounter.SwapOnPartitionPaths).To(BeEmpty())
+							})
+
 							It("creates swap the size of the memory and the rest for data when disk is bigger than twice the memory", func() {
 								memSizeInBytes := uint64(1024 * 1024 * 1024)
 								diskSizeInBytes := 2*memSizeInBytes + 64
@@ -954,6 +1256,101 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 				})
 			})
 
+			Context("when agent should use a loopback file on root disk", func() {
+				BeforeEach(func() {
+					options.CreateLoopbackFileIfNoEphemeralDisk = true
+					collector.DiskStats = map[string]boshstats.DiskStats{
+						"/": {DiskUsage: boshstats.Usage{Total: 10 * 1024 * 1024, Used: 2 * 1024 * 1024}},
+					}
+					cmdRunner.AddCmdResult(
+						"losetup --find --show /fake-dir/bosh/ephemeral_disk.img",
+						fakesys.FakeCmdResult{Stdout: "/dev/loop0\n"},
+					)
+				})
+
+				It("allocates a file sized from the free root space", func() {
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					// 3/4 of 8GiB free
+					Expect(cmdRunner.RunCommands).To(ContainElement(
+						[]string{"fallocate", "-l", "6442450944", "/fake-dir/bosh/ephemeral_disk.img"},
+					))
+				})
+
+				It("formats and mounts the loop device as the data dir without swap", func() {
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(partitioner.PartitionCalled).To(BeFalse())
+					Expect(formatter.FormatPartitionPaths).To(Equal([]string{"/dev/loop0"}))
+					Expect(formatter.FormatFsTypes).To(Equal([]boshdisk.FileSystemType{boshdisk.FileSystemExt4}))
+					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/loop0"}))
+					Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-dir/data"}))
+					Expect(mounter.SwapOnPartitionPaths).To(BeEmpty())
+				})
+
+				It("reuses an existing file and attached loop device", func() {
+					fs.WriteFileString("/fake-dir/bosh/ephemeral_disk.img", "")
+					cmdRunner.AddCmdResult(
+						"losetup -j /fake-dir/bosh/ephemeral_disk.img",
+						fakesys.FakeCmdResult{Stdout: "/dev/loop3: [2049]:131 (/fake-dir/bosh/ephemeral_disk.img)\n"},
+					)
+
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(cmdRunner.RunCommands).To(Equal([][]string{
+						{"losetup", "-j", "/fake-dir/bosh/ephemeral_disk.img"},
+					}))
+					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/loop3"}))
+				})
+
+				It("returns an error when root disk has insufficient free space", func() {
+					collector.DiskStats["/"] = boshstats.DiskStats{
+						DiskUsage: boshstats.Usage{Total: 10 * 1024 * 1024, Used: 9 * 1024 * 1024},
+					}
+
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Insufficient remaining disk"))
+					Expect(formatter.FormatCalled).To(BeFalse())
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+
+				It("returns an error when root disk stats are unavailable", func() {
+					collector.DiskStats = nil
+
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Getting root disk stats"))
+				})
+
+				It("returns an error when allocating the file fails", func() {
+					cmdRunner.AddCmdResult(
+						"fallocate -l 6442450944 /fake-dir/bosh/ephemeral_disk.img",
+						fakesys.FakeCmdResult{Error: errors.New("fake-fallocate-err")},
+					)
+
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-fallocate-err"))
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+
+				It("returns an error when attaching the loop device fails", func() {
+					cmdRunner.AddCmdResult(
+						"losetup -j /fake-dir/bosh/ephemeral_disk.img",
+						fakesys.FakeCmdResult{Error: errors.New("fake-losetup-err")},
+					)
+
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-losetup-err"))
+					Expect(formatter.FormatCalled).To(BeFalse())
+				})
+			})
+
 			Context("when agent should not partition ephemeral disk on root disk", func() {
 				BeforeEach(func() {
 					options.CreatePartitionIfNoEphemeralDisk = false
@@ -991,7 +1388,7 @@ fake-base-path/data/sys/log/*.log fake-base-path/data/sys/log/.*.log fake-base-p
 			})
 
 			It("does nothing", func() {
-				err := platform.SetupEphemeralDiskWithPath("/dev/xvda")
+				err := platform.SetupEphemeralDiskWithPath("/dev/xvda", boshdisk.FileSystemDefault, false)
 
 				Expect(err).ToNot(HaveOccurred())
 				Expect(partitioner.PartitionCalled).To(BeFalse())
@@ -1149,6 +1546,28 @@ Number  Start   End     Size    File system  Name             Flags
 			Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"parted", "-s", "/dev/xvda", "p"}))
 		})
 
+		It("does nothing when there are no raw ephemeral disks", func() {
+			err := platform.SetupRawEphemeralDisks([]boshsettings.DiskSettings{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
+		It("returns an error if partitioning a raw ephemeral disk fails", func() {
+			devicePathResolver.GetRealDevicePathStub = func(diskSettings boshsettings.DiskSettings) (string, bool, error) {
+				return diskSettings.Path, false, nil
+			}
+
+			cmdRunner.AddCmdResult(
+				"parted -s /dev/xvdb mklabel gpt unit % mkpart raw-ephemeral-0 0 100",
+				fakesys.FakeCmdResult{Error: errors.New("fake-mkpart-err")},
+			)
+
+			err := platform.SetupRawEphemeralDisks([]boshsettings.DiskSettings{{Path: "/dev/xvdb"}, {Path: "/dev/xvdc"}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-mkpart-err"))
+			Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("/dev/xvdc")))
+		})
+
 		Context("when SkipDiskSetup is true", func() {
 			BeforeEach(func() {
 				options.SkipDiskSetup = true
@@ -1483,6 +1902,94 @@ Number  Start   End     Size    File system  Name             Flags
 			})
 		})
 
+		Context("when persistent disk is encrypted", func() {
+			var cryptor *fakedisk.FakeCryptor
+
+			BeforeEach(func() {
+				cryptor = diskManager.FakeCryptor
+				devicePathResolver.RealDevicePath = "/dev/sdc"
+				cryptor.EncryptedDevices["/dev/sdc"] = true
+			})
+
+			act := func() error {
+				return platform.MountPersistentDisk(
+					boshsettings.DiskSettings{Path: "fake-volume-id", EncryptionPassphrase: "fake-passphrase"},
+					"/mnt/point",
+				)
+			}
+
+			It("opens it with passphrase and mounts decrypted device without partitioning it", func() {
+				err := act()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cryptor.OpenDevicePaths).To(Equal([]string{"/dev/sdc"}))
+				Expect(cryptor.OpenPassphrases).To(Equal([]string{"fake-passphrase"}))
+
+				Expect(partitioner.PartitionCalled).To(BeFalse())
+				Expect(formatter.FormatPartitionPaths).To(Equal([]string{"/dev/mapper/sdc-fake-crypt"}))
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/mapper/sdc-fake-crypt"}))
+				Expect(mounter.MountMountPoints).To(Equal([]string{"/mnt/point"}))
+				Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("growpart")))
+			})
+
+			It("skips opening and mounting when decrypted device is already mounted", func() {
+				cryptor.MappedPaths["/dev/sdc"] = "/dev/mapper/sdc-fake-crypt"
+				mounter.IsMountPointResult = true
+				mounter.IsMountPointPartitionPath = "/dev/mapper/sdc-fake-crypt"
+
+				err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cryptor.OpenDevicePaths).To(BeEmpty())
+				Expect(mounter.MountCalled).To(BeFalse())
+			})
+
+			It("mounts already opened device without opening it again", func() {
+				cryptor.MappedPaths["/dev/sdc"] = "/dev/mapper/sdc-fake-crypt"
+
+				err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cryptor.OpenDevicePaths).To(BeEmpty())
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/mapper/sdc-fake-crypt"}))
+			})
+
+			It("returns error without mounting when passphrase is wrong", func() {
+				cryptor.OpenErr = errors.New("Wrong passphrase for encrypted device '/dev/sdc'")
+
+				err := act()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Opening encrypted persistent disk: Wrong passphrase for encrypted device '/dev/sdc'"))
+				Expect(formatter.FormatCalled).To(BeFalse())
+				Expect(mounter.MountCalled).To(BeFalse())
+			})
+
+			It("returns error when no passphrase is configured", func() {
+				err := platform.MountPersistentDisk(boshsettings.DiskSettings{Path: "fake-volume-id"}, "/mnt/point")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Persistent disk '/dev/sdc' is encrypted but no passphrase is configured"))
+				Expect(cryptor.OpenDevicePaths).To(BeEmpty())
+				Expect(mounter.MountCalled).To(BeFalse())
+			})
+
+			It("returns error when encryption cannot be detected", func() {
+				cryptor.IsEncryptedErr = errors.New("fake-is-encrypted-err")
+
+				err := act()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-is-encrypted-err"))
+				Expect(mounter.MountCalled).To(BeFalse())
+			})
+
+			It("partitions and mounts disk as before when it is not encrypted", func() {
+				cryptor.EncryptedDevices["/dev/sdc"] = false
+
+				err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cryptor.OpenDevicePaths).To(BeEmpty())
+				Expect(partitioner.PartitionCalled).To(BeTrue())
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/sdc1"}))
+			})
+		})
+
 		Context("when device real path contains /dev/mapper/ and is successfully resolved", func() {
 			BeforeEach(func() {
 				devicePathResolver.RealDevicePath = "/dev/mapper/fake-real-device-path"
@@ -1516,11 +2023,70 @@ Number  Start   End     Size    File system  Name             Flags
 						Expect(fs.GetFileTestStat("/fake-dir/store_migration_target").FileType).To(Equal(fakesys.FakeFileTypeDir))
 						Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/mapper/fake-real-device-path-part1"}))
 						Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-dir/store_migration_target"}))
-						Expect(mounter.MountMountOptions).To(Equal([][]string{nil}))
+						Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "defaults"}}))
 					})
 				})
 			})
 
+			Context("when the disk is already mounted at the expected path", func() {
+				BeforeEach(func() {
+					diskManager.FakeMountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+						{PartitionPath: "/dev/mapper/fake-real-device-path-part1", MountPoint: "/mnt/point"},
+					}
+				})
+
+				It("skips mounting", func() {
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(partitioner.PartitionCalled).To(BeFalse())
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+			})
+
+			Context("when the disk is already mounted at a different path", func() {
+				BeforeEach(func() {
+					diskManager.FakeMountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+						{PartitionPath: "/dev/mapper/fake-real-device-path-part1", MountPoint: "/other/point"},
+					}
+				})
+
+				It("returns an error without mounting", func() {
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Persistent disk '/dev/mapper/fake-real-device-path-part1' is already mounted at '/other/point' instead of '/mnt/point'"))
+					Expect(partitioner.PartitionCalled).To(BeFalse())
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+			})
+
+			Context("when the disk is not mounted", func() {
+				BeforeEach(func() {
+					diskManager.FakeMountsSearcher.SearchMountsMounts = []boshdisk.Mount{
+						{PartitionPath: "/dev/sda1", MountPoint: "/"},
+					}
+				})
+
+				It("mounts the disk", func() {
+					err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/mapper/fake-real-device-path-part1"}))
+					Expect(mounter.MountMountPoints).To(Equal([]string{"/mnt/point"}))
+				})
+			})
+
+			Context("when searching mounts fails", func() {
+				BeforeEach(func() {
+					diskManager.FakeMountsSearcher.SearchMountsErr = errors.New("fake-search-mounts-err")
+				})
+
+				It("returns an error", func() {
+					err := act()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-search-mounts-err"))
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+			})
+
 			Context("when failing to determine if store directory is mounted", func() {
 				BeforeEach(func() {
 					mounter.IsMountPointErr = errors.New("fake-is-mount-point-err")
@@ -1573,7 +2139,7 @@ Number  Start   End     Size    File system  Name             Flags
 					Expect(err).ToNot(HaveOccurred())
 					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/mapper/fake-real-device-path-part1"}))
 					Expect(mounter.MountMountPoints).To(Equal([]string{"/mnt/point"}))
-					Expect(mounter.MountMountOptions).To(Equal([][]string{nil}))
+					Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "defaults"}}))
 				})
 			})
 		})
@@ -1673,7 +2239,91 @@ Number  Start   End     Size    File system  Name             Flags
 					Expect(err).ToNot(HaveOccurred())
 					Expect(mounter.MountPartitionPaths).To(Equal([]string{"fake-real-device-path1"}))
 					Expect(mounter.MountMountPoints).To(Equal([]string{"/mnt/point"}))
-					Expect(mounter.MountMountOptions).To(Equal([][]string{nil}))
+					Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "defaults"}}))
+				})
+
+				It("mounts the disk with the configured mount options", func() {
+					err := platform.MountPersistentDisk(
+						boshsettings.DiskSettings{Path: "fake-volume-id", MountOptions: []string{"noatime", "nodev", "commit=60"}},
+						"/mnt/point",
+					)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "noatime,nodev,commit=60"}}))
+				})
+
+				It("rejects invalid mount options before partitioning and mounting", func() {
+					err := platform.MountPersistentDisk(
+						boshsettings.DiskSettings{Path: "fake-volume-id", MountOptions: []string{"noatime", "nodev,exec"}},
+						"/mnt/point",
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Invalid persistent disk mount option 'nodev,exec'"))
+					Expect(partitioner.PartitionCalled).To(BeFalse())
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+
+				Context("when growpart is installed", func() {
+					BeforeEach(func() {
+						cmdRunner.CommandExistsValue = true
+					})
+
+					It("grows the partition and resizes the ext4 filesystem", func() {
+						err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cmdRunner.RunCommands).To(ContainElement([]string{"growpart", "fake-real-device-path", "1"}))
+						Expect(cmdRunner.RunCommands).To(ContainElement([]string{"resize2fs", "-f", "fake-real-device-path1"}))
+					})
+
+					It("grows the partition and resizes the xfs filesystem", func() {
+						err := platform.MountPersistentDisk(
+							boshsettings.DiskSettings{Path: "fake-volume-id", FileSystemType: boshdisk.FileSystemXFS},
+							"/mnt/point",
+						)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cmdRunner.RunCommands).To(ContainElement([]string{"growpart", "fake-real-device-path", "1"}))
+						Expect(cmdRunner.RunCommands).To(ContainElement([]string{"xfs_growfs", "/mnt/point"}))
+					})
+
+					It("does not resize the filesystem when there is no additional space", func() {
+						cmdRunner.AddCmdResult(
+							"growpart fake-real-device-path 1",
+							fakesys.FakeCmdResult{Stdout: "NOCHANGE: partition 1 is size 2095104. it cannot be grown", ExitStatus: 1, Error: errors.New("fake-growpart-err")},
+						)
+
+						err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cmdRunner.RunCommands).To(Equal([][]string{{"growpart", "fake-real-device-path", "1"}}))
+					})
+
+					It("returns an error when growing the partition fails", func() {
+						cmdRunner.AddCmdResult(
+							"growpart fake-real-device-path 1",
+							fakesys.FakeCmdResult{Error: errors.New("fake-growpart-err")},
+						)
+
+						err := act()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-growpart-err"))
+					})
+
+					It("returns an error when resizing the filesystem fails", func() {
+						cmdRunner.AddCmdResult(
+							"resize2fs -f fake-real-device-path1",
+							fakesys.FakeCmdResult{Error: errors.New("fake-resize2fs-err")},
+						)
+
+						err := act()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-resize2fs-err"))
+					})
+				})
+
+				Context("when growpart is not installed", func() {
+					It("does not try to grow the disk", func() {
+						err := act()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("growpart")))
+					})
 				})
 			})
 
@@ -1706,7 +2356,7 @@ Number  Start   End     Size    File system  Name             Flags
 					Expect(len(mounter.MountPartitionPaths)).To(Equal(1))
 					Expect(mounter.MountPartitionPaths).To(Equal([]string{"fake-real-device-path"})) // no '1' because no partition
 					Expect(mounter.MountMountPoints).To(Equal([]string{"/mnt/point"}))
-					Expect(mounter.MountMountOptions).To(Equal([][]string{nil}))
+					Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "defaults"}}))
 				})
 
 				It("returns error when mounting fails", func() {
@@ -1742,6 +2392,84 @@ Number  Start   End     Size    File system  Name             Flags
 		})
 	})
 
+	Describe("GetPersistentDiskMountPoint", func() {
+		It("returns store directory", func() {
+			Expect(platform.GetPersistentDiskMountPoint("fake-disk-id")).To(Equal("/fake-dir/store"))
+		})
+
+		Context("when MountPersistentDisksByID is set to true", func() {
+			BeforeEach(func() {
+				options.MountPersistentDisksByID = true
+			})
+
+			It("returns directory derived from disk id", func() {
+				Expect(platform.GetPersistentDiskMountPoint("fake-disk-id")).To(Equal("/fake-dir/stores/fake-disk-id"))
+				Expect(platform.GetPersistentDiskMountPoint("fake/disk-id")).To(Equal("/fake-dir/stores/fake%2Fdisk-id"))
+			})
+
+			It("mounts two persistent disks side by side", func() {
+				devicePathResolver.GetRealDevicePathStub = func(diskSettings boshsettings.DiskSettings) (string, bool, error) {
+					return diskSettings.Path, false, nil
+				}
+
+				for _, diskSettings := range []boshsettings.DiskSettings{
+					{ID: "fake-disk-1", Path: "/dev/sdb"},
+					{ID: "fake-disk-2", Path: "/dev/sdc"},
+				} {
+					err := platform.MountPersistentDisk(diskSettings, platform.GetPersistentDiskMountPoint(diskSettings.ID))
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				mounter := diskManager.FakeMounter
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/dev/sdb1", "/dev/sdc1"}))
+				Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-dir/stores/fake-disk-1", "/fake-dir/stores/fake-disk-2"}))
+			})
+		})
+	})
+
+	Describe("SetupJobStores", func() {
+		var mounter *fakedisk.FakeMounter
+		BeforeEach(func() {
+			mounter = diskManager.FakeMounter
+			mounter.IsMountPointResult = true
+			fs.SetGlob("/fake-chroot/store/*", []string{"/fake-chroot/store/fake-job"})
+		})
+
+		It("does nothing when JobStoreMountsDir is not set", func() {
+			err := platform.SetupJobStores([]string{"fake-job"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fs.FileExists("/fake-chroot/store/fake-job")).To(BeFalse())
+			Expect(mounter.MountCalled).To(BeFalse())
+		})
+
+		Context("when JobStoreMountsDir is set", func() {
+			BeforeEach(func() {
+				options.JobStoreMountsDir = "/fake-chroot/store"
+			})
+
+			It("bind-mounts store directories of jobs from the persistent disk", func() {
+				err := platform.SetupJobStores([]string{"fake-job"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fs.FileExists("/fake-chroot/store/fake-job")).To(BeTrue())
+				Expect(mounter.MountPartitionPaths).To(Equal([]string{"/fake-dir/store/fake-job"}))
+				Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-chroot/store/fake-job"}))
+				Expect(mounter.MountMountOptions).To(Equal([][]string{{"--bind"}}))
+			})
+
+			Context("when persistent disk mounter already bind-mounts", func() {
+				BeforeEach(func() {
+					options.BindMountPersistentDisk = true
+				})
+
+				It("does not add bind option", func() {
+					err := platform.SetupJobStores([]string{"fake-job"})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(mounter.MountMountOptions).To(Equal([][]string{nil}))
+				})
+			})
+		})
+	})
+
 	Describe("UnmountPersistentDisk", func() {
 		act := func() (bool, error) {
 			return platform.UnmountPersistentDisk(boshsettings.DiskSettings{Path: "fake-device-path"})
@@ -1794,6 +2522,44 @@ Number  Start   End     Size    File system  Name             Flags
 
 		})
 
+		Context("when encrypted persistent disk is open", func() {
+			var cryptor *fakedisk.FakeCryptor
+
+			BeforeEach(func() {
+				cryptor = diskManager.FakeCryptor
+				devicePathResolver.RealDevicePath = "/dev/sdc"
+				cryptor.MappedPaths["/dev/sdc"] = "/dev/mapper/sdc-fake-crypt"
+			})
+
+			It("unmounts decrypted device and closes it", func() {
+				mounter.UnmountDidUnmount = true
+
+				didUnmount, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(didUnmount).To(BeTrue())
+				Expect(mounter.UnmountPartitionPathOrMountPoint).To(Equal("/dev/mapper/sdc-fake-crypt"))
+				Expect(cryptor.ClosedDevicePaths).To(Equal([]string{"/dev/sdc"}))
+			})
+
+			It("keeps device open when it was not unmounted", func() {
+				mounter.UnmountDidUnmount = false
+
+				didUnmount, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(didUnmount).To(BeFalse())
+				Expect(cryptor.ClosedDevicePaths).To(BeEmpty())
+			})
+
+			It("returns error if closing fails", func() {
+				mounter.UnmountDidUnmount = true
+				cryptor.CloseErr = errors.New("fake-close-err")
+
+				_, err := act()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Closing encrypted persistent disk: fake-close-err"))
+			})
+		})
+
 		Context("when device path can be resolved", func() {
 			BeforeEach(func() {
 				devicePathResolver.RealDevicePath = "fake-real-device-path"
@@ -1841,6 +2607,37 @@ Number  Start   End     Size    File system  Name             Flags
 
 				ItUnmountsPersistentDisk("fake-real-device-path") // note no '1'; no partitions
 			})
+
+			Context("JobStoreMountsDir is set", func() {
+				BeforeEach(func() {
+					options.JobStoreMountsDir = "/fake-chroot/store"
+					fs.SetGlob("/fake-chroot/store/*", []string{"/fake-chroot/store/fake-job"})
+				})
+
+				It("unmounts job store bind mounts before unmounting persistent disk", func() {
+					mounter.UnmountDidUnmount = true
+
+					didUnmount, err := act()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(didUnmount).To(BeTrue())
+					Expect(mounter.UnmountPartitionPathsOrMountPoints).To(Equal([]string{
+						"/fake-chroot/store/fake-job",
+						"fake-real-device-path1",
+					}))
+					Expect(mounter.MountCalled).To(BeFalse())
+				})
+
+				It("restores job store bind mounts if store directory is still mounted", func() {
+					mounter.IsMountPointResult = true
+
+					_, err := act()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(mounter.IsMountPointPath).To(Equal("/fake-dir/store"))
+					Expect(mounter.MountPartitionPaths).To(Equal([]string{"/fake-dir/store/fake-job"}))
+					Expect(mounter.MountMountPoints).To(Equal([]string{"/fake-chroot/store/fake-job"}))
+					Expect(mounter.MountMountOptions).To(Equal([][]string{{"--bind"}}))
+				})
+			})
 		})
 
 		Context("when device path cannot be resolved", func() {
@@ -1871,6 +2668,32 @@ Number  Start   End     Size    File system  Name             Flags
 		})
 	})
 
+	Describe("UnmountPersistentDiskMountPoint", func() {
+		var mounter *fakedisk.FakeMounter
+		BeforeEach(func() {
+			mounter = diskManager.FakeMounter
+			devicePathResolver.GetRealDevicePathErr = errors.New("fake-get-real-device-path-err")
+		})
+
+		It("unmounts mount point without resolving device path", func() {
+			mounter.UnmountDidUnmount = true
+
+			didUnmount, err := platform.UnmountPersistentDiskMountPoint("/fake-dir/stores/fake-disk-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(didUnmount).To(BeTrue())
+			Expect(mounter.UnmountPartitionPathOrMountPoint).To(Equal("/fake-dir/stores/fake-disk-id"))
+			Expect(devicePathResolver.GetRealDevicePathDiskSettings).To(Equal(boshsettings.DiskSettings{}))
+		})
+
+		It("returns error if unmounting fails", func() {
+			mounter.UnmountErr = errors.New("fake-unmount-err")
+
+			_, err := platform.UnmountPersistentDiskMountPoint("/fake-dir/stores/fake-disk-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-unmount-err"))
+		})
+	})
+
 	Describe("GetFileContentsFromCDROM", func() {
 		It("delegates to cdutil", func() {
 			cdutil.GetFilesContentsContents = [][]byte{[]byte("fake-contents")}
@@ -1954,6 +2777,17 @@ Number  Start   End     Size    File system  Name             Flags
 			mounter = diskManager.FakeMounter
 		})
 
+		It("checks decrypted device when encrypted persistent disk is open", func() {
+			devicePathResolver.RealDevicePath = "/dev/sdc"
+			diskManager.FakeCryptor.MappedPaths["/dev/sdc"] = "/dev/mapper/sdc-fake-crypt"
+			mounter.IsMountedResult = true
+
+			isMounted, err := act()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isMounted).To(BeTrue())
+			Expect(mounter.IsMountedDevicePathOrMountPoint).To(Equal("/dev/mapper/sdc-fake-crypt"))
+		})
+
 		Context("when device real path contains /dev/mapper/ and can be resolved", func() {
 			BeforeEach(func() {
 				devicePathResolver.RealDevicePath = "/dev/mapper/fake-real-device-path"
@@ -2085,6 +2919,15 @@ Number  Start   End     Size    File system  Name             Flags
 			devicePathResolver.RealDevicePath = "/fake/device"
 		})
 
+		It("returns true when drive is encrypted", func() {
+			diskManager.FakeCryptor.EncryptedDevices["/fake/device"] = true
+
+			isMountable, err := platform.IsPersistentDiskMountable(boshsettings.DiskSettings{Path: "/fake/device"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isMountable).To(BeTrue())
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
 		Context("when the specified drive does not exist", func() {
 			It("returns error", func() {
 				devicePathResolver.GetRealDevicePathTimedOut = true
@@ -2269,6 +3112,16 @@ unit: sectors
 		})
 	})
 
+	Describe("GetDefaultGatewayInterface", func() {
+		It("delegates to the defaultNetworkResolver", func() {
+			fakeDefaultNetworkResolver.GetDefaultGatewayInterfaceName = "eth1"
+
+			iface, err := platform.GetDefaultGatewayInterface()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iface).To(Equal("eth1"))
+		})
+	})
+
 	Describe("GetHostPublicKey", func() {
 		It("gets host public key if file exists", func() {
 			fs.WriteFileString("/etc/ssh/ssh_host_rsa_key.pub", "public-key")
@@ -2305,6 +3158,47 @@ unit: sectors
 
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("ignores ips that are not in the arp cache", func() {
+			result := fakesys.FakeCmdResult{
+				Error:      errors.New("failure"),
+				ExitStatus: 255,
+				Stderr:     "SIOCDARP(dontpub): Network is unreachable\nNo ARP entry for 1.2.3.4",
+			}
+			cmdRunner.AddCmdResult("arp -d 1.2.3.4", result)
+
+			err := platform.DeleteARPEntryWithIP("1.2.3.4")
+
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Reboot", func() {
+		It("flushes filesystem buffers before rebooting", func() {
+			err := platform.Reboot()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{
+				{"sync"},
+				{"shutdown", "-r", "now"},
+			}))
+		})
+
+		It("does not reboot if flushing fails", func() {
+			cmdRunner.AddCmdResult("sync", fakesys.FakeCmdResult{Error: errors.New("fake-sync-err")})
+
+			err := platform.Reboot()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-sync-err"))
+			Expect(cmdRunner.RunCommands).To(Equal([][]string{{"sync"}}))
+		})
+
+		It("returns error if reboot command fails", func() {
+			cmdRunner.AddCmdResult("shutdown -r now", fakesys.FakeCmdResult{Error: errors.New("fake-shutdown-err")})
+
+			err := platform.Reboot()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-shutdown-err"))
+		})
 	})
 
 	Describe("RemoveDevTools", func() {
@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -15,6 +17,7 @@ const arpingLogTag = "arping"
 type arping struct {
 	cmdRunner boshsys.CmdRunner
 	fs        boshsys.FileSystem
+	clock     clock.Clock
 	logger    boshlog.Logger
 
 	iterations          int
@@ -25,6 +28,7 @@ type arping struct {
 func NewArping(
 	cmdRunner boshsys.CmdRunner,
 	fs boshsys.FileSystem,
+	clock clock.Clock,
 	logger boshlog.Logger,
 	iterations int,
 	iterationDelay time.Duration,
@@ -33,6 +37,7 @@ func NewArping(
 	return arping{
 		cmdRunner:           cmdRunner,
 		fs:                  fs,
+		clock:               clock,
 		logger:              logger,
 		iterations:          iterations,
 		iterationDelay:      iterationDelay,
@@ -56,7 +61,7 @@ func (a arping) BroadcastMACAddresses(addresses []boship.InterfaceAddress) {
 				a.broadcastMACAddress(address)
 				if i < a.iterations-1 {
 					// Sleep between iterations
-					time.Sleep(a.iterationDelay)
+					a.clock.Sleep(a.iterationDelay)
 				}
 			}
 
@@ -72,7 +77,7 @@ func (a arping) BroadcastMACAddresses(addresses []boship.InterfaceAddress) {
 func (a arping) blockUntilInterfaceExists(interfaceName string) {
 	// TODO: Timeout waiting for net interface to exist?
 	for !a.fs.FileExists(path.Join("/sys/class/net", interfaceName)) {
-		time.Sleep(a.interfaceCheckDelay)
+		a.clock.Sleep(a.interfaceCheckDelay)
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	. "github.com/cloudfoundry/bosh-agent/platform/net/arp"
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
@@ -35,7 +36,7 @@ var _ = Describe("arping", func() {
 		fs = fakesys.NewFakeFileSystem()
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		arping = NewArping(cmdRunner, fs, logger, arpingIterations, 0, 0)
+		arping = NewArping(cmdRunner, fs, clock.NewClock(), logger, arpingIterations, 0, 0)
 	})
 
 	Describe("BroadcastMACAddresses", func() {
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/pivotal-golang/clock"
+
 	bosharp "github.com/cloudfoundry/bosh-agent/platform/net/arp"
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -25,6 +27,8 @@ type centosNetManager struct {
 	interfaceAddressesValidator   boship.InterfaceAddressesValidator
 	dnsValidator                  DNSValidator
 	addressBroadcaster            bosharp.AddressBroadcaster
+	resolvConfOptions             []string
+	clock                         clock.Clock
 	logger                        boshlog.Logger
 }
 
@@ -36,6 +40,8 @@ func NewCentosNetManager(
 	interfaceAddressesValidator boship.InterfaceAddressesValidator,
 	dnsValidator DNSValidator,
 	addressBroadcaster bosharp.AddressBroadcaster,
+	resolvConfOptions []string,
+	clock clock.Clock,
 	logger boshlog.Logger,
 ) Manager {
 	return centosNetManager{
@@ -46,11 +52,13 @@ func NewCentosNetManager(
 		interfaceAddressesValidator:   interfaceAddressesValidator,
 		dnsValidator:                  dnsValidator,
 		addressBroadcaster:            addressBroadcaster,
+		resolvConfOptions:             resolvConfOptions,
+		clock:                         clock,
 		logger:                        logger,
 	}
 }
 
-func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) (SetupNetworkingResult, error) {
 	nonVipNetworks := boshsettings.Networks{}
 	for networkName, networkSettings := range networks {
 		if networkSettings.IsVIP() {
@@ -61,26 +69,26 @@ func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errC
 
 	staticInterfaceConfigurations, dhcpInterfaceConfigurations, err := net.buildInterfaces(nonVipNetworks)
 	if err != nil {
-		return err
+		return SetupNetworkingResult{}, err
 	}
 
-	dnsNetwork, _ := nonVipNetworks.DefaultNetworkFor("dns")
-	dnsServers := dnsNetwork.DNS
+	dnsServers := nonVipNetworks.DNSServers()
 
 	interfacesChanged, err := net.writeNetworkInterfaces(dhcpInterfaceConfigurations, staticInterfaceConfigurations, dnsServers)
 	if err != nil {
-		return bosherr.WrapError(err, "Writing network configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Writing network configuration")
 	}
 
 	dhcpChanged := false
 	if len(dhcpInterfaceConfigurations) > 0 {
 		dhcpChanged, err = net.writeDHCPConfiguration(dnsServers, dhcpInterfaceConfigurations)
 		if err != nil {
-			return err
+			return SetupNetworkingResult{}, err
 		}
 	}
 
-	if interfacesChanged || dhcpChanged {
+	restarted := interfacesChanged || dhcpChanged
+	if restarted {
 		net.restartNetworkingInterfaces()
 	}
 
@@ -88,17 +96,17 @@ func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errC
 
 	err = net.interfaceAddressesValidator.Validate(staticAddresses)
 	if err != nil {
-		return bosherr.WrapError(err, "Validating static network configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Validating static network configuration")
 	}
 
 	err = net.dnsValidator.Validate(dnsServers)
 	if err != nil {
-		return bosherr.WrapError(err, "Validating dns configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Validating dns configuration")
 	}
 
 	net.broadcastIps(append(staticAddresses, dynamicAddresses...), errCh)
 
-	return nil
+	return newSetupNetworkingResult(staticInterfaceConfigurations, dhcpInterfaceConfigurations, dnsServers, restarted), nil
 }
 
 func (net centosNetManager) GetConfiguredNetworkInterfaces() ([]string, error) {
@@ -121,7 +129,8 @@ func (net centosNetManager) GetConfiguredNetworkInterfaces() ([]string, error) {
 const centosDHCPIfcfgTemplate = `DEVICE={{ .Name }}
 BOOTPROTO=dhcp
 ONBOOT=yes
-PEERDNS=yes
+PEERDNS=yes{{ if .Mtu }}
+MTU={{ .Mtu }}{{ end }}
 `
 
 const centosStaticIfcfgTemplate = `DEVICE={{ .Name }}
@@ -131,13 +140,18 @@ NETMASK={{ .Netmask }}
 BROADCAST={{ .Broadcast }}{{if .IsDefaultForGateway}}
 GATEWAY={{ .Gateway }}{{end}}
 ONBOOT=yes
-PEERDNS=no{{ range .DNSServers }}
-DNS{{ .Index }}={{ .Address }}{{ end }}
+PEERDNS=no{{ if .Mtu }}
+MTU={{ .Mtu }}{{ end }}{{ range .DNSServers }}
+DNS{{ .Index }}={{ .Address }}{{ end }}{{ if .ResOptions }}
+RES_OPTIONS="{{ .ResOptions }}"{{ end }}
 `
 
 type centosStaticIfcfg struct {
 	*StaticInterfaceConfiguration
 	DNSServers []dnsConfig
+
+	// Written to resolv.conf options line by ifup
+	ResOptions string
 }
 
 type dnsConfig struct {
@@ -179,6 +193,7 @@ func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations [
 
 	staticConfig := centosStaticIfcfg{}
 	staticConfig.DNSServers = newDNSConfigs(dnsServers)
+	staticConfig.ResOptions = strings.Join(net.resolvConfOptions, " ")
 	staticTemplate := template.Must(template.New("ifcfg").Parse(centosStaticIfcfgTemplate))
 
 	for i := range staticInterfaceConfigurations {
@@ -209,7 +224,17 @@ func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations [
 }
 
 func (net centosNetManager) buildInterfaces(networks boshsettings.Networks) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
-	interfacesByMacAddress, err := net.detectMacAddresses()
+	for name, network := range networks {
+		if network.IsBond() {
+			return nil, nil, bosherr.Errorf("Bonded network '%s' is not supported on CentOS", name)
+		}
+
+		if network.Vlan != 0 {
+			return nil, nil, bosherr.Errorf("VLAN network '%s' is not supported on CentOS", name)
+		}
+	}
+
+	interfacesByMacAddress, err := waitForInterfaces(networks, net.detectMacAddresses, net.clock, interfaceWaitTimeout, interfaceWaitPollInterval)
 	if err != nil {
 		return nil, nil, bosherr.WrapError(err, "Getting network interfaces")
 	}
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	. "github.com/cloudfoundry/bosh-agent/platform/net"
 	fakearp "github.com/cloudfoundry/bosh-agent/platform/net/arp/fakes"
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
@@ -27,6 +28,7 @@ func describeCentosNetManager() {
 		addressBroadcaster            *fakearp.FakeAddressBroadcaster
 		netManager                    Manager
 		interfaceConfigurationCreator InterfaceConfigurationCreator
+		timeService                   *fakeaction.FakeClock
 	)
 
 	BeforeEach(func() {
@@ -34,11 +36,12 @@ func describeCentosNetManager() {
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		ipResolver = &fakeip.FakeResolver{}
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(logger)
+		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(NewInterfaceNameResolver(), logger)
 		interfaceAddrsProvider = &fakeip.FakeInterfaceAddressesProvider{}
 		interfaceAddrsValidator := boship.NewInterfaceAddressesValidator(interfaceAddrsProvider)
 		dnsValidator := NewDNSValidator(fs)
 		addressBroadcaster = &fakearp.FakeAddressBroadcaster{}
+		timeService = &fakeaction.FakeClock{}
 		netManager = NewCentosNetManager(
 			fs,
 			cmdRunner,
@@ -47,6 +50,8 @@ func describeCentosNetManager() {
 			interfaceAddrsValidator,
 			dnsValidator,
 			addressBroadcaster,
+			nil,
+			timeService,
 			logger,
 		)
 	})
@@ -149,7 +154,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				"ethstatic": staticNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			staticConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethstatic")
@@ -161,9 +166,107 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			Expect(dhcpConfig.StringContents()).To(Equal(expectedNetworkConfigurationForDHCP))
 		})
 
+		It("writes MTU to network scripts so that it is kept after reboot", func() {
+			dhcpNetwork.Mtu = 9000
+			staticNetwork.Mtu = 1400
+
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			staticConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethstatic")
+			Expect(staticConfig).ToNot(BeNil())
+			Expect(staticConfig.StringContents()).To(ContainSubstring("PEERDNS=no\nMTU=1400\n"))
+
+			dhcpConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethdhcp")
+			Expect(dhcpConfig).ToNot(BeNil())
+			Expect(dhcpConfig.StringContents()).To(ContainSubstring("PEERDNS=yes\nMTU=9000\n"))
+		})
+
+		It("returns result describing applied static and dhcp interfaces", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			result, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(SetupNetworkingResult{
+				StaticInterfaces: []string{"ethstatic"},
+				DHCPInterfaces:   []string{"ethdhcp"},
+				DNSServers:       []string{"8.8.8.8", "9.9.9.9"},
+				Restarted:        true,
+			}))
+		})
+
+		It("returns result without restart when configuration did not change", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethstatic", expectedNetworkConfigurationForStatic)
+			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethdhcp", expectedNetworkConfigurationForDHCP)
+			fs.WriteFileString("/etc/dhcp/dhclient.conf", expectedDhclientConfiguration)
+
+			result, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Restarted).To(BeFalse())
+		})
+
+		Context("when resolv.conf options are configured", func() {
+			BeforeEach(func() {
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+				netManager = NewCentosNetManager(
+					fs,
+					cmdRunner,
+					ipResolver,
+					interfaceConfigurationCreator,
+					boship.NewInterfaceAddressesValidator(interfaceAddrsProvider),
+					NewDNSValidator(fs),
+					addressBroadcaster,
+					[]string{"timeout:2", "attempts:2"},
+					timeService,
+					logger,
+				)
+			})
+
+			It("writes RES_OPTIONS after dns servers in network script for static interfaces", func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethdhcp":   dhcpNetwork,
+					"ethstatic": staticNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				staticConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethstatic")
+				Expect(staticConfig).ToNot(BeNil())
+				Expect(staticConfig.StringContents()).To(Equal(`DEVICE=ethstatic
+BOOTPROTO=static
+IPADDR=1.2.3.4
+NETMASK=255.255.255.0
+BROADCAST=1.2.3.255
+ONBOOT=yes
+PEERDNS=no
+DNS1=8.8.8.8
+DNS2=9.9.9.9
+RES_OPTIONS="timeout:2 attempts:2"
+`))
+
+				dhcpConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethdhcp")
+				Expect(dhcpConfig).ToNot(BeNil())
+				Expect(dhcpConfig.StringContents()).ToNot(ContainSubstring("RES_OPTIONS"))
+			})
+		})
+
 		It("returns errors from glob /sys/class/net/", func() {
 			fs.GlobErr = errors.New("fs-glob-error")
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fs-glob-error"))
 		})
@@ -174,7 +277,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				"static": staticNetwork,
 			})
 			fs.WriteFileError = errors.New("fs-write-file-error")
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fs-write-file-error"))
 		})
@@ -185,7 +288,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			})
 
 			staticNetwork.Netmask = "not an ip" //will cause InterfaceConfigurationCreator to fail
-			err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Creating interface configurations"))
 		})
@@ -196,7 +299,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				"ethstatic": staticNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
@@ -218,7 +321,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				"ethdhcp": dhcpNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetworkWithoutDNS}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetworkWithoutDNS}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
@@ -248,7 +351,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 
 			fs.WriteFileErrors["/etc/dhcp/dhclient.conf"] = errors.New("dhclient.conf-write-error")
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("dhclient.conf-write-error"))
 		})
@@ -261,7 +364,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 
 			fs.SymlinkError = errors.New("dhclient-ethdhcp.conf-symlink-error")
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("dhclient-ethdhcp.conf-symlink-error"))
 		})
@@ -271,7 +374,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				"ethstatic": staticNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient-ethdhcp.conf")
@@ -300,7 +403,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethstatic", expectedNetworkConfigurationForStatic)
 			fs.WriteFileString("/etc/dhcp/dhclient.conf", expectedDhclientConfiguration)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"dhcp-network":            dhcpNetwork,
 				"changing-static-network": changingStaticNetwork,
 				"static-network":          staticNetwork,
@@ -322,7 +425,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethdhcp", expectedNetworkConfigurationForDHCP)
 			fs.WriteFileString("/etc/dhcp/dhclient.conf", expectedDhclientConfiguration)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			networkConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethstatic")
@@ -343,7 +446,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 
 			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethstatic", expectedNetworkConfigurationForStatic)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			networkConfig := fs.GetFileTestStat("/etc/sysconfig/network-scripts/ifcfg-ethstatic")
@@ -367,7 +470,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				})
 
 				errCh := make(chan error)
-				err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Validating static network configuration"))
 			})
@@ -394,7 +497,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				})
 
 				errCh := make(chan error)
-				err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Validating dns configuration"))
 			})
@@ -407,7 +510,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 			})
 
 			errCh := make(chan error)
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, errCh)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, errCh)
 			Expect(err).ToNot(HaveOccurred())
 
 			broadcastErr := <-errCh // wait for all arpings
@@ -434,7 +537,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				IP:      "9.8.7.6",
 			}
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"dhcp-network":   dhcpNetwork,
 				"static-network": staticNetwork,
 				"vip-network":    vipNetwork,
@@ -459,7 +562,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				IP:      "9.8.7.6",
 			}
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"vip-network":    vipNetwork,
 				"static-network": staticNetwork,
 			}, nil)
@@ -492,7 +595,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 					},
 				)
 
-				err := netManager.SetupNetworking(boshsettings.Networks{
+				_, err := netManager.SetupNetworking(boshsettings.Networks{
 					"static-network": staticNetworkWithoutMAC,
 				}, nil)
 				Expect(err).ToNot(HaveOccurred())
@@ -510,7 +613,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 					[]string{"virtual"},
 				)
 
-				err := netManager.SetupNetworking(boshsettings.Networks{
+				_, err := netManager.SetupNetworking(boshsettings.Networks{
 					"static-network": staticNetworkWithoutMAC,
 				}, nil)
 				Expect(err).ToNot(HaveOccurred())
@@ -552,7 +655,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				boship.NewSimpleInterfaceAddress("eth1", "5.6.7.8"),
 			}
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"static-1": staticNetwork,
 				"static-2": secondStaticNetwork,
 			}, nil)
@@ -55,3 +55,18 @@ func (r defaultNetworkResolver) GetDefaultNetwork() (boshsettings.Network, error
 
 	return network, bosherr.Error("Failed to find default route")
 }
+
+func (r defaultNetworkResolver) GetDefaultGatewayInterface() (string, error) {
+	routes, err := r.routesSearcher.SearchRoutes()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Searching routes")
+	}
+
+	for _, route := range routes {
+		if route.IsDefault() {
+			return route.InterfaceName, nil
+		}
+	}
+
+	return "", bosherr.Error("Failed to find default route")
+}
@@ -119,4 +119,35 @@ var _ = Describe("defaultNetworkResolver", func() {
 			})
 		})
 	})
+
+	Describe("GetDefaultGatewayInterface", func() {
+		It("returns interface of the default route", func() {
+			routesSearcher.SearchRoutesRoutes = []Route{
+				Route{Destination: "10.0.0.0", Gateway: "0.0.0.0", InterfaceName: "eth0"},
+				Route{Destination: "0.0.0.0", Gateway: "10.0.16.1", InterfaceName: "eth1"},
+			}
+
+			iface, err := resolver.GetDefaultGatewayInterface()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iface).To(Equal("eth1"))
+		})
+
+		It("returns error when there is no default route", func() {
+			routesSearcher.SearchRoutesRoutes = []Route{
+				Route{Destination: "10.0.0.0", Gateway: "0.0.0.0", InterfaceName: "eth0"},
+			}
+
+			_, err := resolver.GetDefaultGatewayInterface()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Failed to find default route"))
+		})
+
+		It("returns error if searching routes fails", func() {
+			routesSearcher.SearchRoutesErr = errors.New("fake-search-routes-err")
+
+			_, err := resolver.GetDefaultGatewayInterface()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-search-routes-err"))
+		})
+	})
 })
@@ -0,0 +1,138 @@
+package net
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	DHCPClientDhclient        = "dhclient"
+	DHCPClientSystemdNetworkd = "systemd-networkd"
+)
+
+type DHCPClient interface {
+	// ConfigPath returns location of the client's configuration file
+	ConfigPath() string
+
+	// RenderConfig generates configuration that requests leases
+	// for given interfaces and prefers given DNS servers
+	RenderConfig(ifaceNames []string, dnsServers []string) ([]byte, error)
+
+	// RestartCommand returns command that is run so that
+	// the client picks up changed configuration
+	RestartCommand() []string
+
+	// ConfiguresInterfaces is true when client brings up DHCP interfaces
+	// by itself so that they must be left out of /etc/network/interfaces
+	ConfiguresInterfaces() bool
+}
+
+// NewDHCPClient returns client with given name;
+// client is detected from the image when name is empty
+func NewDHCPClient(name string, fs boshsys.FileSystem) (DHCPClient, error) {
+	if name == "" {
+		name = DetectDHCPClient(fs)
+	}
+
+	switch name {
+	case DHCPClientDhclient:
+		return NewDhclientDHCPClient(), nil
+	case DHCPClientSystemdNetworkd:
+		return NewSystemdNetworkdDHCPClient(), nil
+	}
+
+	return nil, bosherr.Errorf("Unknown DHCP client '%s'", name)
+}
+
+// DetectDHCPClient picks systemd-networkd only on images that do not ship dhclient
+func DetectDHCPClient(fs boshsys.FileSystem) string {
+	if !fs.FileExists("/sbin/dhclient") && fs.FileExists("/lib/systemd/systemd-networkd") {
+		return DHCPClientSystemdNetworkd
+	}
+	return DHCPClientDhclient
+}
+
+type dhclientDHCPClient struct{}
+
+func NewDhclientDHCPClient() DHCPClient {
+	return dhclientDHCPClient{}
+}
+
+func (c dhclientDHCPClient) ConfigPath() string { return "/etc/dhcp/dhclient.conf" }
+
+func (c dhclientDHCPClient) RenderConfig(_ []string, dnsServers []string) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{})
+	t := template.Must(template.New("dhcp-config").Parse(ubuntuDHCPConfigTemplate))
+
+	// Keep DNS servers in the order specified by the network
+	// because they are added by a *single* DHCP's prepend command
+	dnsServersList := strings.Join(dnsServers, ", ")
+	err := t.Execute(buffer, dnsServersList)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating config from template")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Stale dhclient processes are killed; ifup starts new ones
+func (c dhclientDHCPClient) RestartCommand() []string { return []string{"pkill", "dhclient"} }
+
+// ifup starts dhclient for interfaces configured with 'inet dhcp'
+func (c dhclientDHCPClient) ConfiguresInterfaces() bool { return false }
+
+type systemdNetworkdDHCPClient struct{}
+
+func NewSystemdNetworkdDHCPClient() DHCPClient {
+	return systemdNetworkdDHCPClient{}
+}
+
+func (c systemdNetworkdDHCPClient) ConfigPath() string {
+	return "/etc/systemd/network/10-bosh-dhcp.network"
+}
+
+func (c systemdNetworkdDHCPClient) RenderConfig(ifaceNames []string, dnsServers []string) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{})
+	t := template.Must(template.New("networkd-dhcp-config").Parse(systemdNetworkdDHCPConfigTemplate))
+
+	err := t.Execute(buffer, systemdNetworkdDHCPConfigArg{
+		IfaceNames: strings.Join(ifaceNames, " "),
+		DNSServers: dnsServers,
+	})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating config from template")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c systemdNetworkdDHCPClient) RestartCommand() []string {
+	return []string{"systemctl", "restart", "systemd-networkd"}
+}
+
+func (c systemdNetworkdDHCPClient) ConfiguresInterfaces() bool { return true }
+
+type systemdNetworkdDHCPConfigArg struct {
+	IfaceNames string
+	DNSServers []string
+}
+
+// DNS servers listed in [Network] section are used in addition to
+// the ones received from DHCP and take precedence over them
+const systemdNetworkdDHCPConfigTemplate = `# Generated by bosh-agent
+
+[Match]
+Name={{ .IfaceNames }}
+
+[Network]
+DHCP=ipv4
+{{ range .DNSServers }}DNS={{ . }}
+{{ end }}
+[DHCP]
+SendHostname=true
+UseMTU=true
+`
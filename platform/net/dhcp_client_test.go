@@ -0,0 +1,138 @@
+package net_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/net"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("DHCPClient", func() {
+	Describe("DetectDHCPClient", func() {
+		var fs *fakesys.FakeFileSystem
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+		})
+
+		It("returns dhclient when dhclient is installed", func() {
+			fs.WriteFileString("/sbin/dhclient", "")
+			fs.WriteFileString("/lib/systemd/systemd-networkd", "")
+			Expect(DetectDHCPClient(fs)).To(Equal("dhclient"))
+		})
+
+		It("returns systemd-networkd when only systemd-networkd is installed", func() {
+			fs.WriteFileString("/lib/systemd/systemd-networkd", "")
+			Expect(DetectDHCPClient(fs)).To(Equal("systemd-networkd"))
+		})
+
+		It("returns dhclient when neither is found", func() {
+			Expect(DetectDHCPClient(fs)).To(Equal("dhclient"))
+		})
+	})
+
+	Describe("NewDHCPClient", func() {
+		var fs *fakesys.FakeFileSystem
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+		})
+
+		It("returns configured client", func() {
+			client, err := NewDHCPClient("systemd-networkd", fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).To(Equal(NewSystemdNetworkdDHCPClient()))
+
+			client, err = NewDHCPClient("dhclient", fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).To(Equal(NewDhclientDHCPClient()))
+		})
+
+		It("returns detected client when it is not configured", func() {
+			fs.WriteFileString("/lib/systemd/systemd-networkd", "")
+
+			client, err := NewDHCPClient("", fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client).To(Equal(NewSystemdNetworkdDHCPClient()))
+		})
+
+		It("returns error when client is unknown", func() {
+			_, err := NewDHCPClient("fake-client", fs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unknown DHCP client 'fake-client'"))
+		})
+	})
+
+	Describe("dhclient", func() {
+		var client DHCPClient
+
+		BeforeEach(func() {
+			client = NewDhclientDHCPClient()
+		})
+
+		It("writes to dhclient.conf", func() {
+			Expect(client.ConfigPath()).To(Equal("/etc/dhcp/dhclient.conf"))
+		})
+
+		It("renders DNS servers in a single prepend directive", func() {
+			contents, err := client.RenderConfig([]string{"eth0"}, []string{"8.8.8.8", "9.9.9.9"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("send host-name \"<hostname>\";"))
+			Expect(string(contents)).To(HaveSuffix("\nprepend domain-name-servers 8.8.8.8, 9.9.9.9;\n"))
+		})
+
+		It("does not render prepend directive without DNS servers", func() {
+			contents, err := client.RenderConfig([]string{"eth0"}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).ToNot(ContainSubstring("prepend"))
+		})
+
+		It("restarts by killing dhclient", func() {
+			Expect(client.RestartCommand()).To(Equal([]string{"pkill", "dhclient"}))
+		})
+
+		It("leaves bringing up interfaces to ifup", func() {
+			Expect(client.ConfiguresInterfaces()).To(BeFalse())
+		})
+	})
+
+	Describe("systemd-networkd", func() {
+		var client DHCPClient
+
+		BeforeEach(func() {
+			client = NewSystemdNetworkdDHCPClient()
+		})
+
+		It("writes a networkd network file", func() {
+			Expect(client.ConfigPath()).To(Equal("/etc/systemd/network/10-bosh-dhcp.network"))
+		})
+
+		It("renders interfaces and DNS servers", func() {
+			contents, err := client.RenderConfig([]string{"eth0", "eth1"}, []string{"8.8.8.8", "9.9.9.9"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal(`# Generated by bosh-agent
+
+[Match]
+Name=eth0 eth1
+
+[Network]
+DHCP=ipv4
+DNS=8.8.8.8
+DNS=9.9.9.9
+
+[DHCP]
+SendHostname=true
+UseMTU=true
+`))
+		})
+
+		It("restarts systemd-networkd", func() {
+			Expect(client.RestartCommand()).To(Equal([]string{"systemctl", "restart", "systemd-networkd"}))
+		})
+
+		It("brings up interfaces by itself", func() {
+			Expect(client.ConfiguresInterfaces()).To(BeTrue())
+		})
+	})
+})
@@ -8,9 +8,16 @@ type FakeDefaultNetworkResolver struct {
 	GetDefaultNetworkNetwork boshsettings.Network
 	GetDefaultNetworkErr     error
 	GetDefaultNetworkCalled  bool
+
+	GetDefaultGatewayInterfaceName string
+	GetDefaultGatewayInterfaceErr  error
 }
 
 func (r *FakeDefaultNetworkResolver) GetDefaultNetwork() (boshsettings.Network, error) {
 	r.GetDefaultNetworkCalled = true
 	return r.GetDefaultNetworkNetwork, r.GetDefaultNetworkErr
 }
+
+func (r *FakeDefaultNetworkResolver) GetDefaultGatewayInterface() (string, error) {
+	return r.GetDefaultGatewayInterfaceName, r.GetDefaultGatewayInterfaceErr
+}
@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	boshnet "github.com/cloudfoundry/bosh-agent/platform/net"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 )
 
@@ -8,6 +9,7 @@ type FakeManager struct {
 	FakeDefaultNetworkResolver
 
 	SetupNetworkingNetworks boshsettings.Networks
+	SetupNetworkingResult   boshnet.SetupNetworkingResult
 	SetupNetworkingErr      error
 
 	GetConfiguredNetworkInterfacesInterfaces []string
@@ -17,9 +19,9 @@ type FakeManager struct {
 	SetupDhcpErr      error
 }
 
-func (net *FakeManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+func (net *FakeManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) (boshnet.SetupNetworkingResult, error) {
 	net.SetupNetworkingNetworks = networks
-	return net.SetupNetworkingErr
+	return net.SetupNetworkingResult, net.SetupNetworkingErr
 }
 
 func (net *FakeManager) GetConfiguredNetworkInterfaces() ([]string, error) {
@@ -1,6 +1,9 @@
 package net
 
 import (
+	"fmt"
+	"sort"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -16,6 +19,9 @@ type StaticInterfaceConfiguration struct {
 	IsDefaultForGateway bool
 	Mac                 string
 	Gateway             string
+	Mtu                 int
+	Bond                *BondInterfaceConfiguration
+	Vlan                *VlanInterfaceConfiguration
 }
 
 type StaticInterfaceConfigurations []StaticInterfaceConfiguration
@@ -34,10 +40,30 @@ func (configs StaticInterfaceConfigurations) Swap(i, j int) {
 
 type DHCPInterfaceConfiguration struct {
 	Name string
+	Mtu  int
+	Bond *BondInterfaceConfiguration
+	Vlan *VlanInterfaceConfiguration
 }
 
 type DHCPInterfaceConfigurations []DHCPInterfaceConfiguration
 
+type BondInterfaceConfiguration struct {
+	Mode string
+
+	// Names of slave interfaces sorted alphabetically
+	Slaves []string
+}
+
+// maxVlanID is the highest VLAN ID allowed by 802.1Q; 0 and 4095 are reserved
+const maxVlanID = 4094
+
+type VlanInterfaceConfiguration struct {
+	ID int
+
+	// Name of interface carrying tagged traffic
+	RawDevice string
+}
+
 func (configs DHCPInterfaceConfigurations) Len() int {
 	return len(configs)
 }
@@ -55,24 +81,64 @@ type InterfaceConfigurationCreator interface {
 }
 
 type interfaceConfigurationCreator struct {
-	logger boshlog.Logger
-	logTag string
+	dhcpDisabled bool
+	nameResolver InterfaceNameResolver
+	logger       boshlog.Logger
+	logTag       string
 }
 
-func NewInterfaceConfigurationCreator(logger boshlog.Logger) InterfaceConfigurationCreator {
+func NewInterfaceConfigurationCreator(nameResolver InterfaceNameResolver, logger boshlog.Logger) InterfaceConfigurationCreator {
 	return interfaceConfigurationCreator{
-		logger: logger,
-		logTag: "interfaceConfigurationCreator",
+		nameResolver: nameResolver,
+		logger:       logger,
+		logTag:       "interfaceConfigurationCreator",
 	}
 }
 
-func (creator interfaceConfigurationCreator) createInterfaceConfiguration(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration, ifaceName string, networkSettings boshsettings.Network) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
+// NewStaticInterfaceConfigurationCreator never falls back to DHCP;
+// every network must specify its IP, netmask and gateway and
+// interfaces without network settings are left unconfigured.
+func NewStaticInterfaceConfigurationCreator(nameResolver InterfaceNameResolver, logger boshlog.Logger) InterfaceConfigurationCreator {
+	return interfaceConfigurationCreator{
+		dhcpDisabled: true,
+		nameResolver: nameResolver,
+		logger:       logger,
+		logTag:       "interfaceConfigurationCreator",
+	}
+}
+
+func (creator interfaceConfigurationCreator) createInterfaceConfiguration(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration, ifaceName string, networkSettings boshsettings.Network, bond *BondInterfaceConfiguration) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
 	creator.logger.Debug(creator.logTag, "Creating network configuration with settings: %s", networkSettings)
 
-	if networkSettings.IsDHCP() || networkSettings.Mac == "" {
+	hasNetworkSettings := networkSettings.Mac != "" || bond != nil
+
+	if creator.dhcpDisabled && !hasNetworkSettings {
+		creator.logger.Debug(creator.logTag, "Skipping interface '%s' without network settings", ifaceName)
+		return staticConfigs, dhcpConfigs, nil
+	}
+
+	var vlan *VlanInterfaceConfiguration
+
+	if networkSettings.Vlan < 0 || networkSettings.Vlan > maxVlanID {
+		return nil, nil, bosherr.Errorf("VLAN %d of interface '%s' must be between 1 and %d", networkSettings.Vlan, ifaceName, maxVlanID)
+	}
+
+	if networkSettings.Vlan > 0 {
+		if bond != nil {
+			return nil, nil, bosherr.Errorf("Bond '%s' cannot be combined with VLAN %d", ifaceName, networkSettings.Vlan)
+		}
+
+		vlan = &VlanInterfaceConfiguration{ID: networkSettings.Vlan, RawDevice: ifaceName}
+		ifaceName = fmt.Sprintf("%s.%d", ifaceName, networkSettings.Vlan)
+	}
+
+	if !creator.dhcpDisabled && (networkSettings.IsDHCP() || !hasNetworkSettings) {
 		creator.logger.Debug(creator.logTag, "Using dhcp networking")
 		dhcpConfigs = append(dhcpConfigs, DHCPInterfaceConfiguration{
 			Name: ifaceName,
+			Mtu:  networkSettings.Mtu,
+			Bond: bond,
+			Vlan: vlan,
 		})
 	} else {
 		creator.logger.Debug(creator.logTag, "Using static networking")
@@ -90,12 +156,121 @@ func (creator interfaceConfigurationCreator) createInterfaceConfiguration(static
 			Broadcast:           broadcastAddress,
 			Mac:                 networkSettings.Mac,
 			Gateway:             networkSettings.Gateway,
+			Mtu:                 networkSettings.Mtu,
+			Bond:                bond,
+			Vlan:                vlan,
 		})
 	}
 	return staticConfigs, dhcpConfigs, nil
 }
 
 func (creator interfaceConfigurationCreator) CreateInterfaceConfigurations(networks boshsettings.Networks, interfacesByMAC map[string]string) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
+	networks, err := creator.resolveNamedInterfaces(networks, interfacesByMAC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if creator.dhcpDisabled {
+		err := creator.validateStaticNetworks(networks, interfacesByMAC)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	bondStaticConfigs, bondDHCPConfigs, networks, interfacesByMAC, err := creator.createBondInterfaceConfigurations(networks, interfacesByMAC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	staticConfigs, dhcpConfigs, err := creator.createNonBondInterfaceConfigurations(networks, interfacesByMAC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(bondStaticConfigs, staticConfigs...), append(bondDHCPConfigs, dhcpConfigs...), nil
+}
+
+// resolveNamedInterfaces sets MAC address of interface resolved for networks
+// that specify interface name so that they are matched like other networks
+func (creator interfaceConfigurationCreator) resolveNamedInterfaces(networks boshsettings.Networks, interfacesByMAC map[string]string) (boshsettings.Networks, error) {
+	resolvedNetworks := boshsettings.Networks{}
+
+	for name, network := range networks {
+		if network.Interface != "" && !network.IsBond() {
+			ifaceName, err := creator.nameResolver.ResolveInterfaceName(network, interfacesByMAC)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Resolving interface for network '%s'", name)
+			}
+
+			for mac, candidateName := range interfacesByMAC {
+				if candidateName == ifaceName {
+					network.Mac = mac
+				}
+			}
+		}
+
+		resolvedNetworks[name] = network
+	}
+
+	return resolvedNetworks, nil
+}
+
+// createBondInterfaceConfigurations returns remaining networks and interfaces
+// without bond networks and their slave interfaces
+func (creator interfaceConfigurationCreator) createBondInterfaceConfigurations(networks boshsettings.Networks, interfacesByMAC map[string]string) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, boshsettings.Networks, map[string]string, error) {
+	staticConfigs := []StaticInterfaceConfiguration{}
+	dhcpConfigs := []DHCPInterfaceConfiguration{}
+	remainingNetworks := boshsettings.Networks{}
+	remainingInterfacesByMAC := map[string]string{}
+
+	for mac, ifaceName := range interfacesByMAC {
+		remainingInterfacesByMAC[mac] = ifaceName
+	}
+
+	networkNames := []string{}
+	for name := range networks {
+		networkNames = append(networkNames, name)
+	}
+
+	sort.Strings(networkNames)
+
+	for _, name := range networkNames {
+		networkSettings := networks[name]
+		if !networkSettings.IsBond() {
+			remainingNetworks[name] = networkSettings
+			continue
+		}
+
+		if networkSettings.Bond.Name == "" || len(networkSettings.Bond.Slaves) == 0 {
+			return nil, nil, nil, nil, bosherr.Errorf("Network '%s' must specify bond name and slaves", name)
+		}
+
+		bond := &BondInterfaceConfiguration{Mode: networkSettings.Bond.Mode}
+
+		for _, slaveMac := range networkSettings.Bond.Slaves {
+			slaveName, found := remainingInterfacesByMAC[slaveMac]
+			if !found {
+				return nil, nil, nil, nil, bosherr.Errorf("No device found for slave of bond '%s' with MAC address '%s'", networkSettings.Bond.Name, slaveMac)
+			}
+
+			bond.Slaves = append(bond.Slaves, slaveName)
+			delete(remainingInterfacesByMAC, slaveMac)
+		}
+
+		sort.Strings(bond.Slaves)
+
+		var err error
+
+		staticConfigs, dhcpConfigs, err = creator.createInterfaceConfiguration(staticConfigs, dhcpConfigs, networkSettings.Bond.Name, networkSettings, bond)
+		if err != nil {
+			return nil, nil, nil, nil, bosherr.WrapErrorf(err, "Creating bond interface configuration for network '%s'", name)
+		}
+	}
+
+	return staticConfigs, dhcpConfigs, remainingNetworks, remainingInterfacesByMAC, nil
+}
+
+func (creator interfaceConfigurationCreator) createNonBondInterfaceConfigurations(networks boshsettings.Networks, interfacesByMAC map[string]string) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
 	// In cases where we only have one network and it has no MAC address (either because the IAAS doesn't give us one or
 	// it's an old CPI), if we only have one interface, we should map them
 	if len(networks) == 1 && len(interfacesByMAC) == 1 {
@@ -103,7 +278,7 @@ func (creator interfaceConfigurationCreator) CreateInterfaceConfigurations(netwo
 		if networkSettings.Mac == "" {
 			var ifaceName string
 			networkSettings.Mac, ifaceName = creator.getFirstInterface(interfacesByMAC)
-			return creator.createInterfaceConfiguration([]StaticInterfaceConfiguration{}, []DHCPInterfaceConfiguration{}, ifaceName, networkSettings)
+			return creator.createInterfaceConfiguration([]StaticInterfaceConfiguration{}, []DHCPInterfaceConfiguration{}, ifaceName, networkSettings, nil)
 		}
 	}
 
@@ -111,36 +286,114 @@ func (creator interfaceConfigurationCreator) CreateInterfaceConfigurations(netwo
 }
 
 func (creator interfaceConfigurationCreator) createMultipleInterfaceConfigurations(networks boshsettings.Networks, interfacesByMAC map[string]string) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
-	if len(interfacesByMAC) < len(networks) {
-		return nil, nil, bosherr.Errorf("Number of network settings '%d' is greater than the number of network devices '%d'", len(networks), len(interfacesByMAC))
+	networkNamesByMAC, err := creator.groupNetworksByMAC(networks)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	for name := range networks {
-		if mac := networks[name].Mac; mac != "" {
-			if _, ok := interfacesByMAC[mac]; !ok {
-				return nil, nil, bosherr.Errorf("No device found for network '%s' with MAC address '%s'", name, mac)
-			}
+	// Networks tagged with VLANs share interface with other networks on the same MAC address
+	requiredInterfaces := len(networkNamesByMAC)
+	for _, network := range networks {
+		if network.Mac == "" {
+			requiredInterfaces++
+		}
+	}
+
+	if len(interfacesByMAC) < requiredInterfaces {
+		return nil, nil, bosherr.Errorf("Number of network settings '%d' is greater than the number of network devices '%d'", requiredInterfaces, len(interfacesByMAC))
+	}
+
+	for mac, names := range networkNamesByMAC {
+		if _, ok := interfacesByMAC[mac]; !ok {
+			return nil, nil, bosherr.Errorf("No device found for network '%s' with MAC address '%s'", names[0], mac)
 		}
 	}
 
 	// Configure interfaces with network settings matching MAC address.
 	// If we cannot find a network setting with a matching MAC address, configure that interface as DHCP
-	var networkSettings boshsettings.Network
-	var err error
 	staticConfigs := []StaticInterfaceConfiguration{}
 	dhcpConfigs := []DHCPInterfaceConfiguration{}
 
 	for mac, ifaceName := range interfacesByMAC {
-		networkSettings, _ = networks.NetworkForMac(mac)
-		staticConfigs, dhcpConfigs, err = creator.createInterfaceConfiguration(staticConfigs, dhcpConfigs, ifaceName, networkSettings)
-		if err != nil {
-			return nil, nil, bosherr.WrapError(err, "Creating interface configuration")
+		names, found := networkNamesByMAC[mac]
+		if !found {
+			staticConfigs, dhcpConfigs, err = creator.createInterfaceConfiguration(staticConfigs, dhcpConfigs, ifaceName, boshsettings.Network{}, nil)
+			if err != nil {
+				return nil, nil, bosherr.WrapError(err, "Creating interface configuration")
+			}
+			continue
+		}
+
+		for _, name := range names {
+			staticConfigs, dhcpConfigs, err = creator.createInterfaceConfiguration(staticConfigs, dhcpConfigs, ifaceName, networks[name], nil)
+			if err != nil {
+				return nil, nil, bosherr.WrapErrorf(err, "Creating interface configuration for network '%s'", name)
+			}
 		}
 	}
 
 	return staticConfigs, dhcpConfigs, nil
 }
 
+// groupNetworksByMAC returns sorted network names for each MAC address;
+// only networks tagged with distinct VLANs and at most one untagged network
+// can share MAC address
+func (creator interfaceConfigurationCreator) groupNetworksByMAC(networks boshsettings.Networks) (map[string][]string, error) {
+	networkNames := []string{}
+	for name := range networks {
+		networkNames = append(networkNames, name)
+	}
+
+	sort.Strings(networkNames)
+
+	networkNamesByMAC := map[string][]string{}
+
+	for _, name := range networkNames {
+		network := networks[name]
+
+		if network.Mac == "" {
+			continue
+		}
+
+		for _, otherName := range networkNamesByMAC[network.Mac] {
+			if networks[otherName].Vlan == network.Vlan {
+				if network.Vlan == 0 {
+					return nil, bosherr.Errorf("Networks '%s' and '%s' with MAC address '%s' must be tagged with VLANs", otherName, name, network.Mac)
+				}
+				return nil, bosherr.Errorf("Networks '%s' and '%s' with MAC address '%s' are tagged with the same VLAN %d", otherName, name, network.Mac, network.Vlan)
+			}
+		}
+
+		networkNamesByMAC[network.Mac] = append(networkNamesByMAC[network.Mac], name)
+	}
+
+	return networkNamesByMAC, nil
+}
+
+func (creator interfaceConfigurationCreator) validateStaticNetworks(networks boshsettings.Networks, interfacesByMAC map[string]string) error {
+	networkNames := []string{}
+	for name := range networks {
+		networkNames = append(networkNames, name)
+	}
+
+	sort.Strings(networkNames)
+
+	for _, name := range networkNames {
+		network := networks[name]
+
+		if network.IP == "" || network.Netmask == "" || network.Gateway == "" {
+			return bosherr.Errorf("Network '%s' must specify ip, netmask and gateway when DHCP is disabled", name)
+		}
+
+		// Only single network can be matched to single interface without MAC address
+		if network.Mac == "" && !network.IsBond() && (len(networks) > 1 || len(interfacesByMAC) > 1) {
+			return bosherr.Errorf("Network '%s' must specify MAC address when DHCP is disabled", name)
+		}
+	}
+
+	return nil
+}
+
 func (creator interfaceConfigurationCreator) getFirstNetwork(networks boshsettings.Networks) boshsettings.Network {
 	for networkName := range networks {
 		return networks[networkName]
@@ -23,7 +23,7 @@ func describeInterfaceConfigurationCreator() {
 
 	BeforeEach(func() {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(logger)
+		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(NewInterfaceNameResolver(), logger)
 		dhcpNetwork = boshsettings.Network{
 			Type:    "dynamic",
 			Default: []string{"dns"},
@@ -225,6 +225,48 @@ func describeInterfaceConfigurationCreator() {
 					})
 				})
 
+				Context("and some networks specify interface name instead of MAC address", func() {
+					BeforeEach(func() {
+						staticNetworkWithoutMAC.Interface = "ens5"
+						networks["foo"] = staticNetworkWithoutMAC
+						networks["bar"] = dhcpNetwork
+						interfacesByMAC["fake-ens5-mac"] = "ens5"
+						interfacesByMAC[dhcpNetwork.Mac] = "dhcp-interface-name"
+					})
+
+					It("configures network on interface with that name", func() {
+						staticInterfaceConfigurations, dhcpInterfaceConfigurations, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(staticInterfaceConfigurations).To(Equal([]StaticInterfaceConfiguration{
+							StaticInterfaceConfiguration{
+								Name:      "ens5",
+								Address:   "1.2.3.4",
+								Netmask:   "255.255.255.0",
+								Network:   "1.2.3.0",
+								Broadcast: "1.2.3.255",
+								Mac:       "fake-ens5-mac",
+								Gateway:   "3.4.5.6",
+							},
+						}))
+
+						Expect(dhcpInterfaceConfigurations).To(Equal([]DHCPInterfaceConfiguration{
+							DHCPInterfaceConfiguration{
+								Name: "dhcp-interface-name",
+							},
+						}))
+					})
+
+					It("returns an error when there is no interface with that name", func() {
+						staticNetworkWithoutMAC.Interface = "enp0s3"
+						networks["foo"] = staticNetworkWithoutMAC
+
+						_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Resolving interface for network 'foo'"))
+					})
+				})
+
 				Context("and some networks MAC addresses that don't match", func() {
 					BeforeEach(func() {
 						networks["foo"] = staticNetwork
@@ -241,6 +283,73 @@ func describeInterfaceConfigurationCreator() {
 			})
 		})
 
+		Context("when networks share MAC address", func() {
+			var vlanNetwork boshsettings.Network
+
+			BeforeEach(func() {
+				vlanNetwork = boshsettings.Network{
+					IP:      "10.0.0.5",
+					Netmask: "255.255.255.0",
+					Gateway: "10.0.0.1",
+					Mac:     staticNetwork.Mac,
+					Vlan:    100,
+				}
+
+				networks["untagged"] = staticNetwork
+				networks["vlan-100"] = vlanNetwork
+				interfacesByMAC[staticNetwork.Mac] = "eth0"
+			})
+
+			It("configures every VLAN network on its own tagged interface", func() {
+				vlanNetwork.Vlan = 200
+				vlanNetwork.IP = "10.0.1.5"
+				vlanNetwork.Gateway = "10.0.1.1"
+				networks["vlan-200"] = vlanNetwork
+
+				staticConfigs, dhcpConfigs, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dhcpConfigs).To(BeEmpty())
+
+				Expect(staticConfigs).To(HaveLen(3))
+				Expect(staticConfigs[0].Name).To(Equal("eth0"))
+				Expect(staticConfigs[0].Vlan).To(BeNil())
+				Expect(staticConfigs[1].Name).To(Equal("eth0.100"))
+				Expect(staticConfigs[1].Address).To(Equal("10.0.0.5"))
+				Expect(staticConfigs[1].Vlan).To(Equal(&VlanInterfaceConfiguration{ID: 100, RawDevice: "eth0"}))
+				Expect(staticConfigs[2].Name).To(Equal("eth0.200"))
+				Expect(staticConfigs[2].Address).To(Equal("10.0.1.5"))
+				Expect(staticConfigs[2].Vlan).To(Equal(&VlanInterfaceConfiguration{ID: 200, RawDevice: "eth0"}))
+			})
+
+			It("returns an error if networks are tagged with the same VLAN", func() {
+				networks["vlan-100-again"] = vlanNetwork
+
+				_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Networks 'vlan-100' and 'vlan-100-again' with MAC address 'fake-static-mac-address' are tagged with the same VLAN 100"))
+			})
+
+			It("returns an error if several networks are untagged", func() {
+				networks["untagged-again"] = staticNetwork
+
+				_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Networks 'untagged' and 'untagged-again' with MAC address 'fake-static-mac-address' must be tagged with VLANs"))
+			})
+		})
+
+		It("returns an error if VLAN is out of range", func() {
+			for _, vlan := range []int{-1, 4095} {
+				staticNetwork.Vlan = vlan
+				networks["foo"] = staticNetwork
+				interfacesByMAC[staticNetwork.Mac] = "eth0"
+
+				_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("must be between 1 and 4094"))
+			}
+		})
+
 		Context("when the number of networks does not match the number of devices", func() {
 			BeforeEach(func() {
 				networks["foo"] = staticNetwork
@@ -258,6 +367,126 @@ func describeInterfaceConfigurationCreator() {
 		})
 	})
 
+	Describe("CreateInterfaceConfigurations when DHCP is disabled", func() {
+		var interfacesByMAC map[string]string
+
+		BeforeEach(func() {
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			interfaceConfigurationCreator = NewStaticInterfaceConfigurationCreator(NewInterfaceNameResolver(), logger)
+
+			interfacesByMAC = map[string]string{
+				staticNetwork.Mac:                   "static-interface-name",
+				staticNetworkWithDefaultGateway.Mac: "static-interface-name-with-default-gateway",
+				"fake-unused-mac-address":           "unused-interface-name",
+			}
+		})
+
+		It("configures every network statically and leaves interfaces without networks unconfigured", func() {
+			dynamicNetworkWithIP := staticNetworkWithDefaultGateway
+			dynamicNetworkWithIP.Type = "dynamic"
+
+			networks := boshsettings.Networks{
+				"foo": staticNetwork,
+				"bar": dynamicNetworkWithIP,
+			}
+
+			staticInterfaceConfigurations, dhcpInterfaceConfigurations, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(staticInterfaceConfigurations).To(ConsistOf(
+				StaticInterfaceConfiguration{
+					Name:                "static-interface-name",
+					Address:             "1.2.3.4",
+					Netmask:             "255.255.255.0",
+					Network:             "1.2.3.0",
+					IsDefaultForGateway: false,
+					Broadcast:           "1.2.3.255",
+					Mac:                 "fake-static-mac-address",
+					Gateway:             "3.4.5.6",
+				},
+				StaticInterfaceConfiguration{
+					Name:                "static-interface-name-with-default-gateway",
+					Address:             "5.6.7.8",
+					Netmask:             "255.255.255.0",
+					Network:             "5.6.7.0",
+					IsDefaultForGateway: true,
+					Broadcast:           "5.6.7.255",
+					Mac:                 "fake-static-mac-address-with-default-gateway",
+					Gateway:             "5.6.7.1",
+				},
+			))
+			Expect(dhcpInterfaceConfigurations).To(BeEmpty())
+		})
+
+		It("returns an error if network does not specify ip", func() {
+			networkWithoutIP := staticNetwork
+			networkWithoutIP.IP = ""
+
+			networks := boshsettings.Networks{
+				"foo": networkWithoutIP,
+				"bar": staticNetworkWithDefaultGateway,
+			}
+
+			_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Network 'foo' must specify ip, netmask and gateway when DHCP is disabled"))
+		})
+
+		It("returns an error if network cannot be matched to interface without MAC address", func() {
+			networks := boshsettings.Networks{"foo": staticNetworkWithoutMAC}
+
+			_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Network 'foo' must specify MAC address when DHCP is disabled"))
+		})
+	})
+
+	Describe("bonded networks", func() {
+		It("configures network on bond interface and leaves other interfaces on DHCP", func() {
+			networks := boshsettings.Networks{
+				"bond-network": boshsettings.Network{
+					Type:    "manual",
+					IP:      "1.2.3.4",
+					Netmask: "255.255.255.0",
+					Gateway: "3.4.5.6",
+					Bond: &boshsettings.Bond{
+						Name:   "bond0",
+						Mode:   "active-backup",
+						Slaves: []string{"fake-slave-mac-2", "fake-slave-mac-1"},
+					},
+				},
+			}
+			interfacesByMAC := map[string]string{
+				"fake-slave-mac-1": "eth0",
+				"fake-slave-mac-2": "eth1",
+				"fake-other-mac":   "eth2",
+			}
+
+			staticConfigs, dhcpConfigs, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(staticConfigs).To(HaveLen(1))
+			Expect(staticConfigs[0].Name).To(Equal("bond0"))
+			Expect(staticConfigs[0].Address).To(Equal("1.2.3.4"))
+			Expect(staticConfigs[0].Bond).To(Equal(&BondInterfaceConfiguration{
+				Mode:   "active-backup",
+				Slaves: []string{"eth0", "eth1"},
+			}))
+
+			Expect(dhcpConfigs).To(Equal([]DHCPInterfaceConfiguration{{Name: "eth2"}}))
+		})
+
+		It("returns an error if bond does not specify slaves", func() {
+			networks := boshsettings.Networks{
+				"bond-network": boshsettings.Network{Type: "dynamic", Bond: &boshsettings.Bond{Name: "bond0"}},
+			}
+
+			_, _, err := interfaceConfigurationCreator.CreateInterfaceConfigurations(networks, map[string]string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Network 'bond-network' must specify bond name and slaves"))
+		})
+	})
+
 	It("wraps errors calculating Network and Broadcast addresses", func() {
 		invalidNetwork := boshsettings.Network{
 			Type:    "manual",
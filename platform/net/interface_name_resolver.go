@@ -0,0 +1,51 @@
+package net
+
+import (
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// InterfaceNameResolver finds interface that network is configured on
+// since names depend on kernel naming scheme, e.g. eth0 vs ens5 or enp0s3
+type InterfaceNameResolver interface {
+	// interfacesByMAC maps MAC addresses to interface names
+	ResolveInterfaceName(network boshsettings.Network, interfacesByMAC map[string]string) (string, error)
+}
+
+type interfaceNameResolver struct{}
+
+// NewInterfaceNameResolver matches MAC address first, then falls back
+// to interface name configured for network and then to the only interface
+func NewInterfaceNameResolver() InterfaceNameResolver {
+	return interfaceNameResolver{}
+}
+
+func (r interfaceNameResolver) ResolveInterfaceName(network boshsettings.Network, interfacesByMAC map[string]string) (string, error) {
+	if network.Mac != "" {
+		if ifaceName, found := interfacesByMAC[network.Mac]; found {
+			return ifaceName, nil
+		}
+	}
+
+	if network.Interface != "" {
+		for _, ifaceName := range interfacesByMAC {
+			if ifaceName == network.Interface {
+				return ifaceName, nil
+			}
+		}
+
+		return "", bosherr.Errorf("No device found with name '%s'", network.Interface)
+	}
+
+	if network.Mac != "" {
+		return "", bosherr.Errorf("No device found with MAC address '%s'", network.Mac)
+	}
+
+	if len(interfacesByMAC) == 1 {
+		for _, ifaceName := range interfacesByMAC {
+			return ifaceName, nil
+		}
+	}
+
+	return "", bosherr.Errorf("Network without MAC address or interface name cannot be matched to one of %d devices", len(interfacesByMAC))
+}
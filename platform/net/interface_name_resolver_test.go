@@ -0,0 +1,78 @@
+package net_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/net"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+var _ = Describe("InterfaceNameResolver", func() {
+	var (
+		resolver        InterfaceNameResolver
+		interfacesByMAC map[string]string
+	)
+
+	BeforeEach(func() {
+		resolver = NewInterfaceNameResolver()
+		interfacesByMAC = map[string]string{
+			"fake-mac-1": "ens5",
+			"fake-mac-2": "enp0s3",
+		}
+	})
+
+	Context("when network specifies MAC address", func() {
+		It("returns interface with matching MAC address", func() {
+			ifaceName, err := resolver.ResolveInterfaceName(boshsettings.Network{Mac: "fake-mac-2"}, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ifaceName).To(Equal("enp0s3"))
+		})
+
+		It("prefers MAC address over interface name", func() {
+			ifaceName, err := resolver.ResolveInterfaceName(boshsettings.Network{Mac: "fake-mac-2", Interface: "ens5"}, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ifaceName).To(Equal("enp0s3"))
+		})
+
+		It("falls back to interface name when MAC address does not match", func() {
+			ifaceName, err := resolver.ResolveInterfaceName(boshsettings.Network{Mac: "fake-other-mac", Interface: "ens5"}, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ifaceName).To(Equal("ens5"))
+		})
+
+		It("returns error when MAC address does not match and there is no interface name", func() {
+			_, err := resolver.ResolveInterfaceName(boshsettings.Network{Mac: "fake-other-mac"}, interfacesByMAC)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No device found with MAC address 'fake-other-mac'"))
+		})
+	})
+
+	Context("when network specifies only interface name", func() {
+		It("returns interface with that name", func() {
+			ifaceName, err := resolver.ResolveInterfaceName(boshsettings.Network{Interface: "enp0s3"}, interfacesByMAC)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ifaceName).To(Equal("enp0s3"))
+		})
+
+		It("returns error when there is no interface with that name", func() {
+			_, err := resolver.ResolveInterfaceName(boshsettings.Network{Interface: "eth0"}, interfacesByMAC)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No device found with name 'eth0'"))
+		})
+	})
+
+	Context("when network specifies neither MAC address nor interface name", func() {
+		It("returns the only interface", func() {
+			ifaceName, err := resolver.ResolveInterfaceName(boshsettings.Network{}, map[string]string{"fake-mac-1": "ens5"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ifaceName).To(Equal("ens5"))
+		})
+
+		It("returns error when there are several interfaces", func() {
+			_, err := resolver.ResolveInterfaceName(boshsettings.Network{}, interfacesByMAC)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cannot be matched to one of 2 devices"))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package net
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	// Some clouds attach NICs a few seconds after the VM boots
+	interfaceWaitTimeout      = 30 * time.Second
+	interfaceWaitPollInterval = 1 * time.Second
+)
+
+type macAddressDetector func() (map[string]string, error)
+
+// waitForInterfaces polls detectMacAddresses until every network that
+// specifies a MAC address and every bond slave has a matching interface
+// or the timeout elapses. Networks that also specify interface name
+// are not waited for since they can be matched by name.
+// Returns interfaces keyed by MAC address from the last poll.
+func waitForInterfaces(
+	networks boshsettings.Networks,
+	detectMacAddresses macAddressDetector,
+	clock clock.Clock,
+	timeout time.Duration,
+	pollInterval time.Duration,
+) (map[string]string, error) {
+	expectedMacs := []string{}
+	for _, network := range networks {
+		if network.Mac != "" && network.Interface == "" {
+			expectedMacs = append(expectedMacs, network.Mac)
+		}
+		if network.IsBond() {
+			expectedMacs = append(expectedMacs, network.Bond.Slaves...)
+		}
+	}
+
+	sort.Strings(expectedMacs)
+
+	var waited time.Duration
+
+	for {
+		interfacesByMacAddress, err := detectMacAddresses()
+		if err != nil {
+			return nil, err
+		}
+
+		missingMacs := []string{}
+		for _, mac := range expectedMacs {
+			if _, found := interfacesByMacAddress[mac]; !found {
+				missingMacs = append(missingMacs, mac)
+			}
+		}
+
+		if len(missingMacs) == 0 {
+			return interfacesByMacAddress, nil
+		}
+
+		if waited >= timeout {
+			return nil, bosherr.Errorf(
+				"Timed out after %s waiting for network interfaces with MAC addresses '%s'",
+				timeout, strings.Join(missingMacs, "', '"),
+			)
+		}
+
+		clock.Sleep(pollInterval)
+		waited += pollInterval
+	}
+}
@@ -8,7 +8,7 @@ type Manager interface {
 	// SetupNetworking configures network interfaces with either a static ip or dhcp.
 	// If errCh is provided, nil or an error will be sent
 	// upon completion of background network reconfiguration (e.g. arping).
-	SetupNetworking(networks boshsettings.Networks, errCh chan error) error
+	SetupNetworking(networks boshsettings.Networks, errCh chan error) (SetupNetworkingResult, error)
 
 	// Returns the list of interfaces that have configurations for them present
 	GetConfiguredNetworkInterfaces() ([]string, error)
@@ -0,0 +1,46 @@
+package net
+
+import (
+	"sort"
+)
+
+// SetupNetworkingResult describes what SetupNetworking applied
+type SetupNetworkingResult struct {
+	// Sorted names of interfaces configured with static ip
+	StaticInterfaces []string
+
+	// Sorted names of interfaces configured with dhcp
+	DHCPInterfaces []string
+
+	DNSServers []string
+
+	// Whether interfaces were restarted since their configuration changed
+	Restarted bool
+}
+
+func newSetupNetworkingResult(
+	staticConfigs []StaticInterfaceConfiguration,
+	dhcpConfigs []DHCPInterfaceConfiguration,
+	dnsServers []string,
+	restarted bool,
+) SetupNetworkingResult {
+	result := SetupNetworkingResult{
+		StaticInterfaces: []string{},
+		DHCPInterfaces:   []string{},
+		DNSServers:       dnsServers,
+		Restarted:        restarted,
+	}
+
+	for _, config := range staticConfigs {
+		result.StaticInterfaces = append(result.StaticInterfaces, config.Name)
+	}
+
+	for _, config := range dhcpConfigs {
+		result.DHCPInterfaces = append(result.DHCPInterfaces, config.Name)
+	}
+
+	sort.Strings(result.StaticInterfaces)
+	sort.Strings(result.DHCPInterfaces)
+
+	return result
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/pivotal-golang/clock"
+
 	bosharp "github.com/cloudfoundry/bosh-agent/platform/net/arp"
 	boship "github.com/cloudfoundry/bosh-agent/platform/net/ip"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
@@ -25,6 +28,9 @@ type UbuntuNetManager struct {
 	interfaceAddressesValidator   boship.InterfaceAddressesValidator
 	dnsValidator                  DNSValidator
 	addressBroadcaster            bosharp.AddressBroadcaster
+	dhcpClient                    DHCPClient
+	resolvConfOptions             []string
+	clock                         clock.Clock
 	logger                        boshlog.Logger
 }
 
@@ -36,6 +42,9 @@ func NewUbuntuNetManager(
 	interfaceAddressesValidator boship.InterfaceAddressesValidator,
 	dnsValidator DNSValidator,
 	addressBroadcaster bosharp.AddressBroadcaster,
+	dhcpClient DHCPClient,
+	resolvConfOptions []string,
+	clock clock.Clock,
 	logger boshlog.Logger,
 ) Manager {
 	return UbuntuNetManager{
@@ -46,6 +55,9 @@ func NewUbuntuNetManager(
 		interfaceAddressesValidator:   interfaceAddressesValidator,
 		dnsValidator:                  dnsValidator,
 		addressBroadcaster:            addressBroadcaster,
+		dhcpClient:                    dhcpClient,
+		resolvConfOptions:             resolvConfOptions,
+		clock:                         clock,
 		logger:                        logger,
 	}
 }
@@ -80,59 +92,94 @@ func (net UbuntuNetManager) ComputeNetworkConfig(networks boshsettings.Networks)
 		return nil, nil, nil, err
 	}
 
-	dnsNetwork, _ := nonVipNetworks.DefaultNetworkFor("dns")
-	dnsServers := dnsNetwork.DNS
-	return staticConfigs, dhcpConfigs, dnsServers, nil
+	return staticConfigs, dhcpConfigs, nonVipNetworks.DNSServers(), nil
 }
 
-func (net UbuntuNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+func (net UbuntuNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) (SetupNetworkingResult, error) {
 	if networks.IsPreconfigured() {
 		// Note in this case IPs are not broadcasted
-		return net.writeResolvConf(networks)
+		err := net.writeResolvConf(networks)
+		if err != nil {
+			return SetupNetworkingResult{}, err
+		}
+
+		return newSetupNetworkingResult(nil, nil, networks.DNSServers(), false), nil
 	}
 
 	staticConfigs, dhcpConfigs, dnsServers, err := net.ComputeNetworkConfig(networks)
 	if err != nil {
-		return bosherr.WrapError(err, "Computing network configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Computing network configuration")
 	}
 
-	interfacesChanged, err := net.writeNetworkInterfaces(dhcpConfigs, staticConfigs, dnsServers)
+	err = net.setupBonding(staticConfigs, dhcpConfigs)
 	if err != nil {
-		return bosherr.WrapError(err, "Writing network configuration")
+		return SetupNetworkingResult{}, err
+	}
+
+	err = net.setupVlans(staticConfigs, dhcpConfigs)
+	if err != nil {
+		return SetupNetworkingResult{}, err
+	}
+
+	// DHCP interfaces are left to DHCP clients that bring them up by themselves
+	ifupDHCPConfigs := dhcpConfigs
+	if net.dhcpClient.ConfiguresInterfaces() {
+		for _, config := range dhcpConfigs {
+			if config.Bond != nil {
+				return SetupNetworkingResult{}, bosherr.Errorf("Bonded DHCP interface '%s' is not supported by configured DHCP client", config.Name)
+			}
+
+			if config.Mtu != 0 {
+				return SetupNetworkingResult{}, bosherr.Errorf("MTU of DHCP interface '%s' is not supported by configured DHCP client", config.Name)
+			}
+		}
+
+		ifupDHCPConfigs = nil
+	}
+
+	interfacesChanged, err := net.writeNetworkInterfaces(ifupDHCPConfigs, staticConfigs, dnsServers)
+	if err != nil {
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Writing network configuration")
 	}
 
 	dhcpChanged := false
 	if len(dhcpConfigs) > 0 {
-		dhcpChanged, err = net.writeDHCPConfiguration(dnsServers)
+		dhcpChanged, err = net.writeDHCPConfiguration(dhcpConfigs, dnsServers)
+		if err != nil {
+			return SetupNetworkingResult{}, err
+		}
+	} else if net.dhcpClient.ConfiguresInterfaces() {
+		dhcpChanged, err = net.removeDHCPConfiguration()
 		if err != nil {
-			return err
+			return SetupNetworkingResult{}, err
 		}
 	}
 
-	if interfacesChanged || dhcpChanged {
+	restarted := interfacesChanged || dhcpChanged
+	if restarted {
 		err = net.removeDhcpDNSConfiguration()
 		if err != nil {
-			return err
+			return SetupNetworkingResult{}, err
 		}
 
-		net.restartNetworkingInterfaces(net.ifaceNames(dhcpConfigs, staticConfigs))
+		net.restartNetworkingInterfaces(net.ifaceNames(ifupDHCPConfigs, staticConfigs))
 	}
 
 	staticAddresses, dynamicAddresses := net.ifaceAddresses(staticConfigs, dhcpConfigs)
 
 	err = net.interfaceAddressesValidator.Validate(staticAddresses)
 	if err != nil {
-		return bosherr.WrapError(err, "Validating static network configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Validating static network configuration")
 	}
 
 	err = net.dnsValidator.Validate(dnsServers)
 	if err != nil {
-		return bosherr.WrapError(err, "Validating dns configuration")
+		return SetupNetworkingResult{}, bosherr.WrapError(err, "Validating dns configuration")
 	}
 
 	net.broadcastIps(append(staticAddresses, dynamicAddresses...), errCh)
 
-	return nil
+	return newSetupNetworkingResult(staticConfigs, dhcpConfigs, dnsServers, restarted), nil
 }
 
 func (net UbuntuNetManager) GetConfiguredNetworkInterfaces() ([]string, error) {
@@ -161,9 +208,15 @@ func (net UbuntuNetManager) removeDhcpDNSConfiguration() error {
 	// Removing dhcp configuration from /etc/network/interfaces
 	// and restarting network does not stop dhclient if dhcp
 	// is no longer needed. See https://bugs.launchpad.net/ubuntu/+source/dhcp3/+bug/38140
-	_, _, _, err := net.cmdRunner.RunCommand("pkill", "dhclient")
+	restartCmd := net.dhcpClient.RestartCommand()
+	_, _, _, err := net.cmdRunner.RunCommand(restartCmd[0], restartCmd[1:]...)
 	if err != nil {
-		net.logger.Error(UbuntuNetManagerLogTag, "Ignoring failure calling 'pkill dhclient': %s", err)
+		// DHCP interfaces are not configured at all unless client restarts
+		if net.dhcpClient.ConfiguresInterfaces() {
+			return bosherr.WrapErrorf(err, "Restarting DHCP client with '%s'", strings.Join(restartCmd, " "))
+		}
+
+		net.logger.Error(UbuntuNetManagerLogTag, "Ignoring failure calling '%s': %s", strings.Join(restartCmd, " "), err)
 	}
 
 	interfacesByMacAddress, err := net.detectMacAddresses()
@@ -185,7 +238,7 @@ func (net UbuntuNetManager) removeDhcpDNSConfiguration() error {
 }
 
 func (net UbuntuNetManager) buildInterfaces(networks boshsettings.Networks) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, error) {
-	interfacesByMacAddress, err := net.detectMacAddresses()
+	interfacesByMacAddress, err := waitForInterfaces(networks, net.detectMacAddresses, net.clock, interfaceWaitTimeout, interfaceWaitPollInterval)
 	if err != nil {
 		return nil, nil, bosherr.WrapError(err, "Getting network interfaces")
 	}
@@ -224,6 +277,78 @@ func (net UbuntuNetManager) broadcastIps(addresses []boship.InterfaceAddress, er
 	}()
 }
 
+// setupBonding loads bonding driver which ifup needs to create bond interfaces
+func (net UbuntuNetManager) setupBonding(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration) error {
+	hasBond := false
+
+	for _, config := range staticConfigs {
+		hasBond = hasBond || config.Bond != nil
+	}
+
+	for _, config := range dhcpConfigs {
+		hasBond = hasBond || config.Bond != nil
+	}
+
+	if !hasBond {
+		return nil
+	}
+
+	_, _, _, err := net.cmdRunner.RunCommand("modprobe", "bonding")
+	if err != nil {
+		return bosherr.WrapError(err, "Loading bonding kernel module")
+	}
+
+	return nil
+}
+
+// setupVlans creates tagged interfaces so that ifup can configure them
+func (net UbuntuNetManager) setupVlans(staticConfigs []StaticInterfaceConfiguration, dhcpConfigs []DHCPInterfaceConfiguration) error {
+	vlansByIface := map[string]*VlanInterfaceConfiguration{}
+
+	for _, config := range staticConfigs {
+		if config.Vlan != nil {
+			vlansByIface[config.Name] = config.Vlan
+		}
+	}
+
+	for _, config := range dhcpConfigs {
+		if config.Vlan != nil {
+			vlansByIface[config.Name] = config.Vlan
+		}
+	}
+
+	if len(vlansByIface) == 0 {
+		return nil
+	}
+
+	_, _, _, err := net.cmdRunner.RunCommand("modprobe", "8021q")
+	if err != nil {
+		return bosherr.WrapError(err, "Loading 8021q kernel module")
+	}
+
+	ifaceNames := []string{}
+	for ifaceName := range vlansByIface {
+		ifaceNames = append(ifaceNames, ifaceName)
+	}
+
+	sort.Strings(ifaceNames)
+
+	for _, ifaceName := range ifaceNames {
+		if net.fs.FileExists(path.Join("/sys/class/net", ifaceName)) {
+			continue
+		}
+
+		vlan := vlansByIface[ifaceName]
+
+		_, _, _, err = net.cmdRunner.RunCommand("ip", "link", "add", "link", vlan.RawDevice, "name", ifaceName, "type", "vlan", "id", strconv.Itoa(vlan.ID))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating VLAN interface '%s'", ifaceName)
+		}
+	}
+
+	return nil
+}
+
 func (net UbuntuNetManager) restartNetworkingInterfaces(ifaceNames []string) {
 	net.logger.Debug(UbuntuNetManagerLogTag, "Restarting network interfaces")
 
@@ -238,29 +363,46 @@ func (net UbuntuNetManager) restartNetworkingInterfaces(ifaceNames []string) {
 	}
 }
 
-func (net UbuntuNetManager) writeDHCPConfiguration(dnsServers []string) (bool, error) {
-	buffer := bytes.NewBuffer([]byte{})
-	t := template.Must(template.New("dhcp-config").Parse(ubuntuDHCPConfigTemplate))
+func (net UbuntuNetManager) writeDHCPConfiguration(dhcpConfigs DHCPInterfaceConfigurations, dnsServers []string) (bool, error) {
+	ifaceNames := []string{}
+	for _, config := range dhcpConfigs {
+		ifaceNames = append(ifaceNames, config.Name)
+	}
 
-	// Keep DNS servers in the order specified by the network
-	// because they are added by a *single* DHCP's prepend command
-	dnsServersList := strings.Join(dnsServers, ", ")
-	err := t.Execute(buffer, dnsServersList)
+	contents, err := net.dhcpClient.RenderConfig(ifaceNames, dnsServers)
 	if err != nil {
-		return false, bosherr.WrapError(err, "Generating config from template")
+		return false, err
 	}
-	dhclientConfigFile := "/etc/dhcp/dhclient.conf"
-	changed, err := net.fs.ConvergeFileContents(dhclientConfigFile, buffer.Bytes())
+
+	dhcpConfigFile := net.dhcpClient.ConfigPath()
+	changed, err := net.fs.ConvergeFileContents(dhcpConfigFile, contents)
 
 	if err != nil {
-		return changed, bosherr.WrapErrorf(err, "Writing to %s", dhclientConfigFile)
+		return changed, bosherr.WrapErrorf(err, "Writing to %s", dhcpConfigFile)
 	}
 
 	return changed, nil
 }
 
+// removeDHCPConfiguration keeps DHCP client from configuring
+// interfaces of networks that no longer use DHCP
+func (net UbuntuNetManager) removeDHCPConfiguration() (bool, error) {
+	dhcpConfigFile := net.dhcpClient.ConfigPath()
+	if !net.fs.FileExists(dhcpConfigFile) {
+		return false, nil
+	}
+
+	err := net.fs.RemoveAll(dhcpConfigFile)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Removing %s", dhcpConfigFile)
+	}
+
+	return true, nil
+}
+
 type networkInterfaceConfig struct {
 	DNSServers        []string
+	DNSOptions        string
 	StaticConfigs     []StaticInterfaceConfiguration
 	DHCPConfigs       []DHCPInterfaceConfiguration
 	HasDNSNameServers bool
@@ -275,6 +417,7 @@ func (net UbuntuNetManager) writeNetworkInterfaces(dhcpConfigs DHCPInterfaceConf
 		StaticConfigs:     staticConfigs,
 		HasDNSNameServers: true,
 		DNSServers:        dnsServers,
+		DNSOptions:        strings.Join(net.resolvConfOptions, " "),
 	}
 
 	buffer := bytes.NewBuffer([]byte{})
@@ -294,22 +437,44 @@ func (net UbuntuNetManager) writeNetworkInterfaces(dhcpConfigs DHCPInterfaceConf
 	return changed, nil
 }
 
+// Bond slaves are declared before the bond so that ifup enslaves them
+// before bond interface is configured. dhcp method has no mtu option
+// hence MTU of DHCP interfaces is set once they are up.
 const networkInterfacesTemplate = `# Generated by bosh-agent
 auto lo
 iface lo inet loopback
-{{ range .DHCPConfigs }}
+{{ range .DHCPConfigs }}{{ $bondName := .Name }}{{ with .Bond }}{{ range .Slaves }}
+auto {{ . }}
+iface {{ . }} inet manual
+    bond-master {{ $bondName }}
+{{ end }}{{ end }}
 auto {{ .Name }}
 iface {{ .Name }} inet dhcp
-{{ end }}{{ range .StaticConfigs }}
+{{ if .Mtu }}    post-up ip link set dev {{ .Name }} mtu {{ .Mtu }}
+{{ end }}{{ with .Bond }}    bond-mode {{ .Mode }}
+    bond-miimon 100
+    bond-slaves{{ range .Slaves }} {{ . }}{{ end }}
+{{ end }}{{ with .Vlan }}    vlan-raw-device {{ .RawDevice }}
+{{ end }}{{ end }}{{ range .StaticConfigs }}{{ $bondName := .Name }}{{ with .Bond }}{{ range .Slaves }}
+auto {{ . }}
+iface {{ . }} inet manual
+    bond-master {{ $bondName }}
+{{ end }}{{ end }}
 auto {{ .Name }}
 iface {{ .Name }} inet static
     address {{ .Address }}
     network {{ .Network }}
     netmask {{ .Netmask }}
-{{ if .IsDefaultForGateway }}    broadcast {{ .Broadcast }}
+{{ if .Mtu }}    mtu {{ .Mtu }}
+{{ end }}{{ with .Bond }}    bond-mode {{ .Mode }}
+    bond-miimon 100
+    bond-slaves{{ range .Slaves }} {{ . }}{{ end }}
+{{ end }}{{ with .Vlan }}    vlan-raw-device {{ .RawDevice }}
+{{ end }}{{ if .IsDefaultForGateway }}    broadcast {{ .Broadcast }}
     gateway {{ .Gateway }}{{ end }}{{ end }}
 {{ if .DNSServers }}
-dns-nameservers{{ range .DNSServers }} {{ . }}{{ end }}{{ end }}`
+dns-nameservers{{ range .DNSServers }} {{ . }}{{ end }}{{ end }}{{ if and .StaticConfigs .DNSOptions }}
+dns-options {{ .DNSOptions }}{{ end }}`
 
 func (net UbuntuNetManager) detectMacAddresses() (map[string]string, error) {
 	addresses := map[string]string{}
@@ -355,17 +520,16 @@ func (net UbuntuNetManager) writeResolvConf(networks boshsettings.Networks) erro
 
 	const ubuntuResolvConfTemplate = `# Generated by bosh-agent
 {{ range .DNSServers }}nameserver {{ . }}
+{{ end }}{{ if .Options }}options {{ .Options }}
 {{ end }}`
 
 	t := template.Must(template.New("resolv-conf").Parse(ubuntuResolvConfTemplate))
 
-	// Keep DNS servers in the order specified by the network
-	dnsNetwork, _ := networks.DefaultNetworkFor("dns")
-
 	type dnsConfigArg struct {
 		DNSServers []string
+		Options    string
 	}
-	dnsServersArg := dnsConfigArg{dnsNetwork.DNS}
+	dnsServersArg := dnsConfigArg{networks.DNSServers(), strings.Join(net.resolvConfOptions, " ")}
 	err := t.Execute(buffer, dnsServersArg)
 	if err != nil {
 		return bosherr.WrapError(err, "Generating config from template")
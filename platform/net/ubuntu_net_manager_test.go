@@ -3,10 +3,12 @@ package net_test
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
 	"github.com/cloudfoundry/bosh-agent/factory"
 	. "github.com/cloudfoundry/bosh-agent/platform/net"
 	fakearp "github.com/cloudfoundry/bosh-agent/platform/net/arp/fakes"
@@ -28,6 +30,9 @@ func describeUbuntuNetManager() {
 		interfaceAddrsProvider        *fakeip.FakeInterfaceAddressesProvider
 		netManager                    UbuntuNetManager
 		interfaceConfigurationCreator InterfaceConfigurationCreator
+		timeService                   *fakeaction.FakeClock
+		dhcpClient                    DHCPClient
+		resolvConfOptions             []string
 	)
 
 	writeNetworkDevice := func(iface string, macAddress string, isPhysical bool) string {
@@ -64,9 +69,16 @@ func describeUbuntuNetManager() {
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		ipResolver = &fakeip.FakeResolver{}
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(logger)
+		interfaceConfigurationCreator = NewInterfaceConfigurationCreator(NewInterfaceNameResolver(), logger)
 		addressBroadcaster = &fakearp.FakeAddressBroadcaster{}
+		timeService = &fakeaction.FakeClock{}
+		dhcpClient = NewDhclientDHCPClient()
+		resolvConfOptions = nil
 		interfaceAddrsProvider = &fakeip.FakeInterfaceAddressesProvider{}
+	})
+
+	JustBeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
 		interfaceAddrsValidator := boship.NewInterfaceAddressesValidator(interfaceAddrsProvider)
 		dnsValidator := NewDNSValidator(fs)
 		netManager = NewUbuntuNetManager(
@@ -77,6 +89,9 @@ func describeUbuntuNetManager() {
 			interfaceAddrsValidator,
 			dnsValidator,
 			addressBroadcaster,
+			dhcpClient,
+			resolvConfOptions,
+			timeService,
 			logger,
 		).(UbuntuNetManager)
 	})
@@ -119,6 +134,82 @@ func describeUbuntuNetManager() {
 			})
 		})
 
+		Context("when there are several networks with DNS servers and one is marked as default for DNS", func() {
+			It("should use DNS servers of the default network first, followed by other networks", func() {
+				networks := boshsettings.Networks{
+					"a-manual": factory.Network{Type: "manual", DNS: &[]string{"10.0.0.1", "8.8.8.8"}, Mac: "fake-mac-a"}.Build(),
+					"b-manual": factory.Network{Type: "manual", DNS: &[]string{"8.8.8.8", "9.9.9.9"}, Default: []string{"dns"}, Mac: "fake-mac-b"}.Build(),
+				}
+				stubInterfaces(networks)
+				_, _, dnsServers, err := netManager.ComputeNetworkConfig(networks)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dnsServers).To(Equal([]string{"8.8.8.8", "9.9.9.9", "10.0.0.1"}))
+			})
+		})
+
+		Context("when waiting for network interfaces to appear", func() {
+			var networks boshsettings.Networks
+
+			BeforeEach(func() {
+				networks = boshsettings.Networks{
+					"static": factory.Network{Type: "manual", Mac: "fake-static-mac", IP: "1.2.3.4", Netmask: "255.255.255.0"}.Build(),
+				}
+			})
+
+			It("does not wait when the interface is already present", func() {
+				stubInterfaces(boshsettings.Networks{"eth0": networks["static"]})
+
+				staticInterfaceConfigurations, _, _, err := netManager.ComputeNetworkConfig(networks)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(staticInterfaceConfigurations).To(HaveLen(1))
+				Expect(staticInterfaceConfigurations[0].Name).To(Equal("eth0"))
+
+				Expect(timeService.SleepCallCount()).To(Equal(0))
+			})
+
+			It("polls until the interface appears", func() {
+				stubInterfaces(boshsettings.Networks{})
+				timeService.SleepStub = func(time.Duration) {
+					if timeService.SleepCallCount() == 3 {
+						stubInterfaces(boshsettings.Networks{"eth0": networks["static"]})
+					}
+				}
+
+				staticInterfaceConfigurations, _, _, err := netManager.ComputeNetworkConfig(networks)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(staticInterfaceConfigurations).To(HaveLen(1))
+				Expect(staticInterfaceConfigurations[0].Name).To(Equal("eth0"))
+
+				Expect(timeService.SleepCallCount()).To(Equal(3))
+				Expect(timeService.SleepArgsForCall(0)).To(Equal(1 * time.Second))
+			})
+
+			It("returns an error naming the missing MAC address when the interface never appears", func() {
+				stubInterfaces(boshsettings.Networks{})
+
+				_, _, _, err := netManager.ComputeNetworkConfig(networks)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Timed out after 30s waiting for network interfaces with MAC addresses 'fake-static-mac'"))
+
+				Expect(timeService.SleepCallCount()).To(Equal(30))
+			})
+		})
+
+		Context("when there is a dynamic network and a vip network", func() {
+			It("configures only the dynamic network with DHCP", func() {
+				networks := boshsettings.Networks{
+					"dynamic": boshsettings.Network{Type: "dynamic", Mac: "fake-dynamic-mac"},
+					"vip":     boshsettings.Network{Type: "vip", IP: "9.8.7.6", Mac: "fake-vip-mac"},
+				}
+				stubInterfaces(boshsettings.Networks{"dynamic": networks["dynamic"]})
+				staticInterfaceConfigurations, dhcpInterfaceConfigurations, _, err := netManager.ComputeNetworkConfig(networks)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(dhcpInterfaceConfigurations).To(Equal([]DHCPInterfaceConfiguration{{Name: "dynamic"}}))
+				Expect(staticInterfaceConfigurations).To(BeEmpty())
+			})
+		})
+
 		Context("when specified more than one DNS", func() {
 			It("extracts all DNS servers from the network configured as default DNS", func() {
 				networks := boshsettings.Networks{
@@ -213,7 +304,7 @@ dns-nameservers 8.8.8.8 9.9.9.9`
 
 				Expect(networks.IsPreconfigured()).To(BeTrue())
 
-				err := netManager.SetupNetworking(networks, nil)
+				_, err := netManager.SetupNetworking(networks, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				resolvConfHead := fs.GetFileTestStat("/etc/resolvconf/resolv.conf.d/head")
@@ -226,6 +317,27 @@ nameserver 9.9.9.9
 				Expect(resolvConfHead.StringContents()).To(Equal(expectedResolvConfHead))
 			})
 
+			It("writes dns servers of all networks starting with default network", func() {
+				dhcpNetwork.Preconfigured = true
+				staticNetwork.Preconfigured = true
+				staticNetwork.DNS = []string{"10.0.0.1", "8.8.8.8"}
+				networks := boshsettings.Networks{
+					"first":  staticNetwork,
+					"second": dhcpNetwork,
+				}
+
+				_, err := netManager.SetupNetworking(networks, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				resolvConfHead := fs.GetFileTestStat("/etc/resolvconf/resolv.conf.d/head")
+				Expect(resolvConfHead).ToNot(BeNil())
+				Expect(resolvConfHead.StringContents()).To(Equal(`# Generated by bosh-agent
+nameserver 8.8.8.8
+nameserver 9.9.9.9
+nameserver 10.0.0.1
+`))
+			})
+
 			It("run resolvconf -u to update resolv.conf", func() {
 				dhcpNetwork.Preconfigured = true
 				staticNetwork.Preconfigured = true
@@ -234,13 +346,30 @@ nameserver 9.9.9.9
 					"second": staticNetwork,
 				}
 
-				err := netManager.SetupNetworking(networks, nil)
+				_, err := netManager.SetupNetworking(networks, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(len(cmdRunner.RunCommands)).To(Equal(1))
 				Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"resolvconf", "-u"}))
 			})
 
+			It("returns result with dns servers and without configured interfaces", func() {
+				dhcpNetwork.Preconfigured = true
+				staticNetwork.Preconfigured = true
+				networks := boshsettings.Networks{
+					"first":  dhcpNetwork,
+					"second": staticNetwork,
+				}
+
+				result, err := netManager.SetupNetworking(networks, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(SetupNetworkingResult{
+					StaticInterfaces: []string{},
+					DHCPInterfaces:   []string{},
+					DNSServers:       []string{"8.8.8.8", "9.9.9.9"},
+				}))
+			})
+
 		})
 
 		It("writes interfaces in /etc/network/interfaces in alphabetic order", func() {
@@ -257,7 +386,7 @@ nameserver 9.9.9.9
 				"ethdhcp0":  anotherDHCPNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"dhcp-network-1": dhcpNetwork,
 				"dhcp-network-2": anotherDHCPNetwork,
 				"static-network": staticNetwork,
@@ -317,7 +446,7 @@ dns-nameservers 8.8.8.8 9.9.9.9`
 				boship.NewSimpleInterfaceAddress("eth1", "5.6.7.8"),
 			}
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"static-1": staticNetwork,
 				"static-2": secondStaticNetwork,
 			}, nil)
@@ -361,7 +490,7 @@ dns-nameservers 8.8.8.8`))
 				"ethstatic": staticNetworkWithoutDNS,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetworkWithoutDNS}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetworkWithoutDNS}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
@@ -380,9 +509,64 @@ iface ethstatic inet static
 `))
 		})
 
+		Context("when resolv.conf options are configured", func() {
+			BeforeEach(func() {
+				resolvConfOptions = []string{"timeout:2", "attempts:2"}
+			})
+
+			It("writes dns-options to /etc/network/interfaces after dns-nameservers", func() {
+				staticNetwork.DNS = []string{"8.8.8.8"}
+
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethstatic": staticNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).To(HaveSuffix(`
+dns-nameservers 8.8.8.8
+dns-options timeout:2 attempts:2`))
+			})
+
+			It("does not write options for DHCP networks", func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethdhcp": dhcpNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).ToNot(ContainSubstring("dns-options"))
+
+				dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
+				Expect(dhcpConfig).ToNot(BeNil())
+				Expect(dhcpConfig.StringContents()).ToNot(ContainSubstring("timeout:2"))
+			})
+
+			It("writes options line after nameservers in /etc/resolvconf/resolv.conf.d/head for preconfigured networks", func() {
+				dhcpNetwork.Preconfigured = true
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				resolvConfHead := fs.GetFileTestStat("/etc/resolvconf/resolv.conf.d/head")
+				Expect(resolvConfHead).ToNot(BeNil())
+				Expect(resolvConfHead.StringContents()).To(Equal(`# Generated by bosh-agent
+nameserver 8.8.8.8
+nameserver 9.9.9.9
+options timeout:2 attempts:2
+`))
+			})
+		})
+
 		It("returns errors from glob /sys/class/net/", func() {
 			fs.GlobErr = errors.New("fs-glob-error")
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fs-glob-error"))
 		})
@@ -393,7 +577,7 @@ iface ethstatic inet static
 				"static": staticNetwork,
 			})
 			fs.WriteFileError = errors.New("fs-write-file-error")
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fs-write-file-error"))
 		})
@@ -404,7 +588,7 @@ iface ethstatic inet static
 				"ethstatic": staticNetwork,
 			})
 			staticNetwork.Netmask = "not an ip" //will cause InterfaceConfigurationCreator to fail
-			err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Creating interface configurations"))
 		})
@@ -415,7 +599,7 @@ iface ethstatic inet static
 				"ethstatic": staticNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
@@ -446,7 +630,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				"ethdhcp": dhcpNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetworkWithoutDNS}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetworkWithoutDNS}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
@@ -474,7 +658,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 
 			fs.WriteFileErrors["/etc/dhcp/dhclient.conf"] = errors.New("dhclient.conf-write-error")
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("dhclient.conf-write-error"))
 		})
@@ -484,7 +668,7 @@ request subnet-mask, broadcast-address, time-offset, routers,
 				"ethstatic": staticNetwork,
 			})
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			dhcpConfig := fs.GetFileTestStat("/etc/dhcp/dhclient.conf")
@@ -513,7 +697,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 
 			fs.WriteFileString("/etc/dhcp/dhclient.conf", initialDhcpConfig)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(len(cmdRunner.RunCommands)).To(Equal(5))
@@ -524,6 +708,22 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			Expect(cmdRunner.RunCommands[4]).To(Equal([]string{"ifup", "--force", "ethdhcp", "ethstatic"}))
 		})
 
+		It("returns result describing applied static and dhcp interfaces", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			result, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(SetupNetworkingResult{
+				StaticInterfaces: []string{"ethstatic"},
+				DHCPInterfaces:   []string{"ethdhcp"},
+				DNSServers:       []string{"8.8.8.8", "9.9.9.9"},
+				Restarted:        true,
+			}))
+		})
+
 		It("doesn't restart the networks if /etc/network/interfaces and /etc/dhcp/dhclient.conf don't change", func() {
 			initialDhcpConfig := `# Generated by bosh-agent
 
@@ -546,7 +746,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			fs.WriteFileString("/etc/network/interfaces", expectedNetworkConfigurationForStaticAndDhcp)
 			fs.WriteFileString("/etc/dhcp/dhclient.conf", initialDhcpConfig)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
@@ -557,6 +757,21 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			Expect(len(cmdRunner.RunCommands)).To(Equal(0))
 		})
 
+		It("returns result without restart when configuration did not change", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethstatic": staticNetwork,
+			})
+
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			result, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.StaticInterfaces).To(Equal([]string{"ethstatic"}))
+			Expect(result.DHCPInterfaces).To(BeEmpty())
+			Expect(result.Restarted).To(BeFalse())
+		})
+
 		It("restarts the networks if /etc/dhcp/dhclient.conf changes", func() {
 			stubInterfaces(map[string]boshsettings.Network{
 				"ethdhcp":   dhcpNetwork,
@@ -565,7 +780,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 
 			fs.WriteFileString("/etc/network/interfaces", expectedNetworkConfigurationForStaticAndDhcp)
 
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
@@ -579,6 +794,336 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			Expect(cmdRunner.RunCommands[4]).To(Equal([]string{"ifup", "--force", "ethdhcp", "ethstatic"}))
 		})
 
+		Context("when network is bonded", func() {
+			BeforeEach(func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"eth0": boshsettings.Network{Mac: "fake-slave-mac-1"},
+					"eth1": boshsettings.Network{Mac: "fake-slave-mac-2"},
+				})
+			})
+
+			It("renders bond in active-backup mode with its slaves", func() {
+				interfaceAddrsProvider.GetInterfaceAddresses = []boship.InterfaceAddress{
+					boship.NewSimpleInterfaceAddress("bond0", "1.2.3.4"),
+				}
+
+				bondNetwork := staticNetwork
+				bondNetwork.Mac = ""
+				bondNetwork.Bond = &boshsettings.Bond{
+					Name:   "bond0",
+					Mode:   "active-backup",
+					Slaves: []string{"fake-slave-mac-2", "fake-slave-mac-1"},
+				}
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"bond-network": bondNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).To(Equal(`# Generated by bosh-agent
+auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet manual
+    bond-master bond0
+
+auto eth1
+iface eth1 inet manual
+    bond-master bond0
+
+auto bond0
+iface bond0 inet static
+    address 1.2.3.4
+    network 1.2.3.0
+    netmask 255.255.255.0
+    bond-mode active-backup
+    bond-miimon 100
+    bond-slaves eth0 eth1
+    broadcast 1.2.3.255
+    gateway 3.4.5.6
+`))
+			})
+
+			It("renders bond in 802.3ad mode configured with DHCP", func() {
+				bondNetwork := dhcpNetwork
+				bondNetwork.Mac = ""
+				bondNetwork.Bond = &boshsettings.Bond{
+					Name:   "bond0",
+					Mode:   "802.3ad",
+					Slaves: []string{"fake-slave-mac-1", "fake-slave-mac-2"},
+				}
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"bond-network": bondNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).To(Equal(`# Generated by bosh-agent
+auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet manual
+    bond-master bond0
+
+auto eth1
+iface eth1 inet manual
+    bond-master bond0
+
+auto bond0
+iface bond0 inet dhcp
+    bond-mode 802.3ad
+    bond-miimon 100
+    bond-slaves eth0 eth1
+
+
+dns-nameservers 8.8.8.8 9.9.9.9`))
+			})
+
+			It("loads bonding module before bringing up interfaces", func() {
+				bondNetwork := dhcpNetwork
+				bondNetwork.Mac = ""
+				bondNetwork.Bond = &boshsettings.Bond{
+					Name:   "bond0",
+					Mode:   "802.3ad",
+					Slaves: []string{"fake-slave-mac-1", "fake-slave-mac-2"},
+				}
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"bond-network": bondNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"modprobe", "bonding"}))
+				Expect(cmdRunner.RunCommands).To(ContainElement([]string{"ifup", "--force", "bond0"}))
+			})
+
+			It("returns error when slave interface is not found", func() {
+				bondNetwork := dhcpNetwork
+				bondNetwork.Mac = ""
+				bondNetwork.Bond = &boshsettings.Bond{
+					Name:   "bond0",
+					Mode:   "802.3ad",
+					Slaves: []string{"fake-slave-mac-1", "fake-missing-mac"},
+				}
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"bond-network": bondNetwork}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-missing-mac"))
+			})
+		})
+
+		Context("when network is tagged with VLAN", func() {
+			BeforeEach(func() {
+				staticNetwork.Vlan = 100
+				interfaceAddrsProvider.GetInterfaceAddresses = []boship.InterfaceAddress{
+					boship.NewSimpleInterfaceAddress("ethstatic.100", "1.2.3.4"),
+				}
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethstatic": staticNetwork,
+				})
+			})
+
+			It("creates VLAN interface before bringing it up", func() {
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"modprobe", "8021q"}))
+				Expect(cmdRunner.RunCommands[1]).To(Equal([]string{"ip", "link", "add", "link", "ethstatic", "name", "ethstatic.100", "type", "vlan", "id", "100"}))
+				Expect(cmdRunner.RunCommands).To(ContainElement([]string{"ifup", "--force", "ethstatic.100"}))
+			})
+
+			It("assigns IP configuration to the tagged interface", func() {
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).To(Equal(`# Generated by bosh-agent
+auto lo
+iface lo inet loopback
+
+auto ethstatic.100
+iface ethstatic.100 inet static
+    address 1.2.3.4
+    network 1.2.3.0
+    netmask 255.255.255.0
+    vlan-raw-device ethstatic
+    broadcast 1.2.3.255
+    gateway 3.4.5.6
+`))
+			})
+
+			It("does not create VLAN interface that already exists", func() {
+				fs.WriteFile("/sys/class/net/ethstatic.100", []byte{})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("vlan")))
+			})
+
+			It("returns error when creating VLAN interface fails", func() {
+				cmdRunner.AddCmdResult("ip link add link ethstatic name ethstatic.100 type vlan id 100", fakesys.FakeCmdResult{Error: errors.New("fake-ip-err")})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Creating VLAN interface 'ethstatic.100'"))
+				Expect(err.Error()).To(ContainSubstring("fake-ip-err"))
+			})
+		})
+
+		It("does not create VLAN interfaces for untagged networks", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cmdRunner.RunCommands).ToNot(ContainElement([]string{"modprobe", "8021q"}))
+		})
+
+		Context("when network specifies MTU", func() {
+			It("writes MTU of static and DHCP interfaces to /etc/network/interfaces so that it is kept after reboot", func() {
+				dhcpNetwork.Mtu = 9000
+				staticNetwork.Mtu = 1400
+
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethdhcp":   dhcpNetwork,
+					"ethstatic": staticNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).To(Equal(`# Generated by bosh-agent
+auto lo
+iface lo inet loopback
+
+auto ethdhcp
+iface ethdhcp inet dhcp
+    post-up ip link set dev ethdhcp mtu 9000
+
+auto ethstatic
+iface ethstatic inet static
+    address 1.2.3.4
+    network 1.2.3.0
+    netmask 255.255.255.0
+    mtu 1400
+    broadcast 1.2.3.255
+    gateway 3.4.5.6
+
+dns-nameservers 8.8.8.8 9.9.9.9`))
+			})
+		})
+
+		It("does not write MTU when networks do not specify it", func() {
+			stubInterfaces(map[string]boshsettings.Network{
+				"ethdhcp":   dhcpNetwork,
+				"ethstatic": staticNetwork,
+			})
+
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+			Expect(networkConfig).ToNot(BeNil())
+			Expect(networkConfig.StringContents()).To(Equal(expectedNetworkConfigurationForStaticAndDhcp))
+		})
+
+		Context("when systemd-networkd is the DHCP client", func() {
+			BeforeEach(func() {
+				dhcpClient = NewSystemdNetworkdDHCPClient()
+			})
+
+			It("writes networkd configuration and restarts systemd-networkd", func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethdhcp":   dhcpNetwork,
+					"ethstatic": staticNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.FileExists("/etc/dhcp/dhclient.conf")).To(BeFalse())
+
+				dhcpConfig := fs.GetFileTestStat("/etc/systemd/network/10-bosh-dhcp.network")
+				Expect(dhcpConfig).ToNot(BeNil())
+				Expect(dhcpConfig.StringContents()).To(ContainSubstring("Name=ethdhcp\n"))
+
+				Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"systemctl", "restart", "systemd-networkd"}))
+			})
+
+			It("leaves DHCP interfaces out of /etc/network/interfaces so that ifup does not start dhclient", func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"ethdhcp":   dhcpNetwork,
+					"ethstatic": staticNetwork,
+				})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				networkConfig := fs.GetFileTestStat("/etc/network/interfaces")
+				Expect(networkConfig).ToNot(BeNil())
+				Expect(networkConfig.StringContents()).ToNot(ContainSubstring("ethdhcp"))
+				Expect(networkConfig.StringContents()).To(ContainSubstring("iface ethstatic inet static"))
+
+				Expect(cmdRunner.RunCommands).To(ContainElement([]string{"ifup", "--force", "ethstatic"}))
+				Expect(cmdRunner.RunCommands).ToNot(ContainElement(ContainElement("ethdhcp")))
+			})
+
+			It("returns error when systemd-networkd cannot be restarted", func() {
+				stubInterfaces(map[string]boshsettings.Network{"ethdhcp": dhcpNetwork})
+				cmdRunner.AddCmdResult("systemctl restart systemd-networkd", fakesys.FakeCmdResult{Error: errors.New("fake-systemctl-err")})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-systemctl-err"))
+			})
+
+			It("removes networkd configuration when no network uses DHCP anymore", func() {
+				stubInterfaces(map[string]boshsettings.Network{"ethstatic": staticNetwork})
+				fs.WriteFileString("/etc/systemd/network/10-bosh-dhcp.network", "fake-config")
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.FileExists("/etc/systemd/network/10-bosh-dhcp.network")).To(BeFalse())
+				Expect(cmdRunner.RunCommands[0]).To(Equal([]string{"systemctl", "restart", "systemd-networkd"}))
+			})
+
+			It("returns error for bonded DHCP network since bond is created by ifup", func() {
+				stubInterfaces(map[string]boshsettings.Network{
+					"eth0": boshsettings.Network{Mac: "fake-slave-mac-1"},
+					"eth1": boshsettings.Network{Mac: "fake-slave-mac-2"},
+				})
+
+				bondNetwork := dhcpNetwork
+				bondNetwork.Mac = ""
+				bondNetwork.Bond = &boshsettings.Bond{
+					Name:   "bond0",
+					Mode:   "active-backup",
+					Slaves: []string{"fake-slave-mac-1", "fake-slave-mac-2"},
+				}
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"bond-network": bondNetwork}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Bonded DHCP interface 'bond0' is not supported"))
+			})
+
+			It("returns error for DHCP network with MTU since it is set by ifup", func() {
+				dhcpNetwork.Mtu = 9000
+				stubInterfaces(map[string]boshsettings.Network{"ethdhcp": dhcpNetwork})
+
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("MTU of DHCP interface 'ethdhcp' is not supported"))
+			})
+		})
+
 		It("broadcasts MAC addresses for all interfaces", func() {
 			stubInterfaces(map[string]boshsettings.Network{
 				"ethdhcp":   dhcpNetwork,
@@ -586,7 +1131,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 			})
 
 			errCh := make(chan error)
-			err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, errCh)
+			_, err := netManager.SetupNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}, errCh)
 			Expect(err).ToNot(HaveOccurred())
 
 			broadcastErr := <-errCh // wait for all arpings
@@ -613,7 +1158,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				IP:      "9.8.7.6",
 			}
 
-			err := netManager.SetupNetworking(boshsettings.Networks{
+			_, err := netManager.SetupNetworking(boshsettings.Networks{
 				"dhcp-network":   dhcpNetwork,
 				"static-network": staticNetwork,
 				"vip-network":    vipNetwork,
@@ -638,7 +1183,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				})
 
 				errCh := make(chan error)
-				err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Validating static network configuration"))
 			})
@@ -665,7 +1210,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 				})
 
 				errCh := make(chan error)
-				err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
+				_, err := netManager.SetupNetworking(boshsettings.Networks{"static-network": staticNetwork}, errCh)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Validating dns configuration"))
 			})
@@ -690,7 +1235,7 @@ prepend domain-name-servers 8.8.8.8, 9.9.9.9;
 					boship.NewSimpleInterfaceAddress("ethstatic", "2.2.2.2"),
 				}
 
-				err := netManager.SetupNetworking(boshsettings.Networks{
+				_, err := netManager.SetupNetworking(boshsettings.Networks{
 					"static-network": staticNetworkWithoutMAC,
 				}, nil)
 				Expect(err).ToNot(HaveOccurred())
@@ -733,7 +1278,7 @@ iface ethstatic inet static
 					boship.NewSimpleInterfaceAddress("ethstatic", "2.2.2.2"),
 				}
 
-				err := netManager.SetupNetworking(boshsettings.Networks{
+				_, err := netManager.SetupNetworking(boshsettings.Networks{
 					"static-network": staticNetworkWithoutMAC,
 				}, nil)
 				Expect(err).ToNot(HaveOccurred())
@@ -55,7 +55,8 @@ netsh interface ip set address $connectionName static %s %s %s
 `
 )
 
-func (net WindowsNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+// SetupNetworking only reports DNS servers since interfaces are identified by MAC address
+func (net WindowsNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) (SetupNetworkingResult, error) {
 
 	nonVipNetworks := boshsettings.Networks{}
 
@@ -68,13 +69,17 @@ func (net WindowsNetManager) SetupNetworking(networks boshsettings.Networks, err
 
 	err := net.setupInterfaces(nonVipNetworks)
 	if err != nil {
-		return err
+		return SetupNetworkingResult{}, err
 	}
 
 	dnsNetwork, _ := nonVipNetworks.DefaultNetworkFor("dns")
-	dns := net.setupDNS(dnsNetwork)
+	dns := net.setupDNS(dnsNetwork.DNS)
 	net.clock.Sleep(5 * time.Second)
-	return dns
+	if dns != nil {
+		return SetupNetworkingResult{}, dns
+	}
+
+	return newSetupNetworkingResult(nil, nil, dnsNetwork.DNS, false), nil
 }
 
 func (net WindowsNetManager) setupInterfaces(networks boshsettings.Networks) error {
@@ -92,9 +97,9 @@ func (net WindowsNetManager) setupInterfaces(networks boshsettings.Networks) err
 	return nil
 }
 
-func (net WindowsNetManager) setupDNS(dnsNetwork boshsettings.Network) error {
-	if len(dnsNetwork.DNS) > 0 {
-		_, _, err := net.scriptRunner.Run(fmt.Sprintf(SetDNSTemplate, strings.Join(dnsNetwork.DNS, `","`)))
+func (net WindowsNetManager) setupDNS(dnsServers []string) error {
+	if len(dnsServers) > 0 {
+		_, _, err := net.scriptRunner.Run(fmt.Sprintf(SetDNSTemplate, strings.Join(dnsServers, `","`)))
 		if err != nil {
 			return bosherr.WrapError(err, "Configuring DNS servers")
 		}
@@ -36,7 +36,8 @@ var _ = Describe("WindowsNetManager", func() {
 		setupNetworking := func(networks boshsettings.Networks) error {
 			// Allow 5 seconds to pass so that the Sleep() in the function can pass.
 			go clock.WaitForWatcherAndIncrement(5 * time.Second)
-			return netManager.SetupNetworking(networks, nil)
+			_, err := netManager.SetupNetworking(networks, nil)
+			return err
 		}
 
 		Describe("Setting NIC settings", func() {
@@ -3,6 +3,7 @@ package platform
 import (
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	"github.com/cloudfoundry/bosh-agent/platform/cert"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -31,9 +32,12 @@ type Platform interface {
 	SetUserPassword(user, encryptedPwd string) (err error)
 	SetupHostname(hostname string) (err error)
 	SetupNetworking(networks boshsettings.Networks) (err error)
-	SetupLogrotate(groupName, basePath, size string) (err error)
+	SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error)
+	SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error)
+	KeepOnlyJobsLogrotate(jobNames []string) (err error)
 	SetTimeWithNtpServers(servers []string) (err error)
-	SetupEphemeralDiskWithPath(devicePath string) (err error)
+	SyncTimeWithNtpServers(servers []string) (err error)
+	SetupEphemeralDiskWithPath(devicePath string, fsType boshdisk.FileSystemType, disableSwap bool) (err error)
 	SetupRawEphemeralDisks(devices []boshsettings.DiskSettings) (err error)
 	SetupDataDir() (err error)
 	SetupTmpDir() (err error)
@@ -44,17 +48,24 @@ type Platform interface {
 	// Disk management
 	MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) error
 	UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error)
+
+	// UnmountPersistentDiskMountPoint does not resolve device path
+	// hence it can be used for disks that are already detached
+	UnmountPersistentDiskMountPoint(mountPoint string) (didUnmount bool, err error)
 	MigratePersistentDisk(fromMountPoint, toMountPoint string) (err error)
 	GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string
 	IsMountPoint(path string) (partitionPath string, result bool, err error)
 	IsPersistentDiskMounted(diskSettings boshsettings.DiskSettings) (result bool, err error)
 	IsPersistentDiskMountable(diskSettings boshsettings.DiskSettings) (bool, error)
+	SetupJobStores(jobNames []string) (err error)
+	GetPersistentDiskMountPoint(diskID string) string
 
 	GetFileContentsFromCDROM(filePath string) (contents []byte, err error)
 	GetFilesContentsFromDisk(diskPath string, fileNames []string) (contents [][]byte, err error)
 
 	// Network misc
 	GetDefaultNetwork() (boshsettings.Network, error)
+	GetDefaultGatewayInterface() (string, error)
 	GetConfiguredNetworkInterfaces() ([]string, error)
 	PrepareForNetworkingChange() error
 	DeleteARPEntryWithIP(ip string) error
@@ -67,4 +78,6 @@ type Platform interface {
 	GetHostPublicKey() (string, error)
 
 	RemoveDevTools(packageFileListPath string) error
+
+	Reboot() error
 }
@@ -38,7 +38,8 @@ type Provider interface {
 }
 
 type provider struct {
-	platforms map[string]Platform
+	platforms    map[string]Platform
+	platformErrs map[string]error
 }
 
 type Options struct {
@@ -47,9 +48,9 @@ type Options struct {
 
 func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsCollector boshstats.Collector, scriptCommandFactory boshsys.ScriptCommandFactory, fs boshsys.FileSystem, options Options, bootstrapState *BootstrapState, clock clock.Clock) Provider {
 	runner := boshsys.NewExecCmdRunner(logger)
-	linuxDiskManager := boshdisk.NewLinuxDiskManager(logger, runner, fs, options.Linux.BindMountPersistentDisk)
+	linuxDiskManager := boshdisk.NewLinuxDiskManager(logger, runner, fs, options.Linux.BindMountPersistentDisk, clock)
 
-	udev := boshudev.NewConcreteUdevDevice(runner, logger)
+	udev := boshudev.NewConcreteUdevDevice(runner, clock, logger)
 	linuxCdrom := boshcdrom.NewLinuxCdrom("/dev/sr0", udev, runner)
 	linuxCdutil := boshcdrom.NewCdUtil(dirProvider.SettingsDir(), fs, linuxCdrom, logger)
 
@@ -63,21 +64,40 @@ func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsColl
 
 	ipResolver := boship.NewResolver(boship.NetworkInterfaceToAddrsFunc)
 
-	arping := bosharp.NewArping(runner, fs, logger, ArpIterations, ArpIterationDelay, ArpInterfaceCheckDelay)
-	interfaceConfigurationCreator := boshnet.NewInterfaceConfigurationCreator(logger)
+	arping := bosharp.NewArping(runner, fs, clock, logger, ArpIterations, ArpIterationDelay, ArpInterfaceCheckDelay)
+	interfaceNameResolver := boshnet.NewInterfaceNameResolver()
+
+	var interfaceConfigurationCreator boshnet.InterfaceConfigurationCreator
+	if options.Linux.DisableDHCP {
+		interfaceConfigurationCreator = boshnet.NewStaticInterfaceConfigurationCreator(interfaceNameResolver, logger)
+	} else {
+		interfaceConfigurationCreator = boshnet.NewInterfaceConfigurationCreator(interfaceNameResolver, logger)
+	}
 
 	interfaceAddressesProvider := boship.NewSystemInterfaceAddressesProvider()
 	interfaceAddressesValidator := boship.NewInterfaceAddressesValidator(interfaceAddressesProvider)
 	dnsValidator := boshnet.NewDNSValidator(fs)
 
-	centosNetManager := boshnet.NewCentosNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, logger)
-	ubuntuNetManager := boshnet.NewUbuntuNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, logger)
+	platformErrs := map[string]error{}
+
+	dhcpClient, err := boshnet.NewDHCPClient(options.Linux.DHCPClient, fs)
+	if err != nil {
+		platformErrs["ubuntu"] = err
+		platformErrs["centos"] = err
+		dhcpClient = boshnet.NewDhclientDHCPClient()
+	} else if options.Linux.DHCPClient == boshnet.DHCPClientSystemdNetworkd {
+		// CentOS network scripts always run dhclient
+		platformErrs["centos"] = bosherror.Errorf("DHCP client '%s' is not supported on centos", options.Linux.DHCPClient)
+	}
+
+	centosNetManager := boshnet.NewCentosNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, options.Linux.ResolvConfOptions, clock, logger)
+	ubuntuNetManager := boshnet.NewUbuntuNetManager(fs, runner, ipResolver, interfaceConfigurationCreator, interfaceAddressesValidator, dnsValidator, arping, dhcpClient, options.Linux.ResolvConfOptions, clock, logger)
 
 	scriptRunner := boshsys.NewConcreteScriptRunner(scriptCommandFactory, runner, fs, logger)
 	windowsNetManager := boshnet.NewWindowsNetManager(scriptRunner, logger, clock)
 
-	centosCertManager := boshcert.NewCentOSCertManager(fs, runner, 0, logger)
-	ubuntuCertManager := boshcert.NewUbuntuCertManager(fs, runner, 60, logger)
+	centosCertManager := boshcert.NewCentOSCertManager(fs, runner, 0, clock, logger)
+	ubuntuCertManager := boshcert.NewUbuntuCertManager(fs, runner, 60, clock, logger)
 
 	routesSearcher := boshnet.NewCmdRoutesSearcher(runner)
 	linuxDefaultNetworkResolver := boshnet.NewDefaultNetworkResolver(routesSearcher, ipResolver)
@@ -85,22 +105,41 @@ func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsColl
 	monitRetryable := NewMonitRetryable(runner)
 	monitRetryStrategy := boshretry.NewAttemptRetryStrategy(10, 1*time.Second, monitRetryable, logger)
 
+	// Configured wait replaces default wait of each resolver
+	diskWaitTimeout := func(defaultTimeout time.Duration) time.Duration {
+		if options.Linux.DevicePathResolutionWaitSeconds > 0 {
+			return time.Duration(options.Linux.DevicePathResolutionWaitSeconds) * time.Second
+		}
+		return defaultTimeout
+	}
+
 	var devicePathResolver devicepathresolver.DevicePathResolver
 	switch options.Linux.DevicePathResolutionType {
 	case "virtio":
-		udev := boshudev.NewConcreteUdevDevice(runner, logger)
-		idDevicePathResolver := devicepathresolver.NewIDDevicePathResolver(500*time.Millisecond, options.Linux.VirtioDevicePrefix, udev, fs)
-		mappedDevicePathResolver := devicepathresolver.NewMappedDevicePathResolver(500*time.Millisecond, fs)
+		udev := boshudev.NewConcreteUdevDevice(runner, clock, logger)
+		idDevicePathResolver := devicepathresolver.NewIDDevicePathResolver(diskWaitTimeout(500*time.Millisecond), options.Linux.VirtioDevicePrefix, udev, fs, clock)
+		mappedDevicePathResolver := devicepathresolver.NewMappedDevicePathResolver(diskWaitTimeout(500*time.Millisecond), fs, clock)
 		devicePathResolver = devicepathresolver.NewVirtioDevicePathResolver(idDevicePathResolver, mappedDevicePathResolver, logger)
 	case "scsi":
-		scsiIDPathResolver := devicepathresolver.NewSCSIIDDevicePathResolver(50000*time.Millisecond, fs, logger)
-		scsiVolumeIDPathResolver := devicepathresolver.NewSCSIVolumeIDDevicePathResolver(500*time.Millisecond, fs)
-		scsiLunPathResolver := devicepathresolver.NewSCSILunDevicePathResolver(50000*time.Millisecond, fs, logger)
+		scsiIDPathResolver := devicepathresolver.NewSCSIIDDevicePathResolver(diskWaitTimeout(50000*time.Millisecond), fs, clock, logger)
+		scsiVolumeIDPathResolver := devicepathresolver.NewSCSIVolumeIDDevicePathResolver(diskWaitTimeout(500*time.Millisecond), fs, clock)
+		scsiLunPathResolver := devicepathresolver.NewSCSILunDevicePathResolver(diskWaitTimeout(50000*time.Millisecond), fs, clock, logger)
 		devicePathResolver = devicepathresolver.NewScsiDevicePathResolver(scsiVolumeIDPathResolver, scsiIDPathResolver, scsiLunPathResolver)
 	default:
-		devicePathResolver = devicepathresolver.NewIdentityDevicePathResolver()
+		if options.Linux.DevicePathResolutionWaitSeconds > 0 {
+			devicePathResolver = devicepathresolver.NewWaitingIdentityDevicePathResolver(diskWaitTimeout(0), fs, clock)
+		} else {
+			devicePathResolver = devicepathresolver.NewIdentityDevicePathResolver()
+		}
 	}
 
+	// Ephemeral disk path provided by some CPIs is occasionally wrong or appears late;
+	// detection must not pick devices that were resolved for other disks
+	resolvedDevices := devicepathresolver.NewResolvedDevices()
+	ephemeralDiskResolver := devicepathresolver.NewFallbackDevicePathResolver(
+		devicePathResolver, resolvedDevices, 30*time.Second, fs, boshdisk.NewProcMountsSearcher(fs), clock, logger)
+	recordingDevicePathResolver := devicepathresolver.NewRecordingDevicePathResolver(devicePathResolver, resolvedDevices)
+
 	centos := NewLinuxPlatform(
 		fs,
 		runner,
@@ -114,7 +153,8 @@ func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsColl
 		centosNetManager,
 		centosCertManager,
 		monitRetryStrategy,
-		devicePathResolver,
+		recordingDevicePathResolver,
+		ephemeralDiskResolver,
 		500*time.Millisecond,
 		bootstrapState,
 		options.Linux,
@@ -135,7 +175,8 @@ func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsColl
 		ubuntuNetManager,
 		ubuntuCertManager,
 		monitRetryStrategy,
-		devicePathResolver,
+		recordingDevicePathResolver,
+		ephemeralDiskResolver,
 		500*time.Millisecond,
 		bootstrapState,
 		options.Linux,
@@ -147,9 +188,10 @@ func NewProvider(logger boshlog.Logger, dirProvider boshdirs.Provider, statsColl
 		platforms: map[string]Platform{
 			"ubuntu":  ubuntu,
 			"centos":  centos,
-			"dummy":   NewDummyPlatform(statsCollector, fs, runner, dirProvider, devicePathResolver, logger),
-			"windows": NewWindowsPlatform(statsCollector, fs, runner, dirProvider, windowsNetManager, devicePathResolver, logger),
+			"dummy":   NewDummyPlatform(statsCollector, fs, runner, dirProvider, devicePathResolver, clock, logger),
+			"windows": NewWindowsPlatform(statsCollector, fs, runner, dirProvider, windowsNetManager, devicePathResolver, clock, logger),
 		},
+		platformErrs: platformErrs,
 	}
 }
 
@@ -158,5 +200,11 @@ func (p provider) Get(name string) (Platform, error) {
 	if !found {
 		return nil, bosherror.Errorf("Platform %s could not be found", name)
 	}
+
+	err := p.platformErrs[name]
+	if err != nil {
+		return nil, bosherror.WrapErrorf(err, "Configuring platform %s", name)
+	}
+
 	return plat, nil
 }
@@ -0,0 +1,138 @@
+package platform
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const defaultFileMode = os.FileMode(0666)
+
+type sensitiveFileSystem struct {
+	boshsys.FileSystem
+
+	umask    os.FileMode
+	patterns []string
+}
+
+// NewSensitiveFileSystem applies umask to files matching one of the patterns,
+// e.g. authorized_keys or settings cache, regardless of process umask.
+// Patterns without a slash are matched against base name of the file,
+// otherwise against the whole path (see path.Match).
+func NewSensitiveFileSystem(fs boshsys.FileSystem, umask os.FileMode, patterns []string) boshsys.FileSystem {
+	return sensitiveFileSystem{FileSystem: fs, umask: umask, patterns: patterns}
+}
+
+func (fs sensitiveFileSystem) WriteFileString(filePath, content string) error {
+	return fs.WriteFile(filePath, []byte(content))
+}
+
+func (fs sensitiveFileSystem) WriteFile(filePath string, content []byte) error {
+	if !fs.isSensitive(filePath) {
+		return fs.FileSystem.WriteFile(filePath, content)
+	}
+
+	return fs.writeSensitiveFile(filePath, content)
+}
+
+func (fs sensitiveFileSystem) ConvergeFileContents(filePath string, content []byte) (bool, error) {
+	if !fs.isSensitive(filePath) {
+		return fs.FileSystem.ConvergeFileContents(filePath, content)
+	}
+
+	existingContent, err := fs.FileSystem.ReadFile(filePath)
+	if err == nil && bytes.Equal(existingContent, content) {
+		return false, nil
+	}
+
+	return true, fs.writeSensitiveFile(filePath, content)
+}
+
+func (fs sensitiveFileSystem) OpenFile(filePath string, flag int, perm os.FileMode) (boshsys.File, error) {
+	if fs.isSensitive(filePath) {
+		perm &^= fs.umask
+	}
+
+	return fs.FileSystem.OpenFile(filePath, flag, perm)
+}
+
+// Chmod never grants permissions masked by umask,
+// e.g. when platform sets up authorized_keys file
+func (fs sensitiveFileSystem) Chmod(filePath string, perm os.FileMode) error {
+	if fs.isSensitive(filePath) {
+		perm &^= fs.umask
+	}
+
+	return fs.FileSystem.Chmod(filePath, perm)
+}
+
+// writeSensitiveFile writes content to temporary file created with masked mode
+// and renames it over sensitive file so that content is never readable
+// with looser permissions, even when sensitive file already exists
+func (fs sensitiveFileSystem) writeSensitiveFile(filePath string, content []byte) error {
+	perm := defaultFileMode &^ fs.umask
+
+	err := fs.FileSystem.MkdirAll(path.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating dir to write file")
+	}
+
+	tmpPath := filePath + ".tmp"
+
+	// Leftover of interrupted write is not reused since its mode may be looser
+	err = fs.FileSystem.RemoveAll(tmpPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing temporary file '%s'", tmpPath)
+	}
+
+	file, err := fs.FileSystem.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating file %s", tmpPath)
+	}
+
+	_, err = file.Write(content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fs.FileSystem.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Writing content to file %s", tmpPath)
+	}
+
+	// Process umask may have masked more than requested
+	err = fs.FileSystem.Chmod(tmpPath, perm)
+	if err != nil {
+		fs.FileSystem.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Applying umask to sensitive file '%s'", filePath)
+	}
+
+	err = fs.FileSystem.Rename(tmpPath, filePath)
+	if err != nil {
+		fs.FileSystem.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Replacing sensitive file '%s'", filePath)
+	}
+
+	return nil
+}
+
+func (fs sensitiveFileSystem) isSensitive(filePath string) bool {
+	filePath = path.Clean(filePath)
+
+	for _, pattern := range fs.patterns {
+		name := filePath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(filePath)
+		}
+
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
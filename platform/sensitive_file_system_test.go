@@ -0,0 +1,170 @@
+package platform_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("SensitiveFileSystem", func() {
+	var (
+		fakeFs *fakesys.FakeFileSystem
+		fs     boshsys.FileSystem
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		fs = NewSensitiveFileSystem(fakeFs, os.FileMode(0077), []string{
+			"authorized_keys",
+			"/var/vcap/bosh/settings.json",
+		})
+	})
+
+	Describe("WriteFile", func() {
+		It("applies umask to files matching base name pattern", func() {
+			err := fs.WriteFile("/home/vcap/.ssh/authorized_keys", []byte("fake-key"))
+			Expect(err).ToNot(HaveOccurred())
+
+			stat := fakeFs.GetFileTestStat("/home/vcap/.ssh/authorized_keys")
+			Expect(stat.Content).To(Equal([]byte("fake-key")))
+			Expect(stat.FileMode).To(Equal(os.FileMode(0600)))
+		})
+
+		It("applies umask to files matching path pattern", func() {
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/var/vcap/bosh/settings.json").FileMode).To(Equal(os.FileMode(0600)))
+		})
+
+		It("applies configured umask", func() {
+			fs = NewSensitiveFileSystem(fakeFs, os.FileMode(0227), []string{"settings.json"})
+
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/var/vcap/bosh/settings.json").FileMode).To(Equal(os.FileMode(0440)))
+		})
+
+		It("does not change mode of other files", func() {
+			err := fs.WriteFileString("/var/vcap/bosh/other/settings.json", "fake-contents")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/var/vcap/bosh/other/settings.json").FileMode).To(Equal(os.FileMode(0)))
+		})
+
+		It("creates temporary file with masked mode and renames it over file", func() {
+			fakeFs.WriteFileString("/var/vcap/bosh/settings.json", "fake-old-settings")
+			fakeFs.Chmod("/var/vcap/bosh/settings.json", os.FileMode(0644))
+
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.RenameOldPaths).To(Equal([]string{"/var/vcap/bosh/settings.json.tmp"}))
+			Expect(fakeFs.RenameNewPaths).To(Equal([]string{"/var/vcap/bosh/settings.json"}))
+			Expect(fakeFs.FileExists("/var/vcap/bosh/settings.json.tmp")).To(BeFalse())
+
+			stat := fakeFs.GetFileTestStat("/var/vcap/bosh/settings.json")
+			Expect(stat.Content).To(Equal([]byte("fake-settings")))
+			Expect(stat.FileMode).To(Equal(os.FileMode(0600)))
+		})
+
+		It("writes other files without temporary file", func() {
+			err := fs.WriteFileString("/var/vcap/bosh/other/settings.json", "fake-contents")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.RenameOldPaths).To(BeEmpty())
+		})
+
+		It("returns error when writing other files fails", func() {
+			fakeFs.WriteFileError = errors.New("fake-write-err")
+
+			err := fs.WriteFileString("/var/vcap/bosh/other/settings.json", "fake-contents")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-write-err"))
+		})
+
+		It("returns error when creating temporary file fails", func() {
+			fakeFs.OpenFileErr = errors.New("fake-open-err")
+
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Creating file /var/vcap/bosh/settings.json.tmp"))
+			Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+		})
+
+		It("returns error and removes temporary file when renaming fails", func() {
+			fakeFs.RenameError = errors.New("fake-rename-err")
+
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Replacing sensitive file '/var/vcap/bosh/settings.json'"))
+			Expect(err.Error()).To(ContainSubstring("fake-rename-err"))
+			Expect(fakeFs.FileExists("/var/vcap/bosh/settings.json.tmp")).To(BeFalse())
+		})
+
+		It("returns error when changing mode fails", func() {
+			fakeFs.ChmodErr = errors.New("fake-chmod-err")
+
+			err := fs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Applying umask to sensitive file '/var/vcap/bosh/settings.json'"))
+			Expect(err.Error()).To(ContainSubstring("fake-chmod-err"))
+		})
+	})
+
+	Describe("ConvergeFileContents", func() {
+		It("applies umask when file is written", func() {
+			written, err := fs.ConvergeFileContents("/var/vcap/bosh/settings.json", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(BeTrue())
+
+			Expect(fakeFs.GetFileTestStat("/var/vcap/bosh/settings.json").FileMode).To(Equal(os.FileMode(0600)))
+		})
+
+		It("does not write file when contents are identical", func() {
+			fakeFs.WriteFileString("/var/vcap/bosh/settings.json", "fake-settings")
+
+			written, err := fs.ConvergeFileContents("/var/vcap/bosh/settings.json", []byte("fake-settings"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(BeFalse())
+
+			Expect(fakeFs.RenameOldPaths).To(BeEmpty())
+		})
+	})
+
+	Describe("Chmod", func() {
+		It("masks permissions of sensitive files", func() {
+			fakeFs.WriteFileString("/home/vcap/.ssh/authorized_keys", "fake-key")
+
+			err := fs.Chmod("/home/vcap/.ssh/authorized_keys", os.FileMode(0644))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/home/vcap/.ssh/authorized_keys").FileMode).To(Equal(os.FileMode(0600)))
+		})
+
+		It("keeps permissions of other files", func() {
+			fakeFs.WriteFileString("/var/vcap/jobs/fake-job/bin/ctl", "fake-script")
+
+			err := fs.Chmod("/var/vcap/jobs/fake-job/bin/ctl", os.FileMode(0755))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/var/vcap/jobs/fake-job/bin/ctl").FileMode).To(Equal(os.FileMode(0755)))
+		})
+	})
+
+	Describe("OpenFile", func() {
+		It("masks permissions of sensitive files", func() {
+			_, err := fs.OpenFile("/home/vcap/.ssh/authorized_keys", os.O_CREATE|os.O_WRONLY, os.FileMode(0644))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.GetFileTestStat("/home/vcap/.ssh/authorized_keys").FileMode).To(Equal(os.FileMode(0600)))
+		})
+	})
+})
@@ -0,0 +1,11 @@
+package fakes
+
+type FakeFreeSpaceChecker struct {
+	CheckFreeSpaceMountedPaths []string
+	CheckFreeSpaceErr          error
+}
+
+func (c *FakeFreeSpaceChecker) CheckFreeSpace(mountedPath string) error {
+	c.CheckFreeSpaceMountedPaths = append(c.CheckFreeSpaceMountedPaths, mountedPath)
+	return c.CheckFreeSpaceErr
+}
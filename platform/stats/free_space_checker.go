@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// DefaultMinFreeSpaceInMB is used by agent when minimum free space is not configured
+const DefaultMinFreeSpaceInMB = 256
+
+type FreeSpaceChecker interface {
+	// CheckFreeSpace returns InsufficientSpaceError when
+	// mounted path has less than minimum free space available
+	CheckFreeSpace(mountedPath string) error
+}
+
+type InsufficientSpaceError struct {
+	MountedPath string
+	RequiredMB  uint64
+	AvailableMB uint64
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("Insufficient disk space on %s: required %dMB, available %dMB", e.MountedPath, e.RequiredMB, e.AvailableMB)
+}
+
+type freeSpaceChecker struct {
+	collector        Collector
+	minFreeSpaceInMB uint64
+}
+
+// NewFreeSpaceChecker returns checker that never fails
+// when minFreeSpaceInMB is 0 (or less)
+func NewFreeSpaceChecker(collector Collector, minFreeSpaceInMB int) FreeSpaceChecker {
+	if minFreeSpaceInMB < 0 {
+		minFreeSpaceInMB = 0
+	}
+
+	return freeSpaceChecker{
+		collector:        collector,
+		minFreeSpaceInMB: uint64(minFreeSpaceInMB),
+	}
+}
+
+func (c freeSpaceChecker) CheckFreeSpace(mountedPath string) error {
+	if c.minFreeSpaceInMB == 0 {
+		return nil
+	}
+
+	diskStats, err := c.collector.GetDiskStats(mountedPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting disk stats for %s", mountedPath)
+	}
+
+	var availableMB uint64
+
+	// Disk usage is reported in KB
+	if diskStats.DiskUsage.Total > diskStats.DiskUsage.Used {
+		availableMB = (diskStats.DiskUsage.Total - diskStats.DiskUsage.Used) / 1024
+	}
+
+	if availableMB < c.minFreeSpaceInMB {
+		return InsufficientSpaceError{
+			MountedPath: mountedPath,
+			RequiredMB:  c.minFreeSpaceInMB,
+			AvailableMB: availableMB,
+		}
+	}
+
+	return nil
+}
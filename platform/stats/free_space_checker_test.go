@@ -0,0 +1,80 @@
+package stats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/platform/stats"
+	fakestats "github.com/cloudfoundry/bosh-agent/platform/stats/fakes"
+)
+
+var _ = Describe("FreeSpaceChecker", func() {
+	var (
+		collector *fakestats.FakeCollector
+		checker   FreeSpaceChecker
+	)
+
+	BeforeEach(func() {
+		collector = &fakestats.FakeCollector{
+			DiskStats: map[string]DiskStats{
+				"/fake-data-dir": DiskStats{
+					// 1000MB of 1500MB are used
+					DiskUsage: Usage{Used: 1000 * 1024, Total: 1500 * 1024},
+				},
+			},
+		}
+	})
+
+	Describe("CheckFreeSpace", func() {
+		It("succeeds when there is enough free space", func() {
+			checker = NewFreeSpaceChecker(collector, 500)
+
+			err := checker.CheckFreeSpace("/fake-data-dir")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns insufficient disk space error with required and available space", func() {
+			checker = NewFreeSpaceChecker(collector, 501)
+
+			err := checker.CheckFreeSpace("/fake-data-dir")
+			Expect(err).To(Equal(InsufficientSpaceError{
+				MountedPath: "/fake-data-dir",
+				RequiredMB:  501,
+				AvailableMB: 500,
+			}))
+			Expect(err.Error()).To(Equal("Insufficient disk space on /fake-data-dir: required 501MB, available 500MB"))
+		})
+
+		It("reports no available space when disk is overcommitted", func() {
+			collector.DiskStats["/fake-data-dir"] = DiskStats{
+				DiskUsage: Usage{Used: 1600 * 1024, Total: 1500 * 1024},
+			}
+			checker = NewFreeSpaceChecker(collector, 1)
+
+			err := checker.CheckFreeSpace("/fake-data-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.(InsufficientSpaceError).AvailableMB).To(Equal(uint64(0)))
+		})
+
+		It("does not check free space when minimum free space is 0", func() {
+			collector.DiskStats["/fake-data-dir"] = DiskStats{
+				DiskUsage: Usage{Used: 1500 * 1024, Total: 1500 * 1024},
+			}
+			checker = NewFreeSpaceChecker(collector, 0)
+
+			err := checker.CheckFreeSpace("/fake-data-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = checker.CheckFreeSpace("/fake-unknown-dir")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when disk stats cannot be retrieved", func() {
+			checker = NewFreeSpaceChecker(collector, 1)
+
+			err := checker.CheckFreeSpace("/fake-unknown-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting disk stats for /fake-unknown-dir"))
+		})
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/pivotal-golang/clock"
+
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -11,13 +13,15 @@ import (
 
 type ConcreteUdevDevice struct {
 	runner boshsys.CmdRunner
+	clock  clock.Clock
 	logger boshlog.Logger
 	logtag string
 }
 
-func NewConcreteUdevDevice(runner boshsys.CmdRunner, logger boshlog.Logger) ConcreteUdevDevice {
+func NewConcreteUdevDevice(runner boshsys.CmdRunner, clock clock.Clock, logger boshlog.Logger) ConcreteUdevDevice {
 	return ConcreteUdevDevice{
 		runner: runner,
+		clock:  clock,
 		logger: logger,
 		logtag: "ConcreteUdevDevice",
 	}
@@ -31,7 +35,7 @@ func (udev ConcreteUdevDevice) KickDevice(filePath string) {
 		if err == nil {
 			break
 		}
-		time.Sleep(time.Second / 2)
+		udev.clock.Sleep(time.Second / 2)
 	}
 
 	if err := udev.readByte(filePath); err != nil {
@@ -76,7 +80,7 @@ func (udev ConcreteUdevDevice) EnsureDeviceReadable(filePath string) error {
 			udev.logger.Debug(udev.logtag, "Ignorable error from readByte: %s", err.Error())
 		}
 
-		time.Sleep(time.Second / 2)
+		udev.clock.Sleep(time.Second / 2)
 	}
 
 	err := udev.readByte(filePath)
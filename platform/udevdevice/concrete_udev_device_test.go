@@ -5,6 +5,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock"
 
 	fakes "github.com/cloudfoundry/bosh-utils/system/fakes"
 
@@ -24,7 +25,7 @@ var _ = Describe("ConcreteUdevDevice", func() {
 	})
 
 	JustBeforeEach(func() {
-		udev = NewConcreteUdevDevice(cmdRunner, logger)
+		udev = NewConcreteUdevDevice(cmdRunner, clock.NewClock(), logger)
 	})
 
 	Describe("#Settle", func() {
@@ -1,14 +1,18 @@
 package platform
 
 import (
+	"github.com/pivotal-golang/clock"
+
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
 	boshcert "github.com/cloudfoundry/bosh-agent/platform/cert"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshnet "github.com/cloudfoundry/bosh-agent/platform/net"
 	boshstats "github.com/cloudfoundry/bosh-agent/platform/stats"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -34,6 +38,7 @@ func NewWindowsPlatform(
 	dirProvider boshdirs.Provider,
 	netManager boshnet.Manager,
 	devicePathResolver boshdpresolv.DevicePathResolver,
+	timeService clock.Clock,
 	logger boshlog.Logger,
 ) Platform {
 	return &WindowsPlatform{
@@ -46,7 +51,7 @@ func NewWindowsPlatform(
 		netManager:         netManager,
 		devicePathResolver: devicePathResolver,
 		vitalsService:      boshvitals.NewService(collector, dirProvider),
-		certManager:        boshcert.NewDummyCertManager(fs, cmdRunner, 0, logger),
+		certManager:        boshcert.NewDummyCertManager(fs, cmdRunner, 0, timeService, logger),
 	}
 }
 
@@ -111,7 +116,8 @@ func (p WindowsPlatform) SetupHostname(hostname string) (err error) {
 }
 
 func (p WindowsPlatform) SetupNetworking(networks boshsettings.Networks) (err error) {
-	return p.netManager.SetupNetworking(networks, nil)
+	_, err = p.netManager.SetupNetworking(networks, nil)
+	return
 }
 
 func (p WindowsPlatform) GetConfiguredNetworkInterfaces() (interfaces []string, err error) {
@@ -122,7 +128,15 @@ func (p WindowsPlatform) GetCertManager() (certManager boshcert.Manager) {
 	return p.certManager
 }
 
-func (p WindowsPlatform) SetupLogrotate(groupName, basePath, size string) (err error) {
+func (p WindowsPlatform) SetupLogrotate(groupName, basePath, size string, jobNames []string) (err error) {
+	return
+}
+
+func (p WindowsPlatform) SetupJobLogrotate(jobName, basePath, size string, rotate int) (err error) {
+	return
+}
+
+func (p WindowsPlatform) KeepOnlyJobsLogrotate(jobNames []string) (err error) {
 	return
 }
 
@@ -130,7 +144,11 @@ func (p WindowsPlatform) SetTimeWithNtpServers(servers []string) (err error) {
 	return
 }
 
-func (p WindowsPlatform) SetupEphemeralDiskWithPath(devicePath string) (err error) {
+func (p WindowsPlatform) SyncTimeWithNtpServers(servers []string) (err error) {
+	return bosherr.Error("Syncing time with NTP servers is not supported on Windows")
+}
+
+func (p WindowsPlatform) SetupEphemeralDiskWithPath(devicePath string, fsType boshdisk.FileSystemType, disableSwap bool) (err error) {
 	return
 }
 
@@ -154,6 +172,10 @@ func (p WindowsPlatform) UnmountPersistentDisk(diskSettings boshsettings.DiskSet
 	return
 }
 
+func (p WindowsPlatform) UnmountPersistentDiskMountPoint(mountPoint string) (didUnmount bool, err error) {
+	return
+}
+
 func (p WindowsPlatform) GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string {
 	return ""
 }
@@ -182,6 +204,14 @@ func (p WindowsPlatform) IsPersistentDiskMountable(diskSettings boshsettings.Dis
 	return true, nil
 }
 
+func (p WindowsPlatform) SetupJobStores(jobNames []string) (err error) {
+	return
+}
+
+func (p WindowsPlatform) GetPersistentDiskMountPoint(diskID string) string {
+	return p.dirProvider.StoreDir()
+}
+
 func (p WindowsPlatform) StartMonit() (err error) {
 	return
 }
@@ -210,10 +240,23 @@ func (p WindowsPlatform) GetDefaultNetwork() (boshsettings.Network, error) {
 	return boshsettings.Network{}, nil
 }
 
+func (p WindowsPlatform) GetDefaultGatewayInterface() (string, error) {
+	return "", nil
+}
+
 func (p WindowsPlatform) GetHostPublicKey() (string, error) {
 	return "", nil
 }
 
+func (p WindowsPlatform) Reboot() error {
+	_, _, _, err := p.cmdRunner.RunCommand("shutdown", "/r", "/t", "0")
+	if err != nil {
+		return bosherr.WrapError(err, "Rebooting")
+	}
+
+	return nil
+}
+
 func (p WindowsPlatform) DeleteARPEntryWithIP(ip string) error {
 	return nil
 }
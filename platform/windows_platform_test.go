@@ -1,8 +1,11 @@
 package platform_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	fakedpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver/fakes"
 	. "github.com/cloudfoundry/bosh-agent/platform"
@@ -48,6 +51,7 @@ var _ = Describe("WindowsPlatform", func() {
 			dirProvider,
 			netManager,
 			devicePathResolver,
+			fakeclock.NewFakeClock(time.Now()),
 			logger,
 		)
 	})
@@ -71,4 +75,12 @@ var _ = Describe("WindowsPlatform", func() {
 			Expect(netManager.SetupNetworkingNetworks).To(Equal(networks))
 		})
 	})
+
+	Describe("SyncTimeWithNtpServers", func() {
+		It("returns error since it is not supported", func() {
+			err := platform.SyncTimeWithNtpServers([]string{"0.north-america.pool.ntp.org"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Syncing time with NTP servers is not supported on Windows"))
+		})
+	})
 })
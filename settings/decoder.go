@@ -0,0 +1,92 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Decoder unmarshals settings payloads delivered by settings sources.
+// All decoders respect json struct tags so that either format
+// populates the same fields.
+type Decoder interface {
+	Decode(data []byte, v interface{}) error
+}
+
+// NewDecoder defaults to JSON when format is not specified
+func NewDecoder(format string) (Decoder, error) {
+	switch format {
+	case "", FormatJSON:
+		return NewJSONDecoder(), nil
+	case FormatYAML:
+		return NewYAMLDecoder(), nil
+	default:
+		return nil, bosherr.Errorf("Unknown settings format '%s'", format)
+	}
+}
+
+type jsonDecoder struct{}
+
+func NewJSONDecoder() Decoder {
+	return jsonDecoder{}
+}
+
+func (d jsonDecoder) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type yamlDecoder struct{}
+
+func NewYAMLDecoder() Decoder {
+	return yamlDecoder{}
+}
+
+// Decode converts YAML document into JSON first so that
+// custom JSON unmarshalling of settings types is reused
+func (d yamlDecoder) Decode(data []byte, v interface{}) error {
+	var value interface{}
+
+	// Strict mode rejects duplicate keys instead of keeping the last one
+	err := yaml.UnmarshalStrict(data, &value)
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing YAML")
+	}
+
+	jsonBytes, err := json.Marshal(jsonCompatibleYAML(value))
+	if err != nil {
+		return bosherr.WrapError(err, "Converting YAML to JSON")
+	}
+
+	return json.Unmarshal(jsonBytes, v)
+}
+
+// jsonCompatibleYAML converts mappings with non-string keys
+// produced by YAML parser into mappings that JSON can marshal
+func jsonCompatibleYAML(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, val := range typedValue {
+			result[fmt.Sprintf("%v", key)] = jsonCompatibleYAML(val)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(typedValue))
+		for i, val := range typedValue {
+			result[i] = jsonCompatibleYAML(val)
+		}
+		return result
+
+	default:
+		return value
+	}
+}
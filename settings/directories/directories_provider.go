@@ -1,13 +1,26 @@
 package directories
 
-import "path"
+import (
+	"net/url"
+	"path"
+	"strings"
+)
 
 type Provider struct {
-	baseDir string
+	baseDir            string
+	compileDir         string
+	authorizedKeysFile string
 }
 
 func NewProvider(baseDir string) Provider {
-	return Provider{baseDir}
+	return Provider{baseDir: baseDir}
+}
+
+// WithCompileDir moves compilation work dirs out of the data dir,
+// e.g. onto a larger ephemeral volume; empty dir keeps the default
+func (p Provider) WithCompileDir(compileDir string) Provider {
+	p.compileDir = compileDir
+	return p
 }
 
 func (p Provider) BaseDir() string {
@@ -38,11 +51,56 @@ func (p Provider) StoreMigrationDir() string {
 	return path.Join(p.BaseDir(), "store_migration_target")
 }
 
+// StoreDirForDisk is used when persistent disks are mounted side by side.
+// Disk id is escaped so that it cannot point outside of stores dir,
+// e.g. "../store" becomes "..%2Fstore" and ".." becomes "%2E%2E".
+func (p Provider) StoreDirForDisk(diskID string) string {
+	name := url.PathEscape(diskID)
+
+	if strings.Trim(name, ".") == "" {
+		name = strings.Replace(name, ".", "%2E", -1)
+	}
+
+	return path.Join(p.BaseDir(), "stores", name)
+}
+
 func (p Provider) PkgDir() string {
 	return path.Join(p.DataDir(), "packages")
 }
 
+// EnabledPackagesDir contains links to installed packages in PkgDir
+func (p Provider) EnabledPackagesDir() string {
+	return path.Join(p.BaseDir(), "packages")
+}
+
+// WithAuthorizedKeysFile sets where SSH public keys of users are installed
+// using sshd_config AuthorizedKeysFile syntax: %h is replaced by home dir,
+// %u by username and relative paths are relative to home dir,
+// e.g. "/etc/ssh/authorized_keys/%u"; empty file keeps the default
+func (p Provider) WithAuthorizedKeysFile(authorizedKeysFile string) Provider {
+	p.authorizedKeysFile = authorizedKeysFile
+	return p
+}
+
+// AuthorizedKeysPath defaults to ~user/.ssh/authorized_keys
+func (p Provider) AuthorizedKeysPath(username, homeDir string) string {
+	if p.authorizedKeysFile == "" {
+		return path.Join(homeDir, ".ssh", "authorized_keys")
+	}
+
+	keysPath := strings.NewReplacer("%%", "%", "%h", homeDir, "%u", username).Replace(p.authorizedKeysFile)
+
+	if !path.IsAbs(keysPath) {
+		keysPath = path.Join(homeDir, keysPath)
+	}
+
+	return path.Clean(keysPath)
+}
+
 func (p Provider) CompileDir() string {
+	if p.compileDir != "" {
+		return p.compileDir
+	}
 	return path.Join(p.DataDir(), "compile")
 }
 
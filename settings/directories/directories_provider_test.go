@@ -0,0 +1,65 @@
+package directories_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/settings/directories"
+)
+
+var _ = Describe("Provider", func() {
+	It("returns dir with links to enabled packages", func() {
+		Expect(NewProvider("/var/vcap").EnabledPackagesDir()).To(Equal("/var/vcap/packages"))
+	})
+
+	Describe("AuthorizedKeysPath", func() {
+		It("defaults to authorized_keys in ssh dir of user home", func() {
+			Expect(NewProvider("/var/vcap").AuthorizedKeysPath("vcap", "/home/vcap")).To(Equal("/home/vcap/.ssh/authorized_keys"))
+		})
+
+		It("replaces username and home dir in configured file", func() {
+			provider := NewProvider("/var/vcap").WithAuthorizedKeysFile("/etc/ssh/keys/%u/%h/authorized_keys")
+			Expect(provider.AuthorizedKeysPath("vcap", "/home/vcap")).To(Equal("/etc/ssh/keys/vcap/home/vcap/authorized_keys"))
+		})
+
+		It("returns configured relative file inside of home dir", func() {
+			provider := NewProvider("/var/vcap").WithAuthorizedKeysFile(".ssh/authorized_keys2")
+			Expect(provider.AuthorizedKeysPath("vcap", "/home/vcap")).To(Equal("/home/vcap/.ssh/authorized_keys2"))
+		})
+
+		It("keeps escaped percent sign", func() {
+			provider := NewProvider("/var/vcap").WithAuthorizedKeysFile("/etc/ssh/100%%/%u")
+			Expect(provider.AuthorizedKeysPath("vcap", "/home/vcap")).To(Equal("/etc/ssh/100%/vcap"))
+		})
+	})
+
+	Describe("StoreDirForDisk", func() {
+		It("returns dir named after disk id in stores dir", func() {
+			Expect(NewProvider("/var/vcap").StoreDirForDisk("fake-disk-id")).To(Equal("/var/vcap/stores/fake-disk-id"))
+		})
+
+		It("escapes disk ids that would point outside of stores dir", func() {
+			provider := NewProvider("/var/vcap")
+			Expect(provider.StoreDirForDisk("fake/disk-id")).To(Equal("/var/vcap/stores/fake%2Fdisk-id"))
+			Expect(provider.StoreDirForDisk("../store")).To(Equal("/var/vcap/stores/..%2Fstore"))
+			Expect(provider.StoreDirForDisk("..")).To(Equal("/var/vcap/stores/%2E%2E"))
+			Expect(provider.StoreDirForDisk(".")).To(Equal("/var/vcap/stores/%2E"))
+		})
+	})
+
+	Describe("CompileDir", func() {
+		It("defaults to compile dir in data dir", func() {
+			Expect(NewProvider("/var/vcap").CompileDir()).To(Equal("/var/vcap/data/compile"))
+		})
+
+		It("keeps default when configured compile dir is empty", func() {
+			Expect(NewProvider("/var/vcap").WithCompileDir("").CompileDir()).To(Equal("/var/vcap/data/compile"))
+		})
+
+		It("returns configured compile dir without moving other dirs", func() {
+			provider := NewProvider("/var/vcap").WithCompileDir("/fake-ephemeral/compile")
+			Expect(provider.CompileDir()).To(Equal("/fake-ephemeral/compile"))
+			Expect(provider.DataDir()).To(Equal("/var/vcap/data"))
+		})
+	})
+})
@@ -8,8 +8,9 @@ type FakeSettingsService struct {
 	PublicKey    string
 	PublicKeyErr error
 
-	LoadSettingsError  error
-	SettingsWereLoaded bool
+	LoadSettingsError    error
+	LoadSettingsCallback func()
+	SettingsWereLoaded   bool
 
 	InvalidateSettingsError error
 	SettingsWereInvalidated bool
@@ -28,6 +29,9 @@ func (service *FakeSettingsService) PublicSSHKeyForUsername(_ string) (string, e
 
 func (service *FakeSettingsService) LoadSettings() error {
 	service.SettingsWereLoaded = true
+	if service.LoadSettingsCallback != nil {
+		service.LoadSettingsCallback()
+	}
 	return service.LoadSettingsError
 }
 
@@ -2,6 +2,7 @@ package settings
 
 import (
 	"encoding/json"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -25,6 +26,7 @@ type settingsService struct {
 	fs                     boshsys.FileSystem
 	settingsPath           string
 	settings               Settings
+	settingsMutex          sync.RWMutex
 	settingsSource         Source
 	defaultNetworkResolver DefaultNetworkResolver
 	logger                 boshlog.Logger
@@ -34,6 +36,9 @@ type DefaultNetworkResolver interface {
 	// Ideally we would find a network based on a MAC address
 	// but current CPI implementations do not include it
 	GetDefaultNetwork() (Network, error)
+
+	// Name of the interface holding default route, e.g. once DHCP configured it
+	GetDefaultGatewayInterface() (string, error)
 }
 
 func NewService(
@@ -72,17 +77,21 @@ func (s *settingsService) LoadSettings() error {
 
 		s.logger.Debug(settingsServiceLogTag, "Successfully read settings from file")
 
-		err := json.Unmarshal(existingSettingsJSON, &s.settings)
+		var existingSettings Settings
+
+		err := json.Unmarshal(existingSettingsJSON, &existingSettings)
 		if err != nil {
 			s.logger.Error(settingsServiceLogTag, "Failed unmarshalling settings from file %s", err.Error())
 			return bosherr.WrapError(fetchErr, "Invoking settings fetcher")
 		}
 
+		s.setSettings(existingSettings)
+
 		return nil
 	}
 
 	s.logger.Debug(settingsServiceLogTag, "Successfully received settings from fetcher")
-	s.settings = newSettings
+	s.setSettings(newSettings)
 
 	newSettingsJSON, err := json.Marshal(newSettings)
 	if err != nil {
@@ -98,8 +107,24 @@ func (s *settingsService) LoadSettings() error {
 }
 
 // GetSettings returns setting even if it fails to resolve IPs for dynamic networks.
+// It is safe to call while settings are being reloaded.
 func (s *settingsService) GetSettings() Settings {
-	for networkName, network := range s.settings.Networks {
+	s.settingsMutex.RLock()
+	settings := s.settings
+	s.settingsMutex.RUnlock()
+
+	if settings.Networks == nil {
+		return settings
+	}
+
+	// Resolved networks go into a copy so that callers never share the map
+	networks := Networks{}
+	for networkName, network := range settings.Networks {
+		networks[networkName] = network
+	}
+	settings.Networks = networks
+
+	for networkName, network := range networks {
 		if !network.IsDHCP() {
 			continue
 		}
@@ -109,10 +134,17 @@ func (s *settingsService) GetSettings() Settings {
 			break
 		}
 
-		s.settings.Networks[networkName] = resolvedNetwork
+		networks[networkName] = resolvedNetwork
 	}
 
-	return s.settings
+	return settings
+}
+
+func (s *settingsService) setSettings(settings Settings) {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	s.settings = settings
 }
 
 func (s *settingsService) InvalidateSettings() error {
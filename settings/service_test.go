@@ -3,6 +3,8 @@ package settings_test
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -272,5 +274,57 @@ func init() {
 				})
 			})
 		})
+
+		Describe("concurrent access", func() {
+			buildSettings := func(i int) Settings {
+				return Settings{
+					AgentID:  fmt.Sprintf("fake-agent-id-%d", i),
+					Networks: Networks{"fake-net": Network{IP: fmt.Sprintf("fake-ip-%d", i), Netmask: "fake-netmask"}},
+				}
+			}
+
+			It("allows reading settings while they are reloaded", func() {
+				fakeSettingsSource.SettingsValue = buildSettings(0)
+				service, _ := buildService()
+				Expect(service.LoadSettings()).To(Succeed())
+
+				done := make(chan struct{})
+				wg := &sync.WaitGroup{}
+
+				for i := 0; i < 5; i++ {
+					wg.Add(1)
+
+					go func() {
+						defer GinkgoRecover()
+						defer wg.Done()
+
+						for {
+							select {
+							case <-done:
+								return
+							default:
+							}
+
+							settings := service.GetSettings()
+							Expect(settings.AgentID).To(HavePrefix("fake-agent-id-"))
+							Expect(settings.Networks["fake-net"].IP).To(HavePrefix("fake-ip-"))
+
+							// Returned networks must not be shared with other readers
+							settings.Networks["fake-net"] = Network{IP: "fake-ip-from-reader", Netmask: "fake-netmask"}
+						}
+					}()
+				}
+
+				for i := 1; i <= 100; i++ {
+					fakeSettingsSource.SettingsValue = buildSettings(i)
+					Expect(service.LoadSettings()).To(Succeed())
+				}
+
+				close(done)
+				wg.Wait()
+
+				Expect(service.GetSettings()).To(Equal(buildSettings(100)))
+			})
+		})
 	})
 }
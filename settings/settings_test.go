@@ -2,6 +2,7 @@ package settings_test
 
 import (
 	"encoding/json"
+	"fmt"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -72,6 +73,34 @@ var _ = Describe("Settings", func() {
 					}))
 				})
 
+				It("gets mount options from env", func() {
+					settingsJSON := `{"env": {"persistent_disk_mount_options": ["noatime", "nodev"]}}`
+
+					err := json.Unmarshal([]byte(settingsJSON), &settings)
+					Expect(err).NotTo(HaveOccurred())
+					diskSettings, _ := settings.PersistentDiskSettings("fake-disk-id")
+					Expect(diskSettings.MountOptions).To(Equal([]string{"noatime", "nodev"}))
+				})
+
+				It("gets encryption passphrase from env", func() {
+					settingsJSON := `{"env": {"persistent_disk_passphrase": "fake-passphrase"}}`
+
+					err := json.Unmarshal([]byte(settingsJSON), &settings)
+					Expect(err).NotTo(HaveOccurred())
+					diskSettings, _ := settings.PersistentDiskSettings("fake-disk-id")
+					Expect(diskSettings.EncryptionPassphrase).To(Equal(Secret("fake-passphrase")))
+				})
+
+				It("does not include encryption passphrase when disk settings are formatted", func() {
+					settingsJSON := `{"env": {"persistent_disk_passphrase": "fake-passphrase"}}`
+
+					err := json.Unmarshal([]byte(settingsJSON), &settings)
+					Expect(err).NotTo(HaveOccurred())
+					diskSettings, _ := settings.PersistentDiskSettings("fake-disk-id")
+					Expect(fmt.Sprintf("%+v", diskSettings)).ToNot(ContainSubstring("fake-passphrase"))
+					Expect(fmt.Sprintf("%+v", diskSettings)).To(ContainSubstring("EncryptionPassphrase:REDACTED"))
+				})
+
 				It("does not crash if env does not have a filesystem type", func() {
 					settingsJSON := `{"env": {"bosh": {"password": "secret"}}}`
 
@@ -250,6 +279,93 @@ var _ = Describe("Settings", func() {
 		})
 	})
 
+	Describe("ValidatePersistentDisk", func() {
+		It("accepts disk settings with device path", func() {
+			settings = Settings{Disks: Disks{Persistent: map[string]interface{}{
+				"fake-disk-id": map[string]interface{}{"path": "/dev/sdb"},
+			}}}
+			Expect(settings.ValidatePersistentDisk("fake-disk-id")).To(Succeed())
+		})
+
+		It("accepts old CPI string disk settings", func() {
+			settings = Settings{Disks: Disks{Persistent: map[string]interface{}{
+				"fake-disk-id": "/dev/sdb",
+			}}}
+			Expect(settings.ValidatePersistentDisk("fake-disk-id")).To(Succeed())
+		})
+
+		It("accepts disk settings located by lun and host device id", func() {
+			settings = Settings{Disks: Disks{Persistent: map[string]interface{}{
+				"fake-disk-id": map[string]interface{}{"lun": "0", "host_device_id": "fake-host-device-id"},
+			}}}
+			Expect(settings.ValidatePersistentDisk("fake-disk-id")).To(Succeed())
+		})
+
+		Context("when device path is missing", func() {
+			It("returns error identifying the disk for empty hash", func() {
+				settings = Settings{Disks: Disks{Persistent: map[string]interface{}{
+					"fake-disk-id":      map[string]interface{}{"path": ""},
+					"fake-good-disk-id": map[string]interface{}{"path": "/dev/sdb"},
+				}}}
+
+				err := settings.ValidatePersistentDisk("fake-disk-id")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Persistent disk 'fake-disk-id' is missing device path"))
+
+				Expect(settings.ValidatePersistentDisk("fake-good-disk-id")).To(Succeed())
+			})
+
+			It("returns error identifying the disk for null settings", func() {
+				err := json.Unmarshal([]byte(`{"disks": {"persistent": {"fake-disk-id": null}}}`), &settings)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = settings.ValidatePersistentDisk("fake-disk-id")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Persistent disk 'fake-disk-id' is missing device path"))
+			})
+
+			It("does not panic when getting disk settings", func() {
+				err := json.Unmarshal([]byte(`{"disks": {"persistent": {"fake-disk-id": null, "fake-other-disk-id": {"path": 5}}}}`), &settings)
+				Expect(err).NotTo(HaveOccurred())
+
+				diskSettings, found := settings.PersistentDiskSettings("fake-disk-id")
+				Expect(found).To(BeTrue())
+				Expect(diskSettings).To(Equal(DiskSettings{ID: "fake-disk-id"}))
+
+				diskSettings, found = settings.PersistentDiskSettings("fake-other-disk-id")
+				Expect(found).To(BeTrue())
+				Expect(diskSettings).To(Equal(DiskSettings{ID: "fake-other-disk-id"}))
+			})
+		})
+
+		It("returns error when device path is not a string", func() {
+			err := json.Unmarshal([]byte(`{"disks": {"persistent": {"fake-disk-id": {"path": 5}}}}`), &settings)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = settings.ValidatePersistentDisk("fake-disk-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Persistent disk 'fake-disk-id' has non-string path '5'"))
+		})
+
+		It("returns error identifying the device path when disk id is missing", func() {
+			settings = Settings{Disks: Disks{Persistent: map[string]interface{}{
+				"": map[string]interface{}{"path": "/dev/sdb"},
+			}}}
+
+			err := settings.ValidatePersistentDisk("")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Persistent disk with settings 'map[path:/dev/sdb]' is missing disk id"))
+		})
+
+		It("returns error when disk is not present", func() {
+			settings = Settings{}
+
+			err := settings.ValidatePersistentDisk("fake-disk-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Persistent disk 'fake-disk-id' could not be found"))
+		})
+	})
+
 	Describe("EphemeralDiskSettings", func() {
 		Context("when the disk settings are a string", func() {
 			BeforeEach(func() {
@@ -294,6 +410,28 @@ var _ = Describe("Settings", func() {
 			})
 		})
 
+		Context("when the disk settings hash specifies size and model", func() {
+			BeforeEach(func() {
+				settings = Settings{
+					Disks: Disks{
+						Ephemeral: map[string]interface{}{
+							"path":  "fake-disk-path",
+							"size":  float64(10240),
+							"model": "fake-model",
+						},
+					},
+				}
+			})
+
+			It("converts disk settings", func() {
+				Expect(settings.EphemeralDiskSettings()).To(Equal(DiskSettings{
+					Path:  "fake-disk-path",
+					Size:  10240,
+					Model: "fake-model",
+				}))
+			})
+		})
+
 		Context("when path is not provided", func() {
 			BeforeEach(func() {
 				settings = Settings{
@@ -313,6 +451,20 @@ var _ = Describe("Settings", func() {
 				}))
 			})
 		})
+
+		Context("when Env is provided", func() {
+			It("gets filesystem type from env", func() {
+				settingsJSON := `{"disks": {"ephemeral": "fake-disk-value"}, "env": {"ephemeral_disk_fs": "xfs"}}`
+
+				err := json.Unmarshal([]byte(settingsJSON), &settings)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(settings.EphemeralDiskSettings()).To(Equal(DiskSettings{
+					VolumeID:       "fake-disk-value",
+					Path:           "fake-disk-value",
+					FileSystemType: disk.FileSystemXFS,
+				}))
+			})
+		})
 	})
 
 	Describe("DefaultNetworkFor", func() {
@@ -404,6 +556,66 @@ var _ = Describe("Settings", func() {
 		})
 	})
 
+	Describe("DNSServers", func() {
+		It("returns empty list when networks have no dns servers", func() {
+			networks := Networks{"first": Network{}}
+			Expect(networks.DNSServers()).To(Equal([]string{}))
+		})
+
+		It("returns servers of network marked default for dns first, then servers of other networks by network name", func() {
+			networks := Networks{
+				"c-first": Network{
+					DNS: []string{"cc.cc.cc.cc"},
+				},
+				"b-second": Network{
+					Default: []string{"gateway", "dns"},
+					DNS:     []string{"xx.xx.xx.xx", "yy.yy.yy.yy"},
+				},
+				"a-third": Network{
+					DNS: []string{"aa.aa.aa.aa"},
+				},
+			}
+
+			Expect(networks.DNSServers()).To(Equal([]string{
+				"xx.xx.xx.xx", "yy.yy.yy.yy", "aa.aa.aa.aa", "cc.cc.cc.cc",
+			}))
+		})
+
+		It("orders several networks marked default for dns by network name", func() {
+			networks := Networks{
+				"second": Network{
+					Default: []string{"dns"},
+					DNS:     []string{"bb.bb.bb.bb"},
+				},
+				"first": Network{
+					Default: []string{"dns"},
+					DNS:     []string{"aa.aa.aa.aa"},
+				},
+				"third": Network{
+					DNS: []string{"cc.cc.cc.cc"},
+				},
+			}
+
+			for i := 0; i < 100; i++ {
+				Expect(networks.DNSServers()).To(Equal([]string{"aa.aa.aa.aa", "bb.bb.bb.bb", "cc.cc.cc.cc"}))
+			}
+		})
+
+		It("keeps servers listed by several networks only at their first position", func() {
+			networks := Networks{
+				"first": Network{
+					DNS: []string{"aa.aa.aa.aa", "xx.xx.xx.xx"},
+				},
+				"second": Network{
+					Default: []string{"dns"},
+					DNS:     []string{"xx.xx.xx.xx", "yy.yy.yy.yy"},
+				},
+			}
+
+			Expect(networks.DNSServers()).To(Equal([]string{"xx.xx.xx.xx", "yy.yy.yy.yy", "aa.aa.aa.aa"}))
+		})
+	})
+
 	Describe("DefaultIP", func() {
 		It("with two networks", func() {
 			networks := Networks{
@@ -636,6 +848,69 @@ var _ = Describe("Settings", func() {
 		})
 	})
 
+	Describe("PrimaryIP", func() {
+		It("returns IP of network that is default for gateway", func() {
+			settings := Settings{
+				Networks: Networks{
+					"bosh":  Network{IP: "xx.xx.xx.xx", Default: []string{"dns"}},
+					"other": Network{IP: "aa.aa.aa.aa", Default: []string{"gateway"}},
+				},
+			}
+
+			ip, err := settings.PrimaryIP()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(Equal("aa.aa.aa.aa"))
+		})
+
+		It("returns IP of the only network", func() {
+			settings := Settings{Networks: Networks{"bosh": Network{IP: "xx.xx.xx.xx"}}}
+
+			ip, err := settings.PrimaryIP()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(Equal("xx.xx.xx.xx"))
+		})
+
+		It("returns error when no network is default for gateway", func() {
+			settings := Settings{
+				Networks: Networks{
+					"bosh":  Network{IP: "xx.xx.xx.xx"},
+					"other": Network{IP: "aa.aa.aa.aa", Default: []string{"dns"}},
+				},
+			}
+
+			_, err := settings.PrimaryIP()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No default network for gateway"))
+		})
+
+		It("returns error when default network does not specify IP", func() {
+			settings := Settings{
+				Networks: Networks{
+					"bosh": Network{Type: "dynamic", Default: []string{"gateway"}},
+				},
+			}
+
+			_, err := settings.PrimaryIP()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Default network for gateway does not specify IP"))
+		})
+	})
+
+	Describe("Hostname", func() {
+		It("returns hostname from env", func() {
+			settings := Settings{
+				AgentID: "fake-agent-id",
+				Env:     Env{Bosh: BoshEnv{Hostname: "fake-hostname"}},
+			}
+			Expect(settings.Hostname()).To(Equal("fake-hostname"))
+		})
+
+		It("returns agent id when env does not specify hostname", func() {
+			settings := Settings{AgentID: "fake-agent-id"}
+			Expect(settings.Hostname()).To(Equal("fake-agent-id"))
+		})
+	})
+
 	Describe("Env", func() {
 		It("unmarshal env value correctly", func() {
 			var env Env
@@ -647,5 +922,109 @@ var _ = Describe("Settings", func() {
 			Expect(env.GetKeepRootPassword()).To(BeFalse())
 			Expect(env.GetRemoveDevTools()).To(BeTrue())
 		})
+
+		It("unmarshals dns update settings", func() {
+			var env Env
+			envJSON := `{"bosh": {"dns_update": {"server": "fake-server", "key_name": "fake-key-name", "key_algorithm": "hmac-sha512", "key": "fake-key", "ttl": 60}}}`
+
+			err := json.Unmarshal([]byte(envJSON), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.DNSUpdate).To(Equal(DNSUpdate{
+				Server:       "fake-server",
+				KeyName:      "fake-key-name",
+				KeyAlgorithm: "hmac-sha512",
+				Key:          "fake-key",
+				TTL:          60,
+			}))
+		})
+
+		It("unmarshals mbus certificates", func() {
+			var env Env
+			envJSON := `{"bosh": {"mbus": {"cert": {"ca": "fake-ca", "certificate": "fake-cert", "private_key": "fake-key"}}}}`
+
+			err := json.Unmarshal([]byte(envJSON), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Mbus.Cert).To(Equal(CertKeyPair{
+				CA:          "fake-ca",
+				Certificate: "fake-cert",
+				PrivateKey:  "fake-key",
+			}))
+		})
+
+		It("unmarshals errand restrictions", func() {
+			var env Env
+			envJSON := `{"bosh": {"errand": {"chroot": "/fake-chroot", "memory_limit_mb": 512, "cpu_quota_percent": 50}}}`
+
+			err := json.Unmarshal([]byte(envJSON), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Bosh.Errand).To(Equal(ErrandEnv{
+				Chroot:          "/fake-chroot",
+				MemoryLimitMB:   512,
+				CPUQuotaPercent: 50,
+			}))
+			Expect(env.Bosh.Errand.IsRestricted()).To(BeTrue())
+		})
+
+		It("does not restrict errands by default", func() {
+			Expect(ErrandEnv{}.IsRestricted()).To(BeFalse())
+		})
+
+		It("unmarshals custom environment variables", func() {
+			var env Env
+			envJSON := `{"custom": {"HTTP_PROXY": "http://fake-proxy:3128"}}`
+
+			err := json.Unmarshal([]byte(envJSON), &env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env.Custom).To(Equal(CustomEnv{"HTTP_PROXY": "http://fake-proxy:3128"}))
+		})
+	})
+
+	Describe("CustomEnv", func() {
+		Describe("Validate", func() {
+			It("accepts variables that do not use reserved names", func() {
+				Expect(CustomEnv{"FOO": "bar", "HTTP_PROXY": "http://fake-proxy:3128"}.Validate()).To(Succeed())
+			})
+
+			It("rejects variables with reserved BOSH_ prefix", func() {
+				err := CustomEnv{"BOSH_JOB_NAME": "other-job"}.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Custom environment variable 'BOSH_JOB_NAME' uses reserved prefix 'BOSH_'"))
+			})
+
+			It("rejects variables that change how scripts are found and run", func() {
+				for _, name := range []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "PWD", "IFS", "LD_PRELOAD", "LD_LIBRARY_PATH"} {
+					err := CustomEnv{name: "fake-value"}.Validate()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal(fmt.Sprintf("Custom environment variable '%s' is reserved", name)))
+				}
+			})
+		})
+
+		Describe("Apply", func() {
+			It("adds custom variables to the environment", func() {
+				env := map[string]string{"PATH": "/usr/bin"}
+
+				err := CustomEnv{"FOO": "bar"}.Apply(env)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env).To(Equal(map[string]string{"PATH": "/usr/bin", "FOO": "bar"}))
+			})
+
+			It("rejects variables with reserved BOSH_ prefix without changing the environment", func() {
+				env := map[string]string{"BOSH_JOB_NAME": "fake-job"}
+
+				err := CustomEnv{"FOO": "bar", "BOSH_JOB_NAME": "other-job"}.Apply(env)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Custom environment variable 'BOSH_JOB_NAME' uses reserved prefix 'BOSH_'"))
+				Expect(env).To(Equal(map[string]string{"BOSH_JOB_NAME": "fake-job"}))
+			})
+
+			It("rejects reserved variables without changing the environment", func() {
+				env := map[string]string{"PATH": "/usr/bin"}
+
+				err := CustomEnv{"PATH": "/fake-bin"}.Apply(env)
+				Expect(err).To(HaveOccurred())
+				Expect(env).To(Equal(map[string]string{"PATH": "/usr/bin"}))
+			})
+		})
 	})
 })
@@ -0,0 +1,5 @@
+package version
+
+// Version of the agent, overridden at build time with
+// -ldflags "-X github.com/cloudfoundry/bosh-agent/version.Version=<version>"
+var Version = "dev"